@@ -33,14 +33,20 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/RedHatInsights/insights-operator-utils/logger"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/RedHatInsights/insights-results-aggregator/archive"
+	"github.com/RedHatInsights/insights-results-aggregator/backup"
+	"github.com/RedHatInsights/insights-results-aggregator/chaos"
 	"github.com/RedHatInsights/insights-results-aggregator/conf"
+	"github.com/RedHatInsights/insights-results-aggregator/export"
 	"github.com/RedHatInsights/insights-results-aggregator/metrics"
 	"github.com/RedHatInsights/insights-results-aggregator/migration"
+	"github.com/RedHatInsights/insights-results-aggregator/server"
 	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
 )
@@ -58,8 +64,17 @@ const (
 	ExitStatusServerError
 	// ExitStatusMigrationError is returned in case of an error while attempting to perform DB migrations
 	ExitStatusMigrationError
+	// ExitStatusExportError is returned in case of an error while exporting data into Parquet files
+	ExitStatusExportError
+	// ExitStatusSchedulerError is returned in case of an error while starting the scheduler
+	ExitStatusSchedulerError
+	// ExitStatusConfigurationError is returned when the configuration is not valid
+	ExitStatusConfigurationError
+	// ExitStatusBackupError is returned in case of an error while writing or restoring backup of the database
+	ExitStatusBackupError
 	defaultConfigFilename = "config"
 	typeStr               = "type"
+	profileFlag           = "--profile"
 
 	databasePreparationMessage = "database preparation exited with error code %v"
 )
@@ -86,18 +101,94 @@ var (
 func createStorage() (*storage.DBStorage, error) {
 	storageCfg := conf.GetStorageConfiguration()
 
+	// chaos mode has to be configured before the storage is created, because
+	// faults are injected by SQL driver hooks
+	chaos.DefaultInjector.Configure(conf.GetChaosConfiguration())
+
 	dbStorage, err := storage.New(storageCfg)
 	if err != nil {
 		log.Error().Err(err).Msg("storage.New")
 		return nil, err
 	}
 
+	// reports moved into the archive are fetched from it on demand
+	archiveCfg := conf.GetArchiveConfiguration()
+	if archiveCfg.Enabled {
+		reportArchive, err := archive.New(archiveCfg)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to create archive of reports")
+			closeStorage(dbStorage)
+			return nil, err
+		}
+		dbStorage.SetReportArchive(reportArchive)
+	}
+
 	return dbStorage, nil
 }
 
+// createServiceStorage creates the storage used by the consumer and the
+// server. Storages of drivers registered by storage.RegisterDriver are
+// created by their factories, they are not SQL databases, so migrations and
+// the scheduler can't be used with them.
+func createServiceStorage() (storage.Storage, error) {
+	storageCfg := conf.GetStorageConfiguration()
+	if !storage.IsRegisteredDriver(storageCfg.Driver) {
+		dbStorage, err := createStorage()
+		if err != nil {
+			return nil, err
+		}
+
+		detectSchemaVersion(dbStorage)
+		return dbStorage, nil
+	}
+
+	serviceStorage, err := storage.NewStorage(storageCfg)
+	if err != nil {
+		log.Error().Err(err).Msg("storage.NewStorage")
+		return nil, err
+	}
+
+	return serviceStorage, nil
+}
+
+// detectSchemaVersion adapts the storage to the migration version of the
+// database, so the service works during rolling upgrades when the database
+// is not migrated yet. The latest schema is expected when the version can't
+// be detected.
+func detectSchemaVersion(dbStorage *storage.DBStorage) {
+	if _, err := dbStorage.DetectSchemaVersion(); err != nil {
+		log.Error().Err(err).Msg("Unable to detect DB migration version, the latest one is expected")
+	}
+}
+
+// createCachedStorage wraps the storage into storage caching reports in
+// Redis, when the cache is enabled. Both the consumer and the server have to
+// use it, so cached reports are invalidated by writes. The returned function
+// closes the connection to Redis.
+func createCachedStorage(wrapped storage.Storage) (storage.Storage, func(), error) {
+	cacheCfg := conf.GetRedisCacheConfiguration()
+	if !cacheCfg.Enabled {
+		return wrapped, func() {}, nil
+	}
+
+	backend, err := storage.NewRedisCacheBackend(cacheCfg.URL, cacheCfg.Timeout)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to create Redis cache of reports")
+		return nil, nil, err
+	}
+
+	closeCache := func() {
+		if err := backend.Close(); err != nil {
+			log.Error().Err(err).Msg("Unable to close connection to Redis")
+		}
+	}
+
+	return storage.NewCachedStorage(wrapped, backend, cacheCfg.TTL, cacheCfg.KeyPrefix), closeCache, nil
+}
+
 // closeStorage closes specified DBStorage with proper error checking
 // whether the close operation was successful or not.
-func closeStorage(storage *storage.DBStorage) {
+func closeStorage(storage storage.Storage) {
 	err := storage.Close()
 	if err != nil {
 		log.Error().Err(err).Msg("Error during closing storage connection")
@@ -118,9 +209,14 @@ func prepareDBMigrations(dbStorage *storage.DBStorage) int {
 			return ExitStatusPrepareDbError
 		}
 
+		// the service is able to work with the previous migration version
+		// too, so instances can be upgraded before the database is migrated
 		maxVersion := migration.GetMaxVersion()
-		if currentVersion != maxVersion {
-			log.Error().Msgf("old DB migration version (current: %d, latest: %d)", currentVersion, maxVersion)
+		if currentVersion < storage.MinSupportedDBVersion || currentVersion > maxVersion {
+			log.Error().Msgf(
+				"unsupported DB migration version (current: %d, supported: %d-%d)",
+				currentVersion, storage.MinSupportedDBVersion, maxVersion,
+			)
 			return ExitStatusPrepareDbError
 		}
 	}
@@ -130,6 +226,10 @@ func prepareDBMigrations(dbStorage *storage.DBStorage) int {
 
 // prepareDB opens a DB connection and loads all available rule content into it.
 func prepareDB() int {
+	if storage.IsRegisteredDriver(conf.GetStorageConfiguration().Driver) {
+		return prepareCustomStorage()
+	}
+
 	dbStorage, err := createStorage()
 	if err != nil {
 		log.Error().Err(err).Msg("Error creating storage")
@@ -142,6 +242,12 @@ func prepareDB() int {
 		return exitCode
 	}
 
+	// Missing indexes make the service slow, but it still works correctly,
+	// so they are just reported.
+	if _, err := dbStorage.CheckIndexes(); err != nil {
+		log.Error().Err(err).Msg("Unable to check DB indexes")
+	}
+
 	// Initialize the database.
 	err = dbStorage.Init()
 	if err != nil {
@@ -152,6 +258,24 @@ func prepareDB() int {
 	return ExitStatusOK
 }
 
+// prepareCustomStorage initializes the storage of the driver registered by
+// storage.RegisterDriver, there are no migrations to check.
+func prepareCustomStorage() int {
+	customStorage, err := createServiceStorage()
+	if err != nil {
+		log.Error().Err(err).Msg("Error creating storage")
+		return ExitStatusPrepareDbError
+	}
+	defer closeStorage(customStorage)
+
+	if err := customStorage.Init(); err != nil {
+		log.Error().Err(err).Msg("Storage initialization error")
+		return ExitStatusPrepareDbError
+	}
+
+	return ExitStatusOK
+}
+
 // startService starts service and returns error code
 func startService() int {
 	metricsCfg := conf.GetMetricsConfiguration()
@@ -159,12 +283,31 @@ func startService() int {
 		metrics.AddMetricsWithNamespace(metricsCfg.Namespace)
 	}
 
+	orgLabeler, err := metrics.NewOrgLabeler(
+		metricsCfg.OrgLabelMode, metricsCfg.OrgLabelTopN, metricsCfg.OrgLabelHashBuckets,
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid configuration of organization labels of metrics")
+		return ExitStatusError
+	}
+	metrics.SetOrgLabeler(orgLabeler)
+
 	prepDbExitCode := prepareDB()
 	if prepDbExitCode != ExitStatusOK {
 		log.Info().Msgf(databasePreparationMessage, prepDbExitCode)
 		return prepDbExitCode
 	}
 
+	schedulerConf := conf.GetSchedulerConfiguration()
+	if schedulerConf.Enabled {
+		if err := startScheduler(schedulerConf); err != nil {
+			log.Error().Err(err).Msg("Scheduler initialization error")
+			return ExitStatusSchedulerError
+		}
+	} else {
+		log.Info().Msg("Scheduler is disabled, not starting it")
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	errorGroup := new(errgroup.Group)
@@ -217,6 +360,8 @@ func startService() int {
 func stopService() int {
 	errCode := ExitStatusOK
 
+	stopScheduler()
+
 	err := stopServer()
 	if err != nil {
 		log.Error().Err(err)
@@ -251,7 +396,10 @@ Aggregator service for insights results
 
 Usage:
 
-    %+v [command]
+    %+v [--profile name] [command]
+
+The --profile flag selects configuration profile, config.<name>.toml is merged
+into config.toml.
 
 The commands are:
 
@@ -260,10 +408,16 @@ The commands are:
     help                prints help
     print-help          prints help
     print-config        prints current configuration set by files & env variables
+    check-config        checks current configuration and prints all problems found
     print-env           prints env variables
     print-version-info  prints version info
     migration           prints information about migrations (current, latest)
     migration <version> migrates database to the specified version
+    export-parquet      exports reports and rule hits into Parquet files
+    backup <file>       writes consistent backup of all tables into the file
+    restore <file>      restores backup from the file into an empty database
+    create-api-key <name>
+                        creates API key with admin scope and prints it
 
 `
 
@@ -285,6 +439,24 @@ func printConfig() int {
 	return ExitStatusOK
 }
 
+// checkConfig validates the configuration and logs all problems found
+func checkConfig() int {
+	err := conf.ValidateConfiguration()
+	if err == nil {
+		return ExitStatusOK
+	}
+
+	if validationErr, ok := err.(*conf.ValidationError); ok {
+		for _, problem := range validationErr.Problems {
+			log.Error().Str("problem", problem).Msg("Invalid configuration")
+		}
+	} else {
+		log.Error().Err(err).Msg("Invalid configuration")
+	}
+
+	return ExitStatusConfigurationError
+}
+
 func printEnv() int {
 	for _, keyVal := range os.Environ() {
 		fmt.Println(keyVal)
@@ -305,6 +477,12 @@ func getDBForMigrations() (*storage.DBStorage, *sql.DB, int) {
 
 	dbConn := db.GetConnection()
 
+	if err := db.CreateSchemaIfNotExists(); err != nil {
+		closeStorage(db)
+		log.Error().Err(err).Msg("Unable to create DB schema")
+		return nil, nil, ExitStatusPrepareDbError
+	}
+
 	if err := migration.InitInfoTable(dbConn); err != nil {
 		closeStorage(db)
 		log.Error().Err(err).Msg("Unable to initialize migration info table")
@@ -376,6 +554,120 @@ func performMigrations() int {
 	}
 }
 
+// exportToParquet exports reports and rule hits from the database into
+// Parquet files stored locally or in S3 bucket.
+func exportToParquet() int {
+	dbStorage, err := createStorage()
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to prepare DB for export")
+		return ExitStatusPrepareDbError
+	}
+	defer closeStorage(dbStorage)
+
+	files, err := export.ToParquet(conf.GetExportConfiguration(), dbStorage)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to export data into Parquet files")
+		return ExitStatusExportError
+	}
+
+	log.Info().Strs("files", files).Msg("Export finished")
+	return ExitStatusOK
+}
+
+// readBackupFileArg returns the name of the backup file given as the only
+// argument of the command
+func readBackupFileArg(command string) (string, bool) {
+	if len(os.Args) != 3 || os.Args[2] == "" {
+		log.Error().Msgf("Unexpected number of arguments to %v command (expected name of backup file)", command)
+		return "", false
+	}
+
+	return os.Args[2], true
+}
+
+// backupDatabase writes logical backup of all tables of the database into
+// the file given as the argument of the command
+func backupDatabase() int {
+	path, ok := readBackupFileArg("backup")
+	if !ok {
+		return ExitStatusBackupError
+	}
+
+	dbStorage, err := createStorage()
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to prepare DB for backup")
+		return ExitStatusPrepareDbError
+	}
+	defer closeStorage(dbStorage)
+
+	summary, err := backup.ToFile(dbStorage, path)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to write backup of the database")
+		return ExitStatusBackupError
+	}
+
+	log.Info().
+		Str("file", path).
+		Uint("migration_version", uint(summary.MigrationVersion)).
+		Interface("rows", summary.Rows).
+		Msg("Backup finished")
+	return ExitStatusOK
+}
+
+// createAdminAPIKey creates API key with admin scope, so the admin endpoints
+// can be accessed before any other key exists
+func createAdminAPIKey() int {
+	if len(os.Args) != 3 || strings.TrimSpace(os.Args[2]) == "" {
+		log.Error().Msg("Unexpected number of arguments to create-api-key command (expected name of the key)")
+		return ExitStatusError
+	}
+
+	dbStorage, err := createStorage()
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to prepare DB for API key")
+		return ExitStatusPrepareDbError
+	}
+	defer closeStorage(dbStorage)
+
+	keyID, key, err := server.CreateAPIKey(dbStorage, os.Args[2], []string{server.APIKeyScopeAdmin}, time.Time{})
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to create API key")
+		return ExitStatusError
+	}
+
+	log.Info().Str("key_id", keyID).Msg("API key created")
+	fmt.Println(key)
+	return ExitStatusOK
+}
+
+// restoreDatabase restores logical backup from the file given as the
+// argument of the command into empty database
+func restoreDatabase() int {
+	path, ok := readBackupFileArg("restore")
+	if !ok {
+		return ExitStatusBackupError
+	}
+
+	dbStorage, _, exitCode := getDBForMigrations()
+	if exitCode != ExitStatusOK {
+		return exitCode
+	}
+	defer closeStorage(dbStorage)
+
+	summary, err := backup.FromFile(dbStorage, path)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to restore backup of the database")
+		return ExitStatusBackupError
+	}
+
+	log.Info().
+		Str("file", path).
+		Uint("migration_version", uint(summary.MigrationVersion)).
+		Interface("rows", summary.Rows).
+		Msg("Restore finished")
+	return ExitStatusOK
+}
+
 func stopServiceOnProcessStopSignal() {
 	signals := make(chan os.Signal, 1)
 
@@ -393,8 +685,33 @@ func stopServiceOnProcessStopSignal() {
 	}()
 }
 
+// parseProfileFlag removes --profile flag (--profile name or --profile=name)
+// from the command line arguments and returns the profile and the rest of
+// the arguments
+func parseProfileFlag(args []string) (profile string, rest []string) {
+	rest = make([]string, 0, len(args))
+
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == profileFlag && i+1 < len(args):
+			profile = args[i+1]
+			i++
+		case strings.HasPrefix(args[i], profileFlag+"="):
+			profile = strings.TrimPrefix(args[i], profileFlag+"=")
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+
+	return profile, rest
+}
+
 func main() {
-	err := conf.LoadConfiguration(defaultConfigFilename)
+	// the command and its arguments are read from os.Args later
+	profile, args := parseProfileFlag(os.Args)
+	os.Args = args
+
+	err := conf.LoadConfigurationWithProfile(defaultConfigFilename, profile)
 	if err != nil {
 		panic(err)
 	}
@@ -427,6 +744,12 @@ func handleCommand(command string) int {
 	case "start-service":
 		printVersionInfo()
 
+		// all problems of the configuration are reported before any
+		// part of the service is started
+		if errCode := checkConfig(); errCode != ExitStatusOK {
+			return errCode
+		}
+
 		stopServiceOnProcessStopSignal()
 
 		return startService()
@@ -434,12 +757,22 @@ func handleCommand(command string) int {
 		return printHelp()
 	case "print-config":
 		return printConfig()
+	case "check-config":
+		return checkConfig()
 	case "print-env":
 		return printEnv()
 	case "print-version-info":
 		printVersionInfo()
 	case "migrations", "migration", "migrate":
 		return performMigrations()
+	case "export-parquet":
+		return exportToParquet()
+	case "backup":
+		return backupDatabase()
+	case "restore":
+		return restoreDatabase()
+	case "create-api-key":
+		return createAdminAPIKey()
 	default:
 		fmt.Printf("\nCommand '%v' not found\n", command)
 		return printHelp()
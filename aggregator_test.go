@@ -83,6 +83,10 @@ func TestStartService(t *testing.T) {
 			"INSIGHTS_RESULTS_AGGREGATOR__STORAGE__SQLITE_DATASOURCE": ":memory:",
 		})
 
+		// the service is stopped before it starts, so the server started
+		// by previous tests must not be stopped instead
+		main.ResetServerInstance()
+
 		go func() {
 			main.StartService()
 		}()
@@ -202,6 +206,23 @@ func TestPrepareDB(t *testing.T) {
 	*main.AutoMigratePtr = false
 }
 
+// TestPrepareDBMigrations_PreviousVersion checks that the service starts
+// with the database not migrated yet during rolling upgrades
+func TestPrepareDBMigrations_PreviousVersion(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+
+	err := migration.SetDBVersion(dbStorage.GetConnection(), dbStorage.GetDBDriverType(), storage.MinSupportedDBVersion)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, main.ExitStatusOK, main.PrepareDBMigrations(dbStorage))
+
+	err = migration.SetDBVersion(dbStorage.GetConnection(), dbStorage.GetDBDriverType(), storage.MinSupportedDBVersion-1)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, main.ExitStatusPrepareDbError, main.PrepareDBMigrations(dbStorage))
+}
+
 func TestPrepareDB_NoRulesDirectory(t *testing.T) {
 	setEnvSettings(t, map[string]string{
 		"INSIGHTS_RESULTS_AGGREGATOR__STORAGE__DB_DRIVER":         "sqlite3",
@@ -290,6 +311,11 @@ func TestStartService_BadBrokerAndServerAddress(t *testing.T) {
 
 	*main.AutoMigratePtr = true
 
+	// the consumer fails first and the service is stopped while the server
+	// starts, the server started by previous tests must not be stopped
+	// instead
+	main.ResetServerInstance()
+
 	errCode := main.StartService()
 	assert.Equal(t, main.ExitStatusError, errCode)
 
@@ -440,3 +466,19 @@ func TestPerformMigrationsTooManyArgs(t *testing.T) {
 
 	os.Args = oldArgs
 }
+
+func TestParseProfileFlag(t *testing.T) {
+	for _, args := range [][]string{
+		{"aggregator", "--profile", "stage", "migration", "10"},
+		{"aggregator", "--profile=stage", "migration", "10"},
+		{"aggregator", "migration", "--profile", "stage", "10"},
+	} {
+		profile, rest := main.ParseProfileFlag(args)
+		assert.Equal(t, "stage", profile, args)
+		assert.Equal(t, []string{"aggregator", "migration", "10"}, rest, args)
+	}
+
+	profile, rest := main.ParseProfileFlag([]string{"aggregator", "start-service"})
+	assert.Equal(t, "", profile)
+	assert.Equal(t, []string{"aggregator", "start-service"}, rest)
+}
@@ -0,0 +1,83 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package aggregatortest contains fixtures for tests of services embedding
+// the aggregator packages: in-memory storage with the latest DB schema,
+// canned payloads of messages produced by the pipeline and a fake consumer
+// processing the payloads by the same code as the service does. The
+// fixtures depend only on public API of the aggregator, so they can be used
+// without copying its internal test helpers.
+package aggregatortest
+
+import (
+	"testing"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+)
+
+// NewInMemoryStorage returns SQLite storage in memory migrated to the latest
+// version of the DB schema. Every call returns independent storage, close it
+// when it's not needed anymore.
+//
+// The storage is not storage.MemoryStorage on purpose: tests of embedding
+// services run the same SQL queries and migrations as the service does and
+// they can use DBStorage-only API (the DB connection, the schema, caches).
+// Use storage.NewMemoryStorage when SQLite (and CGO) is not available.
+func NewInMemoryStorage() (*storage.DBStorage, error) {
+	dbStorage, err := storage.New(storage.Configuration{
+		Driver:           "sqlite3",
+		SQLiteDataSource: ":memory:",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// every connection to :memory: data source opens a new empty DB, so
+	// only one connection can be used
+	dbStorage.GetConnection().SetMaxOpenConns(1)
+
+	if _, err := dbStorage.GetConnection().Exec("PRAGMA foreign_keys = ON;"); err != nil {
+		_ = dbStorage.Close()
+		return nil, err
+	}
+
+	if err := dbStorage.MigrateToLatest(); err != nil {
+		_ = dbStorage.Close()
+		return nil, err
+	}
+
+	if err := dbStorage.Init(); err != nil {
+		_ = dbStorage.Close()
+		return nil, err
+	}
+
+	return dbStorage, nil
+}
+
+// MustNewInMemoryStorage returns in-memory storage (see NewInMemoryStorage)
+// and the function closing it, the test is failed when it can't be created
+func MustNewInMemoryStorage(tb testing.TB) (*storage.DBStorage, func()) {
+	tb.Helper()
+
+	dbStorage, err := NewInMemoryStorage()
+	if err != nil {
+		tb.Fatalf("unable to create in-memory storage: %v", err)
+	}
+
+	return dbStorage, func() {
+		if err := dbStorage.Close(); err != nil {
+			tb.Errorf("unable to close in-memory storage: %v", err)
+		}
+	}
+}
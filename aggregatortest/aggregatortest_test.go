@@ -0,0 +1,100 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aggregatortest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/aggregatortest"
+	"github.com/RedHatInsights/insights-results-aggregator/consumer"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+var _ consumer.Consumer = (*aggregatortest.FakeConsumer)(nil)
+
+func TestFakeConsumer_Send(t *testing.T) {
+	storage, closer := aggregatortest.MustNewInMemoryStorage(t)
+	defer closer()
+
+	fakeConsumer := aggregatortest.NewFakeConsumer(storage)
+
+	err := fakeConsumer.Send(aggregatortest.ReportMessage(
+		aggregatortest.OrgID, aggregatortest.ClusterName, time.Now(),
+		aggregatortest.RuleHit1, aggregatortest.RuleHit2,
+	))
+	assert.NoError(t, err)
+
+	rules, _, err := storage.ReadReportForCluster(aggregatortest.OrgID, aggregatortest.ClusterName)
+	assert.NoError(t, err)
+	assert.Len(t, rules, 2)
+
+	err = fakeConsumer.Send(aggregatortest.FailedAnalysisMessage(
+		aggregatortest.OrgID, aggregatortest.ClusterName, time.Now(),
+	))
+	assert.NoError(t, err)
+
+	status, err := storage.ReadReportStatusForCluster(aggregatortest.OrgID, aggregatortest.ClusterName)
+	assert.NoError(t, err)
+	assert.Equal(t, types.ReportStatusFailed, status)
+
+	err = fakeConsumer.Send([]byte(`{"OrgID": 1}`))
+	assert.Error(t, err)
+}
+
+func TestFakeConsumer_Serve(t *testing.T) {
+	storage, closer := aggregatortest.MustNewInMemoryStorage(t)
+	defer closer()
+
+	fakeConsumer := aggregatortest.NewFakeConsumer(storage)
+
+	served := make(chan struct{})
+	go func() {
+		fakeConsumer.Serve()
+		close(served)
+	}()
+
+	fakeConsumer.Enqueue(aggregatortest.EmptyReportMessage(
+		aggregatortest.OrgID, aggregatortest.ClusterName, time.Now(),
+	))
+
+	assert.NoError(t, fakeConsumer.Close())
+	assert.NoError(t, fakeConsumer.Close())
+	<-served
+
+	assert.Equal(t, uint64(1), fakeConsumer.KafkaConsumer.GetNumberOfSuccessfullyConsumedMessages())
+
+	rules, _, err := storage.ReadReportForCluster(aggregatortest.OrgID, aggregatortest.ClusterName)
+	assert.NoError(t, err)
+	assert.Empty(t, rules)
+}
+
+func TestNewInMemoryStorage_Independent(t *testing.T) {
+	storage1, closer1 := aggregatortest.MustNewInMemoryStorage(t)
+	defer closer1()
+
+	storage2, closer2 := aggregatortest.MustNewInMemoryStorage(t)
+	defer closer2()
+
+	err := aggregatortest.NewFakeConsumer(storage1).Send(aggregatortest.EmptyReportMessage(
+		aggregatortest.OrgID, aggregatortest.ClusterName, time.Now(),
+	))
+	assert.NoError(t, err)
+
+	_, _, err = storage2.ReadReportForCluster(aggregatortest.OrgID, aggregatortest.ClusterName)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
@@ -0,0 +1,117 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aggregatortest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/consumer"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// FakeTopic is the topic messages of the fake consumer are consumed from
+const FakeTopic = "ccx.ocp.results"
+
+// FakeConsumer is consumer.Consumer processing payloads sent by the test
+// instead of messages consumed from Kafka. Payloads are processed by the same
+// code as in the service, so reports are stored in the storage of the
+// consumer.
+type FakeConsumer struct {
+	KafkaConsumer consumer.KafkaConsumer
+	messages      chan *sarama.ConsumerMessage
+	closed        chan struct{}
+	closeOnce     sync.Once
+	offsetMutex   sync.Mutex
+	offset        int64
+}
+
+// NewFakeConsumer constructs fake consumer writing reports to the storage
+func NewFakeConsumer(storage storage.Storage) *FakeConsumer {
+	return &FakeConsumer{
+		KafkaConsumer: consumer.KafkaConsumer{
+			Configuration: broker.Configuration{
+				Topic:   FakeTopic,
+				Enabled: true,
+			},
+			Storage: storage,
+		},
+		messages: make(chan *sarama.ConsumerMessage),
+		closed:   make(chan struct{}),
+	}
+}
+
+// Send processes the payload immediately and returns the processing error
+func (fakeConsumer *FakeConsumer) Send(payload []byte) error {
+	_, err := fakeConsumer.ProcessMessage(fakeConsumer.newMessage(payload))
+	return err
+}
+
+// Enqueue passes the payload to Serve, it blocks until Serve takes it or the
+// consumer is closed
+func (fakeConsumer *FakeConsumer) Enqueue(payload []byte) {
+	select {
+	case fakeConsumer.messages <- fakeConsumer.newMessage(payload):
+	case <-fakeConsumer.closed:
+	}
+}
+
+// Serve handles enqueued payloads the same way as messages consumed from
+// Kafka until the consumer is closed. It blocks current thread.
+func (fakeConsumer *FakeConsumer) Serve() {
+	for {
+		select {
+		case msg := <-fakeConsumer.messages:
+			fakeConsumer.KafkaConsumer.HandleMessage(msg)
+		case <-fakeConsumer.closed:
+			return
+		}
+	}
+}
+
+// ProcessMessage processes the message consumed from Kafka
+func (fakeConsumer *FakeConsumer) ProcessMessage(msg *sarama.ConsumerMessage) (types.RequestID, error) {
+	return fakeConsumer.KafkaConsumer.ProcessMessage(msg)
+}
+
+// Close stops Serve, it can be called more times
+func (fakeConsumer *FakeConsumer) Close() error {
+	fakeConsumer.closeOnce.Do(func() {
+		close(fakeConsumer.closed)
+	})
+
+	return nil
+}
+
+// newMessage wraps the payload into message with the next offset
+func (fakeConsumer *FakeConsumer) newMessage(payload []byte) *sarama.ConsumerMessage {
+	fakeConsumer.offsetMutex.Lock()
+	defer fakeConsumer.offsetMutex.Unlock()
+
+	msg := &sarama.ConsumerMessage{
+		Timestamp:      time.Now(),
+		BlockTimestamp: time.Now(),
+		Value:          payload,
+		Topic:          FakeTopic,
+		Offset:         fakeConsumer.offset,
+	}
+	fakeConsumer.offset++
+
+	return msg
+}
@@ -0,0 +1,114 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aggregatortest
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// Identifiers used by the canned payloads
+const (
+	OrgID       = types.OrgID(1)
+	ClusterName = types.ClusterName("84f7eedc-0dd8-49cd-9d4d-f6646df3a5bc")
+)
+
+// RuleHit is one rule reported for the cluster in the report payload
+type RuleHit struct {
+	RuleID   types.RuleID
+	ErrorKey types.ErrorKey
+	// Details are template data of the rule hit, empty object is used when
+	// they are not set
+	Details map[string]interface{}
+}
+
+// Rule hits used by the canned payloads
+var (
+	RuleHit1 = RuleHit{
+		RuleID:   "ccx_rules_ocp.external.rules.nodes_requirements_check.report",
+		ErrorKey: "NODES_MINIMUM_REQUIREMENTS_NOT_MET",
+		Details:  map[string]interface{}{"nodes": []string{"worker-0"}},
+	}
+	RuleHit2 = RuleHit{
+		RuleID:   "ccx_rules_ocp.external.bug_rules.bug_1766907.report",
+		ErrorKey: "BUGZILLA_BUG_1766907",
+	}
+)
+
+// ReportMessage returns payload of the message with report of the cluster
+// hitting the given rules, in the format produced by the pipeline
+func ReportMessage(
+	orgID types.OrgID, clusterName types.ClusterName, lastChecked time.Time, hits ...RuleHit,
+) []byte {
+	reports := make([]map[string]interface{}, 0, len(hits))
+	for _, hit := range hits {
+		details := hit.Details
+		if details == nil {
+			details = map[string]interface{}{}
+		}
+
+		reports = append(reports, map[string]interface{}{
+			"component": hit.RuleID,
+			"key":       hit.ErrorKey,
+			"details":   details,
+			"type":      "rule",
+		})
+	}
+
+	return mustMarshal(map[string]interface{}{
+		"OrgID":       orgID,
+		"ClusterName": clusterName,
+		"LastChecked": lastChecked.UTC().Format(time.RFC3339Nano),
+		"Version":     1,
+		"Report": map[string]interface{}{
+			"system":       map[string]interface{}{"metadata": map[string]interface{}{}, "hostname": nil},
+			"reports":      reports,
+			"fingerprints": []interface{}{},
+			"skips":        []interface{}{},
+			"info":         []interface{}{},
+		},
+	})
+}
+
+// EmptyReportMessage returns payload of the message with report of the
+// cluster without any rule hit
+func EmptyReportMessage(orgID types.OrgID, clusterName types.ClusterName, lastChecked time.Time) []byte {
+	return ReportMessage(orgID, clusterName, lastChecked)
+}
+
+// FailedAnalysisMessage returns payload of the message sent when the
+// analysis of the cluster failed, such message contains no report
+func FailedAnalysisMessage(orgID types.OrgID, clusterName types.ClusterName, lastChecked time.Time) []byte {
+	return mustMarshal(map[string]interface{}{
+		"OrgID":       orgID,
+		"ClusterName": clusterName,
+		"LastChecked": lastChecked.UTC().Format(time.RFC3339Nano),
+		"Version":     1,
+		"Status":      types.ReportStatusFailed,
+	})
+}
+
+// mustMarshal marshals the payload, it panics when details of rule hits
+// contain values that can't be marshalled
+func mustMarshal(payload interface{}) []byte {
+	bytes, err := json.Marshal(payload)
+	if err != nil {
+		panic("unable to marshal payload: " + err.Error())
+	}
+
+	return bytes
+}
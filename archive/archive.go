@@ -0,0 +1,119 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package archive contains the archive of reports aged out of the database.
+// Every report is stored together with its rule hits as one gzip compressed
+// JSON object in S3 bucket (or local directory), so it can be fetched on
+// demand by the storage when it is not found in the database.
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"path"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const (
+	// objectSuffix is used in keys of the archived reports
+	objectSuffix = ".json.gz"
+
+	contentType     = "application/json"
+	contentEncoding = "gzip"
+)
+
+// Archive stores archived reports in the object store, one object per
+// cluster. It implements storage.ReportArchive.
+type Archive struct {
+	store  ObjectStore
+	prefix string
+}
+
+// New constructs archive of reports in S3 bucket or local directory
+// according to the configuration
+func New(configuration Configuration) (*Archive, error) {
+	if !configuration.s3Enabled() {
+		return NewWithStore(DirectoryStore{Directory: configuration.Path}, ""), nil
+	}
+
+	store, err := NewS3Store(configuration)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewWithStore(store, configuration.S3Prefix), nil
+}
+
+// NewWithStore constructs archive of reports in the given object store, keys
+// of all objects start with the prefix
+func NewWithStore(store ObjectStore, prefix string) *Archive {
+	return &Archive{store: store, prefix: prefix}
+}
+
+// objectKey returns key of the object with archived report of the cluster
+func (archive *Archive) objectKey(clusterName types.ClusterName) string {
+	return path.Join(archive.prefix, string(clusterName)+objectSuffix)
+}
+
+// WriteArchivedReport stores the report, the previously archived report of
+// the same cluster is replaced
+func (archive *Archive) WriteArchivedReport(report storage.ArchivedReport) error {
+	var compressed bytes.Buffer
+
+	writer := gzip.NewWriter(&compressed)
+
+	err := json.NewEncoder(writer).Encode(report)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return archive.store.Put(archive.objectKey(report.ClusterName), compressed.Bytes())
+}
+
+// ReadArchivedReport returns the archived report of the cluster,
+// ItemNotFoundError is returned when no report of the cluster is archived
+func (archive *Archive) ReadArchivedReport(clusterName types.ClusterName) (storage.ArchivedReport, error) {
+	var report storage.ArchivedReport
+
+	compressed, err := archive.store.Get(archive.objectKey(clusterName))
+	if err == ErrObjectNotFound {
+		return report, &types.ItemNotFoundError{ItemID: clusterName}
+	}
+	if err != nil {
+		return report, err
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return report, err
+	}
+
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return report, err
+	}
+
+	err = json.Unmarshal(data, &report)
+
+	return report, err
+}
@@ -0,0 +1,108 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/archive"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func testArchivedReport() storage.ArchivedReport {
+	return storage.ArchivedReport{
+		OrgID:         testdata.OrgID,
+		ClusterName:   testdata.ClusterName,
+		Report:        testdata.Report2Rules,
+		ReportedAt:    testdata.LastCheckedAt.UTC(),
+		LastCheckedAt: testdata.LastCheckedAt.UTC(),
+		KafkaOffset:   testdata.KafkaOffset,
+		RuleHits: []storage.ArchivedRuleHit{
+			{RuleFQDN: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1, TemplateData: `{"key": "value"}`},
+			{RuleFQDN: testdata.Rule2ID, ErrorKey: testdata.ErrorKey2, TemplateData: `{}`},
+		},
+		ArchivedAt: time.Date(2020, 10, 16, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestArchive_WriteAndReadReport(t *testing.T) {
+	directory := t.TempDir()
+	reportArchive := archive.NewWithStore(archive.DirectoryStore{Directory: directory}, "reports")
+
+	report := testArchivedReport()
+	helpers.FailOnError(t, reportArchive.WriteArchivedReport(report))
+
+	archived, err := reportArchive.ReadArchivedReport(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, report, archived)
+
+	// the report is stored as gzip compressed JSON
+	compressed, err := ioutil.ReadFile(filepath.Join(directory, "reports", string(testdata.ClusterName)+".json.gz"))
+	helpers.FailOnError(t, err)
+
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	helpers.FailOnError(t, err)
+
+	content, err := ioutil.ReadAll(reader)
+	helpers.FailOnError(t, err)
+	assert.Contains(t, string(content), `"org_id":`)
+}
+
+func TestArchive_ReplaceReport(t *testing.T) {
+	reportArchive := archive.NewWithStore(archive.DirectoryStore{Directory: t.TempDir()}, "")
+
+	report := testArchivedReport()
+	helpers.FailOnError(t, reportArchive.WriteArchivedReport(report))
+
+	report.RuleHits = report.RuleHits[:1]
+	helpers.FailOnError(t, reportArchive.WriteArchivedReport(report))
+
+	archived, err := reportArchive.ReadArchivedReport(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Len(t, archived.RuleHits, 1)
+}
+
+func TestArchive_ReportNotFound(t *testing.T) {
+	reportArchive := archive.NewWithStore(archive.DirectoryStore{Directory: t.TempDir()}, "")
+
+	_, err := reportArchive.ReadArchivedReport(testdata.ClusterName)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
+
+func TestDirectoryStore_KeyOutsideDirectory(t *testing.T) {
+	directory := t.TempDir()
+	store := archive.DirectoryStore{Directory: filepath.Join(directory, "store")}
+
+	helpers.FailOnError(t, store.Put("../outside", []byte("data")))
+
+	_, err := ioutil.ReadFile(filepath.Join(directory, "outside"))
+	assert.Error(t, err)
+
+	data, err := store.Get("outside")
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []byte("data"), data)
+}
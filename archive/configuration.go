@@ -0,0 +1,44 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import "time"
+
+// Configuration represents configuration of the archive of reports. Reports
+// are stored into S3 bucket when it is configured, into local directory
+// otherwise.
+type Configuration struct {
+	Enabled bool `mapstructure:"enabled" toml:"enabled"`
+	// ReportAge is the time since the last check after which the report of
+	// the cluster is moved into the archive
+	ReportAge time.Duration `mapstructure:"report_age" toml:"report_age"`
+	// BatchSize is the maximal number of reports archived by one run of
+	// the archival task
+	BatchSize    int    `mapstructure:"batch_size" toml:"batch_size"`
+	Path         string `mapstructure:"path" toml:"path"`
+	S3Bucket     string `mapstructure:"s3_bucket" toml:"s3_bucket"`
+	S3Prefix     string `mapstructure:"s3_prefix" toml:"s3_prefix"`
+	S3Region     string `mapstructure:"s3_region" toml:"s3_region"`
+	S3Endpoint   string `mapstructure:"s3_endpoint" toml:"s3_endpoint"`
+	AWSAccessID  string `mapstructure:"aws_access_id" toml:"aws_access_id"`
+	AWSSecretKey string `mapstructure:"aws_secret_key" toml:"aws_secret_key"`
+}
+
+// s3Enabled returns true when reports are archived into S3
+func (configuration Configuration) s3Enabled() bool {
+	return configuration.S3Bucket != ""
+}
@@ -0,0 +1,100 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"bytes"
+	"io/ioutil"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/rs/zerolog/log"
+)
+
+// S3Store stores objects in S3 bucket (or any S3 compatible storage)
+type S3Store struct {
+	client *s3.S3
+	bucket string
+}
+
+// NewS3Store constructs store of objects in the configured S3 bucket
+func NewS3Store(configuration Configuration) (*S3Store, error) {
+	awsConfig := aws.NewConfig()
+
+	if configuration.S3Region != "" {
+		awsConfig = awsConfig.WithRegion(configuration.S3Region)
+	}
+
+	if configuration.S3Endpoint != "" {
+		// S3 compatible storages (like MinIO) usually don't support
+		// virtual hosted-style requests
+		awsConfig = awsConfig.
+			WithEndpoint(configuration.S3Endpoint).
+			WithS3ForcePathStyle(true)
+	}
+
+	if configuration.AWSAccessID != "" {
+		awsConfig = awsConfig.WithCredentials(credentials.NewStaticCredentials(
+			configuration.AWSAccessID, configuration.AWSSecretKey, "",
+		))
+	}
+
+	awsSession, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3Store{client: s3.New(awsSession), bucket: configuration.S3Bucket}, nil
+}
+
+// Put uploads the object into the bucket
+func (store *S3Store) Put(key string, data []byte) error {
+	_, err := store.client.PutObject(&s3.PutObjectInput{
+		Bucket:          aws.String(store.bucket),
+		Key:             aws.String(key),
+		Body:            bytes.NewReader(data),
+		ContentType:     aws.String(contentType),
+		ContentEncoding: aws.String(contentEncoding),
+	})
+
+	return err
+}
+
+// Get downloads the object from the bucket
+func (store *S3Store) Get(key string) ([]byte, error) {
+	output, err := store.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(store.bucket),
+		Key:    aws.String(key),
+	})
+	if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == s3.ErrCodeNoSuchKey {
+		return nil, ErrObjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := output.Body.Close(); err != nil {
+			log.Error().Err(err).Str("key", key).Msg("Unable to close archived object")
+		}
+	}()
+
+	return ioutil.ReadAll(output.Body)
+}
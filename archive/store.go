@@ -0,0 +1,67 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// ErrObjectNotFound is returned by ObjectStore when the object doesn't exist
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectStore represents any store of objects identified by keys (S3 bucket
+// or local directory)
+type ObjectStore interface {
+	// Put creates the object or replaces the existing one
+	Put(key string, data []byte) error
+	// Get returns content of the object or ErrObjectNotFound
+	Get(key string) ([]byte, error)
+}
+
+// DirectoryStore stores objects as files in local directory, keys are
+// relative paths of the files
+type DirectoryStore struct {
+	Directory string
+}
+
+// Put writes the object into the file, missing directories are created
+func (store DirectoryStore) Put(key string, data []byte) error {
+	fileName := store.fileName(key)
+
+	if err := os.MkdirAll(filepath.Dir(fileName), 0750); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(fileName, data, 0600)
+}
+
+// Get reads the object from the file
+func (store DirectoryStore) Get(key string) ([]byte, error) {
+	data, err := ioutil.ReadFile(store.fileName(key))
+	if os.IsNotExist(err) {
+		return nil, ErrObjectNotFound
+	}
+
+	return data, err
+}
+
+func (store DirectoryStore) fileName(key string) string {
+	return filepath.Join(store.Directory, filepath.Clean("/"+key))
+}
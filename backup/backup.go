@@ -0,0 +1,403 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package backup contains logical backup and restore of the whole database,
+// so deployments without DBA tooling can make a consistent snapshot of the
+// data before an upgrade and restore it into a fresh database.
+//
+// The backup is a text file with one JSON object per line (optionally
+// compressed by gzip), the type of the object is in its type attribute:
+//
+//	{"type":"header","format":"insights-results-aggregator-backup","format_version":1,
+//	 "created_at":"2020-01-23T16:15:59Z","db_driver":"postgres","migration_version":30}
+//	{"type":"table","table":"report","columns":["org_id","cluster",...]}
+//	{"type":"row","values":[1,"34c3ecc5-624a-49a5-bab8-4fdc5e51a266",...]}
+//	{"type":"table_end","table":"report","rows":1,"sha256":"..."}
+//	...
+//	{"type":"footer","tables":25}
+//
+// The header is followed by all tables, each of them starts by the table line
+// with names of the columns, continues by one line per row with the values
+// of the columns in the same order and ends by the table_end line with the
+// number of rows and SHA-256 checksum of the values of all rows (the JSON
+// arrays as they are written in row lines, each one followed by a new line).
+// The footer contains the number of tables, so the truncated backup is
+// detected. Timestamps are written as strings in RFC 3339 format in UTC.
+// The migration_info table is not written, its version is in the header,
+// neither is the migration_online_info table.
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator/migration"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const (
+	// FormatName identifies backups in the header
+	FormatName = "insights-results-aggregator-backup"
+	// FormatVersion is the version of the format of written backups
+	FormatVersion = 1
+
+	headerLine   = "header"
+	tableLine    = "table"
+	rowLine      = "row"
+	tableEndLine = "table_end"
+	footerLine   = "footer"
+
+	// migrationInfoTable is not backed up, the version is in the header
+	migrationInfoTable = "migration_info"
+	// migrationOnlineInfoTable is not backed up, it's initialized by migration
+	migrationOnlineInfoTable = "migration_online_info"
+	// gzipSuffix marks files compressed by gzip
+	gzipSuffix = ".gz"
+)
+
+// parentTables are referenced by foreign keys of other tables, so they are
+// written first and their rows are restored before rows referencing them
+var parentTables = []string{"report"}
+
+// header is the first line of the backup
+type header struct {
+	Type             string            `json:"type"`
+	Format           string            `json:"format"`
+	FormatVersion    int               `json:"format_version"`
+	CreatedAt        time.Time         `json:"created_at"`
+	DBDriver         string            `json:"db_driver"`
+	MigrationVersion migration.Version `json:"migration_version"`
+}
+
+// table starts rows of one table
+type table struct {
+	Type    string   `json:"type"`
+	Table   string   `json:"table"`
+	Columns []string `json:"columns"`
+}
+
+// row contains values of columns of one row of the table
+type row struct {
+	Type   string          `json:"type"`
+	Values json.RawMessage `json:"values"`
+}
+
+// tableEnd ends rows of one table
+type tableEnd struct {
+	Type   string `json:"type"`
+	Table  string `json:"table"`
+	Rows   int    `json:"rows"`
+	SHA256 string `json:"sha256"`
+}
+
+// footer is the last line of the backup
+type footer struct {
+	Type   string `json:"type"`
+	Tables int    `json:"tables"`
+}
+
+// Summary describes the written or restored backup
+type Summary struct {
+	MigrationVersion migration.Version `json:"migration_version"`
+	// Rows contains the number of rows of every table
+	Rows map[string]int `json:"rows"`
+}
+
+// ToFile writes the backup of the database into the file, it is compressed
+// by gzip when the name of the file ends by .gz. The file is written under
+// temporary name first, so an incomplete backup never has the final name.
+func ToFile(dbStorage *storage.DBStorage, path string) (Summary, error) {
+	tmpPath := path + ".tmp"
+
+	// #nosec G304
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	summary, err := writeFile(dbStorage, file, strings.HasSuffix(path, gzipSuffix))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return summary, err
+	}
+
+	return summary, os.Rename(tmpPath, path)
+}
+
+// writeFile writes the backup into the file, optionally compressed
+func writeFile(dbStorage *storage.DBStorage, file io.Writer, compress bool) (Summary, error) {
+	if !compress {
+		return Write(dbStorage, file)
+	}
+
+	gzipWriter := gzip.NewWriter(file)
+
+	summary, err := Write(dbStorage, gzipWriter)
+	if closeErr := gzipWriter.Close(); err == nil {
+		err = closeErr
+	}
+
+	return summary, err
+}
+
+// FromFile restores the backup from the file, it is decompressed by gzip
+// when the name of the file ends by .gz
+func FromFile(dbStorage *storage.DBStorage, path string) (Summary, error) {
+	// #nosec G304
+	file, err := os.Open(path)
+	if err != nil {
+		return Summary{}, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	if !strings.HasSuffix(path, gzipSuffix) {
+		return Restore(dbStorage, file)
+	}
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return Summary{}, err
+	}
+	defer func() {
+		_ = gzipReader.Close()
+	}()
+
+	return Restore(dbStorage, gzipReader)
+}
+
+// Write writes the backup of all tables of the database into the writer.
+// All tables are read in one read-only transaction, so the backup is a
+// consistent snapshot of the database even when the service is running.
+func Write(dbStorage *storage.DBStorage, writer io.Writer) (Summary, error) {
+	summary := Summary{Rows: make(map[string]int)}
+
+	schema, err := dbStorage.ReadDBSchema()
+	if err != nil {
+		return summary, err
+	}
+
+	tables := orderTables(schema.Tables)
+
+	tx, err := beginSnapshot(dbStorage)
+	if err != nil {
+		return summary, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// the version is read in the transaction, so it matches the data
+	err = tx.QueryRow("SELECT version FROM migration_info;").Scan(&summary.MigrationVersion)
+	if err != nil {
+		return summary, err
+	}
+
+	bufferedWriter := bufio.NewWriter(writer)
+	backup := json.NewEncoder(bufferedWriter)
+
+	err = backup.Encode(header{
+		Type:             headerLine,
+		Format:           FormatName,
+		FormatVersion:    FormatVersion,
+		CreatedAt:        time.Now().UTC(),
+		DBDriver:         driverName(dbStorage.GetDBDriverType()),
+		MigrationVersion: summary.MigrationVersion,
+	})
+	if err != nil {
+		return summary, err
+	}
+
+	for _, dbTable := range tables {
+		rows, err := writeTable(tx, backup, dbTable)
+		if err != nil {
+			return summary, fmt.Errorf("table %v: %v", dbTable.Name, err)
+		}
+
+		summary.Rows[dbTable.Name] = rows
+	}
+
+	if err := backup.Encode(footer{Type: footerLine, Tables: len(tables)}); err != nil {
+		return summary, err
+	}
+
+	return summary, bufferedWriter.Flush()
+}
+
+// orderTables returns all tables except tables of migrations, parent tables
+// go first
+func orderTables(dbTables []types.DBTable) []types.DBTable {
+	ordered := make([]types.DBTable, 0, len(dbTables))
+
+	for _, parent := range parentTables {
+		for _, dbTable := range dbTables {
+			if dbTable.Name == parent {
+				ordered = append(ordered, dbTable)
+			}
+		}
+	}
+
+	for _, dbTable := range dbTables {
+		if dbTable.Name != migrationInfoTable && dbTable.Name != migrationOnlineInfoTable &&
+			!isParentTable(dbTable.Name) {
+			ordered = append(ordered, dbTable)
+		}
+	}
+
+	return ordered
+}
+
+// isParentTable returns true for tables listed in parentTables
+func isParentTable(name string) bool {
+	for _, parent := range parentTables {
+		if name == parent {
+			return true
+		}
+	}
+
+	return false
+}
+
+// driverName returns name of the DB driver written into the header
+func driverName(driver types.DBDriver) string {
+	switch driver {
+	case types.DBDriverPostgres:
+		return "postgres"
+	case types.DBDriverSQLite3:
+		return "sqlite3"
+	default:
+		return "general"
+	}
+}
+
+// beginSnapshot starts read-only transaction in which all reads see the same
+// snapshot of the database. SQLite transactions are always serializable.
+func beginSnapshot(dbStorage *storage.DBStorage) (*sql.Tx, error) {
+	var options *sql.TxOptions
+	if dbStorage.GetDBDriverType() == types.DBDriverPostgres {
+		options = &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	}
+
+	return dbStorage.GetConnection().BeginTx(context.Background(), options)
+}
+
+// writeTable writes all rows of the table and returns their number
+func writeTable(tx *sql.Tx, backup *json.Encoder, dbTable types.DBTable) (int, error) {
+	columns := make([]string, 0, len(dbTable.Columns))
+	for _, column := range dbTable.Columns {
+		columns = append(columns, column.Name)
+	}
+
+	if err := backup.Encode(table{Type: tableLine, Table: dbTable.Name, Columns: columns}); err != nil {
+		return 0, err
+	}
+
+	// #nosec G202
+	rows, err := tx.Query(fmt.Sprintf(
+		"SELECT %v FROM %v;", quoteIdentifiers(columns), quoteIdentifier(dbTable.Name),
+	))
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	checksum := sha256.New()
+	count := 0
+
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return count, err
+		}
+
+		for i, value := range values {
+			values[i] = backupValue(value)
+		}
+
+		encoded, err := json.Marshal(values)
+		if err != nil {
+			return count, err
+		}
+
+		if err := backup.Encode(row{Type: rowLine, Values: encoded}); err != nil {
+			return count, err
+		}
+
+		_, _ = checksum.Write(append(encoded, '\n'))
+		count++
+	}
+
+	if err := rows.Err(); err != nil {
+		return count, err
+	}
+
+	return count, backup.Encode(tableEnd{
+		Type:   tableEndLine,
+		Table:  dbTable.Name,
+		Rows:   count,
+		SHA256: hex.EncodeToString(checksum.Sum(nil)),
+	})
+}
+
+// backupValue converts value read from the database into the value written
+// into the backup
+func backupValue(value interface{}) interface{} {
+	switch typedValue := value.(type) {
+	case []byte:
+		return string(typedValue)
+	case time.Time:
+		return typedValue.UTC().Format(time.RFC3339Nano)
+	default:
+		return value
+	}
+}
+
+// quoteIdentifier quotes name of the table or column
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quoteIdentifiers quotes names of columns and joins them by commas
+func quoteIdentifiers(names []string) string {
+	quoted := make([]string, 0, len(names))
+	for _, name := range names {
+		quoted = append(quoted, quoteIdentifier(name))
+	}
+
+	return strings.Join(quoted, ", ")
+}
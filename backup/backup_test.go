@@ -0,0 +1,219 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/backup"
+	"github.com/RedHatInsights/insights-results-aggregator/migration"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mustGetStorageWithData returns SQLite storage with report of the cluster
+// hit by one rule which is disabled by the user
+func mustGetStorageWithData(t *testing.T) (*storage.DBStorage, func()) {
+	mockStorage, closer := ira_helpers.MustGetSQLiteMemoryStorage(t, true)
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report0Rules,
+		[]types.ReportItem{{
+			Module:       testdata.Rule1ID,
+			ErrorKey:     testdata.ErrorKey1,
+			TemplateData: []byte(`{"version": "4.5.1"}`),
+		}},
+		time.Date(2020, 1, 23, 16, 15, 59, 0, time.UTC), testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	err = mockStorage.ToggleRuleForCluster(
+		testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, storage.RuleToggleDisable,
+	)
+	helpers.FailOnError(t, err)
+
+	return mockStorage.(*storage.DBStorage), closer
+}
+
+// mustGetEmptyStorage returns SQLite storage which is not migrated yet
+func mustGetEmptyStorage(t *testing.T) (*storage.DBStorage, func()) {
+	mockStorage, closer := ira_helpers.MustGetSQLiteMemoryStorage(t, false)
+	return mockStorage.(*storage.DBStorage), closer
+}
+
+// mustWriteBackup writes backup of the storage into the buffer
+func mustWriteBackup(t *testing.T, dbStorage *storage.DBStorage) *bytes.Buffer {
+	var buffer bytes.Buffer
+
+	summary, err := backup.Write(dbStorage, &buffer)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, migration.GetMaxVersion(), summary.MigrationVersion)
+	assert.Equal(t, 1, summary.Rows["report"])
+	assert.Equal(t, 1, summary.Rows["rule_hit"])
+	assert.NotContains(t, summary.Rows, "migration_info")
+
+	return &buffer
+}
+
+func TestWriteAndRestore(t *testing.T) {
+	source, closeSource := mustGetStorageWithData(t)
+	defer closeSource()
+
+	buffer := mustWriteBackup(t, source)
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	assert.Contains(t, lines[0], `"format":"insights-results-aggregator-backup"`)
+	assert.Equal(t, `{"type":"table","table":"report",`, lines[1][:len(`{"type":"table","table":"report",`)])
+	assert.Contains(t, lines[len(lines)-1], `"type":"footer"`)
+
+	target, closeTarget := mustGetEmptyStorage(t)
+	defer closeTarget()
+
+	summary, err := backup.Restore(target, buffer)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, migration.GetMaxVersion(), summary.MigrationVersion)
+	assert.Equal(t, 1, summary.Rows["report"])
+
+	helpers.FailOnError(t, target.Init())
+
+	clusters, err := target.ListOfClustersForOrg(testdata.OrgID, time.Time{}, 0, 0)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []types.ClusterName{testdata.ClusterName}, clusters)
+
+	ruleHits, err := target.ReadRuleHitsForCluster(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Len(t, ruleHits, 1)
+	assert.Equal(t, `{"version": "4.5.1"}`, ruleHits[0].TemplateData)
+
+	toggle, err := target.GetFromClusterRuleToggle(testdata.ClusterName, testdata.Rule1ID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, storage.RuleToggleDisable, toggle.Disabled)
+
+	// timestamps are restored as timestamps, so they can be compared
+	count, err := target.CountClustersNotCheckedSince(time.Date(2020, 1, 24, 0, 0, 0, 0, time.UTC))
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 1, count)
+
+	// the same backup is written from the restored database
+	restoredBuffer := mustWriteBackup(t, target)
+	assert.Equal(t, lines[1:], strings.Split(strings.TrimSpace(restoredBuffer.String()), "\n")[1:])
+}
+
+func TestToFileAndFromFile(t *testing.T) {
+	for _, name := range []string{"backup.jsonl", "backup.jsonl.gz"} {
+		path := filepath.Join(t.TempDir(), name)
+
+		source, closeSource := mustGetStorageWithData(t)
+		_, err := backup.ToFile(source, path)
+		closeSource()
+		helpers.FailOnError(t, err)
+
+		_, err = os.Stat(path + ".tmp")
+		assert.True(t, os.IsNotExist(err))
+
+		target, closeTarget := mustGetEmptyStorage(t)
+		summary, err := backup.FromFile(target, path)
+		closeTarget()
+		helpers.FailOnError(t, err)
+		assert.Equal(t, 1, summary.Rows["rule_hit"])
+	}
+}
+
+func TestRestore_NotEmptyDatabase(t *testing.T) {
+	source, closeSource := mustGetStorageWithData(t)
+	defer closeSource()
+
+	_, err := backup.Restore(source, mustWriteBackup(t, source))
+	assert.EqualError(t, err, "table report: table is not empty")
+}
+
+func TestRestore_NewerDatabase(t *testing.T) {
+	source, closeSource := mustGetStorageWithData(t)
+	defer closeSource()
+
+	buffer := mustWriteBackup(t, source)
+
+	target, closeTarget := mustGetEmptyStorage(t)
+	defer closeTarget()
+
+	helpers.FailOnError(t, migration.InitInfoTable(target.GetConnection()))
+	helpers.FailOnError(t, migration.SetDBVersion(target.GetConnection(), target.GetDBDriverType(), 1))
+
+	backupWithOldVersion := strings.Replace(
+		buffer.String(), `"migration_version":`+fmt.Sprint(migration.GetMaxVersion()), `"migration_version":0`, 1,
+	)
+
+	_, err := backup.Restore(target, strings.NewReader(backupWithOldVersion))
+	assert.EqualError(t, err, "database is at migration version 1, it can't be newer than version 0 of the backup")
+}
+
+func TestRestore_CorruptedBackup(t *testing.T) {
+	source, closeSource := mustGetStorageWithData(t)
+	defer closeSource()
+
+	backupContent := mustWriteBackup(t, source).String()
+	lines := strings.SplitAfter(backupContent, "\n")
+
+	for name, testCase := range map[string]struct {
+		content string
+		err     string
+	}{
+		"changed row": {
+			content: strings.Replace(backupContent, "4.5.1", "4.5.2", 1),
+			err:     "table rule_hit: checksum of rows doesn't match",
+		},
+		"truncated": {
+			content: strings.Join(lines[:len(lines)-2], ""),
+			err:     "backup is truncated",
+		},
+		"other format": {
+			content: strings.Replace(backupContent, backup.FormatName, "other", 1),
+			err:     "unsupported format other version 1 of backup",
+		},
+		"not JSON": {
+			content: "not a backup\n",
+			err:     "invalid line of backup: invalid character 'o' in literal null (expecting 'u')",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// the target is migrated, so its tables can be checked even
+			// when the backup is rejected before the migration
+			target, closeTarget := mustGetEmptyStorage(t)
+			defer closeTarget()
+			helpers.FailOnError(t, target.MigrateToLatest())
+
+			_, err := backup.Restore(target, strings.NewReader(testCase.content))
+			assert.EqualError(t, err, testCase.err)
+
+			// nothing is restored
+			reportsCount, err := target.ReportsCount()
+			helpers.FailOnError(t, err)
+			assert.Equal(t, 0, reportsCount)
+		})
+	}
+}
@@ -0,0 +1,361 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator/migration"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+)
+
+// errTruncatedBackup is returned when the backup ends before its footer
+var errTruncatedBackup = errors.New("backup is truncated")
+
+// backupReader reads lines of the backup one by one
+type backupReader struct {
+	reader *bufio.Reader
+	line   []byte
+	// lineType is the type attribute of the last read line
+	lineType string
+}
+
+// next reads the next line of the backup, io.EOF is returned at the end
+func (reader *backupReader) next() error {
+	line, err := reader.reader.ReadBytes('\n')
+	if err == io.EOF && len(line) == 0 {
+		return io.EOF
+	}
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	var lineType struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(line, &lineType); err != nil {
+		return fmt.Errorf("invalid line of backup: %v", err)
+	}
+
+	reader.line = line
+	reader.lineType = lineType.Type
+
+	return nil
+}
+
+// expect reads the next line of the backup, checks its type and decodes it
+func (reader *backupReader) expect(lineType string, value interface{}) error {
+	err := reader.next()
+	if err == io.EOF {
+		return errTruncatedBackup
+	}
+	if err != nil {
+		return err
+	}
+
+	if reader.lineType != lineType {
+		return fmt.Errorf("%v line expected, got %v", lineType, reader.lineType)
+	}
+
+	return json.Unmarshal(reader.line, value)
+}
+
+// Restore restores the backup into the database. The database is migrated
+// to the version of the backup first, so the backup can't be restored into
+// a database with newer version. All restored tables have to be empty. All
+// rows are inserted in one transaction, nothing is restored when the backup
+// is corrupted or any row can't be inserted. The database can be migrated to
+// the latest version after the restore.
+func Restore(dbStorage *storage.DBStorage, reader io.Reader) (Summary, error) {
+	summary := Summary{Rows: make(map[string]int)}
+	backup := &backupReader{reader: bufio.NewReader(reader)}
+
+	var backupHeader header
+	if err := backup.expect(headerLine, &backupHeader); err != nil {
+		return summary, err
+	}
+
+	if backupHeader.Format != FormatName || backupHeader.FormatVersion != FormatVersion {
+		return summary, fmt.Errorf(
+			"unsupported format %v version %v of backup", backupHeader.Format, backupHeader.FormatVersion,
+		)
+	}
+	summary.MigrationVersion = backupHeader.MigrationVersion
+
+	if err := migrateToBackupVersion(dbStorage, backupHeader.MigrationVersion); err != nil {
+		return summary, err
+	}
+
+	timestampColumns, err := readTimestampColumns(dbStorage)
+	if err != nil {
+		return summary, err
+	}
+
+	tx, err := dbStorage.GetConnection().Begin()
+	if err != nil {
+		return summary, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	tables := 0
+
+	for {
+		err := backup.next()
+		if err == io.EOF {
+			return summary, errTruncatedBackup
+		}
+		if err != nil {
+			return summary, err
+		}
+
+		if backup.lineType == footerLine {
+			break
+		}
+
+		var backupTable table
+		if backup.lineType != tableLine {
+			return summary, fmt.Errorf("%v line expected, got %v", tableLine, backup.lineType)
+		}
+		if err := json.Unmarshal(backup.line, &backupTable); err != nil {
+			return summary, err
+		}
+
+		rows, err := restoreTable(tx, backup, backupTable, timestampColumns[backupTable.Table])
+		if err != nil {
+			return summary, fmt.Errorf("table %v: %v", backupTable.Table, err)
+		}
+
+		summary.Rows[backupTable.Table] = rows
+		tables++
+	}
+
+	var backupFooter footer
+	if err := json.Unmarshal(backup.line, &backupFooter); err != nil {
+		return summary, err
+	}
+
+	if backupFooter.Tables != tables {
+		return summary, fmt.Errorf("backup contains %v tables, %v tables expected", tables, backupFooter.Tables)
+	}
+
+	if err := backup.next(); err != io.EOF {
+		return summary, fmt.Errorf("unexpected data after the footer of backup")
+	}
+
+	return summary, tx.Commit()
+}
+
+// migrateToBackupVersion migrates the database to the version of the backup
+func migrateToBackupVersion(dbStorage *storage.DBStorage, version migration.Version) error {
+	connection := dbStorage.GetConnection()
+
+	if err := migration.InitInfoTable(connection); err != nil {
+		return err
+	}
+
+	currentVersion, err := migration.GetDBVersion(connection)
+	if err != nil {
+		return err
+	}
+
+	if currentVersion > version {
+		return fmt.Errorf(
+			"database is at migration version %v, it can't be newer than version %v of the backup",
+			currentVersion, version,
+		)
+	}
+
+	if version > migration.GetMaxVersion() {
+		return fmt.Errorf(
+			"migration version %v of the backup is newer than the latest available version %v",
+			version, migration.GetMaxVersion(),
+		)
+	}
+
+	return migration.SetDBVersion(connection, dbStorage.GetDBDriverType(), version)
+}
+
+// readTimestampColumns returns names of timestamp columns of all tables,
+// timestamps are restored from strings
+func readTimestampColumns(dbStorage *storage.DBStorage) (map[string]map[string]bool, error) {
+	schema, err := dbStorage.ReadDBSchema()
+	if err != nil {
+		return nil, err
+	}
+
+	timestampColumns := make(map[string]map[string]bool)
+
+	for _, dbTable := range schema.Tables {
+		columns := make(map[string]bool)
+
+		for _, column := range dbTable.Columns {
+			columnType := strings.ToLower(column.Type)
+			if strings.HasPrefix(columnType, "timestamp") || strings.HasPrefix(columnType, "datetime") {
+				columns[column.Name] = true
+			}
+		}
+
+		timestampColumns[dbTable.Name] = columns
+	}
+
+	return timestampColumns, nil
+}
+
+// restoreTable inserts all rows of the table from the backup and returns
+// their number, the table has to be empty
+func restoreTable(
+	tx *sql.Tx, backup *backupReader, backupTable table, timestampColumns map[string]bool,
+) (int, error) {
+	var count int
+
+	// #nosec G202
+	err := tx.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %v;", quoteIdentifier(backupTable.Table))).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	if count != 0 {
+		return 0, fmt.Errorf("table is not empty")
+	}
+
+	placeholders := make([]string, 0, len(backupTable.Columns))
+	for i := range backupTable.Columns {
+		placeholders = append(placeholders, fmt.Sprintf("$%d", i+1))
+	}
+
+	// #nosec G201
+	insert, err := tx.Prepare(fmt.Sprintf(
+		"INSERT INTO %v (%v) VALUES (%v);",
+		quoteIdentifier(backupTable.Table),
+		quoteIdentifiers(backupTable.Columns),
+		strings.Join(placeholders, ", "),
+	))
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = insert.Close()
+	}()
+
+	checksum := sha256.New()
+
+	for {
+		err := backup.next()
+		if err == io.EOF {
+			return count, errTruncatedBackup
+		}
+		if err != nil {
+			return count, err
+		}
+
+		if backup.lineType != rowLine {
+			break
+		}
+
+		if err := restoreRow(insert, backup.line, backupTable.Columns, timestampColumns, checksum); err != nil {
+			return count, fmt.Errorf("row %v: %v", count+1, err)
+		}
+
+		count++
+	}
+
+	var end tableEnd
+	if backup.lineType != tableEndLine {
+		return count, fmt.Errorf("%v line expected, got %v", tableEndLine, backup.lineType)
+	}
+	if err := json.Unmarshal(backup.line, &end); err != nil {
+		return count, err
+	}
+
+	if end.Table != backupTable.Table || end.Rows != count {
+		return count, fmt.Errorf("table contains %v rows, %v rows of table %v expected", count, end.Rows, end.Table)
+	}
+
+	if hex.EncodeToString(checksum.Sum(nil)) != end.SHA256 {
+		return count, fmt.Errorf("checksum of rows doesn't match")
+	}
+
+	return count, nil
+}
+
+// restoreRow inserts one row from the backup
+func restoreRow(
+	insert *sql.Stmt, line []byte, columns []string, timestampColumns map[string]bool, checksum hash.Hash,
+) error {
+	var backupRow row
+	if err := json.Unmarshal(line, &backupRow); err != nil {
+		return err
+	}
+
+	_, _ = checksum.Write(append([]byte(backupRow.Values), '\n'))
+
+	decoder := json.NewDecoder(bytes.NewReader(backupRow.Values))
+	decoder.UseNumber()
+
+	var values []interface{}
+	if err := decoder.Decode(&values); err != nil {
+		return err
+	}
+
+	if len(values) != len(columns) {
+		return fmt.Errorf("%v values expected, got %v", len(columns), len(values))
+	}
+
+	for i, value := range values {
+		restored, err := restoreValue(value, timestampColumns[columns[i]])
+		if err != nil {
+			return fmt.Errorf("column %v: %v", columns[i], err)
+		}
+
+		values[i] = restored
+	}
+
+	_, err := insert.Exec(values...)
+	return err
+}
+
+// restoreValue converts value from the backup into the value inserted into
+// the database
+func restoreValue(value interface{}, timestamp bool) (interface{}, error) {
+	switch typedValue := value.(type) {
+	case json.Number:
+		if intValue, err := typedValue.Int64(); err == nil {
+			return intValue, nil
+		}
+		return typedValue.Float64()
+	case string:
+		if timestamp {
+			return time.Parse(time.RFC3339Nano, typedValue)
+		}
+		return typedValue, nil
+	default:
+		return value, nil
+	}
+}
@@ -26,13 +26,56 @@ import (
 
 // Configuration represents configuration of Kafka broker
 type Configuration struct {
-	Address             string        `mapstructure:"address" toml:"address"`
-	Topic               string        `mapstructure:"topic" toml:"topic"`
-	Timeout             time.Duration `mapstructure:"timeout" toml:"timeout"`
-	PayloadTrackerTopic string        `mapstructure:"payload_tracker_topic" toml:"payload_tracker_topic"`
-	ServiceName         string        `mapstructure:"service_name" toml:"service_name"`
-	Group               string        `mapstructure:"group" toml:"group"`
-	Enabled             bool          `mapstructure:"enabled" toml:"enabled"`
-	OrgAllowlist        mapset.Set    `mapstructure:"org_allowlist_file" toml:"org_allowlist_file"`
-	OrgAllowlistEnabled bool          `mapstructure:"enable_org_allowlist" toml:"enable_org_allowlist"`
+	Address               string        `mapstructure:"address" toml:"address"`
+	Topic                 string        `mapstructure:"topic" toml:"topic"`
+	Timeout               time.Duration `mapstructure:"timeout" toml:"timeout"`
+	PayloadTrackerTopic   string        `mapstructure:"payload_tracker_topic" toml:"payload_tracker_topic"`
+	RuleToggleTopic       string        `mapstructure:"rule_toggle_topic" toml:"rule_toggle_topic"`
+	ServiceName           string        `mapstructure:"service_name" toml:"service_name"`
+	Group                 string        `mapstructure:"group" toml:"group"`
+	MessageBufferSize     int           `mapstructure:"message_buffer_size" toml:"message_buffer_size"`
+	Enabled               bool          `mapstructure:"enabled" toml:"enabled"`
+	OrgAllowlist          mapset.Set    `mapstructure:"org_allowlist_file" toml:"org_allowlist_file"`
+	OrgAllowlistEnabled   bool          `mapstructure:"enable_org_allowlist" toml:"enable_org_allowlist"`
+	NormalizeClusterNames bool          `mapstructure:"normalize_cluster_names" toml:"normalize_cluster_names"`
+	DecompressPayloads    bool          `mapstructure:"decompress_payloads" toml:"decompress_payloads"`
+	TLSEnabled            bool          `mapstructure:"tls_enabled" toml:"tls_enabled"`
+	TLSCACert             string        `mapstructure:"tls_ca_cert" toml:"tls_ca_cert"`
+	TLSClientCert         string        `mapstructure:"tls_client_cert" toml:"tls_client_cert"`
+	TLSClientKey          string        `mapstructure:"tls_client_key" toml:"tls_client_key"`
+	TLSInsecureSkipVerify bool          `mapstructure:"tls_insecure_skip_verify" toml:"tls_insecure_skip_verify"`
+	SASLMechanism         string        `mapstructure:"sasl_mechanism" toml:"sasl_mechanism"`
+	SASLUsername          string        `mapstructure:"sasl_username" toml:"sasl_username"`
+	SASLPassword          string        `mapstructure:"sasl_password" toml:"sasl_password"`
+	// HistoricalReports enables ingestion of reports uploaded in bulk with
+	// historical timestamps, e.g. from disconnected clusters. Such reports
+	// are stored into history of reports, the latest report of the cluster
+	// is replaced only when the uploaded report is more recent.
+	HistoricalReports bool `mapstructure:"historical_reports" toml:"historical_reports"`
+	// OrgRateWindow enables detection of anomalous message rates of
+	// organizations, see orgRateTracker in consumer package
+	OrgRateWindow          time.Duration `mapstructure:"org_rate_window" toml:"org_rate_window"`
+	OrgRateBaselineWindows int           `mapstructure:"org_rate_baseline_windows" toml:"org_rate_baseline_windows"`
+	OrgRateAnomalyFactor   float64       `mapstructure:"org_rate_anomaly_factor" toml:"org_rate_anomaly_factor"`
+	OrgRateMinMessages     int           `mapstructure:"org_rate_min_messages" toml:"org_rate_min_messages"`
+	OrgRateThrottle        bool          `mapstructure:"org_rate_throttle" toml:"org_rate_throttle"`
+	// ExternalResultsTopic enables consuming of results of checks done by
+	// external sources (security scanners, for example) from the topic
+	ExternalResultsTopic string `mapstructure:"external_results_topic" toml:"external_results_topic"`
+	// RebalanceStrategy is the strategy of assigning partitions to members
+	// of the consumer group, see RebalanceStrategies
+	RebalanceStrategy string `mapstructure:"rebalance_strategy" toml:"rebalance_strategy"`
+	// SessionRetryBackoff is the time the consumer waits before joining the
+	// consumer group again when the consumer group session failed
+	SessionRetryBackoff time.Duration `mapstructure:"session_retry_backoff" toml:"session_retry_backoff"`
+	// options of the produce helper shared by all Kafka producers (payload
+	// tracker, rule toggle events): number of retries of failed deliveries,
+	// backoff before the first retry doubled for every next one up to the
+	// maximum, and circuit breaker opened for the cooldown after the
+	// threshold of consecutive failed deliveries (0 disables it)
+	ProducerRetries                 int           `mapstructure:"producer_retries" toml:"producer_retries"`
+	ProducerRetryBackoff            time.Duration `mapstructure:"producer_retry_backoff" toml:"producer_retry_backoff"`
+	ProducerMaxRetryBackoff         time.Duration `mapstructure:"producer_max_retry_backoff" toml:"producer_max_retry_backoff"`
+	ProducerCircuitBreakerThreshold int           `mapstructure:"producer_circuit_breaker_threshold" toml:"producer_circuit_breaker_threshold"`
+	ProducerCircuitBreakerCooldown  time.Duration `mapstructure:"producer_circuit_breaker_cooldown" toml:"producer_circuit_breaker_cooldown"`
 }
@@ -0,0 +1,56 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package broker
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Shopify/sarama"
+)
+
+// Strategies of assigning partitions to members of the consumer group
+const (
+	RebalanceStrategyRange      = "range"
+	RebalanceStrategyRoundRobin = "roundrobin"
+	RebalanceStrategySticky     = "sticky"
+)
+
+// RebalanceStrategies contains all supported rebalance strategies
+var RebalanceStrategies = []string{
+	RebalanceStrategyRange, RebalanceStrategyRoundRobin, RebalanceStrategySticky,
+}
+
+// ApplyConsumerGroupConfiguration sets the rebalance strategy of the Sarama
+// configuration according to the broker configuration. Sarama default
+// (range) is kept when no strategy is configured.
+func ApplyConsumerGroupConfiguration(saramaConfig *sarama.Config, configuration Configuration) error {
+	switch strings.ToLower(configuration.RebalanceStrategy) {
+	case "":
+		return nil
+	case RebalanceStrategyRange:
+		saramaConfig.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRange
+	case RebalanceStrategyRoundRobin:
+		saramaConfig.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRoundRobin
+	case RebalanceStrategySticky:
+		saramaConfig.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategySticky
+	default:
+		return fmt.Errorf("unsupported rebalance strategy %s", configuration.RebalanceStrategy)
+	}
+
+	return nil
+}
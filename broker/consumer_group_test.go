@@ -0,0 +1,55 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package broker_test
+
+import (
+	"testing"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+)
+
+func TestApplyConsumerGroupConfiguration(t *testing.T) {
+	for _, testCase := range []struct {
+		strategy string
+		expected sarama.BalanceStrategy
+	}{
+		{"", sarama.BalanceStrategyRange},
+		{"range", sarama.BalanceStrategyRange},
+		{"roundrobin", sarama.BalanceStrategyRoundRobin},
+		{"Sticky", sarama.BalanceStrategySticky},
+	} {
+		saramaConfig := sarama.NewConfig()
+
+		err := broker.ApplyConsumerGroupConfiguration(saramaConfig, broker.Configuration{
+			RebalanceStrategy: testCase.strategy,
+		})
+		helpers.FailOnError(t, err)
+
+		assert.Equal(t, testCase.expected, saramaConfig.Consumer.Group.Rebalance.Strategy, testCase.strategy)
+	}
+}
+
+func TestApplyConsumerGroupConfigurationUnknownStrategy(t *testing.T) {
+	err := broker.ApplyConsumerGroupConfiguration(sarama.NewConfig(), broker.Configuration{
+		RebalanceStrategy: "random",
+	})
+	assert.EqualError(t, err, "unsupported rebalance strategy random")
+}
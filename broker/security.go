@@ -0,0 +1,134 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package broker
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"github.com/xdg/scram"
+)
+
+// ApplySecurityConfiguration sets TLS and SASL options of the Sarama
+// configuration according to the broker configuration. Nothing is changed
+// when neither TLS nor SASL is configured.
+func ApplySecurityConfiguration(saramaConfig *sarama.Config, configuration Configuration) error {
+	if configuration.TLSEnabled {
+		tlsConfig, err := newTLSConfig(configuration)
+		if err != nil {
+			return err
+		}
+
+		saramaConfig.Net.TLS.Enable = true
+		saramaConfig.Net.TLS.Config = tlsConfig
+	}
+
+	if configuration.SASLMechanism == "" {
+		return nil
+	}
+
+	saramaConfig.Net.SASL.Enable = true
+	saramaConfig.Net.SASL.Handshake = true
+	saramaConfig.Net.SASL.User = configuration.SASLUsername
+	saramaConfig.Net.SASL.Password = configuration.SASLPassword
+
+	switch strings.ToUpper(configuration.SASLMechanism) {
+	case sarama.SASLTypePlaintext:
+		saramaConfig.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+	case sarama.SASLTypeSCRAMSHA256:
+		saramaConfig.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
+		saramaConfig.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &scramClient{hashGenerator: sha256.New}
+		}
+	case sarama.SASLTypeSCRAMSHA512:
+		saramaConfig.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
+		saramaConfig.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &scramClient{hashGenerator: sha512.New}
+		}
+	default:
+		return fmt.Errorf("unsupported SASL mechanism %s", configuration.SASLMechanism)
+	}
+
+	return nil
+}
+
+// newTLSConfig constructs TLS configuration with optional custom CA
+// certificate and client certificate
+func newTLSConfig(configuration Configuration) (*tls.Config, error) {
+	// #nosec G402
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: configuration.TLSInsecureSkipVerify,
+	}
+
+	if configuration.TLSCACert != "" {
+		caCert, err := ioutil.ReadFile(filepath.Clean(configuration.TLSCACert))
+		if err != nil {
+			return nil, err
+		}
+
+		caCertPool := x509.NewCertPool()
+		if !caCertPool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no valid certificate found in %s", configuration.TLSCACert)
+		}
+		tlsConfig.RootCAs = caCertPool
+	}
+
+	if configuration.TLSClientCert != "" || configuration.TLSClientKey != "" {
+		clientCert, err := tls.LoadX509KeyPair(configuration.TLSClientCert, configuration.TLSClientKey)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{clientCert}
+	}
+
+	return tlsConfig, nil
+}
+
+// scramClient implements sarama.SCRAMClient interface using xdg/scram
+// library
+type scramClient struct {
+	hashGenerator scram.HashGeneratorFcn
+	conversation  *scram.ClientConversation
+}
+
+// Begin prepares the client for the SCRAM exchange
+func (client *scramClient) Begin(userName, password, authzID string) error {
+	scramClient, err := client.hashGenerator.NewClient(userName, password, authzID)
+	if err != nil {
+		return err
+	}
+
+	client.conversation = scramClient.NewConversation()
+	return nil
+}
+
+// Step steps client through the SCRAM exchange
+func (client *scramClient) Step(challenge string) (string, error) {
+	return client.conversation.Step(challenge)
+}
+
+// Done returns true when the SCRAM conversation is over
+func (client *scramClient) Done() bool {
+	return client.conversation.Done()
+}
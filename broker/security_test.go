@@ -0,0 +1,165 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package broker_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+)
+
+// mustWriteCertificate generates self-signed certificate with its key and
+// writes both into the directory in PEM format
+func mustWriteCertificate(t *testing.T, directory string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	helpers.FailOnError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kafka"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	helpers.FailOnError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	helpers.FailOnError(t, err)
+
+	certFile = filepath.Join(directory, "cert.pem")
+	keyFile = filepath.Join(directory, "key.pem")
+
+	helpers.FailOnError(t, ioutil.WriteFile(
+		certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), 0600,
+	))
+	helpers.FailOnError(t, ioutil.WriteFile(
+		keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600,
+	))
+
+	return certFile, keyFile
+}
+
+func TestApplySecurityConfiguration_Disabled(t *testing.T) {
+	saramaConfig := sarama.NewConfig()
+
+	helpers.FailOnError(t, broker.ApplySecurityConfiguration(saramaConfig, broker.Configuration{}))
+
+	assert.False(t, saramaConfig.Net.TLS.Enable)
+	assert.False(t, saramaConfig.Net.SASL.Enable)
+}
+
+func TestApplySecurityConfiguration_TLS(t *testing.T) {
+	certFile, keyFile := mustWriteCertificate(t, t.TempDir())
+	saramaConfig := sarama.NewConfig()
+
+	err := broker.ApplySecurityConfiguration(saramaConfig, broker.Configuration{
+		TLSEnabled:    true,
+		TLSCACert:     certFile,
+		TLSClientCert: certFile,
+		TLSClientKey:  keyFile,
+	})
+	helpers.FailOnError(t, err)
+
+	assert.True(t, saramaConfig.Net.TLS.Enable)
+	assert.NotNil(t, saramaConfig.Net.TLS.Config.RootCAs)
+	assert.Len(t, saramaConfig.Net.TLS.Config.Certificates, 1)
+	assert.False(t, saramaConfig.Net.TLS.Config.InsecureSkipVerify)
+}
+
+func TestApplySecurityConfiguration_TLSInsecureSkipVerify(t *testing.T) {
+	saramaConfig := sarama.NewConfig()
+
+	err := broker.ApplySecurityConfiguration(saramaConfig, broker.Configuration{
+		TLSEnabled:            true,
+		TLSInsecureSkipVerify: true,
+	})
+	helpers.FailOnError(t, err)
+
+	assert.True(t, saramaConfig.Net.TLS.Enable)
+	assert.True(t, saramaConfig.Net.TLS.Config.InsecureSkipVerify)
+}
+
+func TestApplySecurityConfiguration_TLSMissingCACert(t *testing.T) {
+	err := broker.ApplySecurityConfiguration(sarama.NewConfig(), broker.Configuration{
+		TLSEnabled: true,
+		TLSCACert:  "/non/existing/ca.pem",
+	})
+	assert.EqualError(t, err, "open /non/existing/ca.pem: no such file or directory")
+}
+
+func TestApplySecurityConfiguration_TLSInvalidCACert(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	helpers.FailOnError(t, ioutil.WriteFile(caFile, []byte("not a certificate"), 0600))
+
+	err := broker.ApplySecurityConfiguration(sarama.NewConfig(), broker.Configuration{
+		TLSEnabled: true,
+		TLSCACert:  caFile,
+	})
+	assert.EqualError(t, err, "no valid certificate found in "+caFile)
+}
+
+func TestApplySecurityConfiguration_SASL(t *testing.T) {
+	for mechanism, expected := range map[string]sarama.SASLMechanism{
+		"PLAIN":         sarama.SASLTypePlaintext,
+		"scram-sha-256": sarama.SASLTypeSCRAMSHA256,
+		"SCRAM-SHA-512": sarama.SASLTypeSCRAMSHA512,
+	} {
+		saramaConfig := sarama.NewConfig()
+
+		err := broker.ApplySecurityConfiguration(saramaConfig, broker.Configuration{
+			SASLMechanism: mechanism,
+			SASLUsername:  "user",
+			SASLPassword:  "password",
+		})
+		helpers.FailOnError(t, err)
+
+		assert.True(t, saramaConfig.Net.SASL.Enable)
+		assert.Equal(t, expected, saramaConfig.Net.SASL.Mechanism)
+		assert.Equal(t, "user", saramaConfig.Net.SASL.User)
+		assert.Equal(t, "password", saramaConfig.Net.SASL.Password)
+
+		if expected != sarama.SASLTypePlaintext {
+			client := saramaConfig.Net.SASL.SCRAMClientGeneratorFunc()
+			helpers.FailOnError(t, client.Begin("user", "password", ""))
+			assert.False(t, client.Done())
+		}
+	}
+}
+
+func TestApplySecurityConfiguration_UnsupportedSASLMechanism(t *testing.T) {
+	err := broker.ApplySecurityConfiguration(sarama.NewConfig(), broker.Configuration{
+		SASLMechanism: "GSSAPI",
+	})
+	assert.EqualError(t, err, "unsupported SASL mechanism GSSAPI")
+}
@@ -0,0 +1,62 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package broker
+
+import (
+	"github.com/Shopify/sarama"
+)
+
+// HighWaterMarks reads high-water marks of topic partitions from the broker.
+// High-water mark is the offset the next message produced into the
+// partition will get.
+type HighWaterMarks struct {
+	client sarama.Client
+}
+
+// NewHighWaterMarks constructs reader of high-water marks connected to the
+// configured broker
+func NewHighWaterMarks(configuration Configuration) (*HighWaterMarks, error) {
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.Version = sarama.V0_10_2_0
+
+	if configuration.Timeout > 0 {
+		saramaConfig.Net.DialTimeout = configuration.Timeout
+		saramaConfig.Net.ReadTimeout = configuration.Timeout
+		saramaConfig.Net.WriteTimeout = configuration.Timeout
+	}
+
+	if err := ApplySecurityConfiguration(saramaConfig, configuration); err != nil {
+		return nil, err
+	}
+
+	client, err := sarama.NewClient([]string{configuration.Address}, saramaConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HighWaterMarks{client: client}, nil
+}
+
+// HighWaterMark returns high-water mark of the topic partition
+func (marks *HighWaterMarks) HighWaterMark(topic string, partition int32) (int64, error) {
+	return marks.client.GetOffset(topic, partition, sarama.OffsetNewest)
+}
+
+// Close closes connection to the broker
+func (marks *HighWaterMarks) Close() error {
+	return marks.client.Close()
+}
@@ -0,0 +1,62 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package broker_test
+
+import (
+	"testing"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+)
+
+const testTopic = "ccx.ocp.results"
+
+func TestHighWaterMarks(t *testing.T) {
+	mockBroker := sarama.NewMockBroker(t, 0)
+	defer mockBroker.Close()
+
+	mockBroker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(mockBroker.Addr(), mockBroker.BrokerID()).
+			SetLeader(testTopic, 0, mockBroker.BrokerID()),
+		// Kafka 0.10.2 used by the client sends offset requests of version 1
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetVersion(1).
+			SetOffset(testTopic, 0, sarama.OffsetNewest, 42),
+	})
+
+	marks, err := broker.NewHighWaterMarks(broker.Configuration{Address: mockBroker.Addr()})
+	helpers.FailOnError(t, err)
+	defer func() {
+		helpers.FailOnError(t, marks.Close())
+	}()
+
+	highWaterMark, err := marks.HighWaterMark(testTopic, 0)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, int64(42), highWaterMark)
+}
+
+func TestNewHighWaterMarksInvalidSecurity(t *testing.T) {
+	_, err := broker.NewHighWaterMarks(broker.Configuration{
+		Address:       "localhost:9092",
+		SASLMechanism: "unknown",
+	})
+	assert.Error(t, err)
+}
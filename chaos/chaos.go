@@ -0,0 +1,155 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package chaos contains fault injection layer used in staging deployments to
+// simulate degradation of the service. When enabled, random latency and random
+// errors are injected into storage calls and HTTP handlers, so clients of the
+// service can test their resilience.
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrInjectedFault is returned instead of the result of the call when fault
+// is injected
+var ErrInjectedFault = errors.New("fault injected by chaos mode")
+
+// Settings are parameters of fault injection that can be changed at runtime.
+// Every call is delayed by random latency up to MaxLatencyMs milliseconds
+// and ErrorPercentage of calls fail.
+type Settings struct {
+	Active          bool  `json:"active"`
+	MaxLatencyMs    int64 `json:"max_latency_ms"`
+	ErrorPercentage int   `json:"error_percentage"`
+}
+
+// Validate checks that the settings are in allowed ranges
+func (settings Settings) Validate() error {
+	if settings.MaxLatencyMs < 0 {
+		return fmt.Errorf("max latency can't be negative")
+	}
+
+	if settings.ErrorPercentage < 0 || settings.ErrorPercentage > 100 {
+		return fmt.Errorf("error percentage must be between 0 and 100")
+	}
+
+	return nil
+}
+
+// Injector injects faults according to its settings. Faults are injected only
+// into enabled injector, its settings can be changed at runtime.
+type Injector struct {
+	mutex    sync.Mutex
+	enabled  bool
+	settings Settings
+	random   *rand.Rand
+}
+
+// DefaultInjector is used by storage and HTTP server of the service
+var DefaultInjector = NewInjector()
+
+// NewInjector constructs disabled injector
+func NewInjector() *Injector {
+	return &Injector{
+		// #nosec G404
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Configure enables or disables the injector and sets its initial settings
+// according to the configuration
+func (injector *Injector) Configure(configuration Configuration) {
+	injector.mutex.Lock()
+	defer injector.mutex.Unlock()
+
+	injector.enabled = configuration.Enabled
+	injector.settings = Settings{
+		Active:          configuration.Enabled,
+		MaxLatencyMs:    configuration.MaxLatency.Milliseconds(),
+		ErrorPercentage: configuration.ErrorPercentage,
+	}
+}
+
+// Enabled returns true when the injector is enabled by configuration, faults
+// are injected only when its settings are active too
+func (injector *Injector) Enabled() bool {
+	injector.mutex.Lock()
+	defer injector.mutex.Unlock()
+
+	return injector.enabled
+}
+
+// Settings returns current settings of the injector
+func (injector *Injector) Settings() Settings {
+	injector.mutex.Lock()
+	defer injector.mutex.Unlock()
+
+	return injector.settings
+}
+
+// UpdateSettings replaces settings of the injector
+func (injector *Injector) UpdateSettings(settings Settings) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+
+	injector.mutex.Lock()
+	defer injector.mutex.Unlock()
+
+	injector.settings = settings
+
+	return nil
+}
+
+// Inject delays the call by random latency and returns ErrInjectedFault when
+// the call should fail. The delay is interrupted when the context is done.
+func (injector *Injector) Inject(ctx context.Context) error {
+	injector.mutex.Lock()
+	if !injector.enabled || !injector.settings.Active {
+		injector.mutex.Unlock()
+		return nil
+	}
+
+	var latency time.Duration
+	if injector.settings.MaxLatencyMs > 0 {
+		latency = time.Duration(injector.random.Int63n(injector.settings.MaxLatencyMs+1)) * time.Millisecond
+	}
+	fail := injector.random.Intn(100) < injector.settings.ErrorPercentage
+	injector.mutex.Unlock()
+
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if fail {
+		return ErrInjectedFault
+	}
+
+	return nil
+}
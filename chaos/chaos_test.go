@@ -0,0 +1,106 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaos_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/chaos"
+)
+
+func mustGetInjector(t *testing.T, settings chaos.Settings) *chaos.Injector {
+	injector := chaos.NewInjector()
+	injector.Configure(chaos.Configuration{Enabled: true})
+	helpers.FailOnError(t, injector.UpdateSettings(settings))
+
+	return injector
+}
+
+func TestInjector_Disabled(t *testing.T) {
+	injector := chaos.NewInjector()
+	helpers.FailOnError(t, injector.UpdateSettings(chaos.Settings{Active: true, ErrorPercentage: 100}))
+
+	assert.False(t, injector.Enabled())
+	helpers.FailOnError(t, injector.Inject(context.Background()))
+}
+
+func TestInjector_Configure(t *testing.T) {
+	injector := chaos.NewInjector()
+	injector.Configure(chaos.Configuration{
+		Enabled:         true,
+		MaxLatency:      2 * time.Second,
+		ErrorPercentage: 15,
+	})
+
+	assert.True(t, injector.Enabled())
+	assert.Equal(t, chaos.Settings{
+		Active:          true,
+		MaxLatencyMs:    2000,
+		ErrorPercentage: 15,
+	}, injector.Settings())
+}
+
+func TestInjector_Inactive(t *testing.T) {
+	injector := mustGetInjector(t, chaos.Settings{Active: false, ErrorPercentage: 100})
+
+	helpers.FailOnError(t, injector.Inject(context.Background()))
+}
+
+func TestInjector_AllCallsFail(t *testing.T) {
+	injector := mustGetInjector(t, chaos.Settings{Active: true, ErrorPercentage: 100})
+
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, chaos.ErrInjectedFault, injector.Inject(context.Background()))
+	}
+}
+
+func TestInjector_NoCallFails(t *testing.T) {
+	injector := mustGetInjector(t, chaos.Settings{Active: true, ErrorPercentage: 0})
+
+	for i := 0; i < 10; i++ {
+		helpers.FailOnError(t, injector.Inject(context.Background()))
+	}
+}
+
+func TestInjector_LatencyInterruptedByContext(t *testing.T) {
+	injector := mustGetInjector(t, chaos.Settings{Active: true, MaxLatencyMs: time.Hour.Milliseconds()})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.Equal(t, context.Canceled, injector.Inject(ctx))
+}
+
+func TestInjector_UpdateInvalidSettings(t *testing.T) {
+	injector := mustGetInjector(t, chaos.Settings{Active: true, ErrorPercentage: 10})
+
+	for _, settings := range []chaos.Settings{
+		{Active: true, MaxLatencyMs: -1},
+		{Active: true, ErrorPercentage: -1},
+		{Active: true, ErrorPercentage: 101},
+	} {
+		assert.Error(t, injector.UpdateSettings(settings))
+	}
+
+	// the original settings are kept
+	assert.Equal(t, chaos.Settings{Active: true, ErrorPercentage: 10}, injector.Settings())
+}
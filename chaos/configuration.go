@@ -0,0 +1,28 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaos
+
+import "time"
+
+// Configuration represents configuration of fault injection. The faults are
+// injected only when it's enabled, their parameters can be changed at
+// runtime via admin REST API endpoints.
+type Configuration struct {
+	Enabled         bool          `mapstructure:"enabled" toml:"enabled"`
+	MaxLatency      time.Duration `mapstructure:"max_latency" toml:"max_latency"`
+	ErrorPercentage int           `mapstructure:"error_percentage" toml:"error_percentage"`
+}
@@ -48,7 +48,13 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
 
+	"github.com/RedHatInsights/insights-results-aggregator/archive"
 	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/chaos"
+	"github.com/RedHatInsights/insights-results-aggregator/events"
+	"github.com/RedHatInsights/insights-results-aggregator/export"
+	"github.com/RedHatInsights/insights-results-aggregator/inventory"
+	"github.com/RedHatInsights/insights-results-aggregator/scheduler"
 	"github.com/RedHatInsights/insights-results-aggregator/server"
 	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
@@ -58,11 +64,27 @@ const (
 	configFileEnvVariableName   = "INSIGHTS_RESULTS_AGGREGATOR_CONFIG_FILE"
 	defaultOrgAllowlistFileName = "org_allowlist.csv"
 	defaultContentPath          = "/rules-content"
+
+	// sanitizedSecret replaces secrets in the configuration shown by the
+	// info endpoint
+	sanitizedSecret = "*****"
+
+	// debugEndpointsEnvVariableName enables debug endpoints in debug mode,
+	// it's intentionally not part of the configuration structure, so it
+	// can't be set in config file
+	debugEndpointsEnvVariableName = "INSIGHTS_RESULTS_AGGREGATOR_ENABLE_DEBUG_ENDPOINTS"
+
+	// databaseURLEnvVariableName contains PostgreSQL connection URL injected
+	// by the platform, it's used when storage.pg_url is not configured
+	databaseURLEnvVariableName = "DATABASE_URL"
 )
 
 // MetricsConfiguration holds metrics related configuration
 type MetricsConfiguration struct {
-	Namespace string `mapstructure:"namespace" toml:"namespace"`
+	Namespace           string `mapstructure:"namespace" toml:"namespace"`
+	OrgLabelMode        string `mapstructure:"org_label_mode" toml:"org_label_mode"`
+	OrgLabelTopN        int    `mapstructure:"org_label_top_n" toml:"org_label_top_n"`
+	OrgLabelHashBuckets int    `mapstructure:"org_label_hash_buckets" toml:"org_label_hash_buckets"`
 }
 
 // ConfigStruct is a structure holding the whole service configuration
@@ -78,14 +100,37 @@ type ConfigStruct struct {
 	Metrics           MetricsConfiguration              `mapstructure:"metrics" toml:"metrics"`
 	SentryLoggingConf logger.SentryLoggingConfiguration `mapstructure:"sentry" toml:"sentry"`
 	KafkaZerologConf  logger.KafkaZerologConfiguration  `mapstructure:"kafka_zerolog" toml:"kafka_zerolog"`
+	Export            export.Configuration              `mapstructure:"export" toml:"export"`
+	Scheduler         scheduler.Configuration           `mapstructure:"scheduler" toml:"scheduler"`
+	Events            events.Configuration              `mapstructure:"events" toml:"events"`
+	Inventory         inventory.Configuration           `mapstructure:"inventory" toml:"inventory"`
+	Chaos             chaos.Configuration               `mapstructure:"chaos" toml:"chaos"`
+	ShadowStorage     storage.ShadowReadConfiguration   `mapstructure:"shadow_storage" toml:"shadow_storage"`
+	RedisCache        storage.RedisCacheConfiguration   `mapstructure:"redis_cache" toml:"redis_cache"`
+	Archive           archive.Configuration             `mapstructure:"archive" toml:"archive"`
 }
 
 // Config has exactly the same structure as *.toml file
 var Config ConfigStruct
 
 // LoadConfiguration loads configuration from defaultConfigFile, file set in
-// configFileEnvVariableName or from env or from Clowder.
+// configFileEnvVariableName or from env or from Clowder. The profile is
+// selected by configProfileEnvVariableName env variable.
 func LoadConfiguration(defaultConfigFile string) error {
+	return LoadConfigurationWithProfile(defaultConfigFile, "")
+}
+
+// LoadConfigurationWithProfile loads configuration like LoadConfiguration and
+// merges the overlay config file of the profile (config.stage.toml for
+// config.toml and profile stage) into it. When the profile is empty, it's
+// taken from configProfileEnvVariableName env variable. The precedence from
+// the lowest is: base config file, overlay config file, env variables,
+// Clowder.
+func LoadConfigurationWithProfile(defaultConfigFile, profile string) error {
+	if profile == "" {
+		profile = os.Getenv(configProfileEnvVariableName)
+	}
+
 	configFile, specified := os.LookupEnv(configFileEnvVariableName)
 	if specified {
 		// we need to separate the directory name and filename without
@@ -124,6 +169,19 @@ func LoadConfiguration(defaultConfigFile string) error {
 		return fmt.Errorf("fatal error config file: %s", err)
 	}
 
+	if profile != "" {
+		baseConfigFile := viper.ConfigFileUsed()
+		if baseConfigFile == "" {
+			// the base config file doesn't exist, the overlay is
+			// expected in its place
+			baseConfigFile = defaultConfigFile + ".toml"
+		}
+
+		if err := mergeProfileConfig(baseConfigFile, profile); err != nil {
+			return err
+		}
+	}
+
 	// override config from env if there's variable in env
 
 	const envPrefix = "INSIGHTS_RESULTS_AGGREGATOR_"
@@ -142,10 +200,23 @@ func LoadConfiguration(defaultConfigFile string) error {
 		return err
 	}
 
+	updateConfigFromDatabaseURL(&Config)
+
+	applyDefaults(&Config)
+
+	Config.Server.DebugEndpointsEnabled = debugEndpointsEnabled()
+
 	// everything's should be ok
 	return nil
 }
 
+// debugEndpointsEnabled returns true when debug endpoints are explicitly
+// enabled by env variable
+func debugEndpointsEnabled() bool {
+	enabled, err := strconv.ParseBool(os.Getenv(debugEndpointsEnvVariableName))
+	return err == nil && enabled
+}
+
 // GetBrokerConfiguration returns broker configuration
 func GetBrokerConfiguration() broker.Configuration {
 	Config.Broker.OrgAllowlist = getOrganizationAllowlist()
@@ -180,6 +251,18 @@ func GetStorageConfiguration() storage.Configuration {
 	return Config.Storage
 }
 
+// GetShadowStorageConfiguration returns configuration of the candidate
+// storage used in shadow-read mode
+func GetShadowStorageConfiguration() storage.ShadowReadConfiguration {
+	return Config.ShadowStorage
+}
+
+// GetRedisCacheConfiguration returns configuration of Redis used to cache
+// reports
+func GetRedisCacheConfiguration() storage.RedisCacheConfiguration {
+	return Config.RedisCache
+}
+
 // GetLoggingConfiguration returns logging configuration
 func GetLoggingConfiguration() logger.LoggingConfiguration {
 	return Config.Logging
@@ -215,6 +298,70 @@ func GetMetricsConfiguration() MetricsConfiguration {
 	return Config.Metrics
 }
 
+// GetExportConfiguration returns Parquet export configuration
+func GetExportConfiguration() export.Configuration {
+	return Config.Export
+}
+
+// GetArchiveConfiguration returns configuration of the archive of reports
+func GetArchiveConfiguration() archive.Configuration {
+	return Config.Archive
+}
+
+// GetSchedulerConfiguration returns scheduler configuration
+func GetSchedulerConfiguration() scheduler.Configuration {
+	return Config.Scheduler
+}
+
+// GetEventsConfiguration returns configuration of event publishing
+func GetEventsConfiguration() events.Configuration {
+	return Config.Events
+}
+
+// GetInventoryConfiguration returns configuration of the inventory cluster
+// display names are resolved by
+func GetInventoryConfiguration() inventory.Configuration {
+	return Config.Inventory
+}
+
+// GetChaosConfiguration returns configuration of the chaos mode
+func GetChaosConfiguration() chaos.Configuration {
+	return Config.Chaos
+}
+
+// GetSanitizedConfiguration returns the effective configuration with all
+// secrets (passwords, keys and Sentry DSN) replaced
+func GetSanitizedConfiguration() ConfigStruct {
+	sanitized := Config
+
+	sanitizeSecret(&sanitized.Broker.SASLPassword)
+	sanitizeSecret(&sanitized.Storage.PGPassword)
+	sanitizeSecret(&sanitized.ShadowStorage.PGPassword)
+	sanitizeSecret(&sanitized.Storage.PGURL)
+	sanitizeSecret(&sanitized.ShadowStorage.PGURL)
+	sanitizeSecret(&sanitized.Storage.PGReadReplicaURL)
+	sanitizeSecret(&sanitized.ShadowStorage.PGReadReplicaURL)
+	sanitizeSecret(&sanitized.RedisCache.URL)
+	sanitizeSecret(&sanitized.CloudWatch.AWSSecretKey)
+	sanitizeSecret(&sanitized.CloudWatch.AWSSessionToken)
+	sanitizeSecret(&sanitized.Export.AWSSecretKey)
+	sanitizeSecret(&sanitized.Archive.AWSSecretKey)
+	sanitizeSecret(&sanitized.SentryLoggingConf.SentryDSN)
+
+	// the allow list can be huge, path to the file is part of the
+	// configuration anyway
+	sanitized.Broker.OrgAllowlist = nil
+
+	return sanitized
+}
+
+// sanitizeSecret replaces the secret when it is set
+func sanitizeSecret(secret *string) {
+	if *secret != "" {
+		*secret = sanitizedSecret
+	}
+}
+
 // checkIfFileExists returns nil if path doesn't exist or isn't a file,
 // otherwise it returns corresponding error
 func checkIfFileExists(path string) error {
@@ -262,6 +409,15 @@ func loadAllowlistFromCSV(r io.Reader) (mapset.Set, error) {
 	return allowlist, nil
 }
 
+// updateConfigFromDatabaseURL sets PostgreSQL connection URL of the storage
+// from DATABASE_URL env variable when the URL is not configured explicitly
+func updateConfigFromDatabaseURL(c *ConfigStruct) {
+	databaseURL, found := os.LookupEnv(databaseURLEnvVariableName)
+	if found && databaseURL != "" && c.Storage.PGURL == "" {
+		c.Storage.PGURL = databaseURL
+	}
+}
+
 // updateConfigFromClowder updates the current config with the values defined in clowder
 func updateConfigFromClowder(c *ConfigStruct) error {
 	if clowder.IsClowderEnabled() {
@@ -88,6 +88,7 @@ func TestLoadBrokerConfiguration(t *testing.T) {
 	assert.Equal(t, "localhost:29092", brokerCfg.Address)
 	assert.Equal(t, "platform.results.ccx", brokerCfg.Topic)
 	assert.Equal(t, "aggregator", brokerCfg.Group)
+	assert.Equal(t, 32, brokerCfg.MessageBufferSize)
 	assert.Equal(t, expectedTimeout, brokerCfg.Timeout)
 }
 
@@ -149,6 +150,78 @@ func TestLoadConfigurationOverrideFromEnv(t *testing.T) {
 	}, storageCfg)
 }
 
+// TestLoadConfigurationDatabaseURL tests setting storage connection URL by
+// DATABASE_URL env variable
+func TestLoadConfigurationDatabaseURL(t *testing.T) {
+	os.Clearenv()
+
+	const configPath = "../tests/config1"
+
+	mustSetEnv(t, "DATABASE_URL", "postgres://user:password@db:5432/aggregator")
+	defer func() {
+		conf.Config.Storage.PGURL = ""
+	}()
+
+	mustLoadConfiguration(configPath)
+
+	storageCfg := conf.GetStorageConfiguration()
+	assert.Equal(t, "postgres://user:password@db:5432/aggregator", storageCfg.PGURL)
+	// other options are kept, but they are not used
+	assert.Equal(t, "localhost", storageCfg.PGHost)
+}
+
+// TestLoadConfigurationWithProfile tests merging overlay config file of the
+// profile into the base config file
+func TestLoadConfigurationWithProfile(t *testing.T) {
+	os.Clearenv()
+
+	const configPath = "../tests/config1"
+
+	// the base configuration is restored for other tests
+	defer mustLoadConfiguration(configPath)
+
+	helpers.FailOnError(t, conf.LoadConfigurationWithProfile(configPath, "stage"))
+
+	storageCfg := conf.GetStorageConfiguration()
+	assert.Equal(t, "postgres", storageCfg.Driver)
+	assert.Equal(t, "stage-db", storageCfg.PGHost)
+	assert.Equal(t, "aggregator_stage", storageCfg.PGDBName)
+	// options not set in the overlay are taken from the base config file
+	assert.Equal(t, "user", storageCfg.PGUsername)
+	assert.Equal(t, 5432, storageCfg.PGPort)
+	assert.True(t, conf.GetServerConfiguration().Auth)
+
+	// env variables have higher priority than the overlay
+	mustSetEnv(t, "INSIGHTS_RESULTS_AGGREGATOR__STORAGE__PG_HOST", "other-db")
+	helpers.FailOnError(t, conf.LoadConfigurationWithProfile(configPath, "stage"))
+	assert.Equal(t, "other-db", conf.GetStorageConfiguration().PGHost)
+
+	// the profile can be selected by env variable too
+	os.Clearenv()
+	mustSetEnv(t, "INSIGHTS_RESULTS_AGGREGATOR_CONFIG_PROFILE", "stage")
+	mustLoadConfiguration(configPath)
+	assert.Equal(t, "stage-db", conf.GetStorageConfiguration().PGHost)
+	os.Clearenv()
+}
+
+// TestLoadConfigurationWithProfileErrors tests unknown and invalid profiles
+func TestLoadConfigurationWithProfileErrors(t *testing.T) {
+	os.Clearenv()
+
+	const configPath = "../tests/config1"
+
+	defer mustLoadConfiguration(configPath)
+
+	err := conf.LoadConfigurationWithProfile(configPath, "unknown")
+	assert.Error(t, err)
+	if err != nil {
+		assert.Contains(t, err.Error(), "unable to read config file of profile 'unknown'")
+	}
+
+	err = conf.LoadConfigurationWithProfile(configPath, "../stage")
+	assert.EqualError(t, err, "invalid configuration profile name '../stage'")
+}
+
 // TestLoadOrganizationAllowlist tests if the allowlist CSV file gets loaded properly
 func TestLoadOrganizationAllowlist(t *testing.T) {
 	expectedAllowlist := mapset.NewSetWith(
@@ -400,6 +473,77 @@ func TestGetMetricsConfiguration(t *testing.T) {
 	assert.Equal(t, "aggregator", metricsCfg.Namespace)
 }
 
+func TestGetExportConfiguration(t *testing.T) {
+	helpers.FailOnError(t, os.Chdir(".."))
+	TestLoadConfiguration(t)
+
+	exportCfg := conf.GetExportConfiguration()
+	assert.Equal(t, "/tmp/export", exportCfg.Path)
+	assert.Equal(t, 1000, exportCfg.RowGroupSize)
+	assert.Equal(t, "analytics", exportCfg.S3Bucket)
+	assert.Equal(t, "aggregator/", exportCfg.S3Prefix)
+}
+
+func TestGetEventsConfiguration(t *testing.T) {
+	helpers.FailOnError(t, os.Chdir(".."))
+	TestLoadConfiguration(t)
+
+	eventsCfg := conf.GetEventsConfiguration()
+	assert.Equal(t, []string{"http://localhost:9000/toggles", "http://localhost:9001/toggles"}, eventsCfg.WebhookURLs)
+	assert.Equal(t, 5*time.Second, eventsCfg.WebhookTimeout)
+}
+
+func TestGetInventoryConfiguration(t *testing.T) {
+	helpers.FailOnError(t, os.Chdir(".."))
+	TestLoadConfiguration(t)
+
+	inventoryCfg := conf.GetInventoryConfiguration()
+	assert.Equal(t, "http://localhost:9002/display_names", inventoryCfg.URL)
+	assert.Equal(t, 5*time.Second, inventoryCfg.Timeout)
+	assert.Equal(t, 30*time.Minute, inventoryCfg.CacheTTL)
+}
+
+// TestDebugEndpointsEnabledByEnv checks that debug endpoints can be enabled
+// only by the dedicated env variable
+func TestDebugEndpointsEnabledByEnv(t *testing.T) {
+	// GetServerConfiguration isn't used, the OpenAPI specification isn't
+	// in the working directory of the test
+	os.Clearenv()
+	mustLoadConfiguration("/non_existing_path")
+	assert.False(t, conf.Config.Server.DebugEndpointsEnabled)
+
+	// the flag is not part of the configuration structure
+	mustSetEnv(t, "INSIGHTS_RESULTS_AGGREGATOR__SERVER__DEBUG_ENDPOINTS_ENABLED", "true")
+	mustLoadConfiguration("/non_existing_path")
+	assert.False(t, conf.Config.Server.DebugEndpointsEnabled)
+
+	mustSetEnv(t, conf.DebugEndpointsEnvVariableName, "true")
+	mustLoadConfiguration("/non_existing_path")
+	assert.True(t, conf.Config.Server.DebugEndpointsEnabled)
+}
+
+func TestGetChaosConfiguration(t *testing.T) {
+	helpers.FailOnError(t, os.Chdir(".."))
+	TestLoadConfiguration(t)
+
+	chaosCfg := conf.GetChaosConfiguration()
+	assert.False(t, chaosCfg.Enabled)
+	assert.Equal(t, 250*time.Millisecond, chaosCfg.MaxLatency)
+	assert.Equal(t, 10, chaosCfg.ErrorPercentage)
+}
+
+func TestGetShadowStorageConfiguration(t *testing.T) {
+	helpers.FailOnError(t, os.Chdir(".."))
+	TestLoadConfiguration(t)
+
+	shadowCfg := conf.GetShadowStorageConfiguration()
+	assert.False(t, shadowCfg.Enabled)
+	assert.Equal(t, 50, shadowCfg.MaxPendingComparisons)
+	assert.Equal(t, "sqlite3", shadowCfg.Driver)
+	assert.Equal(t, ":memory:", shadowCfg.SQLiteDataSource)
+	assert.Equal(t, 2*time.Second, shadowCfg.ReadTimeout)
+}
+
 func setEnvVariables(t *testing.T) {
 	os.Clearenv()
 
@@ -0,0 +1,74 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conf
+
+import (
+	"reflect"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+)
+
+// Defaults contains default values of configuration options, they are used
+// for the options that are not set in the configuration file nor by env
+// variables. Options without sane default value (database connection, Kafka
+// topic etc.) are required and checked by ValidateConfiguration instead.
+var Defaults = ConfigStruct{
+	Broker: broker.Configuration{
+		Group: "aggregator",
+	},
+	Server: server.Configuration{
+		Address:                      ":8080",
+		APIPrefix:                    "/api/v1/",
+		APISpecFile:                  "openapi.json",
+		AuthType:                     "xrh",
+		MaximumFeedbackMessageLength: 255,
+		OrgOverviewLimitHours:        2,
+	},
+	Metrics: MetricsConfiguration{
+		OrgLabelMode: metrics.OrgLabelModeID,
+	},
+}
+
+func init() {
+	Defaults.Processing.OrgAllowlistFile = defaultOrgAllowlistFileName
+}
+
+// applyDefaults sets all options of the configuration that are not set (they
+// have zero value) to their default values
+func applyDefaults(config *ConfigStruct) {
+	applyDefaultValues(reflect.ValueOf(config).Elem(), reflect.ValueOf(Defaults))
+}
+
+// applyDefaultValues recursively copies non-zero fields of defaults into zero
+// fields of value
+func applyDefaultValues(value, defaults reflect.Value) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		defaultField := defaults.Field(i)
+
+		switch {
+		case !field.CanSet():
+			continue
+		case field.Kind() == reflect.Struct:
+			applyDefaultValues(field, defaultField)
+		case field.IsZero() && !defaultField.IsZero():
+			field.Set(defaultField)
+		}
+	}
+}
@@ -26,7 +26,10 @@ package conf
 // https://medium.com/@robiplus/golang-trick-export-for-test-aa16cbd7b8cd
 // to see why this trick is needed.
 var (
-	GetOrganizationAllowlist  = getOrganizationAllowlist
-	LoadAllowlistFromCSV      = loadAllowlistFromCSV
-	ConfigFileEnvVariableName = configFileEnvVariableName
+	GetOrganizationAllowlist      = getOrganizationAllowlist
+	LoadAllowlistFromCSV          = loadAllowlistFromCSV
+	ConfigFileEnvVariableName     = configFileEnvVariableName
+	DebugEndpointsEnvVariableName = debugEndpointsEnvVariableName
+	ValidateConfigurationStruct   = validateConfiguration
+	ApplyDefaults                 = applyDefaults
 )
@@ -0,0 +1,69 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// configProfileEnvVariableName selects the configuration profile when it's
+// not selected by --profile command line flag
+const configProfileEnvVariableName = "INSIGHTS_RESULTS_AGGREGATOR_CONFIG_PROFILE"
+
+// profileNameRegex matches allowed names of configuration profiles, the name
+// is part of the file name, so it can't contain path separators
+var profileNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// profileConfigFile returns the overlay config file of the profile, it's
+// placed next to the base config file: config.toml -> config.stage.toml
+func profileConfigFile(baseConfigFile, profile string) string {
+	ext := filepath.Ext(baseConfigFile)
+	return strings.TrimSuffix(baseConfigFile, ext) + "." + profile + ext
+}
+
+// mergeProfileConfig merges the overlay config file of the profile into the
+// configuration read from the base config file, options set in the overlay
+// replace options from the base file, other options are kept
+func mergeProfileConfig(baseConfigFile, profile string) error {
+	if !profileNameRegex.MatchString(profile) {
+		return fmt.Errorf("invalid configuration profile name '%s'", profile)
+	}
+
+	overlayFile := profileConfigFile(baseConfigFile, profile)
+
+	file, err := os.Open(filepath.Clean(overlayFile))
+	if err != nil {
+		return fmt.Errorf("unable to read config file of profile '%s': %v", profile, err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	viper.SetConfigType(strings.TrimPrefix(filepath.Ext(overlayFile), "."))
+
+	if err := viper.MergeConfig(file); err != nil {
+		return fmt.Errorf("fatal error config file %s: %s", overlayFile, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,324 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conf
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+)
+
+// ValidationError contains all problems found in the configuration
+type ValidationError struct {
+	Problems []string
+}
+
+// Error returns all problems of the configuration separated by semicolons
+func (err *ValidationError) Error() string {
+	return fmt.Sprintf("invalid configuration: %s", strings.Join(err.Problems, "; "))
+}
+
+// configValidator collects problems found in the configuration
+type configValidator struct {
+	problems []string
+}
+
+// addProblem records one problem of the configuration
+func (validator *configValidator) addProblem(format string, args ...interface{}) {
+	validator.problems = append(validator.problems, fmt.Sprintf(format, args...))
+}
+
+// required checks that the option is set
+func (validator *configValidator) required(option, value string) {
+	if value == "" {
+		validator.addProblem("%s is required", option)
+	}
+}
+
+// oneOf checks that the option has one of the allowed values
+func (validator *configValidator) oneOf(option, value string, allowed ...string) {
+	for _, allowedValue := range allowed {
+		if value == allowedValue {
+			return
+		}
+	}
+
+	validator.addProblem("%s must be one of %s, got '%s'", option, strings.Join(allowed, ", "), value)
+}
+
+// inRange checks that the option is in the closed interval
+func (validator *configValidator) inRange(option string, value, min, max int) {
+	if value < min || value > max {
+		validator.addProblem("%s must be between %d and %d, got %d", option, min, max, value)
+	}
+}
+
+// atLeast checks that the option is not lower than min
+func (validator *configValidator) atLeast(option string, value, min int) {
+	if value < min {
+		validator.addProblem("%s must be at least %d, got %d", option, min, value)
+	}
+}
+
+// notNegative checks that the duration option is not negative
+func (validator *configValidator) notNegative(option string, value time.Duration) {
+	if value < 0 {
+		validator.addProblem("%s must not be negative, got %v", option, value)
+	}
+}
+
+// ValidateConfiguration checks the loaded configuration and returns
+// ValidationError with all problems found, so the service can fail fast
+// before any of its parts is started
+func ValidateConfiguration() error {
+	return validateConfiguration(&Config)
+}
+
+// validateConfiguration checks all sections of the configuration
+func validateConfiguration(config *ConfigStruct) error {
+	validator := &configValidator{}
+
+	validateServerConfiguration(validator, config)
+	validateBrokerConfiguration(validator, config)
+	validateStorageConfiguration(validator, "storage", config.Storage)
+	if config.ShadowStorage.Enabled {
+		validateStorageConfiguration(validator, "shadow_storage", config.ShadowStorage.Configuration)
+		validator.atLeast("shadow_storage.max_pending_comparisons", config.ShadowStorage.MaxPendingComparisons, 0)
+	}
+	if config.RedisCache.Enabled {
+		validator.required("redis_cache.url", config.RedisCache.URL)
+		validator.notNegative("redis_cache.ttl", config.RedisCache.TTL)
+		validator.notNegative("redis_cache.timeout", config.RedisCache.Timeout)
+	}
+
+	_, err := metrics.NewOrgLabeler(
+		config.Metrics.OrgLabelMode, config.Metrics.OrgLabelTopN, config.Metrics.OrgLabelHashBuckets,
+	)
+	if err != nil {
+		validator.addProblem("metrics: %v", err)
+	}
+
+	for _, webhookURL := range config.Events.WebhookURLs {
+		parsedURL, err := url.Parse(webhookURL)
+		if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+			validator.addProblem("events.webhook_urls must contain only HTTP(S) URLs, got '%s'", webhookURL)
+		}
+	}
+	validator.notNegative("events.webhook_timeout", config.Events.WebhookTimeout)
+
+	if config.Inventory.URL != "" {
+		parsedURL, err := url.Parse(config.Inventory.URL)
+		if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+			validator.addProblem("inventory.url must be HTTP(S) URL, got '%s'", config.Inventory.URL)
+		}
+	}
+	validator.notNegative("inventory.timeout", config.Inventory.Timeout)
+	validator.notNegative("inventory.cache_ttl", config.Inventory.CacheTTL)
+
+	validator.inRange("chaos.error_percentage", config.Chaos.ErrorPercentage, 0, 100)
+	validator.notNegative("chaos.max_latency", config.Chaos.MaxLatency)
+
+	validator.atLeast("export.row_group_size", config.Export.RowGroupSize, 0)
+
+	if config.Archive.Enabled {
+		validator.notNegative("archive.report_age", config.Archive.ReportAge)
+		validator.atLeast("archive.batch_size", config.Archive.BatchSize, 0)
+		if config.Archive.S3Bucket == "" {
+			validator.required("archive.path", config.Archive.Path)
+		}
+	}
+
+	validator.notNegative("scheduler.report_retention", config.Scheduler.ReportRetention)
+	validator.notNegative("scheduler.consumer_error_retention", config.Scheduler.ConsumerErrorRetention)
+	validator.notNegative("scheduler.stale_cluster_threshold", config.Scheduler.StaleClusterThreshold)
+	validator.notNegative("scheduler.lease_duration", config.Scheduler.LeaseDuration)
+
+	if len(validator.problems) > 0 {
+		return &ValidationError{Problems: validator.problems}
+	}
+
+	return nil
+}
+
+// validateServerConfiguration checks the configuration of REST API server
+func validateServerConfiguration(validator *configValidator, config *ConfigStruct) {
+	serverCfg := config.Server
+
+	if serverCfg.UnixSocket == "" && !serverCfg.SocketActivation {
+		validator.required("server.address", serverCfg.Address)
+	}
+
+	if !strings.HasPrefix(serverCfg.APIPrefix, "/") {
+		validator.addProblem("server.api_prefix must start with '/', got '%s'", serverCfg.APIPrefix)
+	}
+
+	if err := checkIfFileExists(serverCfg.APISpecFile); err != nil {
+		validator.addProblem("server.api_spec_file: %v", err)
+	}
+
+	if serverCfg.Auth {
+		validator.oneOf("server.auth_type", serverCfg.AuthType, "xrh", "jwt")
+	}
+
+	validator.atLeast("server.maximum_feedback_message_length", serverCfg.MaximumFeedbackMessageLength, 1)
+	validator.notNegative("server.read_timeout", serverCfg.ReadTimeout)
+	validator.notNegative("server.write_timeout", serverCfg.WriteTimeout)
+	validator.notNegative("server.idle_timeout", serverCfg.IdleTimeout)
+	validator.notNegative("server.request_timeout", serverCfg.RequestTimeout)
+	validator.notNegative("server.bulk_request_timeout", serverCfg.BulkRequestTimeout)
+	validator.notNegative("server.export_timeout", serverCfg.ExportTimeout)
+
+	endpoints := make([]string, 0, len(serverCfg.EndpointTimeouts))
+	for endpoint := range serverCfg.EndpointTimeouts {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+
+	for _, endpoint := range endpoints {
+		validator.notNegative(
+			fmt.Sprintf("server.endpoint_timeouts[%s]", endpoint), serverCfg.EndpointTimeouts[endpoint],
+		)
+	}
+}
+
+// validateBrokerConfiguration checks the configuration of Kafka broker, it is
+// checked only when the broker is enabled
+func validateBrokerConfiguration(validator *configValidator, config *ConfigStruct) {
+	brokerCfg := config.Broker
+	if !brokerCfg.Enabled {
+		return
+	}
+
+	validator.required("broker.address", brokerCfg.Address)
+	validator.required("broker.topic", brokerCfg.Topic)
+	validator.required("broker.group", brokerCfg.Group)
+	validator.notNegative("broker.timeout", brokerCfg.Timeout)
+	validator.atLeast("broker.message_buffer_size", brokerCfg.MessageBufferSize, 0)
+
+	if brokerCfg.SASLMechanism != "" {
+		validator.oneOf(
+			"broker.sasl_mechanism", strings.ToUpper(brokerCfg.SASLMechanism),
+			"PLAIN", "SCRAM-SHA-256", "SCRAM-SHA-512",
+		)
+	}
+
+	if brokerCfg.RebalanceStrategy != "" {
+		validator.oneOf(
+			"broker.rebalance_strategy", strings.ToLower(brokerCfg.RebalanceStrategy), broker.RebalanceStrategies...,
+		)
+	}
+	validator.notNegative("broker.session_retry_backoff", brokerCfg.SessionRetryBackoff)
+	validator.atLeast("broker.producer_retries", brokerCfg.ProducerRetries, 0)
+	validator.notNegative("broker.producer_retry_backoff", brokerCfg.ProducerRetryBackoff)
+	validator.notNegative("broker.producer_max_retry_backoff", brokerCfg.ProducerMaxRetryBackoff)
+	validator.atLeast("broker.producer_circuit_breaker_threshold", brokerCfg.ProducerCircuitBreakerThreshold, 0)
+	validator.notNegative("broker.producer_circuit_breaker_cooldown", brokerCfg.ProducerCircuitBreakerCooldown)
+
+	if (brokerCfg.TLSClientCert == "") != (brokerCfg.TLSClientKey == "") {
+		validator.addProblem("broker.tls_client_cert and broker.tls_client_key must be set together")
+	}
+
+	if brokerCfg.OrgRateWindow != 0 {
+		validator.notNegative("broker.org_rate_window", brokerCfg.OrgRateWindow)
+		validator.atLeast("broker.org_rate_baseline_windows", brokerCfg.OrgRateBaselineWindows, 0)
+		validator.atLeast("broker.org_rate_min_messages", brokerCfg.OrgRateMinMessages, 0)
+		if brokerCfg.OrgRateAnomalyFactor < 0 {
+			validator.addProblem(
+				"broker.org_rate_anomaly_factor must not be negative, got %v", brokerCfg.OrgRateAnomalyFactor,
+			)
+		}
+	}
+
+	if brokerCfg.OrgAllowlistEnabled {
+		data, err := ioutil.ReadFile(config.Processing.OrgAllowlistFile)
+		if err == nil {
+			_, err = loadAllowlistFromCSV(bytes.NewBuffer(data))
+		}
+		if err != nil {
+			validator.addProblem("processing.org_allowlist_file: %v", err)
+		}
+	}
+}
+
+// validateStorageConfiguration checks the configuration of the storage in
+// the given section
+func validateStorageConfiguration(validator *configValidator, section string, storageCfg storage.Configuration) {
+	// specific options of drivers registered by storage.RegisterDriver are
+	// checked by their factories
+	validator.oneOf(section+".db_driver", storageCfg.Driver, storage.Drivers()...)
+
+	switch storageCfg.Driver {
+	case "sqlite3":
+		validator.required(section+".sqlite_datasource", storageCfg.SQLiteDataSource)
+	case "postgres":
+		if storageCfg.PGURL != "" {
+			// the URL replaces other connection options
+			if err := storage.ValidatePGURL(storageCfg.PGURL); err != nil {
+				validator.addProblem("%s.pg_url: %v", section, err)
+				break
+			}
+		} else {
+			validator.required(section+".pg_host", storageCfg.PGHost)
+			validator.required(section+".pg_db_name", storageCfg.PGDBName)
+			validator.inRange(section+".pg_port", storageCfg.PGPort, 0, 65535)
+		}
+		if storageCfg.PGReadReplicaURL != "" {
+			if err := storage.ValidatePGURL(storageCfg.PGReadReplicaURL); err != nil {
+				validator.addProblem("%s.pg_read_replica_url: %v", section, err)
+				break
+			}
+		}
+		if storageCfg.PGIAMAuth {
+			if err := storage.ValidatePGIAMAuth(storageCfg); err != nil {
+				validator.addProblem("%s.pg_iam_auth: %v", section, err)
+			}
+		}
+		if storageCfg.PGSSLMode != "" {
+			validator.oneOf(section+".pg_sslmode", storageCfg.PGSSLMode, storage.PGSSLModes...)
+		}
+		if err := storage.ValidatePGTLS(storageCfg); err != nil {
+			validator.addProblem("%s.%v", section, err)
+		}
+	}
+
+	validator.atLeast(section+".max_open_connections", storageCfg.MaxOpenConnections, 0)
+	validator.atLeast(section+".max_idle_connections", storageCfg.MaxIdleConnections, 0)
+	validator.notNegative(section+".conn_max_lifetime", storageCfg.ConnMaxLifetime)
+	validator.notNegative(section+".conn_max_idle_time", storageCfg.ConnMaxIdleTime)
+	validator.notNegative(section+".read_timeout", storageCfg.ReadTimeout)
+	validator.notNegative(section+".write_timeout", storageCfg.WriteTimeout)
+	validator.notNegative(section+".aggregation_timeout", storageCfg.AggregationTimeout)
+	validator.atLeast(section+".check_history_size", storageCfg.CheckHistorySize, 0)
+	validator.atLeast(section+".report_history_size", storageCfg.ReportHistorySize, 0)
+	validator.atLeast(section+".report_cache_size", storageCfg.ReportCacheSize, 0)
+	validator.atLeast(section+".consumer_error_max_message_size", storageCfg.ConsumerErrorMaxMessageSize, 0)
+	if storageCfg.ClusterOrgConflictPolicy != "" {
+		validator.oneOf(
+			section+".cluster_org_conflict_policy",
+			storageCfg.ClusterOrgConflictPolicy,
+			storage.ClusterOrgConflictPolicies...,
+		)
+	}
+}
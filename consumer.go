@@ -34,14 +34,21 @@ func startConsumer(brokerConf broker.Configuration) error {
 		finishConsumerInstanceInitialization()
 	}()
 
-	dbStorage, err := createStorage()
+	serviceStorage, err := createServiceStorage()
 	if err != nil {
 		return err
 	}
 
-	defer closeStorage(dbStorage)
+	defer closeStorage(serviceStorage)
 
-	consumerInstance, err = consumer.New(brokerConf, dbStorage)
+	// written reports have to be removed from the cache used by the server
+	consumerStorage, closeCachedStorage, err := createCachedStorage(serviceStorage)
+	if err != nil {
+		return err
+	}
+	defer closeCachedStorage()
+
+	consumerInstance, err = consumer.New(brokerConf, consumerStorage)
 	if err != nil {
 		log.Error().Err(err).Msg("Broker initialization error")
 		return err
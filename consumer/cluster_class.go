@@ -0,0 +1,69 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer
+
+import (
+	"github.com/Shopify/sarama"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// key for cluster class used in structured log messages
+const clusterClassKey = "cluster_class"
+
+// messageMetadata contains metadata of the cluster sent together with its
+// report
+type messageMetadata struct {
+	// Managed is true for clusters managed by Red Hat SRE (OSD, ROSA)
+	Managed bool `json:"managed"`
+}
+
+// clusterClass returns the class of the cluster taken from metadata of the
+// message, clusters without metadata are self-managed
+func clusterClass(message incomingMessage) types.ClusterClass {
+	if message.Metadata != nil && message.Metadata.Managed {
+		return types.ClusterClassManaged
+	}
+
+	return types.ClusterClassSelfManaged
+}
+
+// writeClusterClass stores the class of the cluster from metadata of the
+// message and updates metrics segregated by the class. The class is stored
+// only when the message contains metadata, so the class set by older
+// messages is not overwritten. The report has already been stored, so any
+// error is only logged.
+func writeClusterClass(consumer *KafkaConsumer, msg *sarama.ConsumerMessage, message incomingMessage) {
+	class := clusterClass(message)
+
+	metrics.ReportsByClusterClass.WithLabelValues(string(class)).Inc()
+	metrics.RuleHitsByClusterClass.WithLabelValues(string(class)).Add(float64(len(message.ParsedHits)))
+
+	if message.Metadata == nil {
+		return
+	}
+
+	err := consumer.Storage.WriteClusterClass(*message.Organization, *message.ClusterName, class)
+	if err != nil {
+		log.Warn().
+			Int(offsetKey, int(msg.Offset)).
+			Str(topicKey, consumer.Configuration.Topic).
+			Str(clusterClassKey, string(class)).
+			Err(err).
+			Msg("Unable to store cluster class")
+	}
+}
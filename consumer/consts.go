@@ -15,6 +15,8 @@
 package consumer
 
 import (
+	"time"
+
 	"github.com/RedHatInsights/insights-results-aggregator/types"
 )
 
@@ -35,6 +37,24 @@ const (
 	durationKey = "duration"
 	// key for data schema version message type used in structured log messages
 	versionKey = "version"
+	// key for Kafka message key used in structured log messages
+	messageKeyKey = "message_key"
+	// DefaultMessageBufferSize is the capacity of sarama's channels of
+	// fetched messages used when it is not configured
+	DefaultMessageBufferSize = 64
+	// DefaultSessionRetryBackoff is the time to wait before the failed
+	// consumer group session is recreated used when it is not configured
+	DefaultSessionRetryBackoff = 5 * time.Second
+	// DefaultOrgRateBaselineWindows is the number of windows the baseline
+	// message rate of organization is computed from when it is not configured
+	DefaultOrgRateBaselineWindows = 12
+	// DefaultOrgRateAnomalyFactor is the multiple of the baseline message
+	// rate considered anomalous when it is not configured
+	DefaultOrgRateAnomalyFactor = 10
+	// maxDecompressedMessageSize is the maximal size of compressed message
+	// value after decompression, it protects the consumer from decompression
+	// bombs
+	maxDecompressedMessageSize = 64 * 1024 * 1024
 	// CurrentSchemaVersion represents the currently supported data schema version
 	CurrentSchemaVersion = types.SchemaVersion(1)
 )
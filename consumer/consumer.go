@@ -20,11 +20,14 @@ package consumer
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	"github.com/Shopify/sarama"
 	"github.com/rs/zerolog/log"
 
 	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
 	"github.com/RedHatInsights/insights-results-aggregator/producer"
 	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
@@ -58,8 +61,10 @@ type KafkaConsumer struct {
 	numberOfSuccessfullyConsumedMessages uint64
 	numberOfErrorsConsumingMessages      uint64
 	ready                                chan bool
+	readyOnce                            sync.Once
 	cancel                               context.CancelFunc
 	payloadTrackerProducer               *producer.KafkaProducer
+	orgRates                             *orgRateTracker
 }
 
 // DefaultSaramaConfig is a config which will be used by default
@@ -87,6 +92,20 @@ func NewWithSaramaConfig(
 			saramaConfig.Net.ReadTimeout = brokerCfg.Timeout
 			saramaConfig.Net.WriteTimeout = brokerCfg.Timeout
 		}
+
+		// messages fetched from Kafka wait for processing in sarama's
+		// channels, fetching stops when they are full
+		saramaConfig.ChannelBufferSize = messageBufferSize(brokerCfg)
+
+		if err := broker.ApplySecurityConfiguration(saramaConfig, brokerCfg); err != nil {
+			log.Error().Err(err).Msg("unable to set up Kafka security options")
+			return nil, err
+		}
+
+		if err := broker.ApplyConsumerGroupConfiguration(saramaConfig, brokerCfg); err != nil {
+			log.Error().Err(err).Msg("unable to set up Kafka consumer group options")
+			return nil, err
+		}
 	}
 
 	consumerGroup, err := sarama.NewConsumerGroup([]string{brokerCfg.Address}, brokerCfg.Group, saramaConfig)
@@ -94,10 +113,16 @@ func NewWithSaramaConfig(
 		return nil, err
 	}
 
-	payloadTrackerProducer, err := producer.New(brokerCfg)
-	if err != nil {
-		log.Error().Err(err).Msg("unable to construct producer")
-		return nil, err
+	// payload tracking is optional, it's enabled by configuring its topic
+	var payloadTrackerProducer *producer.KafkaProducer
+	if brokerCfg.PayloadTrackerTopic != "" {
+		payloadTrackerProducer, err = producer.New(brokerCfg)
+		if err != nil {
+			log.Error().Err(err).Msg("unable to construct producer")
+			return nil, err
+		}
+	} else {
+		log.Info().Msg("Payload tracker topic is not configured, payloads won't be tracked")
 	}
 
 	consumer := &KafkaConsumer{
@@ -108,6 +133,7 @@ func NewWithSaramaConfig(
 		numberOfErrorsConsumingMessages:      0,
 		ready:                                make(chan bool),
 		payloadTrackerProducer:               payloadTrackerProducer,
+		orgRates:                             newOrgRateTracker(brokerCfg, time.Now),
 	}
 
 	return consumer, nil
@@ -123,18 +149,29 @@ func (consumer *KafkaConsumer) Serve() {
 			// `Consume` should be called inside an infinite loop, when a
 			// server-side rebalance happens, the consumer session will need to be
 			// recreated to get the new claims
-			if err := consumer.ConsumerGroup.Consume(ctx, []string{consumer.Configuration.Topic}, consumer); err != nil {
-				log.Fatal().Err(err).Msg("unable to recreate kafka session")
-			}
+			err := consumer.ConsumerGroup.Consume(ctx, consumer.topics(), consumer)
 
 			// check if context was cancelled, signaling that the consumer should stop
 			if ctx.Err() != nil {
 				return
 			}
 
-			log.Info().Msg("created new kafka session")
+			if err != nil {
+				// the broker can be temporarily unavailable, so joining the
+				// group is retried instead of stopping the service
+				metrics.ConsumerGroupErrors.Inc()
+				backoff := sessionRetryBackoff(consumer.Configuration)
+				log.Error().Err(err).Dur("backoff", backoff).Msg("kafka session failed, it will be recreated")
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(backoff):
+				}
+				continue
+			}
 
-			consumer.ready = make(chan bool)
+			log.Info().Msg("created new kafka session")
 		}
 	}()
 
@@ -151,33 +188,98 @@ func (consumer *KafkaConsumer) Serve() {
 	cancel()
 }
 
-// Setup is run at the beginning of a new session, before ConsumeClaim
-func (consumer *KafkaConsumer) Setup(sarama.ConsumerGroupSession) error {
-	log.Info().Msg("new session has been setup")
-	// Mark the consumer as ready
-	close(consumer.ready)
+// topics returns all topics the consumer consumes messages from, the topic
+// with external results is consumed only when it is configured
+func (consumer *KafkaConsumer) topics() []string {
+	topics := []string{consumer.Configuration.Topic}
+	if consumer.Configuration.ExternalResultsTopic != "" {
+		topics = append(topics, consumer.Configuration.ExternalResultsTopic)
+	}
+
+	return topics
+}
+
+// Setup is run at the beginning of a new session, before ConsumeClaim. New
+// session is started after every rebalance of the consumer group.
+func (consumer *KafkaConsumer) Setup(session sarama.ConsumerGroupSession) error {
+	metrics.ConsumerGroupRebalances.Inc()
+	setClaimedPartitions(session.Claims())
+
+	log.Info().
+		Int32("generation", session.GenerationID()).
+		Str("member", session.MemberID()).
+		Interface("claims", session.Claims()).
+		Msg("new session has been setup")
+
+	// Mark the consumer as ready, Serve waits only for the first session
+	consumer.readyOnce.Do(func() {
+		close(consumer.ready)
+	})
 	return nil
 }
 
 // Cleanup is run at the end of a session, once all ConsumeClaim goroutines have exited
-func (consumer *KafkaConsumer) Cleanup(sarama.ConsumerGroupSession) error {
-	log.Info().Msg("new session has been finished")
+func (consumer *KafkaConsumer) Cleanup(session sarama.ConsumerGroupSession) error {
+	setClaimedPartitions(nil)
+
+	log.Info().
+		Int32("generation", session.GenerationID()).
+		Msg("session has been finished")
 	return nil
 }
 
+// setClaimedPartitions exports number of partitions claimed in the current
+// session by topic
+func setClaimedPartitions(claims map[string][]int32) {
+	metrics.ConsumerClaimedPartitions.Reset()
+	for topic, partitions := range claims {
+		metrics.ConsumerClaimedPartitions.WithLabelValues(topic).Set(float64(len(partitions)))
+	}
+}
+
 // ConsumeClaim starts a consumer loop of ConsumerGroupClaim's Messages().
 func (consumer *KafkaConsumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
 	log.Info().
 		Int64(offsetKey, claim.InitialOffset()).
 		Msg("starting messages loop")
 
-	latestMessageOffset, err := consumer.Storage.GetLatestKafkaOffset()
+	latestMessageOffset, err := consumer.Storage.GetLatestKafkaOffset(claim.Topic(), claim.Partition())
 	if err != nil {
 		log.Error().Msg("unable to get latest offset")
 		latestMessageOffset = 0
 	}
 
-	for message := range claim.Messages() {
+	messages := claim.Messages()
+
+	buffered := 0
+	defer func() {
+		metrics.ConsumerBufferedMessages.Sub(float64(buffered))
+	}()
+
+	for {
+		buffered = recordBufferedMessages(messages, buffered)
+
+		var message *sarama.ConsumerMessage
+		select {
+		case received, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			message = received
+		case <-session.Context().Done():
+		}
+
+		// the session is cancelled on rebalance, the partition can be claimed
+		// by another consumer, so messages which were not marked yet are
+		// left for it
+		if session.Context().Err() != nil {
+			log.Info().
+				Str(topicKey, claim.Topic()).
+				Int32(partitionKey, claim.Partition()).
+				Msg("session has been cancelled, stopping messages loop")
+			return nil
+		}
+
 		if types.KafkaOffset(message.Offset) <= latestMessageOffset {
 			log.Warn().
 				Int64(offsetKey, message.Offset).
@@ -190,9 +292,54 @@ func (consumer *KafkaConsumer) ConsumeClaim(session sarama.ConsumerGroupSession,
 		if types.KafkaOffset(message.Offset) > latestMessageOffset {
 			latestMessageOffset = types.KafkaOffset(message.Offset)
 		}
+
+		err = consumer.Storage.WriteKafkaOffset(message.Topic, message.Partition, types.KafkaOffset(message.Offset))
+		if err != nil {
+			log.Error().Err(err).Int64(offsetKey, message.Offset).Msg("unable to store offset of processed message")
+		}
 	}
+}
 
-	return nil
+// recordBufferedMessages exports the number of messages waiting for
+// processing in the channel of the claim. The previous number exported for
+// the claim is replaced, so the metric is the sum over all claims. When the
+// channel is full, sarama stops fetching messages from the partition until
+// the buffered ones are processed. Only the transition from not full to full
+// buffer is counted, sarama refills the buffer after every processed message
+// while the processing is the bottleneck.
+func recordBufferedMessages(messages <-chan *sarama.ConsumerMessage, previous int) int {
+	buffered := len(messages)
+	metrics.ConsumerBufferedMessages.Add(float64(buffered - previous))
+
+	wasFull := previous > 0 && previous == cap(messages)
+	if buffered > 0 && buffered == cap(messages) && !wasFull {
+		metrics.ConsumerBufferFull.Inc()
+		log.Debug().
+			Int("capacity", cap(messages)).
+			Msg("consumer buffer is full, fetching of messages waits for their processing")
+	}
+
+	return buffered
+}
+
+// sessionRetryBackoff returns the configured time to wait before the failed
+// consumer group session is recreated or the default one
+func sessionRetryBackoff(brokerCfg broker.Configuration) time.Duration {
+	if brokerCfg.SessionRetryBackoff > 0 {
+		return brokerCfg.SessionRetryBackoff
+	}
+
+	return DefaultSessionRetryBackoff
+}
+
+// messageBufferSize returns the configured capacity of sarama's channels of
+// fetched messages or the default one
+func messageBufferSize(brokerCfg broker.Configuration) int {
+	if brokerCfg.MessageBufferSize > 0 {
+		return brokerCfg.MessageBufferSize
+	}
+
+	return DefaultMessageBufferSize
 }
 
 // Close method closes all resources used by consumer
@@ -18,10 +18,13 @@ package consumer_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -29,13 +32,18 @@ import (
 	"github.com/RedHatInsights/insights-operator-utils/tests/saramahelpers"
 	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
 	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
 	mapset "github.com/deckarep/golang-set"
+	"github.com/prometheus/client_golang/prometheus"
+	prommodels "github.com/prometheus/client_model/go"
 	"github.com/rs/zerolog"
 	zerolog_log "github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/RedHatInsights/insights-results-aggregator/broker"
 	"github.com/RedHatInsights/insights-results-aggregator/consumer"
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/producer"
 	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
@@ -92,6 +100,30 @@ func TestConsumerConstructorNoKafka(t *testing.T) {
 	)
 }
 
+func TestConsumerConstructorInvalidSecurityConfiguration(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, false)
+	defer closer()
+
+	brokerCfg := wrongBrokerCfg
+	brokerCfg.SASLMechanism = "GSSAPI"
+
+	mockConsumer, err := consumer.New(brokerCfg, mockStorage)
+	assert.EqualError(t, err, "unsupported SASL mechanism GSSAPI")
+	assert.Nil(t, mockConsumer)
+}
+
+func TestConsumerConstructorInvalidRebalanceStrategy(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, false)
+	defer closer()
+
+	brokerCfg := wrongBrokerCfg
+	brokerCfg.RebalanceStrategy = "random"
+
+	mockConsumer, err := consumer.New(brokerCfg, mockStorage)
+	assert.EqualError(t, err, "unsupported rebalance strategy random")
+	assert.Nil(t, mockConsumer)
+}
+
 func TestParseEmptyMessage(t *testing.T) {
 	_, err := consumer.ParseMessage([]byte(""))
 	assert.EqualError(t, err, "unexpected end of JSON input")
@@ -163,6 +195,27 @@ func TestParseProperMessageWrongClusterName(t *testing.T) {
 	assert.EqualError(t, err, "cluster name is not a UUID")
 }
 
+func TestNormalizeClusterName(t *testing.T) {
+	const expected = types.ClusterName("84f7eedc-0dd8-49cd-9d4d-f6646df3a5bc")
+
+	for _, clusterName := range []types.ClusterName{
+		"84f7eedc-0dd8-49cd-9d4d-f6646df3a5bc",
+		"84F7EEDC-0DD8-49CD-9D4D-F6646DF3A5BC",
+		"84f7eedc0dd849cd9d4df6646df3a5bc",
+		"{84f7eedc-0dd8-49cd-9d4d-f6646df3a5bc}",
+		"urn:uuid:84f7eedc-0dd8-49cd-9d4d-f6646df3a5bc",
+	} {
+		normalized, err := consumer.NormalizeClusterName(clusterName)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, expected, normalized)
+	}
+}
+
+func TestNormalizeClusterNameNotUUID(t *testing.T) {
+	_, err := consumer.NormalizeClusterName("this is not a UUID")
+	assert.EqualError(t, err, "cluster name is not a UUID")
+}
+
 func TestParseMessageWithoutOrgID(t *testing.T) {
 	message := `{
 		"ClusterName": "` + string(testdata.ClusterName) + `",
@@ -212,6 +265,53 @@ func TestParseMessageNullReport(t *testing.T) {
 	assert.EqualError(t, err, "missing required attribute 'Report'")
 }
 
+func TestParseMessageFailedAnalysisWithoutReport(t *testing.T) {
+	message := `{
+		"OrgID": ` + fmt.Sprint(testdata.OrgID) + `,
+		"ClusterName": "` + string(testdata.ClusterName) + `",
+		"Status": "failed"
+	}`
+
+	parsed, err := consumer.ParseMessage([]byte(message))
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.ReportStatusFailed, parsed.Status)
+}
+
+func TestParseMessageUnknownStatus(t *testing.T) {
+	message := `{
+		"OrgID": ` + fmt.Sprint(testdata.OrgID) + `,
+		"ClusterName": "` + string(testdata.ClusterName) + `",
+		"Report": ` + testdata.ConsumerReport + `,
+		"Status": "unknown"
+	}`
+
+	_, err := consumer.ParseMessage([]byte(message))
+	assert.EqualError(t, err, "unknown value of attribute 'Status': unknown")
+}
+
+func TestParseMessageRecommendationDeletion(t *testing.T) {
+	message := `{
+		"OrgID": ` + fmt.Sprint(testdata.OrgID) + `,
+		"ClusterName": "` + string(testdata.ClusterName) + `",
+		"Type": "recommendation_deletion"
+	}`
+
+	_, err := consumer.ParseMessage([]byte(message))
+	helpers.FailOnError(t, err)
+}
+
+func TestParseMessageUnknownType(t *testing.T) {
+	message := `{
+		"OrgID": ` + fmt.Sprint(testdata.OrgID) + `,
+		"ClusterName": "` + string(testdata.ClusterName) + `",
+		"Report": ` + testdata.ConsumerReport + `,
+		"Type": "unknown"
+	}`
+
+	_, err := consumer.ParseMessage([]byte(message))
+	assert.EqualError(t, err, "unknown value of attribute 'Type': unknown")
+}
+
 func dummyConsumer(s storage.Storage, allowlist bool) consumer.Consumer {
 	brokerCfg := broker.Configuration{
 		Address: "localhost:1234",
@@ -520,6 +620,112 @@ func TestKafkaConsumer_ProcessMessage_MoreRecentReportAlreadyExists(t *testing.T
 	helpers.FailOnError(t, err)
 
 	assert.Contains(t, buf.String(), "Skipping because a more recent report already exists for this cluster")
+
+	staleWrites, err := mockStorage.ReadStaleReportWrites()
+	helpers.FailOnError(t, err)
+	if assert.Len(t, staleWrites, 1) {
+		assert.Equal(t, testdata.OrgID, staleWrites[0].OrgID)
+		assert.Equal(t, testdata.ClusterName, staleWrites[0].ClusterName)
+		assert.Equal(t, 1, staleWrites[0].RejectedCount)
+	}
+}
+
+func TestKafkaConsumer_ProcessMessage_ClusterOrgConflict(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	buf := new(bytes.Buffer)
+	zerolog_log.Logger = zerolog.New(buf)
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mockStorage.(*storage.DBStorage).SetClusterOrgConflictPolicy(storage.ClusterOrgConflictReject)
+
+	mockConsumer := &consumer.KafkaConsumer{
+		Configuration: wrongBrokerCfg,
+		Storage:       mockStorage,
+	}
+
+	for _, orgID := range []types.OrgID{testdata.OrgID, testdata.Org2ID} {
+		message := `{
+			"OrgID": ` + fmt.Sprint(orgID) + `,
+			"ClusterName": "` + string(testdata.ClusterName) + `",
+			"Report":` + testdata.ConsumerReport + `,
+			"LastChecked": "` + time.Now().Format(time.RFC3339Nano) + `"
+		}`
+
+		err := consumerProcessMessage(mockConsumer, message)
+		helpers.FailOnError(t, err)
+	}
+
+	assert.Contains(t, buf.String(), "Skipping because the cluster is stored under another organization")
+
+	_, _, err := mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+}
+
+func TestKafkaConsumer_ProcessMessage_HistoricalReports(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	buf := new(bytes.Buffer)
+	zerolog_log.Logger = zerolog.New(buf)
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	brokerCfg := wrongBrokerCfg
+	brokerCfg.HistoricalReports = true
+
+	mockConsumer := &consumer.KafkaConsumer{
+		Configuration: brokerCfg,
+		Storage:       mockStorage,
+	}
+
+	lastChecked := time.Now().Add(-time.Hour).UTC()
+
+	for _, checkedAt := range []time.Time{lastChecked, lastChecked.Add(-24 * time.Hour)} {
+		message := `{
+			"OrgID": ` + fmt.Sprint(testdata.OrgID) + `,
+			"ClusterName": "` + string(testdata.ClusterName) + `",
+			"Report":` + testdata.ConsumerReport + `,
+			"LastChecked": "` + checkedAt.Format(time.RFC3339) + `"
+		}`
+
+		err := consumerProcessMessage(mockConsumer, message)
+		helpers.FailOnError(t, err)
+	}
+
+	assert.Contains(t, buf.String(), "Stored into history only")
+
+	// the older report is not considered stale nor it replaces the latest one
+	staleWrites, err := mockStorage.ReadStaleReportWrites()
+	helpers.FailOnError(t, err)
+	assert.Empty(t, staleWrites)
+
+	_, lastCheckedAt, err := mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.Timestamp(lastChecked.Format(time.RFC3339)), lastCheckedAt)
+}
+
+func TestKafkaConsumer_ProcessMessage_HistoricalReportsError(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorageWithFaults(t, true)
+	defer closer()
+
+	mockStorage.InjectFault("WriteReportHistory", ira_helpers.Fault{Err: errors.New("history error")})
+
+	brokerCfg := wrongBrokerCfg
+	brokerCfg.HistoricalReports = true
+
+	mockConsumer := &consumer.KafkaConsumer{
+		Configuration: brokerCfg,
+		Storage:       mockStorage,
+	}
+
+	err := consumerProcessMessage(mockConsumer, testdata.ConsumerMessage)
+	assert.EqualError(t, err, "history error")
+
+	// the latest report is not written when the history can't be stored
+	exists, err := mockStorage.DoesClusterExist(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.False(t, exists)
 }
 
 func TestKafkaConsumer_ProcessMessage_MessageWithUnexpectedSchemaVersion(t *testing.T) {
@@ -566,6 +772,195 @@ func TestKafkaConsumer_ProcessMessage_MessageWithExpectedSchemaVersion(t *testin
 	assert.NotContains(t, buf.String(), "Received data with unexpected version")
 }
 
+func TestKafkaConsumer_ProcessMessage_NormalizeClusterNames(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	brokerCfg := wrongBrokerCfg
+	brokerCfg.NormalizeClusterNames = true
+
+	mockConsumer := &consumer.KafkaConsumer{
+		Configuration: brokerCfg,
+		Storage:       mockStorage,
+	}
+
+	message := `{
+		"OrgID": ` + fmt.Sprint(testdata.OrgID) + `,
+		"ClusterName": "` + strings.ToUpper(string(testdata.ClusterName)) + `",
+		"Report":` + testdata.ConsumerReport + `,
+		"LastChecked": "` + time.Now().Format(time.RFC3339) + `"
+	}`
+
+	err := consumerProcessMessage(mockConsumer, message)
+	helpers.FailOnError(t, err)
+
+	exists, err := mockStorage.DoesClusterExist(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.True(t, exists)
+
+	exists, err = mockStorage.DoesClusterExist(
+		types.ClusterName(strings.ToUpper(string(testdata.ClusterName))),
+	)
+	helpers.FailOnError(t, err)
+	assert.False(t, exists)
+}
+
+func TestKafkaConsumer_ProcessMessage_NormalizeClusterNamesDisabled(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mockConsumer := &consumer.KafkaConsumer{
+		Configuration: wrongBrokerCfg,
+		Storage:       mockStorage,
+	}
+
+	upperCaseClusterName := types.ClusterName(strings.ToUpper(string(testdata.ClusterName)))
+	message := `{
+		"OrgID": ` + fmt.Sprint(testdata.OrgID) + `,
+		"ClusterName": "` + string(upperCaseClusterName) + `",
+		"Report":` + testdata.ConsumerReport + `,
+		"LastChecked": "` + time.Now().Format(time.RFC3339) + `"
+	}`
+
+	err := consumerProcessMessage(mockConsumer, message)
+	helpers.FailOnError(t, err)
+
+	exists, err := mockStorage.DoesClusterExist(upperCaseClusterName)
+	helpers.FailOnError(t, err)
+	assert.True(t, exists)
+}
+
+func TestKafkaConsumer_ProcessMessage_OrgUsage(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mockConsumer := &consumer.KafkaConsumer{
+		Configuration: wrongBrokerCfg,
+		Storage:       mockStorage,
+	}
+
+	err := consumerProcessMessage(mockConsumer, testdata.ConsumerMessage)
+	helpers.FailOnError(t, err)
+
+	// the report isn't newer than the stored one, the message is processed
+	// but nothing is stored
+	err = consumerProcessMessage(mockConsumer, testdata.ConsumerMessage)
+	helpers.FailOnError(t, err)
+
+	usages, err := mockStorage.ReadOrgUsage(storage.UsageMonth(time.Now()))
+	helpers.FailOnError(t, err)
+
+	if assert.Len(t, usages, 1) {
+		assert.Equal(t, testdata.OrgID, usages[0].OrgID)
+		assert.Equal(t, int64(2), usages[0].MessagesProcessed)
+		assert.Greater(t, usages[0].BytesStored, int64(0))
+		assert.Equal(t, int64(0), usages[0].APICalls)
+	}
+}
+
+func TestKafkaConsumer_ProcessMessage_FailedAnalysis(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mockConsumer := &consumer.KafkaConsumer{
+		Configuration: wrongBrokerCfg,
+		Storage:       mockStorage,
+	}
+
+	// the last successful analysis found some rule hits
+	message := `{
+		"OrgID": ` + fmt.Sprint(testdata.OrgID) + `,
+		"ClusterName": "` + string(testdata.ClusterName) + `",
+		"Report": ` + string(testdata.Report3Rules) + `,
+		"LastChecked": "` + testdata.LastCheckedAt.UTC().Format(time.RFC3339) + `"
+	}`
+	err := consumerProcessMessage(mockConsumer, message)
+	helpers.FailOnError(t, err)
+
+	message = `{
+		"OrgID": ` + fmt.Sprint(testdata.OrgID) + `,
+		"ClusterName": "` + string(testdata.ClusterName) + `",
+		"LastChecked": "` + time.Now().UTC().Format(time.RFC3339Nano) + `",
+		"Status": "failed"
+	}`
+	err = consumerProcessMessage(mockConsumer, message)
+	helpers.FailOnError(t, err)
+
+	status, err := mockStorage.ReadReportStatusForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.ReportStatusFailed, status)
+
+	// rule hits of the last successful analysis are kept
+	rules, _, err := mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Len(t, rules, 3)
+}
+
+func TestKafkaConsumer_ProcessMessage_FrozenOrg(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mockConsumer := &consumer.KafkaConsumer{
+		Configuration: wrongBrokerCfg,
+		Storage:       mockStorage,
+	}
+
+	err := mockStorage.FreezeOrg(testdata.OrgID, "legal hold")
+	helpers.FailOnError(t, err)
+
+	// message of frozen organization is dropped without error
+	err = consumerProcessMessage(mockConsumer, testdata.ConsumerMessage)
+	helpers.FailOnError(t, err)
+
+	_, _, err = mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+	err = mockStorage.UnfreezeOrg(testdata.OrgID)
+	helpers.FailOnError(t, err)
+
+	err = consumerProcessMessage(mockConsumer, testdata.ConsumerMessage)
+	helpers.FailOnError(t, err)
+
+	_, _, err = mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+}
+
+func TestKafkaConsumer_ProcessMessage_RecommendationDeletion(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mockConsumer := &consumer.KafkaConsumer{
+		Configuration: wrongBrokerCfg,
+		Storage:       mockStorage,
+	}
+
+	err := consumerProcessMessage(mockConsumer, testdata.ConsumerMessage)
+	helpers.FailOnError(t, err)
+
+	message := `{
+		"OrgID": ` + fmt.Sprint(testdata.OrgID) + `,
+		"ClusterName": "` + string(testdata.ClusterName) + `",
+		"LastChecked": "` + time.Now().UTC().Format(time.RFC3339Nano) + `",
+		"Type": "recommendation_deletion"
+	}`
+	err = consumerProcessMessage(mockConsumer, message)
+	helpers.FailOnError(t, err)
+
+	_, _, err = mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+	// the deletion is idempotent
+	err = consumerProcessMessage(mockConsumer, message)
+	helpers.FailOnError(t, err)
+
+	// report older than the deletion is rejected
+	err = consumerProcessMessage(mockConsumer, testdata.ConsumerMessage)
+	helpers.FailOnError(t, err)
+
+	_, _, err = mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
+
 func TestKafkaConsumer_ConsumeClaim(t *testing.T) {
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
@@ -609,13 +1004,22 @@ func TestKafkaConsumer_ConsumeClaim_OKMessage(t *testing.T) {
 		Storage: mockStorage,
 	}
 
+	message := saramahelpers.StringToSaramaConsumerMessage(testdata.ConsumerMessage)
+
 	mockConsumerGroupSession := &saramahelpers.MockConsumerGroupSession{}
-	mockConsumerGroupClaim := saramahelpers.NewMockConsumerGroupClaim([]*sarama.ConsumerMessage{
-		saramahelpers.StringToSaramaConsumerMessage(testdata.ConsumerMessage),
-	})
+	mockConsumerGroupClaim := saramahelpers.NewMockConsumerGroupClaim([]*sarama.ConsumerMessage{message})
 
 	err := kafkaConsumer.ConsumeClaim(mockConsumerGroupSession, mockConsumerGroupClaim)
 	helpers.FailOnError(t, err)
+
+	// offset of the processed message is stored
+	offset, err := mockStorage.GetLatestKafkaOffset(message.Topic, message.Partition)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.KafkaOffset(message.Offset), offset)
+
+	offsets, err := mockStorage.GetLatestKafkaOffsets()
+	helpers.FailOnError(t, err)
+	assert.Len(t, offsets, 1)
 }
 
 func TestKafkaConsumer_SetupCleanup(t *testing.T) {
@@ -638,8 +1042,436 @@ func TestKafkaConsumer_SetupCleanup(t *testing.T) {
 		helpers.FailOnError(t, mockConsumer.Close())
 	}()
 
-	// The functions don't really use their arguments at all,
-	// so it's possible to just pass nil into them.
-	helpers.FailOnError(t, mockConsumer.Setup(nil))
-	helpers.FailOnError(t, mockConsumer.Cleanup(nil))
+	session := &claimsConsumerGroupSession{claims: map[string][]int32{testTopicName: {0, 1}}}
+
+	helpers.FailOnError(t, mockConsumer.Setup(session))
+	assert.Equal(t, 2.0, getGaugeValue(metrics.ConsumerClaimedPartitions.WithLabelValues(testTopicName)))
+
+	helpers.FailOnError(t, mockConsumer.Cleanup(session))
+	assert.Equal(t, 0.0, getGaugeValue(metrics.ConsumerClaimedPartitions.WithLabelValues(testTopicName)))
+
+	// new session is set up after every rebalance
+	helpers.FailOnError(t, mockConsumer.Setup(session))
+	helpers.FailOnError(t, mockConsumer.Cleanup(session))
+}
+
+// fullConsumerGroupClaim is a claim whose channel of messages is full the
+// same way as sarama's channel is when messages are fetched faster than they
+// are processed
+type fullConsumerGroupClaim struct {
+	saramahelpers.MockConsumerGroupClaim
+	messages chan *sarama.ConsumerMessage
+}
+
+func newFullConsumerGroupClaim(messages []*sarama.ConsumerMessage) *fullConsumerGroupClaim {
+	channel := make(chan *sarama.ConsumerMessage, len(messages))
+	for _, message := range messages {
+		channel <- message
+	}
+	close(channel)
+
+	return &fullConsumerGroupClaim{messages: channel}
+}
+
+func (claim *fullConsumerGroupClaim) Messages() <-chan *sarama.ConsumerMessage {
+	return claim.messages
+}
+
+func TestKafkaConsumer_ConsumeClaim_BufferFull(t *testing.T) {
+	const numberOfMessages = 5
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	kafkaConsumer := consumer.KafkaConsumer{
+		Storage: mockStorage,
+	}
+
+	var messages []*sarama.ConsumerMessage
+	for i := 0; i < numberOfMessages; i++ {
+		message := saramahelpers.StringToSaramaConsumerMessage(testdata.ConsumerMessage)
+		message.Offset = int64(i)
+		messages = append(messages, message)
+	}
+
+	bufferFull := getCounterValue(metrics.ConsumerBufferFull)
+
+	mockConsumerGroupSession := &saramahelpers.MockConsumerGroupSession{}
+	err := kafkaConsumer.ConsumeClaim(mockConsumerGroupSession, newFullConsumerGroupClaim(messages))
+	helpers.FailOnError(t, err)
+
+	// all messages are processed and the full buffer is reported
+	assert.Equal(
+		t,
+		uint64(numberOfMessages),
+		kafkaConsumer.GetNumberOfSuccessfullyConsumedMessages()+kafkaConsumer.GetNumberOfErrorsConsumingMessages(),
+	)
+	assert.Equal(t, bufferFull+1, getCounterValue(metrics.ConsumerBufferFull))
+	assert.Equal(t, 0.0, getGaugeValue(metrics.ConsumerBufferedMessages))
+}
+
+// TestRecordBufferedMessages checks that the buffer staying full is counted
+// only once
+func TestRecordBufferedMessages(t *testing.T) {
+	messages := make(chan *sarama.ConsumerMessage, 2)
+	messages <- &sarama.ConsumerMessage{}
+	messages <- &sarama.ConsumerMessage{}
+
+	bufferFull := getCounterValue(metrics.ConsumerBufferFull)
+	buffered := getGaugeValue(metrics.ConsumerBufferedMessages)
+
+	previous := consumer.RecordBufferedMessages(messages, 0)
+	assert.Equal(t, bufferFull+1, getCounterValue(metrics.ConsumerBufferFull))
+
+	// sarama refilled the buffer after the message was processed
+	previous = consumer.RecordBufferedMessages(messages, previous)
+	assert.Equal(t, bufferFull+1, getCounterValue(metrics.ConsumerBufferFull))
+
+	<-messages
+	previous = consumer.RecordBufferedMessages(messages, previous)
+	assert.Equal(t, bufferFull+1, getCounterValue(metrics.ConsumerBufferFull))
+
+	messages <- &sarama.ConsumerMessage{}
+	previous = consumer.RecordBufferedMessages(messages, previous)
+	assert.Equal(t, bufferFull+2, getCounterValue(metrics.ConsumerBufferFull))
+	assert.Equal(t, buffered+2, getGaugeValue(metrics.ConsumerBufferedMessages))
+
+	metrics.ConsumerBufferedMessages.Sub(float64(previous))
+}
+
+func getGaugeValue(gauge prometheus.Gauge) float64 {
+	pb := &prommodels.Metric{}
+	if err := gauge.Write(pb); err != nil {
+		panic(fmt.Sprintf("Unable to get value of gauge %v", err))
+	}
+
+	return pb.GetGauge().GetValue()
+}
+
+func getCounterValue(counter prometheus.Counter) float64 {
+	pb := &prommodels.Metric{}
+	if err := counter.Write(pb); err != nil {
+		panic(fmt.Sprintf("Unable to get value of counter %v", err))
+	}
+
+	return pb.GetCounter().GetValue()
+}
+
+// expectPayloadStatuses makes the mock producer expect payload tracker
+// messages with the given statuses in the given order
+func expectPayloadStatuses(mockProducer *mocks.SyncProducer, statuses ...string) {
+	for _, status := range statuses {
+		expectedStatus := status
+		mockProducer.ExpectSendMessageWithCheckerFunctionAndSucceed(func(value []byte) error {
+			var sent producer.PayloadTrackerMessage
+			if err := json.Unmarshal(value, &sent); err != nil {
+				return err
+			}
+			if sent.RequestID != string(testdata.TestRequestID) || sent.Status != expectedStatus {
+				return fmt.Errorf("expected status %v, got payload tracker message %+v", expectedStatus, sent)
+			}
+			return nil
+		})
+	}
+}
+
+func payloadTrackerConsumer(s storage.Storage, mockProducer *mocks.SyncProducer) *consumer.KafkaConsumer {
+	kafkaConsumer := dummyConsumer(s, false).(*consumer.KafkaConsumer)
+	kafkaConsumer.SetPayloadTrackerProducer(&producer.KafkaProducer{
+		Configuration: broker.Configuration{PayloadTrackerTopic: "payload-tracker-topic"},
+		Producer:      mockProducer,
+	})
+
+	return kafkaConsumer
+}
+
+func messageWithRequestID(lastChecked string) *sarama.ConsumerMessage {
+	return &sarama.ConsumerMessage{
+		Topic: testTopicName,
+		Value: []byte(`{
+			"OrgID": ` + fmt.Sprint(testdata.OrgID) + `,
+			"ClusterName": "` + string(testdata.ClusterName) + `",
+			"Report": ` + testdata.ConsumerReport + `,
+			"LastChecked": "` + lastChecked + `",
+			"RequestId": "` + string(testdata.TestRequestID) + `"
+		}`),
+	}
+}
+
+// TestKafkaConsumer_HandleMessage_PayloadTracker checks that statuses of the
+// successfully processed payload are sent to Payload Tracker
+func TestKafkaConsumer_HandleMessage_PayloadTracker(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	expectPayloadStatuses(mockProducer, producer.StatusReceived, producer.StatusMessageProcessed, producer.StatusSuccess)
+
+	kafkaConsumer := payloadTrackerConsumer(mockStorage, mockProducer)
+	kafkaConsumer.HandleMessage(messageWithRequestID(testdata.LastCheckedAt.Format(time.RFC3339)))
+
+	assert.Equal(t, uint64(1), kafkaConsumer.GetNumberOfSuccessfullyConsumedMessages())
+	helpers.FailOnError(t, kafkaConsumer.Close())
+}
+
+// TestKafkaConsumer_HandleMessage_PayloadTrackerError checks that the error
+// status is sent to Payload Tracker when the payload can't be processed
+func TestKafkaConsumer_HandleMessage_PayloadTrackerError(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	expectPayloadStatuses(mockProducer, producer.StatusReceived, producer.StatusError)
+
+	kafkaConsumer := payloadTrackerConsumer(mockStorage, mockProducer)
+	kafkaConsumer.HandleMessage(messageWithRequestID("2020.01.23 16:15:59"))
+
+	assert.Equal(t, uint64(1), kafkaConsumer.GetNumberOfErrorsConsumingMessages())
+	helpers.FailOnError(t, kafkaConsumer.Close())
+}
+
+// TestKafkaConsumer_HandleMessage_PayloadTrackerDisabled checks that
+// messages are processed when payload tracking is not configured
+func TestKafkaConsumer_HandleMessage_PayloadTrackerDisabled(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	kafkaConsumer := dummyConsumer(mockStorage, false).(*consumer.KafkaConsumer)
+	kafkaConsumer.HandleMessage(messageWithRequestID(testdata.LastCheckedAt.Format(time.RFC3339)))
+
+	assert.Equal(t, uint64(1), kafkaConsumer.GetNumberOfSuccessfullyConsumedMessages())
+}
+
+// externalResultsMessage returns message with results of the external
+// source with the given results
+func externalResultsMessage(results string) string {
+	return `{
+		"OrgID": ` + fmt.Sprint(testdata.OrgID) + `,
+		"ClusterName": "` + string(testdata.ClusterName) + `",
+		"Source": "scanner",
+		"LastChecked": "` + testdata.LastCheckedAt.Format(time.RFC3339) + `",
+		"Results": ` + results + `
+	}`
+}
+
+func TestParseExternalResults(t *testing.T) {
+	message, err := consumer.ParseExternalResults([]byte(externalResultsMessage(`[
+		{"check_id": "CVE-2020-1234", "severity": 3, "description": "vulnerable image", "details": {"image": "nginx"}}
+	]`)))
+	helpers.FailOnError(t, err)
+	assert.Equal(t, "scanner", message.Source)
+	if assert.Len(t, message.Results, 1) {
+		assert.Equal(t, "CVE-2020-1234", message.Results[0].CheckID)
+		assert.Equal(t, 3, message.Results[0].Severity)
+		assert.JSONEq(t, `{"image": "nginx"}`, string(message.Results[0].Details))
+	}
+}
+
+func TestParseExternalResultsErrors(t *testing.T) {
+	for _, tc := range []struct {
+		message string
+		err     string
+	}{
+		{
+			message: `{"ClusterName": "` + string(testdata.ClusterName) + `", "Source": "scanner", "Results": []}`,
+			err:     "missing required attribute 'OrgID'",
+		},
+		{
+			message: `{"OrgID": 1, "ClusterName": "` + string(testdata.ClusterName) + `", "Results": []}`,
+			err:     "missing required attribute 'Source'",
+		},
+		{
+			message: `{"OrgID": 1, "ClusterName": "` + string(testdata.ClusterName) + `", "Source": "scanner"}`,
+			err:     "missing required attribute 'Results'",
+		},
+		{
+			message: externalResultsMessage(`[{"severity": 1}]`),
+			err:     "missing required attribute 'check_id' of result 0",
+		},
+		{
+			message: externalResultsMessage(`[{"check_id": "CVE-2020-1234", "severity": 5}]`),
+			err:     "severity of result CVE-2020-1234 must be between 1 and 4, got 5",
+		},
+	} {
+		_, err := consumer.ParseExternalResults([]byte(tc.message))
+		assert.EqualError(t, err, tc.err)
+	}
+}
+
+func TestKafkaConsumer_ProcessMessage_ExternalResults(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	brokerCfg := wrongBrokerCfg
+	brokerCfg.ExternalResultsTopic = "external.results"
+
+	mockConsumer := &consumer.KafkaConsumer{
+		Configuration: brokerCfg,
+		Storage:       mockStorage,
+	}
+
+	_, err := mockConsumer.ProcessMessage(&sarama.ConsumerMessage{
+		Topic: brokerCfg.ExternalResultsTopic,
+		Value: []byte(externalResultsMessage(`[
+			{"check_id": "CVE-2020-1234", "severity": 3, "description": "vulnerable image"}
+		]`)),
+	})
+	helpers.FailOnError(t, err)
+
+	results, err := mockStorage.ReadExternalResults(testdata.ClusterName, "")
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []types.ExternalResult{{
+		Source:        "scanner",
+		CheckID:       "CVE-2020-1234",
+		Severity:      3,
+		Description:   "vulnerable image",
+		LastCheckedAt: types.Timestamp(testdata.LastCheckedAt.UTC().Format(time.RFC3339)),
+	}}, results)
+
+	// the same message consumed from the topic with reports is not valid
+	err = consumerProcessMessage(mockConsumer, externalResultsMessage("[]"))
+	assert.EqualError(t, err, "missing required attribute 'Report'")
+}
+
+func TestKafkaConsumer_ProcessMessage_ExternalResultsDBError(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorageWithFaults(t, true)
+	defer closer()
+
+	mockStorage.InjectFault("WriteExternalResults", ira_helpers.Fault{Err: errors.New("results error")})
+
+	brokerCfg := wrongBrokerCfg
+	brokerCfg.ExternalResultsTopic = "external.results"
+
+	mockConsumer := &consumer.KafkaConsumer{
+		Configuration: brokerCfg,
+		Storage:       mockStorage,
+	}
+
+	_, err := mockConsumer.ProcessMessage(&sarama.ConsumerMessage{
+		Topic: brokerCfg.ExternalResultsTopic,
+		Value: []byte(externalResultsMessage("[]")),
+	})
+	assert.EqualError(t, err, "results error")
+}
+
+// claimsConsumerGroupSession is a consumer group session with claimed
+// partitions and context that can be cancelled
+type claimsConsumerGroupSession struct {
+	saramahelpers.MockConsumerGroupSession
+	claims map[string][]int32
+	ctx    context.Context
+}
+
+func (session *claimsConsumerGroupSession) Claims() map[string][]int32 {
+	return session.claims
+}
+
+func (session *claimsConsumerGroupSession) Context() context.Context {
+	if session.ctx == nil {
+		return context.Background()
+	}
+
+	return session.ctx
+}
+
+func TestKafkaConsumer_ConsumeClaim_CancelledSession(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	kafkaConsumer := consumer.KafkaConsumer{
+		Storage: mockStorage,
+	}
+
+	var messages []*sarama.ConsumerMessage
+	for i := 0; i < 3; i++ {
+		message := saramahelpers.StringToSaramaConsumerMessage(testdata.ConsumerMessage)
+		message.Offset = int64(i)
+		messages = append(messages, message)
+	}
+
+	// the session is cancelled by rebalance
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	mockConsumerGroupSession := &claimsConsumerGroupSession{ctx: ctx}
+	mockConsumerGroupClaim := saramahelpers.NewMockConsumerGroupClaim(messages)
+
+	err := kafkaConsumer.ConsumeClaim(mockConsumerGroupSession, mockConsumerGroupClaim)
+	helpers.FailOnError(t, err)
+
+	// no message is processed, they are left for the next owner of the partition
+	assert.Equal(t, uint64(0), kafkaConsumer.GetNumberOfSuccessfullyConsumedMessages())
+	assert.Equal(t, 0.0, getGaugeValue(metrics.ConsumerBufferedMessages))
+
+	offsets, err := mockStorage.GetLatestKafkaOffsets()
+	helpers.FailOnError(t, err)
+	assert.Empty(t, offsets)
+}
+
+func TestParseMessageKey(t *testing.T) {
+	key, kind := consumer.ParseMessageKey([]byte(strings.ToUpper(string(testdata.ClusterName))))
+	assert.Equal(t, string(testdata.ClusterName), key)
+	assert.Equal(t, "cluster", string(kind))
+
+	key, kind = consumer.ParseMessageKey([]byte("80e4b6b5c5ad4d2e9c4d0b8c4a1b2c3d-request"))
+	assert.Equal(t, "80e4b6b5c5ad4d2e9c4d0b8c4a1b2c3d-request", key)
+	assert.Equal(t, "request", string(kind))
+
+	key, kind = consumer.ParseMessageKey(nil)
+	assert.Empty(t, key)
+	assert.Empty(t, string(kind))
+}
+
+func TestKafkaConsumer_ProcessMessage_MessageKey(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mockConsumer := &consumer.KafkaConsumer{
+		Configuration: wrongBrokerCfg,
+		Storage:       mockStorage,
+	}
+
+	_, err := mockConsumer.ProcessMessage(&sarama.ConsumerMessage{
+		Topic: wrongBrokerCfg.Topic,
+		Key:   []byte(testdata.ClusterName),
+		Value: []byte(testdata.ConsumerMessage),
+	})
+	helpers.FailOnError(t, err)
+
+	lookup, err := mockStorage.LookupMessageKey(string(testdata.ClusterName))
+	helpers.FailOnError(t, err)
+	if assert.Len(t, lookup.Reports, 1) {
+		assert.Equal(t, testdata.ClusterName, lookup.Reports[0].ClusterName)
+	}
+	assert.Empty(t, lookup.ConsumerErrors)
+}
+
+func TestKafkaConsumer_ProcessMessage_ClusterClass(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mockConsumer := dummyConsumer(mockStorage, true)
+
+	message := `{
+		"OrgID": ` + fmt.Sprint(testdata.OrgID) + `,
+		"ClusterName": "` + string(testdata.ClusterName) + `",
+		"Report": ` + testdata.ConsumerReport + `,
+		"LastChecked": "` + testdata.LastCheckedAt.Format(time.RFC3339) + `",
+		"Metadata": {"managed": true}
+	}`
+
+	err := consumerProcessMessage(mockConsumer, message)
+	helpers.FailOnError(t, err)
+
+	classes, err := mockStorage.ReadClusterClasses(testdata.OrgID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.ClusterClassManaged, classes[testdata.ClusterName])
+
+	// the class is kept when newer report doesn't contain metadata
+	err = consumerProcessMessage(mockConsumer, testdata.ConsumerMessage)
+	helpers.FailOnError(t, err)
+
+	classes, err = mockStorage.ReadClusterClasses(testdata.OrgID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.ClusterClassManaged, classes[testdata.ClusterName])
 }
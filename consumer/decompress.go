@@ -0,0 +1,79 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	// gzipMagic is the header of gzip compressed data
+	gzipMagic = []byte{0x1f, 0x8b}
+	// zstdMagic is the header of zstd compressed frame
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// decompressMessageValue decompresses gzip or zstd compressed message value
+// when decompression of payloads is enabled. Values that are not compressed
+// are returned unchanged.
+func (consumer *KafkaConsumer) decompressMessageValue(value []byte) ([]byte, error) {
+	if !consumer.Configuration.DecompressPayloads {
+		return value, nil
+	}
+
+	switch {
+	case bytes.HasPrefix(value, gzipMagic):
+		reader, err := gzip.NewReader(bytes.NewReader(value))
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			_ = reader.Close()
+		}()
+
+		return readDecompressed(reader)
+	case bytes.HasPrefix(value, zstdMagic):
+		decoder, err := zstd.NewReader(bytes.NewReader(value), zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		defer decoder.Close()
+
+		return readDecompressed(decoder)
+	default:
+		return value, nil
+	}
+}
+
+// readDecompressed reads all decompressed data, at most
+// maxDecompressedMessageSize bytes
+func readDecompressed(reader io.Reader) ([]byte, error) {
+	value, err := ioutil.ReadAll(io.LimitReader(reader, maxDecompressedMessageSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(value) > maxDecompressedMessageSize {
+		return nil, fmt.Errorf("decompressed message is larger than %d bytes", maxDecompressedMessageSize)
+	}
+
+	return value, nil
+}
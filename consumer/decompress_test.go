@@ -0,0 +1,121 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/consumer"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+)
+
+func testMessage() string {
+	return `{
+		"OrgID": ` + fmt.Sprint(testdata.OrgID) + `,
+		"ClusterName": "` + string(testdata.ClusterName) + `",
+		"Report":` + testdata.ConsumerReport + `,
+		"LastChecked": "` + time.Now().Format(time.RFC3339) + `"
+	}`
+}
+
+func gzipCompress(t testing.TB, data []byte) []byte {
+	var buffer bytes.Buffer
+
+	writer := gzip.NewWriter(&buffer)
+	_, err := writer.Write(data)
+	helpers.FailOnError(t, err)
+	helpers.FailOnError(t, writer.Close())
+
+	return buffer.Bytes()
+}
+
+func zstdCompress(t testing.TB, data []byte) []byte {
+	encoder, err := zstd.NewWriter(nil)
+	helpers.FailOnError(t, err)
+	defer func() {
+		helpers.FailOnError(t, encoder.Close())
+	}()
+
+	return encoder.EncodeAll(data, nil)
+}
+
+func newDecompressingConsumer(t testing.TB, enabled bool) (*consumer.KafkaConsumer, func()) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+
+	brokerCfg := wrongBrokerCfg
+	brokerCfg.DecompressPayloads = enabled
+
+	return &consumer.KafkaConsumer{
+		Configuration: brokerCfg,
+		Storage:       mockStorage,
+	}, closer
+}
+
+func TestKafkaConsumer_ProcessMessage_CompressedPayloads(t *testing.T) {
+	for name, compress := range map[string]func(testing.TB, []byte) []byte{
+		"gzip": gzipCompress,
+		"zstd": zstdCompress,
+		"none": func(_ testing.TB, data []byte) []byte { return data },
+	} {
+		t.Run(name, func(t *testing.T) {
+			mockConsumer, closer := newDecompressingConsumer(t, true)
+			defer closer()
+
+			err := consumerProcessMessage(mockConsumer, string(compress(t, []byte(testMessage()))))
+			helpers.FailOnError(t, err)
+
+			exists, err := mockConsumer.Storage.DoesClusterExist(testdata.ClusterName)
+			helpers.FailOnError(t, err)
+			assert.True(t, exists)
+		})
+	}
+}
+
+func TestKafkaConsumer_ProcessMessage_CompressedPayloadsDisabled(t *testing.T) {
+	mockConsumer, closer := newDecompressingConsumer(t, false)
+	defer closer()
+
+	err := consumerProcessMessage(mockConsumer, string(gzipCompress(t, []byte(testMessage()))))
+	assert.Error(t, err)
+}
+
+func TestKafkaConsumer_ProcessMessage_CorruptedCompressedPayload(t *testing.T) {
+	mockConsumer, closer := newDecompressingConsumer(t, true)
+	defer closer()
+
+	compressed := gzipCompress(t, []byte(testMessage()))
+
+	err := consumerProcessMessage(mockConsumer, string(compressed[:len(compressed)/2]))
+	assert.Error(t, err)
+}
+
+func TestKafkaConsumer_ProcessMessage_DecompressedPayloadTooLarge(t *testing.T) {
+	mockConsumer, closer := newDecompressingConsumer(t, true)
+	defer closer()
+
+	compressed := gzipCompress(t, make([]byte, 64*1024*1024+1))
+
+	err := consumerProcessMessage(mockConsumer, string(compressed))
+	assert.EqualError(t, err, "decompressed message is larger than 67108864 bytes")
+}
@@ -16,6 +16,11 @@ limitations under the License.
 
 package consumer
 
+import (
+	"github.com/RedHatInsights/insights-results-aggregator/producer"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
 // Export for testing
 //
 // This source file contains name aliases of all package-private functions
@@ -26,6 +31,27 @@ package consumer
 // https://medium.com/@robiplus/golang-trick-export-for-test-aa16cbd7b8cd
 // to see why this trick is needed.
 var (
-	ParseMessage         = parseMessage
-	CheckReportStructure = checkReportStructure
+	ParseMessage           = parseMessage
+	ParseExternalResults   = parseExternalResultsMessage
+	CheckReportStructure   = checkReportStructure
+	NormalizeClusterName   = normalizeClusterName
+	NewOrgRateTracker      = newOrgRateTracker
+	ParseMessageKey        = parseMessageKey
+	RecordBufferedMessages = recordBufferedMessages
 )
+
+// SetPayloadTrackerProducer sets producer used to send statuses of payloads
+// to Payload Tracker service
+func (consumer *KafkaConsumer) SetPayloadTrackerProducer(payloadTrackerProducer *producer.KafkaProducer) {
+	consumer.payloadTrackerProducer = payloadTrackerProducer
+}
+
+// SetOrgRateTracker sets tracker of message rates of organizations
+func (consumer *KafkaConsumer) SetOrgRateTracker(tracker *orgRateTracker) {
+	consumer.orgRates = tracker
+}
+
+// Record adds one message of the organization into its message rate
+func (tracker *orgRateTracker) Record(orgID types.OrgID) orgRateCheck {
+	return tracker.record(orgID)
+}
@@ -28,6 +28,7 @@ func logMessageInfo(consumer *KafkaConsumer, originalMessage *sarama.ConsumerMes
 		Str(topicKey, consumer.Configuration.Topic).
 		Int(organizationKey, int(*parsedMessage.Organization)).
 		Str(clusterKey, string(*parsedMessage.ClusterName)).
+		Str(messageKeyKey, parsedMessage.MessageKey).
 		Int(versionKey, int(parsedMessage.Version)).
 		Msg(event)
 }
@@ -36,6 +37,7 @@ func logUnparsedMessageError(consumer *KafkaConsumer, originalMessage *sarama.Co
 	log.Error().
 		Int(offsetKey, int(originalMessage.Offset)).
 		Str(topicKey, consumer.Configuration.Topic).
+		Str(messageKeyKey, string(originalMessage.Key)).
 		Err(err).
 		Msg(event)
 }
@@ -46,6 +48,7 @@ func logMessageError(consumer *KafkaConsumer, originalMessage *sarama.ConsumerMe
 		Str(topicKey, consumer.Configuration.Topic).
 		Int(organizationKey, int(*parsedMessage.Organization)).
 		Str(clusterKey, string(*parsedMessage.ClusterName)).
+		Str(messageKeyKey, parsedMessage.MessageKey).
 		Int(versionKey, int(parsedMessage.Version)).
 		Err(err).
 		Msg(event)
@@ -58,6 +61,7 @@ func logMessageWarning(consumer *KafkaConsumer, originalMessage *sarama.Consumer
 		Str(topicKey, consumer.Configuration.Topic).
 		Int(organizationKey, int(*parsedMessage.Organization)).
 		Str(clusterKey, string(*parsedMessage.ClusterName)).
+		Str(messageKeyKey, parsedMessage.MessageKey).
 		Int(versionKey, int(parsedMessage.Version)).
 		Msg(event)
 }
@@ -0,0 +1,86 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer
+
+import (
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// messageKeyKind describes what the key of the Kafka message identifies
+type messageKeyKind string
+
+const (
+	// messageKeyNone is used for messages without key
+	messageKeyNone messageKeyKind = ""
+	// messageKeyCluster is used when the key is the cluster ID, it is
+	// produced this way by the platform gateway for uploads of the operator
+	messageKeyCluster messageKeyKind = "cluster"
+	// messageKeyRequest is used for any other key, it is the request ID of
+	// the upload
+	messageKeyRequest messageKeyKind = "request"
+)
+
+// parseMessageKey returns the key of the Kafka message as stored and logged
+// together with what the key identifies. Cluster IDs are returned in
+// canonical form, so they can be compared with cluster names.
+func parseMessageKey(key []byte) (string, messageKeyKind) {
+	if len(key) == 0 {
+		return "", messageKeyNone
+	}
+
+	clusterUUID, err := uuid.Parse(string(key))
+	if err != nil {
+		return string(key), messageKeyRequest
+	}
+
+	return clusterUUID.String(), messageKeyCluster
+}
+
+// checkMessageKey warns when the key of the message identifies another
+// cluster than the one the message is about, that means the upload was
+// routed wrongly or the cluster ID was rewritten on the way
+func checkMessageKey(consumer *KafkaConsumer, msg *sarama.ConsumerMessage, message incomingMessage) {
+	if message.MessageKeyKind != messageKeyCluster {
+		return
+	}
+
+	clusterName, err := normalizeClusterName(*message.ClusterName)
+	if err != nil || string(clusterName) != message.MessageKey {
+		logMessageWarning(consumer, msg, message, "Message key identifies another cluster than the message")
+	}
+}
+
+// writeReportMessageKey stores the key of the message together with the
+// report stored from it. The key is used for tracing of uploads only, so
+// the message is not considered failed when the key can't be stored.
+func writeReportMessageKey(
+	consumer *KafkaConsumer, msg *sarama.ConsumerMessage, message incomingMessage, lastCheckedTime time.Time,
+) {
+	err := consumer.Storage.WriteReportMessageKey(
+		*message.Organization, *message.ClusterName, lastCheckedTime, message.MessageKey,
+	)
+	if err != nil {
+		log.Warn().
+			Int(offsetKey, int(msg.Offset)).
+			Str(topicKey, consumer.Configuration.Topic).
+			Str(messageKeyKey, message.MessageKey).
+			Err(err).
+			Msg("Unable to store message key")
+	}
+}
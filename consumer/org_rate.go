@@ -0,0 +1,172 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// orgRatePruneWindows is the number of baseline periods after which the
+// organization that sent no message is forgotten, its baseline is almost
+// zero by then anyway
+const orgRatePruneWindows = 4
+
+// orgRate is the message rate of one organization
+type orgRate struct {
+	// window is the index of the current window
+	window int64
+	// count is the number of messages in the current window
+	count int
+	// baseline is exponential moving average of counts of completed windows
+	baseline float64
+	// windows is the number of completed windows included in baseline,
+	// it is not incremented beyond the number of baseline windows
+	windows int
+	// reported is true when the anomaly in the current window was reported
+	reported bool
+}
+
+// orgRateCheck is the result of recording one message of the organization
+type orgRateCheck struct {
+	Count    int
+	Baseline float64
+	// Anomalous is true when the number of messages in the current window
+	// exceeds the baseline of the organization by the anomaly factor
+	Anomalous bool
+	// Detected is true for the first anomalous message in the window
+	Detected bool
+}
+
+// orgRateTracker tracks the number of messages of every organization in
+// fixed windows and compares it with the baseline of the organization
+// computed from previous windows, so sudden storms of (usually duplicate)
+// messages can be detected
+type orgRateTracker struct {
+	window          time.Duration
+	baselineWindows int
+	anomalyFactor   float64
+	minMessages     int
+	now             func() time.Time
+
+	mutex      sync.Mutex
+	rates      map[types.OrgID]*orgRate
+	lastPruned int64
+}
+
+// newOrgRateTracker constructs the tracker according to the configuration,
+// nil is returned when the detection is disabled
+func newOrgRateTracker(brokerCfg broker.Configuration, now func() time.Time) *orgRateTracker {
+	if brokerCfg.OrgRateWindow <= 0 {
+		return nil
+	}
+
+	tracker := &orgRateTracker{
+		window:          brokerCfg.OrgRateWindow,
+		baselineWindows: brokerCfg.OrgRateBaselineWindows,
+		anomalyFactor:   brokerCfg.OrgRateAnomalyFactor,
+		minMessages:     brokerCfg.OrgRateMinMessages,
+		now:             now,
+		rates:           map[types.OrgID]*orgRate{},
+	}
+
+	if tracker.baselineWindows <= 0 {
+		tracker.baselineWindows = DefaultOrgRateBaselineWindows
+	}
+	if tracker.anomalyFactor <= 0 {
+		tracker.anomalyFactor = DefaultOrgRateAnomalyFactor
+	}
+
+	return tracker
+}
+
+// record adds one message of the organization into its current window and
+// checks whether the rate of the organization is anomalous. The anomaly is
+// detected only when the baseline is computed from all baseline windows.
+func (tracker *orgRateTracker) record(orgID types.OrgID) orgRateCheck {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	window := tracker.now().UnixNano() / int64(tracker.window)
+	tracker.prune(window)
+
+	rate, found := tracker.rates[orgID]
+	if !found {
+		rate = &orgRate{window: window}
+		tracker.rates[orgID] = rate
+	}
+
+	if window > rate.window {
+		tracker.completeWindows(rate, window)
+	}
+
+	rate.count++
+
+	limit := tracker.anomalyFactor * math.Max(rate.baseline, 1)
+	check := orgRateCheck{
+		Count:    rate.count,
+		Baseline: rate.baseline,
+		Anomalous: rate.windows >= tracker.baselineWindows &&
+			rate.count >= tracker.minMessages &&
+			float64(rate.count) > limit,
+	}
+
+	if check.Anomalous && !rate.reported {
+		check.Detected = true
+		rate.reported = true
+	}
+
+	return check
+}
+
+// completeWindows adds the count of the current window and zero counts of
+// windows without messages into the baseline and moves to the new window
+func (tracker *orgRateTracker) completeWindows(rate *orgRate, window int64) {
+	alpha := 1 / float64(tracker.baselineWindows)
+	elapsed := window - rate.window
+
+	rate.baseline += alpha * (float64(rate.count) - rate.baseline)
+	rate.baseline *= math.Pow(1-alpha, float64(elapsed-1))
+
+	if elapsed > int64(tracker.baselineWindows-rate.windows) {
+		rate.windows = tracker.baselineWindows
+	} else {
+		rate.windows += int(elapsed)
+	}
+
+	rate.window = window
+	rate.count = 0
+	rate.reported = false
+}
+
+// prune forgets organizations that sent no message for a long time, it's
+// done at most once per window
+func (tracker *orgRateTracker) prune(window int64) {
+	if window <= tracker.lastPruned {
+		return
+	}
+	tracker.lastPruned = window
+
+	threshold := window - int64(orgRatePruneWindows*tracker.baselineWindows)
+	for orgID, rate := range tracker.rates {
+		if rate.window < threshold {
+			delete(tracker.rates, orgID)
+		}
+	}
+}
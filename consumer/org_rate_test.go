@@ -0,0 +1,138 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/consumer"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// fakeClock returns the time that can be moved by tests
+type fakeClock struct {
+	now time.Time
+}
+
+func (clock *fakeClock) Now() time.Time {
+	return clock.now
+}
+
+var orgRateBrokerCfg = broker.Configuration{
+	OrgRateWindow:          time.Minute,
+	OrgRateBaselineWindows: 3,
+	OrgRateAnomalyFactor:   5,
+	OrgRateMinMessages:     10,
+}
+
+func TestOrgRateTrackerDisabled(t *testing.T) {
+	assert.Nil(t, consumer.NewOrgRateTracker(broker.Configuration{}, time.Now))
+}
+
+func TestOrgRateTrackerAnomaly(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	tracker := consumer.NewOrgRateTracker(orgRateBrokerCfg, clock.Now)
+
+	// baseline of the organization is computed from 3 windows, the
+	// anomaly can't be detected before
+	for window := 0; window < 3; window++ {
+		for i := 0; i < 4; i++ {
+			assert.False(t, tracker.Record(testdata.OrgID).Anomalous)
+		}
+		clock.now = clock.now.Add(time.Minute)
+	}
+
+	// baseline is about 3 messages, so 15 messages exceed it 5 times
+	for i := 1; i < 15; i++ {
+		assert.False(t, tracker.Record(testdata.OrgID).Anomalous, "message %d", i)
+	}
+
+	check := tracker.Record(testdata.OrgID)
+	assert.True(t, check.Anomalous)
+	assert.True(t, check.Detected)
+	assert.Equal(t, 15, check.Count)
+	assert.InDelta(t, 2.81, check.Baseline, 0.01)
+
+	// the anomaly is reported once per window
+	check = tracker.Record(testdata.OrgID)
+	assert.True(t, check.Anomalous)
+	assert.False(t, check.Detected)
+
+	// other organizations are not affected
+	assert.False(t, tracker.Record(testdata.Org2ID).Anomalous)
+}
+
+func TestOrgRateTrackerForgetsIdleOrganization(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	tracker := consumer.NewOrgRateTracker(orgRateBrokerCfg, clock.Now)
+
+	for window := 0; window < 3; window++ {
+		tracker.Record(testdata.OrgID)
+		clock.now = clock.now.Add(time.Minute)
+	}
+
+	// the organization is forgotten after 4 baseline periods without
+	// messages, so the baseline has to be computed again
+	clock.now = clock.now.Add(13 * time.Minute)
+	tracker.Record(testdata.Org2ID)
+
+	for i := 0; i < 20; i++ {
+		assert.False(t, tracker.Record(testdata.OrgID).Anomalous)
+	}
+}
+
+func TestKafkaConsumer_ProcessMessage_OrgRateThrottle(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	brokerCfg := wrongBrokerCfg
+	brokerCfg.OrgRateWindow = time.Minute
+	brokerCfg.OrgRateBaselineWindows = 1
+	brokerCfg.OrgRateAnomalyFactor = 0.5
+	brokerCfg.OrgRateMinMessages = 1
+	brokerCfg.OrgRateThrottle = true
+
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	mockConsumer := &consumer.KafkaConsumer{
+		Configuration: brokerCfg,
+		Storage:       mockStorage,
+	}
+	mockConsumer.SetOrgRateTracker(consumer.NewOrgRateTracker(brokerCfg, clock.Now))
+
+	// one message in the first window is the baseline
+	deletion := `{
+		"OrgID": ` + fmt.Sprint(testdata.OrgID) + `,
+		"ClusterName": "` + string(testdata.ClusterName) + `",
+		"Type": "recommendation_deletion"
+	}`
+	err := consumerProcessMessage(mockConsumer, deletion)
+	helpers.FailOnError(t, err)
+
+	// half of the baseline is anomalous, the message is dropped
+	clock.now = clock.now.Add(time.Minute)
+	err = consumerProcessMessage(mockConsumer, testdata.ConsumerMessage)
+	helpers.FailOnError(t, err)
+
+	_, _, err = mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
@@ -17,12 +17,14 @@ package consumer
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/Shopify/sarama"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 
+	"github.com/RedHatInsights/insights-results-aggregator/events"
 	"github.com/RedHatInsights/insights-results-aggregator/metrics"
 	"github.com/RedHatInsights/insights-results-aggregator/producer"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
@@ -31,6 +33,26 @@ import (
 // Report represents report send in a message consumed from any broker
 type Report map[string]*json.RawMessage
 
+// messageType tells apart messages with results of the rules from messages
+// requesting deletion of the data of decommissioned clusters
+type messageType string
+
+const (
+	// messageTypeRulesResults is the report with results of the rules, it
+	// is used when the message doesn't contain the type
+	messageTypeRulesResults messageType = "rules_results"
+	// messageTypeRecommendationDeletion is the tombstone of decommissioned
+	// cluster, the report of the cluster is deleted
+	messageTypeRecommendationDeletion messageType = "recommendation_deletion"
+	// messageTypeExternalResults contains results of checks of the cluster
+	// done by external source (security scanner, for example), all messages
+	// consumed from the topic with external results have this type
+	messageTypeExternalResults messageType = "external_results"
+)
+
+// maxExternalResultSeverity is the highest severity of external result
+const maxExternalResultSeverity = 4
+
 // incomingMessage is representation of message consumed from any broker
 type incomingMessage struct {
 	Organization *types.OrgID       `json:"OrgID"`
@@ -40,7 +62,26 @@ type incomingMessage struct {
 	LastChecked string              `json:"LastChecked"`
 	Version     types.SchemaVersion `json:"Version"`
 	RequestID   types.RequestID     `json:"RequestId"`
-	ParsedHits  []types.ReportItem
+	// Status is the result of the analysis, the report is not required
+	// when the analysis failed
+	Status types.ReportStatus `json:"Status"`
+	// Type is the type of the message, messageTypeRulesResults is used
+	// when it is missing
+	Type       messageType `json:"Type"`
+	ParsedHits []types.ReportItem
+	// Source is the external source that checked the cluster, it is used
+	// only by messageTypeExternalResults
+	Source string `json:"Source"`
+	// Results are results of the checks done by the external source, they
+	// are used only by messageTypeExternalResults
+	Results []types.ExternalResult `json:"Results"`
+	// Metadata contains metadata of the cluster, it is optional
+	Metadata *messageMetadata `json:"Metadata"`
+	// MessageKey is the key of the Kafka message, it is not part of the
+	// message value
+	MessageKey string `json:"-"`
+	// MessageKeyKind describes what the MessageKey identifies
+	MessageKeyKind messageKeyKind `json:"-"`
 }
 
 // HandleMessage handles the message and does all logging, metrics, etc
@@ -59,8 +100,9 @@ func (consumer *KafkaConsumer) HandleMessage(msg *sarama.ConsumerMessage) {
 	timeAfterProcessingMessage := time.Now()
 	messageProcessingDuration := timeAfterProcessingMessage.Sub(startTime).Seconds()
 
+	// request ID is known only after the message is parsed, the time when
+	// the message was received is used anyway
 	consumer.updatePayloadTracker(requestID, startTime, producer.StatusReceived)
-	consumer.updatePayloadTracker(requestID, timeAfterProcessingMessage, producer.StatusMessageProcessed)
 
 	log.Info().
 		Int64(offsetKey, msg.Offset).
@@ -71,7 +113,12 @@ func (consumer *KafkaConsumer) HandleMessage(msg *sarama.ConsumerMessage) {
 	// Something went wrong while processing the message.
 	if err != nil {
 		metrics.FailedMessagesProcessingTime.Observe(messageProcessingDuration)
-		metrics.ConsumingErrors.Inc()
+		events.DefaultBus.PublishConsumerError(events.ConsumerErrorEvent{
+			Topic:     msg.Topic,
+			Partition: msg.Partition,
+			Offset:    msg.Offset,
+			Err:       err,
+		})
 
 		log.Error().Err(err).Msg("Error processing message consumed from Kafka")
 		consumer.numberOfErrorsConsumingMessages++
@@ -80,12 +127,13 @@ func (consumer *KafkaConsumer) HandleMessage(msg *sarama.ConsumerMessage) {
 			log.Error().Err(err).Msg("Unable to write consumer error to storage")
 		}
 
-		consumer.updatePayloadTracker(requestID, time.Now(), producer.StatusError)
+		consumer.updatePayloadTrackerError(requestID, timeAfterProcessingMessage, err)
 	} else {
 		// The message was processed successfully.
 		metrics.SuccessfulMessagesProcessingTime.Observe(messageProcessingDuration)
 		consumer.numberOfSuccessfullyConsumedMessages++
 
+		consumer.updatePayloadTracker(requestID, timeAfterProcessingMessage, producer.StatusMessageProcessed)
 		consumer.updatePayloadTracker(requestID, time.Now(), producer.StatusSuccess)
 	}
 
@@ -93,14 +141,33 @@ func (consumer *KafkaConsumer) HandleMessage(msg *sarama.ConsumerMessage) {
 	log.Info().Int64(durationKey, totalMessageDuration.Milliseconds()).Int64(offsetKey, msg.Offset).Msg("Message consumed")
 }
 
-// updatePayloadTracker
-func (consumer KafkaConsumer) updatePayloadTracker(requestID types.RequestID, timestamp time.Time, status string) {
+// updatePayloadTracker sends the status of the payload to Payload Tracker
+// service when payload tracking is enabled
+func (consumer *KafkaConsumer) updatePayloadTracker(requestID types.RequestID, timestamp time.Time, status string) {
+	if consumer.payloadTrackerProducer == nil {
+		return
+	}
+
 	err := consumer.payloadTrackerProducer.TrackPayload(requestID, timestamp, status)
 	if err != nil {
 		log.Warn().Msgf(`Unable to send "%s" update to Payload Tracker service`, status)
 	}
 }
 
+// updatePayloadTrackerError sends the error status of the payload together
+// with the processing error to Payload Tracker service when payload tracking
+// is enabled
+func (consumer *KafkaConsumer) updatePayloadTrackerError(requestID types.RequestID, timestamp time.Time, processingErr error) {
+	if consumer.payloadTrackerProducer == nil {
+		return
+	}
+
+	err := consumer.payloadTrackerProducer.TrackPayloadError(requestID, timestamp, processingErr)
+	if err != nil {
+		log.Warn().Msgf(`Unable to send "%s" update to Payload Tracker service`, producer.StatusError)
+	}
+}
+
 // checkMessageVersion - verifies incoming data's version is the expected one
 func checkMessageVersion(consumer *KafkaConsumer, message *incomingMessage, msg *sarama.ConsumerMessage) {
 	if message.Version != CurrentSchemaVersion {
@@ -132,24 +199,76 @@ func (consumer *KafkaConsumer) ProcessMessage(msg *sarama.ConsumerMessage) (type
 	tStart := time.Now()
 
 	log.Info().Int(offsetKey, int(msg.Offset)).Str(topicKey, consumer.Configuration.Topic).Str(groupKey, consumer.Configuration.Group).Msg("Consumed")
-	message, err := parseMessage(msg.Value)
+	messageValue, err := consumer.decompressMessageValue(msg.Value)
+	if err != nil {
+		logUnparsedMessageError(consumer, msg, "Error decompressing message from Kafka", err)
+		return "", err
+	}
+
+	parse := parseMessage
+	if consumer.isExternalResultsTopic(msg.Topic) {
+		parse = parseExternalResultsMessage
+	}
+
+	message, err := parse(messageValue)
 	if err != nil {
 		logUnparsedMessageError(consumer, msg, "Error parsing message from Kafka", err)
+		recordInvalidReport()
 		return message.RequestID, err
 	}
 
+	message.MessageKey, message.MessageKeyKind = parseMessageKey(msg.Key)
+
 	logMessageInfo(consumer, msg, message, "Read")
 	tRead := time.Now()
 
-	checkMessageVersion(consumer, &message, msg)
+	metrics.ConsumedMessagesByType.WithLabelValues(string(message.Type)).Inc()
+
+	if consumer.Configuration.NormalizeClusterNames {
+		// messages with cluster names that are not UUIDs are rejected by
+		// parsing already
+		clusterName := canonicalClusterName(*message.ClusterName)
+		if clusterName != *message.ClusterName {
+			logMessageInfo(consumer, msg, message, "Cluster name normalized to "+string(clusterName))
+			message.ClusterName = &clusterName
+		}
+	}
+
+	checkMessageKey(consumer, msg, message)
+
+	// external sources don't use versions of insights-operator reports
+	if message.Type != messageTypeExternalResults {
+		checkMessageVersion(consumer, &message, msg)
+	}
 
 	if ok, cause := checkMessageOrgInAllowList(consumer, &message, msg); !ok {
 		logMessageError(consumer, msg, message, cause, err)
 		return message.RequestID, errors.New(cause)
 	}
 
+	frozen, err := isOrgFrozen(consumer, msg, message)
+	if err != nil || frozen {
+		return message.RequestID, err
+	}
+
+	if throttled := checkOrgRate(consumer, msg, message); throttled {
+		return message.RequestID, nil
+	}
+
 	tAllowlisted := time.Now()
 
+	if message.Type == messageTypeExternalResults {
+		return message.RequestID, writeExternalResults(consumer, msg, message)
+	}
+
+	if message.Type == messageTypeRecommendationDeletion {
+		return message.RequestID, deleteClusterReport(consumer, msg, message)
+	}
+
+	if message.Status == types.ReportStatusFailed {
+		return message.RequestID, writeFailedReport(consumer, msg, message)
+	}
+
 	reportAsBytes, err := json.Marshal(*message.Report)
 	if err != nil {
 		logMessageError(consumer, msg, message, "Error marshalling report", err)
@@ -162,6 +281,7 @@ func (consumer *KafkaConsumer) ProcessMessage(msg *sarama.ConsumerMessage) (type
 	lastCheckedTime, err := time.Parse(time.RFC3339Nano, message.LastChecked)
 	if err != nil {
 		logMessageError(consumer, msg, message, "Error parsing date from message", err)
+		recordInvalidReport()
 		return message.RequestID, err
 	}
 
@@ -170,11 +290,30 @@ func (consumer *KafkaConsumer) ProcessMessage(msg *sarama.ConsumerMessage) (type
 		logMessageError(consumer, msg, message, "got a message from the future", nil)
 	}
 
-	metrics.LastCheckedTimestampLagMinutes.Observe(lastCheckedTimestampLagMinutes)
+	historical := consumer.Configuration.HistoricalReports
+
+	// lag of historical reports is expected, it would distort the metric
+	if !historical {
+		metrics.LastCheckedTimestampLagMinutes.Observe(lastCheckedTimestampLagMinutes)
+	}
 
 	logMessageInfo(consumer, msg, message, "Time ok")
 	tTimeCheck := time.Now()
 
+	if historical {
+		err = consumer.Storage.WriteReportHistory(
+			*message.Organization,
+			*message.ClusterName,
+			types.ClusterReport(reportAsBytes),
+			lastCheckedTime,
+			types.KafkaOffset(msg.Offset),
+		)
+		if err != nil {
+			logMessageError(consumer, msg, message, "Error writing report to history", err)
+			return message.RequestID, err
+		}
+	}
+
 	err = consumer.Storage.WriteReportForCluster(
 		*message.Organization,
 		*message.ClusterName,
@@ -184,8 +323,20 @@ func (consumer *KafkaConsumer) ProcessMessage(msg *sarama.ConsumerMessage) (type
 		types.KafkaOffset(msg.Offset),
 	)
 	if err != nil {
+		if err == types.ErrOldReport && historical {
+			logMessageInfo(consumer, msg, message, "Stored into history only, a more recent report exists for this cluster")
+			recordOrgUsage(consumer, msg, message, len(reportAsBytes))
+			return message.RequestID, nil
+		}
 		if err == types.ErrOldReport {
 			logMessageInfo(consumer, msg, message, "Skipping because a more recent report already exists for this cluster")
+			recordStaleReport(consumer, msg, message, lastCheckedTime)
+			recordOrgUsage(consumer, msg, message, 0)
+			return message.RequestID, nil
+		}
+		if err == types.ErrClusterOrgConflict {
+			logMessageInfo(consumer, msg, message, "Skipping because the cluster is stored under another organization")
+			recordOrgUsage(consumer, msg, message, 0)
 			return message.RequestID, nil
 		}
 
@@ -195,6 +346,11 @@ func (consumer *KafkaConsumer) ProcessMessage(msg *sarama.ConsumerMessage) (type
 	logMessageInfo(consumer, msg, message, "Stored")
 	tStored := time.Now()
 
+	writeReportMessageKey(consumer, msg, message, lastCheckedTime)
+	writeClusterClass(consumer, msg, message)
+
+	recordOrgUsage(consumer, msg, message, len(reportAsBytes))
+
 	// log durations for every message consumption steps
 	logDuration(tStart, tRead, msg.Offset, "read")
 	logDuration(tRead, tAllowlisted, msg.Offset, "org_filtering")
@@ -206,6 +362,198 @@ func (consumer *KafkaConsumer) ProcessMessage(msg *sarama.ConsumerMessage) (type
 	return message.RequestID, nil
 }
 
+// recordInvalidReport counts the report rejected by validation of the message,
+// results of writes of valid reports are counted by the storage
+func recordInvalidReport() {
+	metrics.WrittenReportsByResult.WithLabelValues(metrics.ReportWriteValidationFailed).Inc()
+}
+
+// writeFailedReport records that the analysis of the cluster failed
+func writeFailedReport(consumer *KafkaConsumer, msg *sarama.ConsumerMessage, message incomingMessage) error {
+	lastCheckedTime, err := time.Parse(time.RFC3339Nano, message.LastChecked)
+	if err != nil {
+		logMessageError(consumer, msg, message, "Error parsing date from message", err)
+		return err
+	}
+
+	err = consumer.Storage.WriteFailedReportForCluster(
+		*message.Organization,
+		*message.ClusterName,
+		lastCheckedTime,
+		types.KafkaOffset(msg.Offset),
+	)
+	if err == types.ErrOldReport {
+		logMessageInfo(consumer, msg, message, "Skipping because a more recent report already exists for this cluster")
+		recordStaleReport(consumer, msg, message, lastCheckedTime)
+		err = nil
+	} else if err == types.ErrClusterOrgConflict {
+		logMessageInfo(consumer, msg, message, "Skipping because the cluster is stored under another organization")
+		err = nil
+	} else if err != nil {
+		logMessageError(consumer, msg, message, "Error writing failed analysis to database", err)
+		return err
+	} else {
+		logMessageInfo(consumer, msg, message, "Stored failed analysis")
+		writeReportMessageKey(consumer, msg, message, lastCheckedTime)
+	}
+
+	recordOrgUsage(consumer, msg, message, 0)
+
+	return err
+}
+
+// writeExternalResults stores results of checks of the cluster done by the
+// external source, results of the source older than the stored ones are
+// skipped
+func writeExternalResults(consumer *KafkaConsumer, msg *sarama.ConsumerMessage, message incomingMessage) error {
+	lastCheckedTime, err := time.Parse(time.RFC3339Nano, message.LastChecked)
+	if err != nil {
+		logMessageError(consumer, msg, message, "Error parsing date from message", err)
+		return err
+	}
+
+	err = consumer.Storage.WriteExternalResults(
+		*message.Organization,
+		*message.ClusterName,
+		message.Source,
+		message.Results,
+		lastCheckedTime,
+		types.KafkaOffset(msg.Offset),
+	)
+	if err == types.ErrOldReport {
+		logMessageInfo(consumer, msg, message, "Skipping because more recent results of the source already exist for this cluster")
+		recordOrgUsage(consumer, msg, message, 0)
+		return nil
+	}
+	if err != nil {
+		logMessageError(consumer, msg, message, "Error writing external results to database", err)
+		return err
+	}
+
+	logMessageInfo(consumer, msg, message, "Stored external results")
+	recordOrgUsage(consumer, msg, message, len(msg.Value))
+
+	return nil
+}
+
+// deleteClusterReport deletes the report of the decommissioned cluster. Time
+// of the deletion is taken from LastChecked attribute when it is present,
+// timestamp of the Kafka message is used otherwise.
+func deleteClusterReport(consumer *KafkaConsumer, msg *sarama.ConsumerMessage, message incomingMessage) error {
+	deletedAt := msg.Timestamp
+	if message.LastChecked != "" {
+		lastCheckedTime, err := time.Parse(time.RFC3339Nano, message.LastChecked)
+		if err != nil {
+			logMessageError(consumer, msg, message, "Error parsing date from message", err)
+			return err
+		}
+		deletedAt = lastCheckedTime
+	}
+
+	err := consumer.Storage.DeleteClusterReport(*message.Organization, *message.ClusterName, deletedAt)
+	if _, notFound := err.(*types.ItemNotFoundError); notFound {
+		// the cluster never sent any report or it was deleted already
+		logMessageInfo(consumer, msg, message, "No report of decommissioned cluster to delete")
+		return nil
+	}
+	if err == types.ErrOldReport {
+		logMessageInfo(consumer, msg, message, "Skipping deletion because a more recent report exists for this cluster")
+		return nil
+	}
+	if err != nil {
+		logMessageError(consumer, msg, message, "Error deleting report of decommissioned cluster", err)
+		return err
+	}
+
+	logMessageInfo(consumer, msg, message, "Deleted report of decommissioned cluster")
+
+	return nil
+}
+
+// recordStaleReport updates metrics and statistics of reports rejected
+// because a more recent report of the cluster was already stored. Timestamp
+// of the Kafka message is stored too, so clusters with wrong clock can be
+// identified.
+func recordStaleReport(
+	consumer *KafkaConsumer, msg *sarama.ConsumerMessage, message incomingMessage, lastCheckedTime time.Time,
+) {
+	metrics.StaleReportWrites.WithLabelValues(
+		metrics.OrgLabel(uint64(*message.Organization)),
+	).Inc()
+
+	err := consumer.Storage.WriteStaleReport(
+		*message.Organization, *message.ClusterName, lastCheckedTime, msg.Timestamp,
+	)
+	if err != nil {
+		logMessageError(consumer, msg, message, "Unable to record stale report", err)
+	}
+}
+
+// recordOrgUsage adds the processed message and the size of the stored report
+// to the monthly usage of the organization
+func recordOrgUsage(
+	consumer *KafkaConsumer, msg *sarama.ConsumerMessage, message incomingMessage, bytesStored int,
+) {
+	err := consumer.Storage.AddOrgUsage(*message.Organization, 1, int64(bytesStored), 0)
+	if err != nil {
+		logMessageError(consumer, msg, message, "Unable to record organization usage", err)
+	}
+}
+
+// isOrgFrozen checks whether the organization was frozen by the
+// administrator, messages from frozen organizations are dropped
+func isOrgFrozen(consumer *KafkaConsumer, msg *sarama.ConsumerMessage, message incomingMessage) (bool, error) {
+	frozen, err := consumer.Storage.IsOrgFrozen(*message.Organization)
+	if err != nil {
+		logMessageError(consumer, msg, message, "Unable to check whether the organization is frozen", err)
+		return false, err
+	}
+
+	if frozen {
+		logMessageWarning(consumer, msg, message, "Organization is frozen, message dropped")
+		metrics.FrozenOrgDroppedMessages.WithLabelValues(
+			metrics.OrgLabel(uint64(*message.Organization)),
+		).Inc()
+	}
+
+	return frozen, nil
+}
+
+// checkOrgRate records the message into the message rate of the
+// organization and warns when the rate is anomalous. True is returned when
+// the message should be dropped, that is when the rate is anomalous and
+// throttling is enabled.
+func checkOrgRate(consumer *KafkaConsumer, msg *sarama.ConsumerMessage, message incomingMessage) bool {
+	if consumer.orgRates == nil {
+		return false
+	}
+
+	check := consumer.orgRates.record(*message.Organization)
+	if !check.Anomalous {
+		return false
+	}
+
+	orgLabel := metrics.OrgLabel(uint64(*message.Organization))
+
+	if check.Detected {
+		log.Warn().
+			Int(organizationKey, int(*message.Organization)).
+			Int("messages", check.Count).
+			Float64("baseline", check.Baseline).
+			Msg("Anomalous message rate of organization detected")
+		metrics.OrgRateAnomalies.WithLabelValues(orgLabel).Inc()
+	}
+
+	if !consumer.Configuration.OrgRateThrottle {
+		return false
+	}
+
+	logMessageWarning(consumer, msg, message, "Message rate of organization is anomalous, message dropped")
+	metrics.OrgRateThrottledMessages.WithLabelValues(orgLabel).Inc()
+
+	return true
+}
+
 // organizationAllowed checks whether the given organization is on allow list or not
 func organizationAllowed(consumer *KafkaConsumer, orgID types.OrgID) bool {
 	allowList := consumer.Configuration.OrgAllowlist
@@ -231,6 +579,22 @@ func checkReportStructure(r Report) error {
 	return nil
 }
 
+// normalizeClusterName checks that the cluster name is a valid UUID and
+// returns it in canonical form (lowercase, hyphenated)
+func normalizeClusterName(clusterName types.ClusterName) (types.ClusterName, error) {
+	if _, err := uuid.Parse(string(clusterName)); err != nil {
+		return clusterName, errors.New("cluster name is not a UUID")
+	}
+
+	return canonicalClusterName(clusterName), nil
+}
+
+// canonicalClusterName returns the cluster name already checked by
+// normalizeClusterName in canonical form
+func canonicalClusterName(clusterName types.ClusterName) types.ClusterName {
+	return types.ClusterName(uuid.MustParse(string(clusterName)).String())
+}
+
 // parseMessage tries to parse incoming message and read all required attributes from it
 func parseMessage(messageValue []byte) (incomingMessage, error) {
 	var deserialized incomingMessage
@@ -246,14 +610,34 @@ func parseMessage(messageValue []byte) (incomingMessage, error) {
 	if deserialized.ClusterName == nil {
 		return deserialized, errors.New("missing required attribute 'ClusterName'")
 	}
-	if deserialized.Report == nil {
-		return deserialized, errors.New("missing required attribute 'Report'")
+
+	_, err = normalizeClusterName(*deserialized.ClusterName)
+	if err != nil {
+		return deserialized, err
 	}
 
-	_, err = uuid.Parse(string(*deserialized.ClusterName))
+	switch deserialized.Type {
+	case "":
+		deserialized.Type = messageTypeRulesResults
+	case messageTypeRulesResults:
+	case messageTypeRecommendationDeletion:
+		// the tombstone contains no report
+		return deserialized, nil
+	default:
+		return deserialized, fmt.Errorf("unknown value of attribute 'Type': %v", deserialized.Type)
+	}
 
-	if err != nil {
-		return deserialized, errors.New("cluster name is not a UUID")
+	switch deserialized.Status {
+	case "", types.ReportStatusAnalyzed:
+	case types.ReportStatusFailed:
+		// there is no report to check when the analysis failed
+		return deserialized, nil
+	default:
+		return deserialized, fmt.Errorf("unknown value of attribute 'Status': %v", deserialized.Status)
+	}
+
+	if deserialized.Report == nil {
+		return deserialized, errors.New("missing required attribute 'Report'")
 	}
 
 	err = checkReportStructure(*deserialized.Report)
@@ -269,3 +653,62 @@ func parseMessage(messageValue []byte) (incomingMessage, error) {
 
 	return deserialized, nil
 }
+
+// parseExternalResultsMessage tries to parse message with results of checks
+// done by external source and checks that all required attributes are
+// present
+func parseExternalResultsMessage(messageValue []byte) (incomingMessage, error) {
+	var deserialized incomingMessage
+
+	err := json.Unmarshal(messageValue, &deserialized)
+	if err != nil {
+		return deserialized, err
+	}
+
+	if deserialized.Organization == nil {
+		return deserialized, errors.New("missing required attribute 'OrgID'")
+	}
+	if deserialized.ClusterName == nil {
+		return deserialized, errors.New("missing required attribute 'ClusterName'")
+	}
+
+	_, err = normalizeClusterName(*deserialized.ClusterName)
+	if err != nil {
+		return deserialized, err
+	}
+
+	switch deserialized.Type {
+	case "", messageTypeExternalResults:
+		deserialized.Type = messageTypeExternalResults
+	default:
+		return deserialized, fmt.Errorf("unknown value of attribute 'Type': %v", deserialized.Type)
+	}
+
+	if deserialized.Source == "" {
+		return deserialized, errors.New("missing required attribute 'Source'")
+	}
+	// empty list of results is valid, all checks passed
+	if deserialized.Results == nil {
+		return deserialized, errors.New("missing required attribute 'Results'")
+	}
+
+	for i, result := range deserialized.Results {
+		if result.CheckID == "" {
+			return deserialized, fmt.Errorf("missing required attribute 'check_id' of result %d", i)
+		}
+		if result.Severity < 1 || result.Severity > maxExternalResultSeverity {
+			return deserialized, fmt.Errorf(
+				"severity of result %s must be between 1 and %d, got %d",
+				result.CheckID, maxExternalResultSeverity, result.Severity,
+			)
+		}
+	}
+
+	return deserialized, nil
+}
+
+// isExternalResultsTopic returns true when the message was consumed from
+// the topic with external results
+func (consumer *KafkaConsumer) isExternalResultsTopic(topic string) bool {
+	return consumer.Configuration.ExternalResultsTopic != "" && topic == consumer.Configuration.ExternalResultsTopic
+}
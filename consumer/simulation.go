@@ -0,0 +1,158 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// IngestSimulator runs messages through the same parsing, validation and
+// report assembly as the consumer, but nothing is read from or written to
+// the storage. Checks that depend on the state of the service (frozen
+// organizations, rate limits, more recent reports) are not simulated.
+type IngestSimulator struct {
+	consumer *KafkaConsumer
+}
+
+// NewIngestSimulator constructs simulator processing messages the way the
+// consumer with the given broker configuration does, no connection to the
+// broker is made
+func NewIngestSimulator(brokerCfg broker.Configuration) *IngestSimulator {
+	return &IngestSimulator{
+		consumer: &KafkaConsumer{Configuration: brokerCfg},
+	}
+}
+
+// SimulateIngest processes the message value as if it was consumed from
+// the topic with results of the rules or, when external is true, from the
+// topic with external results and returns what would be stored together
+// with all validation errors
+func (simulator *IngestSimulator) SimulateIngest(messageValue []byte, external bool) types.IngestSimulation {
+	consumer := simulator.consumer
+
+	simulation := types.IngestSimulation{
+		Errors:   []string{},
+		Warnings: []string{},
+		RuleHits: []types.ReportItem{},
+	}
+
+	addError := func(format string, args ...interface{}) {
+		simulation.Errors = append(simulation.Errors, fmt.Sprintf(format, args...))
+	}
+	addWarning := func(format string, args ...interface{}) {
+		simulation.Warnings = append(simulation.Warnings, fmt.Sprintf(format, args...))
+	}
+
+	messageValue, err := consumer.decompressMessageValue(messageValue)
+	if err != nil {
+		addError("Error decompressing message: %v", err)
+		return simulation
+	}
+
+	parse := parseMessage
+	if external {
+		parse = parseExternalResultsMessage
+	}
+
+	message, err := parse(messageValue)
+	if message.Organization != nil {
+		simulation.OrgID = *message.Organization
+	}
+	if message.ClusterName != nil {
+		simulation.ClusterName = *message.ClusterName
+	}
+	if err != nil {
+		addError("Error parsing message: %v", err)
+		return simulation
+	}
+
+	simulation.Type = string(message.Type)
+
+	if consumer.Configuration.NormalizeClusterNames {
+		clusterName, err := normalizeClusterName(*message.ClusterName)
+		if err != nil {
+			addError("Error normalizing cluster name: %v", err)
+		} else if clusterName != *message.ClusterName {
+			addWarning("Cluster name normalized to %s", clusterName)
+			simulation.ClusterName = clusterName
+		}
+	}
+
+	// external sources don't use versions of insights-operator reports
+	if message.Type != messageTypeExternalResults && message.Version != CurrentSchemaVersion {
+		addWarning("Unexpected version %d of the data, expected %d", message.Version, CurrentSchemaVersion)
+	}
+
+	if consumer.Configuration.OrgAllowlistEnabled && !organizationAllowed(consumer, *message.Organization) {
+		addError("Organization ID is not in allow list")
+	}
+
+	switch {
+	case message.Type == messageTypeExternalResults:
+		simulation.ExternalResults = message.Results
+	case message.Type == messageTypeRecommendationDeletion:
+		addWarning("The report of the cluster would be deleted")
+	default:
+		simulation.Status = message.Status
+		if simulation.Status == "" {
+			simulation.Status = types.ReportStatusAnalyzed
+		}
+
+		simulateReportAssembly(&simulation, message, addError, addWarning)
+	}
+
+	simulation.Valid = len(simulation.Errors) == 0
+
+	return simulation
+}
+
+// simulateReportAssembly checks the time of the last check and assembles
+// the report with rule hits the same way as it's done before storing it
+func simulateReportAssembly(
+	simulation *types.IngestSimulation,
+	message incomingMessage,
+	addError, addWarning func(format string, args ...interface{}),
+) {
+	lastCheckedTime, err := time.Parse(time.RFC3339Nano, message.LastChecked)
+	if err != nil {
+		addError("Error parsing date from message: %v", err)
+	} else {
+		simulation.LastCheckedAt = types.Timestamp(lastCheckedTime.UTC().Format(time.RFC3339))
+		if lastCheckedTime.After(time.Now()) {
+			addWarning("The report is from the future")
+		}
+	}
+
+	// the failed analysis contains no report
+	if message.Status == types.ReportStatusFailed {
+		return
+	}
+
+	reportAsBytes, err := json.Marshal(*message.Report)
+	if err != nil {
+		addError("Error marshalling report: %v", err)
+		return
+	}
+
+	simulation.ReportSize = len(reportAsBytes)
+	if message.ParsedHits != nil {
+		simulation.RuleHits = message.ParsedHits
+	}
+}
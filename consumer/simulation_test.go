@@ -0,0 +1,132 @@
+/*
+Copyright © 2020, 2021 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package consumer_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	mapset "github.com/deckarep/golang-set"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/consumer"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// reportWithOneHit is the report with one rule hit
+const reportWithOneHit = `{
+	"fingerprints": [],
+	"info": [],
+	"skips": [],
+	"system": {},
+	"reports": [
+		{"component": "ccx_rules_ocp.external.rules.nodes_kubelet_version_check.report", "key": "NODE_KUBELET_VERSION", "details": {}}
+	]
+}`
+
+// simulatedMessage returns message with the given cluster name and report
+func simulatedMessage(clusterName, report string) []byte {
+	return []byte(`{
+		"OrgID": ` + fmt.Sprint(testdata.OrgID) + `,
+		"ClusterName": "` + clusterName + `",
+		"Report": ` + report + `,
+		"LastChecked": "` + testdata.LastCheckedAt.Format(time.RFC3339) + `",
+		"Version": ` + fmt.Sprint(consumer.CurrentSchemaVersion) + `
+	}`)
+}
+
+func TestIngestSimulator_SimulateIngest(t *testing.T) {
+	simulator := consumer.NewIngestSimulator(broker.Configuration{})
+
+	simulation := simulator.SimulateIngest(simulatedMessage(string(testdata.ClusterName), reportWithOneHit), false)
+
+	assert.True(t, simulation.Valid)
+	assert.Empty(t, simulation.Errors)
+	assert.Equal(t, "rules_results", simulation.Type)
+	assert.Equal(t, testdata.OrgID, simulation.OrgID)
+	assert.Equal(t, testdata.ClusterName, simulation.ClusterName)
+	assert.Equal(t, types.ReportStatusAnalyzed, simulation.Status)
+	assert.Equal(t, types.Timestamp(testdata.LastCheckedAt.UTC().Format(time.RFC3339)), simulation.LastCheckedAt)
+	assert.NotEqual(t, 0, simulation.ReportSize)
+	if assert.Len(t, simulation.RuleHits, 1) {
+		assert.Equal(t, types.ErrorKey("NODE_KUBELET_VERSION"), simulation.RuleHits[0].ErrorKey)
+	}
+}
+
+func TestIngestSimulator_SimulateIngest_ParseError(t *testing.T) {
+	simulator := consumer.NewIngestSimulator(broker.Configuration{})
+
+	simulation := simulator.SimulateIngest(simulatedMessage(string(testdata.ClusterName), `{"reports": []}`), false)
+
+	assert.False(t, simulation.Valid)
+	assert.Equal(t, []string{"Error parsing message: Improper report structure, missing key fingerprints"}, simulation.Errors)
+	assert.Equal(t, testdata.ClusterName, simulation.ClusterName)
+	assert.Empty(t, simulation.RuleHits)
+}
+
+func TestIngestSimulator_SimulateIngest_ValidationErrors(t *testing.T) {
+	simulator := consumer.NewIngestSimulator(broker.Configuration{
+		OrgAllowlistEnabled: true,
+		OrgAllowlist:        mapset.NewSetWith(types.OrgID(123)),
+	})
+
+	message := strings.Replace(
+		string(simulatedMessage(string(testdata.ClusterName), reportWithOneHit)),
+		testdata.LastCheckedAt.Format(time.RFC3339), "yesterday", 1,
+	)
+
+	simulation := simulator.SimulateIngest([]byte(message), false)
+
+	assert.False(t, simulation.Valid)
+	if assert.Len(t, simulation.Errors, 2) {
+		assert.Equal(t, "Organization ID is not in allow list", simulation.Errors[0])
+		assert.Contains(t, simulation.Errors[1], "Error parsing date from message")
+	}
+	// rule hits are returned even when the message is not valid
+	assert.Len(t, simulation.RuleHits, 1)
+}
+
+func TestIngestSimulator_SimulateIngest_NormalizeClusterName(t *testing.T) {
+	simulator := consumer.NewIngestSimulator(broker.Configuration{NormalizeClusterNames: true})
+
+	simulation := simulator.SimulateIngest(
+		simulatedMessage(strings.ToUpper(string(testdata.ClusterName)), reportWithOneHit), false,
+	)
+
+	assert.True(t, simulation.Valid)
+	assert.Equal(t, testdata.ClusterName, simulation.ClusterName)
+	assert.Equal(t, []string{"Cluster name normalized to " + string(testdata.ClusterName)}, simulation.Warnings)
+}
+
+func TestIngestSimulator_SimulateIngest_ExternalResults(t *testing.T) {
+	simulator := consumer.NewIngestSimulator(broker.Configuration{})
+
+	simulation := simulator.SimulateIngest([]byte(externalResultsMessage(`[
+		{"check_id": "CVE-2020-1234", "severity": 3, "description": "vulnerable image"}
+	]`)), true)
+
+	assert.True(t, simulation.Valid)
+	assert.Equal(t, "external_results", simulation.Type)
+	assert.Empty(t, simulation.RuleHits)
+	if assert.Len(t, simulation.ExternalResults, 1) {
+		assert.Equal(t, "CVE-2020-1234", simulation.ExternalResults[0].CheckID)
+	}
+}
@@ -0,0 +1,107 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package events
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultAsyncQueueSize is the number of events waiting to be published used
+// when no size is specified
+const DefaultAsyncQueueSize = 1000
+
+// ErrPublishQueueFull is returned when the event can't be queued, because
+// the publisher doesn't keep up with the events
+var ErrPublishQueueFull = errors.New("queue of events to publish is full")
+
+// ErrPublisherClosed is returned when the event is published after the
+// publisher was closed
+var ErrPublisherClosed = errors.New("publisher is closed")
+
+// AsyncPublisher publishes events to the wrapped publisher (Kafka topic,
+// webhooks) in the background, so slow destinations don't delay the code
+// publishing the events. Events are published in order by one goroutine,
+// events that don't fit into the queue are dropped.
+type AsyncPublisher struct {
+	publisher Publisher
+	queue     chan RuleToggleEvent
+	done      chan struct{}
+	mutex     sync.RWMutex
+	closed    bool
+}
+
+// NewAsyncPublisher constructs publisher queueing at most queueSize events
+// and starts its goroutine, Close has to be called to stop it
+func NewAsyncPublisher(publisher Publisher, queueSize int) *AsyncPublisher {
+	if queueSize <= 0 {
+		queueSize = DefaultAsyncQueueSize
+	}
+
+	async := &AsyncPublisher{
+		publisher: publisher,
+		queue:     make(chan RuleToggleEvent, queueSize),
+		done:      make(chan struct{}),
+	}
+
+	go async.run()
+
+	return async
+}
+
+// run publishes queued events until the queue is closed
+func (async *AsyncPublisher) run() {
+	defer close(async.done)
+
+	for event := range async.queue {
+		if err := async.publisher.PublishRuleToggle(event); err != nil {
+			log.Error().Err(err).Msg("Unable to publish rule toggle event")
+		}
+	}
+}
+
+// PublishRuleToggle queues the event, it doesn't wait until it's published
+func (async *AsyncPublisher) PublishRuleToggle(event RuleToggleEvent) error {
+	async.mutex.RLock()
+	defer async.mutex.RUnlock()
+
+	if async.closed {
+		return ErrPublisherClosed
+	}
+
+	select {
+	case async.queue <- event:
+		return nil
+	default:
+		return ErrPublishQueueFull
+	}
+}
+
+// Close stops accepting new events and waits until all queued events are
+// published
+func (async *AsyncPublisher) Close() {
+	async.mutex.Lock()
+	if !async.closed {
+		async.closed = true
+		close(async.queue)
+	}
+	async.mutex.Unlock()
+
+	<-async.done
+}
@@ -0,0 +1,75 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package events_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/events"
+)
+
+// blockingPublisher records events, publishing waits until it's released
+type blockingPublisher struct {
+	release chan struct{}
+	events  []events.RuleToggleEvent
+}
+
+func (publisher *blockingPublisher) PublishRuleToggle(event events.RuleToggleEvent) error {
+	<-publisher.release
+	publisher.events = append(publisher.events, event)
+	return errors.New("webhook is down")
+}
+
+func TestAsyncPublisher(t *testing.T) {
+	publisher := &blockingPublisher{release: make(chan struct{})}
+	async := events.NewAsyncPublisher(publisher, 2)
+
+	// events are queued without waiting for the publisher
+	first := testEvent
+	second := testEvent
+	second.Disabled = false
+	helpers.FailOnError(t, async.PublishRuleToggle(first))
+	helpers.FailOnError(t, async.PublishRuleToggle(second))
+
+	close(publisher.release)
+	async.Close()
+
+	// errors of the publisher are only logged
+	assert.Equal(t, []events.RuleToggleEvent{first, second}, publisher.events)
+	assert.Equal(t, events.ErrPublisherClosed, async.PublishRuleToggle(testEvent))
+
+	// closing twice doesn't panic
+	async.Close()
+}
+
+func TestAsyncPublisher_QueueFull(t *testing.T) {
+	publisher := &blockingPublisher{release: make(chan struct{})}
+	async := events.NewAsyncPublisher(publisher, 1)
+
+	var err error
+	for i := 0; i < 3 && err == nil; i++ {
+		err = async.PublishRuleToggle(testEvent)
+	}
+	assert.Equal(t, events.ErrPublishQueueFull, err)
+
+	close(publisher.release)
+	async.Close()
+}
@@ -0,0 +1,195 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// ReportWrittenEvent is published when a new report of the cluster is
+// stored
+type ReportWrittenEvent struct {
+	OrgID       types.OrgID
+	ClusterName types.ClusterName
+	LastChecked time.Time
+	RuleHits    int
+}
+
+// ConsumerErrorEvent is published when a message consumed from Kafka can't
+// be processed
+type ConsumerErrorEvent struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Err       error
+}
+
+// ClusterOrgConflictEvent is published when a report of the cluster is
+// received under another organization than its stored report (the cluster
+// was re-registered, for example). Resolution is the conflict policy applied
+// by the storage: the report was rejected, or the cluster was moved into the
+// new organization (and its previous report was kept in history).
+type ClusterOrgConflictEvent struct {
+	ClusterName   types.ClusterName
+	PreviousOrgID types.OrgID
+	OrgID         types.OrgID
+	DetectedAt    time.Time
+	Resolution    string
+}
+
+// subscription is a handler of events of one type registered in the bus
+type subscription struct {
+	id      int
+	handler interface{}
+}
+
+// Bus is an in-process event bus. Modules subscribe to events they are
+// interested in instead of being called directly by the code producing the
+// events. Handlers are called synchronously in the order of subscription, so
+// they should be fast and must handle their errors themselves.
+type Bus struct {
+	mutex         sync.RWMutex
+	lastID        int
+	subscriptions map[string][]subscription
+}
+
+// event types used as keys of Bus.subscriptions
+const (
+	reportWrittenEventType      = "ReportWritten"
+	ruleToggledEventType        = "RuleToggled"
+	consumerErrorEventType      = "ConsumerError"
+	clusterOrgConflictEventType = "ClusterOrgConflict"
+)
+
+// DefaultBus is the event bus shared by all modules of the service
+var DefaultBus = NewBus()
+
+// NewBus constructs an event bus without subscribers
+func NewBus() *Bus {
+	return &Bus{
+		subscriptions: map[string][]subscription{},
+	}
+}
+
+// subscribe registers the handler of the event type and returns the function
+// that unsubscribes it
+func (bus *Bus) subscribe(eventType string, handler interface{}) func() {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	bus.lastID++
+	id := bus.lastID
+	bus.subscriptions[eventType] = append(bus.subscriptions[eventType], subscription{id: id, handler: handler})
+
+	return func() {
+		bus.mutex.Lock()
+		defer bus.mutex.Unlock()
+
+		subscriptions := bus.subscriptions[eventType]
+		for i := range subscriptions {
+			if subscriptions[i].id == id {
+				bus.subscriptions[eventType] = append(subscriptions[:i:i], subscriptions[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// handlers returns handlers of the event type in the order of subscription
+func (bus *Bus) handlers(eventType string) []interface{} {
+	bus.mutex.RLock()
+	defer bus.mutex.RUnlock()
+
+	handlers := make([]interface{}, 0, len(bus.subscriptions[eventType]))
+	for _, subscription := range bus.subscriptions[eventType] {
+		handlers = append(handlers, subscription.handler)
+	}
+
+	return handlers
+}
+
+// SubscribeReportWritten registers handler of ReportWrittenEvent, the
+// returned function unsubscribes it
+func (bus *Bus) SubscribeReportWritten(handler func(ReportWrittenEvent)) func() {
+	return bus.subscribe(reportWrittenEventType, handler)
+}
+
+// PublishReportWritten delivers the event to all its subscribers
+func (bus *Bus) PublishReportWritten(event ReportWrittenEvent) {
+	for _, handler := range bus.handlers(reportWrittenEventType) {
+		handler.(func(ReportWrittenEvent))(event)
+	}
+}
+
+// SubscribeRuleToggled registers handler of RuleToggleEvent, the returned
+// function unsubscribes it
+func (bus *Bus) SubscribeRuleToggled(handler func(RuleToggleEvent)) func() {
+	return bus.subscribe(ruleToggledEventType, handler)
+}
+
+// PublishRuleToggle delivers the event to all its subscribers. Bus
+// implements Publisher interface, errors are handled by the subscribers, so
+// nil is always returned.
+func (bus *Bus) PublishRuleToggle(event RuleToggleEvent) error {
+	for _, handler := range bus.handlers(ruleToggledEventType) {
+		handler.(func(RuleToggleEvent))(event)
+	}
+
+	return nil
+}
+
+// SubscribeConsumerError registers handler of ConsumerErrorEvent, the
+// returned function unsubscribes it
+func (bus *Bus) SubscribeConsumerError(handler func(ConsumerErrorEvent)) func() {
+	return bus.subscribe(consumerErrorEventType, handler)
+}
+
+// PublishConsumerError delivers the event to all its subscribers
+func (bus *Bus) PublishConsumerError(event ConsumerErrorEvent) {
+	for _, handler := range bus.handlers(consumerErrorEventType) {
+		handler.(func(ConsumerErrorEvent))(event)
+	}
+}
+
+// SubscribeClusterOrgConflict registers handler of ClusterOrgConflictEvent,
+// the returned function unsubscribes it
+func (bus *Bus) SubscribeClusterOrgConflict(handler func(ClusterOrgConflictEvent)) func() {
+	return bus.subscribe(clusterOrgConflictEventType, handler)
+}
+
+// PublishClusterOrgConflict delivers the event to all its subscribers
+func (bus *Bus) PublishClusterOrgConflict(event ClusterOrgConflictEvent) {
+	for _, handler := range bus.handlers(clusterOrgConflictEventType) {
+		handler.(func(ClusterOrgConflictEvent))(event)
+	}
+}
+
+// SubscribePublisher forwards rule toggle events from the bus to the
+// publisher (Kafka topic, webhooks), errors are only logged. The returned
+// function unsubscribes the publisher.
+func (bus *Bus) SubscribePublisher(publisher Publisher) func() {
+	return bus.SubscribeRuleToggled(func(event RuleToggleEvent) {
+		if err := publisher.PublishRuleToggle(event); err != nil {
+			log.Error().Err(err).Msg("Unable to publish rule toggle event")
+		}
+	})
+}
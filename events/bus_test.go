@@ -0,0 +1,108 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package events_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/events"
+)
+
+func TestBus_SubscribersCalledInOrder(t *testing.T) {
+	bus := events.NewBus()
+
+	var calls []string
+	bus.SubscribeReportWritten(func(event events.ReportWrittenEvent) {
+		assert.Equal(t, testdata.ClusterName, event.ClusterName)
+		calls = append(calls, "first")
+	})
+	bus.SubscribeReportWritten(func(events.ReportWrittenEvent) {
+		calls = append(calls, "second")
+	})
+
+	bus.PublishReportWritten(events.ReportWrittenEvent{
+		OrgID:       testdata.OrgID,
+		ClusterName: testdata.ClusterName,
+	})
+
+	assert.Equal(t, []string{"first", "second"}, calls)
+}
+
+func TestBus_EventTypesAreSeparated(t *testing.T) {
+	bus := events.NewBus()
+
+	var consumerErrors []events.ConsumerErrorEvent
+	bus.SubscribeConsumerError(func(event events.ConsumerErrorEvent) {
+		consumerErrors = append(consumerErrors, event)
+	})
+
+	bus.PublishReportWritten(events.ReportWrittenEvent{})
+	helpers.FailOnError(t, bus.PublishRuleToggle(testEvent))
+	assert.Empty(t, consumerErrors)
+
+	consumerErr := errors.New("unable to parse message")
+	bus.PublishConsumerError(events.ConsumerErrorEvent{Topic: "topic", Offset: 42, Err: consumerErr})
+	assert.Equal(t, []events.ConsumerErrorEvent{
+		{Topic: "topic", Offset: 42, Err: consumerErr},
+	}, consumerErrors)
+}
+
+func TestBus_Unsubscribe(t *testing.T) {
+	bus := events.NewBus()
+
+	var first, second int
+	unsubscribe := bus.SubscribeRuleToggled(func(events.RuleToggleEvent) {
+		first++
+	})
+	bus.SubscribeRuleToggled(func(events.RuleToggleEvent) {
+		second++
+	})
+
+	helpers.FailOnError(t, bus.PublishRuleToggle(testEvent))
+	unsubscribe()
+	// unsubscribing twice doesn't remove other subscribers
+	unsubscribe()
+	helpers.FailOnError(t, bus.PublishRuleToggle(testEvent))
+
+	assert.Equal(t, 1, first)
+	assert.Equal(t, 2, second)
+}
+
+func TestBus_SubscribePublisher(t *testing.T) {
+	bus := events.NewBus()
+
+	failing := &publisherMock{err: errors.New("kafka is not available")}
+	publisher := &publisherMock{}
+	bus.SubscribePublisher(failing)
+	unsubscribe := bus.SubscribePublisher(publisher)
+
+	// errors of publishers are handled by the subscription
+	helpers.FailOnError(t, bus.PublishRuleToggle(testEvent))
+
+	assert.Equal(t, 1, failing.calls)
+	assert.Equal(t, 1, publisher.calls)
+
+	unsubscribe()
+	helpers.FailOnError(t, bus.PublishRuleToggle(testEvent))
+	assert.Equal(t, 2, failing.calls)
+	assert.Equal(t, 1, publisher.calls)
+}
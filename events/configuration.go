@@ -0,0 +1,26 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package events
+
+import "time"
+
+// Configuration represents configuration of event publishing. Kafka topic
+// used for events is part of broker configuration.
+type Configuration struct {
+	WebhookURLs    []string      `mapstructure:"webhook_urls" toml:"webhook_urls"`
+	WebhookTimeout time.Duration `mapstructure:"webhook_timeout" toml:"webhook_timeout"`
+}
@@ -0,0 +1,64 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package events contains events published by the aggregator when some state
+// changes, so other services (notification, remediation) can react to the
+// change immediately instead of polling the REST API. Events can be published
+// into Kafka topic (see producer package) and/or sent to HTTP webhooks.
+//
+// Events are delivered to the modules of the service by the in-process event
+// bus (see Bus), so the code producing the events doesn't need to know who is
+// interested in them.
+package events
+
+import (
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// RuleToggleEvent is published when a rule is disabled or enabled for
+// a cluster
+type RuleToggleEvent struct {
+	OrgID     types.OrgID       `json:"org_id"`
+	ClusterID types.ClusterName `json:"cluster_id"`
+	RuleID    types.RuleID      `json:"rule_id"`
+	ErrorKey  types.ErrorKey    `json:"error_key"`
+	Disabled  bool              `json:"disabled"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// Publisher represents any destination rule toggle events can be published to
+type Publisher interface {
+	PublishRuleToggle(event RuleToggleEvent) error
+}
+
+// Publishers publishes every event to all contained publishers. All
+// publishers are always tried, the first error is returned.
+type Publishers []Publisher
+
+// PublishRuleToggle publishes the event to all publishers
+func (publishers Publishers) PublishRuleToggle(event RuleToggleEvent) error {
+	var firstErr error
+
+	for _, publisher := range publishers {
+		if err := publisher.PublishRuleToggle(event); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
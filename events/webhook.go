@@ -0,0 +1,97 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// defaultWebhookTimeout is used when no timeout is configured
+const defaultWebhookTimeout = 10 * time.Second
+
+// WebhookPublisher sends events as JSON in body of HTTP POST requests to
+// configured URLs
+type WebhookPublisher struct {
+	URLs   []string
+	Client *http.Client
+}
+
+// NewWebhookPublisher constructs publisher sending events to the given URLs
+func NewWebhookPublisher(urls []string, timeout time.Duration) *WebhookPublisher {
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+
+	return &WebhookPublisher{
+		URLs:   urls,
+		Client: &http.Client{Timeout: timeout},
+	}
+}
+
+// PublishRuleToggle sends the event to all webhooks. All webhooks are always
+// tried, the first error is returned.
+func (publisher *WebhookPublisher) PublishRuleToggle(event RuleToggleEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	var firstErr error
+
+	for _, url := range publisher.URLs {
+		err := publisher.post(url, body)
+		if err != nil {
+			log.Error().Err(err).Str("url", url).Msg("Unable to send event to webhook")
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	return firstErr
+}
+
+// post sends the body to one webhook and checks the response status
+func (publisher *WebhookPublisher) post(url string, body []byte) error {
+	// #nosec G107
+	response, err := publisher.Client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		// read the rest of body so the connection can be reused
+		_, _ = io.Copy(ioutil.Discard, response.Body)
+		if err := response.Body.Close(); err != nil {
+			log.Error().Err(err).Msg("Unable to close webhook response body")
+		}
+	}()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s responded with status %d", url, response.StatusCode)
+	}
+
+	return nil
+}
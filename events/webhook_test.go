@@ -0,0 +1,117 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package events_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/events"
+)
+
+var testEvent = events.RuleToggleEvent{
+	OrgID:     testdata.OrgID,
+	ClusterID: testdata.ClusterName,
+	RuleID:    testdata.Rule1ID,
+	ErrorKey:  testdata.ErrorKey1,
+	Disabled:  true,
+	Timestamp: time.Date(2020, 10, 16, 10, 1, 2, 0, time.UTC),
+}
+
+func TestWebhookPublisher(t *testing.T) {
+	var received []events.RuleToggleEvent
+
+	webhook := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		assert.Equal(t, http.MethodPost, request.Method)
+		assert.Equal(t, "application/json", request.Header.Get("Content-Type"))
+
+		var event events.RuleToggleEvent
+		helpers.FailOnError(t, json.NewDecoder(request.Body).Decode(&event))
+		received = append(received, event)
+	}))
+	defer webhook.Close()
+
+	publisher := events.NewWebhookPublisher([]string{webhook.URL, webhook.URL + "/second"}, time.Second)
+
+	err := publisher.PublishRuleToggle(testEvent)
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, []events.RuleToggleEvent{testEvent, testEvent}, received)
+}
+
+func TestWebhookPublisherErrorStatus(t *testing.T) {
+	calls := 0
+
+	webhook := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		calls++
+		if request.URL.Path == "/failing" {
+			writer.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer webhook.Close()
+
+	publisher := events.NewWebhookPublisher([]string{webhook.URL + "/failing", webhook.URL}, 0)
+
+	err := publisher.PublishRuleToggle(testEvent)
+	assert.EqualError(t, err, fmt.Sprintf("webhook %s/failing responded with status 503", webhook.URL))
+
+	// the second webhook is called anyway
+	assert.Equal(t, 2, calls)
+}
+
+func TestWebhookPublisherUnreachable(t *testing.T) {
+	webhook := httptest.NewServer(http.NotFoundHandler())
+	webhook.Close()
+
+	publisher := events.NewWebhookPublisher([]string{webhook.URL}, time.Second)
+
+	assert.Error(t, publisher.PublishRuleToggle(testEvent))
+}
+
+type publisherMock struct {
+	calls int
+	err   error
+}
+
+func (publisher *publisherMock) PublishRuleToggle(events.RuleToggleEvent) error {
+	publisher.calls++
+	return publisher.err
+}
+
+func TestPublishers(t *testing.T) {
+	first := &publisherMock{err: errors.New("first error")}
+	second := &publisherMock{err: errors.New("second error")}
+	third := &publisherMock{}
+
+	err := events.Publishers{first, second, third}.PublishRuleToggle(testEvent)
+	assert.EqualError(t, err, "first error")
+
+	assert.Equal(t, 1, first.calls)
+	assert.Equal(t, 1, second.calls)
+	assert.Equal(t, 1, third.calls)
+
+	helpers.FailOnError(t, events.Publishers{third}.PublishRuleToggle(testEvent))
+}
@@ -0,0 +1,43 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package export contains functions that export reports and rule hits
+// stored in the database into Parquet files that can be consumed by
+// analytics pipelines. The files are written either into a local directory
+// or uploaded into S3 bucket.
+package export
+
+// Configuration represents configuration of the Parquet export
+type Configuration struct {
+	// Path is a local directory the files are written into. It is used as
+	// a temporary directory when the files are uploaded into S3.
+	Path         string `mapstructure:"path" toml:"path"`
+	RowGroupSize int    `mapstructure:"row_group_size" toml:"row_group_size"`
+	S3Bucket     string `mapstructure:"s3_bucket" toml:"s3_bucket"`
+	S3Prefix     string `mapstructure:"s3_prefix" toml:"s3_prefix"`
+	S3Region     string `mapstructure:"s3_region" toml:"s3_region"`
+	S3Endpoint   string `mapstructure:"s3_endpoint" toml:"s3_endpoint"`
+	AWSAccessID  string `mapstructure:"aws_access_id" toml:"aws_access_id"`
+	AWSSecretKey string `mapstructure:"aws_secret_key" toml:"aws_secret_key"`
+	// Canonical enables canonical JSON form (sorted keys, rules sorted by
+	// module and error key) of the exported reports and template data
+	Canonical bool `mapstructure:"canonical" toml:"canonical"`
+}
+
+// s3Enabled returns true when the exported files should be uploaded into S3
+func (configuration Configuration) s3Enabled() bool {
+	return configuration.S3Bucket != ""
+}
@@ -0,0 +1,210 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const (
+	// defaultRowGroupSize is used when row group size is not configured
+	defaultRowGroupSize = 10000
+
+	// fileTimestampFormat is used in names of exported files
+	fileTimestampFormat = "20060102T150405Z"
+
+	reportsFilePrefix  = "reports"
+	ruleHitsFilePrefix = "rule_hits"
+	parquetFileSuffix  = ".parquet"
+)
+
+var reportColumns = []parquetColumn{
+	int64Column("org_id"),
+	stringColumn("cluster_id"),
+	stringColumn("report"),
+	timestampColumn("reported_at"),
+	timestampColumn("last_checked_at"),
+	int64Column("kafka_offset"),
+}
+
+var ruleHitColumns = []parquetColumn{
+	int64Column("org_id"),
+	stringColumn("cluster_id"),
+	stringColumn("rule_fqdn"),
+	stringColumn("error_key"),
+	stringColumn("template_data"),
+}
+
+// ToParquet exports all reports and rule hits from the storage into Parquet
+// files. The files are written into configured directory or uploaded into
+// S3 bucket when it is configured. Local paths or S3 keys of the exported
+// files are returned.
+func ToParquet(configuration Configuration, dbStorage storage.Storage) ([]string, error) {
+	return toParquet(configuration, dbStorage, time.Now())
+}
+
+func toParquet(configuration Configuration, dbStorage storage.Storage, now time.Time) ([]string, error) {
+	directory := configuration.Path
+	if configuration.s3Enabled() {
+		tmpDirectory, err := ioutil.TempDir(configuration.Path, "export")
+		if err != nil {
+			return nil, err
+		}
+		defer removeDirectory(tmpDirectory)
+
+		directory = tmpDirectory
+	} else if directory != "" {
+		if err := os.MkdirAll(directory, 0750); err != nil {
+			return nil, err
+		}
+	}
+
+	timestamp := now.UTC().Format(fileTimestampFormat)
+	reportsFile := filepath.Join(directory, reportsFilePrefix+"-"+timestamp+parquetFileSuffix)
+	ruleHitsFile := filepath.Join(directory, ruleHitsFilePrefix+"-"+timestamp+parquetFileSuffix)
+
+	err := writeParquetFile(reportsFile, reportColumns, configuration, func(writer *parquetWriter) error {
+		return dbStorage.IterateReports(func(record storage.ReportRecord) error {
+			report, err := exportedJSON(configuration, string(record.Report))
+			if err != nil {
+				return err
+			}
+
+			return writer.WriteRow(
+				int64(record.OrgID),
+				string(record.ClusterName),
+				report,
+				nullTimeValue(record.ReportedAt),
+				nullTimeValue(record.LastCheckedAt),
+				int64(record.KafkaOffset),
+			)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeParquetFile(ruleHitsFile, ruleHitColumns, configuration, func(writer *parquetWriter) error {
+		return dbStorage.IterateRuleHits(func(record storage.RuleHitRecord) error {
+			templateData, err := exportedJSON(configuration, record.TemplateData)
+			if err != nil {
+				return err
+			}
+
+			return writer.WriteRow(
+				int64(record.OrgID),
+				string(record.ClusterName),
+				string(record.RuleFQDN),
+				string(record.ErrorKey),
+				templateData,
+			)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{reportsFile, ruleHitsFile}
+
+	if configuration.s3Enabled() {
+		return uploadToS3(configuration, files)
+	}
+
+	return files, nil
+}
+
+// writeParquetFile creates new Parquet file and fills it with rows written
+// by the fill function
+func writeParquetFile(
+	fileName string,
+	columns []parquetColumn,
+	configuration Configuration,
+	fill func(*parquetWriter) error,
+) error {
+	rowGroupSize := configuration.RowGroupSize
+	if rowGroupSize == 0 {
+		rowGroupSize = defaultRowGroupSize
+	}
+
+	file, err := os.Create(filepath.Clean(fileName))
+	if err != nil {
+		return err
+	}
+
+	writer, err := newParquetWriter(file, columns, rowGroupSize)
+	if err == nil {
+		err = fill(writer)
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		// don't leave incomplete files behind
+		if removeErr := os.Remove(fileName); removeErr != nil {
+			log.Error().Err(removeErr).Str("file", fileName).Msg("Unable to remove incomplete export file")
+		}
+		return err
+	}
+
+	log.Info().Str("file", fileName).Msg("Parquet file has been written")
+
+	return nil
+}
+
+// exportedJSON returns the JSON document in the form it is exported in,
+// canonical form is used when it is configured
+func exportedJSON(configuration Configuration, document string) (string, error) {
+	if !configuration.Canonical || document == "" {
+		return document, nil
+	}
+
+	canonical, err := types.CanonicalJSON([]byte(document))
+	if err != nil {
+		return "", err
+	}
+
+	return string(canonical), nil
+}
+
+// nullTimeValue converts nullable timestamp into value accepted by
+// parquetWriter
+func nullTimeValue(value sql.NullTime) interface{} {
+	if !value.Valid {
+		return nil
+	}
+	return value.Time
+}
+
+func removeDirectory(directory string) {
+	if err := os.RemoveAll(directory); err != nil {
+		log.Error().Err(err).Str("directory", directory).Msg("Unable to remove temporary export directory")
+	}
+}
@@ -0,0 +1,469 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+// This file contains a minimal Parquet writer. It supports just what the
+// export needs: flat schemas with REQUIRED or OPTIONAL columns of INT64 and
+// BYTE_ARRAY (UTF8) physical types, PLAIN encoding and no compression. Each
+// row group contains exactly one data page per column.
+//
+// File format specification: https://github.com/apache/parquet-format
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	parquetMagic     = "PAR1"
+	parquetCreatedBy = "insights-results-aggregator"
+
+	// physical types
+	parquetTypeInt64     = 2
+	parquetTypeByteArray = 6
+
+	// converted types
+	parquetConvertedTypeNone            = -1
+	parquetConvertedTypeUTF8            = 0
+	parquetConvertedTypeTimestampMillis = 9
+
+	// repetition types
+	parquetRepetitionRequired = 0
+	parquetRepetitionOptional = 1
+
+	parquetEncodingPlain = 0
+	parquetEncodingRLE   = 3
+
+	parquetCodecUncompressed = 0
+	parquetPageTypeData      = 0
+
+	// thrift compact protocol types
+	thriftTypeI32    = 5
+	thriftTypeI64    = 6
+	thriftTypeBinary = 8
+	thriftTypeList   = 9
+	thriftTypeStruct = 12
+)
+
+// parquetColumn describes one column of the flat Parquet schema
+type parquetColumn struct {
+	name          string
+	physicalType  int32
+	convertedType int32
+	optional      bool
+}
+
+// int64Column returns description of INT64 column
+func int64Column(name string) parquetColumn {
+	return parquetColumn{name, parquetTypeInt64, parquetConvertedTypeNone, false}
+}
+
+// stringColumn returns description of UTF8 string column
+func stringColumn(name string) parquetColumn {
+	return parquetColumn{name, parquetTypeByteArray, parquetConvertedTypeUTF8, false}
+}
+
+// timestampColumn returns description of optional timestamp column with
+// milliseconds precision
+func timestampColumn(name string) parquetColumn {
+	return parquetColumn{name, parquetTypeInt64, parquetConvertedTypeTimestampMillis, true}
+}
+
+// parquetColumnBuffer contains values of one column for the current row group
+type parquetColumnBuffer struct {
+	values    bytes.Buffer
+	defLevels []bool
+}
+
+// parquetColumnChunk contains metadata about one column chunk already
+// written to the output
+type parquetColumnChunk struct {
+	offset    int64
+	size      int64
+	numValues int64
+}
+
+// parquetRowGroup contains metadata about one row group already written to
+// the output
+type parquetRowGroup struct {
+	columns []parquetColumnChunk
+	numRows int64
+}
+
+// parquetWriter writes rows into Parquet file
+type parquetWriter struct {
+	output       io.Writer
+	offset       int64
+	columns      []parquetColumn
+	buffers      []parquetColumnBuffer
+	rowGroupSize int
+	bufferedRows int
+	rowGroups    []parquetRowGroup
+	numRows      int64
+}
+
+// newParquetWriter constructs new Parquet writer and writes the file header
+// into the output. Rows are flushed into the output in row groups containing
+// up to rowGroupSize rows.
+func newParquetWriter(output io.Writer, columns []parquetColumn, rowGroupSize int) (*parquetWriter, error) {
+	if rowGroupSize <= 0 {
+		return nil, fmt.Errorf("row group size must be positive, got %d", rowGroupSize)
+	}
+
+	writer := &parquetWriter{
+		output:       output,
+		columns:      columns,
+		buffers:      make([]parquetColumnBuffer, len(columns)),
+		rowGroupSize: rowGroupSize,
+	}
+
+	if err := writer.write([]byte(parquetMagic)); err != nil {
+		return nil, err
+	}
+
+	return writer, nil
+}
+
+func (writer *parquetWriter) write(data []byte) error {
+	n, err := writer.output.Write(data)
+	writer.offset += int64(n)
+	return err
+}
+
+// WriteRow appends one row to the file. Values need to be in the same order
+// as columns. Supported values are int64 and time.Time for INT64 columns,
+// string for BYTE_ARRAY columns and nil for optional columns.
+func (writer *parquetWriter) WriteRow(values ...interface{}) error {
+	if len(values) != len(writer.columns) {
+		return fmt.Errorf("expected %d values, got %d", len(writer.columns), len(values))
+	}
+
+	// all the values are checked first so the row is never written partially
+	for i, column := range writer.columns {
+		if err := checkValue(column, values[i]); err != nil {
+			return err
+		}
+	}
+
+	for i := range writer.columns {
+		writer.buffers[i].append(values[i])
+	}
+
+	writer.bufferedRows++
+	if writer.bufferedRows >= writer.rowGroupSize {
+		return writer.flushRowGroup()
+	}
+
+	return nil
+}
+
+// checkValue checks whether the value can be stored in the column
+func checkValue(column parquetColumn, value interface{}) error {
+	switch value.(type) {
+	case nil:
+		if !column.optional {
+			return fmt.Errorf("column %s is required, but nil value was provided", column.name)
+		}
+	case int64:
+		if column.physicalType != parquetTypeInt64 {
+			return fmt.Errorf("unexpected int64 value for column %s", column.name)
+		}
+	case time.Time:
+		if column.convertedType != parquetConvertedTypeTimestampMillis {
+			return fmt.Errorf("unexpected time value for column %s", column.name)
+		}
+	case string:
+		if column.physicalType != parquetTypeByteArray {
+			return fmt.Errorf("unexpected string value for column %s", column.name)
+		}
+	default:
+		return fmt.Errorf("unsupported value type %T for column %s", value, column.name)
+	}
+
+	return nil
+}
+
+// append encodes one already checked value into the column buffer
+func (buffer *parquetColumnBuffer) append(value interface{}) {
+	var encoded [8]byte
+
+	switch v := value.(type) {
+	case nil:
+		buffer.defLevels = append(buffer.defLevels, false)
+		return
+	case int64:
+		binary.LittleEndian.PutUint64(encoded[:], uint64(v))
+		buffer.values.Write(encoded[:])
+	case time.Time:
+		binary.LittleEndian.PutUint64(encoded[:], uint64(v.UnixNano()/int64(time.Millisecond)))
+		buffer.values.Write(encoded[:])
+	case string:
+		binary.LittleEndian.PutUint32(encoded[:4], uint32(len(v)))
+		buffer.values.Write(encoded[:4])
+		buffer.values.WriteString(v)
+	}
+
+	buffer.defLevels = append(buffer.defLevels, true)
+}
+
+// encodeDefinitionLevels encodes definition levels with maximum level 1
+// using the RLE/bit-packing hybrid encoding prefixed by its length
+func encodeDefinitionLevels(levels []bool) []byte {
+	groups := (len(levels) + 7) / 8
+
+	var encoded bytes.Buffer
+	writeUvarint(&encoded, uint64(groups)<<1|1)
+	packed := make([]byte, groups)
+	for i, defined := range levels {
+		if defined {
+			packed[i/8] |= 1 << uint(i%8)
+		}
+	}
+	encoded.Write(packed)
+
+	result := make([]byte, 4, 4+encoded.Len())
+	binary.LittleEndian.PutUint32(result, uint32(encoded.Len()))
+	return append(result, encoded.Bytes()...)
+}
+
+// flushRowGroup writes all buffered rows into the output as a new row group
+func (writer *parquetWriter) flushRowGroup() error {
+	if writer.bufferedRows == 0 {
+		return nil
+	}
+
+	rowGroup := parquetRowGroup{numRows: int64(writer.bufferedRows)}
+
+	for i, column := range writer.columns {
+		buffer := &writer.buffers[i]
+
+		var page []byte
+		if column.optional {
+			page = encodeDefinitionLevels(buffer.defLevels)
+		}
+		page = append(page, buffer.values.Bytes()...)
+
+		numValues := int64(len(buffer.defLevels))
+
+		header := newThriftWriter()
+		header.i32Field(1, parquetPageTypeData)
+		header.i32Field(2, int32(len(page)))
+		header.i32Field(3, int32(len(page)))
+		header.beginStructField(5)
+		header.i32Field(1, int32(numValues))
+		header.i32Field(2, parquetEncodingPlain)
+		header.i32Field(3, parquetEncodingRLE)
+		header.i32Field(4, parquetEncodingRLE)
+		header.endStruct()
+		header.endStruct()
+
+		chunk := parquetColumnChunk{
+			offset:    writer.offset,
+			size:      int64(header.buf.Len() + len(page)),
+			numValues: numValues,
+		}
+
+		if err := writer.write(header.buf.Bytes()); err != nil {
+			return err
+		}
+		if err := writer.write(page); err != nil {
+			return err
+		}
+
+		rowGroup.columns = append(rowGroup.columns, chunk)
+
+		buffer.values.Reset()
+		buffer.defLevels = buffer.defLevels[:0]
+	}
+
+	writer.rowGroups = append(writer.rowGroups, rowGroup)
+	writer.numRows += rowGroup.numRows
+	writer.bufferedRows = 0
+
+	return nil
+}
+
+// Close flushes remaining rows and writes the file footer. It doesn't close
+// the underlying output.
+func (writer *parquetWriter) Close() error {
+	if err := writer.flushRowGroup(); err != nil {
+		return err
+	}
+
+	footer := newThriftWriter()
+	footer.i32Field(1, 1)
+
+	// schema: root element followed by all the columns
+	footer.listField(2, thriftTypeStruct, len(writer.columns)+1)
+	footer.beginStruct()
+	footer.binaryField(4, []byte("schema"))
+	footer.i32Field(5, int32(len(writer.columns)))
+	footer.endStruct()
+	for _, column := range writer.columns {
+		repetition := int32(parquetRepetitionRequired)
+		if column.optional {
+			repetition = parquetRepetitionOptional
+		}
+
+		footer.beginStruct()
+		footer.i32Field(1, column.physicalType)
+		footer.i32Field(3, repetition)
+		footer.binaryField(4, []byte(column.name))
+		if column.convertedType != parquetConvertedTypeNone {
+			footer.i32Field(6, column.convertedType)
+		}
+		footer.endStruct()
+	}
+
+	footer.i64Field(3, writer.numRows)
+
+	footer.listField(4, thriftTypeStruct, len(writer.rowGroups))
+	for _, rowGroup := range writer.rowGroups {
+		var totalSize int64
+
+		footer.beginStruct()
+		footer.listField(1, thriftTypeStruct, len(rowGroup.columns))
+		for i, chunk := range rowGroup.columns {
+			column := writer.columns[i]
+			totalSize += chunk.size
+
+			footer.beginStruct()
+			footer.i64Field(2, chunk.offset)
+			footer.beginStructField(3)
+			footer.i32Field(1, column.physicalType)
+			footer.listField(2, thriftTypeI32, 2)
+			footer.i32(parquetEncodingPlain)
+			footer.i32(parquetEncodingRLE)
+			footer.listField(3, thriftTypeBinary, 1)
+			footer.binary([]byte(column.name))
+			footer.i32Field(4, parquetCodecUncompressed)
+			footer.i64Field(5, chunk.numValues)
+			footer.i64Field(6, chunk.size)
+			footer.i64Field(7, chunk.size)
+			footer.i64Field(9, chunk.offset)
+			footer.endStruct()
+			footer.endStruct()
+		}
+		footer.i64Field(2, totalSize)
+		footer.i64Field(3, rowGroup.numRows)
+		footer.endStruct()
+	}
+
+	footer.binaryField(6, []byte(parquetCreatedBy))
+	footer.endStruct()
+
+	if err := writer.write(footer.buf.Bytes()); err != nil {
+		return err
+	}
+
+	var footerLength [4]byte
+	binary.LittleEndian.PutUint32(footerLength[:], uint32(footer.buf.Len()))
+	if err := writer.write(footerLength[:]); err != nil {
+		return err
+	}
+
+	return writer.write([]byte(parquetMagic))
+}
+
+// thriftWriter serializes structures using Thrift compact protocol
+type thriftWriter struct {
+	buf bytes.Buffer
+	// IDs of the last written fields for all nested structures
+	lastFieldIDs []int16
+}
+
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{lastFieldIDs: []int16{0}}
+}
+
+func writeUvarint(buf *bytes.Buffer, value uint64) {
+	var encoded [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(encoded[:], value)
+	buf.Write(encoded[:n])
+}
+
+func (writer *thriftWriter) fieldHeader(id int16, fieldType byte) {
+	last := &writer.lastFieldIDs[len(writer.lastFieldIDs)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		writer.buf.WriteByte(byte(delta)<<4 | fieldType)
+	} else {
+		writer.buf.WriteByte(fieldType)
+		writeUvarint(&writer.buf, uint64((int64(id)<<1)^(int64(id)>>63)))
+	}
+	*last = id
+}
+
+func (writer *thriftWriter) i32(value int32) {
+	writeUvarint(&writer.buf, uint64(uint32((value<<1)^(value>>31))))
+}
+
+func (writer *thriftWriter) i64(value int64) {
+	writeUvarint(&writer.buf, uint64((value<<1)^(value>>63)))
+}
+
+func (writer *thriftWriter) binary(value []byte) {
+	writeUvarint(&writer.buf, uint64(len(value)))
+	writer.buf.Write(value)
+}
+
+func (writer *thriftWriter) i32Field(id int16, value int32) {
+	writer.fieldHeader(id, thriftTypeI32)
+	writer.i32(value)
+}
+
+func (writer *thriftWriter) i64Field(id int16, value int64) {
+	writer.fieldHeader(id, thriftTypeI64)
+	writer.i64(value)
+}
+
+func (writer *thriftWriter) binaryField(id int16, value []byte) {
+	writer.fieldHeader(id, thriftTypeBinary)
+	writer.binary(value)
+}
+
+// listField writes header of list field, elements need to be written
+// separately right after it
+func (writer *thriftWriter) listField(id int16, elementType byte, size int) {
+	writer.fieldHeader(id, thriftTypeList)
+	if size < 15 {
+		writer.buf.WriteByte(byte(size)<<4 | elementType)
+	} else {
+		writer.buf.WriteByte(0xf0 | elementType)
+		writeUvarint(&writer.buf, uint64(size))
+	}
+}
+
+// beginStructField writes header of struct field, the struct needs to be
+// finished by endStruct
+func (writer *thriftWriter) beginStructField(id int16) {
+	writer.fieldHeader(id, thriftTypeStruct)
+	writer.beginStruct()
+}
+
+// beginStruct starts struct without field header, i.e. a list element
+func (writer *thriftWriter) beginStruct() {
+	writer.lastFieldIDs = append(writer.lastFieldIDs, 0)
+}
+
+// endStruct writes the stop field and finishes the innermost struct
+func (writer *thriftWriter) endStruct() {
+	writer.buf.WriteByte(0)
+	writer.lastFieldIDs = writer.lastFieldIDs[:len(writer.lastFieldIDs)-1]
+}
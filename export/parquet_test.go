@@ -0,0 +1,329 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/source"
+
+	"github.com/RedHatInsights/insights-results-aggregator/export"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const parquetMagic = "PAR1"
+
+// assertParquetFile checks the file header and trailer and returns the footer
+func assertParquetFile(t *testing.T, content []byte) []byte {
+	assert.True(t, bytes.HasPrefix(content, []byte(parquetMagic)))
+	assert.True(t, bytes.HasSuffix(content, []byte(parquetMagic)))
+
+	// the footer of file without rows fills everything between the magic
+	// bytes and its length
+	footerLength := int(binary.LittleEndian.Uint32(content[len(content)-8:]))
+	assert.LessOrEqual(t, footerLength, len(content)-12)
+
+	return content[len(content)-8-footerLength : len(content)-8]
+}
+
+// parquetBuffer provides the file content to the Parquet reader
+type parquetBuffer struct {
+	*bytes.Reader
+}
+
+func (buffer parquetBuffer) Write([]byte) (int, error) {
+	return 0, errors.New("read only")
+}
+
+func (buffer parquetBuffer) Close() error {
+	return nil
+}
+
+func (buffer parquetBuffer) Open(string) (source.ParquetFile, error) {
+	return parquetBuffer{bytes.NewReader(buffer.content())}, nil
+}
+
+func (buffer parquetBuffer) Create(string) (source.ParquetFile, error) {
+	return nil, errors.New("read only")
+}
+
+func (buffer parquetBuffer) content() []byte {
+	content := make([]byte, buffer.Size())
+	_, _ = buffer.ReadAt(content, 0)
+	return content
+}
+
+// mustReadParquetFile reads the file by independent Parquet implementation
+// and returns its metadata and values of all the columns, nil values are
+// returned for undefined optional values
+func mustReadParquetFile(t *testing.T, content []byte) (*parquet.FileMetaData, [][]interface{}) {
+	parquetReader, err := reader.NewParquetColumnReader(parquetBuffer{bytes.NewReader(content)}, 1)
+	helpers.FailOnError(t, err)
+	defer parquetReader.ReadStop()
+
+	numRows := parquetReader.GetNumRows()
+	columns := make([][]interface{}, len(parquetReader.SchemaHandler.ValueColumns))
+	for i := range columns {
+		if numRows == 0 {
+			continue
+		}
+
+		values, _, _, err := parquetReader.ReadColumnByIndex(int64(i), numRows)
+		helpers.FailOnError(t, err)
+		columns[i] = values
+	}
+
+	// the reader renames the columns in the metadata to Go identifiers
+	for i, element := range parquetReader.Footer.GetSchema() {
+		element.Name = parquetReader.SchemaHandler.GetExName(i)
+	}
+
+	return parquetReader.Footer, columns
+}
+
+func TestParquetWriter(t *testing.T) {
+	var output bytes.Buffer
+
+	writer, err := export.NewParquetWriter(&output, testColumns(), 2)
+	helpers.FailOnError(t, err)
+
+	reportedAt := time.Date(2020, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	helpers.FailOnError(t, writer.WriteRow(int64(1), "first-cluster", reportedAt))
+	helpers.FailOnError(t, writer.WriteRow(int64(2), "second-cluster", nil))
+	helpers.FailOnError(t, writer.WriteRow(int64(3), "third-cluster", reportedAt))
+	helpers.FailOnError(t, writer.Close())
+
+	assertParquetFile(t, output.Bytes())
+
+	metadata, columns := mustReadParquetFile(t, output.Bytes())
+
+	assert.Equal(t, int64(3), metadata.GetNumRows())
+	assert.Len(t, metadata.GetRowGroups(), 2)
+
+	schema := metadata.GetSchema()
+	if assert.Len(t, schema, 4) {
+		assert.Equal(t, "org_id", schema[1].GetName())
+		assert.Equal(t, parquet.Type_INT64, schema[1].GetType())
+		assert.Equal(t, parquet.FieldRepetitionType_REQUIRED, schema[1].GetRepetitionType())
+		assert.Equal(t, "cluster_id", schema[2].GetName())
+		assert.Equal(t, parquet.Type_BYTE_ARRAY, schema[2].GetType())
+		assert.Equal(t, parquet.ConvertedType_UTF8, schema[2].GetConvertedType())
+		assert.Equal(t, "reported_at", schema[3].GetName())
+		assert.Equal(t, parquet.ConvertedType_TIMESTAMP_MILLIS, schema[3].GetConvertedType())
+		assert.Equal(t, parquet.FieldRepetitionType_OPTIONAL, schema[3].GetRepetitionType())
+	}
+
+	reportedAtMillis := reportedAt.Unix() * 1000
+	assert.Equal(t, [][]interface{}{
+		{int64(1), int64(2), int64(3)},
+		{"first-cluster", "second-cluster", "third-cluster"},
+		{reportedAtMillis, nil, reportedAtMillis},
+	}, columns)
+}
+
+func TestParquetWriter_ManyRowsInRowGroup(t *testing.T) {
+	var output bytes.Buffer
+
+	// definition levels of more than 8 values are packed into several bytes
+	writer, err := export.NewParquetWriter(&output, testColumns(), 100)
+	helpers.FailOnError(t, err)
+
+	expected := [][]interface{}{{}, {}, {}}
+	for i := 0; i < 20; i++ {
+		var reportedAt interface{}
+		if i%3 == 0 {
+			reportedAt = int64(i * 1000)
+		}
+
+		cluster := fmt.Sprintf("cluster-%d", i)
+		if reportedAt == nil {
+			helpers.FailOnError(t, writer.WriteRow(int64(i), cluster, nil))
+		} else {
+			helpers.FailOnError(t, writer.WriteRow(int64(i), cluster, time.Unix(int64(i), 0)))
+		}
+
+		expected[0] = append(expected[0], int64(i))
+		expected[1] = append(expected[1], cluster)
+		expected[2] = append(expected[2], reportedAt)
+	}
+	helpers.FailOnError(t, writer.Close())
+
+	metadata, columns := mustReadParquetFile(t, output.Bytes())
+	assert.Equal(t, int64(20), metadata.GetNumRows())
+	assert.Equal(t, expected, columns)
+}
+
+func TestParquetWriter_Empty(t *testing.T) {
+	var output bytes.Buffer
+
+	writer, err := export.NewParquetWriter(&output, testColumns(), 10)
+	helpers.FailOnError(t, err)
+	helpers.FailOnError(t, writer.Close())
+
+	assertParquetFile(t, output.Bytes())
+
+	metadata, _ := mustReadParquetFile(t, output.Bytes())
+	assert.Equal(t, int64(0), metadata.GetNumRows())
+	assert.Len(t, metadata.GetSchema(), 4)
+}
+
+func TestParquetWriter_InvalidRowGroupSize(t *testing.T) {
+	_, err := export.NewParquetWriter(&bytes.Buffer{}, testColumns(), 0)
+	assert.EqualError(t, err, "row group size must be positive, got 0")
+}
+
+func TestParquetWriter_InvalidValues(t *testing.T) {
+	writer, err := export.NewParquetWriter(&bytes.Buffer{}, testColumns(), 10)
+	helpers.FailOnError(t, err)
+
+	assert.EqualError(t, writer.WriteRow(int64(1)), "expected 3 values, got 1")
+	assert.EqualError(
+		t, writer.WriteRow(nil, "cluster", nil),
+		"column org_id is required, but nil value was provided",
+	)
+	assert.EqualError(
+		t, writer.WriteRow("1", "cluster", nil),
+		"unexpected string value for column org_id",
+	)
+	assert.EqualError(
+		t, writer.WriteRow(int64(1), 42, nil),
+		"unsupported value type int for column cluster_id",
+	)
+}
+
+func TestToParquet(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.Report3Rules,
+		testdata.Report3RulesParsed,
+		testdata.LastCheckedAt,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	directory := t.TempDir()
+
+	now := time.Date(2020, 10, 16, 12, 0, 0, 0, time.UTC)
+	files, err := export.ToParquetAt(export.Configuration{Path: directory}, mockStorage, now)
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, []string{
+		filepath.Join(directory, "reports-20201016T120000Z.parquet"),
+		filepath.Join(directory, "rule_hits-20201016T120000Z.parquet"),
+	}, files)
+
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		helpers.FailOnError(t, err)
+
+		assertParquetFile(t, content)
+
+		metadata, columns := mustReadParquetFile(t, content)
+		assert.Greater(t, metadata.GetNumRows(), int64(0))
+		assert.Contains(t, columns[1], string(testdata.ClusterName))
+	}
+}
+
+func TestToParquet_DBError(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	directory := t.TempDir()
+
+	_, err := export.ToParquetAt(export.Configuration{Path: directory}, mockStorage, time.Now())
+	assert.EqualError(t, err, "sql: database is closed")
+
+	// incomplete files are removed
+	files, err := ioutil.ReadDir(directory)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, files)
+}
+
+func testColumns() []export.ParquetColumn {
+	return []export.ParquetColumn{
+		export.Int64Column("org_id"),
+		export.StringColumn("cluster_id"),
+		export.TimestampColumn("reported_at"),
+	}
+}
+
+func TestToParquet_Canonical(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		testdata.ClusterName,
+		`{"system": {"hostname": null}, "reports": [`+
+			`{"key": "KEY_B", "component": "rule.b"}, {"key": "KEY_A", "component": "rule.a"}]}`,
+		[]types.ReportItem{},
+		testdata.LastCheckedAt,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	files, err := export.ToParquetAt(
+		export.Configuration{Path: t.TempDir(), Canonical: true}, mockStorage, time.Now(),
+	)
+	helpers.FailOnError(t, err)
+
+	content, err := ioutil.ReadFile(files[0])
+	helpers.FailOnError(t, err)
+
+	assert.Contains(t, string(content),
+		`{"reports":[{"component":"rule.a","key":"KEY_A"},{"component":"rule.b","key":"KEY_B"}],`+
+			`"system":{"hostname":null}}`,
+	)
+}
+
+func TestToParquet_CanonicalInvalidJSON(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		testdata.ClusterName,
+		"not a JSON",
+		[]types.ReportItem{},
+		testdata.LastCheckedAt,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	_, err = export.ToParquetAt(
+		export.Configuration{Path: t.TempDir(), Canonical: true}, mockStorage, time.Now(),
+	)
+	assert.Error(t, err)
+}
@@ -0,0 +1,101 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/rs/zerolog/log"
+)
+
+// newS3Session creates AWS session from the configuration. Default AWS
+// credentials chain is used when the credentials are not configured.
+func newS3Session(configuration Configuration) (*session.Session, error) {
+	awsConfig := aws.NewConfig()
+
+	if configuration.S3Region != "" {
+		awsConfig = awsConfig.WithRegion(configuration.S3Region)
+	}
+
+	if configuration.S3Endpoint != "" {
+		// S3 compatible storages (like MinIO) usually don't support
+		// virtual hosted-style requests
+		awsConfig = awsConfig.
+			WithEndpoint(configuration.S3Endpoint).
+			WithS3ForcePathStyle(true)
+	}
+
+	if configuration.AWSAccessID != "" {
+		awsConfig = awsConfig.WithCredentials(credentials.NewStaticCredentials(
+			configuration.AWSAccessID, configuration.AWSSecretKey, "",
+		))
+	}
+
+	return session.NewSession(awsConfig)
+}
+
+// uploadToS3 uploads the files into configured S3 bucket and returns keys of
+// the uploaded objects
+func uploadToS3(configuration Configuration, files []string) ([]string, error) {
+	awsSession, err := newS3Session(configuration)
+	if err != nil {
+		return nil, err
+	}
+
+	uploader := s3manager.NewUploader(awsSession)
+
+	keys := make([]string, 0, len(files))
+
+	for _, fileName := range files {
+		key := path.Join(configuration.S3Prefix, filepath.Base(fileName))
+
+		if err := uploadFile(uploader, configuration.S3Bucket, key, fileName); err != nil {
+			return nil, err
+		}
+
+		log.Info().Str("bucket", configuration.S3Bucket).Str("key", key).Msg("Parquet file has been uploaded")
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func uploadFile(uploader *s3manager.Uploader, bucket, key, fileName string) error {
+	file, err := os.Open(filepath.Clean(fileName))
+	if err != nil {
+		return err
+	}
+
+	_, err = uploader.Upload(&s3manager.UploadInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   file,
+	})
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
@@ -22,8 +22,9 @@ DB_ADDRESS=localhost
 DB_LOGIN=postgres
 DB_PASSWORD=postgres
 DB_NAME=aggregator
+DB_SCHEMA=public
 
 # Generate the documentation
 OUTPUT_DIR=docs/db-description-3
 
-java -jar schemaspy-6.1.0.jar -cp . -t pgsql -u ${DB_LOGIN} -p ${DB_PASSWORD} -host ${DB_ADDRESS} -s public -o ${OUTPUT_DIR} -db ${DB_NAME} -dp postgresql-42.2.20.jre7.jar
+java -jar schemaspy-6.1.0.jar -cp . -t pgsql -u ${DB_LOGIN} -p ${DB_PASSWORD} -host ${DB_ADDRESS} -s ${DB_SCHEMA} -o ${OUTPUT_DIR} -db ${DB_NAME} -dp postgresql-42.2.20.jre7.jar
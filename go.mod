@@ -9,19 +9,26 @@ require (
 	github.com/RedHatInsights/insights-operator-utils v1.10.0
 	github.com/RedHatInsights/insights-results-aggregator-data v1.0.1-0.20210614072933-b25730b1e023
 	github.com/Shopify/sarama v1.27.1
+	github.com/aws/aws-sdk-go v1.35.7
 	github.com/deckarep/golang-set v1.7.1
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/gchaincl/sqlhooks v1.3.0
+	github.com/go-redis/redis/v8 v8.4.4
 	github.com/google/uuid v1.1.2
 	github.com/gorilla/mux v1.8.0
+	github.com/klauspost/compress v1.13.1
 	github.com/lib/pq v1.8.0
 	github.com/mattn/go-sqlite3 v2.0.3+incompatible
+	github.com/ory/dockertest/v3 v3.6.3
 	github.com/prometheus/client_golang v1.10.0
 	github.com/prometheus/client_model v0.2.0
 	github.com/redhatinsights/app-common-go v1.5.1
 	github.com/rs/zerolog v1.20.0
 	github.com/spf13/viper v1.7.2-0.20210415161207-7fdb267c730d
-	github.com/stretchr/testify v1.6.1
+	github.com/stretchr/testify v1.7.0
 	github.com/verdverm/frisby v0.0.0-20170604211311-b16556248a9a
+	github.com/xdg/scram v0.0.0-20180814205039-7eeb5667e42c
+	github.com/xdg/stringprep v1.0.0 // indirect
+	github.com/xitongsys/parquet-go v1.6.2
 	golang.org/x/sync v0.0.0-20201207232520-09787c993a3a
 )
@@ -0,0 +1,134 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inventory
+
+import (
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// defaultCacheTTL is used when no cache TTL is configured
+const defaultCacheTTL = time.Hour
+
+// cacheEntry is the display name of one cluster, empty name means the
+// provider doesn't know the display name
+type cacheEntry struct {
+	displayName string
+	expiresAt   time.Time
+}
+
+// CachingProvider remembers display names returned by another provider for
+// the configured time, only clusters missing in the cache are resolved by the
+// provider. Clusters without display name are cached too, so the provider is
+// not asked for them again and again.
+type CachingProvider struct {
+	provider Provider
+	ttl      time.Duration
+	now      func() time.Time
+	mutex    sync.Mutex
+	entries  map[types.ClusterName]cacheEntry
+}
+
+// NewCachingProvider constructs provider caching display names returned by
+// the given provider
+func NewCachingProvider(provider Provider, ttl time.Duration) *CachingProvider {
+	if ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+
+	return &CachingProvider{
+		provider: provider,
+		ttl:      ttl,
+		now:      time.Now,
+		entries:  make(map[types.ClusterName]cacheEntry),
+	}
+}
+
+// DisplayNames returns cached display names, the clusters missing in the
+// cache are resolved by the provider by one call. Nothing is cached when the
+// provider fails.
+func (cache *CachingProvider) DisplayNames(
+	orgID types.OrgID, clusters []types.ClusterName,
+) (map[types.ClusterName]string, error) {
+	displayNames, missing := cache.lookup(clusters)
+	if len(missing) == 0 {
+		return displayNames, nil
+	}
+
+	resolved, err := cache.provider.DisplayNames(orgID, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.store(missing, resolved)
+
+	for cluster, displayName := range resolved {
+		if displayName != "" {
+			displayNames[cluster] = displayName
+		}
+	}
+
+	return displayNames, nil
+}
+
+// lookup returns cached display names and clusters missing in the cache
+func (cache *CachingProvider) lookup(
+	clusters []types.ClusterName,
+) (map[types.ClusterName]string, []types.ClusterName) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	now := cache.now()
+	displayNames := make(map[types.ClusterName]string)
+	var missing []types.ClusterName
+
+	for _, cluster := range clusters {
+		entry, found := cache.entries[cluster]
+		switch {
+		case !found || !now.Before(entry.expiresAt):
+			missing = append(missing, cluster)
+		case entry.displayName != "":
+			displayNames[cluster] = entry.displayName
+		}
+	}
+
+	return displayNames, missing
+}
+
+// store caches display names of the resolved clusters and removes expired
+// entries, so the cache doesn't grow with clusters nobody asks for anymore
+func (cache *CachingProvider) store(clusters []types.ClusterName, resolved map[types.ClusterName]string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	now := cache.now()
+
+	for cluster, entry := range cache.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(cache.entries, cluster)
+		}
+	}
+
+	for _, cluster := range clusters {
+		cache.entries[cluster] = cacheEntry{
+			displayName: resolved[cluster],
+			expiresAt:   now.Add(cache.ttl),
+		}
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inventory_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/inventory"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mockProvider returns display names from the map and records clusters it
+// was asked for
+type mockProvider struct {
+	displayNames map[types.ClusterName]string
+	err          error
+	requested    [][]types.ClusterName
+}
+
+func (provider *mockProvider) DisplayNames(
+	_ types.OrgID, clusters []types.ClusterName,
+) (map[types.ClusterName]string, error) {
+	provider.requested = append(provider.requested, clusters)
+	if provider.err != nil {
+		return nil, provider.err
+	}
+
+	displayNames := make(map[types.ClusterName]string)
+	for _, cluster := range clusters {
+		if displayName, found := provider.displayNames[cluster]; found {
+			displayNames[cluster] = displayName
+		}
+	}
+
+	return displayNames, nil
+}
+
+func TestCachingProvider(t *testing.T) {
+	unknownCluster := testdata.GetRandomClusterID()
+	provider := &mockProvider{displayNames: map[types.ClusterName]string{
+		testdata.ClusterName: "production",
+	}}
+
+	now := time.Date(2020, 10, 16, 10, 0, 0, 0, time.UTC)
+	cache := inventory.NewCachingProvider(provider, time.Hour)
+	cache.SetClock(func() time.Time { return now })
+
+	clusters := []types.ClusterName{testdata.ClusterName, unknownCluster}
+	expected := map[types.ClusterName]string{testdata.ClusterName: "production"}
+
+	displayNames, err := cache.DisplayNames(testdata.OrgID, clusters)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, expected, displayNames)
+
+	// both known and unknown clusters are cached
+	displayNames, err = cache.DisplayNames(testdata.OrgID, clusters)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, expected, displayNames)
+	assert.Equal(t, [][]types.ClusterName{clusters}, provider.requested)
+
+	// only expired clusters are resolved again
+	provider.displayNames[testdata.ClusterName] = "staging"
+	now = now.Add(time.Hour)
+
+	displayNames, err = cache.DisplayNames(testdata.OrgID, clusters)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, map[types.ClusterName]string{testdata.ClusterName: "staging"}, displayNames)
+	assert.Equal(t, [][]types.ClusterName{clusters, clusters}, provider.requested)
+}
+
+func TestCachingProviderError(t *testing.T) {
+	provider := &mockProvider{err: errors.New("inventory is unavailable")}
+	cache := inventory.NewCachingProvider(provider, 0)
+
+	_, err := cache.DisplayNames(testdata.OrgID, []types.ClusterName{testdata.ClusterName})
+	assert.EqualError(t, err, "inventory is unavailable")
+
+	// nothing is cached when the provider fails
+	provider.err = nil
+	provider.displayNames = map[types.ClusterName]string{testdata.ClusterName: "production"}
+
+	displayNames, err := cache.DisplayNames(testdata.OrgID, []types.ClusterName{testdata.ClusterName})
+	helpers.FailOnError(t, err)
+	assert.Equal(t, map[types.ClusterName]string{testdata.ClusterName: "production"}, displayNames)
+}
@@ -0,0 +1,108 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inventory
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// defaultTimeout is used when no timeout is configured
+const defaultTimeout = 10 * time.Second
+
+// displayNamesRequest is the body of the request sent to the inventory
+type displayNamesRequest struct {
+	OrgID    types.OrgID         `json:"org_id"`
+	Clusters []types.ClusterName `json:"clusters"`
+}
+
+// displayNamesResponse is the body of the response of the inventory
+type displayNamesResponse struct {
+	DisplayNames map[types.ClusterName]string `json:"display_names"`
+}
+
+// HTTPProvider asks the inventory service for display names of clusters by
+// HTTP POST requests to the configured URL
+type HTTPProvider struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewHTTPProvider constructs provider asking the inventory at the given URL
+func NewHTTPProvider(url string, timeout time.Duration) *HTTPProvider {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	return &HTTPProvider{
+		URL:    url,
+		Client: &http.Client{Timeout: timeout},
+	}
+}
+
+// DisplayNames asks the inventory for display names of all clusters by one
+// request
+func (provider *HTTPProvider) DisplayNames(
+	orgID types.OrgID, clusters []types.ClusterName,
+) (map[types.ClusterName]string, error) {
+	if len(clusters) == 0 {
+		return map[types.ClusterName]string{}, nil
+	}
+
+	body, err := json.Marshal(displayNamesRequest{OrgID: orgID, Clusters: clusters})
+	if err != nil {
+		return nil, err
+	}
+
+	// #nosec G107
+	response, err := provider.Client.Post(provider.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		// read the rest of body so the connection can be reused
+		_, _ = io.Copy(ioutil.Discard, response.Body)
+		if err := response.Body.Close(); err != nil {
+			log.Error().Err(err).Msg("Unable to close inventory response body")
+		}
+	}()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("inventory %s responded with status %d", provider.URL, response.StatusCode)
+	}
+
+	var decoded displayNamesResponse
+	if err := json.NewDecoder(response.Body).Decode(&decoded); err != nil {
+		return nil, err
+	}
+
+	if decoded.DisplayNames == nil {
+		decoded.DisplayNames = map[types.ClusterName]string{}
+	}
+
+	return decoded.DisplayNames, nil
+}
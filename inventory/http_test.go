@@ -0,0 +1,101 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inventory_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/inventory"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func TestHTTPProvider(t *testing.T) {
+	clusters := []types.ClusterName{testdata.ClusterName, testdata.GetRandomClusterID()}
+
+	inventoryService := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		assert.Equal(t, http.MethodPost, request.Method)
+		assert.Equal(t, "application/json", request.Header.Get("Content-Type"))
+
+		var body struct {
+			OrgID    types.OrgID         `json:"org_id"`
+			Clusters []types.ClusterName `json:"clusters"`
+		}
+		helpers.FailOnError(t, json.NewDecoder(request.Body).Decode(&body))
+		assert.Equal(t, testdata.OrgID, body.OrgID)
+		assert.Equal(t, clusters, body.Clusters)
+
+		_, err := writer.Write([]byte(`{"display_names": {"` + string(testdata.ClusterName) + `": "production"}}`))
+		helpers.FailOnError(t, err)
+	}))
+	defer inventoryService.Close()
+
+	provider := inventory.NewHTTPProvider(inventoryService.URL, time.Second)
+
+	displayNames, err := provider.DisplayNames(testdata.OrgID, clusters)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, map[types.ClusterName]string{testdata.ClusterName: "production"}, displayNames)
+}
+
+func TestHTTPProviderNoClusters(t *testing.T) {
+	calls := 0
+
+	inventoryService := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		calls++
+	}))
+	defer inventoryService.Close()
+
+	provider := inventory.NewHTTPProvider(inventoryService.URL, 0)
+
+	displayNames, err := provider.DisplayNames(testdata.OrgID, nil)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, displayNames)
+	assert.Equal(t, 0, calls)
+}
+
+func TestHTTPProviderErrorStatus(t *testing.T) {
+	inventoryService := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer inventoryService.Close()
+
+	provider := inventory.NewHTTPProvider(inventoryService.URL, time.Second)
+
+	_, err := provider.DisplayNames(testdata.OrgID, []types.ClusterName{testdata.ClusterName})
+	assert.EqualError(t, err, fmt.Sprintf("inventory %s responded with status 503", inventoryService.URL))
+}
+
+func TestHTTPProviderWrongResponse(t *testing.T) {
+	inventoryService := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		_, err := writer.Write([]byte(`not JSON`))
+		helpers.FailOnError(t, err)
+	}))
+	defer inventoryService.Close()
+
+	provider := inventory.NewHTTPProvider(inventoryService.URL, time.Second)
+
+	_, err := provider.DisplayNames(testdata.OrgID, []types.ClusterName{testdata.ClusterName})
+	assert.Error(t, err)
+}
@@ -0,0 +1,31 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package inventory resolves IDs of clusters to their display names known to
+// the cluster inventory (AMS or other external inventory service), so the
+// display names can be attached to REST API responses and UIs don't need to
+// call the inventory for every cluster.
+package inventory
+
+import "github.com/RedHatInsights/insights-results-aggregator/types"
+
+// Provider represents any source of display names of clusters
+type Provider interface {
+	// DisplayNames returns display names of the given clusters of the
+	// organization, clusters unknown to the provider or without display name
+	// are missing in the result
+	DisplayNames(orgID types.OrgID, clusters []types.ClusterName) (map[types.ClusterName]string, error)
+}
@@ -0,0 +1,37 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import "github.com/RedHatInsights/insights-results-aggregator/events"
+
+func init() {
+	SubscribeToEvents(events.DefaultBus)
+}
+
+// SubscribeToEvents updates metrics according to events published to the
+// bus. Metrics are subscribed to the default bus automatically.
+func SubscribeToEvents(bus *events.Bus) {
+	bus.SubscribeReportWritten(func(events.ReportWrittenEvent) {
+		WrittenReports.Inc()
+	})
+	bus.SubscribeConsumerError(func(events.ConsumerErrorEvent) {
+		ConsumingErrors.Inc()
+	})
+	bus.SubscribeClusterOrgConflict(func(event events.ClusterOrgConflictEvent) {
+		ClusterOrgConflicts.WithLabelValues(event.Resolution).Inc()
+	})
+}
@@ -0,0 +1,57 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TraceIDLabel is the label of exemplars containing trace ID
+const TraceIDLabel = "trace_id"
+
+// traceIDKey is the key of trace ID in context
+type traceIDKey struct{}
+
+// ContextWithTraceID returns copy of the context carrying the trace ID, which
+// is then attached to observations made with that context
+func ContextWithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, traceID)
+}
+
+// TraceIDFromContext returns the trace ID carried by the context
+func TraceIDFromContext(ctx context.Context) (string, bool) {
+	traceID, found := ctx.Value(traceIDKey{}).(string)
+	return traceID, found && traceID != ""
+}
+
+// ObserveWithContext observes the value and attaches the trace ID carried by
+// the context as an exemplar, so the observation (a slow bucket for example)
+// can be linked to the trace. The value is observed without exemplar when
+// the context doesn't carry any trace ID or the observer doesn't support
+// exemplars.
+func ObserveWithContext(ctx context.Context, observer prometheus.Observer, value float64) {
+	if traceID, found := TraceIDFromContext(ctx); found {
+		if exemplarObserver, ok := observer.(prometheus.ExemplarObserver); ok {
+			exemplarObserver.ObserveWithExemplar(value, prometheus.Labels{TraceIDLabel: traceID})
+			return
+		}
+	}
+
+	observer.Observe(value)
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+)
+
+const testTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+// observerMock records observed values and exemplars
+type observerMock struct {
+	values    []float64
+	exemplars []prometheus.Labels
+}
+
+func (observer *observerMock) Observe(value float64) {
+	observer.values = append(observer.values, value)
+	observer.exemplars = append(observer.exemplars, nil)
+}
+
+func (observer *observerMock) ObserveWithExemplar(value float64, exemplar prometheus.Labels) {
+	observer.values = append(observer.values, value)
+	observer.exemplars = append(observer.exemplars, exemplar)
+}
+
+// plainObserverMock doesn't support exemplars
+type plainObserverMock struct {
+	values []float64
+}
+
+func (observer *plainObserverMock) Observe(value float64) {
+	observer.values = append(observer.values, value)
+}
+
+func TestTraceIDFromContext(t *testing.T) {
+	_, found := metrics.TraceIDFromContext(context.Background())
+	assert.False(t, found)
+
+	traceID, found := metrics.TraceIDFromContext(metrics.ContextWithTraceID(context.Background(), testTraceID))
+	assert.True(t, found)
+	assert.Equal(t, testTraceID, traceID)
+}
+
+func TestObserveWithContextAttachesExemplar(t *testing.T) {
+	observer := &observerMock{}
+
+	metrics.ObserveWithContext(metrics.ContextWithTraceID(context.Background(), testTraceID), observer, 0.5)
+
+	assert.Equal(t, []float64{0.5}, observer.values)
+	assert.Equal(t, []prometheus.Labels{{metrics.TraceIDLabel: testTraceID}}, observer.exemplars)
+}
+
+func TestObserveWithContextWithoutTraceID(t *testing.T) {
+	observer := &observerMock{}
+
+	metrics.ObserveWithContext(context.Background(), observer, 0.5)
+
+	assert.Equal(t, []float64{0.5}, observer.values)
+	assert.Equal(t, []prometheus.Labels{nil}, observer.exemplars)
+}
+
+func TestObserveWithContextObserverWithoutExemplars(t *testing.T) {
+	observer := &plainObserverMock{}
+
+	metrics.ObserveWithContext(metrics.ContextWithTraceID(context.Background(), testTraceID), observer, 0.5)
+
+	assert.Equal(t, []float64{0.5}, observer.values)
+}
@@ -36,6 +36,32 @@ limitations under the License.
 // sql_queries_counter - total number of SQL queries
 //
 // sql_queries_durations - SQL queries durations
+//
+// stored_reports - number of reports stored in the database
+//
+// stale_clusters - number of clusters that haven't sent a report for a long time
+//
+// consumer_buffered_messages - number of consumed messages waiting for processing
+//
+// consumer_buffer_full - total number of times the consumer buffer was full
+//
+// consumer_group_rebalances - total number of consumer group sessions started after rebalance
+//
+// consumer_group_errors - total number of failed consumer group sessions
+//
+// consumer_claimed_partitions - number of partitions claimed by the consumer in the current session, by topic
+//
+// stale_report_writes - total number of reports rejected because a more recent report was already stored, by organization
+// (see OrgLabel)
+//
+// api_request_durations - REST API requests durations, by endpoint
+//
+// clusters_last_checked_cache_rejections - total number of old reports rejected by the in-memory cache of last checked timestamps
+//
+// clusters_last_checked_db_rejections - total number of old reports that passed the in-memory cache and were rejected by the check in the database
+//
+// Durations of SQL queries and REST API requests can carry trace IDs as
+// exemplars, see ObserveWithContext.
 package metrics
 
 import (
@@ -81,12 +107,45 @@ var ProducedMessages = promauto.NewCounter(prometheus.CounterOpts{
 	Help: "The total number of produced messages sent to Payload Tracker's Kafka topic",
 })
 
+// ProducerDeliveries shows results of deliveries of messages produced by
+// producer package to every topic
+var ProducerDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "producer_deliveries",
+	Help: "The total number of delivered, retried, failed and rejected messages produced to Kafka topics",
+}, []string{"topic", "result"})
+
 // WrittenReports shows number of reports written into the database
 var WrittenReports = promauto.NewCounter(prometheus.CounterOpts{
 	Name: "written_reports",
 	Help: "The total number of reports written to the storage",
 })
 
+// Results of writes of reports, values of the result label of
+// WrittenReportsByResult
+const (
+	// ReportWriteWritten is counted when the report was written
+	ReportWriteWritten = "written"
+	// ReportWriteSkippedOld is counted when a more recent report of the
+	// cluster was already stored
+	ReportWriteSkippedOld = "skipped_old"
+	// ReportWriteDuplicate is counted when the report with the same time of
+	// the last check was already stored
+	ReportWriteDuplicate = "duplicate"
+	// ReportWriteValidationFailed is counted when the report was rejected as
+	// invalid before it was written
+	ReportWriteValidationFailed = "validation_failed"
+	// ReportWriteDBError is counted when the write failed in the database
+	ReportWriteDBError = "db_error"
+)
+
+// WrittenReportsByResult shows results of all attempts to write reports,
+// unlike WrittenReports it counts reports that were not written too, labeled
+// by the result
+var WrittenReportsByResult = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "written_reports_by_result",
+	Help: "The total number of attempts to write reports to the storage by result",
+}, []string{"result"})
+
 // FeedbackOnRules shows how many times users left feedback on rules
 var FeedbackOnRules = promauto.NewCounter(prometheus.CounterOpts{
 	Name: "feedback_on_rules",
@@ -105,6 +164,202 @@ var SQLQueriesDurations = promauto.NewHistogramVec(prometheus.HistogramOpts{
 	Help: "SQL queries durations",
 }, []string{"query"})
 
+// StoredReports shows number of reports stored in the database, it is
+// updated periodically by the scheduler
+var StoredReports = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "stored_reports",
+	Help: "Number of reports stored in the database",
+})
+
+// StaleClusters shows number of clusters whose last report is older than
+// the configured threshold, it is updated periodically by the scheduler
+var StaleClusters = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "stale_clusters",
+	Help: "Number of clusters that haven't sent a report for a long time",
+})
+
+// ConsumerBufferedMessages shows number of messages fetched from Kafka that
+// wait in the consumer buffer for processing
+var ConsumerBufferedMessages = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "consumer_buffered_messages",
+	Help: "Number of consumed messages waiting for processing",
+})
+
+// ConsumerBufferFull shows how many times the consumer buffer became full,
+// so fetching of messages from Kafka had to wait for their processing. The
+// buffer staying full is counted once.
+var ConsumerBufferFull = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "consumer_buffer_full",
+	Help: "The total number of times the consumer buffer was full",
+})
+
+// StaleReportWrites shows how many reports were rejected because a more
+// recent report of the cluster was already stored, labeled by organization
+var StaleReportWrites = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "stale_report_writes",
+	Help: "The total number of reports rejected because a more recent report was already stored",
+}, []string{"org_id"})
+
+// APIRequestDurations shows durations of REST API requests, labeled by
+// endpoint (path template of the route)
+var APIRequestDurations = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name: "api_request_durations",
+	Help: "REST API requests durations",
+}, []string{"endpoint"})
+
+// ClustersLastCheckedCacheRejections shows how many reports were rejected
+// without accessing the database, because the in-memory cache of timestamps
+// when the clusters were last checked contained the same or newer timestamp
+var ClustersLastCheckedCacheRejections = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "clusters_last_checked_cache_rejections",
+	Help: "The total number of old reports rejected by the in-memory cache of last checked timestamps",
+})
+
+// ClustersLastCheckedDBRejections shows how many reports passed the in-memory
+// cache of last checked timestamps, but were rejected by the check in the
+// database transaction, because a newer report was stored (by another replica,
+// for example)
+var ClustersLastCheckedDBRejections = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "clusters_last_checked_db_rejections",
+	Help: "The total number of old reports that passed the in-memory cache and were rejected by the check in the database",
+})
+
+// ShadowReads shows results of comparisons of reads from the current storage
+// with reads from the candidate storage in shadow-read mode, labeled by the
+// storage method and the result (match, mismatch, error or skipped)
+var ShadowReads = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "shadow_reads",
+	Help: "The total number of reads compared with the candidate storage",
+}, []string{"method", "result"})
+
+// FrozenOrgDroppedMessages shows how many messages were dropped by the
+// consumer because the organization was frozen, labeled by organization
+var FrozenOrgDroppedMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "frozen_org_dropped_messages",
+	Help: "The total number of messages dropped because the organization was frozen",
+}, []string{"org_id"})
+
+// ConsumedMessagesByType shows number of parsed messages consumed from Kafka
+// labeled by the type of the message
+var ConsumedMessagesByType = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "consumed_messages_by_type",
+	Help: "The total number of parsed messages consumed from Kafka by message type",
+}, []string{"type"})
+
+// OrgRateAnomalies shows how many times the message rate of organization
+// exceeded its baseline, labeled by organization
+var OrgRateAnomalies = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "org_rate_anomalies",
+	Help: "The total number of windows with anomalous message rate of organization",
+}, []string{"org_id"})
+
+// OrgRateThrottledMessages shows how many messages were dropped because the
+// message rate of organization was anomalous, labeled by organization
+var OrgRateThrottledMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "org_rate_throttled_messages",
+	Help: "The total number of messages dropped because of anomalous message rate of organization",
+}, []string{"org_id"})
+
+// SchedulerLeader shows whether this instance of the service holds the lease
+// of the scheduler and runs its background jobs (1) or not (0)
+var SchedulerLeader = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "scheduler_leader",
+	Help: "Whether this instance runs the background jobs of the scheduler",
+})
+
+// ClusterOrgConflicts shows how many reports of clusters were received under
+// another organization than the stored report, labeled by the resolution
+// (reject, move or keep, see cluster_org_conflict_policy)
+var ClusterOrgConflicts = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "cluster_org_conflicts",
+	Help: "The total number of reports of clusters received under another organization",
+}, []string{"resolution"})
+
+// ConsumerGroupRebalances shows how many consumer group sessions were
+// started, a new session is started after every rebalance of the group
+var ConsumerGroupRebalances = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "consumer_group_rebalances",
+	Help: "The total number of consumer group sessions started after rebalance",
+})
+
+// ConsumerGroupErrors shows how many times joining the consumer group or
+// the consumer group session failed, the consumer retries them
+var ConsumerGroupErrors = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "consumer_group_errors",
+	Help: "The total number of failed consumer group sessions",
+})
+
+// ConsumerClaimedPartitions shows number of partitions claimed by this
+// instance of the consumer in the current session, labeled by topic
+var ConsumerClaimedPartitions = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "consumer_claimed_partitions",
+	Help: "Number of partitions claimed by the consumer in the current session",
+}, []string{"topic"})
+
+// InconsistentReportReads shows how many reads of the report found rule hits
+// written by another write of the report than the report itself, the read is
+// rejected as retriable
+var InconsistentReportReads = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "inconsistent_report_reads",
+	Help: "The total number of report reads rejected because rule hits belong to another generation of the report",
+})
+
+// TooLargeReportResponses shows how many responses of report endpoints were
+// rejected because they exceeded the configured maximum size
+var TooLargeReportResponses = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "too_large_report_responses",
+	Help: "The total number of report responses rejected because they exceeded the maximum size",
+}, []string{"endpoint"})
+
+// ReportsByClusterClass shows how many reports were stored, labeled by class
+// of the cluster (managed or self-managed)
+var ReportsByClusterClass = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "reports_by_cluster_class",
+	Help: "The total number of stored reports by class of the cluster",
+}, []string{"cluster_class"})
+
+// RuleHitsByClusterClass shows how many rule hits were stored, labeled by
+// class of the cluster (managed or self-managed)
+var RuleHitsByClusterClass = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "rule_hits_by_cluster_class",
+	Help: "The total number of stored rule hits by class of the cluster",
+}, []string{"cluster_class"})
+
+// ReportCacheHits shows how many reads of reports used rule hits from the
+// in-memory cache of parsed reports
+var ReportCacheHits = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "report_cache_hits",
+	Help: "The total number of report reads served by the cache of parsed reports",
+})
+
+// ReportCacheMisses shows how many reads of reports had to read and parse
+// rule hits, because they were not found in the cache of parsed reports
+var ReportCacheMisses = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "report_cache_misses",
+	Help: "The total number of report reads not found in the cache of parsed reports",
+})
+
+// CachedReportReads shows how many reads of reports were served by Redis
+// cache, labeled by result (hit, miss or error)
+var CachedReportReads = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "cached_report_reads",
+	Help: "The total number of report reads from Redis cache by result",
+}, []string{"result"})
+
+// ArchivedReports shows how many reports were moved from the database into
+// the report archive
+var ArchivedReports = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "archived_reports",
+	Help: "The total number of reports moved into the report archive",
+})
+
+// PrunedRows shows how many rows were deleted by the cleanup of old data
+// according to the retention policy, labeled by table
+var PrunedRows = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "pruned_rows",
+	Help: "The total number of rows deleted by the retention cleanup by table",
+}, []string{"table"})
+
 // AddMetricsWithNamespace register the desired metrics using a given namespace
 func AddMetricsWithNamespace(namespace string) {
 	metrics.AddAPIMetricsWithNamespace(namespace)
@@ -115,10 +370,39 @@ func AddMetricsWithNamespace(namespace string) {
 	prometheus.Unregister(FailedMessagesProcessingTime)
 	prometheus.Unregister(LastCheckedTimestampLagMinutes)
 	prometheus.Unregister(ProducedMessages)
+	prometheus.Unregister(ProducerDeliveries)
 	prometheus.Unregister(WrittenReports)
+	prometheus.Unregister(WrittenReportsByResult)
 	prometheus.Unregister(FeedbackOnRules)
 	prometheus.Unregister(SQLQueriesCounter)
 	prometheus.Unregister(SQLQueriesDurations)
+	prometheus.Unregister(StoredReports)
+	prometheus.Unregister(StaleClusters)
+	prometheus.Unregister(ConsumerBufferedMessages)
+	prometheus.Unregister(ConsumerBufferFull)
+	prometheus.Unregister(StaleReportWrites)
+	prometheus.Unregister(APIRequestDurations)
+	prometheus.Unregister(ClustersLastCheckedCacheRejections)
+	prometheus.Unregister(ClustersLastCheckedDBRejections)
+	prometheus.Unregister(ShadowReads)
+	prometheus.Unregister(FrozenOrgDroppedMessages)
+	prometheus.Unregister(ConsumedMessagesByType)
+	prometheus.Unregister(OrgRateAnomalies)
+	prometheus.Unregister(OrgRateThrottledMessages)
+	prometheus.Unregister(SchedulerLeader)
+	prometheus.Unregister(ClusterOrgConflicts)
+	prometheus.Unregister(ConsumerGroupRebalances)
+	prometheus.Unregister(ConsumerGroupErrors)
+	prometheus.Unregister(ConsumerClaimedPartitions)
+	prometheus.Unregister(InconsistentReportReads)
+	prometheus.Unregister(TooLargeReportResponses)
+	prometheus.Unregister(ReportsByClusterClass)
+	prometheus.Unregister(RuleHitsByClusterClass)
+	prometheus.Unregister(ReportCacheHits)
+	prometheus.Unregister(ReportCacheMisses)
+	prometheus.Unregister(CachedReportReads)
+	prometheus.Unregister(ArchivedReports)
+	prometheus.Unregister(PrunedRows)
 
 	ConsumedMessages = promauto.NewCounter(prometheus.CounterOpts{
 		Namespace: namespace,
@@ -150,11 +434,21 @@ func AddMetricsWithNamespace(namespace string) {
 		Name:      "produced_messages",
 		Help:      "The total number of produced messages sent to Payload Tracker's Kafka topic",
 	})
+	ProducerDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "producer_deliveries",
+		Help:      "The total number of delivered, retried, failed and rejected messages produced to Kafka topics",
+	}, []string{"topic", "result"})
 	WrittenReports = promauto.NewCounter(prometheus.CounterOpts{
 		Namespace: namespace,
 		Name:      "written_reports",
 		Help:      "The total number of reports written to the storage",
 	})
+	WrittenReportsByResult = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "written_reports_by_result",
+		Help:      "The total number of attempts to write reports to the storage by result",
+	}, []string{"result"})
 	FeedbackOnRules = promauto.NewCounter(prometheus.CounterOpts{
 		Namespace: namespace,
 		Name:      "feedback_on_rules",
@@ -170,4 +464,139 @@ func AddMetricsWithNamespace(namespace string) {
 		Name:      "sql_queries_durations",
 		Help:      "SQL queries durations",
 	}, []string{"query"})
+	StoredReports = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "stored_reports",
+		Help:      "Number of reports stored in the database",
+	})
+	StaleClusters = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "stale_clusters",
+		Help:      "Number of clusters that haven't sent a report for a long time",
+	})
+	ConsumerBufferedMessages = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "consumer_buffered_messages",
+		Help:      "Number of consumed messages waiting for processing",
+	})
+	ConsumerBufferFull = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "consumer_buffer_full",
+		Help:      "The total number of times the consumer buffer was full",
+	})
+	StaleReportWrites = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "stale_report_writes",
+		Help:      "The total number of reports rejected because a more recent report was already stored",
+	}, []string{"org_id"})
+	APIRequestDurations = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "api_request_durations",
+		Help:      "REST API requests durations",
+	}, []string{"endpoint"})
+	ClustersLastCheckedCacheRejections = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "clusters_last_checked_cache_rejections",
+		Help:      "The total number of old reports rejected by the in-memory cache of last checked timestamps",
+	})
+	ClustersLastCheckedDBRejections = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "clusters_last_checked_db_rejections",
+		Help:      "The total number of old reports that passed the in-memory cache and were rejected by the check in the database",
+	})
+	ShadowReads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "shadow_reads",
+		Help:      "The total number of reads compared with the candidate storage",
+	}, []string{"method", "result"})
+	FrozenOrgDroppedMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "frozen_org_dropped_messages",
+		Help:      "The total number of messages dropped because the organization was frozen",
+	}, []string{"org_id"})
+	ConsumedMessagesByType = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "consumed_messages_by_type",
+		Help:      "The total number of parsed messages consumed from Kafka by message type",
+	}, []string{"type"})
+	OrgRateAnomalies = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "org_rate_anomalies",
+		Help:      "The total number of windows with anomalous message rate of organization",
+	}, []string{"org_id"})
+	OrgRateThrottledMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "org_rate_throttled_messages",
+		Help:      "The total number of messages dropped because of anomalous message rate of organization",
+	}, []string{"org_id"})
+	SchedulerLeader = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "scheduler_leader",
+		Help:      "Whether this instance runs the background jobs of the scheduler",
+	})
+	ClusterOrgConflicts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cluster_org_conflicts",
+		Help:      "The total number of reports of clusters received under another organization",
+	}, []string{"resolution"})
+	ConsumerGroupRebalances = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "consumer_group_rebalances",
+		Help:      "The total number of consumer group sessions started after rebalance",
+	})
+	ConsumerGroupErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "consumer_group_errors",
+		Help:      "The total number of failed consumer group sessions",
+	})
+	ConsumerClaimedPartitions = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "consumer_claimed_partitions",
+		Help:      "Number of partitions claimed by the consumer in the current session",
+	}, []string{"topic"})
+	InconsistentReportReads = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "inconsistent_report_reads",
+		Help:      "The total number of report reads rejected because rule hits belong to another generation of the report",
+	})
+	TooLargeReportResponses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "too_large_report_responses",
+		Help:      "The total number of report responses rejected because they exceeded the maximum size",
+	}, []string{"endpoint"})
+	ReportsByClusterClass = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reports_by_cluster_class",
+		Help:      "The total number of stored reports by class of the cluster",
+	}, []string{"cluster_class"})
+	RuleHitsByClusterClass = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rule_hits_by_cluster_class",
+		Help:      "The total number of stored rule hits by class of the cluster",
+	}, []string{"cluster_class"})
+	ReportCacheHits = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "report_cache_hits",
+		Help:      "The total number of report reads served by the cache of parsed reports",
+	})
+	ReportCacheMisses = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "report_cache_misses",
+		Help:      "The total number of report reads not found in the cache of parsed reports",
+	})
+	CachedReportReads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cached_report_reads",
+		Help:      "The total number of report reads from Redis cache by result",
+	}, []string{"result"})
+	ArchivedReports = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "archived_reports",
+		Help:      "The total number of reports moved into the report archive",
+	})
+	PrunedRows = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "pruned_rows",
+		Help:      "The total number of rows deleted by the retention cleanup by table",
+	}, []string{"table"})
 }
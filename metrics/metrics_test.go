@@ -31,6 +31,7 @@ import (
 	"github.com/RedHatInsights/insights-results-aggregator/broker"
 	"github.com/RedHatInsights/insights-results-aggregator/metrics"
 	"github.com/RedHatInsights/insights-results-aggregator/producer"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
 )
@@ -142,6 +143,151 @@ func TestWrittenReportsMetric(t *testing.T) {
 	assertCounterValue(t, 100, metrics.WrittenReports, initValue)
 }
 
+// TestClustersLastCheckedRejectionsMetrics tests that old reports rejected by
+// the in-memory cache and by the check in the database are counted separately
+func TestClustersLastCheckedRejectionsMetrics(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	// other tests may run at the same process
+	initCacheValue := int64(getCounterValue(metrics.ClustersLastCheckedCacheRejections))
+	initDBValue := int64(getCounterValue(metrics.ClustersLastCheckedDBRejections))
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, 0,
+	)
+	helpers.FailOnError(t, err)
+
+	// the same report is rejected by the cache
+	err = mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, 0,
+	)
+	assert.Equal(t, types.ErrOldReport, err)
+
+	assertCounterValue(t, 1, metrics.ClustersLastCheckedCacheRejections, initCacheValue)
+	assertCounterValue(t, 0, metrics.ClustersLastCheckedDBRejections, initDBValue)
+
+	// another storage (replica) with empty cache has to check the database
+	connection := mockStorage.(*storage.DBStorage).GetConnection()
+	replicaStorage := storage.NewFromConnection(connection, mockStorage.GetDBDriverType())
+
+	err = replicaStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt.Add(-time.Hour), 0,
+	)
+	assert.Equal(t, types.ErrOldReport, err)
+
+	assertCounterValue(t, 1, metrics.ClustersLastCheckedCacheRejections, initCacheValue)
+	assertCounterValue(t, 1, metrics.ClustersLastCheckedDBRejections, initDBValue)
+}
+
+// getWrittenReportsByResult returns values of all results of writes of reports
+func getWrittenReportsByResult() map[string]float64 {
+	values := make(map[string]float64)
+	for _, result := range []string{
+		metrics.ReportWriteWritten,
+		metrics.ReportWriteSkippedOld,
+		metrics.ReportWriteDuplicate,
+		metrics.ReportWriteValidationFailed,
+		metrics.ReportWriteDBError,
+	} {
+		values[result] = getCounterVecValue(metrics.WrittenReportsByResult, map[string]string{"result": result})
+	}
+
+	return values
+}
+
+// assertWrittenReportsByResult checks increments of results of writes of
+// reports since the initial values
+func assertWrittenReportsByResult(t testing.TB, expected, initValues map[string]float64) {
+	for result, value := range getWrittenReportsByResult() {
+		assert.Equal(t, initValues[result]+expected[result], value, result)
+	}
+}
+
+func TestWrittenReportsByResultMetric(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	// other tests may run at the same process
+	initValues := getWrittenReportsByResult()
+
+	writeReport := func(lastChecked time.Time) error {
+		return mockStorage.WriteReportForCluster(
+			testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, lastChecked, 0,
+		)
+	}
+
+	helpers.FailOnError(t, writeReport(testdata.LastCheckedAt))
+
+	// the same report is counted as duplicate, older one as skipped, the
+	// caller gets ErrOldReport in both cases
+	assert.Equal(t, types.ErrOldReport, writeReport(testdata.LastCheckedAt))
+	assert.Equal(t, types.ErrOldReport, writeReport(testdata.LastCheckedAt.Add(-time.Hour)))
+
+	helpers.FailOnError(t, mockStorage.Close())
+	assert.Error(t, writeReport(testdata.LastCheckedAt.Add(time.Hour)))
+
+	assertWrittenReportsByResult(t, map[string]float64{
+		metrics.ReportWriteWritten:    1,
+		metrics.ReportWriteDuplicate:  1,
+		metrics.ReportWriteSkippedOld: 1,
+		metrics.ReportWriteDBError:    1,
+	}, initValues)
+}
+
+// TestWrittenReportsByResultMetricConsumer tests that messages failing the
+// validation are counted together with results of writes
+func TestWrittenReportsByResultMetricConsumer(t *testing.T) {
+	helpers.RunTestWithTimeout(t, func(t testing.TB) {
+		mockConsumer, closer := ira_helpers.MustGetMockKafkaConsumerWithExpectedMessages(
+			t, testTopicName, testOrgAllowlist,
+			[]string{testdata.ConsumerMessage, "bad message", testdata.ConsumerMessage},
+		)
+		defer closer()
+
+		initValues := getWrittenReportsByResult()
+
+		go mockConsumer.Serve()
+
+		ira_helpers.WaitForMockConsumerToHaveNConsumedMessages(mockConsumer, 3)
+
+		assertWrittenReportsByResult(t, map[string]float64{
+			metrics.ReportWriteWritten:          1,
+			metrics.ReportWriteValidationFailed: 1,
+			metrics.ReportWriteDuplicate:        1,
+		}, initValues)
+	}, testCaseTimeLimit)
+}
+
+// TestPrunedRowsMetric tests that rows deleted by the retention cleanup are
+// counted by table
+func TestPrunedRowsMetric(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	reportLabels := map[string]string{"table": "report"}
+	consumerErrorLabels := map[string]string{"table": "consumer_error"}
+
+	// other tests may run at the same process
+	initReports := getCounterVecValue(metrics.PrunedRows, reportLabels)
+	initConsumerErrors := getCounterVecValue(metrics.PrunedRows, consumerErrorLabels)
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed,
+		testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	_, err = mockStorage.CleanupOldData(storage.RetentionPolicy{
+		ReportsNotCheckedSince:       testdata.LastCheckedAt.Add(time.Hour),
+		ConsumerErrorsConsumedBefore: time.Now(),
+	})
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, initReports+1, getCounterVecValue(metrics.PrunedRows, reportLabels))
+	assert.Equal(t, initConsumerErrors, getCounterVecValue(metrics.PrunedRows, consumerErrorLabels))
+}
+
 // TODO: write tests for sql queries metrics
 // - SQLQueriesCounter
 // - SQLQueriesDurations
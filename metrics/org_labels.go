@@ -0,0 +1,147 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync"
+)
+
+const (
+	// OrgLabelModeID labels metrics by organization ID, every organization
+	// gets its own time series
+	OrgLabelModeID = "org_id"
+	// OrgLabelModeTop keeps organization ID only for the top N organizations
+	// with the most observations, all other organizations share one label
+	OrgLabelModeTop = "top"
+	// OrgLabelModeHash hashes organization IDs into a fixed number of buckets
+	OrgLabelModeHash = "hash"
+
+	// OtherOrgsLabel is the label shared by organizations that are not
+	// among the top N organizations
+	OtherOrgsLabel = "other"
+)
+
+// OrgLabeler converts organization IDs into values of the org_id label so
+// the number of time series of metrics labeled by organization is bounded
+type OrgLabeler struct {
+	mode        string
+	topN        int
+	hashBuckets uint32
+	// counts contains number of observations of every organization
+	counts map[uint64]uint64
+	// top contains organizations that currently have their own label
+	top   map[uint64]struct{}
+	mutex sync.Mutex
+}
+
+// orgLabeler is used by OrgLabel, organization IDs are used as they are by
+// default
+var orgLabeler = &OrgLabeler{mode: OrgLabelModeID}
+
+// NewOrgLabeler constructs organization labeler for the given mode. topN is
+// used in the "top" mode only, hashBuckets in the "hash" mode only. Empty mode
+// means "org_id".
+func NewOrgLabeler(mode string, topN, hashBuckets int) (*OrgLabeler, error) {
+	switch mode {
+	case "", OrgLabelModeID:
+		return &OrgLabeler{mode: OrgLabelModeID}, nil
+	case OrgLabelModeTop:
+		if topN <= 0 {
+			return nil, fmt.Errorf("number of top organizations has to be positive, got %d", topN)
+		}
+		return &OrgLabeler{
+			mode:   mode,
+			topN:   topN,
+			counts: map[uint64]uint64{},
+			top:    map[uint64]struct{}{},
+		}, nil
+	case OrgLabelModeHash:
+		if hashBuckets <= 0 {
+			return nil, fmt.Errorf("number of hash buckets has to be positive, got %d", hashBuckets)
+		}
+		return &OrgLabeler{mode: mode, hashBuckets: uint32(hashBuckets)}, nil
+	default:
+		return nil, fmt.Errorf("unknown organization label mode '%s'", mode)
+	}
+}
+
+// SetOrgLabeler sets the labeler used by OrgLabel, it's meant to be called
+// once during the service start
+func SetOrgLabeler(labeler *OrgLabeler) {
+	orgLabeler = labeler
+}
+
+// OrgLabel returns value of the org_id label for the organization
+func OrgLabel(orgID uint64) string {
+	return orgLabeler.Label(orgID)
+}
+
+// Label returns value of the org_id label for the organization and records
+// the observation of the organization
+func (labeler *OrgLabeler) Label(orgID uint64) string {
+	switch labeler.mode {
+	case OrgLabelModeTop:
+		return labeler.topLabel(orgID)
+	case OrgLabelModeHash:
+		hash := fnv.New32a()
+		_, _ = hash.Write([]byte(strconv.FormatUint(orgID, 10)))
+		return fmt.Sprintf("hash-%d", hash.Sum32()%labeler.hashBuckets)
+	default:
+		return strconv.FormatUint(orgID, 10)
+	}
+}
+
+// topLabel counts the observation and returns organization ID when the
+// organization is among the top N organizations. An organization outside
+// top N replaces the top organization with the least observations as soon as
+// it has more observations, the replaced organization's time series then
+// stops growing and its observations go to "other".
+func (labeler *OrgLabeler) topLabel(orgID uint64) string {
+	labeler.mutex.Lock()
+	defer labeler.mutex.Unlock()
+
+	labeler.counts[orgID]++
+
+	if _, found := labeler.top[orgID]; found {
+		return strconv.FormatUint(orgID, 10)
+	}
+
+	if len(labeler.top) < labeler.topN {
+		labeler.top[orgID] = struct{}{}
+		return strconv.FormatUint(orgID, 10)
+	}
+
+	var leastOrgID uint64
+	leastCount := ^uint64(0)
+	for topOrgID := range labeler.top {
+		count := labeler.counts[topOrgID]
+		if count < leastCount || (count == leastCount && topOrgID < leastOrgID) {
+			leastOrgID, leastCount = topOrgID, count
+		}
+	}
+
+	if labeler.counts[orgID] > leastCount {
+		delete(labeler.top, leastOrgID)
+		labeler.top[orgID] = struct{}{}
+		return strconv.FormatUint(orgID, 10)
+	}
+
+	return OtherOrgsLabel
+}
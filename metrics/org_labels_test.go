@@ -0,0 +1,80 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics_test
+
+import (
+	"testing"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+)
+
+// TestOrgLabelerOrgID checks that organization IDs are used as they are by
+// default
+func TestOrgLabelerOrgID(t *testing.T) {
+	labeler, err := metrics.NewOrgLabeler("", 0, 0)
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, "42", labeler.Label(42))
+	assert.Equal(t, "43", labeler.Label(43))
+}
+
+// TestOrgLabelerTop checks that only the top N organizations keep their IDs
+// and that an organization with more observations replaces the least
+// observed one
+func TestOrgLabelerTop(t *testing.T) {
+	labeler, err := metrics.NewOrgLabeler(metrics.OrgLabelModeTop, 2, 0)
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, "1", labeler.Label(1))
+	assert.Equal(t, "1", labeler.Label(1))
+	assert.Equal(t, "2", labeler.Label(2))
+	assert.Equal(t, metrics.OtherOrgsLabel, labeler.Label(3))
+
+	// organization 3 has more observations than organization 2 now
+	assert.Equal(t, "3", labeler.Label(3))
+	assert.Equal(t, metrics.OtherOrgsLabel, labeler.Label(2))
+	assert.Equal(t, "1", labeler.Label(1))
+}
+
+// TestOrgLabelerHash checks that organizations are hashed into the configured
+// number of buckets and that one organization always gets the same bucket
+func TestOrgLabelerHash(t *testing.T) {
+	labeler, err := metrics.NewOrgLabeler(metrics.OrgLabelModeHash, 0, 4)
+	helpers.FailOnError(t, err)
+
+	labels := map[string]bool{}
+	for orgID := uint64(1); orgID <= 100; orgID++ {
+		labels[labeler.Label(orgID)] = true
+	}
+
+	assert.LessOrEqual(t, len(labels), 4)
+	assert.Equal(t, labeler.Label(42), labeler.Label(42))
+}
+
+// TestOrgLabelerInvalidConfiguration checks that invalid configuration is
+// rejected
+func TestOrgLabelerInvalidConfiguration(t *testing.T) {
+	_, err := metrics.NewOrgLabeler(metrics.OrgLabelModeTop, 0, 0)
+	assert.Error(t, err)
+
+	_, err = metrics.NewOrgLabeler(metrics.OrgLabelModeHash, 0, 0)
+	assert.Error(t, err)
+
+	_, err = metrics.NewOrgLabeler("unknown", 10, 10)
+	assert.EqualError(t, err, "unknown organization label mode 'unknown'")
+}
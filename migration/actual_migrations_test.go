@@ -19,6 +19,7 @@ import (
 	"database/sql/driver"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
@@ -365,3 +366,784 @@ func TestMigration13(t *testing.T) {
 	assertRule(testdata.Rule2ID, testdata.ErrorKey2, helpers.ToJSONString(testdata.Rule2ExtraData))
 	assertRule(testdata.Rule3ID, testdata.ErrorKey3, helpers.ToJSONString(testdata.Rule3ExtraData))
 }
+
+func TestMigration16(t *testing.T) {
+	db, dbDriver, closer := prepareDBAndInfo(t)
+	defer closer()
+
+	err := migration.SetDBVersion(db, dbDriver, 15)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`
+		INSERT INTO report (org_id, cluster, report, reported_at, last_checked_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`,
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.ClusterReport3Rules,
+		testdata.LastCheckedAt,
+		testdata.LastCheckedAt,
+	)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`
+		INSERT INTO rule_hit (org_id, cluster_id, rule_fqdn, error_key, template_data)
+		VALUES ($1, $2, $3, $4, $5)
+	`,
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.Rule1ID,
+		testdata.ErrorKey1,
+		"{}",
+	)
+	helpers.FailOnError(t, err)
+
+	err = migration.SetDBVersion(db, dbDriver, 16)
+	helpers.FailOnError(t, err)
+
+	var count int
+	err = db.QueryRow(`
+		SELECT
+			COUNT(*)
+		FROM
+			rule_hit_history
+		WHERE
+			org_id = $1 AND cluster_id = $2 AND
+			rule_fqdn = $3 AND error_key = $4 AND
+			disappeared_at IS NULL
+	`,
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.Rule1ID,
+		testdata.ErrorKey1,
+	).Scan(&count)
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, 1, count)
+
+	err = migration.SetDBVersion(db, dbDriver, 15)
+	helpers.FailOnError(t, err)
+}
+
+func TestMigration17(t *testing.T) {
+	db, dbDriver, closer := prepareDBAndInfo(t)
+	defer closer()
+
+	err := migration.SetDBVersion(db, dbDriver, 17)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`
+		INSERT INTO cluster_annotation (annotation_id, cluster_id, author, message, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`,
+		"c8d2b1b3-5e2a-4a7b-9d5c-0a3c2f6b2d11",
+		testdata.ClusterName,
+		testdata.UserID,
+		"note",
+		testdata.LastCheckedAt,
+	)
+	helpers.FailOnError(t, err)
+
+	err = migration.SetDBVersion(db, dbDriver, 16)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`SELECT annotation_id FROM cluster_annotation`)
+	assert.Error(t, err, "cluster_annotation table should not exist")
+}
+
+func TestMigration18(t *testing.T) {
+	db, dbDriver, closer := prepareDBAndInfo(t)
+	defer closer()
+
+	err := migration.SetDBVersion(db, dbDriver, 17)
+	helpers.FailOnError(t, err)
+
+	firstReportedAt := testdata.LastCheckedAt.Add(-time.Hour).UTC()
+	lastReportedAt := testdata.LastCheckedAt.UTC()
+
+	for _, reportedAt := range []time.Time{lastReportedAt, firstReportedAt} {
+		_, err = db.Exec(`
+			INSERT INTO report (org_id, cluster, report, reported_at, last_checked_at)
+			VALUES ($1, $2, $3, $4, $5)
+		`,
+			testdata.OrgID,
+			testdata.GetRandomClusterID(),
+			testdata.ClusterReport3Rules,
+			reportedAt,
+			testdata.LastCheckedAt,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	err = migration.SetDBVersion(db, dbDriver, 18)
+	helpers.FailOnError(t, err)
+
+	var firstSeenAt, lastSeenAt time.Time
+
+	err = db.QueryRow(
+		"SELECT first_seen_at, last_seen_at FROM org_info WHERE org_id = $1", testdata.OrgID,
+	).Scan(&firstSeenAt, &lastSeenAt)
+	helpers.FailOnError(t, err)
+
+	assert.True(t, firstSeenAt.Equal(firstReportedAt))
+	assert.True(t, lastSeenAt.Equal(lastReportedAt))
+
+	err = migration.SetDBVersion(db, dbDriver, 17)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`SELECT org_id FROM org_info`)
+	assert.Error(t, err, "org_info table should not exist")
+}
+
+func TestMigration19(t *testing.T) {
+	db, dbDriver, closer := prepareDBAndInfo(t)
+	defer closer()
+
+	err := migration.SetDBVersion(db, dbDriver, 18)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`SELECT org_id FROM stale_report_write`)
+	assert.Error(t, err, "stale_report_write table should not exist")
+
+	err = migration.SetDBVersion(db, dbDriver, 19)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`
+		INSERT INTO stale_report_write
+			(org_id, cluster_id, rejected_count, last_rejected_at, last_checked_at, produced_at)
+		VALUES ($1, $2, $3, $4, $5, NULL)
+	`,
+		testdata.OrgID,
+		testdata.ClusterName,
+		1,
+		time.Now(),
+		testdata.LastCheckedAt,
+	)
+	helpers.FailOnError(t, err)
+
+	err = migration.SetDBVersion(db, dbDriver, 18)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`SELECT org_id FROM stale_report_write`)
+	assert.Error(t, err, "stale_report_write table should not exist")
+}
+
+func TestMigration20(t *testing.T) {
+	db, dbDriver, closer := prepareDBAndInfo(t)
+	defer closer()
+
+	err := migration.SetDBVersion(db, dbDriver, 19)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`SELECT org_id FROM org_usage`)
+	assert.Error(t, err, "org_usage table should not exist")
+
+	err = migration.SetDBVersion(db, dbDriver, 20)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`
+		INSERT INTO org_usage
+			(org_id, month, messages_processed, bytes_stored, api_calls)
+		VALUES ($1, $2, $3, $4, $5)
+	`,
+		testdata.OrgID,
+		"2020-10",
+		1,
+		1024,
+		2,
+	)
+	helpers.FailOnError(t, err)
+
+	err = migration.SetDBVersion(db, dbDriver, 19)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`SELECT org_id FROM org_usage`)
+	assert.Error(t, err, "org_usage table should not exist")
+}
+
+func TestMigration21(t *testing.T) {
+	db, dbDriver, closer := prepareDBAndInfo(t)
+	defer closer()
+
+	err := migration.SetDBVersion(db, dbDriver, 20)
+	helpers.FailOnError(t, err)
+
+	for _, rule := range []struct {
+		ruleID        types.RuleID
+		disappearedAt interface{}
+	}{
+		{testdata.Rule1ID, testdata.LastCheckedAt},
+		{testdata.Rule2ID, nil},
+	} {
+		_, err = db.Exec(`
+			INSERT INTO rule_hit_history (org_id, cluster_id, rule_fqdn, error_key, appeared_at, disappeared_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`,
+			testdata.OrgID,
+			testdata.ClusterName,
+			rule.ruleID,
+			testdata.ErrorKey1,
+			testdata.LastCheckedAt.Add(-time.Hour),
+			rule.disappearedAt,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	err = migration.SetDBVersion(db, dbDriver, 21)
+	helpers.FailOnError(t, err)
+
+	// only the occurrence that already disappeared is resolved
+	var ruleID types.RuleID
+	err = db.QueryRow(`SELECT rule_fqdn FROM rule_hit_resolution`).Scan(&ruleID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.Rule1ID, ruleID)
+
+	err = migration.SetDBVersion(db, dbDriver, 20)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`SELECT org_id FROM rule_hit_resolution`)
+	assert.Error(t, err, "rule_hit_resolution table should not exist")
+}
+
+func TestMigration22(t *testing.T) {
+	db, dbDriver, closer := prepareDBAndInfo(t)
+	defer closer()
+
+	err := migration.SetDBVersion(db, dbDriver, 22)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`
+		INSERT INTO consumer_offset (topic, partition, kafka_offset, updated_at)
+		VALUES ($1, $2, $3, $4)
+	`, "ccx.ocp.results", 0, testdata.KafkaOffset, testdata.LastCheckedAt)
+	helpers.FailOnError(t, err)
+
+	err = migration.SetDBVersion(db, dbDriver, 21)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`SELECT topic FROM consumer_offset`)
+	assert.Error(t, err, "consumer_offset table should not exist")
+}
+
+func TestMigration23(t *testing.T) {
+	db, dbDriver, closer := prepareDBAndInfo(t)
+	defer closer()
+
+	err := migration.SetDBVersion(db, dbDriver, 22)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`
+		INSERT INTO report (org_id, cluster, report, reported_at, last_checked_at, kafka_offset)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`,
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.ClusterReportEmpty,
+		testdata.LastCheckedAt,
+		testdata.LastCheckedAt,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	err = migration.SetDBVersion(db, dbDriver, 23)
+	helpers.FailOnError(t, err)
+
+	// reports stored before the migration were analyzed successfully
+	var status types.ReportStatus
+	err = db.QueryRow(`SELECT status FROM report WHERE cluster = $1`, testdata.ClusterName).Scan(&status)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.ReportStatusAnalyzed, status)
+
+	err = migration.SetDBVersion(db, dbDriver, 22)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`SELECT status FROM report`)
+	assert.Error(t, err, "status column should not exist")
+
+	var kafkaOffset types.KafkaOffset
+	err = db.QueryRow(`SELECT kafka_offset FROM report WHERE cluster = $1`, testdata.ClusterName).Scan(&kafkaOffset)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.KafkaOffset, kafkaOffset)
+}
+
+func TestMigration24(t *testing.T) {
+	db, dbDriver, closer := prepareDBAndInfo(t)
+	defer closer()
+
+	indexQuery := `SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = $1`
+	if dbDriver == types.DBDriverPostgres {
+		indexQuery = `SELECT COUNT(*) FROM pg_indexes WHERE schemaname = current_schema() AND indexname = $1`
+	}
+
+	indexes := []string{
+		"report_org_id_reported_at_idx",
+		"rule_hit_cluster_id_idx",
+		"cluster_rule_toggle_cluster_id_rule_id_idx",
+	}
+
+	assertIndexes := func(expectedCount int) {
+		for _, index := range indexes {
+			var count int
+			err := db.QueryRow(indexQuery, index).Scan(&count)
+			helpers.FailOnError(t, err)
+			assert.Equal(t, expectedCount, count, index)
+		}
+	}
+
+	err := migration.SetDBVersion(db, dbDriver, 23)
+	helpers.FailOnError(t, err)
+	assertIndexes(0)
+
+	err = migration.SetDBVersion(db, dbDriver, 24)
+	helpers.FailOnError(t, err)
+	assertIndexes(1)
+
+	err = migration.SetDBVersion(db, dbDriver, 23)
+	helpers.FailOnError(t, err)
+	assertIndexes(0)
+}
+
+func TestMigration25(t *testing.T) {
+	db, dbDriver, closer := prepareDBAndInfo(t)
+	defer closer()
+
+	err := migration.SetDBVersion(db, dbDriver, 25)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`
+		INSERT INTO org_freeze (org_id, reason, frozen_at)
+		VALUES ($1, $2, $3)
+	`, testdata.OrgID, "legal hold", testdata.LastCheckedAt)
+	helpers.FailOnError(t, err)
+
+	err = migration.SetDBVersion(db, dbDriver, 24)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`SELECT org_id FROM org_freeze`)
+	assert.Error(t, err, "org_freeze table should not exist")
+}
+
+func TestMigration26(t *testing.T) {
+	db, dbDriver, closer := prepareDBAndInfo(t)
+	defer closer()
+
+	err := migration.SetDBVersion(db, dbDriver, 25)
+	helpers.FailOnError(t, err)
+
+	firstSeenAt := testdata.LastCheckedAt.Add(-time.Hour)
+
+	_, err = db.Exec(`
+		INSERT INTO report (org_id, cluster, report, reported_at, last_checked_at, kafka_offset)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`,
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.ClusterReportEmpty,
+		testdata.LastCheckedAt,
+		testdata.LastCheckedAt,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	for _, ruleID := range []types.RuleID{testdata.Rule1ID, testdata.Rule2ID} {
+		_, err = db.Exec(`
+			INSERT INTO rule_hit (org_id, cluster_id, rule_fqdn, error_key, template_data)
+			VALUES ($1, $2, $3, $4, $5)
+		`, testdata.OrgID, testdata.ClusterName, ruleID, testdata.ErrorKey1, "{}")
+		helpers.FailOnError(t, err)
+	}
+
+	// only the first rule has the history
+	_, err = db.Exec(`
+		INSERT INTO rule_hit_history (org_id, cluster_id, rule_fqdn, error_key, appeared_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, testdata.OrgID, testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, firstSeenAt)
+	helpers.FailOnError(t, err)
+
+	err = migration.SetDBVersion(db, dbDriver, 26)
+	helpers.FailOnError(t, err)
+
+	for ruleID, expected := range map[types.RuleID]time.Time{
+		testdata.Rule1ID: firstSeenAt,
+		testdata.Rule2ID: testdata.LastCheckedAt,
+	} {
+		var got time.Time
+		err = db.QueryRow(
+			`SELECT first_seen_at FROM rule_hit WHERE rule_fqdn = $1`, ruleID,
+		).Scan(&got)
+		helpers.FailOnError(t, err)
+		assert.True(t, expected.Equal(got), ruleID)
+	}
+
+	err = migration.SetDBVersion(db, dbDriver, 25)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`SELECT first_seen_at FROM rule_hit`)
+	assert.Error(t, err, "first_seen_at column should not exist")
+
+	var count int
+	err = db.QueryRow(`SELECT COUNT(*) FROM rule_hit`).Scan(&count)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestMigration26_InterruptedBackfill(t *testing.T) {
+	db, dbDriver, closer := prepareDBAndInfo(t)
+	defer closer()
+
+	err := migration.SetDBVersion(db, dbDriver, 26)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`
+		INSERT INTO report (org_id, cluster, report, reported_at, last_checked_at, kafka_offset)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`,
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.ClusterReportEmpty,
+		testdata.LastCheckedAt,
+		testdata.LastCheckedAt,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`
+		INSERT INTO rule_hit (org_id, cluster_id, rule_fqdn, error_key, template_data)
+		VALUES ($1, $2, $3, $4, $5)
+	`, testdata.OrgID, testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, "{}")
+	helpers.FailOnError(t, err)
+
+	// the backfill was interrupted before it got to the rule hit
+	_, err = db.Exec(`UPDATE migration_online_info SET version = 25`)
+	helpers.FailOnError(t, err)
+
+	// the pending online step is finished even when the version doesn't change
+	err = migration.SetDBVersion(db, dbDriver, 26)
+	helpers.FailOnError(t, err)
+
+	var firstSeenAt time.Time
+	err = db.QueryRow(`SELECT first_seen_at FROM rule_hit`).Scan(&firstSeenAt)
+	helpers.FailOnError(t, err)
+	assert.True(t, testdata.LastCheckedAt.Equal(firstSeenAt))
+
+	var onlineVersion migration.Version
+	err = db.QueryRow(`SELECT version FROM migration_online_info`).Scan(&onlineVersion)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, migration.Version(26), onlineVersion)
+}
+
+func TestBackfillStep(t *testing.T) {
+	db, dbDriver, closer := prepareDB(t)
+	defer closer()
+
+	_, err := db.Exec(`CREATE TABLE backfill_test (k VARCHAR NOT NULL, v INTEGER NULL)`)
+	helpers.FailOnError(t, err)
+
+	for _, key := range []string{"c", "a", "b", "a"} {
+		_, err = db.Exec(`INSERT INTO backfill_test (k) VALUES ($1)`, key)
+		helpers.FailOnError(t, err)
+	}
+
+	// every batch updates one key, so three batches are needed
+	step := migration.NewBackfillStep("backfill_test", "k", `
+		UPDATE backfill_test SET v = 1 WHERE v IS NULL AND k >= $1 AND k <= $2
+	`, 1)
+	helpers.FailOnError(t, step.Run(db, dbDriver))
+
+	var notUpdated int
+	err = db.QueryRow(`SELECT COUNT(*) FROM backfill_test WHERE v IS NULL`).Scan(&notUpdated)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 0, notUpdated)
+}
+
+func TestConcurrentIndexStep(t *testing.T) {
+	db, dbDriver, closer := prepareDB(t)
+	defer closer()
+
+	_, err := db.Exec(`CREATE TABLE index_test (k VARCHAR NOT NULL)`)
+	helpers.FailOnError(t, err)
+
+	step := migration.NewConcurrentIndexStep("index_test_k_idx", "index_test", "k")
+
+	// the step is idempotent
+	helpers.FailOnError(t, step.Run(db, dbDriver))
+	helpers.FailOnError(t, step.Run(db, dbDriver))
+
+	_, err = db.Exec(`DROP INDEX index_test_k_idx`)
+	helpers.FailOnError(t, err)
+}
+
+func TestMigration27(t *testing.T) {
+	db, dbDriver, closer := prepareDBAndInfo(t)
+	defer closer()
+
+	err := migration.SetDBVersion(db, dbDriver, 27)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`
+		INSERT INTO leader_lease (name, holder, expires_at)
+		VALUES ($1, $2, $3)
+	`, "scheduler", "instance", testdata.LastCheckedAt)
+	helpers.FailOnError(t, err)
+
+	err = migration.SetDBVersion(db, dbDriver, 26)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`SELECT name FROM leader_lease`)
+	assert.Error(t, err, "leader_lease table should not exist")
+}
+
+func TestMigration28(t *testing.T) {
+	db, dbDriver, closer := prepareDBAndInfo(t)
+	defer closer()
+
+	err := migration.SetDBVersion(db, dbDriver, 28)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`
+		INSERT INTO report_check (org_id, cluster_id, checked_at, hit_count, added_count, removed_count)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, testdata.OrgID, testdata.ClusterName, testdata.LastCheckedAt, 3, 1, 2)
+	helpers.FailOnError(t, err)
+
+	err = migration.SetDBVersion(db, dbDriver, 27)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`SELECT cluster_id FROM report_check`)
+	assert.Error(t, err, "report_check table should not exist")
+}
+
+func TestMigration29(t *testing.T) {
+	db, dbDriver, closer := prepareDBAndInfo(t)
+	defer closer()
+
+	err := migration.SetDBVersion(db, dbDriver, 29)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`
+		INSERT INTO report_history (org_id, cluster_id, report, last_checked_at, reported_at, kafka_offset)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`,
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.ClusterReportEmpty,
+		testdata.LastCheckedAt,
+		testdata.LastCheckedAt,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	err = migration.SetDBVersion(db, dbDriver, 28)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`SELECT cluster_id FROM report_history`)
+	assert.Error(t, err, "report_history table should not exist")
+}
+
+func TestMigration30(t *testing.T) {
+	db, dbDriver, closer := prepareDBAndInfo(t)
+	defer closer()
+
+	err := migration.SetDBVersion(db, dbDriver, 30)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`
+		INSERT INTO api_key (key_id, name, key_hash, scopes, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`,
+		"0123456789abcdef",
+		"notification service",
+		"hash",
+		"read",
+		testdata.LastCheckedAt,
+	)
+	helpers.FailOnError(t, err)
+
+	err = migration.SetDBVersion(db, dbDriver, 29)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`SELECT key_id FROM api_key`)
+	assert.Error(t, err, "api_key table should not exist")
+}
+
+func TestMigration31(t *testing.T) {
+	db, dbDriver, closer := prepareDBAndInfo(t)
+	defer closer()
+
+	err := migration.SetDBVersion(db, dbDriver, 31)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`
+		INSERT INTO cluster_org_change (cluster_id, previous_org_id, org_id, changed_at, resolution)
+		VALUES ($1, $2, $3, $4, $5)
+	`,
+		testdata.ClusterName,
+		testdata.OrgID,
+		testdata.Org2ID,
+		testdata.LastCheckedAt,
+		"move",
+	)
+	helpers.FailOnError(t, err)
+
+	err = migration.SetDBVersion(db, dbDriver, 30)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`SELECT cluster_id FROM cluster_org_change`)
+	assert.Error(t, err, "cluster_org_change table should not exist")
+}
+
+func TestMigration32(t *testing.T) {
+	db, dbDriver, closer := prepareDBAndInfo(t)
+	defer closer()
+
+	err := migration.SetDBVersion(db, dbDriver, 32)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`
+		INSERT INTO external_report (org_id, cluster_id, source, last_checked_at, reported_at)
+		VALUES ($1, $2, $3, $4, $4)
+	`, testdata.OrgID, testdata.ClusterName, "scanner", testdata.LastCheckedAt)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`
+		INSERT INTO external_result (org_id, cluster_id, source, check_id, severity, description, details)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, testdata.OrgID, testdata.ClusterName, "scanner", "CVE-2020-1234", 3, "vulnerable image", "{}")
+	helpers.FailOnError(t, err)
+
+	err = migration.SetDBVersion(db, dbDriver, 31)
+	helpers.FailOnError(t, err)
+
+	for _, table := range []string{"external_report", "external_result"} {
+		_, err = db.Exec(`SELECT cluster_id FROM ` + table)
+		assert.Error(t, err, table+" table should not exist")
+	}
+}
+
+func TestMigration33(t *testing.T) {
+	db, dbDriver, closer := prepareDBAndInfo(t)
+	defer closer()
+
+	err := migration.SetDBVersion(db, dbDriver, 33)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`
+		INSERT INTO report (org_id, cluster, report, reported_at, last_checked_at, kafka_offset, message_key)
+		VALUES ($1, $2, $3, $4, $4, $5, $2)
+	`, testdata.OrgID, testdata.ClusterName, testdata.ClusterReportEmpty, testdata.LastCheckedAt, 1)
+	helpers.FailOnError(t, err)
+
+	err = migration.SetDBVersion(db, dbDriver, 32)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`SELECT message_key FROM report`)
+	assert.Error(t, err, "message_key column should not exist")
+
+	var kafkaOffset int64
+	err = db.QueryRow(
+		`SELECT kafka_offset FROM report WHERE cluster = $1`, testdata.ClusterName,
+	).Scan(&kafkaOffset)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, int64(1), kafkaOffset)
+}
+
+func TestMigration34(t *testing.T) {
+	db, dbDriver, closer := prepareDBAndInfo(t)
+	defer closer()
+
+	err := migration.SetDBVersion(db, dbDriver, 33)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`
+		INSERT INTO report (org_id, cluster, report, reported_at, last_checked_at, kafka_offset)
+		VALUES ($1, $2, $3, $4, $4, $5)
+	`, testdata.OrgID, testdata.ClusterName, testdata.ClusterReportEmpty, testdata.LastCheckedAt, 1)
+	helpers.FailOnError(t, err)
+
+	err = migration.SetDBVersion(db, dbDriver, 34)
+	helpers.FailOnError(t, err)
+
+	var generation int64
+	err = db.QueryRow(
+		`SELECT generation FROM report WHERE cluster = $1`, testdata.ClusterName,
+	).Scan(&generation)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, int64(0), generation)
+
+	_, err = db.Exec(`SELECT generation FROM rule_hit`)
+	helpers.FailOnError(t, err)
+
+	err = migration.SetDBVersion(db, dbDriver, 33)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`SELECT generation FROM report`)
+	assert.Error(t, err, "generation column should not exist in report table")
+
+	_, err = db.Exec(`SELECT generation FROM rule_hit`)
+	assert.Error(t, err, "generation column should not exist in rule_hit table")
+
+	var kafkaOffset int64
+	err = db.QueryRow(
+		`SELECT kafka_offset FROM report WHERE cluster = $1`, testdata.ClusterName,
+	).Scan(&kafkaOffset)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, int64(1), kafkaOffset)
+}
+
+func TestMigration35(t *testing.T) {
+	db, dbDriver, closer := prepareDBAndInfo(t)
+	defer closer()
+
+	err := migration.SetDBVersion(db, dbDriver, 35)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`
+		INSERT INTO org_rule_user_feedback
+		(org_id, rule_id, error_key, user_id, user_vote, message, added_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+	`, testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID, 1, "", testdata.LastCheckedAt)
+	helpers.FailOnError(t, err)
+
+	err = migration.SetDBVersion(db, dbDriver, 34)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`SELECT org_id FROM org_rule_user_feedback`)
+	assert.Error(t, err, "org_rule_user_feedback table should not exist")
+}
+
+func TestMigration36(t *testing.T) {
+	db, dbDriver, closer := prepareDBAndInfo(t)
+	defer closer()
+
+	err := migration.SetDBVersion(db, dbDriver, 35)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`
+		INSERT INTO report (org_id, cluster, report, reported_at, last_checked_at, kafka_offset)
+		VALUES ($1, $2, $3, $4, $4, $5)
+	`, testdata.OrgID, testdata.ClusterName, testdata.ClusterReportEmpty, testdata.LastCheckedAt, 1)
+	helpers.FailOnError(t, err)
+
+	err = migration.SetDBVersion(db, dbDriver, 36)
+	helpers.FailOnError(t, err)
+
+	var clusterClass string
+	err = db.QueryRow(
+		`SELECT cluster_class FROM report WHERE cluster = $1`, testdata.ClusterName,
+	).Scan(&clusterClass)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, "self-managed", clusterClass)
+
+	err = migration.SetDBVersion(db, dbDriver, 35)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`SELECT cluster_class FROM report`)
+	assert.Error(t, err, "cluster_class column should not exist in report table")
+
+	var kafkaOffset int64
+	err = db.QueryRow(
+		`SELECT kafka_offset FROM report WHERE cluster = $1`, testdata.ClusterName,
+	).Scan(&kafkaOffset)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, int64(1), kafkaOffset)
+}
@@ -0,0 +1,66 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mig0016AddRuleHitHistoryTable adds a table with the history of rule hits.
+// Each record represents one period of time during which the rule was
+// reported for the cluster, disappeared_at is NULL while the rule is still
+// being reported.
+var mig0016AddRuleHitHistoryTable = Migration{
+	StepUp: func(tx *sql.Tx, driver types.DBDriver) error {
+		_, err := tx.Exec(`
+		CREATE TABLE rule_hit_history (
+			org_id          INTEGER NOT NULL,
+			cluster_id      VARCHAR NOT NULL,
+			rule_fqdn       VARCHAR NOT NULL,
+			error_key       VARCHAR NOT NULL,
+			appeared_at     TIMESTAMP NOT NULL,
+			disappeared_at  TIMESTAMP NULL,
+			PRIMARY KEY(cluster_id, org_id, rule_fqdn, error_key, appeared_at)
+		)`)
+		if err != nil {
+			return err
+		}
+
+		// rules that are already stored are taken as appeared at the time
+		// of the last check of the cluster
+		_, err = tx.Exec(`
+			INSERT INTO rule_hit_history (
+				org_id, cluster_id, rule_fqdn, error_key, appeared_at
+			)
+			SELECT
+				rule_hit.org_id, rule_hit.cluster_id, rule_hit.rule_fqdn, rule_hit.error_key, report.last_checked_at
+			FROM
+				rule_hit
+			JOIN
+				report ON report.cluster = rule_hit.cluster_id AND report.org_id = rule_hit.org_id
+		`)
+		return err
+	},
+	StepDown: func(tx *sql.Tx, driver types.DBDriver) error {
+		_, err := tx.Exec(`
+			DROP TABLE rule_hit_history
+		`)
+		return err
+	},
+}
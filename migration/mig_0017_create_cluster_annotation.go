@@ -0,0 +1,48 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+var mig0017CreateClusterAnnotation = Migration{
+	StepUp: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`
+			CREATE TABLE cluster_annotation (
+				annotation_id VARCHAR NOT NULL,
+				cluster_id VARCHAR NOT NULL,
+				author VARCHAR NOT NULL,
+				message VARCHAR NOT NULL,
+				created_at TIMESTAMP NOT NULL,
+
+				PRIMARY KEY(annotation_id)
+			)`)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`CREATE INDEX cluster_annotation_cluster_id_idx ON cluster_annotation (cluster_id)`)
+		return err
+	},
+	StepDown: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`DROP TABLE cluster_annotation`)
+		return err
+	},
+}
@@ -0,0 +1,52 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mig0018CreateOrgInfo adds a table with timestamps when the organization
+// was first seen and last active. It is filled from the stored reports.
+var mig0018CreateOrgInfo = Migration{
+	StepUp: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`
+			CREATE TABLE org_info (
+				org_id INTEGER NOT NULL,
+				first_seen_at TIMESTAMP NOT NULL,
+				last_seen_at TIMESTAMP NOT NULL,
+
+				PRIMARY KEY(org_id)
+			)`)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO org_info (org_id, first_seen_at, last_seen_at)
+			SELECT org_id, MIN(reported_at), MAX(reported_at) FROM report
+			WHERE reported_at IS NOT NULL
+			GROUP BY org_id`)
+		return err
+	},
+	StepDown: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`DROP TABLE org_info`)
+		return err
+	},
+}
@@ -0,0 +1,46 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mig0019CreateStaleReportWrite adds a table with statistics of reports that
+// were rejected because a more recent report of the cluster was stored
+var mig0019CreateStaleReportWrite = Migration{
+	StepUp: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`
+			CREATE TABLE stale_report_write (
+				org_id INTEGER NOT NULL,
+				cluster_id VARCHAR NOT NULL,
+				rejected_count INTEGER NOT NULL,
+				last_rejected_at TIMESTAMP NOT NULL,
+				last_checked_at TIMESTAMP NOT NULL,
+				produced_at TIMESTAMP,
+
+				PRIMARY KEY(org_id, cluster_id)
+			)`)
+		return err
+	},
+	StepDown: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`DROP TABLE stale_report_write`)
+		return err
+	},
+}
@@ -0,0 +1,45 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mig0020CreateOrgUsage adds a table with monthly usage of the service by
+// organizations, the month is stored in YYYY-MM format
+var mig0020CreateOrgUsage = Migration{
+	StepUp: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`
+			CREATE TABLE org_usage (
+				org_id INTEGER NOT NULL,
+				month VARCHAR NOT NULL,
+				messages_processed BIGINT NOT NULL,
+				bytes_stored BIGINT NOT NULL,
+				api_calls BIGINT NOT NULL,
+
+				PRIMARY KEY(org_id, month)
+			)`)
+		return err
+	},
+	StepDown: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`DROP TABLE org_usage`)
+		return err
+	},
+}
@@ -0,0 +1,64 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mig0021CreateRuleHitResolution adds a table with resolutions of rule hits.
+// Each record represents one occasion when the rule reported for the cluster
+// disappeared from its new report, so it's possible to measure whether the
+// recommendations are acted upon.
+var mig0021CreateRuleHitResolution = Migration{
+	StepUp: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`
+			CREATE TABLE rule_hit_resolution (
+				org_id      INTEGER NOT NULL,
+				cluster_id  VARCHAR NOT NULL,
+				rule_fqdn   VARCHAR NOT NULL,
+				error_key   VARCHAR NOT NULL,
+				resolved_at TIMESTAMP NOT NULL,
+
+				PRIMARY KEY(cluster_id, org_id, rule_fqdn, error_key, resolved_at)
+			)`)
+		if err != nil {
+			return err
+		}
+
+		// occurrences of rules that already disappeared are taken as
+		// resolved at the time they disappeared
+		_, err = tx.Exec(`
+			INSERT INTO rule_hit_resolution (
+				org_id, cluster_id, rule_fqdn, error_key, resolved_at
+			)
+			SELECT
+				org_id, cluster_id, rule_fqdn, error_key, disappeared_at
+			FROM
+				rule_hit_history
+			WHERE
+				disappeared_at IS NOT NULL
+		`)
+		return err
+	},
+	StepDown: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`DROP TABLE rule_hit_resolution`)
+		return err
+	},
+}
@@ -0,0 +1,63 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mig0023AddStatusToReport adds the status of the analysis of the cluster to
+// the report table, all reports stored so far were analyzed successfully
+var mig0023AddStatusToReport = Migration{
+	StepUp: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`
+			ALTER TABLE report ADD COLUMN status VARCHAR NOT NULL DEFAULT 'analyzed'
+		`)
+		return err
+	},
+	StepDown: func(tx *sql.Tx, driver types.DBDriver) error {
+		if driver == types.DBDriverSQLite3 {
+			err := downgradeTable(tx, clusterReportTable, `
+				CREATE TABLE report (
+					org_id          INTEGER NOT NULL,
+					cluster         VARCHAR NOT NULL UNIQUE,
+					report          VARCHAR NOT NULL,
+					reported_at     TIMESTAMP,
+					last_checked_at TIMESTAMP,
+					kafka_offset    BIGINT NOT NULL DEFAULT 0,
+					PRIMARY KEY(org_id, cluster)
+				)
+			`, []string{"org_id", "cluster", "report", "reported_at", "last_checked_at", "kafka_offset"})
+			if err != nil {
+				return err
+			}
+
+			// the index is dropped together with the original table
+			_, err = tx.Exec(`
+				CREATE INDEX report_kafka_offset_btree_idx ON report (kafka_offset)
+			`)
+			return err
+		}
+
+		_, err := tx.Exec(`
+			ALTER TABLE report DROP COLUMN status
+		`)
+		return err
+	},
+}
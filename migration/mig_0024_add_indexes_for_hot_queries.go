@@ -0,0 +1,64 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mig0024Indexes are indexes used by the most frequent queries, IF NOT EXISTS
+// is used because the indexes could have been created manually already. They
+// are built by online steps, so writes to the tables are not blocked.
+var mig0024Indexes = []struct {
+	name    string
+	table   string
+	columns string
+}{
+	{"report_org_id_reported_at_idx", "report", "org_id, reported_at"},
+	{"rule_hit_cluster_id_idx", "rule_hit", "cluster_id"},
+	{"cluster_rule_toggle_cluster_id_rule_id_idx", "cluster_rule_toggle", "cluster_id, rule_id"},
+}
+
+var mig0024AddIndexesForHotQueries = Migration{
+	StepUp: func(*sql.Tx, types.DBDriver) error {
+		return nil
+	},
+	OnlineSteps: mig0024IndexSteps(),
+	StepDown: func(tx *sql.Tx, _ types.DBDriver) error {
+		for _, index := range mig0024Indexes {
+			// #nosec G202
+			_, err := tx.Exec("DROP INDEX IF EXISTS " + index.name)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	},
+}
+
+// mig0024IndexSteps returns online steps creating the indexes
+func mig0024IndexSteps() []OnlineStep {
+	steps := make([]OnlineStep, 0, len(mig0024Indexes))
+	for _, index := range mig0024Indexes {
+		steps = append(steps, NewConcurrentIndexStep(index.name, index.table, index.columns))
+	}
+
+	return steps
+}
@@ -0,0 +1,85 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mig0026AddFirstSeenAtToRuleHit adds the time the rule was reported for the
+// cluster for the first time to the rule_hit table. Rule hits stored so far
+// take it from the history of rule hits, the time of the last check of the
+// cluster is used when the history is missing. Existing rule hits are
+// updated by the online step in batches of clusters, first_seen_at stays NULL
+// until then.
+var mig0026AddFirstSeenAtToRuleHit = Migration{
+	StepUp: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`
+			ALTER TABLE rule_hit ADD COLUMN first_seen_at TIMESTAMP NULL
+		`)
+		return err
+	},
+	OnlineSteps: []OnlineStep{
+		NewBackfillStep(ruleHitTable, "cluster_id", `
+			UPDATE rule_hit SET first_seen_at = COALESCE(
+				(
+					SELECT MIN(history.appeared_at) FROM rule_hit_history history
+					WHERE
+						history.org_id = rule_hit.org_id AND
+						history.cluster_id = rule_hit.cluster_id AND
+						history.rule_fqdn = rule_hit.rule_fqdn AND
+						history.error_key = rule_hit.error_key
+				),
+				(
+					SELECT report.last_checked_at FROM report
+					WHERE report.cluster = rule_hit.cluster_id
+				)
+			)
+			WHERE first_seen_at IS NULL AND cluster_id >= $1 AND cluster_id <= $2
+		`, 0),
+	},
+	StepDown: func(tx *sql.Tx, driver types.DBDriver) error {
+		if driver == types.DBDriverSQLite3 {
+			err := downgradeTable(tx, ruleHitTable, `
+				CREATE TABLE rule_hit (
+					org_id          INTEGER NOT NULL,
+					cluster_id      VARCHAR NOT NULL,
+					rule_fqdn       VARCHAR NOT NULL,
+					error_key       VARCHAR NOT NULL,
+					template_data   VARCHAR NOT NULL,
+					PRIMARY KEY(cluster_id, org_id, rule_fqdn, error_key)
+				)
+			`, []string{"org_id", "cluster_id", "rule_fqdn", "error_key", "template_data"})
+			if err != nil {
+				return err
+			}
+
+			// the index is dropped together with the original table
+			_, err = tx.Exec(`
+				CREATE INDEX rule_hit_cluster_id_idx ON rule_hit (cluster_id)
+			`)
+			return err
+		}
+
+		_, err := tx.Exec(`
+			ALTER TABLE rule_hit DROP COLUMN first_seen_at
+		`)
+		return err
+	},
+}
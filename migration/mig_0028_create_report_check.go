@@ -0,0 +1,46 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mig0028CreateReportCheck adds a table with statistics of the last checks of
+// every cluster (number of rule hits, rules added and removed by the check)
+var mig0028CreateReportCheck = Migration{
+	StepUp: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`
+			CREATE TABLE report_check (
+				org_id INTEGER NOT NULL,
+				cluster_id VARCHAR NOT NULL,
+				checked_at TIMESTAMP NOT NULL,
+				hit_count INTEGER NOT NULL,
+				added_count INTEGER NOT NULL,
+				removed_count INTEGER NOT NULL,
+
+				PRIMARY KEY(cluster_id, checked_at)
+			)`)
+		return err
+	},
+	StepDown: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`DROP TABLE report_check`)
+		return err
+	},
+}
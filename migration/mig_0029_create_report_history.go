@@ -0,0 +1,47 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mig0029CreateReportHistory adds a table with historical reports of
+// clusters, like the reports of disconnected clusters uploaded in bulk,
+// that are older than the latest report of the cluster
+var mig0029CreateReportHistory = Migration{
+	StepUp: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`
+			CREATE TABLE report_history (
+				org_id INTEGER NOT NULL,
+				cluster_id VARCHAR NOT NULL,
+				report VARCHAR NOT NULL,
+				last_checked_at TIMESTAMP NOT NULL,
+				reported_at TIMESTAMP NOT NULL,
+				kafka_offset BIGINT NOT NULL DEFAULT 0,
+
+				PRIMARY KEY(cluster_id, last_checked_at)
+			)`)
+		return err
+	},
+	StepDown: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`DROP TABLE report_history`)
+		return err
+	},
+}
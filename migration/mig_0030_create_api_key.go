@@ -0,0 +1,49 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mig0030CreateAPIKey adds a table with API keys used by internal services to
+// authenticate to the REST API. Only hash of the secret part of the key is
+// stored, scopes are stored as comma separated list.
+var mig0030CreateAPIKey = Migration{
+	StepUp: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`
+			CREATE TABLE api_key (
+				key_id VARCHAR NOT NULL,
+				name VARCHAR NOT NULL,
+				key_hash VARCHAR NOT NULL,
+				scopes VARCHAR NOT NULL,
+				created_at TIMESTAMP NOT NULL,
+				expires_at TIMESTAMP NULL,
+				rotated_at TIMESTAMP NULL,
+				revoked_at TIMESTAMP NULL,
+
+				PRIMARY KEY(key_id)
+			)`)
+		return err
+	},
+	StepDown: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`DROP TABLE api_key`)
+		return err
+	},
+}
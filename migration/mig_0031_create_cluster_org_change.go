@@ -0,0 +1,46 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mig0031CreateClusterOrgChange adds a table recording clusters that started
+// to report under another organization (re-registration) and how the conflict
+// was resolved
+var mig0031CreateClusterOrgChange = Migration{
+	StepUp: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`
+			CREATE TABLE cluster_org_change (
+				cluster_id VARCHAR NOT NULL,
+				previous_org_id INTEGER NOT NULL,
+				org_id INTEGER NOT NULL,
+				changed_at TIMESTAMP NOT NULL,
+				resolution VARCHAR NOT NULL,
+
+				PRIMARY KEY(cluster_id, changed_at)
+			)`)
+		return err
+	},
+	StepDown: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`DROP TABLE cluster_org_change`)
+		return err
+	},
+}
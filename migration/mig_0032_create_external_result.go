@@ -0,0 +1,69 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mig0032CreateExternalResult adds tables with results of checks of clusters
+// done by external sources (security scanners, for example) instead of
+// insights-operator. external_report contains one record for every source
+// that checked the cluster, external_result contains its results.
+var mig0032CreateExternalResult = Migration{
+	StepUp: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`
+			CREATE TABLE external_report (
+				org_id INTEGER NOT NULL,
+				cluster_id VARCHAR NOT NULL,
+				source VARCHAR NOT NULL,
+				last_checked_at TIMESTAMP NOT NULL,
+				reported_at TIMESTAMP NOT NULL,
+				kafka_offset BIGINT NOT NULL DEFAULT 0,
+
+				PRIMARY KEY(cluster_id, source)
+			)`)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			CREATE TABLE external_result (
+				org_id INTEGER NOT NULL,
+				cluster_id VARCHAR NOT NULL,
+				source VARCHAR NOT NULL,
+				check_id VARCHAR NOT NULL,
+				severity INTEGER NOT NULL,
+				description VARCHAR NOT NULL,
+				details VARCHAR NOT NULL,
+
+				PRIMARY KEY(cluster_id, source, check_id)
+			)`)
+		return err
+	},
+	StepDown: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`DROP TABLE external_result`)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`DROP TABLE external_report`)
+		return err
+	},
+}
@@ -0,0 +1,98 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mig0033AddMessageKey adds the key of the Kafka message the report was
+// consumed from to the report table, so the upload can be traced from the
+// platform gateway to the stored report. Reports and consumer errors (that
+// contain the key already) are indexed by the key, so they can be looked up
+// by it.
+var mig0033AddMessageKey = Migration{
+	StepUp: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`
+			ALTER TABLE report ADD COLUMN message_key VARCHAR
+		`)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			CREATE INDEX report_message_key_idx ON report (message_key)
+		`)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			CREATE INDEX consumer_error_key_idx ON consumer_error (key)
+		`)
+		return err
+	},
+	StepDown: func(tx *sql.Tx, driver types.DBDriver) error {
+		_, err := tx.Exec(`DROP INDEX consumer_error_key_idx`)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`DROP INDEX report_message_key_idx`)
+		if err != nil {
+			return err
+		}
+
+		if driver == types.DBDriverSQLite3 {
+			err := downgradeTable(tx, clusterReportTable, `
+				CREATE TABLE report (
+					org_id          INTEGER NOT NULL,
+					cluster         VARCHAR NOT NULL UNIQUE,
+					report          VARCHAR NOT NULL,
+					reported_at     TIMESTAMP,
+					last_checked_at TIMESTAMP,
+					kafka_offset    BIGINT NOT NULL DEFAULT 0,
+					status          VARCHAR NOT NULL DEFAULT 'analyzed',
+					PRIMARY KEY(org_id, cluster)
+				)
+			`, []string{"org_id", "cluster", "report", "reported_at", "last_checked_at", "kafka_offset", "status"})
+			if err != nil {
+				return err
+			}
+
+			// the indexes are dropped together with the original table
+			_, err = tx.Exec(`
+				CREATE INDEX report_kafka_offset_btree_idx ON report (kafka_offset)
+			`)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec(`
+				CREATE INDEX report_org_id_reported_at_idx ON report (org_id, reported_at)
+			`)
+			return err
+		}
+
+		_, err = tx.Exec(`
+			ALTER TABLE report DROP COLUMN message_key
+		`)
+		return err
+	},
+}
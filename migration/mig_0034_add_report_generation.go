@@ -0,0 +1,107 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mig0034AddReportGeneration adds the generation of the report to the report
+// and rule_hit tables. The generation is increased by every write of the
+// report and rule hits get the generation of the report they were written
+// with, so reads can detect rule hits that don't belong to the report read.
+// Reports and rule hits stored so far share the generation 0.
+var mig0034AddReportGeneration = Migration{
+	StepUp: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`
+			ALTER TABLE report ADD COLUMN generation BIGINT NOT NULL DEFAULT 0
+		`)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			ALTER TABLE rule_hit ADD COLUMN generation BIGINT NOT NULL DEFAULT 0
+		`)
+		return err
+	},
+	StepDown: func(tx *sql.Tx, driver types.DBDriver) error {
+		if driver == types.DBDriverSQLite3 {
+			err := downgradeTable(tx, ruleHitTable, `
+				CREATE TABLE rule_hit (
+					org_id          INTEGER NOT NULL,
+					cluster_id      VARCHAR NOT NULL,
+					rule_fqdn       VARCHAR NOT NULL,
+					error_key       VARCHAR NOT NULL,
+					template_data   VARCHAR NOT NULL,
+					first_seen_at   TIMESTAMP NULL,
+					PRIMARY KEY(cluster_id, org_id, rule_fqdn, error_key)
+				)
+			`, []string{"org_id", "cluster_id", "rule_fqdn", "error_key", "template_data", "first_seen_at"})
+			if err != nil {
+				return err
+			}
+
+			err = downgradeTable(tx, clusterReportTable, `
+				CREATE TABLE report (
+					org_id          INTEGER NOT NULL,
+					cluster         VARCHAR NOT NULL UNIQUE,
+					report          VARCHAR NOT NULL,
+					reported_at     TIMESTAMP,
+					last_checked_at TIMESTAMP,
+					kafka_offset    BIGINT NOT NULL DEFAULT 0,
+					status          VARCHAR NOT NULL DEFAULT 'analyzed',
+					message_key     VARCHAR,
+					PRIMARY KEY(org_id, cluster)
+				)
+			`, []string{
+				"org_id", "cluster", "report", "reported_at", "last_checked_at", "kafka_offset", "status", "message_key",
+			})
+			if err != nil {
+				return err
+			}
+
+			// the indexes are dropped together with the original tables
+			for _, index := range []string{
+				"CREATE INDEX rule_hit_cluster_id_idx ON rule_hit (cluster_id)",
+				"CREATE INDEX report_kafka_offset_btree_idx ON report (kafka_offset)",
+				"CREATE INDEX report_org_id_reported_at_idx ON report (org_id, reported_at)",
+				"CREATE INDEX report_message_key_idx ON report (message_key)",
+			} {
+				if _, err := tx.Exec(index); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}
+
+		_, err := tx.Exec(`
+			ALTER TABLE rule_hit DROP COLUMN generation
+		`)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			ALTER TABLE report DROP COLUMN generation
+		`)
+		return err
+	},
+}
@@ -0,0 +1,49 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mig0035CreateOrgRuleUserFeedback adds a table with votes and feedback left
+// by users on rules in the view of the whole organization, where no single
+// cluster applies
+var mig0035CreateOrgRuleUserFeedback = Migration{
+	StepUp: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`
+			CREATE TABLE org_rule_user_feedback (
+				org_id INTEGER NOT NULL,
+				rule_id VARCHAR NOT NULL,
+				error_key VARCHAR NOT NULL,
+				user_id VARCHAR NOT NULL,
+				user_vote SMALLINT NOT NULL,
+				message VARCHAR NOT NULL,
+				added_at TIMESTAMP NOT NULL,
+				updated_at TIMESTAMP NOT NULL,
+
+				PRIMARY KEY(org_id, rule_id, error_key, user_id)
+			)`)
+		return err
+	},
+	StepDown: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`DROP TABLE org_rule_user_feedback`)
+		return err
+	},
+}
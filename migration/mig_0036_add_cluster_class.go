@@ -0,0 +1,77 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mig0036AddClusterClass adds the class of the cluster (managed or
+// self-managed) to the report table. The class is taken from metadata of
+// incoming messages, clusters stored so far are self-managed.
+var mig0036AddClusterClass = Migration{
+	StepUp: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`
+			ALTER TABLE report ADD COLUMN cluster_class VARCHAR NOT NULL DEFAULT 'self-managed'
+		`)
+		return err
+	},
+	StepDown: func(tx *sql.Tx, driver types.DBDriver) error {
+		if driver == types.DBDriverSQLite3 {
+			err := downgradeTable(tx, clusterReportTable, `
+				CREATE TABLE report (
+					org_id          INTEGER NOT NULL,
+					cluster         VARCHAR NOT NULL UNIQUE,
+					report          VARCHAR NOT NULL,
+					reported_at     TIMESTAMP,
+					last_checked_at TIMESTAMP,
+					kafka_offset    BIGINT NOT NULL DEFAULT 0,
+					status          VARCHAR NOT NULL DEFAULT 'analyzed',
+					message_key     VARCHAR,
+					generation      BIGINT NOT NULL DEFAULT 0,
+					PRIMARY KEY(org_id, cluster)
+				)
+			`, []string{
+				"org_id", "cluster", "report", "reported_at", "last_checked_at", "kafka_offset", "status",
+				"message_key", "generation",
+			})
+			if err != nil {
+				return err
+			}
+
+			// the indexes are dropped together with the original table
+			for _, index := range []string{
+				"CREATE INDEX report_kafka_offset_btree_idx ON report (kafka_offset)",
+				"CREATE INDEX report_org_id_reported_at_idx ON report (org_id, reported_at)",
+				"CREATE INDEX report_message_key_idx ON report (message_key)",
+			} {
+				if _, err := tx.Exec(index); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}
+
+		_, err := tx.Exec(`
+			ALTER TABLE report DROP COLUMN cluster_class
+		`)
+		return err
+	},
+}
@@ -36,10 +36,12 @@ type Version uint
 // or decrease the migration version of the database.
 type Step func(tx *sql.Tx, driver types.DBDriver) error
 
-// Migration type describes a single Migration.
+// Migration type describes a single Migration. OnlineSteps are optional,
+// they are executed after StepUp is committed.
 type Migration struct {
-	StepUp   Step
-	StepDown Step
+	StepUp      Step
+	StepDown    Step
+	OnlineSteps []OnlineStep
 }
 
 const (
@@ -48,6 +50,7 @@ const (
 	clusterReportTable                  = "report"
 	clusterRuleToggleTable              = "cluster_rule_toggle"
 	clusterUserRuleDisableFeedbackTable = "cluster_user_rule_disable_feedback"
+	ruleHitTable                        = "rule_hit"
 )
 
 // GetMaxVersion returns the highest available migration version.
@@ -102,7 +105,10 @@ func GetDBVersion(db *sql.DB) (Version, error) {
 }
 
 // SetDBVersion attempts to get the database into the specified
-// target version using available migration steps.
+// target version using available migration steps. All steps are executed in
+// one transaction, unless some migrations have online steps. The transaction
+// is committed before online steps in that case and pending online steps of
+// already applied migrations are finished first.
 func SetDBVersion(db *sql.DB, dbDriver types.DBDriver, targetVer Version) error {
 	maxVer := GetMaxVersion()
 	if targetVer > maxVer {
@@ -120,6 +126,10 @@ func SetDBVersion(db *sql.DB, dbDriver types.DBDriver, targetVer Version) error
 		return fmt.Errorf("current version (%d) is outside of available migration boundaries", currentVer)
 	}
 
+	if hasOnlineSteps() {
+		return execStepsOnline(db, dbDriver, currentVer, targetVer)
+	}
+
 	return execStepsInTx(db, dbDriver, currentVer, targetVer)
 }
 
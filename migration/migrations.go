@@ -32,4 +32,25 @@ var migrations = []Migration{
 	mig0013AddRuleHitTable,
 	mig0014ModifyClusterRuleToggle,
 	mig0015ModifyFeedbackTables,
+	mig0016AddRuleHitHistoryTable,
+	mig0017CreateClusterAnnotation,
+	mig0018CreateOrgInfo,
+	mig0019CreateStaleReportWrite,
+	mig0020CreateOrgUsage,
+	mig0021CreateRuleHitResolution,
+	mig0022CreateConsumerOffset,
+	mig0023AddStatusToReport,
+	mig0024AddIndexesForHotQueries,
+	mig0025CreateOrgFreeze,
+	mig0026AddFirstSeenAtToRuleHit,
+	mig0027CreateLeaderLease,
+	mig0028CreateReportCheck,
+	mig0029CreateReportHistory,
+	mig0030CreateAPIKey,
+	mig0031CreateClusterOrgChange,
+	mig0032CreateExternalResult,
+	mig0033AddMessageKey,
+	mig0034AddReportGeneration,
+	mig0035CreateOrgRuleUserFeedback,
+	mig0036AddClusterClass,
 }
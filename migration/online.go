@@ -0,0 +1,332 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// OnlineInfoTable is the table containing the highest migration version
+// whose online steps were finished
+const OnlineInfoTable = "migration_online_info"
+
+// defaultBackfillBatchSize is the number of distinct keys updated by one
+// batch of the backfill
+const defaultBackfillBatchSize = 1000
+
+// OnlineStep is a part of the migration executed after the transaction of
+// the migration is committed, so heavy operations (index builds, backfills
+// of new columns) don't lock tables for the whole migration. Online steps
+// have to be idempotent, interrupted steps are executed again by the next
+// migration of the database.
+type OnlineStep struct {
+	// Name describes the step in logs
+	Name string
+	Run  func(db *sql.DB, driver types.DBDriver) error
+}
+
+// NewConcurrentIndexStep returns the online step creating the index without
+// locking writes to the table. CREATE INDEX CONCURRENTLY is used for
+// PostgreSQL, invalid index left by interrupted build is dropped first.
+// Other databases create the index in the usual way.
+func NewConcurrentIndexStep(name, table, columns string) OnlineStep {
+	return OnlineStep{
+		Name: "index " + name,
+		Run: func(db *sql.DB, driver types.DBDriver) error {
+			if driver != types.DBDriverPostgres {
+				// #nosec G202
+				_, err := db.Exec("CREATE INDEX IF NOT EXISTS " + name + " ON " + table + " (" + columns + ")")
+				return err
+			}
+
+			var invalid int
+			err := db.QueryRow(`
+				SELECT COUNT(*) FROM pg_index
+				JOIN pg_class ON pg_class.oid = pg_index.indexrelid
+				JOIN pg_namespace ON pg_namespace.oid = pg_class.relnamespace
+				WHERE pg_class.relname = $1 AND pg_namespace.nspname = current_schema()
+					AND NOT pg_index.indisvalid
+			`, name).Scan(&invalid)
+			if err != nil {
+				return err
+			}
+
+			if invalid > 0 {
+				log.Warn().Str("index", name).Msg("Dropping invalid index left by interrupted build")
+				// #nosec G202
+				if _, err := db.Exec("DROP INDEX CONCURRENTLY IF EXISTS " + name); err != nil {
+					return err
+				}
+			}
+
+			// #nosec G202
+			_, err = db.Exec("CREATE INDEX CONCURRENTLY IF NOT EXISTS " + name + " ON " + table + " (" + columns + ")")
+			return err
+		},
+	}
+}
+
+// NewBackfillStep returns the online step updating rows of the table in
+// batches. Distinct values of keyColumn are read in their order, every batch
+// of batchSize keys (1000 when not set) is updated by one statement, so the
+// table is locked only for a short time. The update statement gets the first
+// and the last key of the batch as $1 and $2 parameters, it has to skip
+// already updated rows. Progress is logged after every batch.
+func NewBackfillStep(table, keyColumn, update string, batchSize int) OnlineStep {
+	if batchSize <= 0 {
+		batchSize = defaultBackfillBatchSize
+	}
+
+	name := "backfill of " + table
+
+	return OnlineStep{
+		Name: name,
+		Run: func(db *sql.DB, _ types.DBDriver) error {
+			var total int
+			// #nosec G202
+			err := db.QueryRow("SELECT COUNT(DISTINCT " + keyColumn + ") FROM " + table).Scan(&total)
+			if err != nil {
+				return err
+			}
+
+			done := 0
+			var lastKey *string
+
+			for {
+				keys, err := readBackfillKeys(db, table, keyColumn, lastKey, batchSize)
+				if err != nil {
+					return err
+				}
+
+				if len(keys) == 0 {
+					return nil
+				}
+
+				if _, err := db.Exec(update, keys[0], keys[len(keys)-1]); err != nil {
+					return err
+				}
+
+				done += len(keys)
+				log.Info().
+					Str("step", name).
+					Int("done", done).
+					Int("total", total).
+					Msgf("Backfill progress %d%%", done*100/maxInt(total, done))
+
+				if len(keys) < batchSize {
+					return nil
+				}
+
+				lastKey = &keys[len(keys)-1]
+			}
+		},
+	}
+}
+
+// readBackfillKeys returns the next batch of distinct keys of the table
+// following the last key, the first batch is returned when it's nil
+func readBackfillKeys(db *sql.DB, table, keyColumn string, lastKey *string, batchSize int) ([]string, error) {
+	var (
+		rows *sql.Rows
+		err  error
+	)
+
+	if lastKey == nil {
+		// #nosec G202
+		rows, err = db.Query(
+			"SELECT DISTINCT "+keyColumn+" FROM "+table+" ORDER BY "+keyColumn+" LIMIT $1", batchSize,
+		)
+	} else {
+		// #nosec G202
+		rows, err = db.Query(
+			"SELECT DISTINCT "+keyColumn+" FROM "+table+" WHERE "+keyColumn+" > $1 ORDER BY "+keyColumn+" LIMIT $2",
+			*lastKey, batchSize,
+		)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	keys := make([]string, 0, batchSize)
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// maxInt returns the greater of the numbers
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// hasOnlineSteps returns true when any of the migrations has online steps
+func hasOnlineSteps() bool {
+	for _, migration := range migrations {
+		if len(migration.OnlineSteps) != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// initOnlineInfoTable creates the table with the version of finished online
+// steps and returns the version. It's initialized to the current version of
+// the database, online steps of migrations applied before they were
+// introduced were executed in the transaction of the migration. The version
+// is never higher than the current version of the database.
+func initOnlineInfoTable(db *sql.DB, currentVer Version) (Version, error) {
+	var onlineVer Version
+
+	err := withTransaction(db, func(tx *sql.Tx) error {
+		// #nosec G202
+		_, err := tx.Exec("CREATE TABLE IF NOT EXISTS " + OnlineInfoTable + " (version INTEGER NOT NULL);")
+		if err != nil {
+			return err
+		}
+
+		// #nosec G202
+		_, err = tx.Exec(
+			"INSERT INTO " + OnlineInfoTable + " (version) SELECT version FROM migration_info " +
+				"WHERE NOT EXISTS (SELECT version FROM " + OnlineInfoTable + ");",
+		)
+		if err != nil {
+			return err
+		}
+
+		// #nosec G202
+		return tx.QueryRow("SELECT version FROM " + OnlineInfoTable + ";").Scan(&onlineVer)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if onlineVer > currentVer {
+		onlineVer = currentVer
+	}
+
+	return onlineVer, nil
+}
+
+// setOnlineVersion stores the highest version whose online steps were
+// finished
+func setOnlineVersion(db *sql.DB, version Version) error {
+	// #nosec G202
+	_, err := db.Exec("UPDATE "+OnlineInfoTable+" SET version = $1;", version)
+	return err
+}
+
+// execStepsOnline migrates the database like execStepsInTx, but the
+// transaction is committed after every migration with online steps and the
+// online steps are executed before the following migrations. Online steps
+// interrupted before are finished first.
+func execStepsOnline(db *sql.DB, dbDriver types.DBDriver, currentVer, targetVer Version) error {
+	onlineVer, err := initOnlineInfoTable(db, currentVer)
+	if err != nil {
+		return err
+	}
+
+	pendingVer := currentVer
+	if targetVer < pendingVer {
+		pendingVer = targetVer
+	}
+
+	if err := execOnlineSteps(db, dbDriver, onlineVer, pendingVer); err != nil {
+		return err
+	}
+
+	for currentVer < targetVer {
+		nextVer := currentVer + 1
+		for nextVer < targetVer && len(migrations[nextVer-1].OnlineSteps) == 0 {
+			nextVer++
+		}
+
+		if err := execStepsInTx(db, dbDriver, currentVer, nextVer); err != nil {
+			return err
+		}
+
+		if err := execOnlineSteps(db, dbDriver, currentVer, nextVer); err != nil {
+			return err
+		}
+
+		currentVer = nextVer
+	}
+
+	if currentVer > targetVer {
+		if err := execStepsInTx(db, dbDriver, currentVer, targetVer); err != nil {
+			return err
+		}
+
+		return setOnlineVersion(db, targetVer)
+	}
+
+	return nil
+}
+
+// execOnlineSteps executes online steps of migrations to versions from
+// fromVer (exclusive) to toVer (inclusive) and records the progress
+func execOnlineSteps(db *sql.DB, dbDriver types.DBDriver, fromVer, toVer Version) error {
+	if fromVer >= toVer {
+		return nil
+	}
+
+	for version := fromVer + 1; version <= toVer; version++ {
+		steps := migrations[version-1].OnlineSteps
+		if len(steps) == 0 {
+			continue
+		}
+
+		for _, step := range steps {
+			started := time.Now()
+			log.Info().Uint("migration", uint(version)).Str("step", step.Name).Msg("Online step of migration started")
+
+			if err := step.Run(db, dbDriver); err != nil {
+				return fmt.Errorf("online step %v of migration %v: %v", step.Name, version, err)
+			}
+
+			log.Info().
+				Uint("migration", uint(version)).
+				Str("step", step.Name).
+				Dur("duration", time.Since(started)).
+				Msg("Online step of migration finished")
+		}
+
+		if err := setOnlineVersion(db, version); err != nil {
+			return err
+		}
+	}
+
+	return setOnlineVersion(db, toVer)
+}
@@ -0,0 +1,169 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package producer
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+)
+
+const (
+	// defaultRetryBackoff is the time to wait before the first retry when
+	// no backoff is configured
+	defaultRetryBackoff = 100 * time.Millisecond
+	// defaultMaxRetryBackoff caps the exponential backoff when no maximum
+	// is configured
+	defaultMaxRetryBackoff = 5 * time.Second
+	// defaultCircuitBreakerCooldown is the time the circuit stays open when
+	// no cooldown is configured
+	defaultCircuitBreakerCooldown = 30 * time.Second
+)
+
+// results of message deliveries counted by producer_deliveries metric
+const (
+	deliveryDelivered = "delivered"
+	deliveryRetried   = "retried"
+	deliveryFailed    = "failed"
+	deliveryRejected  = "rejected"
+)
+
+// ErrCircuitOpen is returned instead of sending the message when the
+// previous messages repeatedly failed and the circuit breaker is open
+var ErrCircuitOpen = errors.New("Kafka producer circuit breaker is open")
+
+// circuitBreaker stops sending of messages for a while after the configured
+// number of consecutive failed deliveries, so the callers (consumer, REST API
+// handlers) don't wait for retries when the broker is not available. After
+// the cooldown deliveries are tried again, the first successful one closes
+// the circuit and the first failed one opens it again. Zero value is ready to
+// use.
+type circuitBreaker struct {
+	mutex     sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+// allow returns false when the circuit is open
+func (breaker *circuitBreaker) allow(now time.Time) bool {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	return !now.Before(breaker.openUntil)
+}
+
+// success closes the circuit
+func (breaker *circuitBreaker) success() {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	breaker.failures = 0
+	breaker.openUntil = time.Time{}
+}
+
+// failure records failed delivery and opens the circuit for the cooldown when
+// the threshold of consecutive failures is reached, true is returned when the
+// circuit has been opened
+func (breaker *circuitBreaker) failure(now time.Time, threshold int, cooldown time.Duration) bool {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	breaker.failures++
+	if threshold <= 0 || breaker.failures < threshold {
+		return false
+	}
+
+	breaker.openUntil = now.Add(cooldown)
+	return true
+}
+
+// retryBackoff returns the time to wait before the given retry (starting
+// from 1), the backoff is doubled for every retry up to the maximum
+func (producer *KafkaProducer) retryBackoff(retry int) time.Duration {
+	backoff := producer.Configuration.ProducerRetryBackoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+
+	maxBackoff := producer.Configuration.ProducerMaxRetryBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxRetryBackoff
+	}
+
+	for i := 1; i < retry && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+
+	return backoff
+}
+
+// sendMessage is the produce helper shared by all messages produced by the
+// service. It sends the message and retries failed deliveries with
+// exponential backoff (see producer_retries in broker configuration), the
+// error of the last attempt is returned. Messages are rejected by
+// ErrCircuitOpen while the circuit breaker is open.
+func (producer *KafkaProducer) sendMessage(message *sarama.ProducerMessage) (partition int32, offset int64, err error) {
+	topic := message.Topic
+
+	if !producer.circuit.allow(time.Now()) {
+		metrics.ProducerDeliveries.WithLabelValues(topic, deliveryRejected).Inc()
+		return 0, 0, ErrCircuitOpen
+	}
+
+	for retry := 0; ; retry++ {
+		if retry > 0 {
+			metrics.ProducerDeliveries.WithLabelValues(topic, deliveryRetried).Inc()
+			time.Sleep(producer.retryBackoff(retry))
+		}
+
+		partition, offset, err = producer.Producer.SendMessage(message)
+		if err == nil {
+			producer.circuit.success()
+			metrics.ProducerDeliveries.WithLabelValues(topic, deliveryDelivered).Inc()
+			metrics.ProducedMessages.Inc()
+			return partition, offset, nil
+		}
+
+		log.Warn().Err(err).Str("topic", topic).Int("retry", retry).Msg("Unable to deliver message to Kafka")
+
+		if retry >= producer.Configuration.ProducerRetries {
+			break
+		}
+	}
+
+	metrics.ProducerDeliveries.WithLabelValues(topic, deliveryFailed).Inc()
+
+	cooldown := producer.Configuration.ProducerCircuitBreakerCooldown
+	if cooldown <= 0 {
+		cooldown = defaultCircuitBreakerCooldown
+	}
+
+	if producer.circuit.failure(time.Now(), producer.Configuration.ProducerCircuitBreakerThreshold, cooldown) {
+		log.Error().Str("topic", topic).Dur("cooldown", cooldown).Msg("Kafka producer circuit breaker is open")
+	}
+
+	return 0, 0, err
+}
@@ -26,7 +26,7 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"github.com/RedHatInsights/insights-results-aggregator/broker"
-	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/events"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
 )
 
@@ -50,11 +50,21 @@ type Producer interface {
 type KafkaProducer struct {
 	Configuration broker.Configuration
 	Producer      sarama.SyncProducer
+	circuit       circuitBreaker
 }
 
 // New constructs new implementation of Producer interface
 func New(brokerCfg broker.Configuration) (*KafkaProducer, error) {
-	producer, err := sarama.NewSyncProducer([]string{brokerCfg.Address}, nil)
+	saramaConfig := sarama.NewConfig()
+	// required by sync producer
+	saramaConfig.Producer.Return.Successes = true
+
+	if err := broker.ApplySecurityConfiguration(saramaConfig, brokerCfg); err != nil {
+		log.Error().Err(err).Msg("unable to set up Kafka security options")
+		return nil, err
+	}
+
+	producer, err := sarama.NewSyncProducer([]string{brokerCfg.Address}, saramaConfig)
 	if err != nil {
 		log.Error().Err(err).Msg("unable to create a new Kafka producer")
 		return nil, err
@@ -72,6 +82,7 @@ type PayloadTrackerMessage struct {
 	Service   string `json:"service"`
 	RequestID string `json:"request_id"`
 	Status    string `json:"status"`
+	StatusMsg string `json:"status_msg,omitempty"`
 	Date      string `json:"date"`
 }
 
@@ -89,12 +100,11 @@ func (producer *KafkaProducer) produceMessage(trackerMsg PayloadTrackerMessage)
 		Value: sarama.ByteEncoder(jsonBytes),
 	}
 
-	partition, offset, err := producer.Producer.SendMessage(producerMsg)
+	partition, offset, err := producer.sendMessage(producerMsg)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to produce message to Kafka")
 	} else {
 		log.Info().Msgf("message sent to partition %d at offset %d\n", partition, offset)
-		metrics.ProducedMessages.Inc()
 	}
 	return partition, offset, err
 }
@@ -105,6 +115,20 @@ func (producer *KafkaProducer) produceMessage(trackerMsg PayloadTrackerMessage)
 // this can happen in some scenarios and it is not considered an error.
 // Instead, only a warning is logged and no error is returned.
 func (producer *KafkaProducer) TrackPayload(reqID types.RequestID, timestamp time.Time, status string) error {
+	return producer.trackPayload(reqID, timestamp, status, "")
+}
+
+// TrackPayloadError publishes the error status of a payload with the given
+// request ID to the payload tracker Kafka topic. The processing error is sent
+// as the status message, so it is visible in the payload tracker.
+func (producer *KafkaProducer) TrackPayloadError(reqID types.RequestID, timestamp time.Time, processingErr error) error {
+	return producer.trackPayload(reqID, timestamp, StatusError, processingErr.Error())
+}
+
+// trackPayload publishes the status of a payload with optional status message
+func (producer *KafkaProducer) trackPayload(
+	reqID types.RequestID, timestamp time.Time, status, statusMsg string,
+) error {
 	if len(reqID) == 0 {
 		log.Warn().Str("Operation", "TrackPayload").Msg("request ID is missing, null or empty")
 		return nil
@@ -114,6 +138,7 @@ func (producer *KafkaProducer) TrackPayload(reqID types.RequestID, timestamp tim
 		Service:   producer.Configuration.ServiceName,
 		RequestID: string(reqID),
 		Status:    status,
+		StatusMsg: statusMsg,
 		Date:      timestamp.UTC().Format(time.RFC3339Nano),
 	})
 	if err != nil {
@@ -127,6 +152,31 @@ func (producer *KafkaProducer) TrackPayload(reqID types.RequestID, timestamp tim
 	return nil
 }
 
+// PublishRuleToggle publishes the rule toggle event to the rule toggle Kafka
+// topic. Cluster ID is used as the message key, so all events of one cluster
+// end in the same partition and keep their order.
+func (producer *KafkaProducer) PublishRuleToggle(event events.RuleToggleEvent) error {
+	jsonBytes, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	producerMsg := &sarama.ProducerMessage{
+		Topic: producer.Configuration.RuleToggleTopic,
+		Key:   sarama.StringEncoder(event.ClusterID),
+		Value: sarama.ByteEncoder(jsonBytes),
+	}
+
+	_, _, err = producer.sendMessage(producerMsg)
+	if err != nil {
+		log.Error().Err(err).Msgf(
+			"unable to produce rule toggle event (cluster: '%s', rule: '%s')", event.ClusterID, event.RuleID)
+		return err
+	}
+
+	return nil
+}
+
 // Close allow the Sarama producer to be gracefully closed
 func (producer *KafkaProducer) Close() error {
 	if err := producer.Producer.Close(); err != nil {
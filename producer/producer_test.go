@@ -17,7 +17,9 @@ limitations under the License.
 package producer_test
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
@@ -29,6 +31,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/events"
 	"github.com/RedHatInsights/insights-results-aggregator/producer"
 	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
@@ -112,6 +115,98 @@ func TestProducerTrackPayloadWithError(t *testing.T) {
 	assert.EqualError(t, err, producerErrorMessage)
 }
 
+// TestProducerTrackPayloadError checks that the processing error is sent as
+// the status message together with the error status
+func TestProducerTrackPayloadError(t *testing.T) {
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	mockProducer.ExpectSendMessageWithCheckerFunctionAndSucceed(func(value []byte) error {
+		var sent producer.PayloadTrackerMessage
+		if err := json.Unmarshal(value, &sent); err != nil {
+			return err
+		}
+		if sent.Status != producer.StatusError || sent.StatusMsg != "unable to parse report" {
+			return fmt.Errorf("unexpected payload tracker message %+v", sent)
+		}
+		return nil
+	})
+
+	kafkaProducer := producer.KafkaProducer{
+		Configuration: brokerCfg,
+		Producer:      mockProducer,
+	}
+	defer func() {
+		helpers.FailOnError(t, kafkaProducer.Close())
+	}()
+
+	err := kafkaProducer.TrackPayloadError(testdata.TestRequestID, testTimestamp, errors.New("unable to parse report"))
+	assert.NoError(t, err, "payload tracking failed")
+}
+
+// TestProducerPublishRuleToggle checks that rule toggle event is sent to the
+// configured topic with cluster ID as the key.
+func TestProducerPublishRuleToggle(t *testing.T) {
+	cfg := brokerCfg
+	cfg.RuleToggleTopic = "rule-toggle-topic"
+
+	event := events.RuleToggleEvent{
+		OrgID:     testdata.OrgID,
+		ClusterID: testdata.ClusterName,
+		RuleID:    testdata.Rule1ID,
+		ErrorKey:  testdata.ErrorKey1,
+		Disabled:  true,
+		Timestamp: testTimestamp,
+	}
+
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	mockProducer.ExpectSendMessageWithCheckerFunctionAndSucceed(func(value []byte) error {
+		var sent events.RuleToggleEvent
+		if err := json.Unmarshal(value, &sent); err != nil {
+			return err
+		}
+		// location of the decoded timestamp differs, the time is compared
+		if !sent.Timestamp.Equal(event.Timestamp) {
+			return fmt.Errorf("unexpected timestamp %v", sent.Timestamp)
+		}
+		sent.Timestamp = event.Timestamp
+
+		if sent != event {
+			return fmt.Errorf("unexpected event %+v", sent)
+		}
+		return nil
+	})
+
+	kafkaProducer := producer.KafkaProducer{
+		Configuration: cfg,
+		Producer:      mockProducer,
+	}
+	defer func() {
+		helpers.FailOnError(t, kafkaProducer.Close())
+	}()
+
+	err := kafkaProducer.PublishRuleToggle(event)
+	assert.NoError(t, err, "rule toggle publishing failed")
+}
+
+// TestProducerPublishRuleToggleWithError checks that errors from the
+// underlying producer are correctly returned.
+func TestProducerPublishRuleToggleWithError(t *testing.T) {
+	const producerErrorMessage = "unable to send the message"
+
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	mockProducer.ExpectSendMessageAndFail(errors.New(producerErrorMessage))
+
+	kafkaProducer := producer.KafkaProducer{
+		Configuration: brokerCfg,
+		Producer:      mockProducer,
+	}
+	defer func() {
+		helpers.FailOnError(t, kafkaProducer.Close())
+	}()
+
+	err := kafkaProducer.PublishRuleToggle(events.RuleToggleEvent{ClusterID: testdata.ClusterName})
+	assert.EqualError(t, err, producerErrorMessage)
+}
+
 // TestProducerClose makes sure it's possible to close the producer.
 func TestProducerClose(t *testing.T) {
 	mockProducer := mocks.NewSyncProducer(t, nil)
@@ -141,3 +236,79 @@ func TestProducerNew(t *testing.T) {
 
 	helpers.FailOnError(t, prod.Close())
 }
+
+// TestProducerRetriesFailedDelivery checks that failed delivery is retried
+// with backoff and the message is delivered by the retry
+func TestProducerRetriesFailedDelivery(t *testing.T) {
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	mockProducer.ExpectSendMessageAndFail(errors.New("leader not available"))
+	mockProducer.ExpectSendMessageAndSucceed()
+
+	cfg := brokerCfg
+	cfg.ProducerRetries = 2
+	cfg.ProducerRetryBackoff = time.Millisecond
+
+	kafkaProducer := producer.KafkaProducer{
+		Configuration: cfg,
+		Producer:      mockProducer,
+	}
+	defer func() {
+		helpers.FailOnError(t, kafkaProducer.Close())
+	}()
+
+	err := kafkaProducer.TrackPayload(testdata.TestRequestID, testTimestamp, producer.StatusReceived)
+	assert.NoError(t, err, "payload tracking failed")
+}
+
+// TestProducerRetriesExhausted checks that the error of the last attempt is
+// returned when all retries fail
+func TestProducerRetriesExhausted(t *testing.T) {
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	mockProducer.ExpectSendMessageAndFail(errors.New("leader not available"))
+	mockProducer.ExpectSendMessageAndFail(errors.New("broker not available"))
+
+	cfg := brokerCfg
+	cfg.ProducerRetries = 1
+	cfg.ProducerRetryBackoff = time.Millisecond
+
+	kafkaProducer := producer.KafkaProducer{
+		Configuration: cfg,
+		Producer:      mockProducer,
+	}
+	defer func() {
+		helpers.FailOnError(t, kafkaProducer.Close())
+	}()
+
+	err := kafkaProducer.TrackPayload(testdata.TestRequestID, testTimestamp, producer.StatusReceived)
+	assert.EqualError(t, err, "broker not available")
+}
+
+// TestProducerCircuitBreaker checks that messages are not sent while the
+// circuit breaker is open after consecutive failed deliveries
+func TestProducerCircuitBreaker(t *testing.T) {
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	mockProducer.ExpectSendMessageAndFail(errors.New("broker not available"))
+	mockProducer.ExpectSendMessageAndFail(errors.New("broker not available"))
+
+	cfg := brokerCfg
+	cfg.ProducerCircuitBreakerThreshold = 2
+	cfg.ProducerCircuitBreakerCooldown = time.Hour
+
+	kafkaProducer := producer.KafkaProducer{
+		Configuration: cfg,
+		Producer:      mockProducer,
+	}
+	defer func() {
+		helpers.FailOnError(t, kafkaProducer.Close())
+	}()
+
+	for i := 0; i < 2; i++ {
+		err := kafkaProducer.TrackPayload(testdata.TestRequestID, testTimestamp, producer.StatusReceived)
+		assert.EqualError(t, err, "broker not available")
+	}
+
+	// the message is rejected without calling the mock producer, it would
+	// fail on unexpected message otherwise
+	err := kafkaProducer.TrackPayload(testdata.TestRequestID, testTimestamp, producer.StatusReceived)
+	assert.Equal(t, producer.ErrCircuitOpen, err)
+}
@@ -0,0 +1,255 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/conf"
+	"github.com/RedHatInsights/insights-results-aggregator/export"
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/scheduler"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+)
+
+// schedulerLeaseName is the name of the lease held by the instance of the
+// service that runs the scheduled tasks
+const schedulerLeaseName = "scheduler"
+
+// defaultArchivalBatchSize is the number of reports archived by one run of
+// the report archival task when no batch size is configured
+const defaultArchivalBatchSize = 1000
+
+var (
+	schedulerInstance *scheduler.Scheduler
+	schedulerStorage  *storage.DBStorage
+)
+
+// startScheduler registers all tasks with configured schedule and starts
+// the scheduler or returns an error
+func startScheduler(schedulerConf scheduler.Configuration) error {
+	dbStorage, err := createStorage()
+	if err != nil {
+		return err
+	}
+
+	detectSchemaVersion(dbStorage)
+
+	taskScheduler := scheduler.New()
+
+	err = registerSchedulerTasks(taskScheduler, schedulerConf, dbStorage)
+	if err != nil {
+		closeStorage(dbStorage)
+		return err
+	}
+
+	if schedulerConf.LeaderElection {
+		identity := schedulerIdentity()
+		log.Info().Str("identity", identity).Msg("Leader election of the scheduler enabled")
+
+		elector := scheduler.NewLeaderElector(
+			dbStorage, schedulerLeaseName, identity, schedulerConf.LeaseDuration,
+		)
+		if err := taskScheduler.SetLeaderElector(elector); err != nil {
+			closeStorage(dbStorage)
+			return err
+		}
+	}
+
+	schedulerInstance, schedulerStorage = taskScheduler, dbStorage
+	schedulerInstance.Start()
+
+	return nil
+}
+
+// stopScheduler waits for running tasks to finish and stops the scheduler
+func stopScheduler() {
+	if schedulerInstance == nil {
+		return
+	}
+
+	schedulerInstance.Stop()
+	closeStorage(schedulerStorage)
+}
+
+// schedulerIdentity returns identity of this instance of the service used in
+// the leader election. Host name (pod name in Kubernetes) makes the leader
+// easy to find, random suffix keeps identities unique when the host name is
+// shared.
+func schedulerIdentity() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to get host name")
+		hostname = "unknown"
+	}
+
+	return hostname + "-" + uuid.New().String()
+}
+
+// registerSchedulerTasks registers all the tasks that have a schedule set in
+// the configuration
+func registerSchedulerTasks(
+	taskScheduler *scheduler.Scheduler,
+	schedulerConf scheduler.Configuration,
+	dbStorage storage.Storage,
+) error {
+	if schedulerConf.RetentionCleanupSchedule != "" &&
+		schedulerConf.ReportRetention <= 0 && schedulerConf.ConsumerErrorRetention <= 0 {
+		return fmt.Errorf("report_retention or consumer_error_retention needs to be set for retention cleanup task")
+	}
+
+	if schedulerConf.StaleClustersDetectionSchedule != "" && schedulerConf.StaleClusterThreshold <= 0 {
+		return fmt.Errorf("stale_cluster_threshold needs to be set for stale clusters detection task")
+	}
+
+	archiveConf := conf.GetArchiveConfiguration()
+	if schedulerConf.ReportArchivalSchedule != "" && (!archiveConf.Enabled || archiveConf.ReportAge <= 0) {
+		return fmt.Errorf("archive needs to be enabled with report_age set for report archival task")
+	}
+
+	tasks := []struct {
+		name     string
+		schedule string
+		run      scheduler.TaskFunc
+	}{
+		{
+			name:     "retention_cleanup",
+			schedule: schedulerConf.RetentionCleanupSchedule,
+			run: func() error {
+				return retentionCleanupTask(
+					dbStorage, schedulerConf.ReportRetention, schedulerConf.ConsumerErrorRetention,
+				)
+			},
+		},
+		{
+			name:     "stale_clusters_detection",
+			schedule: schedulerConf.StaleClustersDetectionSchedule,
+			run: func() error {
+				return staleClustersDetectionTask(dbStorage, schedulerConf.StaleClusterThreshold)
+			},
+		},
+		{
+			name:     "metrics_collection",
+			schedule: schedulerConf.MetricsCollectionSchedule,
+			run: func() error {
+				return metricsCollectionTask(dbStorage)
+			},
+		},
+		{
+			name:     "parquet_export",
+			schedule: schedulerConf.ParquetExportSchedule,
+			run: func() error {
+				_, err := export.ToParquet(conf.GetExportConfiguration(), dbStorage)
+				return err
+			},
+		},
+		{
+			name:     "report_archival",
+			schedule: schedulerConf.ReportArchivalSchedule,
+			run: func() error {
+				return reportArchivalTask(dbStorage, archiveConf.ReportAge, archiveConf.BatchSize)
+			},
+		},
+	}
+
+	for _, task := range tasks {
+		if task.schedule == "" {
+			continue
+		}
+
+		if err := taskScheduler.Register(task.name, task.schedule, task.run); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// retentionCleanupTask deletes reports of clusters that haven't been checked
+// for longer than the report retention period and messages stored in
+// consumer_error table that are older than the consumer error retention
+// period, data are kept when the period is not set
+func retentionCleanupTask(
+	dbStorage storage.Storage, reportRetention, consumerErrorRetention time.Duration,
+) error {
+	var policy storage.RetentionPolicy
+
+	now := time.Now()
+	if reportRetention > 0 {
+		policy.ReportsNotCheckedSince = now.Add(-reportRetention)
+	}
+	if consumerErrorRetention > 0 {
+		policy.ConsumerErrorsConsumedBefore = now.Add(-consumerErrorRetention)
+	}
+
+	pruned, err := dbStorage.CleanupOldData(policy)
+	if err != nil {
+		return err
+	}
+
+	log.Info().
+		Int("reports", pruned["report"]).
+		Int("consumer_errors", pruned["consumer_error"]).
+		Msg("Data older than retention period deleted")
+	return nil
+}
+
+// reportArchivalTask moves reports of clusters that haven't been checked for
+// longer than the given age into the archive of reports
+func reportArchivalTask(dbStorage storage.Storage, age time.Duration, batchSize int) error {
+	if batchSize <= 0 {
+		batchSize = defaultArchivalBatchSize
+	}
+
+	archived, err := dbStorage.ArchiveReportsNotCheckedSince(time.Now().Add(-age), batchSize)
+	if err != nil {
+		return err
+	}
+
+	log.Info().Int("archived", archived).Msg("Reports older than archival age archived")
+	return nil
+}
+
+// staleClustersDetectionTask counts clusters that haven't been checked for
+// longer than the threshold and exposes the number as a metric
+func staleClustersDetectionTask(dbStorage storage.Storage, threshold time.Duration) error {
+	count, err := dbStorage.CountClustersNotCheckedSince(time.Now().Add(-threshold))
+	if err != nil {
+		return err
+	}
+
+	metrics.StaleClusters.Set(float64(count))
+
+	if count > 0 {
+		log.Warn().Int("count", count).Msgf("Clusters not checked in last %v detected", threshold)
+	}
+	return nil
+}
+
+// metricsCollectionTask updates metrics computed from the database content
+func metricsCollectionTask(dbStorage storage.Storage) error {
+	count, err := dbStorage.ReportsCount()
+	if err != nil {
+		return err
+	}
+
+	metrics.StoredReports.Set(float64(count))
+	return nil
+}
@@ -0,0 +1,37 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import "time"
+
+// Configuration represents configuration of the scheduler. Every task has
+// its own schedule, tasks with empty schedule are not registered at all.
+// With leader election enabled, tasks run on one instance of the service
+// only, the one holding the lease stored in the database.
+type Configuration struct {
+	Enabled                        bool          `mapstructure:"enabled" toml:"enabled"`
+	RetentionCleanupSchedule       string        `mapstructure:"retention_cleanup_schedule" toml:"retention_cleanup_schedule"`
+	ReportRetention                time.Duration `mapstructure:"report_retention" toml:"report_retention"`
+	ConsumerErrorRetention         time.Duration `mapstructure:"consumer_error_retention" toml:"consumer_error_retention"`
+	StaleClustersDetectionSchedule string        `mapstructure:"stale_clusters_detection_schedule" toml:"stale_clusters_detection_schedule"`
+	StaleClusterThreshold          time.Duration `mapstructure:"stale_cluster_threshold" toml:"stale_cluster_threshold"`
+	MetricsCollectionSchedule      string        `mapstructure:"metrics_collection_schedule" toml:"metrics_collection_schedule"`
+	ParquetExportSchedule          string        `mapstructure:"parquet_export_schedule" toml:"parquet_export_schedule"`
+	ReportArchivalSchedule         string        `mapstructure:"report_archival_schedule" toml:"report_archival_schedule"`
+	LeaderElection                 bool          `mapstructure:"leader_election" toml:"leader_election"`
+	LeaseDuration                  time.Duration `mapstructure:"lease_duration" toml:"lease_duration"`
+}
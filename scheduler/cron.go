@@ -0,0 +1,242 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxScheduleLookup is the limit for searching the next matching time. It
+// prevents endless loop for expressions like "0 0 31 2 *" that never match.
+const maxScheduleLookup = 5 * 366 * 24 * time.Hour
+
+// Schedule returns the next time a task should run after the given time
+type Schedule interface {
+	Next(time.Time) time.Time
+}
+
+// cronField describes one field of the cron expression
+type cronField struct {
+	name string
+	min  int
+	max  int
+}
+
+var (
+	minuteField     = cronField{"minute", 0, 59}
+	hourField       = cronField{"hour", 0, 23}
+	dayOfMonthField = cronField{"day of month", 1, 31}
+	monthField      = cronField{"month", 1, 12}
+	dayOfWeekField  = cronField{"day of week", 0, 6}
+)
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+const everyPrefix = "@every "
+
+// cronSchedule is a schedule defined by standard five-field cron expression.
+// Every field is represented as a bit set of the matching values.
+type cronSchedule struct {
+	minutes     uint64
+	hours       uint64
+	daysOfMonth uint64
+	months      uint64
+	daysOfWeek  uint64
+	// day of month and day of week are combined by OR when both are
+	// restricted, as in the standard cron
+	dayOfMonthAny bool
+	dayOfWeekAny  bool
+	location      *time.Location
+}
+
+// everySchedule runs the task periodically with fixed interval
+type everySchedule struct {
+	interval time.Duration
+}
+
+// ParseSchedule parses cron expression. Standard five fields (minute, hour,
+// day of month, month, day of week) are supported with values, ranges, steps
+// and lists, together with macros like @daily or @hourly. Additionally
+// "@every <duration>" can be used to run the task with fixed interval. The
+// times are evaluated in UTC.
+func ParseSchedule(expression string) (Schedule, error) {
+	expression = strings.TrimSpace(expression)
+
+	if strings.HasPrefix(expression, everyPrefix) {
+		interval, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expression, everyPrefix)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval in expression '%s': %v", expression, err)
+		}
+		if interval < time.Second {
+			return nil, fmt.Errorf("interval in expression '%s' must be at least 1s", expression)
+		}
+		return everySchedule{interval}, nil
+	}
+
+	if macro, found := cronMacros[expression]; found {
+		expression = macro
+	}
+
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields in cron expression '%s', got %d", expression, len(fields))
+	}
+
+	schedule := cronSchedule{
+		dayOfMonthAny: fields[2] == "*",
+		dayOfWeekAny:  fields[4] == "*",
+		location:      time.UTC,
+	}
+
+	var err error
+	for _, parsed := range []struct {
+		target *uint64
+		value  string
+		field  cronField
+	}{
+		{&schedule.minutes, fields[0], minuteField},
+		{&schedule.hours, fields[1], hourField},
+		{&schedule.daysOfMonth, fields[2], dayOfMonthField},
+		{&schedule.months, fields[3], monthField},
+		{&schedule.daysOfWeek, fields[4], dayOfWeekField},
+	} {
+		*parsed.target, err = parseCronField(parsed.value, parsed.field)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return schedule, nil
+}
+
+// parseCronField parses one field of the cron expression into the bit set
+func parseCronField(value string, field cronField) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(value, ",") {
+		rangePart, step := part, 1
+
+		if slash := strings.Index(part, "/"); slash >= 0 {
+			var err error
+			rangePart = part[:slash]
+			step, err = strconv.Atoi(part[slash+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %s field '%s'", field.name, value)
+			}
+		}
+
+		low, high := field.min, field.max
+
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+
+			var err error
+			low, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return 0, fmt.Errorf("invalid value in %s field '%s'", field.name, value)
+			}
+
+			high = low
+			if len(bounds) == 2 {
+				high, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return 0, fmt.Errorf("invalid value in %s field '%s'", field.name, value)
+				}
+			} else if step != 1 {
+				// "5/10" means every 10th value starting with 5
+				high = field.max
+			}
+		}
+
+		// 7 is also accepted as Sunday
+		if field == dayOfWeekField && high == 7 {
+			bits |= 1
+			if low == 7 {
+				continue
+			}
+			high = 6
+		}
+
+		if low < field.min || high > field.max || low > high {
+			return 0, fmt.Errorf(
+				"%s field '%s' out of range %d-%d", field.name, value, field.min, field.max,
+			)
+		}
+
+		for i := low; i <= high; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+
+	return bits, nil
+}
+
+func hasBit(bits uint64, value int) bool {
+	return bits&(1<<uint(value)) != 0
+}
+
+// matchesDay checks whether the day matches both day of month and day of
+// week fields
+func (schedule cronSchedule) matchesDay(t time.Time) bool {
+	dayOfMonth := hasBit(schedule.daysOfMonth, t.Day())
+	dayOfWeek := hasBit(schedule.daysOfWeek, int(t.Weekday()))
+
+	if schedule.dayOfMonthAny || schedule.dayOfWeekAny {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
+
+// Next returns the first time matching the schedule strictly after the given
+// time. Zero time is returned when the schedule never matches.
+func (schedule cronSchedule) Next(after time.Time) time.Time {
+	t := after.In(schedule.location).Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxScheduleLookup)
+
+	for t.Before(limit) {
+		switch {
+		case !hasBit(schedule.months, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, schedule.location)
+		case !schedule.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, schedule.location)
+		case !hasBit(schedule.hours, t.Hour()):
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case !hasBit(schedule.minutes, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// Next returns the given time increased by the interval
+func (schedule everySchedule) Next(after time.Time) time.Time {
+	return after.Add(schedule.interval)
+}
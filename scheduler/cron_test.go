@@ -0,0 +1,89 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/scheduler"
+)
+
+func mustParseTime(t *testing.T, value string) time.Time {
+	parsed, err := time.Parse("2006-01-02 15:04:05", value)
+	helpers.FailOnError(t, err)
+	return parsed
+}
+
+func TestParseSchedule_Next(t *testing.T) {
+	for _, testCase := range []struct {
+		expression string
+		after      string
+		expected   string
+	}{
+		{"*/15 * * * *", "2020-10-16 12:07:30", "2020-10-16 12:15:00"},
+		{"5/20 * * * *", "2020-10-16 12:06:00", "2020-10-16 12:25:00"},
+		{"0 3 * * *", "2020-10-16 12:00:00", "2020-10-17 03:00:00"},
+		{"30 2 1 * *", "2020-10-16 12:00:00", "2020-11-01 02:30:00"},
+		{"0 12 * 2 *", "2020-10-16 12:00:00", "2021-02-01 12:00:00"},
+		{"0 0 29 2 *", "2020-10-16 12:00:00", "2024-02-29 00:00:00"},
+		// 2020-10-16 is Friday
+		{"0 0 * * 1-5", "2020-10-16 12:00:00", "2020-10-19 00:00:00"},
+		{"0 0 * * 7", "2020-10-16 12:00:00", "2020-10-18 00:00:00"},
+		{"0 0 * * 6-7", "2020-10-16 12:00:00", "2020-10-17 00:00:00"},
+		// day of month and day of week are combined by OR
+		{"0 0 13 * 5", "2020-10-16 12:00:00", "2020-10-23 00:00:00"},
+		{"0,30 8-9 * * *", "2020-10-16 08:45:00", "2020-10-16 09:00:00"},
+		{"@hourly", "2020-10-16 12:59:59", "2020-10-16 13:00:00"},
+		{"@daily", "2020-10-16 12:00:00", "2020-10-17 00:00:00"},
+		{"@every 90s", "2020-10-16 12:00:00", "2020-10-16 12:01:30"},
+	} {
+		t.Run(testCase.expression, func(t *testing.T) {
+			schedule, err := scheduler.ParseSchedule(testCase.expression)
+			helpers.FailOnError(t, err)
+
+			next := schedule.Next(mustParseTime(t, testCase.after))
+			assert.Equal(t, mustParseTime(t, testCase.expected), next)
+		})
+	}
+}
+
+func TestParseSchedule_NeverMatches(t *testing.T) {
+	schedule, err := scheduler.ParseSchedule("0 0 30 2 *")
+	helpers.FailOnError(t, err)
+
+	assert.True(t, schedule.Next(time.Now()).IsZero())
+}
+
+func TestParseSchedule_Invalid(t *testing.T) {
+	for expression, expectedError := range map[string]string{
+		"* * * *":      "expected 5 fields in cron expression '* * * *', got 4",
+		"60 * * * *":   "minute field '60' out of range 0-59",
+		"a * * * *":    "invalid value in minute field 'a'",
+		"*/0 * * * *":  "invalid step in minute field '*/0'",
+		"0 5-1 * * *":  "hour field '5-1' out of range 0-23",
+		"* * * * 8":    "day of week field '8' out of range 0-6",
+		"@every 1ms":   "interval in expression '@every 1ms' must be at least 1s",
+		"@every never": `invalid interval in expression '@every never': time: invalid duration "never"`,
+	} {
+		_, err := scheduler.ParseSchedule(expression)
+		assert.EqualError(t, err, expectedError, expression)
+	}
+}
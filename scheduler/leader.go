@@ -0,0 +1,150 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+)
+
+// DefaultLeaseDuration is used when no lease duration is configured
+const DefaultLeaseDuration = 30 * time.Second
+
+// LeaseStorage stores leases shared by all instances of the service
+type LeaseStorage interface {
+	AcquireLease(name, holder string, duration time.Duration) (bool, error)
+	ReleaseLease(name, holder string) error
+}
+
+// LeaderElector elects the only instance of the service that runs the tasks
+// when multiple replicas of the service are running. The instance holding
+// the lease is the leader, it renews the lease several times during the
+// lease duration. Other instances try to acquire the lease in the same
+// interval, so one of them takes over when the leader stops renewing it.
+type LeaderElector struct {
+	storage  LeaseStorage
+	name     string
+	identity string
+	duration time.Duration
+
+	mutex sync.Mutex
+	// validUntil is the time the lease acquired by this instance expires,
+	// zero when this instance is not the leader
+	validUntil time.Time
+}
+
+// NewLeaderElector constructs leader elector competing for the lease of the
+// given name, identity has to be unique for every instance of the service
+func NewLeaderElector(
+	storage LeaseStorage, name, identity string, duration time.Duration,
+) *LeaderElector {
+	if duration <= 0 {
+		duration = DefaultLeaseDuration
+	}
+
+	return &LeaderElector{
+		storage:  storage,
+		name:     name,
+		identity: identity,
+		duration: duration,
+	}
+}
+
+// IsLeader returns true when this instance holds the lease that has not
+// expired yet
+func (elector *LeaderElector) IsLeader() bool {
+	elector.mutex.Lock()
+	defer elector.mutex.Unlock()
+
+	return time.Now().Before(elector.validUntil)
+}
+
+// TryLead acquires or renews the lease and returns true when this instance
+// is the leader. Leadership is lost when the lease can't be renewed.
+func (elector *LeaderElector) TryLead() bool {
+	// the lease can expire before the storage responds, so its validity is
+	// counted from the time of the request
+	started := time.Now()
+
+	acquired, err := elector.storage.AcquireLease(elector.name, elector.identity, elector.duration)
+	if err != nil {
+		log.Error().Err(err).Str("lease", elector.name).Msg("Unable to acquire lease")
+		acquired = false
+	}
+
+	elector.mutex.Lock()
+	wasLeader := started.Before(elector.validUntil)
+	if acquired {
+		elector.validUntil = started.Add(elector.duration)
+	} else {
+		elector.validUntil = time.Time{}
+	}
+	elector.mutex.Unlock()
+
+	if acquired && !wasLeader {
+		log.Info().Str("lease", elector.name).Str("identity", elector.identity).Msg("Became the leader")
+		metrics.SchedulerLeader.Set(1)
+	} else if !acquired && wasLeader {
+		log.Warn().Str("lease", elector.name).Str("identity", elector.identity).Msg("Leadership lost")
+		metrics.SchedulerLeader.Set(0)
+	}
+
+	return acquired
+}
+
+// run competes for the lease until the context is done, the lease is
+// released then, so another instance can take over immediately
+func (elector *LeaderElector) run(ctx context.Context) {
+	ticker := time.NewTicker(elector.duration / 3)
+	defer ticker.Stop()
+
+	for {
+		elector.TryLead()
+
+		select {
+		case <-ctx.Done():
+			elector.release()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// release gives up the leadership
+func (elector *LeaderElector) release() {
+	if !elector.IsLeader() {
+		return
+	}
+
+	elector.mutex.Lock()
+	elector.validUntil = time.Time{}
+	elector.mutex.Unlock()
+
+	metrics.SchedulerLeader.Set(0)
+
+	if err := elector.storage.ReleaseLease(elector.name, elector.identity); err != nil {
+		log.Error().Err(err).Str("lease", elector.name).Msg("Unable to release lease")
+		return
+	}
+
+	log.Info().Str("lease", elector.name).Msg("Lease released")
+}
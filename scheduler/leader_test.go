@@ -0,0 +1,170 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/scheduler"
+)
+
+// leaseStorage is in-memory lease storage holding one lease
+type leaseStorage struct {
+	mutex    sync.Mutex
+	holder   string
+	err      error
+	released bool
+}
+
+func (storage *leaseStorage) AcquireLease(name, holder string, duration time.Duration) (bool, error) {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	if storage.err != nil {
+		return false, storage.err
+	}
+
+	if storage.holder == "" {
+		storage.holder = holder
+	}
+
+	return storage.holder == holder, nil
+}
+
+func (storage *leaseStorage) ReleaseLease(name, holder string) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	if storage.holder == holder {
+		storage.holder = ""
+		storage.released = true
+	}
+
+	return nil
+}
+
+func (storage *leaseStorage) setError(err error) {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	storage.err = err
+}
+
+func (storage *leaseStorage) isReleased() bool {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	return storage.released
+}
+
+func TestLeaderElector_TryLead(t *testing.T) {
+	storage := &leaseStorage{}
+
+	first := scheduler.NewLeaderElector(storage, "scheduler", "first", time.Minute)
+	second := scheduler.NewLeaderElector(storage, "scheduler", "second", time.Minute)
+
+	assert.False(t, first.IsLeader())
+
+	assert.True(t, first.TryLead())
+	assert.True(t, first.IsLeader())
+
+	assert.False(t, second.TryLead())
+	assert.False(t, second.IsLeader())
+
+	// leadership is lost when the lease can't be renewed
+	storage.setError(errors.New("database is down"))
+	assert.False(t, first.TryLead())
+	assert.False(t, first.IsLeader())
+}
+
+func TestLeaderElector_LeaseExpires(t *testing.T) {
+	elector := scheduler.NewLeaderElector(&leaseStorage{}, "scheduler", "first", time.Millisecond)
+
+	assert.True(t, elector.TryLead())
+
+	time.Sleep(5 * time.Millisecond)
+	assert.False(t, elector.IsLeader())
+}
+
+func TestScheduler_LeaderRunsTask(t *testing.T) {
+	storage := &leaseStorage{}
+	taskScheduler := scheduler.New()
+	runs := make(chan struct{}, 10)
+
+	err := taskScheduler.Register("test", "@every 1s", func() error {
+		runs <- struct{}{}
+		return nil
+	})
+	helpers.FailOnError(t, err)
+
+	elector := scheduler.NewLeaderElector(storage, "scheduler", "first", time.Minute)
+	helpers.FailOnError(t, taskScheduler.SetLeaderElector(elector))
+
+	taskScheduler.Start()
+
+	select {
+	case <-runs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("task was not run")
+	}
+
+	// the lease is released when the scheduler stops
+	taskScheduler.Stop()
+	assert.True(t, storage.isReleased())
+}
+
+func TestScheduler_NotLeaderSkipsTask(t *testing.T) {
+	storage := &leaseStorage{holder: "another instance"}
+	taskScheduler := scheduler.New()
+	runs := make(chan struct{}, 10)
+
+	err := taskScheduler.Register("test", "@every 1s", func() error {
+		runs <- struct{}{}
+		return nil
+	})
+	helpers.FailOnError(t, err)
+
+	elector := scheduler.NewLeaderElector(storage, "scheduler", "first", time.Minute)
+	helpers.FailOnError(t, taskScheduler.SetLeaderElector(elector))
+
+	taskScheduler.Start()
+	defer taskScheduler.Stop()
+
+	select {
+	case <-runs:
+		t.Fatal("task should not run on instance that is not the leader")
+	case <-time.After(2500 * time.Millisecond):
+	}
+}
+
+func TestScheduler_SetLeaderElector_AfterStart(t *testing.T) {
+	taskScheduler := scheduler.New()
+	taskScheduler.Start()
+	defer taskScheduler.Stop()
+
+	elector := scheduler.NewLeaderElector(&leaseStorage{}, "scheduler", "first", time.Minute)
+	assert.EqualError(
+		t, taskScheduler.SetLeaderElector(elector),
+		"unable to set leader elector, scheduler is already running",
+	)
+}
@@ -0,0 +1,201 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package scheduler contains simple embedded scheduler that runs registered
+// periodic tasks (like retention cleanup or data export) according to
+// cron-like expressions taken from the configuration.
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// TaskFunc is a function performing the task
+type TaskFunc func() error
+
+// task is one registered task
+type task struct {
+	name     string
+	schedule Schedule
+	run      TaskFunc
+}
+
+// Scheduler runs registered tasks according to their schedules. Every task
+// runs in its own goroutine, so a long running task never delays other tasks
+// and runs of the same task never overlap. When leader elector is set, tasks
+// run only when this instance of the service is the leader.
+type Scheduler struct {
+	tasks     []task
+	elector   *LeaderElector
+	cancel    context.CancelFunc
+	waitGroup sync.WaitGroup
+	mutex     sync.Mutex
+}
+
+// New constructs new scheduler without any task
+func New() *Scheduler {
+	return &Scheduler{}
+}
+
+// Register adds new task to the scheduler. The expression is parsed by
+// ParseSchedule. Tasks need to be registered before the scheduler is started.
+func (scheduler *Scheduler) Register(name, expression string, run TaskFunc) error {
+	schedule, err := ParseSchedule(expression)
+	if err != nil {
+		return fmt.Errorf("invalid schedule of task %s: %v", name, err)
+	}
+
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	if scheduler.cancel != nil {
+		return fmt.Errorf("unable to register task %s, scheduler is already running", name)
+	}
+
+	for _, registered := range scheduler.tasks {
+		if registered.name == name {
+			return fmt.Errorf("task %s is already registered", name)
+		}
+	}
+
+	scheduler.tasks = append(scheduler.tasks, task{name, schedule, run})
+
+	log.Info().Str("task", name).Str("schedule", expression).Msg("Task registered")
+
+	return nil
+}
+
+// SetLeaderElector makes the scheduler run tasks only when the elector has
+// elected this instance as the leader. It needs to be set before the
+// scheduler is started.
+func (scheduler *Scheduler) SetLeaderElector(elector *LeaderElector) error {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	if scheduler.cancel != nil {
+		return fmt.Errorf("unable to set leader elector, scheduler is already running")
+	}
+
+	scheduler.elector = elector
+	return nil
+}
+
+// Start starts all registered tasks. It doesn't block.
+func (scheduler *Scheduler) Start() {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	if scheduler.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	scheduler.cancel = cancel
+
+	if scheduler.elector != nil {
+		scheduler.waitGroup.Add(1)
+		go func() {
+			defer scheduler.waitGroup.Done()
+			scheduler.elector.run(ctx)
+		}()
+	}
+
+	for _, registered := range scheduler.tasks {
+		scheduler.waitGroup.Add(1)
+		go scheduler.loop(ctx, registered)
+	}
+
+	log.Info().Int("tasks", len(scheduler.tasks)).Msg("Scheduler started")
+}
+
+// Stop stops the scheduler and waits for all running tasks to finish
+func (scheduler *Scheduler) Stop() {
+	scheduler.mutex.Lock()
+	cancel := scheduler.cancel
+	scheduler.mutex.Unlock()
+
+	if cancel == nil {
+		return
+	}
+
+	cancel()
+	scheduler.waitGroup.Wait()
+
+	log.Info().Msg("Scheduler stopped")
+}
+
+// loop runs the task according to its schedule until the context is done
+func (scheduler *Scheduler) loop(ctx context.Context, registered task) {
+	defer scheduler.waitGroup.Done()
+
+	for {
+		next := registered.schedule.Next(time.Now())
+		if next.IsZero() {
+			log.Warn().Str("task", registered.name).Msg("Task schedule never matches, task won't run")
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			if scheduler.elector != nil && !scheduler.elector.IsLeader() {
+				log.Debug().Str("task", registered.name).Msg("Task skipped, this instance is not the leader")
+				continue
+			}
+			runTask(registered)
+		}
+	}
+}
+
+// runTask runs the task once and logs the result. Panics are recovered so
+// a failing task doesn't stop the whole service.
+func runTask(registered task) {
+	started := time.Now()
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Error().
+				Str("task", registered.name).
+				Interface("panic", recovered).
+				Msg("Task panicked")
+		}
+	}()
+
+	log.Info().Str("task", registered.name).Msg("Task started")
+
+	if err := registered.run(); err != nil {
+		log.Error().
+			Err(err).
+			Str("task", registered.name).
+			Dur("duration", time.Since(started)).
+			Msg("Task failed")
+		return
+	}
+
+	log.Info().
+		Str("task", registered.name).
+		Dur("duration", time.Since(started)).
+		Msg("Task finished")
+}
@@ -0,0 +1,106 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/scheduler"
+)
+
+func noopTask() error {
+	return nil
+}
+
+func TestScheduler_RunsTask(t *testing.T) {
+	taskScheduler := scheduler.New()
+	runs := make(chan struct{}, 10)
+
+	err := taskScheduler.Register("test", "@every 1s", func() error {
+		runs <- struct{}{}
+		return nil
+	})
+	helpers.FailOnError(t, err)
+
+	taskScheduler.Start()
+	defer taskScheduler.Stop()
+
+	select {
+	case <-runs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("task was not run")
+	}
+}
+
+func TestScheduler_PanickingTask(t *testing.T) {
+	taskScheduler := scheduler.New()
+	runs := make(chan struct{}, 10)
+
+	err := taskScheduler.Register("test", "@every 1s", func() error {
+		runs <- struct{}{}
+		panic("task failure")
+	})
+	helpers.FailOnError(t, err)
+
+	taskScheduler.Start()
+	defer taskScheduler.Stop()
+
+	// the task keeps being scheduled after panic
+	for i := 0; i < 2; i++ {
+		select {
+		case <-runs:
+		case <-time.After(5 * time.Second):
+			t.Fatal("task was not run")
+		}
+	}
+}
+
+func TestScheduler_StopWithoutStart(t *testing.T) {
+	taskScheduler := scheduler.New()
+	taskScheduler.Stop()
+}
+
+func TestScheduler_Register_InvalidSchedule(t *testing.T) {
+	err := scheduler.New().Register("test", "* * *", noopTask)
+	assert.EqualError(
+		t, err, "invalid schedule of task test: expected 5 fields in cron expression '* * *', got 3",
+	)
+}
+
+func TestScheduler_Register_Duplicate(t *testing.T) {
+	taskScheduler := scheduler.New()
+
+	helpers.FailOnError(t, taskScheduler.Register("test", "@daily", noopTask))
+	assert.EqualError(
+		t, taskScheduler.Register("test", "@hourly", noopTask), "task test is already registered",
+	)
+}
+
+func TestScheduler_Register_AfterStart(t *testing.T) {
+	taskScheduler := scheduler.New()
+	taskScheduler.Start()
+	defer taskScheduler.Stop()
+
+	assert.EqualError(
+		t, taskScheduler.Register("test", "@daily", noopTask),
+		"unable to register task test, scheduler is already running",
+	)
+}
@@ -0,0 +1,164 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	main "github.com/RedHatInsights/insights-results-aggregator"
+	"github.com/RedHatInsights/insights-results-aggregator/archive"
+	"github.com/RedHatInsights/insights-results-aggregator/scheduler"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+)
+
+func TestRegisterSchedulerTasks(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := main.RegisterSchedulerTasks(scheduler.New(), scheduler.Configuration{
+		RetentionCleanupSchedule:       "@daily",
+		ReportRetention:                90 * 24 * time.Hour,
+		StaleClustersDetectionSchedule: "*/10 * * * *",
+		StaleClusterThreshold:          24 * time.Hour,
+		MetricsCollectionSchedule:      "@every 1m",
+	}, mockStorage)
+	helpers.FailOnError(t, err)
+}
+
+func TestRegisterSchedulerTasks_MissingRetention(t *testing.T) {
+	err := main.RegisterSchedulerTasks(scheduler.New(), scheduler.Configuration{
+		RetentionCleanupSchedule: "@daily",
+	}, nil)
+	assert.EqualError(t, err, "report_retention or consumer_error_retention needs to be set for retention cleanup task")
+}
+
+func TestRegisterSchedulerTasks_ConsumerErrorRetentionOnly(t *testing.T) {
+	err := main.RegisterSchedulerTasks(scheduler.New(), scheduler.Configuration{
+		RetentionCleanupSchedule: "@daily",
+		ConsumerErrorRetention:   30 * 24 * time.Hour,
+	}, nil)
+	helpers.FailOnError(t, err)
+}
+
+func TestRegisterSchedulerTasks_MissingStaleThreshold(t *testing.T) {
+	err := main.RegisterSchedulerTasks(scheduler.New(), scheduler.Configuration{
+		StaleClustersDetectionSchedule: "@daily",
+	}, nil)
+	assert.EqualError(t, err, "stale_cluster_threshold needs to be set for stale clusters detection task")
+}
+
+func TestRegisterSchedulerTasks_ArchiveNotEnabled(t *testing.T) {
+	err := main.RegisterSchedulerTasks(scheduler.New(), scheduler.Configuration{
+		ReportArchivalSchedule: "@daily",
+	}, nil)
+	assert.EqualError(t, err, "archive needs to be enabled with report_age set for report archival task")
+}
+
+func TestRegisterSchedulerTasks_InvalidSchedule(t *testing.T) {
+	err := main.RegisterSchedulerTasks(scheduler.New(), scheduler.Configuration{
+		MetricsCollectionSchedule: "every minute",
+	}, nil)
+	assert.EqualError(
+		t, err,
+		"invalid schedule of task metrics_collection: expected 5 fields in cron expression 'every minute', got 2",
+	)
+}
+
+func TestRetentionCleanupTask(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.Report3Rules,
+		testdata.Report3RulesParsed,
+		time.Now().Add(-2*time.Hour),
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.FailOnError(t, main.RetentionCleanupTask(mockStorage, 3*time.Hour, 0))
+
+	count, err := mockStorage.ReportsCount()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 1, count)
+
+	helpers.FailOnError(t, main.RetentionCleanupTask(mockStorage, time.Hour, time.Hour))
+
+	count, err = mockStorage.ReportsCount()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestReportArchivalTask(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	reportArchive := archive.NewWithStore(archive.DirectoryStore{Directory: t.TempDir()}, "")
+	mockStorage.(*storage.DBStorage).SetReportArchive(reportArchive)
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.Report3Rules,
+		testdata.Report3RulesParsed,
+		time.Now().Add(-2*time.Hour),
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.FailOnError(t, main.ReportArchivalTask(mockStorage, 3*time.Hour, 0))
+
+	count, err := mockStorage.ReportsCount()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 1, count)
+
+	helpers.FailOnError(t, main.ReportArchivalTask(mockStorage, time.Hour, 0))
+
+	count, err = mockStorage.ReportsCount()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 0, count)
+
+	// the report is read from the archive
+	rules, _, err := mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Len(t, rules, 3)
+}
+
+func TestSchedulerTasks_DBError(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	assert.Error(t, main.RetentionCleanupTask(mockStorage, time.Hour, time.Hour))
+	assert.Error(t, main.StaleClustersDetectionTask(mockStorage, time.Hour))
+	assert.Error(t, main.MetricsCollectionTask(mockStorage))
+}
+
+func TestStaleClustersDetectionAndMetricsCollectionTasks(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	helpers.FailOnError(t, main.StaleClustersDetectionTask(mockStorage, time.Hour))
+	helpers.FailOnError(t, main.MetricsCollectionTask(mockStorage))
+}
@@ -19,8 +19,15 @@ import (
 
 	"github.com/rs/zerolog/log"
 
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/chaos"
 	"github.com/RedHatInsights/insights-results-aggregator/conf"
+	"github.com/RedHatInsights/insights-results-aggregator/consumer"
+	"github.com/RedHatInsights/insights-results-aggregator/events"
+	"github.com/RedHatInsights/insights-results-aggregator/inventory"
+	"github.com/RedHatInsights/insights-results-aggregator/producer"
 	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
 )
 
 var (
@@ -34,15 +41,82 @@ func startServer() error {
 		finishServerInstanceInitialization()
 	}()
 
-	dbStorage, err := createStorage()
+	serviceStorage, err := createServiceStorage()
 	if err != nil {
 		return err
 	}
-	defer closeStorage(dbStorage)
+	defer closeStorage(serviceStorage)
+
+	serverStorage, closeShadowStorage, err := createShadowReadStorage(serviceStorage)
+	if err != nil {
+		return err
+	}
+	defer closeShadowStorage()
+
+	serverStorage, closeCachedStorage, err := createCachedStorage(serverStorage)
+	if err != nil {
+		return err
+	}
+	defer closeCachedStorage()
 
 	serverCfg := conf.GetServerConfiguration()
 
-	serverInstance = server.New(serverCfg, dbStorage)
+	serverInstance = server.New(serverCfg, serverStorage)
+	serverInstance.EffectiveConfiguration = conf.GetSanitizedConfiguration()
+	serverInstance.DefaultConfiguration = conf.Defaults
+
+	exportCfg := conf.GetExportConfiguration()
+	serverInstance.ExportConfiguration = &exportCfg
+
+	publisher, closePublisher, err := createEventPublisher()
+	if err != nil {
+		return err
+	}
+	defer closePublisher()
+
+	// rule toggles are published to the event bus, external publishers
+	// are just one of its subscribers
+	if publisher != nil {
+		defer events.DefaultBus.SubscribePublisher(publisher)()
+	}
+	serverInstance.EventPublisher = events.DefaultBus
+
+	// display names of clusters are cached, so the inventory is not asked
+	// on every request
+	inventoryCfg := conf.GetInventoryConfiguration()
+	if inventoryCfg.URL != "" {
+		serverInstance.DisplayNames = inventory.NewCachingProvider(
+			inventory.NewHTTPProvider(inventoryCfg.URL, inventoryCfg.Timeout), inventoryCfg.CacheTTL,
+		)
+	}
+
+	if chaos.DefaultInjector.Enabled() {
+		serverInstance.FaultInjector = chaos.DefaultInjector
+	}
+
+	brokerCfg := conf.GetBrokerConfiguration()
+
+	// messages are processed the same way the consumer does it, no
+	// connection to the broker is needed
+	if serverCfg.Debug {
+		serverInstance.IngestSimulator = consumer.NewIngestSimulator(brokerCfg)
+	}
+
+	// lag of the consumer is reported only by the debug endpoint, so the
+	// server starts even if the broker is not available
+	if serverCfg.Debug && brokerCfg.Enabled {
+		highWaterMarks, err := broker.NewHighWaterMarks(brokerCfg)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to connect to broker, consumer lag won't be available")
+		} else {
+			defer func() {
+				if err := highWaterMarks.Close(); err != nil {
+					log.Error().Err(err).Msg("Unable to close connection to broker")
+				}
+			}()
+			serverInstance.HighWaterMarks = highWaterMarks
+		}
+	}
 
 	err = serverInstance.Start(finishServerInstanceInitialization)
 	if err != nil {
@@ -53,6 +127,77 @@ func startServer() error {
 	return nil
 }
 
+// createShadowReadStorage wraps the storage into storage sending all reads to
+// the candidate storage too, when shadow-read mode is enabled. The returned
+// function waits for running comparisons and closes the candidate storage.
+func createShadowReadStorage(current storage.Storage) (storage.Storage, func(), error) {
+	shadowCfg := conf.GetShadowStorageConfiguration()
+	if !shadowCfg.Enabled {
+		return current, func() {}, nil
+	}
+
+	candidate, err := storage.NewStorage(shadowCfg.Configuration)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to create candidate storage for shadow reads")
+		return nil, nil, err
+	}
+
+	if dbStorage, ok := candidate.(*storage.DBStorage); ok {
+		detectSchemaVersion(dbStorage)
+	}
+
+	shadowStorage := storage.NewShadowReadStorage(current, candidate, shadowCfg.MaxPendingComparisons)
+	closeShadowStorage := func() {
+		shadowStorage.Wait()
+		closeStorage(candidate)
+	}
+
+	return shadowStorage, closeShadowStorage, nil
+}
+
+// createEventPublisher constructs publisher of rule toggle events according
+// to the configuration. Nil publisher is returned when neither Kafka topic nor
+// webhooks are configured. The returned function closes the publisher.
+func createEventPublisher() (events.Publisher, func(), error) {
+	brokerCfg := conf.GetBrokerConfiguration()
+	eventsCfg := conf.GetEventsConfiguration()
+
+	var publishers events.Publishers
+	closePublisher := func() {}
+
+	if brokerCfg.RuleToggleTopic != "" {
+		kafkaProducer, err := producer.New(brokerCfg)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to create producer for rule toggle events")
+			return nil, nil, err
+		}
+
+		publishers = append(publishers, kafkaProducer)
+		closePublisher = func() {
+			_ = kafkaProducer.Close()
+		}
+	}
+
+	if len(eventsCfg.WebhookURLs) > 0 {
+		publishers = append(publishers, events.NewWebhookPublisher(eventsCfg.WebhookURLs, eventsCfg.WebhookTimeout))
+	}
+
+	if len(publishers) == 0 {
+		return nil, closePublisher, nil
+	}
+
+	// slow webhooks or broker must not delay responses to rule toggles,
+	// queued events are published before the Kafka producer is closed
+	asyncPublisher := events.NewAsyncPublisher(publishers, events.DefaultAsyncQueueSize)
+	closeProducer := closePublisher
+	closePublisher = func() {
+		asyncPublisher.Close()
+		closeProducer()
+	}
+
+	return asyncPublisher, closePublisher, nil
+}
+
 func stopServer() error {
 	waitForServerToStartOrFail()
 
@@ -69,6 +214,14 @@ func stopServer() error {
 	return nil
 }
 
+// resetServerInstance forgets the server started by the previous run of the
+// service, so stopServer waits for the server of the next run instead of
+// stopping the old one. It must not be called while stopServer is waiting.
+func resetServerInstance() {
+	serverInstance = nil
+	serverInstanceIsStarting, finishServerInstanceInitialization = context.WithCancel(context.Background())
+}
+
 func waitForServerToStartOrFail() {
 	log.Info().Msg("waiting for server to start")
 	<-serverInstanceIsStarting.Done()
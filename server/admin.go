@@ -0,0 +1,278 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const (
+	// clusterOrgChangesDaysQueryParam is the number of days the changes of
+	// organizations of clusters are returned for
+	clusterOrgChangesDaysQueryParam = "days"
+	// defaultClusterOrgChangesDays is used when the number of days is not
+	// specified
+	defaultClusterOrgChangesDays = 7
+	// cacheDivergenceSampleQueryParam is the number of clusters sampled from
+	// the database and from the cache
+	cacheDivergenceSampleQueryParam = "sample"
+	// defaultCacheDivergenceSample is used when the sample size is not
+	// specified
+	defaultCacheDivergenceSample = 500
+	// maxCacheDivergenceSample keeps the number of query parameters below
+	// the SQLite limit
+	maxCacheDivergenceSample = 900
+)
+
+// orgStaleReportWrites contains stale report writes of all clusters from
+// one organization
+type orgStaleReportWrites struct {
+	OrgID         types.OrgID              `json:"org_id"`
+	RejectedCount int                      `json:"rejected_count"`
+	Clusters      []types.StaleReportWrite `json:"clusters"`
+}
+
+// rebuildClustersLastCheckedCache reloads the cache of timestamps when the
+// clusters were last checked from the storage
+func (server *HTTPServer) rebuildClustersLastCheckedCache(writer http.ResponseWriter, _ *http.Request) {
+	size, err := server.Storage.RebuildClustersLastCheckedCache()
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to rebuild clusters last checked cache")
+		handleServerError(writer, err)
+		return
+	}
+
+	log.Info().Int("size", size).Msg("Clusters last checked cache has been rebuilt")
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("size", size))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// getClustersLastCheckedCacheStats returns statistics about the cache of
+// timestamps when the clusters were last checked
+func (server *HTTPServer) getClustersLastCheckedCacheStats(writer http.ResponseWriter, _ *http.Request) {
+	stats, err := server.Storage.GetClustersLastCheckedCacheStats()
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to get clusters last checked cache stats")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("stats", stats))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// cacheDivergenceSampleParam defines optional query parameter with the
+// number of sampled clusters
+func cacheDivergenceSampleParam(sampleSize *int) param {
+	return param{
+		name:   cacheDivergenceSampleQueryParam,
+		source: queryParam,
+		parse: func(rawValue string) error {
+			value, err := strconv.ParseUint(rawValue, 10, 31)
+			if err != nil {
+				return errors.New("unsigned integer expected")
+			}
+
+			if value == 0 || value > maxCacheDivergenceSample {
+				return fmt.Errorf("integer between 1 and %d expected", maxCacheDivergenceSample)
+			}
+
+			*sampleSize = int(value)
+			return nil
+		},
+	}
+}
+
+// getClustersLastCheckedDivergence compares sample of the cache of timestamps
+// when the clusters were last checked with the database, so staleness of the
+// cache of this instance caused by writes of other instances can be measured
+func (server *HTTPServer) getClustersLastCheckedDivergence(writer http.ResponseWriter, request *http.Request) {
+	sampleSize := defaultCacheDivergenceSample
+	if !readParams(writer, request, cacheDivergenceSampleParam(&sampleSize)) {
+		// everything has been handled already
+		return
+	}
+
+	divergence, err := server.Storage.CheckClustersLastCheckedDivergence(sampleSize)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to check divergence of clusters last checked cache")
+		handleServerError(writer, err)
+		return
+	}
+
+	log.Info().
+		Int("sampled_reports", divergence.SampledReports).
+		Int("stale", divergence.Stale).
+		Int("ahead", divergence.Ahead).
+		Int("missing_in_cache", divergence.MissingInCache).
+		Int("missing_in_storage", divergence.MissingInStorage).
+		Msg("Divergence of clusters last checked cache has been checked")
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("divergence", divergence))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// getStaleReportWrites returns statistics of reports rejected because a more
+// recent report of the cluster was already stored, grouped by organization
+func (server *HTTPServer) getStaleReportWrites(writer http.ResponseWriter, _ *http.Request) {
+	staleWrites, err := server.Storage.ReadStaleReportWrites()
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read stale report writes")
+		handleServerError(writer, err)
+		return
+	}
+
+	// stale writes are ordered by organization
+	orgs := make([]orgStaleReportWrites, 0)
+	for _, staleWrite := range staleWrites {
+		if len(orgs) == 0 || orgs[len(orgs)-1].OrgID != staleWrite.OrgID {
+			orgs = append(orgs, orgStaleReportWrites{OrgID: staleWrite.OrgID})
+		}
+
+		org := &orgs[len(orgs)-1]
+		org.RejectedCount += staleWrite.RejectedCount
+		org.Clusters = append(org.Clusters, staleWrite)
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("organizations", orgs))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// getRawRuleHits returns rule hits of the cluster as they are stored in the
+// database, without assembling the report, so storage problems can be
+// distinguished from problems in the report assembly
+func (server *HTTPServer) getRawRuleHits(writer http.ResponseWriter, request *http.Request) {
+	clusterName, successful := readClusterName(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	clusterExists, err := server.Storage.DoesClusterExist(clusterName)
+	if err != nil {
+		handleServerError(writer, err)
+		return
+	}
+	if !clusterExists {
+		handleServerError(writer, &types.ItemNotFoundError{ItemID: clusterName})
+		return
+	}
+
+	ruleHits, err := server.Storage.ReadRuleHitsForCluster(clusterName)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read rule hits of the cluster")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("rule_hits", ruleHits))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// getClusterOrgChanges returns clusters that started to report under another
+// organization in the last days (7 by default), the most recent change goes
+// first
+func (server *HTTPServer) getClusterOrgChanges(writer http.ResponseWriter, request *http.Request) {
+	days, daysPresent, successful := readUintQueryParam(writer, request, clusterOrgChangesDaysQueryParam)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	if !daysPresent {
+		days = defaultClusterOrgChangesDays
+	}
+
+	since := time.Now().AddDate(0, 0, -days)
+
+	changes, err := server.Storage.ReadClusterOrgChanges(since)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read changes of organizations of clusters")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("changes", changes))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// getDBSchema returns tables, columns and indexes of the deployed database
+// as introspected at runtime together with its migration version
+func (server *HTTPServer) getDBSchema(writer http.ResponseWriter, _ *http.Request) {
+	if !server.Storage.Capabilities().SchemaIntrospection {
+		err := responses.SendServiceUnavailable(writer, "DB schema introspection is not supported by the storage")
+		if err != nil {
+			log.Error().Err(err).Msg(responseDataError)
+		}
+		return
+	}
+
+	schema, err := server.Storage.ReadDBSchema()
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read DB schema")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("schema", schema))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// lookupMessageKey returns reports and consumer errors stored for the key of
+// the Kafka message, so the upload can be traced from the platform gateway
+// to the stored report
+func (server *HTTPServer) lookupMessageKey(writer http.ResponseWriter, request *http.Request) {
+	key, err := getRouterParam(request, "key")
+	if err != nil {
+		handleServerError(writer, err)
+		return
+	}
+
+	lookup, err := server.Storage.LookupMessageKey(key)
+	if err != nil {
+		log.Error().Err(err).Str("message_key", key).Msg("Unable to look up message key")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("message_key", lookup))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
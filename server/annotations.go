@@ -0,0 +1,136 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// annotationsQueryParam is the name of report endpoint query parameter
+// requesting cluster annotations in the report meta
+const annotationsQueryParam = "annotations"
+
+// reportResponseMetaWithAnnotations is the report meta extended by cluster
+// annotations
+type reportResponseMetaWithAnnotations struct {
+	reportResponseMeta
+	Annotations []types.ClusterAnnotation `json:"annotations"`
+}
+
+// reportResponseWithAnnotations is the report response with cluster
+// annotations in the meta
+type reportResponseWithAnnotations struct {
+	Meta   reportResponseMetaWithAnnotations `json:"meta"`
+	Report []ruleOnReport                    `json:"reports"`
+}
+
+// addClusterAnnotation attaches a new annotation to the cluster report
+func (server *HTTPServer) addClusterAnnotation(writer http.ResponseWriter, request *http.Request) {
+	var (
+		clusterID types.ClusterName
+		userID    types.UserID
+	)
+	if !readParams(writer, request, clusterNameParam(&clusterID), userIDParam(&userID)) ||
+		!server.checkUserClusterPermissions(writer, request, clusterID) {
+		// everything has been handled already
+		return
+	}
+
+	message, err := server.getFeedbackMessageFromBody(request)
+	if err != nil {
+		handleServerError(writer, err)
+		return
+	}
+
+	message = strings.TrimSpace(message)
+	if message == "" {
+		handleServerError(writer, &types.ValidationError{
+			ParamName:  "message",
+			ParamValue: message,
+			ErrString:  "annotation message can not be empty",
+		})
+		return
+	}
+
+	annotation, err := server.Storage.AddClusterAnnotation(clusterID, userID, message)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to add cluster annotation")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendCreated(writer, responses.BuildOkResponseWithData("annotation", annotation))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// getClusterAnnotations returns all annotations of the cluster
+func (server *HTTPServer) getClusterAnnotations(writer http.ResponseWriter, request *http.Request) {
+	clusterID, successful := readClusterName(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	successful = server.checkUserClusterPermissions(writer, request, clusterID)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	annotations, err := server.Storage.ReadClusterAnnotations(clusterID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read cluster annotations")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("annotations", annotations))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// deleteClusterAnnotation deletes the annotation of the cluster
+func (server *HTTPServer) deleteClusterAnnotation(writer http.ResponseWriter, request *http.Request) {
+	var (
+		clusterID    types.ClusterName
+		annotationID string
+	)
+	if !readParams(writer, request, clusterNameParam(&clusterID), uuidParam("annotation_id", &annotationID)) ||
+		!server.checkUserClusterPermissions(writer, request, clusterID) {
+		// everything has been handled already
+		return
+	}
+
+	err := server.Storage.DeleteClusterAnnotation(clusterID, annotationID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to delete cluster annotation")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponse())
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
@@ -0,0 +1,363 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const (
+	// APIKeyHeader is the header containing API key used by internal
+	// services instead of the identity token
+	APIKeyHeader = "x-api-key"
+	// APIKeyScopeRead allows the API key to be used for requests reading data
+	APIKeyScopeRead = "read"
+	// APIKeyScopeWrite allows the API key to be used for requests changing data
+	APIKeyScopeWrite = "write"
+	// APIKeyScopeAdmin allows the API key to be used for admin endpoints and
+	// for all other requests
+	APIKeyScopeAdmin = "admin"
+
+	// apiKeySeparator separates ID of the key from its secret
+	apiKeySeparator = "."
+	// apiKeyIDBytes and apiKeySecretBytes are numbers of random bytes of ID
+	// and secret of generated API keys
+	apiKeyIDBytes     = 8
+	apiKeySecretBytes = 32
+
+	// #nosec G101
+	invalidAPIKeyMessage = "Invalid API key"
+)
+
+// apiKeyScopes are all scopes the API key can have
+var apiKeyScopes = map[string]bool{
+	APIKeyScopeRead:  true,
+	APIKeyScopeWrite: true,
+	APIKeyScopeAdmin: true,
+}
+
+// apiKeyContextKey is the key of ID of the API key the request was
+// authenticated by in the request context
+type apiKeyContextKey struct{}
+
+// apiKeyRequest is the body of the request creating new API key
+type apiKeyRequest struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	ExpiresAt string   `json:"expires_at"`
+}
+
+// generateAPIKey generates random ID and secret of new API key
+func generateAPIKey() (keyID, secret string, err error) {
+	keyID, err = randomHex(apiKeyIDBytes)
+	if err != nil {
+		return "", "", err
+	}
+
+	secret, err = randomHex(apiKeySecretBytes)
+	if err != nil {
+		return "", "", err
+	}
+
+	return keyID, secret, nil
+}
+
+// CreateAPIKey generates new API key with the given scopes and stores it.
+// Only hash of the secret is stored, so the returned key can't be read later.
+func CreateAPIKey(
+	storage storage.Storage, name string, scopes []string, expiresAt time.Time,
+) (keyID, key string, err error) {
+	keyID, secret, err := generateAPIKey()
+	if err != nil {
+		return "", "", err
+	}
+
+	err = storage.CreateAPIKey(keyID, name, scopes, hashAPIKeySecret(secret), expiresAt)
+	if err != nil {
+		return "", "", err
+	}
+
+	return keyID, keyID + apiKeySeparator + secret, nil
+}
+
+// randomHex returns the given number of random bytes encoded in hex
+func randomHex(size int) (string, error) {
+	bytes := make([]byte, size)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(bytes), nil
+}
+
+// hashAPIKeySecret returns hash of the secret of the API key, only the hash
+// is stored
+func hashAPIKeySecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+// requiredAPIKeyScope returns the scope the API key needs to have to be used
+// for the request
+func requiredAPIKeyScope(request *http.Request) string {
+	switch request.Method {
+	case http.MethodGet, http.MethodHead:
+		return APIKeyScopeRead
+	default:
+		return APIKeyScopeWrite
+	}
+}
+
+// hasAPIKeyScope returns true when the API key has the scope, admin scope
+// includes all other scopes
+func hasAPIKeyScope(key types.APIKey, scope string) bool {
+	for _, keyScope := range key.Scopes {
+		if keyScope == scope || keyScope == APIKeyScopeAdmin {
+			return true
+		}
+	}
+
+	return false
+}
+
+// authenticateAPIKey checks that the API key exists, it is neither revoked
+// nor expired and it has the scope needed for the request. The key is
+// returned when it's valid.
+func (server *HTTPServer) authenticateAPIKey(request *http.Request, apiKey string) (types.APIKey, error) {
+	return server.authenticateAPIKeyWithScope(apiKey, requiredAPIKeyScope(request))
+}
+
+// authenticateAPIKeyWithScope checks that the API key exists, it is neither
+// revoked nor expired and it has the given scope
+func (server *HTTPServer) authenticateAPIKeyWithScope(apiKey, scope string) (types.APIKey, error) {
+	parts := strings.SplitN(apiKey, apiKeySeparator, 2)
+	if len(parts) != 2 {
+		return types.APIKey{}, &UnauthorizedError{ErrString: invalidAPIKeyMessage}
+	}
+
+	key, keyHash, err := server.Storage.ReadAPIKey(parts[0])
+	if _, notFound := err.(*types.ItemNotFoundError); notFound {
+		return key, &UnauthorizedError{ErrString: invalidAPIKeyMessage}
+	}
+	if err != nil {
+		return key, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashAPIKeySecret(parts[1])), []byte(keyHash)) != 1 {
+		return key, &UnauthorizedError{ErrString: invalidAPIKeyMessage}
+	}
+
+	if key.RevokedAt != "" {
+		return key, &UnauthorizedError{ErrString: "API key is revoked"}
+	}
+
+	if key.ExpiresAt != "" {
+		expiresAt, err := time.Parse(time.RFC3339, string(key.ExpiresAt))
+		if err != nil || !time.Now().Before(expiresAt) {
+			return key, &UnauthorizedError{ErrString: "API key is expired"}
+		}
+	}
+
+	if hasAPIKeyScope(key, scope) {
+		return key, nil
+	}
+
+	return key, &ForbiddenError{ErrString: fmt.Sprintf("API key doesn't have scope %s", scope)}
+}
+
+// withAPIKey returns the request with ID of the API key stored in its context
+func withAPIKey(request *http.Request, key types.APIKey) *http.Request {
+	return request.WithContext(context.WithValue(request.Context(), apiKeyContextKey{}, key.KeyID))
+}
+
+// isAuthenticatedByAPIKey returns true when the request was authenticated by
+// API key of an internal service, such requests can access data of all
+// organizations
+func isAuthenticatedByAPIKey(request *http.Request) bool {
+	_, found := request.Context().Value(apiKeyContextKey{}).(string)
+	return found
+}
+
+// readAPIKeyRequest reads and validates body of the request creating API key
+func readAPIKeyRequest(writer http.ResponseWriter, request *http.Request) (apiKeyRequest, time.Time, bool) {
+	var (
+		body      apiKeyRequest
+		expiresAt time.Time
+	)
+
+	err := json.NewDecoder(request.Body).Decode(&body)
+	if err != nil || strings.TrimSpace(body.Name) == "" {
+		handleServerError(writer, &types.ValidationError{
+			ParamName:  "name",
+			ParamValue: body.Name,
+			ErrString:  "name of the API key expected",
+		})
+		return body, expiresAt, false
+	}
+
+	if len(body.Scopes) == 0 {
+		handleServerError(writer, &types.ValidationError{
+			ParamName:  "scopes",
+			ParamValue: body.Scopes,
+			ErrString:  "at least one scope expected",
+		})
+		return body, expiresAt, false
+	}
+
+	for _, scope := range body.Scopes {
+		if !apiKeyScopes[scope] {
+			handleServerError(writer, &types.ValidationError{
+				ParamName:  "scopes",
+				ParamValue: scope,
+				ErrString:  "unknown scope",
+			})
+			return body, expiresAt, false
+		}
+	}
+
+	if body.ExpiresAt != "" {
+		expiresAt, err = time.Parse(time.RFC3339, body.ExpiresAt)
+		if err != nil || !expiresAt.After(time.Now()) {
+			handleServerError(writer, &types.ValidationError{
+				ParamName:  "expires_at",
+				ParamValue: body.ExpiresAt,
+				ErrString:  "time in the future in RFC 3339 format expected",
+			})
+			return body, expiresAt, false
+		}
+	}
+
+	return body, expiresAt, true
+}
+
+// createAPIKey creates new API key. The key is returned only in the
+// response, it can't be read later.
+func (server *HTTPServer) createAPIKey(writer http.ResponseWriter, request *http.Request) {
+	body, expiresAt, successful := readAPIKeyRequest(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	keyID, key, err := CreateAPIKey(server.Storage, body.Name, body.Scopes, expiresAt)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to create API key")
+		handleServerError(writer, err)
+		return
+	}
+
+	log.Info().Str("key_id", keyID).Str("name", body.Name).Strs("scopes", body.Scopes).Msg("API key created")
+
+	server.sendAPIKey(writer, keyID, key, http.StatusCreated)
+}
+
+// rotateAPIKey generates new secret of the API key, the previous secret
+// can't be used anymore
+func (server *HTTPServer) rotateAPIKey(writer http.ResponseWriter, request *http.Request) {
+	keyID, err := getRouterParam(request, "key_id")
+	if err != nil {
+		handleServerError(writer, err)
+		return
+	}
+
+	_, secret, err := generateAPIKey()
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to generate API key")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = server.Storage.RotateAPIKey(keyID, hashAPIKeySecret(secret))
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to rotate API key")
+		handleServerError(writer, err)
+		return
+	}
+
+	log.Info().Str("key_id", keyID).Msg("API key rotated")
+
+	server.sendAPIKey(writer, keyID, keyID+apiKeySeparator+secret, http.StatusOK)
+}
+
+// sendAPIKey sends the stored API key together with the key itself
+func (server *HTTPServer) sendAPIKey(writer http.ResponseWriter, keyID, key string, statusCode int) {
+	storedKey, _, err := server.Storage.ReadAPIKey(keyID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read API key")
+		handleServerError(writer, err)
+		return
+	}
+
+	response := responses.BuildOkResponseWithData("api_key", storedKey)
+	response["key"] = key
+
+	err = responses.Send(statusCode, writer, response)
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// revokeAPIKey revokes the API key, so it can't be used anymore
+func (server *HTTPServer) revokeAPIKey(writer http.ResponseWriter, request *http.Request) {
+	keyID, err := getRouterParam(request, "key_id")
+	if err != nil {
+		handleServerError(writer, err)
+		return
+	}
+
+	err = server.Storage.RevokeAPIKey(keyID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to revoke API key")
+		handleServerError(writer, err)
+		return
+	}
+
+	log.Info().Str("key_id", keyID).Msg("API key revoked")
+
+	err = responses.SendOK(writer, responses.BuildOkResponse())
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// getAPIKeys returns all API keys without their secrets
+func (server *HTTPServer) getAPIKeys(writer http.ResponseWriter, _ *http.Request) {
+	keys, err := server.Storage.ReadAPIKeys()
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read API keys")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("api_keys", keys))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
@@ -0,0 +1,318 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// configAPIKeyAuth enables authentication, so API keys are checked
+var configAPIKeyAuth = server.Configuration{
+	Address:                      ":8080",
+	APIPrefix:                    "/api/test/",
+	Auth:                         true,
+	AuthType:                     "xrh",
+	MaximumFeedbackMessageLength: 255,
+}
+
+// apiKeyHeaders returns headers authenticating the request by the API key
+func apiKeyHeaders(key string) http.Header {
+	headers := http.Header{}
+	headers.Set(server.APIKeyHeader, key)
+	return headers
+}
+
+// mustSendAPIKeyRequest sends request to API key admin endpoint and returns
+// the key from the response
+func mustSendAPIKeyRequest(
+	t *testing.T, mockStorage storage.Storage, request *helpers.APIRequest, statusCode int,
+) (types.APIKey, string) {
+	var response struct {
+		Status string       `json:"status"`
+		APIKey types.APIKey `json:"api_key"`
+		Key    string       `json:"key"`
+	}
+
+	request.ExtraHeaders = helpers.DebugConfirmationHeaders()
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, request, &helpers.APIResponse{
+		StatusCode: statusCode,
+		BodyChecker: func(t testing.TB, _, got []byte) {
+			helpers.FailOnError(t, json.Unmarshal(got, &response))
+		},
+	})
+
+	assert.Equal(t, "ok", response.Status)
+	assert.NotEmpty(t, response.Key)
+
+	return response.APIKey, response.Key
+}
+
+// mustCreateAPIKey creates API key with the given scopes
+func mustCreateAPIKey(t *testing.T, mockStorage storage.Storage, scopes string) (types.APIKey, string) {
+	return mustSendAPIKeyRequest(t, mockStorage, &helpers.APIRequest{
+		Method:   http.MethodPost,
+		Endpoint: server.AdminAPIKeysEndpoint,
+		Body:     `{"name": "notification service", "scopes": ` + scopes + `}`,
+	}, http.StatusCreated)
+}
+
+// mustWriteReport3Rules stores report of the cluster of testdata.OrgID
+func mustWriteReport3Rules(t *testing.T, mockStorage storage.Storage) {
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed,
+		testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+}
+
+// assertClusterStatsRequest sends request authenticated by the API key and
+// checks the response
+func assertClusterStatsRequest(t *testing.T, mockStorage storage.Storage, key string, expected *helpers.APIResponse) {
+	helpers.AssertAPIRequest(t, mockStorage, &configAPIKeyAuth, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClusterStatsEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName},
+		ExtraHeaders: apiKeyHeaders(key),
+	}, expected)
+}
+
+var clusterStatsOKResponse = &helpers.APIResponse{
+	StatusCode: http.StatusOK,
+	Body:       `{"status": "ok", "hit_count_change": 0, "checks": []}`,
+}
+
+func TestHTTPServer_APIKeyAuthentication(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReport3Rules(t, mockStorage)
+
+	apiKey, key := mustCreateAPIKey(t, mockStorage, `["read"]`)
+	assert.Equal(t, "notification service", apiKey.Name)
+	assert.Equal(t, []string{server.APIKeyScopeRead}, apiKey.Scopes)
+	assert.Empty(t, apiKey.ExpiresAt)
+
+	// cluster of any organization can be read
+	assertClusterStatsRequest(t, mockStorage, key, clusterStatsOKResponse)
+
+	// but the key can't change data
+	helpers.AssertAPIRequest(t, mockStorage, &configAPIKeyAuth, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.LikeRuleEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID},
+		ExtraHeaders: apiKeyHeaders(key),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusForbidden,
+		Body:       `{"status": "API key doesn't have scope write"}`,
+	})
+
+	// secret of the key is not returned by the list of keys
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminAPIKeysEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got []byte) {
+			assert.NotContains(t, string(got), key)
+
+			var response struct {
+				APIKeys []types.APIKey `json:"api_keys"`
+			}
+			helpers.FailOnError(t, json.Unmarshal(got, &response))
+			assert.Equal(t, []types.APIKey{apiKey}, response.APIKeys)
+		},
+	})
+}
+
+func TestHTTPServer_APIKeyRotateAndRevoke(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReport3Rules(t, mockStorage)
+
+	apiKey, key := mustCreateAPIKey(t, mockStorage, `["read", "write"]`)
+
+	rotatedKey, newKey := mustSendAPIKeyRequest(t, mockStorage, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.AdminAPIKeyRotateEndpoint,
+		EndpointArgs: []interface{}{apiKey.KeyID},
+	}, http.StatusOK)
+	assert.Equal(t, apiKey.KeyID, rotatedKey.KeyID)
+	assert.NotEmpty(t, rotatedKey.RotatedAt)
+	assert.NotEqual(t, key, newKey)
+
+	// the previous secret can't be used anymore
+	assertClusterStatsRequest(t, mockStorage, key, &helpers.APIResponse{
+		StatusCode: http.StatusUnauthorized,
+		Body:       `{"status": "Invalid API key"}`,
+	})
+	assertClusterStatsRequest(t, mockStorage, newKey, clusterStatsOKResponse)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodDelete,
+		Endpoint:     server.AdminAPIKeyEndpoint,
+		EndpointArgs: []interface{}{apiKey.KeyID},
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"status": "ok"}`,
+	})
+
+	assertClusterStatsRequest(t, mockStorage, newKey, &helpers.APIResponse{
+		StatusCode: http.StatusUnauthorized,
+		Body:       `{"status": "API key is revoked"}`,
+	})
+
+	// revoked key can't be rotated
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.AdminAPIKeyRotateEndpoint,
+		EndpointArgs: []interface{}{apiKey.KeyID},
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+		Body:       `{"status": "Item with ID ` + apiKey.KeyID + ` was not found in the storage"}`,
+	})
+}
+
+func TestHTTPServer_APIKeyExpired(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReport3Rules(t, mockStorage)
+
+	hash := sha256.Sum256([]byte("secret"))
+	err := mockStorage.CreateAPIKey(
+		"expired", "old service", []string{server.APIKeyScopeRead}, hex.EncodeToString(hash[:]),
+		time.Now().Add(-time.Hour),
+	)
+	helpers.FailOnError(t, err)
+
+	assertClusterStatsRequest(t, mockStorage, "expired.secret", &helpers.APIResponse{
+		StatusCode: http.StatusUnauthorized,
+		Body:       `{"status": "API key is expired"}`,
+	})
+}
+
+func TestHTTPServer_APIKeyInvalid(t *testing.T) {
+	for _, key := range []string{"malformed", "unknown.secret"} {
+		helpers.AssertAPIRequest(t, nil, &configAPIKeyAuth, &helpers.APIRequest{
+			Method:       http.MethodGet,
+			Endpoint:     server.ClusterStatsEndpoint,
+			EndpointArgs: []interface{}{testdata.ClusterName},
+			ExtraHeaders: apiKeyHeaders(key),
+		}, &helpers.APIResponse{
+			StatusCode: http.StatusUnauthorized,
+			Body:       `{"status": "Invalid API key"}`,
+		})
+	}
+}
+
+func TestHTTPServer_CreateAPIKeyBadRequest(t *testing.T) {
+	for body, expected := range map[string]string{
+		`{"scopes": ["read"]}`:                    `{"status": "Error during validating param 'name' with value ''. Error: 'name of the API key expected'"}`,
+		`{"name": "service"}`:                     `{"status": "Error during validating param 'scopes' with value '[]'. Error: 'at least one scope expected'"}`,
+		`{"name": "service", "scopes": ["root"]}`: `{"status": "Error during validating param 'scopes' with value 'root'. Error: 'unknown scope'"}`,
+		`{"name": "service", "scopes": ["read"], "expires_at": "2000-01-01T00:00:00Z"}`: `{"status": "Error during validating param 'expires_at' with value '2000-01-01T00:00:00Z'. Error: 'time in the future in RFC 3339 format expected'"}`,
+	} {
+		helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+			Method:       http.MethodPost,
+			Endpoint:     server.AdminAPIKeysEndpoint,
+			ExtraHeaders: helpers.DebugConfirmationHeaders(),
+			Body:         body,
+		}, &helpers.APIResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       expected,
+		})
+	}
+}
+
+func TestHTTPServer_CreateAPIKeyWithExpiration(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	expiresAt := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+
+	apiKey, _ := mustSendAPIKeyRequest(t, mockStorage, &helpers.APIRequest{
+		Method:   http.MethodPost,
+		Endpoint: server.AdminAPIKeysEndpoint,
+		Body:     `{"name": "service", "scopes": ["read"], "expires_at": "` + expiresAt + `"}`,
+	}, http.StatusCreated)
+	assert.Equal(t, types.Timestamp(expiresAt), apiKey.ExpiresAt)
+}
+
+func TestHTTPServer_AdminEndpointsWithAdminAPIKey(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	_, adminKey, err := server.CreateAPIKey(mockStorage, "admin", []string{server.APIKeyScopeAdmin}, time.Time{})
+	helpers.FailOnError(t, err)
+	_, readKey, err := server.CreateAPIKey(mockStorage, "service", []string{server.APIKeyScopeRead}, time.Time{})
+	helpers.FailOnError(t, err)
+
+	// debug endpoints are disabled, so the key is the only way to access
+	// admin endpoints
+	helpers.AssertAPIRequest(t, mockStorage, &configAPIKeyAuth, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.AdminAPIKeysEndpoint,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusUnauthorized,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, &configAPIKeyAuth, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminAPIKeysEndpoint,
+		ExtraHeaders: apiKeyHeaders(readKey),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusForbidden,
+		Body:       `{"status": "API key doesn't have scope admin"}`,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, &configAPIKeyAuth, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminAPIKeysEndpoint,
+		ExtraHeaders: apiKeyHeaders(adminKey),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got []byte) {
+			var response struct {
+				APIKeys []types.APIKey `json:"api_keys"`
+			}
+			helpers.FailOnError(t, json.Unmarshal(got, &response))
+			assert.Len(t, response.APIKeys, 2)
+		},
+	})
+
+	// admin scope includes other scopes
+	mustWriteReport3Rules(t, mockStorage)
+	assertClusterStatsRequest(t, mockStorage, adminKey, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+	})
+}
@@ -58,6 +58,20 @@ func (server *HTTPServer) Authentication(next http.Handler, noAuthURLs []string)
 			return
 		}
 
+		// internal services authenticate by API keys instead of identity
+		// tokens
+		if apiKey := r.Header.Get(APIKeyHeader); apiKey != "" {
+			key, err := server.authenticateAPIKey(r, apiKey)
+			if err != nil {
+				log.Error().Err(err).Msg("Unable to authenticate by API key")
+				handleServerError(w, err)
+				return
+			}
+
+			next.ServeHTTP(w, withAPIKey(r, key))
+			return
+		}
+
 		token, err := server.getAuthTokenHeader(w, r)
 		if err != nil {
 			log.Error().Err(err).Msg(err.Error())
@@ -0,0 +1,213 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// cacheableEndpoints contains read endpoints that respond to HEAD requests
+// and return ETag and Cache-Control headers, the value is true for endpoints
+// returning data aggregated from all clusters of organization
+var cacheableEndpoints = map[string]bool{
+	ReportEndpoint:                          false,
+	RuleEndpoint:                            false,
+	ReportForListOfClustersEndpoint:         false,
+	ClustersForOrganizationEndpoint:         false,
+	OrganizationInfoEndpoint:                false,
+	OrganizationOverviewEndpoint:            true,
+	OrganizationReportEndpoint:              true,
+	OrganizationRuleResolutionRatesEndpoint: true,
+}
+
+// bufferedWriter buffers the response written by handler, so the response
+// can be checked (its ETag computed, for example) before the headers are sent
+type bufferedWriter struct {
+	header http.Header
+	body   bytes.Buffer
+	status int
+}
+
+// Header returns the header map of the buffered response
+func (writer *bufferedWriter) Header() http.Header {
+	return writer.header
+}
+
+// Write writes the data into the buffered response
+func (writer *bufferedWriter) Write(data []byte) (int, error) {
+	if writer.status == 0 {
+		writer.status = http.StatusOK
+	}
+
+	return writer.body.Write(data)
+}
+
+// WriteHeader sets the status code of the buffered response
+func (writer *bufferedWriter) WriteHeader(statusCode int) {
+	if writer.status == 0 {
+		writer.status = statusCode
+	}
+}
+
+// setLastModified sets Last-Modified header of the response to the time the
+// report was checked, nothing is set when the time is unknown
+func setLastModified(writer http.ResponseWriter, lastChecked types.Timestamp) {
+	checkedAt, err := time.Parse(time.RFC3339, string(lastChecked))
+	if err != nil {
+		return
+	}
+
+	writer.Header().Set("Last-Modified", checkedAt.UTC().Format(http.TimeFormat))
+}
+
+// computeETag returns strong entity tag of the response body
+func computeETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches returns true when value of If-None-Match header contains the
+// entity tag, weak comparison is used as defined by RFC 7232
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isNotModified returns true when the client already has the current version
+// of the response. If-Modified-Since is checked only when the request
+// doesn't contain If-None-Match.
+func isNotModified(request *http.Request, header http.Header) bool {
+	if ifNoneMatch := request.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return etagMatches(ifNoneMatch, header.Get("ETag"))
+	}
+
+	ifModifiedSince, err := http.ParseTime(request.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+
+	lastModified, err := http.ParseTime(header.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+
+	return !lastModified.After(ifModifiedSince)
+}
+
+// endpointCacheControl returns Cache-Control header of the endpoint the
+// request was routed to, found is false for endpoints that can't be cached
+func (server *HTTPServer) endpointCacheControl(request *http.Request) (cacheControl string, found bool) {
+	route := mux.CurrentRoute(request)
+	if route == nil {
+		return "", false
+	}
+
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return "", false
+	}
+
+	aggregate, found := cacheableEndpoints[strings.TrimPrefix(template, server.Config.APIPrefix)]
+	if !found {
+		return "", false
+	}
+
+	if aggregate && server.Config.AggregateCacheControl != "" {
+		return server.Config.AggregateCacheControl, true
+	}
+
+	return server.Config.CacheControl, true
+}
+
+// Caching is a middleware that adds ETag and configured Cache-Control headers
+// to successful responses of read endpoints (see cacheableEndpoints) and
+// responds with 304 Not Modified when the client already has the current
+// version of the response. Response to HEAD request contains the same
+// headers as the response to GET request, but no body.
+func (server *HTTPServer) Caching(nextHandler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodGet && request.Method != http.MethodHead {
+			nextHandler.ServeHTTP(writer, request)
+			return
+		}
+
+		cacheControl, found := server.endpointCacheControl(request)
+		if !found {
+			nextHandler.ServeHTTP(writer, request)
+			return
+		}
+
+		buffered := &bufferedWriter{header: make(http.Header)}
+		nextHandler.ServeHTTP(buffered, request)
+
+		if buffered.status == 0 {
+			buffered.status = http.StatusOK
+		}
+
+		for key, values := range buffered.header {
+			writer.Header()[key] = values
+		}
+
+		body := buffered.body.Bytes()
+		status := buffered.status
+
+		if status == http.StatusOK {
+			writer.Header().Set("ETag", computeETag(body))
+			if cacheControl != "" {
+				writer.Header().Set("Cache-Control", cacheControl)
+			}
+			// responses depend on the identity of the caller
+			if server.Config.Auth {
+				writer.Header().Set("Vary", "Authorization, X-Rh-Identity, "+http.CanonicalHeaderKey(APIKeyHeader))
+			}
+
+			if isNotModified(request, writer.Header()) {
+				writer.Header().Del("Content-Type")
+				writer.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+
+		writer.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		writer.WriteHeader(status)
+
+		if request.Method == http.MethodHead {
+			return
+		}
+
+		if _, err := writer.Write(body); err != nil {
+			log.Error().Err(err).Msg(responseDataError)
+		}
+	})
+}
@@ -0,0 +1,154 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	httputils "github.com/RedHatInsights/insights-operator-utils/http"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+)
+
+// cachingServerConfig returns server configuration with Cache-Control
+// headers configured
+func cachingServerConfig() server.Configuration {
+	config := helpers.DefaultServerConfig
+	config.CacheControl = "private, no-cache"
+	config.AggregateCacheControl = "public, max-age=60"
+	return config
+}
+
+// executeCachingRequest sends request with the method and headers to the
+// report endpoint
+func executeCachingRequest(
+	t *testing.T, mockStorage storage.Storage, method, endpoint string, header http.Header, args ...interface{},
+) *http.Response {
+	url := httputils.MakeURLToEndpoint(helpers.DefaultServerConfig.APIPrefix, endpoint, args...)
+	request, err := http.NewRequest(method, url, nil)
+	helpers.FailOnError(t, err)
+	for key, values := range header {
+		request.Header[key] = values
+	}
+
+	return helpers.ExecuteRequest(server.New(cachingServerConfig(), mockStorage), request).Result()
+}
+
+func writeCachingReport(t *testing.T, mockStorage storage.Storage) {
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed,
+		testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+}
+
+func TestCaching_GetReport(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	writeCachingReport(t, mockStorage)
+
+	response := executeCachingRequest(
+		t, mockStorage, http.MethodGet, server.ReportEndpoint, nil, testdata.OrgID, testdata.ClusterName, testdata.UserID,
+	)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.NotEmpty(t, response.Header.Get("ETag"))
+	assert.Equal(t, "private, no-cache", response.Header.Get("Cache-Control"))
+	assert.Equal(t, testdata.LastCheckedAt.UTC().Format(http.TimeFormat), response.Header.Get("Last-Modified"))
+}
+
+func TestCaching_HeadReport(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	writeCachingReport(t, mockStorage)
+
+	get := executeCachingRequest(
+		t, mockStorage, http.MethodGet, server.ReportEndpoint, nil, testdata.OrgID, testdata.ClusterName, testdata.UserID,
+	)
+	head := executeCachingRequest(
+		t, mockStorage, http.MethodHead, server.ReportEndpoint, nil, testdata.OrgID, testdata.ClusterName, testdata.UserID,
+	)
+
+	assert.Equal(t, http.StatusOK, head.StatusCode)
+	assert.Equal(t, get.Header.Get("ETag"), head.Header.Get("ETag"))
+	assert.Equal(t, get.Header.Get("Content-Length"), head.Header.Get("Content-Length"))
+	assert.Equal(t, get.Header.Get("Last-Modified"), head.Header.Get("Last-Modified"))
+
+	body, err := ioutil.ReadAll(head.Body)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, body)
+}
+
+func TestCaching_NotModified(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	writeCachingReport(t, mockStorage)
+
+	response := executeCachingRequest(
+		t, mockStorage, http.MethodGet, server.ReportEndpoint, nil, testdata.OrgID, testdata.ClusterName, testdata.UserID,
+	)
+	etag := response.Header.Get("ETag")
+
+	response = executeCachingRequest(
+		t, mockStorage, http.MethodGet, server.ReportEndpoint,
+		http.Header{"If-None-Match": []string{etag}}, testdata.OrgID, testdata.ClusterName, testdata.UserID,
+	)
+	assert.Equal(t, http.StatusNotModified, response.StatusCode)
+	assert.Equal(t, etag, response.Header.Get("ETag"))
+
+	response = executeCachingRequest(
+		t, mockStorage, http.MethodGet, server.ReportEndpoint,
+		http.Header{"If-None-Match": []string{`"other"`}}, testdata.OrgID, testdata.ClusterName, testdata.UserID,
+	)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	response = executeCachingRequest(
+		t, mockStorage, http.MethodGet, server.ReportEndpoint,
+		http.Header{"If-Modified-Since": []string{testdata.LastCheckedAt.UTC().Format(http.TimeFormat)}},
+		testdata.OrgID, testdata.ClusterName, testdata.UserID,
+	)
+	assert.Equal(t, http.StatusNotModified, response.StatusCode)
+}
+
+func TestCaching_AggregateCacheControl(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	writeCachingReport(t, mockStorage)
+
+	response := executeCachingRequest(t, mockStorage, http.MethodHead, server.OrganizationReportEndpoint, nil, testdata.OrgID)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "public, max-age=60", response.Header.Get("Cache-Control"))
+}
+
+func TestCaching_ErrorNotCached(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	response := executeCachingRequest(
+		t, mockStorage, http.MethodGet, server.ReportEndpoint, nil, testdata.OrgID, testdata.ClusterName, testdata.UserID,
+	)
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	assert.Empty(t, response.Header.Get("ETag"))
+	assert.Empty(t, response.Header.Get("Cache-Control"))
+}
@@ -0,0 +1,49 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// canonicalQueryParam is the name of query parameter requesting the
+// response in canonical JSON form (sorted keys, rules sorted by module and
+// error key), so responses can be compared by diff tools and cached reliably
+const canonicalQueryParam = "canonical"
+
+// readCanonicalQueryParam checks whether canonical JSON was requested
+// if it's not possible, it writes http error to the writer and returns false
+func readCanonicalQueryParam(writer http.ResponseWriter, request *http.Request) (canonical, successful bool) {
+	return readBoolQueryParam(writer, request, canonicalQueryParam)
+}
+
+// sendOKResponse sends the data with status OK, in canonical JSON form when
+// requested
+func sendOKResponse(writer http.ResponseWriter, canonical bool, data map[string]interface{}) error {
+	if !canonical {
+		return responses.SendOK(writer, data)
+	}
+
+	bytes, err := types.MarshalCanonical(data)
+	if err != nil {
+		return err
+	}
+
+	return responses.Send(http.StatusOK, writer, bytes)
+}
@@ -0,0 +1,150 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// assertCanonicalJSON checks that the body is already in canonical form
+func assertCanonicalJSON(t testing.TB, body []byte) {
+	canonical, err := types.CanonicalJSON(body)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, string(canonical), string(body))
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	for _, testCase := range []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"keys are sorted", `{"b": 1, "a": {"d": 2, "c": 3}}`, `{"a":{"c":3,"d":2},"b":1}`},
+		{"large numbers are kept", `{"org_id": 12345678901234567890}`, `{"org_id":12345678901234567890}`},
+		{
+			"rules in report are sorted",
+			`{"reports": [{"key": "B", "component": "y"}, {"key": "A", "component": "y"}, {"key": "A", "component": "x"}]}`,
+			`{"reports":[{"component":"x","key":"A"},{"component":"y","key":"A"},{"component":"y","key":"B"}]}`,
+		},
+		{
+			"rules in organization report are sorted",
+			`[{"rule_id": "z", "error_key": "A"}, {"rule_id": "a", "error_key": "B"}]`,
+			`[{"error_key":"B","rule_id":"a"},{"error_key":"A","rule_id":"z"}]`,
+		},
+		{"other lists are kept", `{"clusters": ["b", "a"]}`, `{"clusters":["b","a"]}`},
+		{
+			"mixed lists are kept",
+			`[{"component": "z", "key": "A"}, {"component": "a"}]`,
+			`[{"component":"z","key":"A"},{"component":"a"}]`,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			canonical, err := types.CanonicalJSON([]byte(testCase.input))
+			helpers.FailOnError(t, err)
+			assert.Equal(t, testCase.expected, string(canonical))
+		})
+	}
+}
+
+func TestCanonicalJSON_InvalidJSON(t *testing.T) {
+	_, err := types.CanonicalJSON([]byte("{"))
+	assert.Error(t, err)
+}
+
+func TestHttpServer_readReportForCluster_Canonical(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.Report3Rules,
+		testdata.Report3RulesParsed,
+		testdata.LastCheckedAt,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportEndpoint + "?canonical=true",
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got []byte) {
+			assertCanonicalJSON(t, got)
+
+			var response struct {
+				Report struct {
+					Reports []types.RuleOnReport `json:"reports"`
+				} `json:"report"`
+			}
+			helpers.FailOnError(t, json.Unmarshal(got, &response))
+
+			gotRules := []types.RuleID{}
+			for _, rule := range response.Report.Reports {
+				gotRules = append(gotRules, rule.Module)
+			}
+			assert.Equal(t, []types.RuleID{testdata.Rule1ID, testdata.Rule2ID, testdata.Rule3ID}, gotRules)
+		},
+	})
+}
+
+func TestHttpServer_readReportForCluster_BadCanonicalParam(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportEndpoint + "?canonical=maybe",
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body:       `{"status": "Error during parsing param 'canonical' with value 'maybe'. Error: 'boolean value expected'"}`,
+	})
+}
+
+func TestReadReportsForClusters_Canonical(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.Report3Rules,
+		testdata.Report3RulesParsed,
+		testdata.LastCheckedAt,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportForListOfClustersEndpoint + "?canonical=true",
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got []byte) {
+			assertCanonicalJSON(t, got)
+			assert.Contains(t, string(got), string(testdata.ClusterName))
+		},
+	})
+}
@@ -0,0 +1,111 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/chaos"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// injectedFaultMessage is returned in body of response when fault is injected
+// into the handler
+const injectedFaultMessage = "Fault injected by chaos mode"
+
+// faultsInjectedInto returns true when faults can be injected into the
+// endpoint the request was routed to. Faults are never injected into endpoints
+// controlling the chaos mode and into metrics, so it's always possible to
+// turn the chaos mode off and to observe it.
+func (server *HTTPServer) faultsInjectedInto(request *http.Request) bool {
+	switch server.routeEndpoint(request) {
+	case AdminChaosEndpoint, MetricsEndpoint:
+		return false
+	}
+
+	return true
+}
+
+// InjectFaults is a middleware that delays requests and responds with 503
+// Service Unavailable according to settings of the chaos mode
+func (server *HTTPServer) InjectFaults(nextHandler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if !server.faultsInjectedInto(request) {
+			nextHandler.ServeHTTP(writer, request)
+			return
+		}
+
+		err := server.FaultInjector.Inject(request.Context())
+		if err == chaos.ErrInjectedFault {
+			log.Warn().Str("url", request.URL.String()).Msg(injectedFaultMessage)
+
+			err = responses.Send(
+				http.StatusServiceUnavailable, writer, responses.BuildResponse(injectedFaultMessage),
+			)
+			if err != nil {
+				log.Error().Err(err).Msg(responseDataError)
+			}
+			return
+		}
+
+		// the request was cancelled while it was delayed, the handler will
+		// deal with the cancelled context
+		nextHandler.ServeHTTP(writer, request)
+	})
+}
+
+// getChaosSettings returns current settings of the chaos mode
+func (server *HTTPServer) getChaosSettings(writer http.ResponseWriter, _ *http.Request) {
+	err := responses.SendOK(writer, responses.BuildOkResponseWithData("chaos", server.FaultInjector.Settings()))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// updateChaosSettings replaces settings of the chaos mode by the settings
+// from the request body
+func (server *HTTPServer) updateChaosSettings(writer http.ResponseWriter, request *http.Request) {
+	var settings chaos.Settings
+
+	err := json.NewDecoder(request.Body).Decode(&settings)
+	if err != nil {
+		handleServerError(writer, &types.ValidationError{
+			ParamName:  "body",
+			ParamValue: "",
+			ErrString:  "chaos settings in JSON format expected",
+		})
+		return
+	}
+
+	err = server.FaultInjector.UpdateSettings(settings)
+	if err != nil {
+		handleServerError(writer, &types.ValidationError{
+			ParamName:  "body",
+			ParamValue: "",
+			ErrString:  err.Error(),
+		})
+		return
+	}
+
+	log.Warn().Interface("settings", settings).Msg("Chaos mode settings changed")
+
+	server.getChaosSettings(writer, request)
+}
@@ -0,0 +1,193 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	iou_helpers "github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/chaos"
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+)
+
+// newChaosServer returns server with chaos mode enabled and headers with API
+// key with admin scope, so the chaos settings can be changed
+func newChaosServer(t *testing.T, settings chaos.Settings) (*server.HTTPServer, http.Header, func()) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+
+	injector := chaos.NewInjector()
+	injector.Configure(chaos.Configuration{Enabled: true})
+	helpers.FailOnError(t, injector.UpdateSettings(settings))
+
+	_, adminKey, err := server.CreateAPIKey(mockStorage, "admin", []string{server.APIKeyScopeAdmin}, time.Time{})
+	helpers.FailOnError(t, err)
+
+	// debug endpoints are disabled, so the key is the only way to access
+	// admin endpoints
+	config := helpers.DefaultServerConfig
+	config.DebugEndpointsEnabled = false
+
+	testServer := server.New(config, mockStorage)
+	testServer.FaultInjector = injector
+
+	return testServer, apiKeyHeaders(adminKey), closer
+}
+
+// TestChaosFaultInjected checks that the request is responded with 503 when
+// the fault is injected into the handler
+func TestChaosFaultInjected(t *testing.T) {
+	testServer, _, closer := newChaosServer(t, chaos.Settings{Active: true, ErrorPercentage: 100})
+	defer closer()
+
+	iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.MainEndpoint,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusServiceUnavailable,
+		Body:       `{"status":"Fault injected by chaos mode"}`,
+	})
+}
+
+// TestChaosInactive checks that no fault is injected when chaos mode is
+// paused
+func TestChaosInactive(t *testing.T) {
+	testServer, _, closer := newChaosServer(t, chaos.Settings{Active: false, ErrorPercentage: 100})
+	defer closer()
+
+	iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.MainEndpoint,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"status":"ok"}`,
+	})
+}
+
+// TestChaosGetSettings checks that faults are not injected into the endpoint
+// returning chaos settings
+func TestChaosGetSettings(t *testing.T) {
+	testServer, adminHeaders, closer := newChaosServer(t, chaos.Settings{Active: true, MaxLatencyMs: 0, ErrorPercentage: 100})
+	defer closer()
+
+	iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminChaosEndpoint,
+		ExtraHeaders: adminHeaders,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"status":"ok","chaos":{"active":true,"max_latency_ms":0,"error_percentage":100}}`,
+	})
+}
+
+// TestChaosUpdateSettings checks that chaos mode can be paused by the admin
+// endpoint
+func TestChaosUpdateSettings(t *testing.T) {
+	testServer, adminHeaders, closer := newChaosServer(t, chaos.Settings{Active: true, ErrorPercentage: 100})
+	defer closer()
+
+	iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.AdminChaosEndpoint,
+		Body:         `{"active":false,"max_latency_ms":100,"error_percentage":50}`,
+		ExtraHeaders: adminHeaders,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"status":"ok","chaos":{"active":false,"max_latency_ms":100,"error_percentage":50}}`,
+	})
+
+	assert.Equal(t, chaos.Settings{
+		Active:          false,
+		MaxLatencyMs:    100,
+		ErrorPercentage: 50,
+	}, testServer.FaultInjector.Settings())
+
+	iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.MainEndpoint,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"status":"ok"}`,
+	})
+}
+
+// TestChaosUpdateSettingsInvalid checks that invalid settings are rejected
+func TestChaosUpdateSettingsInvalid(t *testing.T) {
+	testServer, adminHeaders, closer := newChaosServer(t, chaos.Settings{Active: true})
+	defer closer()
+
+	for _, body := range []string{
+		`{"active":true,"error_percentage":101}`,
+		`{"active":true,"max_latency_ms":-1}`,
+		`not JSON`,
+	} {
+		iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, &helpers.APIRequest{
+			Method:       http.MethodPut,
+			Endpoint:     server.AdminChaosEndpoint,
+			Body:         body,
+			ExtraHeaders: adminHeaders,
+		}, &helpers.APIResponse{
+			StatusCode: http.StatusBadRequest,
+		})
+	}
+
+	assert.Equal(t, chaos.Settings{Active: true}, testServer.FaultInjector.Settings())
+}
+
+// TestChaosEndpointNotAvailable checks that chaos mode can't be controlled
+// when it's not enabled
+func TestChaosEndpointNotAvailable(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.AdminChaosEndpoint,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+	})
+}
+
+// TestChaosSettingsRequireAdminKey checks that chaos mode can be controlled
+// only by API key with admin scope
+func TestChaosSettingsRequireAdminKey(t *testing.T) {
+	testServer, _, closer := newChaosServer(t, chaos.Settings{Active: true})
+	defer closer()
+
+	_, writeKey, err := server.CreateAPIKey(testServer.Storage, "service", []string{server.APIKeyScopeWrite}, time.Time{})
+	helpers.FailOnError(t, err)
+
+	for _, method := range []string{http.MethodGet, http.MethodPut} {
+		iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, &helpers.APIRequest{
+			Method:       method,
+			Endpoint:     server.AdminChaosEndpoint,
+			Body:         `{"active":false}`,
+			ExtraHeaders: apiKeyHeaders(writeKey),
+		}, &helpers.APIResponse{
+			StatusCode: http.StatusForbidden,
+		})
+
+		iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, &helpers.APIRequest{
+			Method:   method,
+			Endpoint: server.AdminChaosEndpoint,
+			Body:     `{"active":false}`,
+		}, &helpers.APIResponse{
+			StatusCode: http.StatusUnauthorized,
+		})
+	}
+
+	assert.Equal(t, chaos.Settings{Active: true}, testServer.FaultInjector.Settings())
+}
@@ -0,0 +1,123 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const (
+	// clusterClassQueryParam is the name of query parameter used to filter
+	// clusters by their class (managed or self-managed)
+	clusterClassQueryParam = "cluster_class"
+	// clusterClassesResponse is the name of response field with classes of
+	// the clusters
+	clusterClassesResponse = "cluster_classes"
+)
+
+// clusterClassParam defines optional query parameter with the class of
+// clusters
+func clusterClassParam(class *types.ClusterClass) param {
+	return param{
+		name:   clusterClassQueryParam,
+		source: queryParam,
+		parse: func(rawValue string) error {
+			value := types.ClusterClass(rawValue)
+			if !value.IsValid() {
+				classes := make([]string, len(types.ClusterClasses))
+				for i, known := range types.ClusterClasses {
+					classes[i] = string(known)
+				}
+
+				return errors.New("one of " + strings.Join(classes, ", ") + " expected")
+			}
+
+			*class = value
+			return nil
+		},
+	}
+}
+
+// readClusterClassQueryParam reads the class of clusters the response should
+// be filtered by, empty class is returned when the parameter is missing
+func readClusterClassQueryParam(
+	writer http.ResponseWriter, request *http.Request,
+) (class types.ClusterClass, successful bool) {
+	successful = readParams(writer, request, clusterClassParam(&class))
+
+	return class, successful
+}
+
+// filterClustersByClass returns only the clusters of the given class.
+// Clusters without known class (without any report) are kept, so they are
+// handled by the caller the same way as without the filter.
+func filterClustersByClass(
+	clusters []types.ClusterName,
+	classes map[types.ClusterName]types.ClusterClass,
+	class types.ClusterClass,
+) []types.ClusterName {
+	filtered := make([]types.ClusterName, 0, len(clusters))
+
+	for _, cluster := range clusters {
+		if clusterClass, found := classes[cluster]; found && clusterClass != class {
+			continue
+		}
+		filtered = append(filtered, cluster)
+	}
+
+	return filtered
+}
+
+// filterOrgReportByClass returns the report of the organization containing
+// only clusters of the given class, rules not hit by any of them are removed
+func filterOrgReportByClass(
+	report []types.OrgReportRule,
+	classes map[types.ClusterName]types.ClusterClass,
+	class types.ClusterClass,
+) []types.OrgReportRule {
+	filtered := make([]types.OrgReportRule, 0, len(report))
+
+	for _, rule := range report {
+		rule.Clusters = filterClustersByClass(rule.Clusters, classes, class)
+		rule.DisabledClusters = filterClustersByClass(rule.DisabledClusters, classes, class)
+
+		if len(rule.Clusters) == 0 && len(rule.DisabledClusters) == 0 {
+			continue
+		}
+		filtered = append(filtered, rule)
+	}
+
+	return filtered
+}
+
+// clusterClassesOf returns classes of the listed clusters, clusters without
+// known class are omitted
+func clusterClassesOf(
+	clusters []types.ClusterName, classes map[types.ClusterName]types.ClusterClass,
+) map[types.ClusterName]types.ClusterClass {
+	clusterClasses := make(map[types.ClusterName]types.ClusterClass, len(clusters))
+
+	for _, cluster := range clusters {
+		if class, found := classes[cluster]; found {
+			clusterClasses[cluster] = class
+		}
+	}
+
+	return clusterClasses
+}
@@ -0,0 +1,178 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mustWriteClustersOfBothClasses writes reports of managed and self-managed
+// cluster, both clusters hit the same rule
+func mustWriteClustersOfBothClasses(t *testing.T, mockStorage storage.Storage) (managed, selfManaged types.ClusterName) {
+	managed, selfManaged = testdata.ClusterName, testdata.GetRandomClusterID()
+	rule1 := types.ReportItem{Module: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1, TemplateData: []byte("{}")}
+
+	for _, cluster := range []types.ClusterName{managed, selfManaged} {
+		err := mockStorage.WriteReportForCluster(
+			testdata.OrgID, cluster, testdata.ClusterReportEmpty, []types.ReportItem{rule1}, testdata.LastCheckedAt, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	helpers.FailOnError(t, mockStorage.WriteClusterClass(testdata.OrgID, managed, types.ClusterClassManaged))
+
+	return managed, selfManaged
+}
+
+func TestListOfClustersForOrganization_ClusterClass(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	managed, selfManaged := mustWriteClustersOfBothClasses(t, mockStorage)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClustersForOrganizationEndpoint + "?cluster_class=managed",
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"clusters": ["` + string(managed) + `"],
+			"cluster_classes": {"` + string(managed) + `": "managed"},
+			"status": "ok"
+		}`,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClustersForOrganizationEndpoint + "?cluster_class=self-managed",
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"clusters": ["` + string(selfManaged) + `"],
+			"cluster_classes": {"` + string(selfManaged) + `": "self-managed"},
+			"status": "ok"
+		}`,
+	})
+}
+
+func TestListOfClustersForOrganization_ClusterClassPaging(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	_, selfManaged := mustWriteClustersOfBothClasses(t, mockStorage)
+
+	// clusters are paged after they are filtered by the class
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClustersForOrganizationEndpoint + "?cluster_class=self-managed&limit=1",
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"clusters": ["` + string(selfManaged) + `"],
+			"cluster_classes": {"` + string(selfManaged) + `": "self-managed"},
+			"paging": {"offset": 0, "limit": 1, "total": 1},
+			"status": "ok"
+		}`,
+	})
+
+	// the offset is applied to the filtered clusters too
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClustersForOrganizationEndpoint + "?cluster_class=self-managed&offset=1",
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"clusters": [],
+			"paging": {"offset": 1, "total": 1},
+			"status": "ok"
+		}`,
+	})
+}
+
+func TestListOfClustersForOrganization_UnknownClusterClass(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClustersForOrganizationEndpoint + "?cluster_class=hosted",
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body: `{
+			"status": "Error during parsing param 'cluster_class' with value 'hosted'. Error: 'one of managed, self-managed expected'"
+		}`,
+	})
+}
+
+func TestReportForListOfClusters_ClusterClass(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	managed, selfManaged := mustWriteClustersOfBothClasses(t, mockStorage)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.ReportForListOfClustersPayloadEndpoint + "?cluster_class=managed",
+		EndpointArgs: []interface{}{testdata.OrgID},
+		Body:         `{"clusters": ["` + string(managed) + `", "` + string(selfManaged) + `"]}`,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got []byte) {
+			var reports types.ClusterReports
+			helpers.FailOnError(t, json.Unmarshal(got, &reports))
+
+			// clusters of other class are neither reported nor listed as errors
+			assert.Equal(t, []types.ClusterName{managed}, reports.ClusterList)
+			assert.Empty(t, reports.Errors)
+			assert.Contains(t, reports.Reports, managed)
+		},
+	})
+}
+
+func TestOrganizationReport_ClusterClass(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	_, selfManaged := mustWriteClustersOfBothClasses(t, mockStorage)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.OrganizationReportEndpoint + "?cluster_class=self-managed",
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: helpers.ToJSONString(map[string]interface{}{
+			"report": []types.OrgReportRule{{
+				RuleID:           testdata.Rule1ID,
+				ErrorKey:         testdata.ErrorKey1,
+				Clusters:         []types.ClusterName{selfManaged},
+				DisabledClusters: []types.ClusterName{},
+			}},
+			"status": "ok",
+		}),
+	})
+}
@@ -0,0 +1,74 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// clusterStatsLimitQueryParam is the number of the last checks returned
+	// in the cluster statistics
+	clusterStatsLimitQueryParam = "limit"
+	// defaultClusterStatsLimit is used when the limit is not specified
+	defaultClusterStatsLimit = 10
+)
+
+// getClusterStats returns statistics of the last checks of the cluster: the
+// number of rules hit by every check and the number of rules added and
+// removed by it. The change of the number of rule hits between the oldest
+// and the newest check shows whether the cluster is getting healthier.
+func (server *HTTPServer) getClusterStats(writer http.ResponseWriter, request *http.Request) {
+	clusterID, successful := readClusterName(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	limit := defaultClusterStatsLimit
+	if !readParams(writer, request, positiveIntQueryParam(clusterStatsLimitQueryParam, &limit, nil)) {
+		// everything has been handled already
+		return
+	}
+
+	successful = server.checkUserClusterPermissions(writer, request, clusterID)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	checks, err := server.Storage.ReadReportChecks(clusterID, limit)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read statistics of cluster checks")
+		handleServerError(writer, err)
+		return
+	}
+
+	hitCountChange := 0
+	if len(checks) > 0 {
+		hitCountChange = checks[len(checks)-1].HitCount - checks[0].HitCount
+	}
+
+	response := responses.BuildOkResponseWithData("checks", checks)
+	response["hit_count_change"] = hitCountChange
+
+	err = responses.SendOK(writer, response)
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
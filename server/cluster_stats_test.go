@@ -0,0 +1,169 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mustWriteClusterChecks writes three reports of the cluster, one hour apart:
+// with two rules, with three rules and without rules
+func mustWriteClusterChecks(t *testing.T, mockStorage storage.Storage) []string {
+	var checkedAt []string
+
+	for i, report := range []struct {
+		report types.ClusterReport
+		rules  []types.ReportItem
+	}{
+		{testdata.Report2Rules, testdata.Report2RulesParsed},
+		{testdata.Report3Rules, testdata.Report3RulesParsed},
+		{testdata.ClusterReportEmpty, testdata.ReportEmptyRulesParsed},
+	} {
+		lastChecked := testdata.LastCheckedAt.Add(time.Duration(i-2) * time.Hour)
+
+		err := mockStorage.WriteReportForCluster(
+			testdata.OrgID, testdata.ClusterName, report.report, report.rules, lastChecked, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+
+		checkedAt = append(checkedAt, lastChecked.UTC().Format(time.RFC3339))
+	}
+
+	return checkedAt
+}
+
+func TestHTTPServer_GetClusterStats(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mockStorage.(*storage.DBStorage).SetCheckHistorySize(10)
+	checkedAt := mustWriteClusterChecks(t, mockStorage)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClusterStatsEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{"status": "ok", "hit_count_change": -2, "checks": [
+			{"checked_at": "` + checkedAt[0] + `", "hit_count": 2, "added": 2, "removed": 0},
+			{"checked_at": "` + checkedAt[1] + `", "hit_count": 3, "added": 1, "removed": 0},
+			{"checked_at": "` + checkedAt[2] + `", "hit_count": 0, "added": 0, "removed": 3}
+		]}`,
+	})
+
+	// only the last checks are returned
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClusterStatsEndpoint + "?limit=2",
+		EndpointArgs: []interface{}{testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{"status": "ok", "hit_count_change": -3, "checks": [
+			{"checked_at": "` + checkedAt[1] + `", "hit_count": 3, "added": 1, "removed": 0},
+			{"checked_at": "` + checkedAt[2] + `", "hit_count": 0, "added": 0, "removed": 3}
+		]}`,
+	})
+}
+
+func TestHTTPServer_GetClusterStats_HistorySize(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	// statistics of older checks are deleted
+	mockStorage.(*storage.DBStorage).SetCheckHistorySize(1)
+	checkedAt := mustWriteClusterChecks(t, mockStorage)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClusterStatsEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{"status": "ok", "hit_count_change": 0, "checks": [
+			{"checked_at": "` + checkedAt[2] + `", "hit_count": 0, "added": 0, "removed": 3}
+		]}`,
+	})
+}
+
+func TestHTTPServer_GetClusterStats_Disabled(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteClusterChecks(t, mockStorage)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClusterStatsEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"status": "ok", "hit_count_change": 0, "checks": []}`,
+	})
+}
+
+func TestHTTPServer_GetClusterStats_ClusterNotFound(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClusterStatsEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+		Body:       `{"status": "Item with ID ` + string(testdata.ClusterName) + ` was not found in the storage"}`,
+	})
+}
+
+func TestHTTPServer_GetClusterStats_InvalidLimit(t *testing.T) {
+	for _, limit := range []string{"0", "-1", "many"} {
+		helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+			Method:       http.MethodGet,
+			Endpoint:     server.ClusterStatsEndpoint + "?limit=" + limit,
+			EndpointArgs: []interface{}{testdata.ClusterName},
+		}, &helpers.APIResponse{
+			StatusCode: http.StatusBadRequest,
+		})
+	}
+}
+
+func TestHTTPServer_GetClusterStats_DBError(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorageWithFaults(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	mockStorage.InjectFault("ReadReportChecks", helpers.Fault{Err: errors.New("database is unavailable")})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClusterStatsEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status": "Internal Server Error"}`,
+	})
+}
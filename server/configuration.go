@@ -16,6 +16,8 @@ limitations under the License.
 
 package server
 
+import "time"
+
 // Configuration represents configuration of REST API HTTP server
 type Configuration struct {
 	Address                      string `mapstructure:"address" toml:"address"`
@@ -27,4 +29,49 @@ type Configuration struct {
 	MaximumFeedbackMessageLength int    `mapstructure:"maximum_feedback_message_length" toml:"maximum_feedback_message_length"`
 	// OrgOverviewLimitHours is temporary until request param parsing, but lets make it atleast configurable
 	OrgOverviewLimitHours int64 `mapstructure:"org_overview_limit_hours" toml:"org_overview_limit_hours"`
+	// ReadTimeout, WriteTimeout and IdleTimeout are timeouts of the HTTP
+	// server connections, 0 means no timeout
+	ReadTimeout  time.Duration `mapstructure:"read_timeout" toml:"read_timeout"`
+	WriteTimeout time.Duration `mapstructure:"write_timeout" toml:"write_timeout"`
+	IdleTimeout  time.Duration `mapstructure:"idle_timeout" toml:"idle_timeout"`
+	// RequestTimeout is the deadline for processing of REST API requests,
+	// BulkRequestTimeout is the deadline for endpoints returning reports
+	// for multiple clusters and ExportTimeout is the deadline for streamed
+	// exports, 0 means no deadline
+	RequestTimeout     time.Duration `mapstructure:"request_timeout" toml:"request_timeout"`
+	BulkRequestTimeout time.Duration `mapstructure:"bulk_request_timeout" toml:"bulk_request_timeout"`
+	ExportTimeout      time.Duration `mapstructure:"export_timeout" toml:"export_timeout"`
+	// EndpointTimeouts overrides deadlines of endpoints selected by their
+	// path templates without API prefix
+	EndpointTimeouts map[string]time.Duration `mapstructure:"endpoint_timeouts" toml:"endpoint_timeouts"`
+	// JustificationRequiredRules contains rules that can be disabled only
+	// with justification message, either rule ID (for all its error keys)
+	// or rule ID and error key separated by "|"
+	JustificationRequiredRules []string `mapstructure:"justification_required_rules" toml:"justification_required_rules"`
+	// Tracing enables propagation of trace IDs from W3C traceparent header
+	// of the requests to exemplars of latency metrics
+	Tracing bool `mapstructure:"tracing" toml:"tracing"`
+	// ReportAnalysisStatus adds status of the analysis to the meta of the
+	// cluster report without rule hits and returns empty report with
+	// no_data status instead of 404 for the cluster without report
+	ReportAnalysisStatus bool `mapstructure:"report_analysis_status" toml:"report_analysis_status"`
+	// UnixSocket is the path of unix domain socket the server listens on
+	// instead of Address, SocketActivation uses the socket passed by
+	// systemd instead of both of them
+	UnixSocket       string `mapstructure:"unix_socket" toml:"unix_socket"`
+	SocketActivation bool   `mapstructure:"socket_activation" toml:"socket_activation"`
+	// CacheControl is the Cache-Control header of successful responses of
+	// read endpoints and AggregateCacheControl overrides it for endpoints
+	// returning data aggregated from all clusters of organization, no header
+	// is sent when they are empty
+	CacheControl          string `mapstructure:"cache_control" toml:"cache_control"`
+	AggregateCacheControl string `mapstructure:"aggregate_cache_control" toml:"aggregate_cache_control"`
+	// MaxReportResponseSize is the maximal size of response of cluster report
+	// endpoints in bytes, larger reports are replaced by 413 response
+	// pointing to the paginated variant, 0 means no limit
+	MaxReportResponseSize int `mapstructure:"max_report_response_size" toml:"max_report_response_size"`
+	// DebugEndpointsEnabled enables debug endpoints in debug mode. It can't
+	// be set in config file, only by env variable (see conf package), so
+	// misconfigured debug mode doesn't expose the debug endpoints.
+	DebugEndpointsEnabled bool `mapstructure:"-" toml:"-"`
 }
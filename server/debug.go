@@ -0,0 +1,149 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const (
+	// DebugConfirmationHeader has to be sent with value "true" in every
+	// request to debug endpoints
+	DebugConfirmationHeader = "X-Debug-Confirm"
+
+	// debugConfirmationValue is the only accepted value of the header
+	debugConfirmationValue = "true"
+
+	// debugConfirmationMissingMessage is returned in body of response when
+	// the confirmation header is missing
+	debugConfirmationMissingMessage = "Debug endpoints require header " +
+		DebugConfirmationHeader + ": " + debugConfirmationValue
+
+	// adminAPIKeyMissingMessage is returned in body of response when the
+	// request to admin endpoint is not authenticated by API key
+	// #nosec G101
+	adminAPIKeyMissingMessage = "Admin endpoints require API key with scope " + APIKeyScopeAdmin
+)
+
+// debugEndpointsEnabled returns true when debug endpoints should be
+// available. Debug mode is not enough, the endpoints have to be enabled
+// explicitly by the flag that can't be set in config file.
+func (server *HTTPServer) debugEndpointsEnabled() bool {
+	return server.Config.Debug && server.Config.DebugEndpointsEnabled
+}
+
+// auditWriter remembers status code of the response, so it can be logged
+type auditWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader remembers the status code and writes it into the response
+func (writer *auditWriter) WriteHeader(statusCode int) {
+	if writer.status == 0 {
+		writer.status = statusCode
+	}
+
+	writer.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write writes the data into the response, status 200 is written implicitly
+// if it wasn't written before
+func (writer *auditWriter) Write(data []byte) (int, error) {
+	if writer.status == 0 {
+		writer.status = http.StatusOK
+	}
+
+	return writer.ResponseWriter.Write(data)
+}
+
+// AuditDebugRequest is a middleware that writes every invocation of debug
+// endpoints, including rejected ones, into audit log together with identity
+// of the caller and status of the response
+func (server *HTTPServer) AuditDebugRequest(nextHandler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		auditWriter := &auditWriter{ResponseWriter: writer}
+
+		nextHandler.ServeHTTP(auditWriter, request)
+
+		event := log.Warn().
+			Str("type", "audit").
+			Str("method", request.Method).
+			Str("url", request.URL.String()).
+			Str("remote_address", request.RemoteAddr).
+			Int("status", auditWriter.status)
+
+		if identity, ok := request.Context().Value(types.ContextKeyUser).(Identity); ok {
+			event = event.
+				Str("account_number", string(identity.AccountNumber)).
+				Uint64("org_id", uint64(identity.Internal.OrgID))
+		}
+		if keyID, ok := request.Context().Value(apiKeyContextKey{}).(string); ok {
+			event = event.Str("api_key_id", keyID)
+		}
+
+		event.Msg("Debug endpoint invoked")
+	})
+}
+
+// RequireDebugConfirmation is a middleware that rejects requests to debug
+// endpoints without confirmation header, so the endpoints can't be called
+// accidentally (by crawler or by a link in browser, for example)
+func (server *HTTPServer) RequireDebugConfirmation(nextHandler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Header.Get(DebugConfirmationHeader) != debugConfirmationValue {
+			err := responses.SendForbidden(writer, debugConfirmationMissingMessage)
+			if err != nil {
+				log.Error().Err(err).Msg(responseDataError)
+			}
+			return
+		}
+
+		nextHandler.ServeHTTP(writer, request)
+	})
+}
+
+// RequireAdminAccess is a middleware that allows requests to admin endpoints
+// only when they are authenticated by API key with admin scope. When debug
+// endpoints are enabled, the request confirmed by DebugConfirmationHeader is
+// allowed too.
+func (server *HTTPServer) RequireAdminAccess(nextHandler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if apiKey := request.Header.Get(APIKeyHeader); apiKey != "" {
+			key, err := server.authenticateAPIKeyWithScope(apiKey, APIKeyScopeAdmin)
+			if err != nil {
+				handleServerError(writer, err)
+				return
+			}
+
+			nextHandler.ServeHTTP(writer, withAPIKey(request, key))
+			return
+		}
+
+		if server.debugEndpointsEnabled() {
+			server.RequireDebugConfirmation(nextHandler).ServeHTTP(writer, request)
+			return
+		}
+
+		handleServerError(writer, &UnauthorizedError{ErrString: adminAPIKeyMissingMessage})
+	})
+}
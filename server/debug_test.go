@@ -0,0 +1,116 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+)
+
+// TestDebugEndpointWithoutConfirmation checks that debug endpoints reject
+// requests without the confirmation header
+func TestDebugEndpointWithoutConfirmation(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.OrganizationsEndpoint,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusForbidden,
+		Body:       `{"status": "Debug endpoints require header X-Debug-Confirm: true"}`,
+	})
+}
+
+// TestDebugEndpointWrongConfirmation checks that only the exact value of the
+// confirmation header is accepted
+func TestDebugEndpointWrongConfirmation(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.OrganizationsEndpoint,
+		ExtraHeaders: http.Header{server.DebugConfirmationHeader: []string{"yes"}},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusForbidden,
+	})
+}
+
+// TestDebugEndpointsNotEnabled checks that debug mode alone doesn't make
+// the debug endpoints available
+func TestDebugEndpointsNotEnabled(t *testing.T) {
+	config := helpers.DefaultServerConfig
+	config.DebugEndpointsEnabled = false
+
+	helpers.AssertAPIRequest(t, nil, &config, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.OrganizationsEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+	})
+}
+
+// TestDebugEndpointsOutsideDebugMode checks that the flag enabling debug
+// endpoints is ignored outside debug mode
+func TestDebugEndpointsOutsideDebugMode(t *testing.T) {
+	config := helpers.DefaultServerConfig
+	config.Debug = false
+
+	helpers.AssertAPIRequest(t, nil, &config, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.OrganizationsEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+	})
+}
+
+// TestDebugEndpointAuditLog checks that both accepted and rejected
+// invocations of debug endpoints are written into audit log
+func TestDebugEndpointAuditLog(t *testing.T) {
+	buf := new(bytes.Buffer)
+	defer func(logger zerolog.Logger) { log.Logger = logger }(log.Logger)
+	log.Logger = zerolog.New(buf)
+
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.OrganizationsEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+	})
+
+	assert.Contains(t, buf.String(), `"type":"audit"`)
+	assert.Contains(t, buf.String(), `"status":200`)
+	assert.Contains(t, buf.String(), "Debug endpoint invoked")
+
+	buf.Reset()
+
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodDelete,
+		Endpoint:     server.DeleteOrganizationsEndpoint,
+		EndpointArgs: []interface{}{1},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusForbidden,
+	})
+
+	assert.Contains(t, buf.String(), `"method":"DELETE"`)
+	assert.Contains(t, buf.String(), `"status":403`)
+	assert.Contains(t, buf.String(), "Debug endpoint invoked")
+}
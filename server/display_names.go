@@ -0,0 +1,103 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// displayNamesResponse is the name of response field with display names of
+// clusters
+const displayNamesResponse = "display_names"
+
+// readDisplayNames returns display names of the clusters of the organization,
+// nil is returned when no display name provider is configured. Display names
+// are only enrichment of responses, so errors of the provider are just logged
+// and no display names are returned.
+func (server *HTTPServer) readDisplayNames(
+	orgID types.OrgID, clusters []types.ClusterName,
+) map[types.ClusterName]string {
+	if server.DisplayNames == nil || len(clusters) == 0 {
+		return nil
+	}
+
+	displayNames, err := server.DisplayNames.DisplayNames(orgID, clusters)
+	if err != nil {
+		log.Error().Err(err).Int("orgID", int(orgID)).Msg("Unable to resolve display names of clusters")
+		return nil
+	}
+
+	return displayNames
+}
+
+// getClusterDisplayNames returns display names of clusters specified in the
+// request body, so UIs can resolve all clusters shown at once. Only clusters
+// of the organization known to the aggregator are resolved, other clusters
+// are missing in the response the same way as clusters without display name.
+func (server *HTTPServer) getClusterDisplayNames(writer http.ResponseWriter, request *http.Request) {
+	orgID, successful := readOrganizationID(writer, request, server.Config.Auth)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	clusters, successful := readClusterListFromBody(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	for _, clusterID := range clusters {
+		if err := validateClusterID(clusterID); err != nil {
+			sendWrongClusterIDResponse(writer, err)
+			return
+		}
+	}
+
+	clusterNames := constructClusterNames(clusters)
+	orgIDs, err := server.Storage.ReadOrgIDsOfClusters(clusterNames)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read organizations of clusters")
+		handleServerError(writer, err)
+		return
+	}
+
+	var orgClusters []types.ClusterName
+	for _, clusterName := range clusterNames {
+		if clusterOrgID, found := orgIDs[clusterName]; found && clusterOrgID == orgID {
+			orgClusters = append(orgClusters, clusterName)
+		}
+	}
+
+	displayNames := map[types.ClusterName]string{}
+	if server.DisplayNames != nil && len(orgClusters) > 0 {
+		displayNames, err = server.DisplayNames.DisplayNames(orgID, orgClusters)
+		if err != nil {
+			log.Error().Err(err).Int("orgID", int(orgID)).Msg("Unable to resolve display names of clusters")
+			handleServerError(writer, err)
+			return
+		}
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData(displayNamesResponse, displayNames))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	httputils "github.com/RedHatInsights/insights-operator-utils/http"
+	iou_helpers "github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mockDisplayNames resolves display names of clusters from the map
+type mockDisplayNames struct {
+	displayNames map[types.ClusterName]string
+	err          error
+}
+
+func (provider mockDisplayNames) DisplayNames(
+	_ types.OrgID, clusters []types.ClusterName,
+) (map[types.ClusterName]string, error) {
+	if provider.err != nil {
+		return nil, provider.err
+	}
+
+	displayNames := make(map[types.ClusterName]string)
+	for _, cluster := range clusters {
+		if displayName, found := provider.displayNames[cluster]; found {
+			displayNames[cluster] = displayName
+		}
+	}
+
+	return displayNames, nil
+}
+
+// newServerWithDisplayNames constructs server resolving display names by the
+// mock provider
+func newServerWithDisplayNames(mockStorage storage.Storage, err error) *server.HTTPServer {
+	testServer := server.New(helpers.DefaultServerConfig, mockStorage)
+	testServer.DisplayNames = mockDisplayNames{
+		displayNames: map[types.ClusterName]string{testdata.ClusterName: "production"},
+		err:          err,
+	}
+
+	return testServer
+}
+
+func TestHTTPServer_ListOfClustersWithDisplayNames(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	testServer := newServerWithDisplayNames(mockStorage, nil)
+
+	iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClustersForOrganizationEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"clusters": ["` + string(testdata.ClusterName) + `"],
+			"cluster_classes": {"` + string(testdata.ClusterName) + `": "self-managed"},
+			"display_names": {"` + string(testdata.ClusterName) + `": "production"},
+			"status": "ok"
+		}`,
+	})
+}
+
+func TestHTTPServer_ListOfClustersWithDisplayNamesError(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	// the list is returned without display names when the inventory fails
+	testServer := newServerWithDisplayNames(mockStorage, errors.New("inventory is unavailable"))
+
+	iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClustersForOrganizationEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"clusters": ["` + string(testdata.ClusterName) + `"],
+			"cluster_classes": {"` + string(testdata.ClusterName) + `": "self-managed"},
+			"status": "ok"
+		}`,
+	})
+}
+
+func TestHTTPServer_ReportWithDisplayName(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	url := httputils.MakeURLToEndpoint(
+		helpers.DefaultServerConfig.APIPrefix, server.ReportEndpoint, testdata.OrgID, testdata.ClusterName, testdata.UserID,
+	)
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	helpers.FailOnError(t, err)
+
+	recorder := helpers.ExecuteRequest(newServerWithDisplayNames(mockStorage, nil), request)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var response struct {
+		Report struct {
+			Meta struct {
+				DisplayName string `json:"display_name"`
+			} `json:"meta"`
+		} `json:"report"`
+	}
+	helpers.FailOnError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, "production", response.Report.Meta.DisplayName)
+}
+
+func TestHTTPServer_GetClusterDisplayNames(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	cluster2 := testdata.GetRandomClusterID()
+	cluster3 := testdata.GetRandomClusterID()
+
+	for _, cluster := range []struct {
+		orgID       types.OrgID
+		clusterName types.ClusterName
+	}{
+		{testdata.OrgID, testdata.ClusterName},
+		{testdata.OrgID, cluster2},
+		{testdata.Org2ID, cluster3},
+	} {
+		err := mockStorage.WriteReportForCluster(
+			cluster.orgID, cluster.clusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	testServer := server.New(helpers.DefaultServerConfig, mockStorage)
+	testServer.DisplayNames = mockDisplayNames{displayNames: map[types.ClusterName]string{
+		testdata.ClusterName: "production",
+		cluster3:             "another organization",
+	}}
+
+	// clusters of other organizations are not resolved
+	iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.ClusterDisplayNamesEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+		Body:         `{"clusters": ["` + string(testdata.ClusterName) + `", "` + string(cluster2) + `", "` + string(cluster3) + `"]}`,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"display_names": {"` + string(testdata.ClusterName) + `": "production"}, "status": "ok"}`,
+	})
+}
+
+func TestHTTPServer_GetClusterDisplayNamesNoProvider(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.ClusterDisplayNamesEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+		Body:         `{"clusters": ["` + string(testdata.ClusterName) + `"]}`,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"display_names": {}, "status": "ok"}`,
+	})
+}
+
+func TestHTTPServer_GetClusterDisplayNamesBadRequest(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.ClusterDisplayNamesEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+		Body:         `{"clusters": ["not-uuid"]}`,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+	})
+}
+
+func TestHTTPServer_GetClusterDisplayNamesProviderError(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	testServer := newServerWithDisplayNames(mockStorage, errors.New("inventory is unavailable"))
+
+	iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.ClusterDisplayNamesEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+		Body:         `{"clusters": ["` + string(testdata.ClusterName) + `"]}`,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status": "Internal Server Error"}`,
+	})
+}
@@ -21,7 +21,6 @@ import (
 	httputils "github.com/RedHatInsights/insights-operator-utils/http"
 
 	"github.com/gorilla/mux"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 const (
@@ -33,6 +32,9 @@ const (
 	DeleteClustersEndpoint = "clusters/{clusters}"
 	// OrganizationsEndpoint returns all organizations
 	OrganizationsEndpoint = "organizations"
+	// OrganizationsDetailedEndpoint returns all organizations with number of their clusters and the time of
+	// the most recent report. DEBUG only
+	OrganizationsDetailedEndpoint = "organizations/detailed"
 	// ReportEndpoint returns report for provided {organization}, {cluster}, and {user_id}
 	ReportEndpoint = "organizations/{org_id}/clusters/{cluster}/users/{user_id}/report"
 	// RuleEndpoint returns rule report for provided {organization} {cluster} and {rule_id}
@@ -53,53 +55,218 @@ const (
 	GetVoteOnRuleEndpoint = "clusters/{cluster}/rules/{rule_id}/error_key/{error_key}/users/{user_id}/get_vote"
 	// ClustersForOrganizationEndpoint returns all clusters for {organization}
 	ClustersForOrganizationEndpoint = "organizations/{organization}/clusters"
+	// ClusterDisplayNamesEndpoint returns display names of clusters of {organization} specified in request body
+	ClusterDisplayNamesEndpoint = "organizations/{organization}/clusters/display_names"
+	// UserFeedbackForClustersEndpoint returns feedback left by {user_id} on rules of clusters of {organization}
+	// specified in request body
+	UserFeedbackForClustersEndpoint = "organizations/{organization}/users/{user_id}/feedback"
+	// OrganizationInfoEndpoint returns when the first and the last report from {organization} was received
+	OrganizationInfoEndpoint = "organizations/{organization}/info"
+	// OrganizationOverviewEndpoint returns activity of {organization} together with numbers of its disabled rules
+	OrganizationOverviewEndpoint = "organizations/{organization}/overview"
+	// OrganizationReportEndpoint returns report merged from reports of all clusters of {organization}
+	OrganizationReportEndpoint = "organizations/{organization}/report"
+	// LikeRuleForOrgEndpoint likes rule with {rule_id} for {organization} using current user(from auth header)
+	LikeRuleForOrgEndpoint = "organizations/{organization}/rules/{rule_id}/error_key/{error_key}/users/{user_id}/like"
+	// DislikeRuleForOrgEndpoint dislikes rule with {rule_id} for {organization} using current user(from auth header)
+	DislikeRuleForOrgEndpoint = "organizations/{organization}/rules/{rule_id}/error_key/{error_key}/users/{user_id}/dislike"
+	// ResetVoteOnRuleForOrgEndpoint resets vote on rule with {rule_id} for {organization} using current
+	// user(from auth header)
+	ResetVoteOnRuleForOrgEndpoint = "organizations/{organization}/rules/{rule_id}/error_key/{error_key}/users/{user_id}/reset_vote"
+	// UserFeedbackOnRuleForOrgEndpoint stores and returns feedback left by {user_id} on rule with {rule_id}
+	// for {organization}
+	UserFeedbackOnRuleForOrgEndpoint = "organizations/{organization}/rules/{rule_id}/error_key/{error_key}/users/{user_id}/feedback"
+	// FeedbackOnRuleForOrgEndpoint returns feedback left by all users on rule with {rule_id} for {organization}
+	FeedbackOnRuleForOrgEndpoint = "organizations/{organization}/rules/{rule_id}/error_key/{error_key}/feedback"
 	// DisableRuleForClusterEndpoint disables a rule for specified cluster
 	DisableRuleForClusterEndpoint = "clusters/{cluster}/rules/{rule_id}/error_key/{error_key}/disable"
 	// EnableRuleForClusterEndpoint re-enables a rule for specified cluster
 	EnableRuleForClusterEndpoint = "clusters/{cluster}/rules/{rule_id}/error_key/{error_key}/enable"
 	// DisableRuleFeedbackEndpoint accepts a feedback from user when (s)he disables a rule
 	DisableRuleFeedbackEndpoint = "clusters/{cluster}/rules/{rule_id}/error_key/{error_key}/users/{user_id}/disable_feedback"
+	// RuleHitOccurrencesEndpoint returns the timeline of periods during which the rule was reported for {cluster}
+	RuleHitOccurrencesEndpoint = "clusters/{cluster}/rules/{rule_id}/error_key/{error_key}/occurrences"
+	// RuleHitsExportEndpoint streams all clusters affected by the rule with selected fields of template data as CSV or NDJSON
+	RuleHitsExportEndpoint = "rules/{rule_id}/error_key/{error_key}/export"
+	// RuleResolutionRatesEndpoint returns how often rules hit by clusters of all organizations were resolved. DEBUG only
+	RuleResolutionRatesEndpoint = "rules/resolution_rates"
+	// OrganizationRuleResolutionRatesEndpoint returns how often rules hit by clusters of {organization} were resolved
+	OrganizationRuleResolutionRatesEndpoint = "organizations/{organization}/rules/resolution_rates"
+	// TopRulesEndpoint returns rules affecting the most clusters in the time window and changes against the previous window. ADMIN only
+	TopRulesEndpoint = "rules/top"
+	// AddClusterAnnotationEndpoint attaches a new annotation written by {user_id} to the {cluster} report
+	AddClusterAnnotationEndpoint = "clusters/{cluster}/users/{user_id}/annotations"
+	// ClusterAnnotationsEndpoint returns all annotations of the {cluster} report
+	ClusterAnnotationsEndpoint = "clusters/{cluster}/annotations"
+	// ClusterStatsEndpoint returns statistics of the last checks of the {cluster}
+	ClusterStatsEndpoint = "clusters/{cluster}/stats"
+	// ExternalResultsEndpoint returns results of checks of the {cluster} done by external sources
+	ExternalResultsEndpoint = "clusters/{cluster}/external-results"
+	// ExternalSourceResultsEndpoint returns results of checks of the {cluster} done by the external {source}
+	ExternalSourceResultsEndpoint = "clusters/{cluster}/external-results/{source}"
+	// DeleteClusterAnnotationEndpoint deletes annotation with {annotation_id} of the {cluster} report
+	DeleteClusterAnnotationEndpoint = "clusters/{cluster}/annotations/{annotation_id}"
+	// AdminCacheRebuildEndpoint rebuilds the cache of timestamps when the clusters were last checked. ADMIN only
+	AdminCacheRebuildEndpoint = "admin/cache/rebuild"
+	// AdminCacheStatsEndpoint returns statistics about the cache of timestamps when the clusters were last checked. ADMIN only
+	AdminCacheStatsEndpoint = "admin/cache/stats"
+	// AdminCacheDivergenceEndpoint compares sample of the cache of timestamps when the clusters were last checked
+	// with the database. DEBUG only
+	AdminCacheDivergenceEndpoint = "admin/cache/divergence"
+	// AdminStaleWritesEndpoint returns statistics of reports rejected because a more recent report was already stored. DEBUG only
+	AdminStaleWritesEndpoint = "admin/stale-writes"
+	// AdminClusterRuleHitsEndpoint returns raw rule hits of the cluster as they are stored in the database. DEBUG only
+	AdminClusterRuleHitsEndpoint = "admin/clusters/{cluster}/rule-hits"
+	// AdminClusterOrgChangesEndpoint returns clusters that started to report under another organization recently. DEBUG only
+	AdminClusterOrgChangesEndpoint = "admin/clusters/org-changes"
+	// AdminOrgUsageEndpoint returns monthly usage of the service by organizations. ADMIN only
+	AdminOrgUsageEndpoint = "admin/usage"
+	// AdminOffsetsEndpoint returns offsets of the latest processed messages and lag of the consumer. ADMIN only
+	AdminOffsetsEndpoint = "admin/offsets"
+	// AdminVotesImportEndpoint imports a batch of votes and feedback in one transaction. ADMIN only
+	AdminVotesImportEndpoint = "admin/votes/import"
+	// AdminOrgFreezeEndpoint freezes and unfreezes {organization}, so its messages are dropped and its data
+	// can't be changed. ADMIN only
+	AdminOrgFreezeEndpoint = "admin/organizations/{organization}/freeze"
+	// AdminFrozenOrgsEndpoint returns all frozen organizations. ADMIN only
+	AdminFrozenOrgsEndpoint = "admin/organizations/frozen"
+	// AdminJobsEndpoint returns the last runs of all jobs started on this instance. ADMIN only
+	AdminJobsEndpoint = "admin/jobs"
+	// AdminJobEndpoint starts the {job} asynchronously and returns its last run. ADMIN only
+	AdminJobEndpoint = "admin/jobs/{job}"
+	// AdminAPIKeysEndpoint returns all API keys and creates new ones. ADMIN only
+	AdminAPIKeysEndpoint = "admin/api-keys"
+	// AdminAPIKeyEndpoint revokes API key with {key_id}. ADMIN only
+	AdminAPIKeyEndpoint = "admin/api-keys/{key_id}"
+	// AdminAPIKeyRotateEndpoint generates new secret of API key with {key_id}. ADMIN only
+	AdminAPIKeyRotateEndpoint = "admin/api-keys/{key_id}/rotate"
+	// AdminSimulateIngestEndpoint runs the message through parsing, validation and report assembly without storing it. DEBUG only
+	AdminSimulateIngestEndpoint = "admin/simulate-ingest"
+	// AdminSchemaEndpoint returns tables, columns and indexes of the database and its migration version. DEBUG only
+	AdminSchemaEndpoint = "admin/schema"
+	// AdminMessageKeyEndpoint returns reports and consumer errors stored for the Kafka message {key}. DEBUG only
+	AdminMessageKeyEndpoint = "admin/message-keys/{key}"
+	// AdminChaosEndpoint returns and changes settings of the chaos mode. Available only when chaos mode is enabled
+	AdminChaosEndpoint = "admin/chaos"
+	// InfoEndpoint returns the effective configuration of the service. DEBUG only
+	InfoEndpoint = "info"
 	// MetricsEndpoint returns prometheus metrics
 	MetricsEndpoint = "metrics"
 )
 
+// addDebugEndpointsToRouter adds debug endpoints into separate router, so
+// every request to them can be audit logged and has to be confirmed by
+// DebugConfirmationHeader
 func (server *HTTPServer) addDebugEndpointsToRouter(router *mux.Router) {
 	apiPrefix := server.Config.APIPrefix
 
-	router.HandleFunc(apiPrefix+OrganizationsEndpoint, server.listOfOrganizations).Methods(http.MethodGet)
-	router.HandleFunc(apiPrefix+DeleteOrganizationsEndpoint, server.deleteOrganizations).Methods(http.MethodDelete)
-	router.HandleFunc(apiPrefix+DeleteClustersEndpoint, server.deleteClusters).Methods(http.MethodDelete)
-	router.HandleFunc(apiPrefix+GetVoteOnRuleEndpoint, server.getVoteOnRule).Methods(http.MethodGet)
+	debugRouter := router.NewRoute().Subrouter()
+	debugRouter.Use(server.AuditDebugRequest, server.RequireDebugConfirmation)
 
-	// endpoints for pprof - needed for profiling, ie. usually in debug mode
-	router.PathPrefix("/debug/pprof/").Handler(http.DefaultServeMux)
+	debugRouter.HandleFunc(apiPrefix+OrganizationsEndpoint, server.listOfOrganizations).Methods(http.MethodGet)
+	debugRouter.HandleFunc(apiPrefix+OrganizationsDetailedEndpoint, server.listOfOrganizationsDetailed).Methods(http.MethodGet)
+	debugRouter.HandleFunc(apiPrefix+DeleteOrganizationsEndpoint, server.deleteOrganizations).Methods(http.MethodDelete)
+	debugRouter.HandleFunc(apiPrefix+DeleteClustersEndpoint, server.deleteClusters).Methods(http.MethodDelete)
+	debugRouter.HandleFunc(apiPrefix+GetVoteOnRuleEndpoint, server.getVoteOnRule).Methods(http.MethodGet)
+	debugRouter.HandleFunc(apiPrefix+AdminCacheDivergenceEndpoint, server.getClustersLastCheckedDivergence).Methods(http.MethodGet)
+	debugRouter.HandleFunc(apiPrefix+AdminStaleWritesEndpoint, server.getStaleReportWrites).Methods(http.MethodGet)
+	debugRouter.HandleFunc(apiPrefix+AdminClusterRuleHitsEndpoint, server.getRawRuleHits).Methods(http.MethodGet)
+	debugRouter.HandleFunc(apiPrefix+AdminClusterOrgChangesEndpoint, server.getClusterOrgChanges).Methods(http.MethodGet)
+	debugRouter.HandleFunc(apiPrefix+RuleResolutionRatesEndpoint, server.getRuleResolutionRates).Methods(http.MethodGet)
+	debugRouter.HandleFunc(apiPrefix+AdminSimulateIngestEndpoint, server.simulateIngest).Methods(http.MethodPost)
+	debugRouter.HandleFunc(apiPrefix+AdminSchemaEndpoint, server.getDBSchema).Methods(http.MethodGet)
+	debugRouter.HandleFunc(apiPrefix+AdminMessageKeyEndpoint, server.lookupMessageKey).Methods(http.MethodGet)
+	debugRouter.HandleFunc(apiPrefix+InfoEndpoint, server.getInfo).Methods(http.MethodGet)
+
+	// endpoints for pprof - needed for profiling, ie. usually in debug mode;
+	// profiling tools can't send the confirmation header, so the requests
+	// are only audit logged
+	router.PathPrefix("/debug/pprof/").Handler(server.AuditDebugRequest(http.DefaultServeMux))
+}
+
+// addAdminEndpointsToRouter adds admin endpoints into separate router, so
+// every request to them can be audit logged and has to be authenticated by
+// API key with admin scope
+func (server *HTTPServer) addAdminEndpointsToRouter(router *mux.Router) {
+	apiPrefix := server.Config.APIPrefix
+
+	adminRouter := router.NewRoute().Subrouter()
+	adminRouter.Use(server.AuditDebugRequest, server.RequireAdminAccess)
+
+	adminRouter.HandleFunc(apiPrefix+AdminAPIKeysEndpoint, server.getAPIKeys).Methods(http.MethodGet)
+	adminRouter.HandleFunc(apiPrefix+AdminAPIKeysEndpoint, server.createAPIKey).Methods(http.MethodPost)
+	adminRouter.HandleFunc(apiPrefix+AdminAPIKeyEndpoint, server.revokeAPIKey).Methods(http.MethodDelete)
+	adminRouter.HandleFunc(apiPrefix+AdminAPIKeyRotateEndpoint, server.rotateAPIKey).Methods(http.MethodPost)
+	adminRouter.HandleFunc(apiPrefix+TopRulesEndpoint, server.getTopRules).Methods(http.MethodGet)
+	adminRouter.HandleFunc(apiPrefix+AdminOffsetsEndpoint, server.getKafkaOffsets).Methods(http.MethodGet)
+	adminRouter.HandleFunc(apiPrefix+AdminOrgUsageEndpoint, server.getOrgUsage).Methods(http.MethodGet)
+	adminRouter.HandleFunc(apiPrefix+AdminCacheRebuildEndpoint, server.rebuildClustersLastCheckedCache).Methods(http.MethodPost)
+	adminRouter.HandleFunc(apiPrefix+AdminCacheStatsEndpoint, server.getClustersLastCheckedCacheStats).Methods(http.MethodGet)
+	adminRouter.HandleFunc(apiPrefix+AdminVotesImportEndpoint, server.importVotes).Methods(http.MethodPost)
+	adminRouter.HandleFunc(apiPrefix+AdminOrgFreezeEndpoint, server.freezeOrg).Methods(http.MethodPut)
+	adminRouter.HandleFunc(apiPrefix+AdminOrgFreezeEndpoint, server.unfreezeOrg).Methods(http.MethodDelete)
+	adminRouter.HandleFunc(apiPrefix+AdminFrozenOrgsEndpoint, server.getFrozenOrgs).Methods(http.MethodGet)
+	adminRouter.HandleFunc(apiPrefix+AdminJobsEndpoint, server.getJobs).Methods(http.MethodGet)
+	adminRouter.HandleFunc(apiPrefix+AdminJobEndpoint, server.startJob).Methods(http.MethodPost)
+	adminRouter.HandleFunc(apiPrefix+AdminJobEndpoint, server.getJob).Methods(http.MethodGet)
+
+	// chaos mode settings
+	if server.FaultInjector != nil {
+		adminRouter.HandleFunc(apiPrefix+AdminChaosEndpoint, server.getChaosSettings).Methods(http.MethodGet)
+		adminRouter.HandleFunc(apiPrefix+AdminChaosEndpoint, server.updateChaosSettings).Methods(http.MethodPut)
+	}
 }
 
 func (server *HTTPServer) addEndpointsToRouter(router *mux.Router) {
 	apiPrefix := server.Config.APIPrefix
 	openAPIURL := apiPrefix + filepath.Base(server.Config.APISpecFile)
 
-	// it is possible to use special REST API endpoints in debug mode
-	if server.Config.Debug {
+	// it is possible to use special REST API endpoints in debug mode, when
+	// they are explicitly enabled
+	if server.debugEndpointsEnabled() {
 		server.addDebugEndpointsToRouter(router)
 	}
 
+	// admin endpoints are always available, but only for API keys with
+	// admin scope
+	server.addAdminEndpointsToRouter(router)
+
 	// common REST API endpoints
 	router.HandleFunc(apiPrefix+MainEndpoint, server.mainEndpoint).Methods(http.MethodGet)
-	router.HandleFunc(apiPrefix+ReportEndpoint, server.readReportForCluster).Methods(http.MethodGet, http.MethodOptions)
-	router.HandleFunc(apiPrefix+RuleEndpoint, server.readSingleRule).Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc(apiPrefix+ReportEndpoint, server.readReportForCluster).Methods(http.MethodGet, http.MethodHead, http.MethodOptions)
+	router.HandleFunc(apiPrefix+RuleEndpoint, server.readSingleRule).Methods(http.MethodGet, http.MethodHead, http.MethodOptions)
 	router.HandleFunc(apiPrefix+LikeRuleEndpoint, server.likeRule).Methods(http.MethodPut, http.MethodOptions)
 	router.HandleFunc(apiPrefix+DislikeRuleEndpoint, server.dislikeRule).Methods(http.MethodPut, http.MethodOptions)
 	router.HandleFunc(apiPrefix+ResetVoteOnRuleEndpoint, server.resetVoteOnRule).Methods(http.MethodPut, http.MethodOptions)
-	router.HandleFunc(apiPrefix+ClustersForOrganizationEndpoint, server.listOfClustersForOrganization).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+ClustersForOrganizationEndpoint, server.listOfClustersForOrganization).Methods(http.MethodGet, http.MethodHead)
+	router.HandleFunc(apiPrefix+ClusterDisplayNamesEndpoint, server.getClusterDisplayNames).Methods(http.MethodPost)
+	router.HandleFunc(apiPrefix+OrganizationInfoEndpoint, server.organizationInfo).Methods(http.MethodGet, http.MethodHead)
+	router.HandleFunc(apiPrefix+OrganizationOverviewEndpoint, server.organizationOverview).Methods(http.MethodGet, http.MethodHead)
+	router.HandleFunc(apiPrefix+OrganizationReportEndpoint, server.organizationReport).Methods(http.MethodGet, http.MethodHead)
+	router.HandleFunc(apiPrefix+UserFeedbackForClustersEndpoint, server.userFeedbackForClusters).Methods(http.MethodPost)
+	router.HandleFunc(apiPrefix+LikeRuleForOrgEndpoint, server.likeRuleForOrg).Methods(http.MethodPut, http.MethodOptions)
+	router.HandleFunc(apiPrefix+DislikeRuleForOrgEndpoint, server.dislikeRuleForOrg).Methods(http.MethodPut, http.MethodOptions)
+	router.HandleFunc(apiPrefix+ResetVoteOnRuleForOrgEndpoint, server.resetVoteOnRuleForOrg).Methods(http.MethodPut, http.MethodOptions)
+	router.HandleFunc(apiPrefix+UserFeedbackOnRuleForOrgEndpoint, server.saveFeedbackOnRuleForOrg).Methods(http.MethodPost)
+	router.HandleFunc(apiPrefix+UserFeedbackOnRuleForOrgEndpoint, server.getUserFeedbackOnRuleForOrg).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+FeedbackOnRuleForOrgEndpoint, server.getFeedbackOnRuleForOrg).Methods(http.MethodGet)
 	router.HandleFunc(apiPrefix+DisableRuleForClusterEndpoint, server.disableRuleForCluster).Methods(http.MethodPut, http.MethodOptions)
 	router.HandleFunc(apiPrefix+EnableRuleForClusterEndpoint, server.enableRuleForCluster).Methods(http.MethodPut, http.MethodOptions)
 	router.HandleFunc(apiPrefix+DisableRuleFeedbackEndpoint, server.saveDisableFeedback).Methods(http.MethodPost)
-	router.HandleFunc(apiPrefix+ReportForListOfClustersEndpoint, server.reportForListOfClusters).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+RuleHitOccurrencesEndpoint, server.getRuleHitOccurrences).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+RuleHitsExportEndpoint, server.exportRuleHits).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+OrganizationRuleResolutionRatesEndpoint, server.getOrganizationRuleResolutionRates).Methods(http.MethodGet, http.MethodHead)
+	router.HandleFunc(apiPrefix+AddClusterAnnotationEndpoint, server.addClusterAnnotation).Methods(http.MethodPost)
+	router.HandleFunc(apiPrefix+ClusterAnnotationsEndpoint, server.getClusterAnnotations).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+ClusterStatsEndpoint, server.getClusterStats).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+ExternalResultsEndpoint, server.getExternalResults).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+ExternalSourceResultsEndpoint, server.getExternalResults).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+DeleteClusterAnnotationEndpoint, server.deleteClusterAnnotation).Methods(http.MethodDelete)
+	router.HandleFunc(apiPrefix+ReportForListOfClustersEndpoint, server.reportForListOfClusters).Methods(http.MethodGet, http.MethodHead)
 	router.HandleFunc(apiPrefix+ReportForListOfClustersPayloadEndpoint, server.reportForListOfClustersPayload).Methods(http.MethodPost)
 
 	// Prometheus metrics
-	router.Handle(apiPrefix+MetricsEndpoint, promhttp.Handler()).Methods(http.MethodGet)
+	router.Handle(apiPrefix+MetricsEndpoint, server.metricsHandler()).Methods(http.MethodGet)
 
 	// OpenAPI specs
 	router.HandleFunc(
@@ -15,7 +15,14 @@
 package server
 
 import (
+	"errors"
+	"net/http"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
 	operator_utils_types "github.com/RedHatInsights/insights-operator-utils/types"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
 )
 
 type (
@@ -34,5 +41,26 @@ type (
 // handleServerError handles separate server errors and sends appropriate responses
 var handleServerError = operator_utils_types.HandleServerError
 
+// reportRetryAfter is the value of Retry-After header sent when the report
+// is being rewritten and should be read again
+const reportRetryAfter = "1"
+
+// handleReportReadError handles errors returned when the report is read.
+// Report with rule hits from another generation is being rewritten right
+// now, so the client is asked to retry the request instead of getting mixed
+// data.
+func handleReportReadError(writer http.ResponseWriter, err error) {
+	if errors.Is(err, types.ErrReportInconsistent) {
+		writer.Header().Set("Retry-After", reportRetryAfter)
+		err := responses.Send(http.StatusServiceUnavailable, writer, responses.BuildResponse(err.Error()))
+		if err != nil {
+			log.Error().Err(err).Msg(responseDataError)
+		}
+		return
+	}
+
+	handleServerError(writer, err)
+}
+
 // responseDataError is used as the error message when the responses functions return an error
 const responseDataError = "Unexpected error during response data encoding"
@@ -39,4 +39,13 @@ var (
 	SendDBErrorResponse           = sendDBErrorResponse
 	SendMarshallErrorResponse     = sendMarshallErrorResponse
 	FillInGeneratedReports        = fillInGeneratedReports
+	ParseTraceParent              = parseTraceParent
+	ReadParams                    = readParams
+	ClusterNameParam              = clusterNameParam
+	OrgIDParam                    = orgIDParam
+	UserIDParam                   = userIDParam
+	RuleIDWithErrorKeyParam       = ruleIDWithErrorKeyParam
+	BoolQueryParam                = boolQueryParam
+	UintQueryParam                = uintQueryParam
+	PositiveIntQueryParam         = positiveIntQueryParam
 )
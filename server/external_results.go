@@ -0,0 +1,89 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+)
+
+// externalResultsSourcePathParam is the optional path parameter selecting
+// results of one external source
+const externalResultsSourcePathParam = "source"
+
+// externalResultsSourceParam defines optional path parameter with the name
+// of the external source, any value is accepted
+func externalResultsSourceParam(source *string) param {
+	return param{
+		name:   externalResultsSourcePathParam,
+		source: optionalPathParam,
+		parse: func(rawValue string) error {
+			*source = rawValue
+			return nil
+		},
+	}
+}
+
+// getExternalResults returns results of checks of the cluster done by
+// external sources (security scanners, for example), only results of one
+// source are returned when the source is in the path. The results can be
+// paged the same way as rules in the report.
+func (server *HTTPServer) getExternalResults(writer http.ResponseWriter, request *http.Request) {
+	clusterID, successful := readClusterName(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	paging, successful := readReportPagingQueryParams(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	successful = server.checkUserClusterPermissions(writer, request, clusterID)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	// the parameter is missing when results of all sources are requested
+	var source string
+	if !readParams(writer, request, externalResultsSourceParam(&source)) {
+		// everything has been handled already
+		return
+	}
+
+	results, err := server.Storage.ReadExternalResults(clusterID, source)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read external results of the cluster")
+		handleServerError(writer, err)
+		return
+	}
+
+	response := responses.BuildOkResponseWithData("results", results)
+	if paging != nil {
+		start, end := paging.page(len(results))
+		response["results"] = results[start:end]
+		response["paging"] = paging
+	}
+
+	err = responses.SendOK(writer, response)
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
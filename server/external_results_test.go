@@ -0,0 +1,147 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mustWriteExternalResults writes the report of the cluster, so the cluster
+// is known, and results of two external sources
+func mustWriteExternalResults(t *testing.T, mockStorage storage.Storage) string {
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	err = mockStorage.WriteExternalResults(testdata.OrgID, testdata.ClusterName, "scanner", []types.ExternalResult{
+		{CheckID: "CVE-2020-0001", Severity: 2, Description: "outdated package"},
+		{CheckID: "CVE-2020-0002", Severity: 4, Description: "vulnerable image", Details: []byte(`{"image":"nginx"}`)},
+	}, testdata.LastCheckedAt, testdata.KafkaOffset)
+	helpers.FailOnError(t, err)
+
+	err = mockStorage.WriteExternalResults(testdata.OrgID, testdata.ClusterName, "compliance", []types.ExternalResult{
+		{CheckID: "password-policy", Severity: 1, Description: "weak password policy"},
+	}, testdata.LastCheckedAt, testdata.KafkaOffset)
+	helpers.FailOnError(t, err)
+
+	return testdata.LastCheckedAt.UTC().Format(time.RFC3339)
+}
+
+func TestHTTPServer_GetExternalResults(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	lastChecked := mustWriteExternalResults(t, mockStorage)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ExternalResultsEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{"status": "ok", "results": [
+			{"source": "compliance", "check_id": "password-policy", "severity": 1, "description": "weak password policy", "last_checked_at": "` + lastChecked + `"},
+			{"source": "scanner", "check_id": "CVE-2020-0002", "severity": 4, "description": "vulnerable image", "details": {"image": "nginx"}, "last_checked_at": "` + lastChecked + `"},
+			{"source": "scanner", "check_id": "CVE-2020-0001", "severity": 2, "description": "outdated package", "last_checked_at": "` + lastChecked + `"}
+		]}`,
+	})
+}
+
+func TestHTTPServer_GetExternalResults_Source(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	lastChecked := mustWriteExternalResults(t, mockStorage)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ExternalSourceResultsEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName, "compliance"},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{"status": "ok", "results": [
+			{"source": "compliance", "check_id": "password-policy", "severity": 1, "description": "weak password policy", "last_checked_at": "` + lastChecked + `"}
+		]}`,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ExternalSourceResultsEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName, "unknown"},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"status": "ok", "results": []}`,
+	})
+}
+
+func TestHTTPServer_GetExternalResults_Paging(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	lastChecked := mustWriteExternalResults(t, mockStorage)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ExternalResultsEndpoint + "?limit=1&offset=1",
+		EndpointArgs: []interface{}{testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{"status": "ok", "results": [
+			{"source": "scanner", "check_id": "CVE-2020-0002", "severity": 4, "description": "vulnerable image", "details": {"image": "nginx"}, "last_checked_at": "` + lastChecked + `"}
+		], "paging": {"offset": 1, "limit": 1, "total": 3}}`,
+	})
+}
+
+func TestHTTPServer_GetExternalResults_UnknownCluster(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ExternalResultsEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+	})
+}
+
+func TestHTTPServer_GetExternalResults_DBError(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorageWithFaults(t, true)
+	defer closer()
+
+	mustWriteExternalResults(t, mockStorage)
+
+	mockStorage.InjectFault("ReadExternalResults", helpers.Fault{Err: errors.New("database is unavailable")})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ExternalResultsEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status": "Internal Server Error"}`,
+	})
+}
@@ -0,0 +1,34 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+)
+
+// getInfo returns the effective configuration of the service with secrets
+// replaced and the default values of configuration options
+func (server *HTTPServer) getInfo(writer http.ResponseWriter, _ *http.Request) {
+	response := responses.BuildOkResponseWithData("configuration", server.EffectiveConfiguration)
+	response["defaults"] = server.DefaultConfiguration
+
+	err := responses.SendOK(writer, response)
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
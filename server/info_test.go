@@ -0,0 +1,59 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"net/http"
+	"testing"
+
+	iou_helpers "github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+)
+
+func TestGetInfo(t *testing.T) {
+	testServer := server.New(helpers.DefaultServerConfig, nil)
+	testServer.EffectiveConfiguration = map[string]interface{}{
+		"Storage": map[string]string{"pg_password": "*****"},
+	}
+	testServer.DefaultConfiguration = map[string]interface{}{
+		"Server": map[string]string{"address": ":8080"},
+	}
+
+	iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.InfoEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"status": "ok",
+			"configuration": {"Storage": {"pg_password": "*****"}},
+			"defaults": {"Server": {"address": ":8080"}}
+		}`,
+	})
+}
+
+func TestGetInfoRequiresDebugConfirmation(t *testing.T) {
+	testServer := server.New(helpers.DefaultServerConfig, nil)
+
+	iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.InfoEndpoint,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusForbidden,
+	})
+}
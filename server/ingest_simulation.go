@@ -0,0 +1,77 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// externalQueryParam tells that the simulated message is from the topic with
+// results of external sources
+const externalQueryParam = "external"
+
+// IngestSimulator runs the message value through parsing, validation and
+// report assembly done by the consumer without storing anything
+type IngestSimulator interface {
+	SimulateIngest(messageValue []byte, external bool) types.IngestSimulation
+}
+
+// simulateIngest returns the rule hits that would be stored when the message
+// in the request body was consumed together with all validation errors. The
+// message is never stored.
+func (server *HTTPServer) simulateIngest(writer http.ResponseWriter, request *http.Request) {
+	if server.IngestSimulator == nil {
+		err := responses.SendServiceUnavailable(writer, "Ingestion simulation is not available")
+		if err != nil {
+			log.Error().Err(err).Msg(responseDataError)
+		}
+		return
+	}
+
+	external, successful := readBoolQueryParam(writer, request, externalQueryParam)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	messageValue, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		handleServerError(writer, err)
+		return
+	}
+
+	if len(messageValue) == 0 {
+		handleServerError(writer, &NoBodyError{})
+		return
+	}
+
+	simulation := server.IngestSimulator.SimulateIngest(messageValue, external)
+
+	log.Info().
+		Bool("valid", simulation.Valid).
+		Int("rule_hits", len(simulation.RuleHits)).
+		Msg("Ingestion of message simulated")
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("simulation", simulation))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	iou_helpers "github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/consumer"
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// newServerWithIngestSimulator constructs server simulating ingestion of
+// messages the way the consumer with default configuration does it
+func newServerWithIngestSimulator(t *testing.T) (*server.HTTPServer, func()) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+
+	testServer := server.New(helpers.DefaultServerConfig, mockStorage)
+	testServer.IngestSimulator = consumer.NewIngestSimulator(broker.Configuration{})
+
+	return testServer, closer
+}
+
+// assertIngestSimulation sends the message to the simulation endpoint and
+// returns the simulation from the response
+func assertIngestSimulation(t *testing.T, testServer *server.HTTPServer, endpoint, message string) types.IngestSimulation {
+	var response struct {
+		Status     string                 `json:"status"`
+		Simulation types.IngestSimulation `json:"simulation"`
+	}
+
+	iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     endpoint,
+		Body:         message,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		// the body checker is called only when the expected body is set
+		Body: "",
+		BodyChecker: func(t testing.TB, _, got []byte) {
+			helpers.FailOnError(t, json.Unmarshal(got, &response))
+		},
+	})
+
+	assert.Equal(t, "ok", response.Status)
+
+	return response.Simulation
+}
+
+func TestHTTPServer_SimulateIngest(t *testing.T) {
+	testServer, closer := newServerWithIngestSimulator(t)
+	defer closer()
+
+	simulation := assertIngestSimulation(t, testServer, server.AdminSimulateIngestEndpoint, `{
+		"OrgID": `+fmt.Sprint(testdata.OrgID)+`,
+		"ClusterName": "`+string(testdata.ClusterName)+`",
+		"Report": {
+			"fingerprints": [], "info": [], "skips": [], "system": {},
+			"reports": [{"component": "ccx_rules_ocp.external.rules.nodes_kubelet_version_check.report", "key": "NODE_KUBELET_VERSION", "details": {}}]
+		},
+		"LastChecked": "`+testdata.LastCheckedAt.Format(time.RFC3339)+`",
+		"Version": 1
+	}`)
+
+	assert.True(t, simulation.Valid)
+	assert.Empty(t, simulation.Errors)
+	assert.Equal(t, testdata.ClusterName, simulation.ClusterName)
+	if assert.Len(t, simulation.RuleHits, 1) {
+		assert.Equal(t, types.ErrorKey("NODE_KUBELET_VERSION"), simulation.RuleHits[0].ErrorKey)
+	}
+
+	// nothing has been stored
+	_, _, err := testServer.Storage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	assert.Error(t, err)
+}
+
+func TestHTTPServer_SimulateIngest_InvalidMessage(t *testing.T) {
+	testServer, closer := newServerWithIngestSimulator(t)
+	defer closer()
+
+	simulation := assertIngestSimulation(t, testServer, server.AdminSimulateIngestEndpoint, `{
+		"ClusterName": "`+string(testdata.ClusterName)+`"
+	}`)
+
+	assert.False(t, simulation.Valid)
+	assert.Equal(t, []string{"Error parsing message: missing required attribute 'OrgID'"}, simulation.Errors)
+	assert.Empty(t, simulation.RuleHits)
+}
+
+func TestHTTPServer_SimulateIngest_ExternalResults(t *testing.T) {
+	testServer, closer := newServerWithIngestSimulator(t)
+	defer closer()
+
+	simulation := assertIngestSimulation(t, testServer, server.AdminSimulateIngestEndpoint+"?external=true", `{
+		"OrgID": `+fmt.Sprint(testdata.OrgID)+`,
+		"ClusterName": "`+string(testdata.ClusterName)+`",
+		"Source": "scanner",
+		"Results": [{"check_id": "CVE-2020-1234", "severity": 3}]
+	}`)
+
+	assert.True(t, simulation.Valid)
+	assert.Len(t, simulation.ExternalResults, 1)
+}
+
+func TestHTTPServer_SimulateIngest_BadRequest(t *testing.T) {
+	testServer, closer := newServerWithIngestSimulator(t)
+	defer closer()
+
+	iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.AdminSimulateIngestEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body:       `{"status": "client didn't provide request body"}`,
+	})
+
+	iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.AdminSimulateIngestEndpoint + "?external=maybe",
+		Body:         `{}`,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body:       `{"status": "Error during parsing param 'external' with value 'maybe'. Error: 'boolean value expected'"}`,
+	})
+}
+
+func TestHTTPServer_SimulateIngest_NotAvailable(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.AdminSimulateIngestEndpoint,
+		Body:         `{}`,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusServiceUnavailable,
+		Body:       `{"status": "Ingestion simulation is not available"}`,
+	})
+}
@@ -0,0 +1,238 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/export"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// RecomputeAggregatesJob rebuilds tables and caches derived from reports
+// and rule hits, it should be run after bulk imports or replays of messages
+const RecomputeAggregatesJob = "recompute-aggregates"
+
+// ParquetExportJob exports reports and rule hits into Parquet files, it's
+// available only when the export is configured
+const ParquetExportJob = "parquet-export"
+
+// statuses of job runs
+const (
+	jobStatusRunning  = "running"
+	jobStatusFinished = "finished"
+	jobStatusFailed   = "failed"
+)
+
+// jobAlreadyRunningMessage is returned in body of response when the job is
+// started while its previous run hasn't finished yet
+const jobAlreadyRunningMessage = "Job is already running"
+
+// JobFunc performs the job and returns its result
+type JobFunc func() (interface{}, error)
+
+// JobRun describes the last run of the job
+type JobRun struct {
+	Name       string          `json:"name"`
+	Status     string          `json:"status"`
+	StartedAt  types.Timestamp `json:"started_at"`
+	FinishedAt types.Timestamp `json:"finished_at,omitempty"`
+	Result     interface{}     `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// jobRuns contains the last run of every job started by the jobs API. Jobs
+// run asynchronously in their own goroutines and runs of the same job never
+// overlap. The runs are kept in memory only, so every instance of the
+// service knows about the jobs started on it.
+type jobRuns struct {
+	runs  map[string]JobRun
+	mutex sync.Mutex
+}
+
+// jobs returns all jobs that can be started by the jobs API
+func (server *HTTPServer) jobs() map[string]JobFunc {
+	jobs := map[string]JobFunc{
+		RecomputeAggregatesJob: func() (interface{}, error) {
+			return server.Storage.RecomputeAggregates()
+		},
+	}
+
+	if server.ExportConfiguration != nil {
+		exportConfiguration := *server.ExportConfiguration
+		jobs[ParquetExportJob] = func() (interface{}, error) {
+			files, err := export.ToParquet(exportConfiguration, server.Storage)
+			if err != nil {
+				return nil, err
+			}
+
+			return map[string]interface{}{"files": files}, nil
+		}
+	}
+
+	return jobs
+}
+
+// start starts the job unless its previous run is still running. The
+// started run is returned.
+func (jobRuns *jobRuns) start(name string, run JobFunc) (JobRun, bool) {
+	jobRuns.mutex.Lock()
+	defer jobRuns.mutex.Unlock()
+
+	if jobRuns.runs == nil {
+		jobRuns.runs = make(map[string]JobRun)
+	}
+
+	if previous, found := jobRuns.runs[name]; found && previous.Status == jobStatusRunning {
+		return previous, false
+	}
+
+	started := JobRun{
+		Name:      name,
+		Status:    jobStatusRunning,
+		StartedAt: types.Timestamp(time.Now().UTC().Format(time.RFC3339)),
+	}
+	jobRuns.runs[name] = started
+
+	go jobRuns.run(started, run)
+
+	return started, true
+}
+
+// run performs the job and records its result. Panics are recovered so
+// a failing job doesn't stop the whole service.
+func (jobRuns *jobRuns) run(jobRun JobRun, run JobFunc) {
+	log.Info().Str("job", jobRun.Name).Msg("Job started")
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			jobRun.Status = jobStatusFailed
+			jobRun.Error = fmt.Sprint(recovered)
+		}
+
+		jobRun.FinishedAt = types.Timestamp(time.Now().UTC().Format(time.RFC3339))
+
+		if jobRun.Status == jobStatusFailed {
+			log.Error().Str("job", jobRun.Name).Str("error", jobRun.Error).Msg("Job failed")
+		} else {
+			log.Info().Str("job", jobRun.Name).Msg("Job finished")
+		}
+
+		jobRuns.mutex.Lock()
+		jobRuns.runs[jobRun.Name] = jobRun
+		jobRuns.mutex.Unlock()
+	}()
+
+	result, err := run()
+	if err != nil {
+		jobRun.Status = jobStatusFailed
+		jobRun.Error = err.Error()
+		return
+	}
+
+	jobRun.Status = jobStatusFinished
+	jobRun.Result = result
+}
+
+// get returns the last run of the job
+func (jobRuns *jobRuns) get(name string) (JobRun, bool) {
+	jobRuns.mutex.Lock()
+	defer jobRuns.mutex.Unlock()
+
+	jobRun, found := jobRuns.runs[name]
+	return jobRun, found
+}
+
+// list returns the last runs of all jobs ordered by name of the job
+func (jobRuns *jobRuns) list() []JobRun {
+	jobRuns.mutex.Lock()
+	defer jobRuns.mutex.Unlock()
+
+	list := make([]JobRun, 0, len(jobRuns.runs))
+	for _, jobRun := range jobRuns.runs {
+		list = append(list, jobRun)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	return list
+}
+
+// startJob starts the job asynchronously and responds with 202 Accepted and
+// the started run. 409 Conflict is returned when the job is already running.
+func (server *HTTPServer) startJob(writer http.ResponseWriter, request *http.Request) {
+	name, err := getRouterParam(request, "job")
+	if err != nil {
+		handleServerError(writer, err)
+		return
+	}
+
+	run, found := server.jobs()[name]
+	if !found {
+		handleServerError(writer, &types.ItemNotFoundError{ItemID: name})
+		return
+	}
+
+	jobRun, started := server.jobRuns.start(name, run)
+	if !started {
+		err = responses.Send(http.StatusConflict, writer, responses.BuildResponse(jobAlreadyRunningMessage))
+		if err != nil {
+			log.Error().Err(err).Msg(responseDataError)
+		}
+		return
+	}
+
+	err = responses.Send(http.StatusAccepted, writer, responses.BuildOkResponseWithData("job", jobRun))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// getJob returns the last run of the job
+func (server *HTTPServer) getJob(writer http.ResponseWriter, request *http.Request) {
+	name, err := getRouterParam(request, "job")
+	if err != nil {
+		handleServerError(writer, err)
+		return
+	}
+
+	jobRun, found := server.jobRuns.get(name)
+	if !found {
+		handleServerError(writer, &types.ItemNotFoundError{ItemID: name})
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("job", jobRun))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// getJobs returns the last runs of all jobs started on this instance
+func (server *HTTPServer) getJobs(writer http.ResponseWriter, _ *http.Request) {
+	err := responses.SendOK(writer, responses.BuildOkResponseWithData("jobs", server.jobRuns.list()))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
@@ -0,0 +1,280 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	httputils "github.com/RedHatInsights/insights-operator-utils/http"
+	iou_helpers "github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/export"
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+)
+
+// mustWaitForJob polls the last run of the job until it is finished
+func mustWaitForJob(t *testing.T, testServer *server.HTTPServer, name string) server.JobRun {
+	var response struct {
+		Job server.JobRun `json:"job"`
+	}
+
+	url := httputils.MakeURLToEndpoint(helpers.DefaultServerConfig.APIPrefix, server.AdminJobEndpoint, name)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		request, err := http.NewRequest(http.MethodGet, url, nil)
+		helpers.FailOnError(t, err)
+		request.Header = helpers.DebugConfirmationHeaders()
+
+		recorder := helpers.ExecuteRequest(testServer, request)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		helpers.FailOnError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+
+		if response.Job.Status != "running" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s is still running", name)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return response.Job
+}
+
+func TestHTTPServer_RecomputeAggregatesJob(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed,
+		testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	testServer := server.New(helpers.DefaultServerConfig, mockStorage)
+
+	iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.AdminJobEndpoint,
+		EndpointArgs: []interface{}{server.RecomputeAggregatesJob},
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusAccepted,
+		BodyChecker: func(t testing.TB, _, got []byte) {
+			var response struct {
+				Status string        `json:"status"`
+				Job    server.JobRun `json:"job"`
+			}
+			helpers.FailOnError(t, json.Unmarshal(got, &response))
+			assert.Equal(t, "ok", response.Status)
+			assert.Equal(t, server.RecomputeAggregatesJob, response.Job.Name)
+			assert.NotEmpty(t, response.Job.StartedAt)
+		},
+	})
+
+	jobRun := mustWaitForJob(t, testServer, server.RecomputeAggregatesJob)
+	assert.Equal(t, "finished", jobRun.Status)
+	assert.NotEmpty(t, jobRun.FinishedAt)
+	assert.Empty(t, jobRun.Error)
+	assert.Equal(t, map[string]interface{}{
+		"organizations":        1.0,
+		"rule_hits_first_seen": 0.0,
+		"cached_clusters":      1.0,
+	}, jobRun.Result)
+
+	iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminJobsEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got []byte) {
+			var response struct {
+				Jobs []server.JobRun `json:"jobs"`
+			}
+			helpers.FailOnError(t, json.Unmarshal(got, &response))
+			if assert.Len(t, response.Jobs, 1) {
+				assert.Equal(t, server.RecomputeAggregatesJob, response.Jobs[0].Name)
+			}
+		},
+	})
+}
+
+func TestHTTPServer_JobFailed(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorageWithFaults(t, true)
+	defer closer()
+
+	mockStorage.InjectFault("RecomputeAggregates", helpers.Fault{Err: errors.New("recomputation error")})
+
+	testServer := server.New(helpers.DefaultServerConfig, mockStorage)
+
+	iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.AdminJobEndpoint,
+		EndpointArgs: []interface{}{server.RecomputeAggregatesJob},
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusAccepted,
+	})
+
+	jobRun := mustWaitForJob(t, testServer, server.RecomputeAggregatesJob)
+	assert.Equal(t, "failed", jobRun.Status)
+	assert.Equal(t, "recomputation error", jobRun.Error)
+	assert.Nil(t, jobRun.Result)
+}
+
+func TestHTTPServer_JobAlreadyRunning(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorageWithFaults(t, true)
+	defer closer()
+
+	// slow storage keeps the first run running
+	mockStorage.InjectFault("RecomputeAggregates", helpers.Fault{Latency: 200 * time.Millisecond})
+
+	testServer := server.New(helpers.DefaultServerConfig, mockStorage)
+
+	request := &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.AdminJobEndpoint,
+		EndpointArgs: []interface{}{server.RecomputeAggregatesJob},
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}
+
+	iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, request, &helpers.APIResponse{
+		StatusCode: http.StatusAccepted,
+	})
+
+	iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, request, &helpers.APIResponse{
+		StatusCode: http.StatusConflict,
+		Body:       `{"status": "Job is already running"}`,
+	})
+
+	jobRun := mustWaitForJob(t, testServer, server.RecomputeAggregatesJob)
+	assert.Equal(t, "finished", jobRun.Status)
+
+	// the job can be started again once it's finished
+	iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, request, &helpers.APIResponse{
+		StatusCode: http.StatusAccepted,
+	})
+	mustWaitForJob(t, testServer, server.RecomputeAggregatesJob)
+}
+
+func TestHTTPServer_ParquetExportJob(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed,
+		testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	directory := t.TempDir()
+
+	testServer := server.New(helpers.DefaultServerConfig, mockStorage)
+	testServer.ExportConfiguration = &export.Configuration{Path: directory}
+
+	iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.AdminJobEndpoint,
+		EndpointArgs: []interface{}{server.ParquetExportJob},
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusAccepted,
+	})
+
+	jobRun := mustWaitForJob(t, testServer, server.ParquetExportJob)
+	assert.Equal(t, "finished", jobRun.Status)
+	assert.Empty(t, jobRun.Error)
+
+	result, ok := jobRun.Result.(map[string]interface{})
+	if assert.True(t, ok) {
+		assert.Len(t, result["files"], 2)
+	}
+
+	files, err := ioutil.ReadDir(directory)
+	helpers.FailOnError(t, err)
+	assert.Len(t, files, 2)
+}
+
+func TestHTTPServer_ParquetExportJobNotConfigured(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.AdminJobEndpoint,
+		EndpointArgs: []interface{}{server.ParquetExportJob},
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+	})
+}
+
+func TestHTTPServer_UnknownJob(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.AdminJobEndpoint,
+		EndpointArgs: []interface{}{"unknown"},
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+		Body:       `{"status": "Item with ID unknown was not found in the storage"}`,
+	})
+}
+
+func TestHTTPServer_JobNotStarted(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminJobEndpoint,
+		EndpointArgs: []interface{}{server.RecomputeAggregatesJob},
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+		Body:       `{"status": "Item with ID ` + server.RecomputeAggregatesJob + ` was not found in the storage"}`,
+	})
+}
+
+func TestHTTPServer_JobsRequireAdminKey(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	// the confirmation header is not enough when debug endpoints are disabled
+	helpers.AssertAPIRequest(t, mockStorage, &configAPIKeyAuth, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminJobsEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusUnauthorized,
+	})
+
+	_, adminKey, err := server.CreateAPIKey(mockStorage, "admin", []string{server.APIKeyScopeAdmin}, time.Time{})
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, &configAPIKeyAuth, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminJobsEndpoint,
+		ExtraHeaders: apiKeyHeaders(adminKey),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"jobs": [], "status": "ok"}`,
+	})
+}
@@ -0,0 +1,101 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// systemdListenPIDEnv and systemdListenFDsEnv are environment variables
+	// set by systemd for the process activated by socket
+	systemdListenPIDEnv = "LISTEN_PID"
+	systemdListenFDsEnv = "LISTEN_FDS"
+	// systemdListenFDsStart is the first file descriptor passed by systemd
+	systemdListenFDsStart = 3
+)
+
+// listen returns the listener the server accepts connections from, that is
+// the socket passed by systemd when socket activation is enabled, unix
+// domain socket when its path is configured or TCP address otherwise
+func (server *HTTPServer) listen() (net.Listener, error) {
+	switch {
+	case server.Config.SocketActivation:
+		log.Info().Msg("Using socket passed by systemd")
+		return systemdListener()
+	case server.Config.UnixSocket != "":
+		log.Info().Msgf("Listening on unix socket '%s'", server.Config.UnixSocket)
+		return unixSocketListener(server.Config.UnixSocket)
+	default:
+		log.Info().Msgf("Listening on address '%s'", server.Config.Address)
+		return net.Listen("tcp", server.Config.Address)
+	}
+}
+
+// unixSocketListener listens on the unix domain socket. Socket left by the
+// previous instance of the service is removed, any other file is kept.
+func unixSocketListener(path string) (net.Listener, error) {
+	info, err := os.Lstat(path)
+	switch {
+	case err == nil && info.Mode()&os.ModeSocket != 0:
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	case err == nil:
+		return nil, fmt.Errorf("unable to listen on unix socket %s, the file exists and it is not a socket", path)
+	case !os.IsNotExist(err):
+		return nil, err
+	}
+
+	return net.Listen("unix", path)
+}
+
+// systemdListener returns the first socket passed by systemd when the service
+// is activated by socket. The environment variables are unset, so they are
+// not inherited by child processes.
+func systemdListener() (net.Listener, error) {
+	defer func() {
+		_ = os.Unsetenv(systemdListenPIDEnv)
+		_ = os.Unsetenv(systemdListenFDsEnv)
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv(systemdListenPIDEnv))
+	if err != nil || pid != os.Getpid() {
+		return nil, errors.New("no socket was passed by systemd to the process")
+	}
+
+	fds, err := strconv.Atoi(os.Getenv(systemdListenFDsEnv))
+	if err != nil || fds < 1 {
+		return nil, errors.New("no socket was passed by systemd to the process")
+	}
+
+	if fds > 1 {
+		log.Warn().Int("sockets", fds).Msg("More sockets passed by systemd, only the first one is used")
+	}
+
+	file := os.NewFile(uintptr(systemdListenFDsStart), "LISTEN_FD_3")
+	defer func() {
+		// the listener uses its own copy of the descriptor
+		_ = file.Close()
+	}()
+
+	return net.FileListener(file)
+}
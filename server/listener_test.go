@@ -0,0 +1,110 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+)
+
+func TestServerStartUnixSocket(t *testing.T) {
+	helpers.RunTestWithTimeout(t, func(t testing.TB) {
+		dir, err := ioutil.TempDir("", "aggregator-socket")
+		helpers.FailOnError(t, err)
+		defer func() {
+			_ = os.RemoveAll(dir)
+		}()
+
+		socketPath := filepath.Join(dir, "aggregator.sock")
+
+		// socket left by the previous run is replaced
+		staleListener, err := net.Listen("unix", socketPath)
+		helpers.FailOnError(t, err)
+		if unixListener, ok := staleListener.(*net.UnixListener); ok {
+			unixListener.SetUnlinkOnClose(false)
+		}
+		helpers.FailOnError(t, staleListener.Close())
+
+		config := helpers.DefaultServerConfig
+		config.UnixSocket = socketPath
+		s := server.New(config, nil)
+
+		client := &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socketPath)
+			},
+		}}
+
+		go func() {
+			defer func() {
+				helpers.FailOnError(t, s.Stop(context.Background()))
+			}()
+
+			for {
+				response, err := client.Get("http://aggregator" + config.APIPrefix)
+				if err != nil {
+					time.Sleep(100 * time.Millisecond)
+					continue
+				}
+				_ = response.Body.Close()
+
+				assert.Equal(t, http.StatusOK, response.StatusCode)
+				return
+			}
+		}()
+
+		err = s.Start(nil)
+		if err != nil && err != http.ErrServerClosed {
+			t.Fatal(err)
+		}
+	}, 5*time.Second)
+}
+
+func TestServerStartUnixSocketFileExists(t *testing.T) {
+	file, err := ioutil.TempFile("", "aggregator-socket")
+	helpers.FailOnError(t, err)
+	helpers.FailOnError(t, file.Close())
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+
+	config := helpers.DefaultServerConfig
+	config.UnixSocket = file.Name()
+
+	err = server.New(config, nil).Start(nil)
+	assert.EqualError(
+		t, err, "unable to listen on unix socket "+file.Name()+", the file exists and it is not a socket",
+	)
+}
+
+func TestServerStartSocketActivationWithoutSocket(t *testing.T) {
+	config := helpers.DefaultServerConfig
+	config.SocketActivation = true
+
+	err := server.New(config, nil).Start(nil)
+	assert.EqualError(t, err, "no socket was passed by systemd to the process")
+}
@@ -0,0 +1,90 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// HighWaterMarkReader reads high-water marks of topic partitions from the
+// broker, that is offsets the next messages produced into the partitions
+// will get
+type HighWaterMarkReader interface {
+	HighWaterMark(topic string, partition int32) (int64, error)
+}
+
+// partitionOffset is offset of the latest message processed in the topic
+// partition together with lag of the consumer behind the broker, the lag is
+// not available when high-water mark can't be read from the broker
+type partitionOffset struct {
+	types.KafkaPartitionOffset
+	HighWaterMark *int64 `json:"high_water_mark,omitempty"`
+	Lag           *int64 `json:"lag,omitempty"`
+}
+
+// consumerLag returns number of messages in the partition that were not
+// processed yet, offset is the offset of the latest processed message
+func consumerLag(offset types.KafkaOffset, highWaterMark int64) int64 {
+	lag := highWaterMark - int64(offset) - 1
+	if lag < 0 {
+		return 0
+	}
+
+	return lag
+}
+
+// getKafkaOffsets returns offsets of the latest messages processed in all
+// topic partitions with lag versus the broker high-water marks
+func (server *HTTPServer) getKafkaOffsets(writer http.ResponseWriter, _ *http.Request) {
+	offsets, err := server.Storage.GetLatestKafkaOffsets()
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read Kafka offsets")
+		handleServerError(writer, err)
+		return
+	}
+
+	partitionOffsets := make([]partitionOffset, 0, len(offsets))
+	for _, offset := range offsets {
+		partitionOffset := partitionOffset{KafkaPartitionOffset: offset}
+
+		if server.HighWaterMarks != nil {
+			highWaterMark, err := server.HighWaterMarks.HighWaterMark(offset.Topic, offset.Partition)
+			if err != nil {
+				log.Error().Err(err).
+					Str("topic", offset.Topic).
+					Int32("partition", offset.Partition).
+					Msg("Unable to read high-water mark from broker")
+			} else {
+				lag := consumerLag(offset.Offset, highWaterMark)
+				partitionOffset.HighWaterMark = &highWaterMark
+				partitionOffset.Lag = &lag
+			}
+		}
+
+		partitionOffsets = append(partitionOffsets, partitionOffset)
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("offsets", partitionOffsets))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
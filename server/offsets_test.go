@@ -0,0 +1,120 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	iou_helpers "github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+)
+
+const testKafkaTopic = "ccx.ocp.results"
+
+// updatedAtRegexp matches times of offset updates in the response
+var updatedAtRegexp = regexp.MustCompile(`"updated_at":"[^"]*"`)
+
+// highWaterMarksMock returns high-water marks of partitions from the map,
+// partitions not in the map can't be read
+type highWaterMarksMock map[int32]int64
+
+func (marks highWaterMarksMock) HighWaterMark(topic string, partition int32) (int64, error) {
+	highWaterMark, found := marks[partition]
+	if !found {
+		return 0, fmt.Errorf("partition %d of topic %s not found", partition, topic)
+	}
+
+	return highWaterMark, nil
+}
+
+// offsetsBodyChecker checks the response ignoring times of offset updates
+func offsetsBodyChecker(expected string) iou_helpers.BodyChecker {
+	return func(t testing.TB, _, got []byte) {
+		helpers.AssertStringsAreEqualJSON(t, expected, updatedAtRegexp.ReplaceAllString(string(got), `"updated_at":""`))
+	}
+}
+
+func TestGetKafkaOffsets(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	helpers.FailOnError(t, mockStorage.WriteKafkaOffset(testKafkaTopic, 0, 10))
+	helpers.FailOnError(t, mockStorage.WriteKafkaOffset(testKafkaTopic, 1, 20))
+
+	testServer := server.New(helpers.DefaultServerConfig, mockStorage)
+	testServer.HighWaterMarks = highWaterMarksMock{0: 15}
+
+	// lag isn't available for partition whose high-water mark can't be read
+	iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminOffsetsEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		// the body checker is called only when the expected body is set
+		Body: "",
+		BodyChecker: offsetsBodyChecker(`{
+			"status": "ok",
+			"offsets": [
+				{"topic": "ccx.ocp.results", "partition": 0, "offset": 10, "updated_at": "", "high_water_mark": 15, "lag": 4},
+				{"topic": "ccx.ocp.results", "partition": 1, "offset": 20, "updated_at": ""}
+			]
+		}`),
+	})
+}
+
+func TestGetKafkaOffsetsWithoutBroker(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	helpers.FailOnError(t, mockStorage.WriteKafkaOffset(testKafkaTopic, 0, 10))
+
+	_, adminKey, err := server.CreateAPIKey(mockStorage, "admin", []string{server.APIKeyScopeAdmin}, time.Time{})
+	helpers.FailOnError(t, err)
+
+	// offsets are available in production to API keys with admin scope
+	helpers.AssertAPIRequest(t, mockStorage, &configAPIKeyAuth, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminOffsetsEndpoint,
+		ExtraHeaders: apiKeyHeaders(adminKey),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: offsetsBodyChecker(`{
+			"status": "ok",
+			"offsets": [{"topic": "ccx.ocp.results", "partition": 0, "offset": 10, "updated_at": ""}]
+		}`),
+	})
+}
+
+func TestGetKafkaOffsetsDBError(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorageWithFaults(t, true)
+	defer closer()
+
+	mockStorage.InjectFault("GetLatestKafkaOffsets", helpers.Fault{Err: fmt.Errorf("database is down")})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminOffsetsEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+	})
+}
@@ -0,0 +1,231 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// orgFrozenMessage is returned in body of response when the request would
+// change data of frozen organization
+const orgFrozenMessage = "Organization is frozen"
+
+// frozenOrgWriteEndpoints are endpoints changing data of the cluster or the
+// organization, they are rejected with 423 Locked when the organization is
+// frozen. Endpoints changing data of more organizations (deletion of
+// organizations and clusters, import of votes) check the freeze by
+// rejectFrozenOrgs in their handlers.
+var frozenOrgWriteEndpoints = map[string]bool{
+	LikeRuleEndpoint:                 true,
+	DislikeRuleEndpoint:              true,
+	ResetVoteOnRuleEndpoint:          true,
+	DisableRuleForClusterEndpoint:    true,
+	EnableRuleForClusterEndpoint:     true,
+	DisableRuleFeedbackEndpoint:      true,
+	AddClusterAnnotationEndpoint:     true,
+	DeleteClusterAnnotationEndpoint:  true,
+	LikeRuleForOrgEndpoint:           true,
+	DislikeRuleForOrgEndpoint:        true,
+	ResetVoteOnRuleForOrgEndpoint:    true,
+	UserFeedbackOnRuleForOrgEndpoint: true,
+}
+
+// orgFreezeRequest is the body of the request freezing the organization
+type orgFreezeRequest struct {
+	Reason string `json:"reason"`
+}
+
+// requestClusterOrgID returns the organization owning the cluster the
+// request was made for. The organization which made the request is used
+// when the cluster has no report yet.
+func (server *HTTPServer) requestClusterOrgID(request *http.Request) (types.OrgID, bool, error) {
+	clusterName, err := getRouterParam(request, "cluster")
+	if err == nil {
+		orgID, err := server.Storage.GetOrgIDByClusterID(types.ClusterName(clusterName))
+		err = types.ConvertDBError(err, clusterName)
+		if err == nil {
+			return orgID, true, nil
+		}
+		if _, notFound := err.(*types.ItemNotFoundError); !notFound {
+			return 0, false, err
+		}
+	}
+
+	orgID, found := requestOrgID(request)
+	return orgID, found, nil
+}
+
+// RejectWritesOfFrozenOrgs is a middleware that responds with 423 Locked to
+// requests changing data of clusters of frozen organizations
+func (server *HTTPServer) RejectWritesOfFrozenOrgs(nextHandler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		// reads are allowed, some endpoints share the path for reads and writes
+		readOnly := request.Method == http.MethodOptions || request.Method == http.MethodGet
+		if readOnly || !frozenOrgWriteEndpoints[server.routeEndpoint(request)] {
+			nextHandler.ServeHTTP(writer, request)
+			return
+		}
+
+		orgID, found, err := server.requestClusterOrgID(request)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to read organization of the cluster")
+			handleServerError(writer, err)
+			return
+		}
+
+		if found && server.rejectFrozenOrgs(writer, request, orgID) {
+			return
+		}
+
+		nextHandler.ServeHTTP(writer, request)
+	})
+}
+
+// rejectFrozenOrgs responds with 423 Locked when any of the
+// organizations is frozen, true is returned when the request was responded
+func (server *HTTPServer) rejectFrozenOrgs(
+	writer http.ResponseWriter, request *http.Request, orgIDs ...types.OrgID,
+) bool {
+	for _, orgID := range orgIDs {
+		frozen, err := server.Storage.IsOrgFrozen(orgID)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to check whether the organization is frozen")
+			handleServerError(writer, err)
+			return true
+		}
+
+		if frozen {
+			log.Warn().Uint64("org_id", uint64(orgID)).Str("url", request.URL.String()).Msg(orgFrozenMessage)
+
+			err = responses.Send(http.StatusLocked, writer, responses.BuildResponse(orgFrozenMessage))
+			if err != nil {
+				log.Error().Err(err).Msg(responseDataError)
+			}
+			return true
+		}
+	}
+
+	return false
+}
+
+// rejectFrozenClusters responds with 423 Locked when organization
+// owning any of the clusters is frozen, clusters without report are skipped.
+// True is returned when the request was responded.
+func (server *HTTPServer) rejectFrozenClusters(
+	writer http.ResponseWriter, request *http.Request, clusterNames ...types.ClusterName,
+) bool {
+	orgIDs := make([]types.OrgID, 0, len(clusterNames))
+	seen := make(map[types.OrgID]bool)
+
+	for _, clusterName := range clusterNames {
+		orgID, err := server.Storage.GetOrgIDByClusterID(clusterName)
+		err = types.ConvertDBError(err, clusterName)
+		if _, notFound := err.(*types.ItemNotFoundError); notFound {
+			continue
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to read organization of the cluster")
+			handleServerError(writer, err)
+			return true
+		}
+
+		if !seen[orgID] {
+			seen[orgID] = true
+			orgIDs = append(orgIDs, orgID)
+		}
+	}
+
+	return server.rejectFrozenOrgs(writer, request, orgIDs...)
+}
+
+// freezeOrg freezes the organization, the reason of the freeze is required
+func (server *HTTPServer) freezeOrg(writer http.ResponseWriter, request *http.Request) {
+	orgID, successful := readOrganizationID(writer, request, false)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	var body orgFreezeRequest
+
+	err := json.NewDecoder(request.Body).Decode(&body)
+	if err != nil || strings.TrimSpace(body.Reason) == "" {
+		handleServerError(writer, &types.ValidationError{
+			ParamName:  "reason",
+			ParamValue: body.Reason,
+			ErrString:  "reason of the freeze expected",
+		})
+		return
+	}
+
+	err = server.Storage.FreezeOrg(orgID, body.Reason)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to freeze organization")
+		handleServerError(writer, err)
+		return
+	}
+
+	log.Warn().Uint64("org_id", uint64(orgID)).Str("reason", body.Reason).Msg("Organization frozen")
+
+	err = responses.SendOK(writer, responses.BuildOkResponse())
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// unfreezeOrg removes the freeze of the organization
+func (server *HTTPServer) unfreezeOrg(writer http.ResponseWriter, request *http.Request) {
+	orgID, successful := readOrganizationID(writer, request, false)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	err := server.Storage.UnfreezeOrg(orgID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to unfreeze organization")
+		handleServerError(writer, err)
+		return
+	}
+
+	log.Warn().Uint64("org_id", uint64(orgID)).Msg("Organization unfrozen")
+
+	err = responses.SendOK(writer, responses.BuildOkResponse())
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// getFrozenOrgs returns all frozen organizations with reasons of the freeze
+func (server *HTTPServer) getFrozenOrgs(writer http.ResponseWriter, _ *http.Request) {
+	freezes, err := server.Storage.ReadFrozenOrgs()
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read frozen organizations")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("organizations", freezes))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
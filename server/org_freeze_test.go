@@ -0,0 +1,257 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func TestHTTPServer_FreezeOrg(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed,
+		testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.AdminOrgFreezeEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+		Body:         `{"reason": "legal hold"}`,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"status": "ok"}`,
+	})
+
+	frozenOrgs, err := mockStorage.ReadFrozenOrgs()
+	helpers.FailOnError(t, err)
+	assert.Len(t, frozenOrgs, 1)
+	assert.Equal(t, testdata.OrgID, frozenOrgs[0].OrgID)
+	assert.Equal(t, "legal hold", frozenOrgs[0].Reason)
+
+	// data of the frozen organization can't be changed
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.LikeRuleEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusLocked,
+		Body:       `{"status": "Organization is frozen"}`,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.DisableRuleForClusterEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusLocked,
+		Body:       `{"status": "Organization is frozen"}`,
+	})
+
+	// but it can be still read
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodDelete,
+		Endpoint:     server.AdminOrgFreezeEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"status": "ok"}`,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.LikeRuleEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"status": "ok"}`,
+	})
+}
+
+func TestHTTPServer_FreezeOrg_MissingReason(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.AdminOrgFreezeEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+		Body:         `{"reason": " "}`,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body:       `{"status": "Error during validating param 'reason' with value ' '. Error: 'reason of the freeze expected'"}`,
+	})
+}
+
+func TestHTTPServer_UnfreezeOrg_NotFrozen(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodDelete,
+		Endpoint:     server.AdminOrgFreezeEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+		Body:       fmt.Sprintf(`{"status": "Item with ID %v was not found in the storage"}`, testdata.OrgID),
+	})
+}
+
+func TestHTTPServer_GetFrozenOrgs(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminFrozenOrgsEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"organizations": [], "status": "ok"}`,
+	})
+}
+
+func TestHTTPServer_FreezeOrgRequiresAdminKey(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	// the confirmation header is not enough when debug endpoints are disabled
+	helpers.AssertAPIRequest(t, mockStorage, &configAPIKeyAuth, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.AdminOrgFreezeEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+		Body:         `{"reason": "legal hold"}`,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusUnauthorized,
+	})
+
+	_, adminKey, err := server.CreateAPIKey(mockStorage, "admin", []string{server.APIKeyScopeAdmin}, time.Time{})
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, &configAPIKeyAuth, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminFrozenOrgsEndpoint,
+		ExtraHeaders: apiKeyHeaders(adminKey),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"organizations": [], "status": "ok"}`,
+	})
+}
+
+// mustFreezeOrgWithReport writes report of the cluster and freezes its
+// organization
+func mustFreezeOrgWithReport(t *testing.T, mockStorage storage.Storage) {
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed,
+		testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.FailOnError(t, mockStorage.FreezeOrg(testdata.OrgID, "legal hold"))
+}
+
+// assertReportNotDeleted checks that report of the frozen organization is kept
+func assertReportNotDeleted(t *testing.T, mockStorage storage.Storage) {
+	count, err := mockStorage.ReportsCount()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestHTTPServer_FreezeOrg_DeleteOrganizations(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustFreezeOrgWithReport(t, mockStorage)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodDelete,
+		Endpoint:     server.DeleteOrganizationsEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+		EndpointArgs: []interface{}{fmt.Sprintf("%v,%v", testdata.Org2ID, testdata.OrgID)},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusLocked,
+		Body:       `{"status": "Organization is frozen"}`,
+	})
+
+	assertReportNotDeleted(t, mockStorage)
+}
+
+func TestHTTPServer_FreezeOrg_DeleteClusters(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustFreezeOrgWithReport(t, mockStorage)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodDelete,
+		Endpoint:     server.DeleteClustersEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+		EndpointArgs: []interface{}{fmt.Sprintf("%v,%v", testdata.GetRandomClusterID(), testdata.ClusterName)},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusLocked,
+		Body:       `{"status": "Organization is frozen"}`,
+	})
+
+	assertReportNotDeleted(t, mockStorage)
+}
+
+func TestHTTPServer_FreezeOrg_ImportVotes(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustFreezeOrgWithReport(t, mockStorage)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.AdminVotesImportEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+		Body: `{"votes": [{
+			"cluster": "` + string(testdata.ClusterName) + `",
+			"rule_id": "` + string(testdata.Rule1ID) + `",
+			"error_key": "` + string(testdata.ErrorKey1) + `",
+			"user_id": "` + string(testdata.UserID) + `",
+			"user_vote": 1
+		}]}`,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusLocked,
+		Body:       `{"status": "Organization is frozen"}`,
+	})
+
+	_, err := mockStorage.GetUserFeedbackOnRule(
+		testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID,
+	)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
@@ -0,0 +1,195 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// orgRuleFeedback is feedback left by the user on rule for the organization
+type orgRuleFeedback struct {
+	UserID    types.UserID    `json:"user_id"`
+	UserVote  types.UserVote  `json:"user_vote"`
+	Message   string          `json:"message"`
+	AddedAt   types.Timestamp `json:"added_at"`
+	UpdatedAt types.Timestamp `json:"updated_at"`
+}
+
+// newOrgRuleFeedback converts the stored feedback into the response
+func newOrgRuleFeedback(feedback storage.OrgUserFeedbackOnRule) orgRuleFeedback {
+	return orgRuleFeedback{
+		UserID:    feedback.UserID,
+		UserVote:  feedback.UserVote,
+		Message:   feedback.Message,
+		AddedAt:   types.Timestamp(feedback.AddedAt.UTC().Format(time.RFC3339)),
+		UpdatedAt: types.Timestamp(feedback.UpdatedAt.UTC().Format(time.RFC3339)),
+	}
+}
+
+// readOrgRuleParams reads organization ID, rule ID and error key from the
+// path of the request. The organization has to be the organization of the
+// current user when authentication is enabled.
+func (server *HTTPServer) readOrgRuleParams(
+	writer http.ResponseWriter, request *http.Request,
+) (orgID types.OrgID, ruleID types.RuleID, errorKey types.ErrorKey, successful bool) {
+	successful = readParams(writer, request,
+		organizationParam(&orgID), ruleIDParam(&ruleID), errorKeyParam(&errorKey),
+	) && checkPermissions(writer, request, orgID, server.Config.Auth)
+
+	return orgID, ruleID, errorKey, successful
+}
+
+// likeRuleForOrg likes the rule for the organization for current user
+func (server *HTTPServer) likeRuleForOrg(writer http.ResponseWriter, request *http.Request) {
+	server.voteOnRuleForOrg(writer, request, types.UserVoteLike)
+}
+
+// dislikeRuleForOrg dislikes the rule for the organization for current user
+func (server *HTTPServer) dislikeRuleForOrg(writer http.ResponseWriter, request *http.Request) {
+	server.voteOnRuleForOrg(writer, request, types.UserVoteDislike)
+}
+
+// resetVoteOnRuleForOrg resets vote for the rule for the organization for
+// current user
+func (server *HTTPServer) resetVoteOnRuleForOrg(writer http.ResponseWriter, request *http.Request) {
+	server.voteOnRuleForOrg(writer, request, types.UserVoteNone)
+}
+
+func (server *HTTPServer) voteOnRuleForOrg(writer http.ResponseWriter, request *http.Request, userVote types.UserVote) {
+	orgID, ruleID, errorKey, successful := server.readOrgRuleParams(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	userID, successful := readUserID(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	voteMessage, successful := server.readFeedbackRequestBody(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	err := server.Storage.VoteOnRuleForOrg(orgID, ruleID, errorKey, userID, userVote, voteMessage)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to store vote on rule for organization")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponse())
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// saveFeedbackOnRuleForOrg stores feedback message left by current user on
+// the rule for the organization, the vote of the user is kept
+func (server *HTTPServer) saveFeedbackOnRuleForOrg(writer http.ResponseWriter, request *http.Request) {
+	orgID, ruleID, errorKey, successful := server.readOrgRuleParams(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	userID, successful := readUserID(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	message, err := server.getFeedbackMessageFromBody(request)
+	if err != nil {
+		handleServerError(writer, err)
+		return
+	}
+
+	err = server.Storage.AddOrUpdateFeedbackOnRuleForOrg(orgID, ruleID, errorKey, userID, message)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to store feedback on rule for organization")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("message", message))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// getUserFeedbackOnRuleForOrg returns vote and feedback left by current user
+// on the rule for the organization
+func (server *HTTPServer) getUserFeedbackOnRuleForOrg(writer http.ResponseWriter, request *http.Request) {
+	orgID, ruleID, errorKey, successful := server.readOrgRuleParams(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	userID, successful := readUserID(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	feedback, err := server.Storage.GetUserFeedbackOnRuleForOrg(orgID, ruleID, errorKey, userID)
+	if err != nil {
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("feedback", newOrgRuleFeedback(*feedback)))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// getFeedbackOnRuleForOrg returns votes and feedback left by all users of the
+// organization on the rule, the most recently updated first
+func (server *HTTPServer) getFeedbackOnRuleForOrg(writer http.ResponseWriter, request *http.Request) {
+	orgID, ruleID, errorKey, successful := server.readOrgRuleParams(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	feedbacks, err := server.Storage.GetFeedbackOnRuleForOrg(orgID, ruleID, errorKey)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read feedback on rule for organization")
+		handleServerError(writer, err)
+		return
+	}
+
+	response := make([]orgRuleFeedback, 0, len(feedbacks))
+	for _, feedback := range feedbacks {
+		response = append(response, newOrgRuleFeedback(feedback))
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("feedback", response))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
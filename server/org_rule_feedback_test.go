@@ -0,0 +1,175 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// orgRuleFeedbackResponse is the response of the endpoint returning feedback
+// of the user on rule for organization
+type orgRuleFeedbackResponse struct {
+	Feedback struct {
+		UserID   types.UserID   `json:"user_id"`
+		UserVote types.UserVote `json:"user_vote"`
+		Message  string         `json:"message"`
+	} `json:"feedback"`
+}
+
+func TestHTTPServer_VoteOnRuleForOrg(t *testing.T) {
+	expectedVotes := map[string]types.UserVote{
+		server.LikeRuleForOrgEndpoint:        types.UserVoteLike,
+		server.DislikeRuleForOrgEndpoint:     types.UserVoteDislike,
+		server.ResetVoteOnRuleForOrgEndpoint: types.UserVoteNone,
+	}
+
+	for endpoint, expectedVote := range expectedVotes {
+		func(endpoint string, expectedVote types.UserVote) {
+			mockStorage, closer := helpers.MustGetMockStorage(t, true)
+			defer closer()
+
+			helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+				Method:       http.MethodPut,
+				Endpoint:     endpoint,
+				EndpointArgs: []interface{}{testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID},
+				Body:         `{"message": "not relevant for our organization"}`,
+			}, &helpers.APIResponse{
+				StatusCode: http.StatusOK,
+				Body:       `{"status": "ok"}`,
+			})
+
+			feedback, err := mockStorage.GetUserFeedbackOnRuleForOrg(
+				testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID,
+			)
+			helpers.FailOnError(t, err)
+
+			assert.Equal(t, testdata.OrgID, feedback.OrgID)
+			assert.Equal(t, expectedVote, feedback.UserVote)
+			assert.Equal(t, "not relevant for our organization", feedback.Message)
+		}(endpoint, expectedVote)
+	}
+}
+
+func TestHTTPServer_FeedbackOnRuleForOrg(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.VoteOnRuleForOrg(
+		testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID, types.UserVoteLike, "",
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.UserFeedbackOnRuleForOrgEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID},
+		Body:         `{"message": "useful"}`,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"message": "useful", "status": "ok"}`,
+	})
+
+	// the vote is kept when the message is changed
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.UserFeedbackOnRuleForOrgEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got []byte) {
+			var response orgRuleFeedbackResponse
+			helpers.FailOnError(t, json.Unmarshal(got, &response))
+
+			assert.Equal(t, testdata.UserID, response.Feedback.UserID)
+			assert.Equal(t, types.UserVoteLike, response.Feedback.UserVote)
+			assert.Equal(t, "useful", response.Feedback.Message)
+		},
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.FeedbackOnRuleForOrgEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got []byte) {
+			var response struct {
+				Feedback []json.RawMessage `json:"feedback"`
+			}
+			helpers.FailOnError(t, json.Unmarshal(got, &response))
+			assert.Len(t, response.Feedback, 1)
+		},
+	})
+}
+
+func TestHTTPServer_FeedbackOnRuleForOrg_NotFound(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.UserFeedbackOnRuleForOrgEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+	})
+}
+
+func TestHTTPServer_VoteOnRuleForOrg_DBError(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorageWithFaults(t, true)
+	defer closer()
+
+	mockStorage.InjectFault("VoteOnRuleForOrg", helpers.Fault{Err: errors.New("database is unavailable")})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.LikeRuleForOrgEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+	})
+}
+
+func TestHTTPServer_VoteOnRuleForOrg_FrozenOrg(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	helpers.FailOnError(t, mockStorage.FreezeOrg(testdata.OrgID, "investigation"))
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.LikeRuleForOrgEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusLocked,
+	})
+
+	// feedback can still be read
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.FeedbackOnRuleForOrgEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"feedback": [], "status": "ok"}`,
+	})
+}
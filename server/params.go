@@ -0,0 +1,339 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// paramSource says where the value of the endpoint argument is taken from
+type paramSource int
+
+const (
+	// pathParam is taken from the path of the endpoint, it is always required
+	pathParam paramSource = iota
+	// queryParam is taken from the query of the request, it is optional and
+	// the variable keeps its value when the parameter is not specified
+	queryParam
+	// optionalPathParam is taken from the path of the endpoint that is
+	// registered also without the parameter, the variable keeps its value
+	// when the parameter is not in the path
+	optionalPathParam
+)
+
+// errEmptyParam is returned by the parse function of the parameter when its
+// value is empty, it is reported the same way as missing path parameter
+var errEmptyParam = errors.New("empty value")
+
+// param defines one argument of the endpoint: its name, where it is taken
+// from and the function validating the raw value and storing it into the
+// variable of the handler. The error returned by the parse function is sent
+// to the client as the reason of 400 Bad Request response.
+type param struct {
+	name   string
+	source paramSource
+	parse  func(rawValue string) error
+}
+
+// readParams reads all arguments of the endpoint in the given order, for
+// example:
+//
+//	var (
+//		clusterName types.ClusterName
+//		ruleID      types.RuleID
+//		errorKey    types.ErrorKey
+//	)
+//	if !readParams(writer, request,
+//		clusterNameParam(&clusterName), ruleIDParam(&ruleID), errorKeyParam(&errorKey),
+//	) {
+//		return
+//	}
+//
+// if it's not possible, it writes http error for the first invalid argument
+// to the writer and returns false
+func readParams(writer http.ResponseWriter, request *http.Request, params ...param) bool {
+	for _, param := range params {
+		if err := param.read(request); err != nil {
+			handleServerError(writer, err)
+			return false
+		}
+	}
+
+	return true
+}
+
+// read reads and validates value of the parameter from the request
+func (param param) read(request *http.Request) error {
+	var rawValue string
+
+	switch param.source {
+	case pathParam:
+		value, found := mux.Vars(request)[param.name]
+		if !found {
+			return &RouterMissingParamError{ParamName: param.name}
+		}
+		rawValue = value
+	case queryParam:
+		rawValue = request.URL.Query().Get(param.name)
+		if rawValue == "" {
+			return nil
+		}
+	case optionalPathParam:
+		value, found := mux.Vars(request)[param.name]
+		if !found {
+			return nil
+		}
+		rawValue = value
+	}
+
+	err := param.parse(rawValue)
+	switch {
+	case err == errEmptyParam:
+		return &RouterMissingParamError{ParamName: param.name}
+	case err != nil:
+		return &RouterParsingError{
+			ParamName:  param.name,
+			ParamValue: rawValue,
+			ErrString:  err.Error(),
+		}
+	}
+
+	return nil
+}
+
+// parsePositiveInt parses positive integer
+func parsePositiveInt(rawValue string) (uint64, error) {
+	value, err := strconv.ParseUint(rawValue, 10, 64)
+	if err != nil {
+		return 0, errors.New("unsigned integer expected")
+	}
+
+	if value == 0 {
+		return 0, errors.New("positive value expected")
+	}
+
+	return value, nil
+}
+
+// orgIDParamNamed defines path parameter with ID of the organization
+func orgIDParamNamed(name string, orgID *types.OrgID) param {
+	return param{
+		name:   name,
+		source: pathParam,
+		parse: func(rawValue string) error {
+			value, err := parsePositiveInt(rawValue)
+			if err != nil {
+				return err
+			}
+
+			*orgID = types.OrgID(value)
+			return nil
+		},
+	}
+}
+
+// orgIDParam defines path parameter org_id with ID of the organization
+func orgIDParam(orgID *types.OrgID) param {
+	return orgIDParamNamed("org_id", orgID)
+}
+
+// organizationParam defines path parameter organization with ID of the
+// organization, permissions of the user to access it are not checked
+func organizationParam(orgID *types.OrgID) param {
+	return orgIDParamNamed("organization", orgID)
+}
+
+// clusterNameParam defines path parameter cluster with UUID of the cluster,
+// the UUID is converted to canonical form (lowercase, hyphenated), so the
+// cluster is found regardless of the case of the UUID in the request
+func clusterNameParam(clusterName *types.ClusterName) param {
+	return param{
+		name:   "cluster",
+		source: pathParam,
+		parse: func(rawValue string) error {
+			clusterUUID, err := uuid.Parse(rawValue)
+			if err != nil {
+				log.Error().Err(err).Msgf("invalid cluster name: '%s'. Error: %s", rawValue, err.Error())
+				return err
+			}
+
+			*clusterName = types.ClusterName(clusterUUID.String())
+			return nil
+		},
+	}
+}
+
+// userIDParam defines path parameter user_id, surrounding white spaces are
+// removed
+func userIDParam(userID *types.UserID) param {
+	return param{
+		name:   "user_id",
+		source: pathParam,
+		parse: func(rawValue string) error {
+			rawValue = strings.TrimSpace(rawValue)
+			if rawValue == "" {
+				return errEmptyParam
+			}
+
+			*userID = types.UserID(rawValue)
+			return nil
+		},
+	}
+}
+
+// ruleIDParam defines path parameter rule_id with ID of the rule
+func ruleIDParam(ruleID *types.RuleID) param {
+	return param{
+		name:   "rule_id",
+		source: pathParam,
+		parse: func(rawValue string) error {
+			if !ruleIDValidator.MatchString(rawValue) {
+				return errors.New(
+					"invalid rule ID, it must contain only from latin characters, number, underscores or dots",
+				)
+			}
+
+			*ruleID = types.RuleID(rawValue)
+			return nil
+		},
+	}
+}
+
+// errorKeyParam defines path parameter error_key, any value is accepted
+func errorKeyParam(errorKey *types.ErrorKey) param {
+	return param{
+		name:   "error_key",
+		source: pathParam,
+		parse: func(rawValue string) error {
+			*errorKey = types.ErrorKey(rawValue)
+			return nil
+		},
+	}
+}
+
+// ruleIDWithErrorKeyParam defines path parameter rule_id with ID of the rule
+// and error key separated by |
+func ruleIDWithErrorKeyParam(ruleID *types.RuleID, errorKey *types.ErrorKey) param {
+	return param{
+		name:   "rule_id",
+		source: pathParam,
+		parse: func(rawValue string) error {
+			parts := strings.Split(rawValue, "|")
+			if len(parts) != 2 {
+				return fmt.Errorf("invalid rule ID, it must contain only rule ID and error key separated by |")
+			}
+
+			if !ruleIDValidator.MatchString(parts[0]) || !ruleIDValidator.MatchString(parts[1]) {
+				return fmt.Errorf(
+					"invalid rule ID, each part of ID must contain only from latin characters, number, underscores or dots",
+				)
+			}
+
+			*ruleID = types.RuleID(parts[0])
+			*errorKey = types.ErrorKey(parts[1])
+			return nil
+		},
+	}
+}
+
+// uuidParam defines path parameter with UUID
+func uuidParam(name string, value *string) param {
+	return param{
+		name:   name,
+		source: pathParam,
+		parse: func(rawValue string) error {
+			if _, err := uuid.Parse(rawValue); err != nil {
+				return errors.New("UUID expected")
+			}
+
+			*value = rawValue
+			return nil
+		},
+	}
+}
+
+// boolQueryParam defines optional boolean query parameter
+func boolQueryParam(name string, value *bool) param {
+	return param{
+		name:   name,
+		source: queryParam,
+		parse: func(rawValue string) error {
+			boolValue, err := strconv.ParseBool(rawValue)
+			if err != nil {
+				return errors.New("boolean value expected")
+			}
+
+			*value = boolValue
+			return nil
+		},
+	}
+}
+
+// uintQueryParam defines optional unsigned integer query parameter, present
+// is set to true when the parameter is specified and present is not nil
+func uintQueryParam(name string, value *int, present *bool) param {
+	return param{
+		name:   name,
+		source: queryParam,
+		parse: func(rawValue string) error {
+			parsed, err := strconv.ParseUint(rawValue, 10, 31)
+			if err != nil {
+				return errors.New("unsigned integer expected")
+			}
+
+			*value = int(parsed)
+			if present != nil {
+				*present = true
+			}
+			return nil
+		},
+	}
+}
+
+// positiveIntQueryParam defines optional positive integer query parameter,
+// present is set to true when the parameter is specified and present is not
+// nil
+func positiveIntQueryParam(name string, value *int, present *bool) param {
+	return param{
+		name:   name,
+		source: queryParam,
+		parse: func(rawValue string) error {
+			parsed, err := strconv.ParseUint(rawValue, 10, 31)
+			if err != nil {
+				return errors.New("unsigned integer expected")
+			}
+
+			if parsed == 0 {
+				return errors.New("positive integer expected")
+			}
+
+			*value = int(parsed)
+			if present != nil {
+				*present = true
+			}
+			return nil
+		},
+	}
+}
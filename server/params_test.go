@@ -0,0 +1,176 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// readParamsResponse reads parameters of the request with cluster, org_id,
+// user_id and rule_id path parameters and verbose query parameter
+func readParamsResponse(t *testing.T, vars map[string]string, url string) (*http.Response, bool) {
+	var (
+		clusterName types.ClusterName
+		orgID       types.OrgID
+		userID      types.UserID
+		ruleID      types.RuleID
+		errorKey    types.ErrorKey
+		verbose     = true
+	)
+
+	request := mustGetRequestWithMuxVars(t, http.MethodGet, url, nil, vars)
+	recorder := httptest.NewRecorder()
+
+	successful := server.ReadParams(recorder, request,
+		server.ClusterNameParam(&clusterName),
+		server.OrgIDParam(&orgID),
+		server.UserIDParam(&userID),
+		server.RuleIDWithErrorKeyParam(&ruleID, &errorKey),
+		server.BoolQueryParam("verbose", &verbose),
+	)
+
+	if successful {
+		assert.Equal(t, types.ClusterName(cluster1ID), clusterName)
+		assert.Equal(t, types.OrgID(42), orgID)
+		assert.Equal(t, types.UserID("user"), userID)
+		assert.Equal(t, types.RuleID("rule.module"), ruleID)
+		assert.Equal(t, types.ErrorKey("ERROR_KEY"), errorKey)
+		assert.Equal(t, !strings.Contains(url, "verbose=false"), verbose)
+	}
+
+	return recorder.Result(), successful
+}
+
+// assertParamsError checks that the request with the given parameters is
+// rejected with the given reason
+func assertParamsError(t *testing.T, vars map[string]string, url, expectedStatus string) {
+	response, successful := readParamsResponse(t, vars, url)
+	assert.False(t, successful)
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+
+	body, err := ioutil.ReadAll(response.Body)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, `{"status":"`+expectedStatus+`"}`, strings.TrimSpace(string(body)))
+}
+
+func validParamsVars() map[string]string {
+	return map[string]string{
+		"cluster": cluster1ID,
+		"org_id":  "42",
+		"user_id": " user ",
+		"rule_id": "rule.module|ERROR_KEY",
+	}
+}
+
+func TestReadParams(t *testing.T) {
+	for _, url := range []string{"", "?verbose=true", "?verbose=false"} {
+		_, successful := readParamsResponse(t, validParamsVars(), url)
+		assert.True(t, successful, url)
+	}
+}
+
+// TestReadParamsClusterNameCanonical checks that the cluster name is
+// converted to canonical lowercase form
+func TestReadParamsClusterNameCanonical(t *testing.T) {
+	vars := validParamsVars()
+	vars["cluster"] = strings.ToUpper(cluster1ID)
+
+	_, successful := readParamsResponse(t, vars, "")
+	assert.True(t, successful)
+}
+
+func TestReadParamsMissing(t *testing.T) {
+	vars := validParamsVars()
+	delete(vars, "org_id")
+
+	assertParamsError(t, vars, "", "Missing required param from request: org_id")
+}
+
+func TestReadParamsEmptyUserID(t *testing.T) {
+	vars := validParamsVars()
+	vars["user_id"] = "  "
+
+	assertParamsError(t, vars, "", "Missing required param from request: user_id")
+}
+
+func TestReadParamsInvalid(t *testing.T) {
+	vars := validParamsVars()
+	vars["org_id"] = "0"
+
+	assertParamsError(t, vars, "",
+		"Error during parsing param 'org_id' with value '0'. Error: 'positive value expected'",
+	)
+
+	vars = validParamsVars()
+	vars["rule_id"] = "rule.module"
+
+	assertParamsError(t, vars, "",
+		"Error during parsing param 'rule_id' with value 'rule.module'. "+
+			"Error: 'invalid rule ID, it must contain only rule ID and error key separated by |'",
+	)
+
+	assertParamsError(t, validParamsVars(), "?verbose=maybe",
+		"Error during parsing param 'verbose' with value 'maybe'. Error: 'boolean value expected'",
+	)
+}
+
+func TestReadParamsIntegerQuery(t *testing.T) {
+	readLimits := func(url string) (limit, offset int, offsetPresent bool, response *http.Response) {
+		limit, offset = 10, 20
+		request := mustGetRequestWithMuxVars(t, http.MethodGet, url, nil, nil)
+		recorder := httptest.NewRecorder()
+
+		server.ReadParams(recorder, request,
+			server.PositiveIntQueryParam("limit", &limit, nil),
+			server.UintQueryParam("offset", &offset, &offsetPresent),
+		)
+
+		return limit, offset, offsetPresent, recorder.Result()
+	}
+
+	limit, offset, offsetPresent, response := readLimits("")
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, 10, limit)
+	assert.Equal(t, 20, offset)
+	assert.False(t, offsetPresent)
+
+	limit, offset, offsetPresent, response = readLimits("?limit=5&offset=0")
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, 5, limit)
+	assert.Equal(t, 0, offset)
+	assert.True(t, offsetPresent)
+
+	for url, expectedStatus := range map[string]string{
+		"?limit=0":   "Error during parsing param 'limit' with value '0'. Error: 'positive integer expected'",
+		"?offset=-1": "Error during parsing param 'offset' with value '-1'. Error: 'unsigned integer expected'",
+	} {
+		_, _, _, response = readLimits(url)
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode, url)
+
+		body, err := ioutil.ReadAll(response.Body)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, `{"status":"`+expectedStatus+`"}`, strings.TrimSpace(string(body)), url)
+	}
+}
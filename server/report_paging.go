@@ -0,0 +1,99 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const (
+	// reportLimitQueryParam is the maximal number of rules returned in the
+	// report
+	reportLimitQueryParam = "limit"
+	// reportOffsetQueryParam is the number of rules skipped in the report
+	reportOffsetQueryParam = "offset"
+)
+
+// reportPaging describes the page of rules returned in the report or the page
+// of other lists, limit 0 means all remaining items are returned
+type reportPaging struct {
+	Offset int `json:"offset"`
+	Limit  int `json:"limit,omitempty"`
+	Total  int `json:"total"`
+}
+
+// readUintQueryParam parses the unsigned integer query parameter, present is
+// false when the parameter is not specified
+// if it's not possible, it writes http error to the writer and returns false
+func readUintQueryParam(
+	writer http.ResponseWriter, request *http.Request, paramName string,
+) (value int, present, successful bool) {
+	successful = readParams(writer, request, uintQueryParam(paramName, &value, &present))
+
+	return value, present, successful
+}
+
+// readReportPagingQueryParams retrieves the page of rules requested in the
+// report, nil is returned when neither limit nor offset is specified
+// if it's not possible, it writes http error to the writer and returns false
+func readReportPagingQueryParams(writer http.ResponseWriter, request *http.Request) (*reportPaging, bool) {
+	var (
+		paging                      reportPaging
+		limitPresent, offsetPresent bool
+	)
+	if !readParams(writer, request,
+		positiveIntQueryParam(reportLimitQueryParam, &paging.Limit, &limitPresent),
+		uintQueryParam(reportOffsetQueryParam, &paging.Offset, &offsetPresent),
+	) {
+		return nil, false
+	}
+
+	if !limitPresent && !offsetPresent {
+		return nil, true
+	}
+
+	return &paging, true
+}
+
+// pageRules sorts the rules by rule ID and error key, so the pages are
+// stable, and returns the requested page of them. Total number of rules is
+// stored in the paging.
+func (paging *reportPaging) pageRules(rules []types.RuleOnReport) []types.RuleOnReport {
+	types.SortRulesOnReport(rules)
+
+	start, end := paging.page(len(rules))
+
+	return rules[start:end]
+}
+
+// page returns bounds of the requested page of the list with the given
+// number of already sorted items, the number is stored in the paging as the
+// total
+func (paging *reportPaging) page(total int) (start, end int) {
+	paging.Total = total
+
+	if paging.Offset >= total {
+		return total, total
+	}
+
+	end = total
+	if paging.Limit > 0 && paging.Offset+paging.Limit < end {
+		end = paging.Offset + paging.Limit
+	}
+
+	return paging.Offset, end
+}
@@ -0,0 +1,140 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+)
+
+const (
+	// reportTooLargeMessage is returned in body of response when the report
+	// exceeds the configured maximum size
+	reportTooLargeMessage = "Report is too large, request it in pages"
+	// reportsTooLargeMessage is returned in body of response when the reports
+	// for list of clusters exceed the configured maximum size
+	reportsTooLargeMessage = "Reports are too large, request them for fewer clusters"
+	// defaultReportPageLimit is the limit of rules in the paginated URL
+	// proposed when the report without paging is too large
+	defaultReportPageLimit = 100
+)
+
+// reportTooLargeResponse is the body of 413 response sent instead of
+// a report that exceeds the configured maximum size
+type reportTooLargeResponse struct {
+	Status       string `json:"status"`
+	Size         int    `json:"size"`
+	MaxSize      int    `json:"max_size"`
+	PaginatedURL string `json:"paginated_url,omitempty"`
+}
+
+// isSizeLimitedEndpoint returns true for endpoints
+// returning cluster reports, which size is limited by
+// Config.MaxReportResponseSize
+func isSizeLimitedEndpoint(endpoint string) bool {
+	switch endpoint {
+	case ReportEndpoint, ReportForListOfClustersEndpoint, ReportForListOfClustersPayloadEndpoint:
+		return true
+	}
+
+	return false
+}
+
+// paginatedReportURL returns URL of the first page of the report that is
+// smaller than the page requested (or the whole report when paging was not
+// requested)
+func paginatedReportURL(request *http.Request) string {
+	limit := defaultReportPageLimit
+
+	// the limit was validated by the handler already
+	var requestedLimit int
+	err := positiveIntQueryParam(reportLimitQueryParam, &requestedLimit, nil).read(request)
+	if err == nil && requestedLimit > 0 && requestedLimit/2 < limit {
+		limit = requestedLimit / 2
+		if limit == 0 {
+			limit = 1
+		}
+	}
+
+	paginatedURL := *request.URL
+	query := paginatedURL.Query()
+	query.Set(reportLimitQueryParam, strconv.Itoa(limit))
+	query.Set(reportOffsetQueryParam, "0")
+	paginatedURL.RawQuery = query.Encode()
+
+	return paginatedURL.RequestURI()
+}
+
+// LimitReportSize is a middleware that replaces successful responses of
+// report endpoints larger than Config.MaxReportResponseSize by 413 Request
+// Entity Too Large pointing to the paginated variant of the endpoint, so huge
+// reports don't break proxies in front of the service
+func (server *HTTPServer) LimitReportSize(nextHandler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		maxSize := server.Config.MaxReportResponseSize
+		endpoint := server.routeEndpoint(request)
+		if maxSize <= 0 || !isSizeLimitedEndpoint(endpoint) {
+			nextHandler.ServeHTTP(writer, request)
+			return
+		}
+
+		buffered := &bufferedWriter{header: make(http.Header)}
+		nextHandler.ServeHTTP(buffered, request)
+
+		if buffered.status == 0 {
+			buffered.status = http.StatusOK
+		}
+
+		body := buffered.body.Bytes()
+
+		if buffered.status == http.StatusOK && len(body) > maxSize {
+			metrics.TooLargeReportResponses.WithLabelValues(endpoint).Inc()
+			log.Warn().
+				Str("url", request.URL.String()).
+				Int("size", len(body)).
+				Int("max_size", maxSize).
+				Msg("Report response exceeds maximum size")
+
+			response := reportTooLargeResponse{
+				Status:  reportsTooLargeMessage,
+				Size:    len(body),
+				MaxSize: maxSize,
+			}
+			if endpoint == ReportEndpoint {
+				response.Status = reportTooLargeMessage
+				response.PaginatedURL = paginatedReportURL(request)
+			}
+
+			if err := responses.Send(http.StatusRequestEntityTooLarge, writer, response); err != nil {
+				log.Error().Err(err).Msg(responseDataError)
+			}
+			return
+		}
+
+		for key, values := range buffered.header {
+			writer.Header()[key] = values
+		}
+		writer.WriteHeader(buffered.status)
+
+		if _, err := writer.Write(body); err != nil {
+			log.Error().Err(err).Msg(responseDataError)
+		}
+	})
+}
@@ -0,0 +1,121 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	httputils "github.com/RedHatInsights/insights-operator-utils/http"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+)
+
+// reportTooLargeBody is the body of 413 response of report endpoints
+type reportTooLargeBody struct {
+	Status       string `json:"status"`
+	Size         int    `json:"size"`
+	MaxSize      int    `json:"max_size"`
+	PaginatedURL string `json:"paginated_url"`
+}
+
+// executeSizeLimitedRequest sends GET request to the endpoint of server with
+// the maximum report response size configured
+func executeSizeLimitedRequest(
+	t *testing.T, maxSize int, endpoint, query string, args ...interface{},
+) *http.Response {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	writeCachingReport(t, mockStorage)
+
+	config := helpers.DefaultServerConfig
+	config.MaxReportResponseSize = maxSize
+
+	url := httputils.MakeURLToEndpoint(config.APIPrefix, endpoint, args...) + query
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	helpers.FailOnError(t, err)
+
+	return helpers.ExecuteRequest(server.New(config, mockStorage), request).Result()
+}
+
+func readReportTooLargeBody(t *testing.T, response *http.Response) reportTooLargeBody {
+	var body reportTooLargeBody
+	helpers.FailOnError(t, json.NewDecoder(response.Body).Decode(&body))
+	return body
+}
+
+func TestLimitReportSize_Unlimited(t *testing.T) {
+	response := executeSizeLimitedRequest(
+		t, 0, server.ReportEndpoint, "", testdata.OrgID, testdata.ClusterName, testdata.UserID,
+	)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+}
+
+func TestLimitReportSize_UnderLimit(t *testing.T) {
+	response := executeSizeLimitedRequest(
+		t, 1024*1024, server.ReportEndpoint, "", testdata.OrgID, testdata.ClusterName, testdata.UserID,
+	)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+}
+
+func TestLimitReportSize_Report(t *testing.T) {
+	response := executeSizeLimitedRequest(
+		t, 10, server.ReportEndpoint, "", testdata.OrgID, testdata.ClusterName, testdata.UserID,
+	)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, response.StatusCode)
+
+	body := readReportTooLargeBody(t, response)
+	assert.Equal(t, 10, body.MaxSize)
+	assert.Greater(t, body.Size, 10)
+
+	paginatedURL, err := url.Parse(body.PaginatedURL)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, "100", paginatedURL.Query().Get("limit"))
+	assert.Equal(t, "0", paginatedURL.Query().Get("offset"))
+}
+
+func TestLimitReportSize_ReportPageTooLarge(t *testing.T) {
+	response := executeSizeLimitedRequest(
+		t, 10, server.ReportEndpoint, "?limit=3&offset=1", testdata.OrgID, testdata.ClusterName, testdata.UserID,
+	)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, response.StatusCode)
+
+	paginatedURL, err := url.Parse(readReportTooLargeBody(t, response).PaginatedURL)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, "1", paginatedURL.Query().Get("limit"))
+	assert.Equal(t, "0", paginatedURL.Query().Get("offset"))
+}
+
+func TestLimitReportSize_ReportsForListOfClusters(t *testing.T) {
+	response := executeSizeLimitedRequest(
+		t, 10, server.ReportForListOfClustersEndpoint, "", testdata.OrgID, testdata.ClusterName,
+	)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, response.StatusCode)
+
+	body := readReportTooLargeBody(t, response)
+	assert.Equal(t, 10, body.MaxSize)
+	assert.Empty(t, body.PaginatedURL)
+}
+
+func TestLimitReportSize_OtherEndpoint(t *testing.T) {
+	response := executeSizeLimitedRequest(t, 10, server.MainEndpoint, "")
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+}
@@ -0,0 +1,55 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import "github.com/RedHatInsights/insights-results-aggregator/types"
+
+// reportResponseMeta is the report meta extended by the status of the
+// analysis of the cluster, the status is set only when report_analysis_status
+// is enabled and no rule is hit. Paging is set only when a page of rules is
+// requested. Display name is set only when it's known to the inventory.
+type reportResponseMeta struct {
+	types.ReportResponseMeta
+	AnalysisStatus types.ReportStatus `json:"analysis_status,omitempty"`
+	Paging         *reportPaging      `json:"paging,omitempty"`
+	DisplayName    string             `json:"display_name,omitempty"`
+}
+
+// reportResponse is the report response with the status of the analysis in
+// the meta
+type reportResponse struct {
+	Meta   reportResponseMeta `json:"meta"`
+	Report []ruleOnReport     `json:"reports"`
+}
+
+// readReportAnalysisStatus returns the status of the analysis of the cluster
+// report without rule hits. Empty status is returned when the status is not
+// requested by the configuration or some rule is hit.
+func (server *HTTPServer) readReportAnalysisStatus(
+	orgID types.OrgID, clusterName types.ClusterName, hitRulesCount int,
+) (types.ReportStatus, error) {
+	if !server.Config.ReportAnalysisStatus || hitRulesCount != 0 {
+		return "", nil
+	}
+
+	return server.Storage.ReadReportStatusForCluster(orgID, clusterName)
+}
+
+// isReportNotFoundWithStatus checks whether the missing report of the
+// cluster should be returned as empty report with no_data status
+func (server *HTTPServer) isReportNotFoundWithStatus(err error) bool {
+	_, itemNotFound := err.(*types.ItemNotFoundError)
+	return itemNotFound && server.Config.ReportAnalysisStatus
+}
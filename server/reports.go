@@ -138,6 +138,16 @@ func fillInGeneratedReports(clusterNames []types.ClusterName, reports map[types.
 func processListOfClusters(server *HTTPServer, writer http.ResponseWriter, request *http.Request, orgID types.OrgID, clusters []string) {
 	log.Info().Int("number of clusters", len(clusters)).Str("list", strings.Join(clusters, ", ")).Msg("processListOfClusters")
 
+	canonical, successful := readCanonicalQueryParam(writer, request)
+	if !successful {
+		return
+	}
+
+	class, successful := readClusterClassQueryParam(writer, request)
+	if !successful {
+		return
+	}
+
 	// first step: check if all cluster IDs have proper format
 	for _, clusterID := range clusters {
 		// all clusters should be identified by proper ID
@@ -150,20 +160,32 @@ func processListOfClusters(server *HTTPServer, writer http.ResponseWriter, reque
 	log.Debug().Msg("all clusters have proper UUID format")
 
 	clusterNames := constructClusterNames(clusters)
-	orgIDs, err := server.Storage.ReadOrgIDsForClusters(clusterNames)
+	orgIDs, err := server.Storage.ReadOrgIDsOfClusters(clusterNames)
 	if err != nil {
-		log.Error().Err(err).Msg("try to read org IDs for list of clusters")
+		sendDBErrorResponse(writer, err)
+		return
 	}
 
-	// second step: check if all clusters belongs to given organization ID
-	for _, id := range orgIDs {
+	// second step: check if all clusters belongs to given organization ID,
+	// unknown clusters are reported in the response
+	for clusterName, id := range orgIDs {
 		if id != orgID {
+			log.Error().Str("cluster", string(clusterName)).Msg("cluster belongs to another organization")
 			sendWrongClusterOrgIDResponse(writer, id)
 			return
 		}
 	}
 	log.Debug().Msg("all clusters have proper organization ID")
 
+	if class != "" {
+		classes, err := server.Storage.ReadClusterClasses(orgID)
+		if err != nil {
+			sendDBErrorResponse(writer, err)
+			return
+		}
+		clusterNames = filterClustersByClass(clusterNames, classes, class)
+	}
+
 	reports, err := server.Storage.ReadReportsForClusters(clusterNames)
 	if err != nil {
 		sendDBErrorResponse(writer, err)
@@ -172,7 +194,12 @@ func processListOfClusters(server *HTTPServer, writer http.ResponseWriter, reque
 
 	generatedReports := fillInGeneratedReports(clusterNames, reports)
 
-	bytes, err := json.MarshalIndent(generatedReports, "", "\t")
+	var bytes []byte
+	if canonical {
+		bytes, err = types.MarshalCanonical(generatedReports)
+	} else {
+		bytes, err = json.MarshalIndent(generatedReports, "", "\t")
+	}
 	if err != nil {
 		sendMarshallErrorResponse(writer, err)
 		return
@@ -231,3 +258,47 @@ func (server *HTTPServer) reportForListOfClustersPayload(writer http.ResponseWri
 	// we were able to read the cluster IDs, let's process them
 	processListOfClusters(server, writer, request, orgID, listOfClusters)
 }
+
+// organizationReport function returns report merged from reports of all
+// clusters of the organization: every rule hit by any of its clusters with the
+// list of affected clusters. Clusters where the rule is disabled are listed
+// separately.
+func (server *HTTPServer) organizationReport(writer http.ResponseWriter, request *http.Request) {
+	organizationID, successful := readOrganizationID(writer, request, server.Config.Auth)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	canonical, successful := readCanonicalQueryParam(writer, request)
+	if !successful {
+		return
+	}
+
+	class, successful := readClusterClassQueryParam(writer, request)
+	if !successful {
+		return
+	}
+
+	report, err := server.Storage.ReadOrgReport(organizationID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read report of organization")
+		handleServerError(writer, err)
+		return
+	}
+
+	if class != "" {
+		classes, err := server.Storage.ReadClusterClasses(organizationID)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to read classes of clusters")
+			handleServerError(writer, err)
+			return
+		}
+		report = filterOrgReportByClass(report, classes, class)
+	}
+
+	err = sendOKResponse(writer, canonical, responses.BuildOkResponseWithData("report", report))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
@@ -202,3 +202,59 @@ func TestReadReportsForClustersPayloadPositiveOrgID(t *testing.T) {
 		}`,
 	})
 }
+
+// TestOrganizationReport checks that the report merged from reports of all
+// clusters of the organization is returned
+func TestOrganizationReport(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	rule1 := types.ReportItem{Module: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1, TemplateData: []byte("{}")}
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.ClusterReportEmpty, []types.ReportItem{rule1}, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.OrganizationReportEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: helpers.ToJSONString(map[string]interface{}{
+			"report": []types.OrgReportRule{{
+				RuleID:           testdata.Rule1ID,
+				ErrorKey:         testdata.ErrorKey1,
+				Clusters:         []types.ClusterName{testdata.ClusterName},
+				DisabledClusters: []types.ClusterName{},
+			}},
+			"status": "ok",
+		}),
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.OrganizationReportEndpoint,
+		EndpointArgs: []interface{}{testdata.Org2ID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"report": [], "status": "ok"}`,
+	})
+}
+
+// TestOrganizationReportDBError checks that DB error is reported as internal
+// server error
+func TestOrganizationReportDBError(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	closer()
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.OrganizationReportEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status": "Internal Server Error"}`,
+	})
+}
@@ -17,13 +17,11 @@ package server
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"net/http"
 	"regexp"
 	"strings"
 
 	httputils "github.com/RedHatInsights/insights-operator-utils/http"
-	"github.com/rs/zerolog/log"
 
 	"github.com/RedHatInsights/insights-results-aggregator/types"
 )
@@ -34,48 +32,62 @@ type ClusterList struct {
 }
 
 var (
-	readRuleID                = httputils.ReadRuleID
-	readErrorKey              = httputils.ReadErrorKey
 	getRouterParam            = httputils.GetRouterParam
 	getRouterPositiveIntParam = httputils.GetRouterPositiveIntParam
 	validateClusterName       = httputils.ValidateClusterName
 	splitRequestParamArray    = httputils.SplitRequestParamArray
 	handleOrgIDError          = httputils.HandleOrgIDError
-	readClusterName           = httputils.ReadClusterName
-	readOrganizationID        = httputils.ReadOrganizationID
 	checkPermissions          = httputils.CheckPermissions
 	readClusterNames          = httputils.ReadClusterNames
 	readOrganizationIDs       = httputils.ReadOrganizationIDs
 )
 
+// ruleIDValidator checks format of rule IDs and error keys
+var ruleIDValidator = regexp.MustCompile(`^[a-zA-Z_0-9.]+$`)
+
 // readUserID retrieves user_id from request
 // if it's not possible, it writes http error to the writer and returns false
-func readUserID(writer http.ResponseWriter, request *http.Request) (types.UserID, bool) {
-	userID, err := getRouterParam(request, "user_id")
-	if err != nil {
-		handleServerError(writer, err)
-		return "", false
-	}
-
-	userID = strings.TrimSpace(userID)
-	if len(userID) == 0 {
-		handleServerError(writer, &RouterMissingParamError{ParamName: "user_id"})
-		return "", false
-	}
-
-	return types.UserID(userID), true
+func readUserID(writer http.ResponseWriter, request *http.Request) (userID types.UserID, successful bool) {
+	return userID, readParams(writer, request, userIDParam(&userID))
 }
 
 // readOrgID retrieves org_id from request
 // if it's not possible, it writes http error to the writer and returns false
-func readOrgID(writer http.ResponseWriter, request *http.Request) (types.OrgID, bool) {
-	orgID, err := getRouterPositiveIntParam(request, "org_id")
-	if err != nil {
-		handleServerError(writer, err)
+func readOrgID(writer http.ResponseWriter, request *http.Request) (orgID types.OrgID, successful bool) {
+	return orgID, readParams(writer, request, orgIDParam(&orgID))
+}
+
+// readOrganizationID retrieves organization from request and checks the user
+// has access to it when auth is enabled
+// if it's not possible, it writes http error to the writer and returns false
+func readOrganizationID(
+	writer http.ResponseWriter, request *http.Request, auth bool,
+) (orgID types.OrgID, successful bool) {
+	if !readParams(writer, request, organizationParam(&orgID)) {
 		return 0, false
 	}
 
-	return types.OrgID(orgID), true
+	return orgID, checkPermissions(writer, request, orgID, auth)
+}
+
+// readClusterName retrieves cluster from request
+// if it's not possible, it writes http error to the writer and returns false
+func readClusterName(
+	writer http.ResponseWriter, request *http.Request,
+) (clusterName types.ClusterName, successful bool) {
+	return clusterName, readParams(writer, request, clusterNameParam(&clusterName))
+}
+
+// readRuleID retrieves rule_id from request
+// if it's not possible, it writes http error to the writer and returns false
+func readRuleID(writer http.ResponseWriter, request *http.Request) (ruleID types.RuleID, successful bool) {
+	return ruleID, readParams(writer, request, ruleIDParam(&ruleID))
+}
+
+// readErrorKey retrieves error_key from request
+// if it's not possible, it writes http error to the writer and returns false
+func readErrorKey(writer http.ResponseWriter, request *http.Request) (errorKey types.ErrorKey, successful bool) {
+	return errorKey, readParams(writer, request, errorKeyParam(&errorKey))
 }
 
 // readClusterListFromPath retrieves list of clusters from request's path
@@ -123,115 +135,35 @@ func readClusterListFromBody(writer http.ResponseWriter, request *http.Request)
 	return clusterList.Clusters, true
 }
 
-func readRuleIDWithErrorKey(writer http.ResponseWriter, request *http.Request) (types.RuleID, types.ErrorKey, bool) {
-	ruleIDWithErrorKey, err := getRouterParam(request, "rule_id")
-	if err != nil {
-		const message = "unable to get rule id"
-		log.Error().Err(err).Msg(message)
-		handleServerError(writer, err)
-		return types.RuleID("0"), types.ErrorKey("0"), false
-	}
-
-	splitedRuleID := strings.Split(string(ruleIDWithErrorKey), "|")
-
-	if len(splitedRuleID) != 2 {
-		err = fmt.Errorf("invalid rule ID, it must contain only rule ID and error key separated by |")
-		log.Error().Err(err)
-		handleServerError(writer, &RouterParsingError{
-			ParamName:  "rule_id",
-			ParamValue: ruleIDWithErrorKey,
-			ErrString:  err.Error(),
-		})
-		return types.RuleID("0"), types.ErrorKey("0"), false
-	}
-
-	IDValidator := regexp.MustCompile(`^[a-zA-Z_0-9.]+$`)
-
-	isRuleIDValid := IDValidator.Match([]byte(splitedRuleID[0]))
-	isErrorKeyValid := IDValidator.Match([]byte(splitedRuleID[1]))
-
-	if !isRuleIDValid || !isErrorKeyValid {
-		err = fmt.Errorf("invalid rule ID, each part of ID must contain only from latin characters, number, underscores or dots")
-		log.Error().Err(err)
-		handleServerError(writer, &RouterParsingError{
-			ParamName:  "rule_id",
-			ParamValue: ruleIDWithErrorKey,
-			ErrString:  err.Error(),
-		})
-		return types.RuleID("0"), types.ErrorKey("0"), false
-	}
-
-	return types.RuleID(splitedRuleID[0]), types.ErrorKey(splitedRuleID[1]), true
-}
-
-// readClusterRuleUserParams gets cluster_name, rule_id and user_id from current request
+// readClusterRuleUserParams gets cluster_name, rule_id and user_id from current
+// request and checks the user has access to the cluster
 func (server *HTTPServer) readClusterRuleUserParams(
 	writer http.ResponseWriter, request *http.Request,
-) (types.ClusterName, types.RuleID, types.UserID, bool) {
-	clusterID, successful := readClusterName(writer, request)
-	if !successful {
-		return "", "", "", false
-	}
+) (clusterID types.ClusterName, ruleID types.RuleID, userID types.UserID, successful bool) {
+	successful = readParams(writer, request,
+		clusterNameParam(&clusterID), ruleIDParam(&ruleID), userIDParam(&userID),
+	) && server.checkUserClusterPermissions(writer, request, clusterID)
 
-	ruleID, successful := readRuleID(writer, request)
-	if !successful {
-		return "", "", "", false
-	}
-
-	userID, successful := readUserID(writer, request)
-	if !successful {
-		// everything has been handled already
-		return "", "", "", false
-	}
-
-	clusterExists, err := server.Storage.DoesClusterExist(clusterID)
-	if err != nil {
-		handleServerError(writer, err)
-		return "", "", "", false
-	}
-	if !clusterExists {
-		handleServerError(writer, &types.ItemNotFoundError{ItemID: clusterID})
-		return "", "", "", false
-	}
-
-	return clusterID, ruleID, userID, true
+	return clusterID, ruleID, userID, successful
 }
 
-// readClusterRuleParams gets cluster_name, rule_id and error_key from current request
+// readClusterRuleParams gets cluster_name, rule_id and error_key from current
+// request and checks the user has access to the cluster
 func (server *HTTPServer) readClusterRuleParams(
 	writer http.ResponseWriter, request *http.Request,
 ) (clusterID types.ClusterName, ruleID types.RuleID, errorKey types.ErrorKey, successful bool) {
-	clusterID = ""
-	ruleID = ""
-	errorKey = ""
-	successful = false
-
-	clusterID, successful = readClusterName(writer, request)
-	if !successful {
-		return
-	}
-
-	ruleID, successful = readRuleID(writer, request)
-	if !successful {
-		return
-	}
-
-	errorKey, successful = readErrorKey(writer, request)
-	if !successful {
-		return
-	}
+	successful = readParams(writer, request,
+		clusterNameParam(&clusterID), ruleIDParam(&ruleID), errorKeyParam(&errorKey),
+	) && server.checkUserClusterPermissions(writer, request, clusterID)
 
-	clusterExists, err := server.Storage.DoesClusterExist(clusterID)
-	if err != nil {
-		handleServerError(writer, err)
-		successful = false
-		return
-	}
-	if !clusterExists {
-		handleServerError(writer, &types.ItemNotFoundError{ItemID: clusterID})
-		successful = false
-		return
-	}
+	return clusterID, ruleID, errorKey, successful
+}
 
-	return clusterID, ruleID, errorKey, true
+// readBoolQueryParam parses the boolean query parameter, false is returned
+// when the parameter is not specified
+// if it's not possible, it writes http error to the writer and returns false
+func readBoolQueryParam(
+	writer http.ResponseWriter, request *http.Request, paramName string,
+) (value, successful bool) {
+	return value, readParams(writer, request, boolQueryParam(paramName, &value))
 }
@@ -0,0 +1,89 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// ruleDisableDetails describes who disabled the rule for the cluster, when
+// and with which justification
+type ruleDisableDetails struct {
+	DisabledBy    types.UserID    `json:"disabled_by,omitempty"`
+	DisabledAt    types.Timestamp `json:"disabled_at,omitempty"`
+	Justification string          `json:"justification,omitempty"`
+}
+
+// ruleOnReport is the rule in the cluster report extended by details of
+// disabling the rule and the time the rule was reported for the first time,
+// so clients don't need to request them separately. Votes are returned by
+// the single rule endpoint only.
+type ruleOnReport struct {
+	types.RuleOnReport
+	DisableDetails *ruleDisableDetails `json:"disable_details,omitempty"`
+	FirstSeenAt    types.Timestamp     `json:"first_seen_at,omitempty"`
+	Votes          *ruleVotes          `json:"votes,omitempty"`
+}
+
+// addDisableDetailsToRules adds details of disabling to the disabled rules,
+// the details are read only when some rule is disabled
+func (server HTTPServer) addDisableDetailsToRules(
+	clusterName types.ClusterName, rules []types.RuleOnReport,
+) ([]ruleOnReport, error) {
+	rulesWithDetails := make([]ruleOnReport, len(rules))
+	anyDisabled := false
+
+	for i := range rules {
+		rulesWithDetails[i].RuleOnReport = rules[i]
+		anyDisabled = anyDisabled || rules[i].Disabled
+	}
+
+	if !anyDisabled {
+		return rulesWithDetails, nil
+	}
+
+	details, err := server.Storage.ReadRuleDisableDetails(clusterName)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to retrieve details of disabled rules from database")
+		return nil, err
+	}
+
+	for i := range rulesWithDetails {
+		rule := &rulesWithDetails[i]
+		if !rule.Disabled {
+			continue
+		}
+
+		for _, detail := range details {
+			if detail.RuleID != rule.Module || detail.ErrorKey != rule.ErrorKey {
+				continue
+			}
+
+			rule.DisableDetails = &ruleDisableDetails{
+				DisabledBy:    detail.DisabledBy,
+				Justification: detail.Justification,
+			}
+			if detail.DisabledAt.Valid {
+				rule.DisableDetails.DisabledAt = types.Timestamp(detail.DisabledAt.Time.UTC().Format(time.RFC3339))
+			}
+		}
+	}
+
+	return rulesWithDetails, nil
+}
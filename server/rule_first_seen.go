@@ -0,0 +1,52 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// addFirstSeenToRules adds the time the rules were reported for the cluster
+// for the first time, so clients can tell how long the issue has existed
+func (server HTTPServer) addFirstSeenToRules(
+	orgID types.OrgID, clusterName types.ClusterName, rules []ruleOnReport,
+) error {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	firstSeen, err := server.Storage.ReadRuleHitsFirstSeen(orgID, clusterName)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to retrieve first occurrences of rules from database")
+		return err
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+
+		for _, record := range firstSeen {
+			if record.RuleID == rule.Module && record.ErrorKey == rule.ErrorKey {
+				rule.FirstSeenAt = types.Timestamp(record.FirstSeenAt.UTC().Format(time.RFC3339))
+				break
+			}
+		}
+	}
+
+	return nil
+}
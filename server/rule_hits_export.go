@@ -0,0 +1,379 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const (
+	// ruleHitsExportFormatQueryParam selects the format of the export, csv
+	// (default) or ndjson
+	ruleHitsExportFormatQueryParam = "format"
+	// ruleHitsExportFieldsQueryParam is the comma separated list of fields
+	// of the template data exported for every cluster, whole template data
+	// are exported when it's missing
+	ruleHitsExportFieldsQueryParam = "fields"
+	// ruleHitsExportOrgQueryParam selects the organization whose clusters
+	// are exported
+	ruleHitsExportOrgQueryParam = "org_id"
+	// ruleHitsExportFormatCSV exports one line of CSV per cluster
+	ruleHitsExportFormatCSV = "csv"
+	// ruleHitsExportFormatNDJSON exports one JSON object per line and cluster
+	ruleHitsExportFormatNDJSON = "ndjson"
+	// ruleHitsExportFlushInterval is the number of exported clusters after
+	// which the response is flushed to the client
+	ruleHitsExportFlushInterval = 100
+)
+
+// templateDataFieldValidator checks names of fields of the template data
+var templateDataFieldValidator = regexp.MustCompile(`^[a-zA-Z_0-9]+$`)
+
+// ruleHitsExportContentTypes are content types of responses of all formats
+var ruleHitsExportContentTypes = map[string]string{
+	ruleHitsExportFormatCSV:    "text/csv",
+	ruleHitsExportFormatNDJSON: "application/x-ndjson",
+}
+
+// ruleHitsExportWriter writes the exported rule hits in one format
+type ruleHitsExportWriter interface {
+	// writeHeader is called once before the first rule hit is written
+	writeHeader() error
+	writeRuleHit(orgID types.OrgID, clusterName types.ClusterName, templateData interface{}) error
+	flush() error
+}
+
+// csvRuleHitsExportWriter writes organization, cluster and either the
+// selected fields of template data or the whole template data as JSON
+type csvRuleHitsExportWriter struct {
+	writer *csv.Writer
+	fields []string
+}
+
+func (export *csvRuleHitsExportWriter) writeHeader() error {
+	header := []string{"org_id", "cluster"}
+	if len(export.fields) == 0 {
+		return export.writer.Write(append(header, "template_data"))
+	}
+
+	return export.writer.Write(append(header, export.fields...))
+}
+
+func (export *csvRuleHitsExportWriter) writeRuleHit(
+	orgID types.OrgID, clusterName types.ClusterName, templateData interface{},
+) error {
+	line := []string{strconv.FormatUint(uint64(orgID), 10), string(clusterName)}
+
+	if len(export.fields) == 0 {
+		value, err := json.Marshal(templateData)
+		if err != nil {
+			return err
+		}
+
+		return export.writer.Write(append(line, string(value)))
+	}
+
+	fields := templateData.(map[string]json.RawMessage)
+	for _, field := range export.fields {
+		line = append(line, csvTemplateDataValue(fields[field]))
+	}
+
+	return export.writer.Write(line)
+}
+
+func (export *csvRuleHitsExportWriter) flush() error {
+	export.writer.Flush()
+	return export.writer.Error()
+}
+
+// csvTemplateDataValue converts value of the field of template data into the
+// CSV column: strings are unquoted, missing values and nulls are empty and
+// other values are kept as JSON
+func csvTemplateDataValue(value json.RawMessage) string {
+	if len(value) == 0 || string(value) == "null" {
+		return ""
+	}
+
+	var stringValue string
+	if err := json.Unmarshal(value, &stringValue); err == nil {
+		return stringValue
+	}
+
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, value); err != nil {
+		return string(value)
+	}
+
+	return compacted.String()
+}
+
+// ndjsonRuleHitsExportWriter writes one JSON object per line
+type ndjsonRuleHitsExportWriter struct {
+	encoder *json.Encoder
+}
+
+// exportedRuleHit is one line of the NDJSON export
+type exportedRuleHit struct {
+	OrgID        types.OrgID       `json:"org_id"`
+	ClusterName  types.ClusterName `json:"cluster"`
+	TemplateData interface{}       `json:"template_data"`
+}
+
+func (*ndjsonRuleHitsExportWriter) writeHeader() error {
+	return nil
+}
+
+func (export *ndjsonRuleHitsExportWriter) writeRuleHit(
+	orgID types.OrgID, clusterName types.ClusterName, templateData interface{},
+) error {
+	return export.encoder.Encode(exportedRuleHit{
+		OrgID:        orgID,
+		ClusterName:  clusterName,
+		TemplateData: templateData,
+	})
+}
+
+func (*ndjsonRuleHitsExportWriter) flush() error {
+	return nil
+}
+
+// newRuleHitsExportWriter constructs writer of the export in the format
+func newRuleHitsExportWriter(format string, fields []string, writer io.Writer) ruleHitsExportWriter {
+	if format == ruleHitsExportFormatNDJSON {
+		return &ndjsonRuleHitsExportWriter{encoder: json.NewEncoder(writer)}
+	}
+
+	return &csvRuleHitsExportWriter{writer: csv.NewWriter(writer), fields: fields}
+}
+
+// selectTemplateDataFields returns the fields of template data selected for
+// the export, missing fields are null. Whole template data are returned when
+// no fields are selected. Template data which are not a JSON object are
+// treated as empty.
+func selectTemplateDataFields(record storage.RuleHitRecord, fields []string) interface{} {
+	var templateData map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(record.TemplateData), &templateData); err != nil {
+		log.Warn().
+			Err(err).
+			Str("cluster", string(record.ClusterName)).
+			Msg("Unable to parse template data of rule hit")
+	}
+	if templateData == nil {
+		templateData = map[string]json.RawMessage{}
+	}
+
+	if len(fields) == 0 {
+		return templateData
+	}
+
+	selected := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		value, found := templateData[field]
+		if !found {
+			value = json.RawMessage("null")
+		}
+		selected[field] = value
+	}
+
+	return selected
+}
+
+// ruleHitsExportFormatParam defines optional query parameter with the format
+// of the export
+func ruleHitsExportFormatParam(format *string) param {
+	return param{
+		name:   ruleHitsExportFormatQueryParam,
+		source: queryParam,
+		parse: func(rawValue string) error {
+			if _, found := ruleHitsExportContentTypes[rawValue]; !found {
+				return errors.New("csv or ndjson expected")
+			}
+
+			*format = rawValue
+			return nil
+		},
+	}
+}
+
+// ruleHitsExportFieldsParam defines optional query parameter with the comma
+// separated list of fields of the template data selected for the export
+func ruleHitsExportFieldsParam(fields *[]string) param {
+	return param{
+		name:   ruleHitsExportFieldsQueryParam,
+		source: queryParam,
+		parse: func(rawValue string) error {
+			values := strings.Split(rawValue, ",")
+			for i, field := range values {
+				values[i] = strings.TrimSpace(field)
+				if !templateDataFieldValidator.MatchString(values[i]) {
+					return errors.New(
+						"comma separated names of fields consisting of latin characters, numbers or underscores expected",
+					)
+				}
+			}
+
+			*fields = values
+			return nil
+		},
+	}
+}
+
+// readRuleHitsExportOrgID retrieves the organization whose clusters are
+// exported, 0 means clusters of all organizations. Users authenticated by
+// identity can export only clusters of their organization, which is used
+// when the organization is not specified, internal services authenticated
+// by API keys can export clusters of all organizations.
+// if it's not possible, it writes http error to the writer and returns false
+func (server *HTTPServer) readRuleHitsExportOrgID(writer http.ResponseWriter, request *http.Request) (types.OrgID, bool) {
+	rawOrgID, present, successful := readUintQueryParam(writer, request, ruleHitsExportOrgQueryParam)
+	if !successful {
+		return 0, false
+	}
+
+	orgID := types.OrgID(rawOrgID)
+	if !server.Config.Auth || isAuthenticatedByAPIKey(request) {
+		return orgID, true
+	}
+
+	if !present {
+		identity, ok := request.Context().Value(types.ContextKeyUser).(Identity)
+		if !ok {
+			handleServerError(writer, &UnauthorizedError{ErrString: "user identity is not provided"})
+			return 0, false
+		}
+		orgID = identity.Internal.OrgID
+	}
+
+	return orgID, checkPermissions(writer, request, orgID, server.Config.Auth)
+}
+
+// exportRuleHits streams organizations and names of all clusters affected by
+// the rule with error key together with the selected fields of template data
+// of the rule hits as CSV or NDJSON, so the remediation of the clusters can
+// be automated. Clusters are ordered by organization and name, their page
+// can be selected by limit and offset query parameters.
+func (server *HTTPServer) exportRuleHits(writer http.ResponseWriter, request *http.Request) {
+	var (
+		ruleID   types.RuleID
+		errorKey types.ErrorKey
+		// CSV is used when the format is not specified
+		format = ruleHitsExportFormatCSV
+		fields []string
+	)
+	if !readParams(writer, request,
+		ruleIDParam(&ruleID), errorKeyParam(&errorKey),
+		ruleHitsExportFormatParam(&format), ruleHitsExportFieldsParam(&fields),
+	) {
+		// everything has been handled already
+		return
+	}
+
+	paging, successful := readReportPagingQueryParams(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+	if paging == nil {
+		paging = &reportPaging{}
+	}
+
+	orgID, successful := server.readRuleHitsExportOrgID(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	export := newRuleHitsExportWriter(format, fields, writer)
+	flusher, _ := writer.(http.Flusher)
+	exported := 0
+
+	err := server.Storage.IterateRuleHitsForRule(
+		orgID, ruleID, errorKey, paging.Offset, paging.Limit,
+		func(record storage.RuleHitRecord) error {
+			// the deadline of the export expired or the client went away
+			if err := request.Context().Err(); err != nil {
+				return err
+			}
+
+			if exported == 0 {
+				writer.Header().Set("Content-Type", ruleHitsExportContentTypes[format])
+				if err := export.writeHeader(); err != nil {
+					return err
+				}
+			}
+
+			err := export.writeRuleHit(record.OrgID, record.ClusterName, selectTemplateDataFields(record, fields))
+			if err != nil {
+				return err
+			}
+
+			exported++
+			if exported%ruleHitsExportFlushInterval == 0 {
+				if err := export.flush(); err != nil {
+					return err
+				}
+				if flusher != nil {
+					flusher.Flush()
+				}
+			}
+
+			return nil
+		},
+	)
+	if err != nil {
+		log.Error().Err(err).Int("exported", exported).Msg("Unable to export rule hits")
+		switch {
+		case exported > 0:
+			// part of the export has been sent already and the client gets
+			// it truncated
+		case errors.Is(err, context.DeadlineExceeded):
+			err = responses.Send(
+				http.StatusGatewayTimeout, writer, responses.BuildResponse(requestTimeoutMessage),
+			)
+			if err != nil {
+				log.Error().Err(err).Msg(responseDataError)
+			}
+		default:
+			handleServerError(writer, err)
+		}
+		return
+	}
+
+	if exported == 0 {
+		writer.Header().Set("Content-Type", ruleHitsExportContentTypes[format])
+		err = export.writeHeader()
+	}
+	if err == nil {
+		err = export.flush()
+	}
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	operator_utils_types "github.com/RedHatInsights/insights-operator-utils/types"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const (
+	exportedCluster1 = types.ClusterName("11111111-0000-0000-0000-000000000000")
+	exportedCluster2 = types.ClusterName("22222222-0000-0000-0000-000000000000")
+)
+
+// mustWriteExportedRuleHits stores reports of two clusters of testdata.OrgID
+// and testdata.Org2ID hit by testdata.Rule1ID
+func mustWriteExportedRuleHits(t *testing.T, mockStorage storage.Storage) {
+	for clusterName, ruleHit := range map[types.ClusterName]struct {
+		orgID        types.OrgID
+		templateData string
+	}{
+		exportedCluster1: {testdata.OrgID, `{"nodes": ["node-1", "node-2"], "version": "4.5.1", "link": null}`},
+		exportedCluster2: {testdata.Org2ID, `{"version": "4.6.0", "count": 2}`},
+	} {
+		err := mockStorage.WriteReportForCluster(
+			ruleHit.orgID, clusterName, testdata.Report0Rules,
+			[]types.ReportItem{{
+				Module:       testdata.Rule1ID,
+				ErrorKey:     testdata.ErrorKey1,
+				TemplateData: []byte(ruleHit.templateData),
+			}},
+			testdata.LastCheckedAt, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+}
+
+// assertRuleHitsExport requests export of rule hits of testdata.Rule1ID
+// with the query and checks the response body and content type
+func assertRuleHitsExport(
+	t *testing.T, mockStorage storage.Storage, serverConfig *server.Configuration,
+	request *helpers.APIRequest, query, contentType, expectedBody string,
+) {
+	request.Method = http.MethodGet
+	request.Endpoint = server.RuleHitsExportEndpoint + query
+	request.EndpointArgs = []interface{}{testdata.Rule1ID, testdata.ErrorKey1}
+
+	helpers.AssertAPIRequest(t, mockStorage, serverConfig, request, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Headers:    map[string]string{"Content-Type": contentType},
+		BodyChecker: func(t testing.TB, _, got []byte) {
+			assert.Equal(t, expectedBody, string(got))
+		},
+	})
+}
+
+func TestHTTPServer_ExportRuleHits_CSV(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteExportedRuleHits(t, mockStorage)
+
+	assertRuleHitsExport(t, mockStorage, nil, &helpers.APIRequest{}, "?fields=version,nodes,link", "text/csv",
+		"org_id,cluster,version,nodes,link\n"+
+			`1,11111111-0000-0000-0000-000000000000,4.5.1,"[""node-1"",""node-2""]",`+"\n"+
+			"2,22222222-0000-0000-0000-000000000000,4.6.0,,\n",
+	)
+
+	assertRuleHitsExport(t, mockStorage, nil, &helpers.APIRequest{}, "?format=csv&offset=1", "text/csv",
+		"org_id,cluster,template_data\n"+
+			`2,22222222-0000-0000-0000-000000000000,"{""count"":2,""version"":""4.6.0""}"`+"\n",
+	)
+}
+
+func TestHTTPServer_ExportRuleHits_NDJSON(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteExportedRuleHits(t, mockStorage)
+
+	assertRuleHitsExport(t, mockStorage, nil, &helpers.APIRequest{}, "?format=ndjson&fields=count", "application/x-ndjson",
+		`{"org_id":1,"cluster":"11111111-0000-0000-0000-000000000000","template_data":{"count":null}}`+"\n"+
+			`{"org_id":2,"cluster":"22222222-0000-0000-0000-000000000000","template_data":{"count":2}}`+"\n",
+	)
+
+	assertRuleHitsExport(t, mockStorage, nil, &helpers.APIRequest{}, "?format=ndjson&org_id=2&limit=1", "application/x-ndjson",
+		`{"org_id":2,"cluster":"22222222-0000-0000-0000-000000000000","template_data":{"count":2,"version":"4.6.0"}}`+"\n",
+	)
+}
+
+func TestHTTPServer_ExportRuleHits_NoRuleHits(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	assertRuleHitsExport(t, mockStorage, nil, &helpers.APIRequest{}, "?fields=version", "text/csv",
+		"org_id,cluster,version\n",
+	)
+
+	assertRuleHitsExport(t, mockStorage, nil, &helpers.APIRequest{}, "?format=ndjson", "application/x-ndjson", "")
+}
+
+func TestHTTPServer_ExportRuleHits_Auth(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteExportedRuleHits(t, mockStorage)
+
+	identity := helpers.MakeXRHTokenString(t, &types.Token{
+		Identity: operator_utils_types.Identity{
+			AccountNumber: testdata.UserID,
+			Internal: operator_utils_types.Internal{
+				OrgID: testdata.Org2ID,
+			},
+		},
+	})
+
+	// only clusters of the organization of the user are exported
+	assertRuleHitsExport(t, mockStorage, &helpers.DefaultServerConfigAuth, &helpers.APIRequest{
+		XRHIdentity: identity,
+	}, "", "text/csv",
+		"org_id,cluster,template_data\n"+
+			`2,22222222-0000-0000-0000-000000000000,"{""count"":2,""version"":""4.6.0""}"`+"\n",
+	)
+
+	helpers.AssertAPIRequest(t, mockStorage, &helpers.DefaultServerConfigAuth, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.RuleHitsExportEndpoint + "?org_id=1",
+		EndpointArgs: []interface{}{testdata.Rule1ID, testdata.ErrorKey1},
+		XRHIdentity:  identity,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusForbidden,
+		Body:       `{"status":"you have no permissions to get or change info about this organization"}`,
+	})
+}
+
+func TestHTTPServer_ExportRuleHits_APIKey(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteExportedRuleHits(t, mockStorage)
+
+	_, key := mustCreateAPIKey(t, mockStorage, `["read"]`)
+
+	// internal services export clusters of all organizations
+	assertRuleHitsExport(t, mockStorage, &configAPIKeyAuth, &helpers.APIRequest{
+		ExtraHeaders: apiKeyHeaders(key),
+	}, "?fields=version", "text/csv",
+		"org_id,cluster,version\n"+
+			"1,11111111-0000-0000-0000-000000000000,4.5.1\n"+
+			"2,22222222-0000-0000-0000-000000000000,4.6.0\n",
+	)
+}
+
+func TestHTTPServer_ExportRuleHits_BadParams(t *testing.T) {
+	for query, body := range map[string]string{
+		"?format=xml":   `{"status": "Error during parsing param 'format' with value 'xml'. Error: 'csv or ndjson expected'"}`,
+		"?fields=a,,b":  `{"status": "Error during parsing param 'fields' with value 'a,,b'. Error: 'comma separated names of fields consisting of latin characters, numbers or underscores expected'"}`,
+		"?limit=0":      `{"status": "Error during parsing param 'limit' with value '0'. Error: 'positive integer expected'"}`,
+		"?org_id=first": `{"status": "Error during parsing param 'org_id' with value 'first'. Error: 'unsigned integer expected'"}`,
+	} {
+		helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+			Method:       http.MethodGet,
+			Endpoint:     server.RuleHitsExportEndpoint + query,
+			EndpointArgs: []interface{}{testdata.Rule1ID, testdata.ErrorKey1},
+		}, &helpers.APIResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       body,
+		})
+	}
+
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.RuleHitsExportEndpoint,
+		EndpointArgs: []interface{}{testdata.BadRuleID, testdata.ErrorKey1},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+	})
+}
+
+func TestHTTPServer_ExportRuleHits_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorageWithFaults(t, true)
+	defer closer()
+
+	mockStorage.InjectFault("IterateRuleHitsForRule", helpers.Fault{Err: errors.New("database is unavailable")})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.RuleHitsExportEndpoint,
+		EndpointArgs: []interface{}{testdata.Rule1ID, testdata.ErrorKey1},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status": "Internal Server Error"}`,
+	})
+}
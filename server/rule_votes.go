@@ -0,0 +1,55 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import "github.com/RedHatInsights/insights-results-aggregator/types"
+
+// orgVotesQueryParam is the name of single rule endpoint query parameter
+// requesting votes on the rule from all clusters of the organization
+const orgVotesQueryParam = "org_votes"
+
+// ruleVotes contains vote of the requesting user and the summary of votes of
+// all users on the rule
+type ruleVotes struct {
+	User         types.UserVote     `json:"user"`
+	Cluster      types.VoteSummary  `json:"cluster"`
+	Organization *types.VoteSummary `json:"organization,omitempty"`
+}
+
+// readRuleVotes reads the summary of votes on the rule for the cluster and,
+// when requested, for the whole organization
+func (server *HTTPServer) readRuleVotes(
+	orgID types.OrgID, clusterName types.ClusterName, rule types.RuleOnReport, includeOrg bool,
+) (*ruleVotes, error) {
+	summary, err := server.Storage.ReadVoteSummaryOnRule(clusterName, rule.Module, rule.ErrorKey)
+	if err != nil {
+		return nil, err
+	}
+
+	votes := &ruleVotes{
+		User:    rule.UserVote,
+		Cluster: summary,
+	}
+
+	if includeOrg {
+		orgSummary, err := server.Storage.ReadVoteSummaryOnRuleForOrg(orgID, rule.Module, rule.ErrorKey)
+		if err != nil {
+			return nil, err
+		}
+		votes.Organization = &orgSummary
+	}
+
+	return votes, nil
+}
@@ -15,12 +15,15 @@
 package server
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/rs/zerolog/log"
 
 	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/RedHatInsights/insights-results-aggregator/events"
 	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
 )
@@ -43,10 +46,17 @@ func (server *HTTPServer) toggleRuleForCluster(writer http.ResponseWriter, reque
 		return
 	}
 
-	successful = server.checkUserClusterPermissions(writer, request, clusterID)
-	if !successful {
-		// everything has been handled already
-		return
+	var (
+		justification string
+		userID        types.UserID
+	)
+
+	if toggleRule == storage.RuleToggleDisable {
+		justification, userID, successful = server.readDisableJustification(writer, request, ruleID, errorKey)
+		if !successful {
+			// everything has been handled already
+			return
+		}
 	}
 
 	err := server.Storage.ToggleRuleForCluster(clusterID, ruleID, errorKey, toggleRule)
@@ -56,12 +66,112 @@ func (server *HTTPServer) toggleRuleForCluster(writer http.ResponseWriter, reque
 		return
 	}
 
+	// the justification is stored only for the rule that was actually
+	// disabled
+	if justification != "" {
+		err = server.Storage.AddFeedbackOnRuleDisable(clusterID, ruleID, errorKey, userID, justification)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to store justification of disabling the rule")
+			handleServerError(writer, err)
+			return
+		}
+	}
+
+	server.publishRuleToggle(clusterID, ruleID, errorKey, toggleRule)
+
 	err = responses.SendOK(writer, responses.BuildOkResponse())
 	if err != nil {
 		log.Error().Err(err).Msg(responseDataError)
 	}
 }
 
+// readDisableJustification reads optional justification of disabling the
+// rule from the request body together with the current user it is stored
+// for as the disable feedback. Empty justification is returned when none
+// was sent. Rules configured in JustificationRequiredRules can't be
+// disabled without justification, 422 is returned in such case.
+func (server *HTTPServer) readDisableJustification(
+	writer http.ResponseWriter,
+	request *http.Request,
+	ruleID types.RuleID,
+	errorKey types.ErrorKey,
+) (string, types.UserID, bool) {
+	justification, err := server.getFeedbackMessageFromBody(request)
+	if _, noBody := err.(*NoBodyError); err != nil && !noBody {
+		handleServerError(writer, err)
+		return "", "", false
+	}
+
+	if strings.TrimSpace(justification) == "" {
+		if !server.justificationRequired(ruleID, errorKey) {
+			return "", "", true
+		}
+
+		err = responses.Send(http.StatusUnprocessableEntity, writer, responses.BuildResponse(fmt.Sprintf(
+			`Justification is required to disable rule %v|%v, send the reason in request body as {"message": "reason"}`,
+			ruleID, errorKey,
+		)))
+		if err != nil {
+			log.Error().Err(err).Msg(responseDataError)
+		}
+		return "", "", false
+	}
+
+	// user is known only when authentication is enabled
+	var userID types.UserID
+	if server.Config.Auth {
+		userID, err = server.GetCurrentUserID(request)
+		if err != nil {
+			handleServerError(writer, err)
+			return "", "", false
+		}
+	}
+
+	return justification, userID, true
+}
+
+// justificationRequired checks whether the rule can be disabled only with
+// justification
+func (server *HTTPServer) justificationRequired(ruleID types.RuleID, errorKey types.ErrorKey) bool {
+	for _, rule := range server.Config.JustificationRequiredRules {
+		if rule == string(ruleID) || rule == string(ruleID)+"|"+string(errorKey) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// publishRuleToggle notifies other services about the toggled rule. The
+// toggle itself is already stored, so errors are only logged.
+func (server *HTTPServer) publishRuleToggle(
+	clusterID types.ClusterName,
+	ruleID types.RuleID,
+	errorKey types.ErrorKey,
+	toggleRule storage.RuleToggle,
+) {
+	if server.EventPublisher == nil {
+		return
+	}
+
+	orgID, err := server.Storage.GetOrgIDByClusterID(clusterID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read organization of cluster for rule toggle event")
+	}
+
+	err = server.EventPublisher.PublishRuleToggle(events.RuleToggleEvent{
+		OrgID:     orgID,
+		ClusterID: clusterID,
+		RuleID:    ruleID,
+		ErrorKey:  errorKey,
+		Disabled:  toggleRule == storage.RuleToggleDisable,
+		Timestamp: time.Now().UTC(),
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to publish rule toggle event")
+	}
+}
+
 // getFeedbackAndTogglesOnRules
 func (server HTTPServer) getFeedbackAndTogglesOnRules(
 	clusterName types.ClusterName,
@@ -122,12 +232,6 @@ func (server HTTPServer) saveDisableFeedback(writer http.ResponseWriter, request
 		return
 	}
 
-	successful = server.checkUserClusterPermissions(writer, request, clusterID)
-	if !successful {
-		// everything has been handled already
-		return
-	}
-
 	feedback, err := server.getFeedbackMessageFromBody(request)
 	if err != nil {
 		handleServerError(writer, err)
@@ -148,6 +252,66 @@ func (server HTTPServer) saveDisableFeedback(writer http.ResponseWriter, request
 	}
 }
 
+// getRuleHitOccurrences returns the timeline of periods during which the rule
+// was reported for the cluster
+func (server *HTTPServer) getRuleHitOccurrences(writer http.ResponseWriter, request *http.Request) {
+	clusterID, ruleID, errorKey, successful := server.readClusterRuleParams(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	occurrences, err := server.Storage.ReadRuleHitOccurrences(clusterID, ruleID, errorKey)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read rule hit occurrences")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("occurrences", occurrences))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// getRuleResolutionRates returns for every rule how many times it was hit by
+// clusters of all organizations and how many times it was resolved
+func (server *HTTPServer) getRuleResolutionRates(writer http.ResponseWriter, _ *http.Request) {
+	rates, err := server.Storage.ReadRuleResolutionRates()
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read rule resolution rates")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("resolution_rates", rates))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// getOrganizationRuleResolutionRates returns for every rule how many times it
+// was hit by clusters of the organization and how many times it was resolved
+func (server *HTTPServer) getOrganizationRuleResolutionRates(writer http.ResponseWriter, request *http.Request) {
+	organizationID, successful := readOrganizationID(writer, request, server.Config.Auth)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	rates, err := server.Storage.ReadRuleResolutionRatesForOrg(organizationID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read rule resolution rates of organization")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("resolution_rates", rates))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
 // getFeedbackAndTogglesOnRule
 func (server HTTPServer) getFeedbackAndTogglesOnRule(
 	clusterName types.ClusterName,
@@ -61,6 +61,10 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/rs/zerolog/log"
 
+	"github.com/RedHatInsights/insights-results-aggregator/chaos"
+	"github.com/RedHatInsights/insights-results-aggregator/events"
+	"github.com/RedHatInsights/insights-results-aggregator/export"
+	"github.com/RedHatInsights/insights-results-aggregator/inventory"
 	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
 )
@@ -68,6 +72,10 @@ import (
 const (
 	// ReportResponse constant that defines the name of response field
 	ReportResponse = "report"
+
+	// mostDisabledRulesInOverview is the number of the most commonly disabled
+	// rules returned in the overview of organization
+	mostDisabledRulesInOverview = 5
 )
 
 // HTTPServer in an implementation of Server interface
@@ -75,13 +83,38 @@ type HTTPServer struct {
 	Config  Configuration
 	Storage storage.Storage
 	Serv    *http.Server
+	// EventPublisher is notified about rule toggles, it's optional
+	EventPublisher events.Publisher
+	// FaultInjector injects faults into handlers in chaos mode, it's optional
+	FaultInjector *chaos.Injector
+	// HighWaterMarks is used to compute lag of the consumer, it's optional
+	HighWaterMarks HighWaterMarkReader
+	// DisplayNames resolves IDs of clusters to their display names, it's
+	// optional
+	DisplayNames inventory.Provider
+	// IngestSimulator runs messages through the processing of the consumer
+	// without storing them, it's optional
+	IngestSimulator IngestSimulator
+	// EffectiveConfiguration and DefaultConfiguration are returned by the
+	// info endpoint, secrets have to be removed from them, they're optional
+	EffectiveConfiguration interface{}
+	DefaultConfiguration   interface{}
+	// ExportConfiguration is used by the Parquet export job, the job is
+	// available only when it's set
+	ExportConfiguration *export.Configuration
+	// jobRuns contains the last runs of jobs started by the jobs API
+	jobRuns *jobRuns
+	// apiCalls counts API calls of organizations until they are stored
+	apiCalls *apiCallCounter
 }
 
 // New constructs new implementation of Server interface
 func New(config Configuration, storage storage.Storage) *HTTPServer {
 	return &HTTPServer{
-		Config:  config,
-		Storage: storage,
+		Config:   config,
+		Storage:  storage,
+		jobRuns:  &jobRuns{},
+		apiCalls: newAPICallCounter(),
 	}
 }
 
@@ -106,6 +139,22 @@ func (server *HTTPServer) listOfOrganizations(writer http.ResponseWriter, _ *htt
 	}
 }
 
+// listOfOrganizationsDetailed returns all organizations together with number
+// of their clusters and the time of the most recent report, so clients don't
+// need to ask for clusters of every organization
+func (server *HTTPServer) listOfOrganizationsDetailed(writer http.ResponseWriter, _ *http.Request) {
+	organizations, err := server.Storage.ListOfOrgsWithSummary()
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to get list of organizations with summary")
+		handleServerError(writer, err)
+		return
+	}
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("organizations", organizations))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
 func (server *HTTPServer) listOfClustersForOrganization(writer http.ResponseWriter, request *http.Request) {
 	organizationID, successful := readOrganizationID(writer, request, server.Config.Auth)
 	if !successful {
@@ -113,52 +162,207 @@ func (server *HTTPServer) listOfClustersForOrganization(writer http.ResponseWrit
 		return
 	}
 
+	class, successful := readClusterClassQueryParam(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	paging, successful := readReportPagingQueryParams(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
 	// TODO get limit from request param instead of hardcoded config param
 	timeLimit := time.Now().Add(-time.Duration(server.Config.OrgOverviewLimitHours) * time.Hour)
 
-	clusters, err := server.Storage.ListOfClustersForOrg(organizationID, timeLimit)
+	clusters, err := server.readClustersForOrganization(organizationID, timeLimit, class, paging)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable to get list of clusters")
 		handleServerError(writer, err)
 		return
 	}
-	err = responses.SendOK(writer, responses.BuildOkResponseWithData("clusters", clusters))
+
+	classes, err := server.Storage.ReadClusterClasses(organizationID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to get classes of clusters")
+		handleServerError(writer, err)
+		return
+	}
+
+	if class != "" {
+		clusters = filterClustersByClass(clusters, classes, class)
+	}
+
+	if paging != nil && class != "" {
+		start, end := paging.page(len(clusters))
+		clusters = clusters[start:end]
+	}
+
+	response := responses.BuildOkResponseWithData("clusters", clusters)
+	if paging != nil {
+		response["paging"] = paging
+	}
+	if clusterClasses := clusterClassesOf(clusters, classes); len(clusterClasses) != 0 {
+		response[clusterClassesResponse] = clusterClasses
+	}
+	if displayNames := server.readDisplayNames(organizationID, clusters); displayNames != nil {
+		response[displayNamesResponse] = displayNames
+	}
+
+	err = responses.SendOK(writer, response)
 	if err != nil {
 		log.Error().Err(err).Msg(responseDataError)
 	}
 }
 
-func (server *HTTPServer) readReportForCluster(writer http.ResponseWriter, request *http.Request) {
-	clusterName, successful := readClusterName(writer, request)
+// readClustersForOrganization reads the requested page of clusters of the
+// organization, the total number of clusters is stored in the paging. All
+// clusters are read when the class filter is used, they are paged after they
+// are filtered.
+func (server *HTTPServer) readClustersForOrganization(
+	organizationID types.OrgID, timeLimit time.Time, class types.ClusterClass, paging *reportPaging,
+) ([]types.ClusterName, error) {
+	if paging == nil || class != "" {
+		return server.Storage.ListOfClustersForOrg(organizationID, timeLimit, 0, 0)
+	}
+
+	total, err := server.Storage.CountClustersForOrg(organizationID, timeLimit)
+	if err != nil {
+		return nil, err
+	}
+	paging.Total = total
+
+	return server.Storage.ListOfClustersForOrg(organizationID, timeLimit, paging.Offset, paging.Limit)
+}
+
+// organizationInfo returns when the organization was first seen and last active
+func (server *HTTPServer) organizationInfo(writer http.ResponseWriter, request *http.Request) {
+	organizationID, successful := readOrganizationID(writer, request, server.Config.Auth)
 	if !successful {
 		// everything has been handled already
 		return
 	}
 
-	userID, successful := readUserID(writer, request)
+	orgInfo, err := server.Storage.ReadOrgInfo(organizationID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read organization info")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("organization", orgInfo))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// organizationOverview returns when the organization was first seen and last
+// active together with numbers of rules disabled by the organization and its
+// most commonly disabled rules
+func (server *HTTPServer) organizationOverview(writer http.ResponseWriter, request *http.Request) {
+	organizationID, successful := readOrganizationID(writer, request, server.Config.Auth)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	orgInfo, err := server.Storage.ReadOrgInfo(organizationID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read organization info")
+		handleServerError(writer, err)
+		return
+	}
+
+	disabledRules, err := server.Storage.ReadOrgRuleDisables(organizationID, mostDisabledRulesInOverview)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read rules disabled by organization")
+		handleServerError(writer, err)
+		return
+	}
+
+	overview := types.OrgOverview{OrgInfo: orgInfo, DisabledRules: disabledRules}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("overview", overview))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+func (server *HTTPServer) readReportForCluster(writer http.ResponseWriter, request *http.Request) {
+	var (
+		clusterName        types.ClusterName
+		userID             types.UserID
+		orgID              types.OrgID
+		includeAnnotations bool
+	)
+	if !readParams(writer, request,
+		clusterNameParam(&clusterName), userIDParam(&userID), orgIDParam(&orgID),
+		boolQueryParam(annotationsQueryParam, &includeAnnotations),
+	) {
+		// everything has been handled already
+		return
+	}
+
+	paging, successful := readReportPagingQueryParams(writer, request)
 	if !successful {
 		return
 	}
 
-	orgID, successful := readOrgID(writer, request)
+	canonical, successful := readCanonicalQueryParam(writer, request)
 	if !successful {
 		return
 	}
 
+	var analysisStatus types.ReportStatus
+
 	reports, lastChecked, err := server.Storage.ReadReportForCluster(orgID, clusterName)
+	if server.isReportNotFoundWithStatus(err) {
+		reports, lastChecked, err = []types.RuleOnReport{}, "", nil
+		analysisStatus = types.ReportStatusNoData
+	}
 	if err != nil {
 		log.Error().Err(err).Msg("Unable to read report for cluster")
-		handleServerError(writer, err)
+		handleReportReadError(writer, err)
 		return
 	}
 
 	hitRulesCount := len(reports)
 
+	if analysisStatus == "" {
+		analysisStatus, err = server.readReportAnalysisStatus(orgID, clusterName, hitRulesCount)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to read status of the report for cluster")
+			handleServerError(writer, err)
+			return
+		}
+	}
+
+	if paging != nil {
+		reports = paging.pageRules(reports)
+	} else if canonical {
+		types.SortRulesOnReport(reports)
+	}
+
 	reports, err = server.getFeedbackAndTogglesOnRules(clusterName, userID, reports)
 
 	if err != nil {
 		log.Error().Err(err).Msg("An error has occurred when getting feedback or toggles")
 		handleServerError(writer, err)
+		return
+	}
+
+	rules, err := server.addDisableDetailsToRules(clusterName, reports)
+	if err != nil {
+		handleServerError(writer, err)
+		return
+	}
+
+	err = server.addFirstSeenToRules(orgID, clusterName, rules)
+	if err != nil {
+		handleServerError(writer, err)
+		return
 	}
 
 	// -1 as count in response means there are no rules for this cluster
@@ -167,15 +371,41 @@ func (server *HTTPServer) readReportForCluster(writer http.ResponseWriter, reque
 		hitRulesCount = -1
 	}
 
-	response := types.ReportResponse{
-		Meta: types.ReportResponseMeta{
+	meta := reportResponseMeta{
+		ReportResponseMeta: types.ReportResponseMeta{
 			Count:         hitRulesCount,
 			LastCheckedAt: lastChecked,
 		},
-		Report: reports,
+		AnalysisStatus: analysisStatus,
+		Paging:         paging,
+		DisplayName:    server.readDisplayNames(orgID, []types.ClusterName{clusterName})[clusterName],
+	}
+
+	var response interface{} = reportResponse{
+		Meta:   meta,
+		Report: rules,
 	}
 
-	err = responses.SendOK(writer, responses.BuildOkResponseWithData(ReportResponse, response))
+	if includeAnnotations {
+		annotations, err := server.Storage.ReadClusterAnnotations(clusterName)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to read cluster annotations")
+			handleServerError(writer, err)
+			return
+		}
+
+		response = reportResponseWithAnnotations{
+			Meta: reportResponseMetaWithAnnotations{
+				reportResponseMeta: meta,
+				Annotations:        annotations,
+			},
+			Report: rules,
+		}
+	}
+
+	setLastModified(writer, lastChecked)
+
+	err = sendOKResponse(writer, canonical, responses.BuildOkResponseWithData(ReportResponse, response))
 	if err != nil {
 		log.Error().Err(err).Msg(responseDataError)
 	}
@@ -183,27 +413,22 @@ func (server *HTTPServer) readReportForCluster(writer http.ResponseWriter, reque
 
 // readSingleRule returns a rule by cluster ID, org ID and rule ID
 func (server *HTTPServer) readSingleRule(writer http.ResponseWriter, request *http.Request) {
-	clusterName, successful := readClusterName(writer, request)
-	if !successful {
+	var (
+		clusterName     types.ClusterName
+		userID          types.UserID
+		orgID           types.OrgID
+		ruleID          types.RuleID
+		errorKey        types.ErrorKey
+		includeOrgVotes bool
+	)
+	if !readParams(writer, request,
+		clusterNameParam(&clusterName), userIDParam(&userID), orgIDParam(&orgID),
+		ruleIDWithErrorKeyParam(&ruleID, &errorKey), boolQueryParam(orgVotesQueryParam, &includeOrgVotes),
+	) {
 		// everything has been handled already
 		return
 	}
 
-	userID, successful := readUserID(writer, request)
-	if !successful {
-		return
-	}
-
-	orgID, successful := readOrgID(writer, request)
-	if !successful {
-		return
-	}
-
-	ruleID, errorKey, successful := readRuleIDWithErrorKey(writer, request)
-	if !successful {
-		return
-	}
-
 	templateData, err := server.Storage.ReadSingleRuleTemplateData(orgID, clusterName, ruleID, errorKey)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable to read rule report for cluster")
@@ -219,29 +444,55 @@ func (server *HTTPServer) readSingleRule(writer http.ResponseWriter, request *ht
 
 	reportRule = server.getFeedbackAndTogglesOnRule(clusterName, userID, reportRule)
 
-	err = responses.SendOK(writer, responses.BuildOkResponseWithData(ReportResponse, reportRule))
+	rules, err := server.addDisableDetailsToRules(clusterName, []types.RuleOnReport{reportRule})
+	if err != nil {
+		handleServerError(writer, err)
+		return
+	}
+
+	err = server.addFirstSeenToRules(orgID, clusterName, rules)
+	if err != nil {
+		handleServerError(writer, err)
+		return
+	}
+
+	rules[0].Votes, err = server.readRuleVotes(orgID, clusterName, reportRule, includeOrgVotes)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read votes on rule")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData(ReportResponse, rules[0]))
 	if err != nil {
 		log.Error().Err(err).Msg(responseDataError)
 	}
 }
 
-// checkUserClusterPermissions retrieves organization ID by checking the owner of cluster ID, checks if it matches the one from request
+// checkUserClusterPermissions checks that the cluster exists and, when
+// authentication is enabled, that it belongs to the organization of the user.
+// Both are checked by one query, 404 is returned for unknown cluster and 403
+// for cluster of another organization.
+// if it's not possible, it writes http error to the writer and returns false
 func (server *HTTPServer) checkUserClusterPermissions(writer http.ResponseWriter, request *http.Request, clusterID types.ClusterName) bool {
-	if server.Config.Auth {
-		orgID, err := server.Storage.GetOrgIDByClusterID(clusterID)
-		if err != nil {
-			log.Error().Err(err).Msg("Unable to get org id")
-			handleServerError(writer, err)
-			return false
-		}
+	clusterExists, orgID, err := server.Storage.DoesClusterExistWithOrgID(clusterID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to get org id")
+		handleServerError(writer, err)
+		return false
+	}
 
-		successful := checkPermissions(writer, request, orgID, server.Config.Auth)
-		if !successful {
-			return false
-		}
+	if !clusterExists {
+		handleServerError(writer, &types.ItemNotFoundError{ItemID: clusterID})
+		return false
+	}
+
+	// internal services can access clusters of all organizations
+	if isAuthenticatedByAPIKey(request) {
+		return true
 	}
 
-	return true
+	return checkPermissions(writer, request, orgID, server.Config.Auth)
 }
 
 func (server *HTTPServer) deleteOrganizations(writer http.ResponseWriter, request *http.Request) {
@@ -251,6 +502,10 @@ func (server *HTTPServer) deleteOrganizations(writer http.ResponseWriter, reques
 		return
 	}
 
+	if server.rejectFrozenOrgs(writer, request, orgIds...) {
+		return
+	}
+
 	for _, org := range orgIds {
 		if err := server.Storage.DeleteReportsForOrg(org); err != nil {
 			log.Error().Err(err).Msg("Unable to delete reports")
@@ -272,6 +527,10 @@ func (server *HTTPServer) deleteClusters(writer http.ResponseWriter, request *ht
 		return
 	}
 
+	if server.rejectFrozenClusters(writer, request, clusterNames...) {
+		return
+	}
+
 	for _, cluster := range clusterNames {
 		if err := server.Storage.DeleteReportsForCluster(cluster); err != nil {
 			log.Error().Err(err).Msg("Unable to delete reports")
@@ -304,6 +563,7 @@ func (server *HTTPServer) Initialize() http.Handler {
 
 	router := mux.NewRouter().StrictSlash(true)
 	router.Use(httputils.LogRequest)
+	router.Use(server.MeasureDuration)
 
 	apiPrefix := server.Config.APIPrefix
 
@@ -325,6 +585,18 @@ func (server *HTTPServer) Initialize() http.Handler {
 		router.Use(func(next http.Handler) http.Handler { return server.Authentication(next, noAuthURLs) })
 	}
 
+	router.Use(server.UsageAccounting)
+	router.Use(server.Deadline)
+	router.Use(server.RejectWritesOfFrozenOrgs)
+	router.Use(server.Caching)
+	router.Use(server.LimitReportSize)
+
+	// faults are injected after deadline is set, so the injected latency
+	// can't make the request exceed its timeout
+	if server.FaultInjector != nil {
+		router.Use(server.InjectFaults)
+	}
+
 	server.addEndpointsToRouter(router)
 
 	return router
@@ -335,13 +607,28 @@ func (server *HTTPServer) Start(serverInstanceReady context.CancelFunc) error {
 	address := server.Config.Address
 	log.Info().Msgf("Starting HTTP server at '%s'", address)
 	router := server.Initialize()
-	server.Serv = &http.Server{Addr: address, Handler: router}
+	server.Serv = &http.Server{
+		Addr:         address,
+		Handler:      router,
+		ReadTimeout:  server.Config.ReadTimeout,
+		WriteTimeout: server.Config.WriteTimeout,
+		IdleTimeout:  server.Config.IdleTimeout,
+	}
 
 	if serverInstanceReady != nil {
 		serverInstanceReady()
 	}
 
-	err := server.Serv.ListenAndServe()
+	listener, err := server.listen()
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to start HTTP server")
+		return err
+	}
+
+	stopFlushingUsage := server.apiCalls.flushPeriodically(server.Storage, usageFlushInterval)
+	defer stopFlushingUsage()
+
+	err = server.Serv.Serve(listener)
 	if err != nil && err != http.ErrServerClosed {
 		log.Error().Err(err).Msg("Unable to start HTTP server")
 		return err
@@ -356,7 +643,12 @@ func (server *HTTPServer) Stop(ctx context.Context) error {
 		return fmt.Errorf("server.Serv is nil, nothing to stop")
 	}
 
-	return server.Serv.Shutdown(ctx)
+	err := server.Serv.Shutdown(ctx)
+
+	// API calls served by this instance must not be lost
+	server.apiCalls.flush(server.Storage)
+
+	return err
 }
 
 // readFeedbackRequestBody parse request body and return object with message in it
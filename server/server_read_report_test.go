@@ -15,20 +15,51 @@
 package server_test
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"sort"
 	"testing"
 	"time"
 
-	operator_utils_types "github.com/RedHatInsights/insights-operator-utils/types"
 	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
 )
 
+// firstSeenAt is the time rules of reports written at testdata.LastCheckedAt
+// were first seen
+var firstSeenAt = types.Timestamp(testdata.LastCheckedAt.UTC().Format(time.RFC3339))
+
+// expectedReportResponse returns the expected response of the report of
+// testdata.ClusterName written at testdata.LastCheckedAt with details of
+// disabling taken from the storage
+func expectedReportResponse(t *testing.T, mockStorage storage.Storage, response string) string {
+	details, err := mockStorage.ReadRuleDisableDetails(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+
+	return helpers.ExpectedReportResponse(t, response, func(rule *helpers.RuleOnReport) {
+		rule.FirstSeenAt = firstSeenAt
+
+		for _, detail := range details {
+			if !rule.Disabled || detail.RuleID != rule.Module || detail.ErrorKey != rule.ErrorKey {
+				continue
+			}
+
+			rule.DisableDetails = &helpers.RuleDisableDetails{
+				DisabledBy:    detail.DisabledBy,
+				DisabledAt:    types.Timestamp(detail.DisabledAt.Time.UTC().Format(time.RFC3339)),
+				Justification: detail.Justification,
+			}
+		}
+	})
+}
+
 func TestReadReportForClusterNonIntOrgID(t *testing.T) {
 	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
 		Method:       http.MethodGet,
@@ -80,6 +111,8 @@ func TestReadNonExistingReport(t *testing.T) {
 }
 
 func TestHttpServer_readReportForCluster_NoRules(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -107,7 +140,101 @@ func TestHttpServer_readReportForCluster_NoRules(t *testing.T) {
 	})
 }
 
+func TestHttpServer_readReportForCluster_AnalysisStatus(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report0Rules, testdata.ReportEmptyRulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	config := helpers.DefaultServerConfig
+	config.ReportAnalysisStatus = true
+
+	helpers.AssertAPIRequest(t, mockStorage, &config, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"status":"ok",
+			"report": {
+				"meta": {
+					"count": -1,
+					"last_checked_at": "` + testdata.LastCheckedAt.Format(time.RFC3339) + `",
+					"analysis_status": "analyzed"
+				},
+				"reports":[]
+			}
+		}`,
+	})
+}
+
+func TestHttpServer_readReportForCluster_AnalysisStatusFailed(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteFailedReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	config := helpers.DefaultServerConfig
+	config.ReportAnalysisStatus = true
+
+	helpers.AssertAPIRequest(t, mockStorage, &config, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"status":"ok",
+			"report": {
+				"meta": {
+					"count": -1,
+					"last_checked_at": "` + testdata.LastCheckedAt.Format(time.RFC3339) + `",
+					"analysis_status": "failed"
+				},
+				"reports":[]
+			}
+		}`,
+	})
+}
+
+func TestHttpServer_readReportForCluster_AnalysisStatusNoData(t *testing.T) {
+	config := helpers.DefaultServerConfig
+	config.ReportAnalysisStatus = true
+
+	helpers.AssertAPIRequest(t, nil, &config, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"status":"ok",
+			"report": {
+				"meta": {
+					"count": -1,
+					"last_checked_at": "",
+					"analysis_status": "no_data"
+				},
+				"reports":[]
+			}
+		}`,
+	})
+}
+
 func TestReadReportDBError(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := helpers.MustGetMockStorage(t, true)
 	closer()
 
@@ -121,7 +248,28 @@ func TestReadReportDBError(t *testing.T) {
 	})
 }
 
+func TestReadReportInconsistent(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorageWithFaults(t, true)
+	defer closer()
+
+	mockStorage.InjectFault("ReadReportForCluster", helpers.Fault{Err: types.ErrReportInconsistent})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusServiceUnavailable,
+		Body:       `{"status":"rule hits belong to another generation of the report"}`,
+		Headers:    map[string]string{"Retry-After": "1"},
+	})
+}
+
 func TestReadReport(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -141,12 +289,14 @@ func TestReadReport(t *testing.T) {
 		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
 	}, &helpers.APIResponse{
 		StatusCode:  http.StatusOK,
-		Body:        testdata.Report3RulesExpectedResponse,
+		Body:        expectedReportResponse(t, mockStorage, testdata.Report3RulesExpectedResponse),
 		BodyChecker: helpers.AssertReportResponsesEqual,
 	})
 }
 
 func TestReadRuleReport(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -174,7 +324,11 @@ func TestReadRuleReport(t *testing.T) {
 		Body: fmt.Sprintf(`{
 			"report": %v,
 			"status": "ok"
-		}`, helpers.ToJSONString(testdata.RuleOnReport1)),
+		}`, helpers.ToJSONString(helpers.RuleOnReport{
+			RuleOnReport: testdata.RuleOnReport1,
+			FirstSeenAt:  firstSeenAt,
+			Votes:        &helpers.RuleVotes{},
+		})),
 		BodyChecker: helpers.AssertRuleResponsesEqual,
 	})
 }
@@ -183,6 +337,8 @@ func TestReadRuleReport(t *testing.T) {
 // expecting the rule to be last and disabled, re-enables it and expects regular
 // response with Rule1 first again
 func TestReadReportDisableRule(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -202,7 +358,7 @@ func TestReadReportDisableRule(t *testing.T) {
 		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
 	}, &helpers.APIResponse{
 		StatusCode:  http.StatusOK,
-		Body:        testdata.Report2RulesEnabledRulesExpectedResponse,
+		Body:        expectedReportResponse(t, mockStorage, testdata.Report2RulesEnabledRulesExpectedResponse),
 		BodyChecker: helpers.AssertReportResponsesEqual,
 	})
 
@@ -221,7 +377,7 @@ func TestReadReportDisableRule(t *testing.T) {
 		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
 	}, &helpers.APIResponse{
 		StatusCode:  http.StatusOK,
-		Body:        testdata.Report2RulesDisabledRule1ExpectedResponse,
+		Body:        expectedReportResponse(t, mockStorage, testdata.Report2RulesDisabledRule1ExpectedResponse),
 		BodyChecker: helpers.AssertReportResponsesEqual,
 	})
 
@@ -240,13 +396,15 @@ func TestReadReportDisableRule(t *testing.T) {
 		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
 	}, &helpers.APIResponse{
 		StatusCode:  http.StatusOK,
-		Body:        testdata.Report2RulesEnabledRulesExpectedResponse,
+		Body:        expectedReportResponse(t, mockStorage, testdata.Report2RulesEnabledRulesExpectedResponse),
 		BodyChecker: helpers.AssertReportResponsesEqual,
 	})
 }
 
 // TestReadReportDisableRuleMultipleUsers tests behaviour of disabling rules
 func TestReadReportDisableRuleMultipleUsers(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -267,7 +425,7 @@ func TestReadReportDisableRuleMultipleUsers(t *testing.T) {
 		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
 	}, &helpers.APIResponse{
 		StatusCode:  http.StatusOK,
-		Body:        testdata.Report2RulesEnabledRulesExpectedResponse,
+		Body:        expectedReportResponse(t, mockStorage, testdata.Report2RulesEnabledRulesExpectedResponse),
 		BodyChecker: helpers.AssertReportResponsesEqual,
 	})
 
@@ -288,7 +446,7 @@ func TestReadReportDisableRuleMultipleUsers(t *testing.T) {
 		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.User2ID},
 	}, &helpers.APIResponse{
 		StatusCode:  http.StatusOK,
-		Body:        testdata.Report2RulesDisabledRule1ExpectedResponse,
+		Body:        expectedReportResponse(t, mockStorage, testdata.Report2RulesDisabledRule1ExpectedResponse),
 		BodyChecker: helpers.AssertReportResponsesEqual,
 	})
 
@@ -299,7 +457,7 @@ func TestReadReportDisableRuleMultipleUsers(t *testing.T) {
 		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
 	}, &helpers.APIResponse{
 		StatusCode:  http.StatusOK,
-		Body:        testdata.Report2RulesDisabledRule1ExpectedResponse,
+		Body:        expectedReportResponse(t, mockStorage, testdata.Report2RulesDisabledRule1ExpectedResponse),
 		BodyChecker: helpers.AssertReportResponsesEqual,
 	})
 
@@ -320,7 +478,7 @@ func TestReadReportDisableRuleMultipleUsers(t *testing.T) {
 		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.User2ID},
 	}, &helpers.APIResponse{
 		StatusCode:  http.StatusOK,
-		Body:        testdata.Report2RulesEnabledRulesExpectedResponse,
+		Body:        expectedReportResponse(t, mockStorage, testdata.Report2RulesEnabledRulesExpectedResponse),
 		BodyChecker: helpers.AssertReportResponsesEqual,
 	})
 
@@ -331,7 +489,7 @@ func TestReadReportDisableRuleMultipleUsers(t *testing.T) {
 		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
 	}, &helpers.APIResponse{
 		StatusCode:  http.StatusOK,
-		Body:        testdata.Report2RulesEnabledRulesExpectedResponse,
+		Body:        expectedReportResponse(t, mockStorage, testdata.Report2RulesEnabledRulesExpectedResponse),
 		BodyChecker: helpers.AssertReportResponsesEqual,
 	})
 
@@ -362,7 +520,7 @@ func TestReadReportDisableRuleMultipleUsers(t *testing.T) {
 		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
 	}, &helpers.APIResponse{
 		StatusCode:  http.StatusOK,
-		Body:        testdata.Report2RulesDisabledExpectedResponse,
+		Body:        expectedReportResponse(t, mockStorage, testdata.Report2RulesDisabledExpectedResponse),
 		BodyChecker: helpers.AssertReportResponsesEqual,
 	})
 
@@ -373,12 +531,14 @@ func TestReadReportDisableRuleMultipleUsers(t *testing.T) {
 		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.User2ID},
 	}, &helpers.APIResponse{
 		StatusCode:  http.StatusOK,
-		Body:        testdata.Report2RulesDisabledExpectedResponse,
+		Body:        expectedReportResponse(t, mockStorage, testdata.Report2RulesDisabledExpectedResponse),
 		BodyChecker: helpers.AssertReportResponsesEqual,
 	})
 }
 
 func TestReadReport_RuleDisableFeedback(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -398,7 +558,7 @@ func TestReadReport_RuleDisableFeedback(t *testing.T) {
 		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
 	}, &helpers.APIResponse{
 		StatusCode:  http.StatusOK,
-		Body:        testdata.Report2RulesEnabledRulesExpectedResponse,
+		Body:        expectedReportResponse(t, mockStorage, testdata.Report2RulesEnabledRulesExpectedResponse),
 		BodyChecker: helpers.AssertReportResponsesEqual,
 	})
 
@@ -427,14 +587,14 @@ func TestReadReport_RuleDisableFeedback(t *testing.T) {
 		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
 	}, &helpers.APIResponse{
 		StatusCode: http.StatusOK,
-		Body:       testdata.Report2RulesDisabledRule1WithFeedbackExpectedResponse,
+		Body:       expectedReportResponse(t, mockStorage, testdata.Report2RulesDisabledRule1WithFeedbackExpectedResponse),
 		BodyChecker: func(t testing.TB, expected, got []byte) {
 			helpers.AssertReportResponsesEqualCustomElementsChecker(
 				t, expected, got,
 				func(
 					t testing.TB,
-					expectedRules []operator_utils_types.RuleOnReport,
-					gotRules []operator_utils_types.RuleOnReport,
+					expectedRules []helpers.RuleOnReport,
+					gotRules []helpers.RuleOnReport,
 				) {
 					assert.Equal(t, len(expectedRules), len(gotRules))
 
@@ -460,3 +620,551 @@ func TestReadReport_RuleDisableFeedback(t *testing.T) {
 		},
 	})
 }
+
+// TestReadReportDisableDetails checks that disabled rules contain the time,
+// the user and the justification of disabling them
+func TestReadReportDisableDetails(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.Report2Rules,
+		testdata.Report2RulesParsed,
+		testdata.LastCheckedAt,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.DisableRuleForClusterEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"status": "ok"}`,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.DisableRuleFeedbackEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID},
+		Body:         `{"message": "test"}`,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"status": "ok", "message": "test"}`,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got []byte) {
+			var response struct {
+				Report struct {
+					Reports []struct {
+						Module         types.RuleID `json:"component"`
+						Disabled       bool         `json:"disabled"`
+						DisableDetails *struct {
+							DisabledBy    types.UserID    `json:"disabled_by"`
+							DisabledAt    types.Timestamp `json:"disabled_at"`
+							Justification string          `json:"justification"`
+						} `json:"disable_details"`
+					} `json:"reports"`
+				} `json:"report"`
+			}
+			helpers.FailOnError(t, json.Unmarshal(got, &response))
+
+			assert.Len(t, response.Report.Reports, 2)
+			for _, rule := range response.Report.Reports {
+				if !rule.Disabled {
+					assert.Nil(t, rule.DisableDetails)
+					continue
+				}
+
+				assert.Equal(t, testdata.Rule1ID, rule.Module)
+				if assert.NotNil(t, rule.DisableDetails) {
+					assert.Equal(t, testdata.UserID, rule.DisableDetails.DisabledBy)
+					assert.NotEmpty(t, rule.DisableDetails.DisabledAt)
+					assert.Equal(t, "test", rule.DisableDetails.Justification)
+				}
+			}
+		},
+	})
+}
+
+// TestReadReportFirstSeen checks that rules contain the time they were
+// reported for the cluster for the first time
+func TestReadReportFirstSeen(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	for _, lastCheckedAt := range []time.Time{testdata.LastCheckedAt, testdata.LastCheckedAt.Add(time.Hour)} {
+		err := mockStorage.WriteReportForCluster(
+			testdata.OrgID,
+			testdata.ClusterName,
+			testdata.Report2Rules,
+			testdata.Report2RulesParsed,
+			lastCheckedAt,
+			testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	expectedFirstSeenAt := types.Timestamp(testdata.LastCheckedAt.UTC().Format(time.RFC3339))
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got []byte) {
+			var response struct {
+				Report struct {
+					Reports []struct {
+						FirstSeenAt types.Timestamp `json:"first_seen_at"`
+					} `json:"reports"`
+				} `json:"report"`
+			}
+			helpers.FailOnError(t, json.Unmarshal(got, &response))
+
+			assert.Len(t, response.Report.Reports, 2)
+			for _, rule := range response.Report.Reports {
+				assert.Equal(t, expectedFirstSeenAt, rule.FirstSeenAt)
+			}
+		},
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.RuleEndpoint,
+		EndpointArgs: []interface{}{
+			testdata.OrgID,
+			testdata.ClusterName,
+			testdata.UserID,
+			fmt.Sprintf("%v|%v", testdata.Rule1ID, testdata.ErrorKey1),
+		},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got []byte) {
+			var response struct {
+				Report struct {
+					FirstSeenAt types.Timestamp `json:"first_seen_at"`
+				} `json:"report"`
+			}
+			helpers.FailOnError(t, json.Unmarshal(got, &response))
+
+			assert.Equal(t, expectedFirstSeenAt, response.Report.FirstSeenAt)
+		},
+	})
+}
+
+func TestReadReportFirstSeenDBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorageWithFaults(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.Report2Rules,
+		testdata.Report2RulesParsed,
+		testdata.LastCheckedAt,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	mockStorage.InjectFault("ReadRuleHitsFirstSeen", helpers.Fault{Err: errors.New("first seen is unavailable")})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status":"Internal Server Error"}`,
+	})
+}
+
+func TestHttpServer_readReportForCluster_WithAnnotations(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report0Rules, testdata.ReportEmptyRulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	annotation, err := mockStorage.AddClusterAnnotation(testdata.ClusterName, testdata.UserID, "upgrade is planned")
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportEndpoint + "?annotations=true",
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"status":"ok",
+			"report": {
+				"meta": {
+					"count": -1,
+					"last_checked_at": "` + testdata.LastCheckedAt.Format(time.RFC3339) + `",
+					"annotations": [{
+						"id": "` + annotation.ID + `",
+						"cluster": "` + string(testdata.ClusterName) + `",
+						"author": "` + string(testdata.UserID) + `",
+						"message": "upgrade is planned",
+						"created_at": "` + string(annotation.CreatedAt) + `"
+					}]
+				},
+				"reports":[]
+			}
+		}`,
+	})
+}
+
+func TestHttpServer_readReportForCluster_BadAnnotationsParam(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportEndpoint + "?annotations=maybe",
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body:       `{"status": "Error during parsing param 'annotations' with value 'maybe'. Error: 'boolean value expected'"}`,
+	})
+}
+
+func TestHttpServer_readReportForCluster_Paging(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.Report3Rules,
+		testdata.Report3RulesParsed,
+		testdata.LastCheckedAt,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	for _, testCase := range []struct {
+		query         string
+		expectedRules []types.RuleID
+		expectedMeta  string
+	}{
+		{"?limit=2", []types.RuleID{testdata.Rule1ID, testdata.Rule2ID}, `{"offset": 0, "limit": 2, "total": 3}`},
+		{"?limit=2&offset=2", []types.RuleID{testdata.Rule3ID}, `{"offset": 2, "limit": 2, "total": 3}`},
+		{"?offset=1", []types.RuleID{testdata.Rule2ID, testdata.Rule3ID}, `{"offset": 1, "total": 3}`},
+		{"?offset=5", []types.RuleID{}, `{"offset": 5, "total": 3}`},
+	} {
+		testCase := testCase
+
+		helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+			Method:       http.MethodGet,
+			Endpoint:     server.ReportEndpoint + testCase.query,
+			EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
+		}, &helpers.APIResponse{
+			StatusCode: http.StatusOK,
+			BodyChecker: func(t testing.TB, _, got []byte) {
+				var response struct {
+					Report struct {
+						Meta struct {
+							Count  int             `json:"count"`
+							Paging json.RawMessage `json:"paging"`
+						} `json:"meta"`
+						Reports []struct {
+							Module types.RuleID `json:"component"`
+						} `json:"reports"`
+					} `json:"report"`
+				}
+				helpers.FailOnError(t, json.Unmarshal(got, &response))
+
+				gotRules := []types.RuleID{}
+				for _, rule := range response.Report.Reports {
+					gotRules = append(gotRules, rule.Module)
+				}
+
+				assert.Equal(t, 3, response.Report.Meta.Count, testCase.query)
+				assert.JSONEq(t, testCase.expectedMeta, string(response.Report.Meta.Paging), testCase.query)
+				assert.Equal(t, testCase.expectedRules, gotRules, testCase.query)
+			},
+		})
+	}
+}
+
+func TestHttpServer_readReportForCluster_BadPagingParams(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportEndpoint + "?limit=0",
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body:       `{"status": "Error during parsing param 'limit' with value '0'. Error: 'positive integer expected'"}`,
+	})
+
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportEndpoint + "?offset=-1",
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body:       `{"status": "Error during parsing param 'offset' with value '-1'. Error: 'unsigned integer expected'"}`,
+	})
+}
+
+func TestReadReportTogglesDBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorageWithFaults(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.Report3Rules,
+		testdata.Report3RulesParsed,
+		testdata.LastCheckedAt,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	mockStorage.InjectFault("GetTogglesForRules", helpers.Fault{Err: errors.New("toggles are unavailable")})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status":"Internal Server Error"}`,
+	})
+
+	assert.Equal(t, 1, mockStorage.Calls("ReadReportForCluster"))
+	assert.Equal(t, 0, mockStorage.Calls("GetUserFeedbackOnRules"))
+}
+
+func TestReadReportDBErrorAfterCalls(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorageWithFaults(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.Report3Rules,
+		testdata.Report3RulesParsed,
+		testdata.LastCheckedAt,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	// only the second request fails
+	mockStorage.InjectFault("ReadReportForCluster", helpers.Fault{
+		Err:        errors.New("connection reset"),
+		AfterCalls: 1,
+		Times:      1,
+	})
+
+	request := &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
+	}
+	okResponse := &helpers.APIResponse{
+		StatusCode:  http.StatusOK,
+		Body:        expectedReportResponse(t, mockStorage, testdata.Report3RulesExpectedResponse),
+		BodyChecker: helpers.AssertReportResponsesEqual,
+	}
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, request, okResponse)
+	helpers.AssertAPIRequest(t, mockStorage, nil, request, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status":"Internal Server Error"}`,
+	})
+	helpers.AssertAPIRequest(t, mockStorage, nil, request, okResponse)
+
+	assert.Equal(t, 3, mockStorage.Calls("ReadReportForCluster"))
+}
+
+func TestReadReportStorageLatency(t *testing.T) {
+	const latency = 100 * time.Millisecond
+
+	mockStorage, closer := helpers.MustGetMockStorageWithFaults(t, true)
+	defer closer()
+
+	mockStorage.InjectFault("ReadReportForCluster", helpers.Fault{Latency: latency})
+
+	started := time.Now()
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+		Body: fmt.Sprintf(
+			`{"status":"Item with ID %v/%v was not found in the storage"}`, testdata.OrgID, testdata.ClusterName,
+		),
+	})
+
+	assert.GreaterOrEqual(t, int64(time.Since(started)), int64(latency))
+}
+
+func TestReadRuleReport_Votes(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.Report3Rules,
+		testdata.Report3RulesParsed,
+		testdata.LastCheckedAt,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	// another cluster of the same organization
+	cluster2 := testdata.GetRandomClusterID()
+	err = mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		cluster2,
+		testdata.Report3Rules,
+		testdata.Report3RulesParsed,
+		testdata.LastCheckedAt,
+		testdata.KafkaOffset+1,
+	)
+	helpers.FailOnError(t, err)
+
+	for _, vote := range []struct {
+		cluster types.ClusterName
+		userID  types.UserID
+		vote    types.UserVote
+	}{
+		{testdata.ClusterName, testdata.UserID, types.UserVoteDislike},
+		{testdata.ClusterName, "2", types.UserVoteDislike},
+		{testdata.ClusterName, "3", types.UserVoteLike},
+		{cluster2, "2", types.UserVoteDislike},
+	} {
+		helpers.FailOnError(t, mockStorage.VoteOnRule(
+			vote.cluster, testdata.Rule1ID, testdata.ErrorKey1, vote.userID, vote.vote, "",
+		))
+	}
+
+	type votesResponse struct {
+		Report struct {
+			Votes struct {
+				User         types.UserVote     `json:"user"`
+				Cluster      types.VoteSummary  `json:"cluster"`
+				Organization *types.VoteSummary `json:"organization"`
+			} `json:"votes"`
+		} `json:"report"`
+	}
+
+	endpointArgs := []interface{}{
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.UserID,
+		fmt.Sprintf("%v|%v", testdata.Rule1ID, testdata.ErrorKey1),
+	}
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.RuleEndpoint,
+		EndpointArgs: endpointArgs,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got []byte) {
+			var response votesResponse
+			helpers.FailOnError(t, json.Unmarshal(got, &response))
+
+			assert.Equal(t, types.UserVoteDislike, response.Report.Votes.User)
+			assert.Equal(t, types.VoteSummary{Likes: 1, Dislikes: 2}, response.Report.Votes.Cluster)
+			assert.Nil(t, response.Report.Votes.Organization)
+		},
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.RuleEndpoint + "?org_votes=true",
+		EndpointArgs: endpointArgs,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got []byte) {
+			var response votesResponse
+			helpers.FailOnError(t, json.Unmarshal(got, &response))
+
+			assert.Equal(t, types.VoteSummary{Likes: 1, Dislikes: 2}, response.Report.Votes.Cluster)
+			assert.Equal(t, &types.VoteSummary{Likes: 1, Dislikes: 3}, response.Report.Votes.Organization)
+		},
+	})
+}
+
+func TestReadRuleReport_BadOrgVotesParam(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.RuleEndpoint + "?org_votes=maybe",
+		EndpointArgs: []interface{}{
+			testdata.OrgID,
+			testdata.ClusterName,
+			testdata.UserID,
+			fmt.Sprintf("%v|%v", testdata.Rule1ID, testdata.ErrorKey1),
+		},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body: `{
+			"status": "Error during parsing param 'org_votes' with value 'maybe'. Error: 'boolean value expected'"
+		}`,
+	})
+}
+
+func TestReadRuleReport_VotesDBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorageWithFaults(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.Report3Rules,
+		testdata.Report3RulesParsed,
+		testdata.LastCheckedAt,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	mockStorage.InjectFault("ReadVoteSummaryOnRuleForOrg", helpers.Fault{Err: errors.New("votes are unavailable")})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.RuleEndpoint + "?org_votes=1",
+		EndpointArgs: []interface{}{
+			testdata.OrgID,
+			testdata.ClusterName,
+			testdata.UserID,
+			fmt.Sprintf("%v|%v", testdata.Rule1ID, testdata.ErrorKey1),
+		},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status":"Internal Server Error"}`,
+	})
+}
@@ -20,6 +20,8 @@ import (
 	"bytes"
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -28,6 +30,7 @@ import (
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	iou_helpers "github.com/RedHatInsights/insights-operator-utils/tests/helpers"
 	operator_utils_types "github.com/RedHatInsights/insights-operator-utils/types"
 	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
 	"github.com/rs/zerolog"
@@ -35,6 +38,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/RedHatInsights/insights-results-aggregator/conf"
+	"github.com/RedHatInsights/insights-results-aggregator/events"
 	"github.com/RedHatInsights/insights-results-aggregator/server"
 	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
@@ -70,6 +74,8 @@ func TestListOfClustersForNonExistingOrganization(t *testing.T) {
 }
 
 func TestListOfClustersForOrganizationOK(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -84,13 +90,74 @@ func TestListOfClustersForOrganizationOK(t *testing.T) {
 		EndpointArgs: []interface{}{testdata.OrgID},
 	}, &helpers.APIResponse{
 		StatusCode: http.StatusOK,
-		Body:       `{"clusters":["` + string(testdata.ClusterName) + `"],"status":"ok"}`,
+		Body: `{
+			"clusters":["` + string(testdata.ClusterName) + `"],
+			"cluster_classes":{"` + string(testdata.ClusterName) + `":"self-managed"},
+			"status":"ok"
+		}`,
+	})
+}
+
+func TestListOfClustersForOrganizationPaging(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	for _, cluster := range []types.ClusterName{testdata.ClusterName, testdata.GetRandomClusterID()} {
+		err := mockStorage.WriteReportForCluster(
+			testdata.OrgID, cluster, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	clusters, err := mockStorage.ListOfClustersForOrg(testdata.OrgID, time.Time{}, 0, 0)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClustersForOrganizationEndpoint + "?limit=1&offset=1",
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"clusters":["` + string(clusters[1]) + `"],
+			"cluster_classes":{"` + string(clusters[1]) + `":"self-managed"},
+			"paging":{"offset":1,"limit":1,"total":2},
+			"status":"ok"
+		}`,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClustersForOrganizationEndpoint + "?offset=2",
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"clusters":[],
+			"paging":{"offset":2,"total":2},
+			"status":"ok"
+		}`,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClustersForOrganizationEndpoint + "?limit=0",
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body: `{
+			"status": "Error during parsing param 'limit' with value '0'. Error: 'positive integer expected'"
+		}`,
 	})
 }
 
 // TestListOfClustersForOrganizationDBError expects db error
 // because the storage is closed before the query
 func TestListOfClustersForOrganizationDBError(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := helpers.MustGetMockStorage(t, true)
 	closer()
 
@@ -117,6 +184,106 @@ func TestListOfClustersForOrganizationNegativeID(t *testing.T) {
 	})
 }
 
+func TestOrganizationInfo(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	orgInfo, err := mockStorage.ReadOrgInfo(testdata.OrgID)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.OrganizationInfoEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: fmt.Sprintf(
+			`{"status":"ok","organization":{"org_id":%v,"first_seen_at":"%v","last_seen_at":"%v"}}`,
+			testdata.OrgID, orgInfo.FirstSeenAt, orgInfo.LastSeenAt,
+		),
+	})
+}
+
+func TestOrganizationInfoNotFound(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.OrganizationInfoEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+		Body:       fmt.Sprintf(`{"status":"Item with ID %v was not found in the storage"}`, testdata.OrgID),
+	})
+}
+
+func TestOrganizationInfoDBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	closer()
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.OrganizationInfoEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status": "Internal Server Error"}`,
+	})
+}
+
+func TestOrganizationOverview(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	err = mockStorage.ToggleRuleForCluster(
+		testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, storage.RuleToggleDisable,
+	)
+	helpers.FailOnError(t, err)
+
+	orgInfo, err := mockStorage.ReadOrgInfo(testdata.OrgID)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.OrganizationOverviewEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: fmt.Sprintf(
+			`{"status":"ok","overview":{"org_id":%v,"first_seen_at":"%v","last_seen_at":"%v","disabled_rules":{
+				"cluster_level":1,"org_level":1,
+				"most_disabled":[{"rule_id":"%v","error_key":"%v","clusters":1}]
+			}}}`,
+			testdata.OrgID, orgInfo.FirstSeenAt, orgInfo.LastSeenAt, testdata.Rule1ID, testdata.ErrorKey1,
+		),
+	})
+}
+
+func TestOrganizationOverviewNotFound(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.OrganizationOverviewEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+		Body:       fmt.Sprintf(`{"status":"Item with ID %v was not found in the storage"}`, testdata.OrgID),
+	})
+}
+
 func TestListOfClustersForOrganizationNonIntID(t *testing.T) {
 	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
 		Method:       http.MethodGet,
@@ -142,8 +309,9 @@ func TestMainEndpoint(t *testing.T) {
 
 func TestListOfOrganizationsEmpty(t *testing.T) {
 	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
-		Method:   http.MethodGet,
-		Endpoint: server.OrganizationsEndpoint,
+		Method:       http.MethodGet,
+		Endpoint:     server.OrganizationsEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
 	}, &helpers.APIResponse{
 		StatusCode: http.StatusOK,
 		Body:       `{"organizations":[],"status":"ok"}`,
@@ -151,6 +319,8 @@ func TestListOfOrganizationsEmpty(t *testing.T) {
 }
 
 func TestListOfOrganizationsOK(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -165,8 +335,9 @@ func TestListOfOrganizationsOK(t *testing.T) {
 	helpers.FailOnError(t, err)
 
 	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
-		Method:   http.MethodGet,
-		Endpoint: server.OrganizationsEndpoint,
+		Method:       http.MethodGet,
+		Endpoint:     server.OrganizationsEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
 	}, &helpers.APIResponse{
 		StatusCode: http.StatusOK,
 		Body:       `{"organizations":[1, 5],"status":"ok"}`,
@@ -174,12 +345,68 @@ func TestListOfOrganizationsOK(t *testing.T) {
 }
 
 func TestListOfOrganizationsDBError(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := helpers.MustGetMockStorage(t, true)
 	closer()
 
 	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
-		Method:   http.MethodGet,
-		Endpoint: server.OrganizationsEndpoint,
+		Method:       http.MethodGet,
+		Endpoint:     server.OrganizationsEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status": "Internal Server Error"}`,
+	})
+}
+
+func TestListOfOrganizationsDetailedOK(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	older := time.Date(2020, 8, 3, 10, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+
+	for _, report := range []struct {
+		orgID       types.OrgID
+		clusterName types.ClusterName
+		lastChecked time.Time
+	}{
+		{1, "8083c377-8a05-4922-af8d-e7d0970c1f49", older},
+		{1, "52ab955f-b769-444d-8170-4b676c5d3c85", newer},
+		{5, "a1bf5b15-5229-4042-9825-c69dc36b57f5", older},
+	} {
+		err := mockStorage.WriteReportForCluster(
+			report.orgID, report.clusterName, "{}", testdata.ReportEmptyRulesParsed, report.lastChecked, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.OrganizationsDetailedEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{"organizations":[
+			{"org_id": 1, "cluster_count": 2, "last_checked_at": "2020-08-03T11:00:00Z"},
+			{"org_id": 5, "cluster_count": 1, "last_checked_at": "2020-08-03T10:00:00Z"}
+		],"status":"ok"}`,
+	})
+}
+
+func TestListOfOrganizationsDetailedDBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	closer()
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.OrganizationsDetailedEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
 	}, &helpers.APIResponse{
 		StatusCode: http.StatusInternalServerError,
 		Body:       `{"status": "Internal Server Error"}`,
@@ -325,9 +552,20 @@ func TestRuleFeedbackVote_DBError(t *testing.T) {
 	mockStorage, expects := helpers.MustGetMockStorageWithExpects(t)
 	defer helpers.MustCloseMockStorageWithExpects(t, mockStorage, expects)
 
-	expects.ExpectQuery("SELECT .* FROM report").
+	// organization of the cluster is checked not to be frozen
+	expects.ExpectQuery("SELECT org_id FROM report").
 		WillReturnRows(
-			sqlmock.NewRows([]string{"cluster"}).AddRow(testdata.ClusterName),
+			sqlmock.NewRows([]string{"org_id"}).AddRow(testdata.OrgID),
+		)
+	expects.ExpectQuery("SELECT COUNT(.*) FROM org_freeze").
+		WillReturnRows(
+			sqlmock.NewRows([]string{"count"}).AddRow(0),
+		)
+
+	// existence and ownership of the cluster are checked by one query
+	expects.ExpectQuery("SELECT org_id FROM report WHERE cluster").
+		WillReturnRows(
+			sqlmock.NewRows([]string{"org_id"}).AddRow(testdata.OrgID),
 		)
 
 	expects.ExpectPrepare("INSERT INTO").
@@ -390,6 +628,7 @@ func TestHTTPServer_UserFeedback_ClusterDoesNotExistError(t *testing.T) {
 
 func TestRuleFeedbackErrorBadClusterName(t *testing.T) {
 	buf := new(bytes.Buffer)
+	defer func(logger zerolog.Logger) { log.Logger = logger }(log.Logger)
 	log.Logger = zerolog.New(buf)
 
 	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
@@ -476,6 +715,7 @@ func TestHTTPServer_GetVoteOnRule_BadRuleID(t *testing.T) {
 	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
 		Method:       http.MethodGet,
 		Endpoint:     server.GetVoteOnRuleEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
 		EndpointArgs: []interface{}{testdata.ClusterName, testdata.BadRuleID, testdata.ErrorKey1, testdata.UserID},
 	}, &helpers.APIResponse{
 		StatusCode: http.StatusBadRequest,
@@ -486,6 +726,8 @@ func TestHTTPServer_GetVoteOnRule_BadRuleID(t *testing.T) {
 }
 
 func TestHTTPServer_GetVoteOnRule_DBError(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -502,6 +744,7 @@ func TestHTTPServer_GetVoteOnRule_DBError(t *testing.T) {
 	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
 		Method:       http.MethodGet,
 		Endpoint:     server.GetVoteOnRuleEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
 		EndpointArgs: []interface{}{testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID},
 	}, &helpers.APIResponse{
 		StatusCode: http.StatusInternalServerError,
@@ -510,6 +753,8 @@ func TestHTTPServer_GetVoteOnRule_DBError(t *testing.T) {
 }
 
 func TestRuleFeedbackErrorClosedStorage(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := helpers.MustGetMockStorage(t, true)
 	closer()
 
@@ -561,6 +806,7 @@ func TestHTTPServer_GetVoteOnRule(t *testing.T) {
 			helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
 				Method:       http.MethodGet,
 				Endpoint:     server.GetVoteOnRuleEndpoint,
+				ExtraHeaders: helpers.DebugConfirmationHeaders(),
 				EndpointArgs: []interface{}{testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID},
 			}, &helpers.APIResponse{
 				StatusCode: http.StatusOK,
@@ -618,80 +864,159 @@ func TestRuleToggle(t *testing.T) {
 	}
 }
 
-func TestHTTPServer_deleteOrganizationsOK(t *testing.T) {
-	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
-		Method:       http.MethodDelete,
-		Endpoint:     server.DeleteOrganizationsEndpoint,
-		EndpointArgs: []interface{}{1},
-	}, &helpers.APIResponse{
-		StatusCode: http.StatusOK,
-		Body:       `{"status": "ok"}`,
-	})
+type ruleTogglePublisherMock struct {
+	events []events.RuleToggleEvent
+	err    error
 }
 
-func TestHTTPServer_deleteOrganizations_NonIntOrgID(t *testing.T) {
-	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
-		Method:       http.MethodDelete,
-		Endpoint:     server.DeleteOrganizationsEndpoint,
-		EndpointArgs: []interface{}{"non-int"},
-	}, &helpers.APIResponse{
-		StatusCode: http.StatusBadRequest,
-		Body:       `{"status": "Error during parsing param 'organizations' with value 'non-int'. Error: 'integer array expected'"}`,
-	})
+func (publisher *ruleTogglePublisherMock) PublishRuleToggle(event events.RuleToggleEvent) error {
+	publisher.events = append(publisher.events, event)
+	return publisher.err
 }
 
-func TestHTTPServer_deleteOrganizations_DBError(t *testing.T) {
+func TestRuleTogglePublishesEvent(t *testing.T) {
+	for _, publishErr := range []error{nil, fmt.Errorf("broker is down")} {
+		mockStorage, closer := helpers.MustGetMockStorage(t, true)
+
+		err := mockStorage.WriteReportForCluster(
+			testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+
+		publisher := &ruleTogglePublisherMock{err: publishErr}
+		testServer := server.New(helpers.DefaultServerConfig, mockStorage)
+		testServer.EventPublisher = publisher
+
+		// publishing errors must not affect the response
+		iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, &helpers.APIRequest{
+			Method:       http.MethodPut,
+			Endpoint:     server.DisableRuleForClusterEndpoint,
+			EndpointArgs: []interface{}{testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1},
+		}, &helpers.APIResponse{
+			StatusCode: http.StatusOK,
+			Body:       `{"status": "ok"}`,
+		})
+
+		iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, &helpers.APIRequest{
+			Method:       http.MethodPut,
+			Endpoint:     server.EnableRuleForClusterEndpoint,
+			EndpointArgs: []interface{}{testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1},
+		}, &helpers.APIResponse{
+			StatusCode: http.StatusOK,
+			Body:       `{"status": "ok"}`,
+		})
+
+		closer()
+
+		assert.Len(t, publisher.events, 2)
+		for i, disabled := range []bool{true, false} {
+			event := publisher.events[i]
+			assert.Equal(t, testdata.OrgID, event.OrgID)
+			assert.Equal(t, testdata.ClusterName, event.ClusterID)
+			assert.Equal(t, testdata.Rule1ID, event.RuleID)
+			assert.Equal(t, types.ErrorKey(testdata.ErrorKey1), event.ErrorKey)
+			assert.Equal(t, disabled, event.Disabled)
+			assert.False(t, event.Timestamp.IsZero())
+		}
+	}
+}
+
+func TestRuleToggleDBErrorDoesNotPublishEvent(t *testing.T) {
 	mockStorage, closer := helpers.MustGetMockStorage(t, true)
 	closer()
 
-	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
-		Method:       http.MethodDelete,
-		Endpoint:     server.DeleteOrganizationsEndpoint,
-		EndpointArgs: []interface{}{testdata.OrgID},
+	publisher := &ruleTogglePublisherMock{}
+	testServer := server.New(helpers.DefaultServerConfig, mockStorage)
+	testServer.EventPublisher = publisher
+
+	iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.DisableRuleForClusterEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1},
 	}, &helpers.APIResponse{
 		StatusCode: http.StatusInternalServerError,
 		Body:       `{"status": "Internal Server Error"}`,
 	})
+
+	assert.Empty(t, publisher.events)
 }
 
-func TestHTTPServer_deleteClusters(t *testing.T) {
-	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
-		Method:       http.MethodDelete,
-		Endpoint:     server.DeleteClustersEndpoint,
-		EndpointArgs: []interface{}{testdata.ClusterName},
+func TestRuleToggle_JustificationRequired(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	config := helpers.DefaultServerConfig
+	config.JustificationRequiredRules = []string{
+		string(testdata.Rule1ID),
+		string(testdata.Rule2ID) + "|" + string(testdata.ErrorKey2),
+	}
+
+	for _, body := range []string{"", `{"message": "  "}`} {
+		helpers.AssertAPIRequest(t, mockStorage, &config, &helpers.APIRequest{
+			Method:       http.MethodPut,
+			Endpoint:     server.DisableRuleForClusterEndpoint,
+			EndpointArgs: []interface{}{testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1},
+			Body:         body,
+		}, &helpers.APIResponse{
+			StatusCode: http.StatusUnprocessableEntity,
+			Body: iou_helpers.ToJSONString(map[string]string{"status": fmt.Sprintf(
+				`Justification is required to disable rule %v|%v, send the reason in request body as {"message": "reason"}`,
+				testdata.Rule1ID, testdata.ErrorKey1,
+			)}),
+		})
+	}
+
+	_, err = mockStorage.GetFromClusterRuleToggle(testdata.ClusterName, testdata.Rule1ID)
+	assert.Equal(t, &types.ItemNotFoundError{ItemID: testdata.Rule1ID}, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, &config, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.DisableRuleForClusterEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1},
+		Body:         `{"message": "accepted risk"}`,
 	}, &helpers.APIResponse{
 		StatusCode: http.StatusOK,
 		Body:       `{"status": "ok"}`,
 	})
-}
 
-func TestHTTPServer_deleteClusters_DBError(t *testing.T) {
-	mockStorage, closer := helpers.MustGetMockStorage(t, true)
-	closer()
+	feedback, err := mockStorage.GetUserFeedbackOnRuleDisable(testdata.ClusterName, testdata.Rule1ID, "")
+	helpers.FailOnError(t, err)
+	assert.Equal(t, "accepted risk", feedback.Message)
 
-	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
-		Method:       http.MethodDelete,
-		Endpoint:     server.DeleteClustersEndpoint,
-		EndpointArgs: []interface{}{testdata.ClusterName},
+	// other error keys of the rule don't require justification
+	helpers.AssertAPIRequest(t, mockStorage, &config, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.DisableRuleForClusterEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName, testdata.Rule2ID, testdata.ErrorKey1},
 	}, &helpers.APIResponse{
-		StatusCode: http.StatusInternalServerError,
-		Body:       `{"status": "Internal Server Error"}`,
+		StatusCode: http.StatusOK,
+		Body:       `{"status": "ok"}`,
 	})
-}
 
-func TestHTTPServer_deleteClusters_BadClusterName(t *testing.T) {
-	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
-		Method:       http.MethodDelete,
-		Endpoint:     server.DeleteClustersEndpoint,
-		EndpointArgs: []interface{}{testdata.BadClusterName},
+	// enabling the rule never requires justification
+	helpers.AssertAPIRequest(t, mockStorage, &config, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.EnableRuleForClusterEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1},
 	}, &helpers.APIResponse{
-		StatusCode: http.StatusBadRequest,
-		Body:       `{"status": "Error during parsing param 'cluster' with value 'aaaa'. Error: 'invalid UUID length: 4'"}`,
+		StatusCode: http.StatusOK,
+		Body:       `{"status": "ok"}`,
 	})
 }
 
-func TestHTTPServer_SaveDisableFeedback(t *testing.T) {
-	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+// TestRuleToggle_JustificationToggleError checks that the justification
+// isn't stored when the rule can't be disabled
+func TestRuleToggle_JustificationToggleError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorageWithFaults(t, true)
 	defer closer()
 
 	err := mockStorage.WriteReportForCluster(
@@ -699,20 +1024,332 @@ func TestHTTPServer_SaveDisableFeedback(t *testing.T) {
 	)
 	helpers.FailOnError(t, err)
 
-	const expectedFeedback = "user's feedback"
+	mockStorage.InjectFault("ToggleRuleForCluster", helpers.Fault{Err: errors.New("database is unavailable")})
 
 	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
-		Method:       http.MethodPost,
-		Endpoint:     server.DisableRuleFeedbackEndpoint,
-		EndpointArgs: []interface{}{testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID},
-		Body:         fmt.Sprintf(`{"message": "%v"}`, expectedFeedback),
+		Method:       http.MethodPut,
+		Endpoint:     server.DisableRuleForClusterEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1},
+		Body:         `{"message": "accepted risk"}`,
 	}, &helpers.APIResponse{
-		StatusCode: http.StatusOK,
-		Body:       `{"message":"user's feedback", "status":"ok"}`,
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status": "Internal Server Error"}`,
 	})
 
-	feedback, err := mockStorage.GetUserFeedbackOnRuleDisable(testdata.ClusterName, testdata.Rule1ID, testdata.UserID)
-	helpers.FailOnError(t, err)
+	_, err = mockStorage.GetUserFeedbackOnRuleDisable(testdata.ClusterName, testdata.Rule1ID, "")
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
+
+func TestRuleToggle_JustificationBadBody(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.DisableRuleForClusterEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1},
+		Body:         "not-json",
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body:       `{"status": "invalid character 'o' in literal null (expecting 'u')"}`,
+	})
+}
+
+func TestHTTPServer_ClusterAnnotations(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.AddClusterAnnotationEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName, testdata.UserID},
+		Body:         `{"message": "upgrade is planned"}`,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusCreated,
+	})
+
+	annotations, err := mockStorage.ReadClusterAnnotations(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Len(t, annotations, 1)
+	assert.Equal(t, testdata.UserID, annotations[0].Author)
+	assert.Equal(t, "upgrade is planned", annotations[0].Message)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClusterAnnotationsEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{"status": "ok", "annotations": [{
+			"id": "` + annotations[0].ID + `",
+			"cluster": "` + string(testdata.ClusterName) + `",
+			"author": "` + string(testdata.UserID) + `",
+			"message": "upgrade is planned",
+			"created_at": "` + string(annotations[0].CreatedAt) + `"
+		}]}`,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodDelete,
+		Endpoint:     server.DeleteClusterAnnotationEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName, annotations[0].ID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"status": "ok"}`,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodDelete,
+		Endpoint:     server.DeleteClusterAnnotationEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName, annotations[0].ID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+		Body:       `{"status": "Item with ID ` + annotations[0].ID + ` was not found in the storage"}`,
+	})
+}
+
+func TestHTTPServer_AddClusterAnnotation_EmptyMessage(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report0Rules, testdata.ReportEmptyRulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.AddClusterAnnotationEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName, testdata.UserID},
+		Body:         `{"message": "  "}`,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body:       `{"status": "Error during validating param 'message' with value ''. Error: 'annotation message can not be empty'"}`,
+	})
+}
+
+func TestHTTPServer_AddClusterAnnotation_ClusterNotFound(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.AddClusterAnnotationEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName, testdata.UserID},
+		Body:         `{"message": "note"}`,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+		Body:       `{"status": "Item with ID ` + string(testdata.ClusterName) + ` was not found in the storage"}`,
+	})
+}
+
+func TestHTTPServer_GetClusterAnnotations_ClusterNotFound(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClusterAnnotationsEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+		Body:       `{"status": "Item with ID ` + string(testdata.ClusterName) + ` was not found in the storage"}`,
+	})
+}
+
+func TestHTTPServer_GetClusterAnnotations_ClusterNotFoundWithAuth(t *testing.T) {
+	// unknown cluster is not found, not forbidden and not an internal error
+	helpers.AssertAPIRequest(t, nil, &helpers.DefaultServerConfigAuth, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClusterAnnotationsEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName},
+		XRHIdentity: helpers.MakeXRHTokenString(t, &types.Token{
+			Identity: operator_utils_types.Identity{
+				AccountNumber: testdata.UserID,
+				Internal: operator_utils_types.Internal{
+					OrgID: testdata.OrgID,
+				},
+			},
+		}),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+		Body:       `{"status": "Item with ID ` + string(testdata.ClusterName) + ` was not found in the storage"}`,
+	})
+}
+
+func TestHTTPServer_GetClusterAnnotations_AnotherOrganization(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report0Rules, testdata.ReportEmptyRulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, &helpers.DefaultServerConfigAuth, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClusterAnnotationsEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName},
+		XRHIdentity: helpers.MakeXRHTokenString(t, &types.Token{
+			Identity: operator_utils_types.Identity{
+				AccountNumber: testdata.UserID,
+				Internal: operator_utils_types.Internal{
+					OrgID: testdata.Org2ID,
+				},
+			},
+		}),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusForbidden,
+		Body:       `{"status":"you have no permissions to get or change info about this organization"}`,
+	})
+}
+
+func TestHTTPServer_DeleteClusterAnnotation_BadID(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodDelete,
+		Endpoint:     server.DeleteClusterAnnotationEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName, "not-uuid"},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body:       `{"status": "Error during parsing param 'annotation_id' with value 'not-uuid'. Error: 'UUID expected'"}`,
+	})
+}
+
+func TestHTTPServer_GetClusterAnnotations_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	closer()
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClusterAnnotationsEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status": "Internal Server Error"}`,
+	})
+}
+
+func TestHTTPServer_deleteOrganizationsOK(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodDelete,
+		Endpoint:     server.DeleteOrganizationsEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+		EndpointArgs: []interface{}{1},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"status": "ok"}`,
+	})
+}
+
+func TestHTTPServer_deleteOrganizations_NonIntOrgID(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodDelete,
+		Endpoint:     server.DeleteOrganizationsEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+		EndpointArgs: []interface{}{"non-int"},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body:       `{"status": "Error during parsing param 'organizations' with value 'non-int'. Error: 'integer array expected'"}`,
+	})
+}
+
+func TestHTTPServer_deleteOrganizations_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	closer()
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodDelete,
+		Endpoint:     server.DeleteOrganizationsEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status": "Internal Server Error"}`,
+	})
+}
+
+func TestHTTPServer_deleteClusters(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodDelete,
+		Endpoint:     server.DeleteClustersEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+		EndpointArgs: []interface{}{testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"status": "ok"}`,
+	})
+}
+
+func TestHTTPServer_deleteClusters_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	closer()
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodDelete,
+		Endpoint:     server.DeleteClustersEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+		EndpointArgs: []interface{}{testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status": "Internal Server Error"}`,
+	})
+}
+
+func TestHTTPServer_deleteClusters_BadClusterName(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodDelete,
+		Endpoint:     server.DeleteClustersEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+		EndpointArgs: []interface{}{testdata.BadClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body:       `{"status": "Error during parsing param 'cluster' with value 'aaaa'. Error: 'invalid UUID length: 4'"}`,
+	})
+}
+
+func TestHTTPServer_SaveDisableFeedback(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	const expectedFeedback = "user's feedback"
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.DisableRuleFeedbackEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID},
+		Body:         fmt.Sprintf(`{"message": "%v"}`, expectedFeedback),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"message":"user's feedback", "status":"ok"}`,
+	})
+
+	feedback, err := mockStorage.GetUserFeedbackOnRuleDisable(testdata.ClusterName, testdata.Rule1ID, testdata.UserID)
+	helpers.FailOnError(t, err)
 
 	assert.Equal(t, expectedFeedback, feedback.Message)
 }
@@ -732,6 +1369,8 @@ func TestHTTPServer_SaveDisableFeedback_Error_BadClusterName(t *testing.T) {
 }
 
 func TestHTTPServer_SaveDisableFeedback_Error_CheckUserClusterPermissions(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -762,6 +1401,8 @@ func TestHTTPServer_SaveDisableFeedback_Error_CheckUserClusterPermissions(t *tes
 }
 
 func TestHTTPServer_SaveDisableFeedback_Error_BadBody(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -782,6 +1423,8 @@ func TestHTTPServer_SaveDisableFeedback_Error_BadBody(t *testing.T) {
 }
 
 func TestHTTPServer_SaveDisableFeedback_Error_DBError(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := helpers.MustGetMockStorage(t, true)
 
 	err := mockStorage.WriteReportForCluster(
@@ -801,3 +1444,841 @@ func TestHTTPServer_SaveDisableFeedback_Error_DBError(t *testing.T) {
 		Body:       `{"status": "Internal Server Error"}`,
 	})
 }
+
+func TestHTTPServer_GetRuleHitOccurrences(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.RuleHitOccurrencesEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: fmt.Sprintf(
+			`{"occurrences": [{"appeared_at": "%v"}], "status": "ok"}`,
+			testdata.LastCheckedAt.UTC().Format(time.RFC3339),
+		),
+	})
+}
+
+func TestHTTPServer_GetRuleHitOccurrences_ClusterDoesNotExist(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.RuleHitOccurrencesEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+		Body:       fmt.Sprintf(`{"status": "Item with ID %v was not found in the storage"}`, testdata.ClusterName),
+	})
+}
+
+func TestHTTPServer_GetRuleResolutionRates(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	rule1 := types.ReportItem{Module: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1, TemplateData: []byte("{}")}
+
+	// the rule disappears from the second report
+	for i, rules := range [][]types.ReportItem{{rule1}, {}} {
+		err := mockStorage.WriteReportForCluster(
+			testdata.OrgID, testdata.ClusterName, testdata.Report0Rules, rules,
+			testdata.LastCheckedAt.Add(time.Duration(i)*time.Hour), testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	expectedBody := iou_helpers.ToJSONString(map[string]interface{}{
+		"resolution_rates": []types.RuleResolutionRate{{
+			RuleID:         testdata.Rule1ID,
+			ErrorKey:       testdata.ErrorKey1,
+			Hits:           1,
+			Resolved:       1,
+			ResolutionRate: 1,
+		}},
+		"status": "ok",
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.RuleResolutionRatesEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       expectedBody,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.OrganizationRuleResolutionRatesEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       expectedBody,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.OrganizationRuleResolutionRatesEndpoint,
+		EndpointArgs: []interface{}{testdata.Org2ID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"resolution_rates": [], "status": "ok"}`,
+	})
+}
+
+func TestHTTPServer_GetRuleResolutionRates_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	closer()
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.RuleResolutionRatesEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status": "Internal Server Error"}`,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.OrganizationRuleResolutionRatesEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status": "Internal Server Error"}`,
+	})
+}
+
+func TestHTTPServer_GetRuleHitOccurrences_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	closer()
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.RuleHitOccurrencesEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status": "Internal Server Error"}`,
+	})
+}
+
+func TestHTTPServer_RebuildClustersLastCheckedCache(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.AdminCacheRebuildEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"size": 1, "status": "ok"}`,
+	})
+}
+
+func TestHTTPServer_RebuildClustersLastCheckedCache_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	closer()
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.AdminCacheRebuildEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status": "Internal Server Error"}`,
+	})
+}
+
+func TestHTTPServer_GetClustersLastCheckedCacheStats(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminCacheStatsEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"stats": {
+				"size": 0,
+				"reports_in_storage": 0,
+				"age_distribution": {
+					"less_than_1h": 0,
+					"less_than_1d": 0,
+					"less_than_7d": 0,
+					"less_than_30d": 0,
+					"older": 0
+				},
+				"memory_estimate_bytes": 0
+			},
+			"status": "ok"
+		}`,
+	})
+}
+
+func TestHTTPServer_GetClustersLastCheckedCacheStats_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	closer()
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminCacheStatsEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status": "Internal Server Error"}`,
+	})
+}
+
+func TestHTTPServer_GetClustersLastCheckedDivergence(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed,
+		testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminCacheDivergenceEndpoint + "?sample=10",
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"divergence": {
+				"sampled_reports": 1,
+				"matching": 1,
+				"stale": 0,
+				"ahead": 0,
+				"missing_in_cache": 0,
+				"sampled_cache": 1,
+				"missing_in_storage": 0,
+				"max_staleness_seconds": 0,
+				"examples": []
+			},
+			"status": "ok"
+		}`,
+	})
+}
+
+func TestHTTPServer_GetClustersLastCheckedDivergence_BadSample(t *testing.T) {
+	for _, sample := range []string{"0", "901", "all"} {
+		helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+			Method:       http.MethodGet,
+			Endpoint:     server.AdminCacheDivergenceEndpoint + "?sample=" + sample,
+			ExtraHeaders: helpers.DebugConfirmationHeaders(),
+		}, &helpers.APIResponse{
+			StatusCode: http.StatusBadRequest,
+		})
+	}
+}
+
+func TestHTTPServer_AdminCacheEndpointsRequireAdminKey(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	// the confirmation header is not enough when debug endpoints are disabled
+	helpers.AssertAPIRequest(t, mockStorage, &configAPIKeyAuth, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.AdminCacheRebuildEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusUnauthorized,
+	})
+
+	_, adminKey, err := server.CreateAPIKey(mockStorage, "admin", []string{server.APIKeyScopeAdmin}, time.Time{})
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, &configAPIKeyAuth, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.AdminCacheRebuildEndpoint,
+		ExtraHeaders: apiKeyHeaders(adminKey),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"size": 0, "status": "ok"}`,
+	})
+}
+
+func TestHTTPServer_GetStaleReportWrites(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	cluster2 := testdata.GetRandomClusterID()
+	for _, write := range []struct {
+		orgID       types.OrgID
+		clusterName types.ClusterName
+	}{
+		{testdata.OrgID, testdata.ClusterName},
+		{testdata.OrgID, cluster2},
+		{testdata.OrgID, cluster2},
+		{testdata.Org2ID, testdata.ClusterName},
+	} {
+		err := mockStorage.WriteStaleReport(write.orgID, write.clusterName, testdata.LastCheckedAt, time.Time{})
+		helpers.FailOnError(t, err)
+	}
+
+	staleWrites, err := mockStorage.ReadStaleReportWrites()
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminStaleWritesEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: iou_helpers.ToJSONString(map[string]interface{}{
+			"organizations": []map[string]interface{}{
+				{
+					"org_id":         testdata.OrgID,
+					"rejected_count": 3,
+					"clusters":       staleWrites[:2],
+				},
+				{
+					"org_id":         testdata.Org2ID,
+					"rejected_count": 1,
+					"clusters":       staleWrites[2:],
+				},
+			},
+			"status": "ok",
+		}),
+	})
+}
+
+func TestHTTPServer_GetStaleReportWrites_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	closer()
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminStaleWritesEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status": "Internal Server Error"}`,
+	})
+}
+
+func TestHTTPServer_GetRawRuleHits(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	ruleHits, err := mockStorage.ReadRuleHitsForCluster(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminClusterRuleHitsEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+		EndpointArgs: []interface{}{testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: iou_helpers.ToJSONString(map[string]interface{}{
+			"rule_hits": ruleHits,
+			"status":    "ok",
+		}),
+	})
+}
+
+func TestHTTPServer_GetRawRuleHits_ClusterNotFound(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminClusterRuleHitsEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+		EndpointArgs: []interface{}{testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+		Body:       fmt.Sprintf(`{"status": "Item with ID %v was not found in the storage"}`, testdata.ClusterName),
+	})
+}
+
+func TestHTTPServer_GetRawRuleHits_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorageWithFaults(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	mockStorage.InjectFault("ReadRuleHitsForCluster", helpers.Fault{Err: errors.New("database is unavailable")})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminClusterRuleHitsEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+		EndpointArgs: []interface{}{testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status": "Internal Server Error"}`,
+	})
+}
+
+func TestUserFeedbackForClusters(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	cluster2 := testdata.GetRandomClusterID()
+
+	for _, clusterName := range []types.ClusterName{testdata.ClusterName, cluster2} {
+		err := mockStorage.WriteReportForCluster(
+			testdata.OrgID, clusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	err := mockStorage.VoteOnRule(
+		testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID, types.UserVoteLike, "helpful",
+	)
+	helpers.FailOnError(t, err)
+
+	feedback, err := mockStorage.GetUserFeedbackOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.UserFeedbackForClustersEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.UserID},
+		Body:         fmt.Sprintf(`{"clusters": ["%v", "%v"]}`, testdata.ClusterName, cluster2),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: fmt.Sprintf(`{"status": "ok", "feedback": {
+			"%v": [{
+				"rule_id": "%v",
+				"error_key": "%v",
+				"user_vote": %d,
+				"message": "helpful",
+				"updated_at": "%v"
+			}],
+			"%v": []
+		}}`,
+			testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, types.UserVoteLike,
+			feedback.UpdatedAt.UTC().Format(time.RFC3339), cluster2,
+		),
+	})
+}
+
+func TestUserFeedbackForClustersWrongOrganization(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.Org2ID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.UserFeedbackForClustersEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.UserID},
+		Body:         fmt.Sprintf(`{"clusters": ["%v"]}`, testdata.ClusterName),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body:       `{"status": "Improper organization ID"}`,
+	})
+}
+
+func TestUserFeedbackForClustersBadClusterID(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.UserFeedbackForClustersEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.UserID},
+		Body:         `{"clusters": ["not a real cluster"]}`,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body:       `{"status": "invalid cluster ID: 'not a real cluster'. Error: invalid UUID length: 18"}`,
+	})
+}
+
+func TestUserFeedbackForClustersDBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorageWithFaults(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	mockStorage.InjectFault("GetUserFeedbackOnRulesForClusters", helpers.Fault{Err: errors.New("db is down")})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.UserFeedbackForClustersEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.UserID},
+		Body:         fmt.Sprintf(`{"clusters": ["%v"]}`, testdata.ClusterName),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status": "Internal Server Error"}`,
+	})
+}
+
+func TestHTTPServer_OrgUsage(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	// the calls are counted by the server until the usage is read
+	testServer := server.New(helpers.DefaultServerConfig, mockStorage)
+
+	// the call is accounted even when the report doesn't exist
+	iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+	})
+
+	iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.OrganizationInfoEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+	})
+
+	month := storage.UsageMonth(time.Now())
+
+	iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminOrgUsageEndpoint + "?month=" + month,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: iou_helpers.ToJSONString(map[string]interface{}{
+			"usage": []types.OrgUsage{{
+				OrgID:    testdata.OrgID,
+				Month:    month,
+				APICalls: 2,
+			}},
+			"status": "ok",
+		}),
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminOrgUsageEndpoint + "?month=2000-01",
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"usage": [], "status": "ok"}`,
+	})
+}
+
+func TestHTTPServer_OrgUsage_StoreError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorageWithFaults(t, true)
+	defer closer()
+
+	testServer := server.New(helpers.DefaultServerConfig, mockStorage)
+
+	iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.OrganizationInfoEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+	})
+
+	// the calls are kept in memory until they can be stored
+	mockStorage.InjectFault("AddOrgUsage", helpers.Fault{Err: errors.New("database is unreachable"), Times: 1})
+
+	for _, expectedUsage := range []string{
+		`{"usage": [], "status": "ok"}`,
+		iou_helpers.ToJSONString(map[string]interface{}{
+			"usage": []types.OrgUsage{{
+				OrgID:    testdata.OrgID,
+				Month:    storage.UsageMonth(time.Now()),
+				APICalls: 1,
+			}},
+			"status": "ok",
+		}),
+	} {
+		iou_helpers.AssertAPIRequest(t, testServer, helpers.DefaultServerConfig.APIPrefix, &helpers.APIRequest{
+			Method:       http.MethodGet,
+			Endpoint:     server.AdminOrgUsageEndpoint,
+			ExtraHeaders: helpers.DebugConfirmationHeaders(),
+		}, &helpers.APIResponse{
+			StatusCode: http.StatusOK,
+			Body:       expectedUsage,
+		})
+	}
+}
+
+func TestHTTPServer_OrgUsage_BadMonth(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminOrgUsageEndpoint + "?month=october",
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body:       `{"status": "Error during parsing param 'month' with value 'october'. Error: 'month in YYYY-MM format expected'"}`,
+	})
+}
+
+func TestHTTPServer_OrgUsage_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorageWithFaults(t, true)
+	defer closer()
+
+	mockStorage.InjectFault("ReadOrgUsage", helpers.Fault{Err: errors.New("database is unreachable")})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminOrgUsageEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status": "Internal Server Error"}`,
+	})
+}
+
+func TestHTTPServer_GetClusterOrgChanges(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	lastChecked := time.Now().Add(-time.Hour).UTC()
+
+	for i, orgID := range []types.OrgID{testdata.OrgID, testdata.Org2ID} {
+		err := mockStorage.WriteReportForCluster(
+			orgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed,
+			lastChecked.Add(time.Duration(i)*time.Minute), testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminClusterOrgChangesEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: iou_helpers.ToJSONString(map[string]interface{}{
+			"changes": []types.ClusterOrgChange{{
+				ClusterName:   testdata.ClusterName,
+				PreviousOrgID: testdata.OrgID,
+				OrgID:         testdata.Org2ID,
+				ChangedAt:     types.Timestamp(lastChecked.Add(time.Minute).Format(time.RFC3339)),
+				Resolution:    storage.ClusterOrgConflictMove,
+			}},
+			"status": "ok",
+		}),
+	})
+
+	// the change is older than 0 days
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminClusterOrgChangesEndpoint + "?days=0",
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"changes": [], "status": "ok"}`,
+	})
+}
+
+func TestHTTPServer_GetDBSchema(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	var response struct {
+		Status string         `json:"status"`
+		Schema types.DBSchema `json:"schema"`
+	}
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminSchemaEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got []byte) {
+			helpers.FailOnError(t, json.Unmarshal(got, &response))
+		},
+	})
+
+	assert.Equal(t, "ok", response.Status)
+	assert.NotEqual(t, uint(0), response.Schema.MigrationVersion)
+	assert.Equal(t, response.Schema.LatestMigrationVersion, response.Schema.MigrationVersion)
+
+	tableNames := make([]string, 0, len(response.Schema.Tables))
+	for _, table := range response.Schema.Tables {
+		tableNames = append(tableNames, table.Name)
+	}
+	assert.Subset(t, tableNames, []string{"report", "rule_hit", "cluster_rule_toggle", "migration_info"})
+}
+
+func TestHTTPServer_GetDBSchema_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorageWithFaults(t, true)
+	defer closer()
+
+	mockStorage.InjectFault("ReadDBSchema", helpers.Fault{Err: errors.New("database is unavailable")})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminSchemaEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status": "Internal Server Error"}`,
+	})
+}
+
+func TestHTTPServer_GetDBSchema_NotSupported(t *testing.T) {
+	helpers.AssertAPIRequest(t, storage.NewMemoryStorage(), nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminSchemaEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusServiceUnavailable,
+		Body:       `{"status": "DB schema introspection is not supported by the storage"}`,
+	})
+}
+
+func TestHTTPServer_LookupMessageKey(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.Report3Rules,
+		testdata.Report3RulesParsed,
+		testdata.LastCheckedAt,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	err = mockStorage.WriteReportMessageKey(
+		testdata.OrgID, testdata.ClusterName, testdata.LastCheckedAt, string(testdata.ClusterName),
+	)
+	helpers.FailOnError(t, err)
+
+	var response struct {
+		Status     string                 `json:"status"`
+		MessageKey types.MessageKeyLookup `json:"message_key"`
+	}
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminMessageKeyEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName},
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got []byte) {
+			helpers.FailOnError(t, json.Unmarshal(got, &response))
+		},
+	})
+
+	assert.Equal(t, "ok", response.Status)
+	assert.Equal(t, string(testdata.ClusterName), response.MessageKey.Key)
+	if assert.Len(t, response.MessageKey.Reports, 1) {
+		assert.Equal(t, testdata.OrgID, response.MessageKey.Reports[0].OrgID)
+		assert.Equal(t, testdata.ClusterName, response.MessageKey.Reports[0].ClusterName)
+	}
+	assert.Empty(t, response.MessageKey.ConsumerErrors)
+}
+
+func TestHTTPServer_LookupMessageKey_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorageWithFaults(t, true)
+	defer closer()
+
+	mockStorage.InjectFault("LookupMessageKey", helpers.Fault{Err: errors.New("database is unavailable")})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminMessageKeyEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName},
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status": "Internal Server Error"}`,
+	})
+}
+
+func TestHTTPServer_GetClusterOrgChanges_BadDays(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminClusterOrgChangesEndpoint + "?days=week",
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body:       `{"status": "Error during parsing param 'days' with value 'week'. Error: 'unsigned integer expected'"}`,
+	})
+}
+
+func TestHTTPServer_GetClusterOrgChanges_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	closer()
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AdminClusterOrgChangesEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Body:       `{"status": "Internal Server Error"}`,
+	})
+}
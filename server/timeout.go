@@ -0,0 +1,184 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+)
+
+// requestTimeoutMessage is returned in body of response when the request was
+// not processed before its deadline
+const requestTimeoutMessage = "Request was not processed in time"
+
+// timeoutWriter buffers the response written by handler, so it can be thrown
+// away when the handler does not finish before the deadline
+type timeoutWriter struct {
+	mutex    sync.Mutex
+	header   http.Header
+	body     bytes.Buffer
+	status   int
+	timedOut bool
+}
+
+// Header returns the header map of the buffered response
+func (writer *timeoutWriter) Header() http.Header {
+	return writer.header
+}
+
+// Write writes the data into the buffered response
+func (writer *timeoutWriter) Write(data []byte) (int, error) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+
+	if writer.status == 0 {
+		writer.status = http.StatusOK
+	}
+
+	return writer.body.Write(data)
+}
+
+// WriteHeader sets the status code of the buffered response
+func (writer *timeoutWriter) WriteHeader(statusCode int) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.timedOut || writer.status != 0 {
+		return
+	}
+
+	writer.status = statusCode
+}
+
+// isStreamedEndpoint checks whether the endpoint streams its response, so it
+// can't be buffered
+func isStreamedEndpoint(endpoint string) bool {
+	return endpoint == RuleHitsExportEndpoint
+}
+
+// endpointTimeout returns the deadline for processing of the request to the
+// endpoint, 0 means no deadline
+func (server *HTTPServer) endpointTimeout(endpoint string) time.Duration {
+	if timeout, found := server.Config.EndpointTimeouts[endpoint]; found {
+		return timeout
+	}
+
+	switch endpoint {
+	case ReportForListOfClustersEndpoint, ReportForListOfClustersPayloadEndpoint:
+		return server.Config.BulkRequestTimeout
+	case MetricsEndpoint:
+		return 0
+	case RuleHitsExportEndpoint:
+		return server.Config.ExportTimeout
+	}
+
+	// profiling can take much longer than any API request
+	if strings.HasPrefix(endpoint, "/debug/pprof/") {
+		return 0
+	}
+
+	return server.Config.RequestTimeout
+}
+
+// Deadline is a middleware that cancels context of the request when it is not
+// processed in time (see endpointTimeout) and responds with 504 Gateway
+// Timeout. The handler keeps running in the background, but its response is
+// thrown away. Responses of streamed endpoints are not buffered, their
+// handlers have to stop streaming when the context is cancelled.
+func (server *HTTPServer) Deadline(nextHandler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		endpoint := server.routeEndpoint(request)
+
+		timeout := server.endpointTimeout(endpoint)
+		if timeout <= 0 {
+			nextHandler.ServeHTTP(writer, request)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(request.Context(), timeout)
+		defer cancel()
+
+		if isStreamedEndpoint(endpoint) {
+			nextHandler.ServeHTTP(writer, request.WithContext(ctx))
+			return
+		}
+
+		bufferedWriter := &timeoutWriter{header: make(http.Header)}
+		done := make(chan struct{})
+		panicked := make(chan interface{}, 1)
+
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- p
+				}
+			}()
+
+			nextHandler.ServeHTTP(bufferedWriter, request.WithContext(ctx))
+			close(done)
+		}()
+
+		select {
+		case p := <-panicked:
+			panic(p)
+		case <-done:
+			bufferedWriter.mutex.Lock()
+			defer bufferedWriter.mutex.Unlock()
+
+			for key, values := range bufferedWriter.header {
+				writer.Header()[key] = values
+			}
+
+			if bufferedWriter.status == 0 {
+				bufferedWriter.status = http.StatusOK
+			}
+			writer.WriteHeader(bufferedWriter.status)
+
+			if _, err := writer.Write(bufferedWriter.body.Bytes()); err != nil {
+				log.Error().Err(err).Msg(responseDataError)
+			}
+		case <-ctx.Done():
+			bufferedWriter.mutex.Lock()
+			bufferedWriter.timedOut = true
+			bufferedWriter.mutex.Unlock()
+
+			log.Error().
+				Err(ctx.Err()).
+				Str("url", request.URL.String()).
+				Dur("timeout", timeout).
+				Msg("Request was not processed before its deadline")
+
+			err := responses.Send(
+				http.StatusGatewayTimeout, writer, responses.BuildResponse(requestTimeoutMessage),
+			)
+			if err != nil {
+				log.Error().Err(err).Msg(responseDataError)
+			}
+		}
+	})
+}
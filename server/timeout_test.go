@@ -0,0 +1,137 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+)
+
+func timeoutServerConfig(requestTimeout, bulkRequestTimeout time.Duration) *server.Configuration {
+	config := helpers.DefaultServerConfig
+	config.RequestTimeout = requestTimeout
+	config.BulkRequestTimeout = bulkRequestTimeout
+
+	return &config
+}
+
+// TestReadReportTimeout checks that the slow request is responded with 504
+func TestReadReportTimeout(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorageWithFaults(t, true)
+	defer closer()
+
+	mockStorage.InjectFault("ReadReportForCluster", helpers.Fault{Latency: 500 * time.Millisecond})
+
+	helpers.AssertAPIRequest(t, mockStorage, timeoutServerConfig(10*time.Millisecond, 0), &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusGatewayTimeout,
+		Body:       `{"status":"Request was not processed in time"}`,
+	})
+}
+
+// TestReadReportWithinDeadline checks that response of the request processed
+// before its deadline is not changed
+func TestReadReportWithinDeadline(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorageWithFaults(t, true)
+	defer closer()
+
+	mockStorage.InjectFault("ReadReportForCluster", helpers.Fault{Latency: 10 * time.Millisecond})
+
+	helpers.AssertAPIRequest(t, mockStorage, timeoutServerConfig(5*time.Second, 0), &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+		Body: fmt.Sprintf(
+			`{"status":"Item with ID %v/%v was not found in the storage"}`, testdata.OrgID, testdata.ClusterName,
+		),
+	})
+}
+
+// TestReadReportsForClustersBulkDeadline checks that the endpoints returning
+// reports for multiple clusters use the bulk request deadline
+func TestReadReportsForClustersBulkDeadline(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorageWithFaults(t, true)
+	defer closer()
+
+	mockStorage.InjectFault("ReadReportsForClusters", helpers.Fault{Latency: 100 * time.Millisecond})
+
+	helpers.AssertAPIRequest(t, mockStorage, timeoutServerConfig(10*time.Millisecond, 5*time.Second), &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportForListOfClustersEndpoint,
+		EndpointArgs: []interface{}{1, testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"clusters": null,"errors": ["84f7eedc-0dd8-49cd-9d4d-f6646df3a5bc"],"reports": {},"generated_at": "","status": "OK"
+		}`,
+	})
+}
+
+// TestExportRuleHitsTimeout checks that the streamed export uses the export
+// deadline and 504 is returned when nothing was exported before it expired
+func TestExportRuleHitsTimeout(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorageWithFaults(t, true)
+	defer closer()
+
+	mustWriteExportedRuleHits(t, mockStorage)
+	mockStorage.InjectFault("IterateRuleHitsForRule", helpers.Fault{Latency: 100 * time.Millisecond})
+
+	config := timeoutServerConfig(5*time.Second, 0)
+	config.ExportTimeout = 10 * time.Millisecond
+
+	helpers.AssertAPIRequest(t, mockStorage, config, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.RuleHitsExportEndpoint,
+		EndpointArgs: []interface{}{testdata.Rule1ID, testdata.ErrorKey1},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusGatewayTimeout,
+		Body:       `{"status":"Request was not processed in time"}`,
+	})
+}
+
+// TestEndpointTimeoutOverride checks that the deadline configured for the
+// endpoint is used instead of the default one
+func TestEndpointTimeoutOverride(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorageWithFaults(t, true)
+	defer closer()
+
+	mockStorage.InjectFault("ReadReportForCluster", helpers.Fault{Latency: 100 * time.Millisecond})
+
+	config := timeoutServerConfig(10*time.Millisecond, 0)
+	config.EndpointTimeouts = map[string]time.Duration{server.ReportEndpoint: 5 * time.Second}
+
+	helpers.AssertAPIRequest(t, mockStorage, config, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+		Body: fmt.Sprintf(
+			`{"status":"Item with ID %v/%v was not found in the storage"}`, testdata.OrgID, testdata.ClusterName,
+		),
+	})
+}
@@ -0,0 +1,129 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const (
+	// topRulesWindowQueryParam is the length of the time window the top
+	// rules are computed for, number of days with d suffix (7d) or a duration
+	// (12h)
+	topRulesWindowQueryParam = "window"
+	// topRulesOrgQueryParam selects the organization whose clusters are
+	// counted, clusters of all organizations are counted when it's missing
+	topRulesOrgQueryParam = "org"
+	// topRulesLimitQueryParam is the maximal number of the top rules returned
+	topRulesLimitQueryParam = "limit"
+	// defaultTopRulesWindow is used when the window is not specified
+	defaultTopRulesWindow = 7 * 24 * time.Hour
+	// defaultTopRulesLimit is used when the limit is not specified
+	defaultTopRulesLimit = 10
+)
+
+// parseTopRulesWindow parses the length of the time window, number of days
+// with d suffix or any duration accepted by time.ParseDuration
+func parseTopRulesWindow(value string) (time.Duration, bool) {
+	if strings.HasSuffix(value, "d") {
+		days, err := strconv.ParseUint(strings.TrimSuffix(value, "d"), 10, 16)
+		if err != nil || days == 0 {
+			return 0, false
+		}
+
+		return time.Duration(days) * 24 * time.Hour, true
+	}
+
+	window, err := time.ParseDuration(value)
+	if err != nil || window <= 0 {
+		return 0, false
+	}
+
+	return window, true
+}
+
+// topRulesWindowParam defines optional query parameter with the length of
+// the time window
+func topRulesWindowParam(window *time.Duration) param {
+	return param{
+		name:   topRulesWindowQueryParam,
+		source: queryParam,
+		parse: func(rawValue string) error {
+			value, valid := parseTopRulesWindow(rawValue)
+			if !valid {
+				return errors.New("positive number of days (7d) or duration (12h) expected")
+			}
+
+			*window = value
+			return nil
+		},
+	}
+}
+
+// getTopRules returns rules affecting the most clusters in the time window
+// ending now together with the number of affected clusters in the previous
+// window of the same length and the difference of both numbers. Clusters of
+// all organizations are counted unless the organization is specified.
+func (server *HTTPServer) getTopRules(writer http.ResponseWriter, request *http.Request) {
+	var (
+		window     = defaultTopRulesWindow
+		orgID      int
+		orgPresent bool
+		limit      = defaultTopRulesLimit
+	)
+	if !readParams(writer, request,
+		topRulesWindowParam(&window),
+		uintQueryParam(topRulesOrgQueryParam, &orgID, &orgPresent),
+		positiveIntQueryParam(topRulesLimitQueryParam, &limit, nil),
+	) {
+		// everything has been handled already
+		return
+	}
+
+	to := time.Now().UTC()
+	from := to.Add(-window)
+
+	var rules []types.TopRule
+	var err error
+
+	if orgPresent {
+		rules, err = server.Storage.ReadTopRulesForOrg(types.OrgID(orgID), from, to, limit)
+	} else {
+		rules, err = server.Storage.ReadTopRules(from, to, limit)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read top rules")
+		handleServerError(writer, err)
+		return
+	}
+
+	response := responses.BuildOkResponseWithData("top_rules", rules)
+	response["from"] = types.Timestamp(from.Format(time.RFC3339))
+	response["to"] = types.Timestamp(to.Format(time.RFC3339))
+
+	err = responses.SendOK(writer, response)
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
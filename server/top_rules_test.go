@@ -0,0 +1,153 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// topRulesResponse is the body of the response of TopRulesEndpoint
+type topRulesResponse struct {
+	Status   string          `json:"status"`
+	TopRules []types.TopRule `json:"top_rules"`
+	From     types.Timestamp `json:"from"`
+	To       types.Timestamp `json:"to"`
+}
+
+// assertTopRulesRequest requests the top rules with the query by API key
+// with admin scope, as the weekly report does, and returns the parsed
+// response
+func assertTopRulesRequest(t *testing.T, mockStorage *ira_helpers.FaultInjectingStorage, query string) topRulesResponse {
+	var response topRulesResponse
+
+	_, key, err := server.CreateAPIKey(mockStorage, "weekly report", []string{server.APIKeyScopeAdmin}, time.Time{})
+	helpers.FailOnError(t, err)
+
+	ira_helpers.AssertAPIRequest(t, mockStorage, &configAPIKeyAuth, &ira_helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.TopRulesEndpoint + query,
+		ExtraHeaders: apiKeyHeaders(key),
+	}, &ira_helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		BodyChecker: func(t testing.TB, _, got []byte) {
+			helpers.FailOnError(t, json.Unmarshal(got, &response))
+		},
+	})
+
+	return response
+}
+
+func TestHTTPServer_GetTopRules(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorageWithFaults(t, true)
+	defer closer()
+
+	rule1 := types.ReportItem{Module: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1, TemplateData: []byte("{}")}
+	rule2 := types.ReportItem{Module: testdata.Rule2ID, ErrorKey: testdata.ErrorKey2, TemplateData: []byte("{}")}
+
+	// rule1 affects the cluster in the current window only, rule2 since the
+	// previous window
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report0Rules, []types.ReportItem{rule2},
+		time.Now().Add(-8*24*time.Hour), testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	err = mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report0Rules, []types.ReportItem{rule1, rule2},
+		time.Now().Add(-time.Hour), testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	response := assertTopRulesRequest(t, mockStorage, "")
+	assert.Equal(t, "ok", response.Status)
+	assert.Equal(t, []types.TopRule{
+		{RuleID: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1, Clusters: 1, PreviousClusters: 0, Delta: 1},
+		{RuleID: testdata.Rule2ID, ErrorKey: testdata.ErrorKey2, Clusters: 1, PreviousClusters: 1, Delta: 0},
+	}, response.TopRules)
+
+	from, err := time.Parse(time.RFC3339, string(response.From))
+	helpers.FailOnError(t, err)
+	to, err := time.Parse(time.RFC3339, string(response.To))
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 7*24*time.Hour, to.Sub(from))
+
+	// both rules affect the cluster in both short windows, the first rule by
+	// rule ID is returned
+	response = assertTopRulesRequest(t, mockStorage, "?window=30m&limit=1")
+	assert.Equal(t, []types.TopRule{
+		{RuleID: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1, Clusters: 1, PreviousClusters: 1, Delta: 0},
+	}, response.TopRules)
+
+	response = assertTopRulesRequest(t, mockStorage, "?window=2d&org=12345")
+	assert.Empty(t, response.TopRules)
+
+	response = assertTopRulesRequest(t, mockStorage, fmt.Sprintf("?window=2d&org=%v", testdata.OrgID))
+	assert.Len(t, response.TopRules, 2)
+}
+
+func TestHTTPServer_GetTopRules_BadParams(t *testing.T) {
+	for query, body := range map[string]string{
+		"?window=week": `{"status": "Error during parsing param 'window' with value 'week'. Error: 'positive number of days (7d) or duration (12h) expected'"}`,
+		"?window=0d":   `{"status": "Error during parsing param 'window' with value '0d'. Error: 'positive number of days (7d) or duration (12h) expected'"}`,
+		"?window=-1h":  `{"status": "Error during parsing param 'window' with value '-1h'. Error: 'positive number of days (7d) or duration (12h) expected'"}`,
+		"?org=first":   `{"status": "Error during parsing param 'org' with value 'first'. Error: 'unsigned integer expected'"}`,
+		"?limit=0":     `{"status": "Error during parsing param 'limit' with value '0'. Error: 'positive integer expected'"}`,
+	} {
+		ira_helpers.AssertAPIRequest(t, nil, nil, &ira_helpers.APIRequest{
+			Method:       http.MethodGet,
+			Endpoint:     server.TopRulesEndpoint + query,
+			ExtraHeaders: ira_helpers.DebugConfirmationHeaders(),
+		}, &ira_helpers.APIResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       body,
+		})
+	}
+}
+
+func TestHTTPServer_GetTopRules_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorageWithFaults(t, true)
+	defer closer()
+
+	mockStorage.InjectFault("ReadTopRules", ira_helpers.Fault{Err: errors.New("database is unavailable")})
+	mockStorage.InjectFault("ReadTopRulesForOrg", ira_helpers.Fault{Err: errors.New("database is unavailable")})
+
+	for _, query := range []string{"", "?org=1"} {
+		ira_helpers.AssertAPIRequest(t, mockStorage, nil, &ira_helpers.APIRequest{
+			Method:       http.MethodGet,
+			Endpoint:     server.TopRulesEndpoint + query,
+			ExtraHeaders: ira_helpers.DebugConfirmationHeaders(),
+		}, &ira_helpers.APIResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       `{"status": "Internal Server Error"}`,
+		})
+	}
+}
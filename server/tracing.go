@@ -0,0 +1,116 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+)
+
+// traceParentHeader is W3C Trace Context header carrying the trace ID
+// of the request
+const traceParentHeader = "traceparent"
+
+// invalidTraceID is reserved by W3C Trace Context and never identifies a trace
+const invalidTraceID = "00000000000000000000000000000000"
+
+// parseTraceParent returns the trace ID from the value of traceparent header
+// in format version-traceid-parentid-flags, for example
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func parseTraceParent(traceParent string) (string, bool) {
+	parts := strings.Split(strings.TrimSpace(traceParent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return "", false
+	}
+
+	traceID := parts[1]
+	if len(traceID) != len(invalidTraceID) || traceID == invalidTraceID || !isLowerHex(traceID) {
+		return "", false
+	}
+
+	return traceID, true
+}
+
+// isLowerHex returns true when the string contains only lowercase
+// hexadecimal digits
+func isLowerHex(value string) bool {
+	for _, char := range value {
+		if !(char >= '0' && char <= '9') && !(char >= 'a' && char <= 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// routeEndpoint returns the endpoint the request was routed to, that is path
+// template of the route without API prefix
+func (server *HTTPServer) routeEndpoint(request *http.Request) string {
+	route := mux.CurrentRoute(request)
+	if route == nil {
+		return ""
+	}
+
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimPrefix(template, server.Config.APIPrefix)
+}
+
+// MeasureDuration is a middleware that observes duration of the request in
+// metrics. When tracing is enabled, the trace ID of the request is stored in
+// its context and attached to the observations as an exemplar.
+func (server *HTTPServer) MeasureDuration(nextHandler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if server.Config.Tracing {
+			if traceID, found := parseTraceParent(request.Header.Get(traceParentHeader)); found {
+				request = request.WithContext(metrics.ContextWithTraceID(request.Context(), traceID))
+			}
+		}
+
+		startTime := time.Now()
+		nextHandler.ServeHTTP(writer, request)
+
+		metrics.ObserveWithContext(
+			request.Context(),
+			metrics.APIRequestDurations.With(prometheus.Labels{"endpoint": server.routeEndpoint(request)}),
+			time.Since(startTime).Seconds(),
+		)
+	})
+}
+
+// metricsHandler returns handler of the endpoint with Prometheus metrics,
+// exemplars are available only in OpenMetrics format
+func (server *HTTPServer) metricsHandler() http.Handler {
+	if !server.Config.Tracing {
+		return promhttp.Handler()
+	}
+
+	return promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}),
+	)
+}
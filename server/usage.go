@@ -0,0 +1,179 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const (
+	usageMonthQueryParam = "month"
+	// usageFlushInterval is the period the API calls counted in memory are
+	// added to the stored usage
+	usageFlushInterval = 30 * time.Second
+)
+
+// apiCallCounter counts API calls of organizations in memory, so requests
+// don't wait for the database. The counts are added to the stored usage of
+// the current month periodically and when the server is stopped.
+type apiCallCounter struct {
+	mutex sync.Mutex
+	calls map[types.OrgID]int64
+}
+
+// newAPICallCounter constructs counter without any calls
+func newAPICallCounter() *apiCallCounter {
+	return &apiCallCounter{calls: map[types.OrgID]int64{}}
+}
+
+// add adds calls of the organization
+func (counter *apiCallCounter) add(orgID types.OrgID, calls int64) {
+	counter.mutex.Lock()
+	defer counter.mutex.Unlock()
+
+	counter.calls[orgID] += calls
+}
+
+// flush adds the counted calls to the stored usage, calls that can't be
+// stored are kept for the next flush
+func (counter *apiCallCounter) flush(storage storage.Storage) {
+	counter.mutex.Lock()
+	calls := counter.calls
+	counter.calls = map[types.OrgID]int64{}
+	counter.mutex.Unlock()
+
+	for orgID, count := range calls {
+		if err := storage.AddOrgUsage(orgID, 0, 0, count); err != nil {
+			log.Error().Err(err).Msg("Unable to record organization usage")
+			counter.add(orgID, count)
+		}
+	}
+}
+
+// flushPeriodically flushes the counted calls every interval until the
+// returned function is called
+func (counter *apiCallCounter) flushPeriodically(storage storage.Storage, interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				counter.flush(storage)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
+
+// UsageAccounting is a middleware that adds every served API call to the
+// monthly usage of the organization that made it. The organization is taken
+// from the identity of the user or from the organization ID in the URL, calls
+// that can't be attributed to any organization are not accounted. The calls
+// are counted in memory and stored periodically.
+func (server *HTTPServer) UsageAccounting(nextHandler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		nextHandler.ServeHTTP(writer, request)
+
+		orgID, found := requestOrgID(request)
+		if !found {
+			return
+		}
+
+		server.apiCalls.add(orgID, 1)
+	})
+}
+
+// requestOrgID returns the organization which made the request
+func requestOrgID(request *http.Request) (types.OrgID, bool) {
+	if identity, ok := request.Context().Value(types.ContextKeyUser).(Identity); ok {
+		if identity.Internal.OrgID != 0 {
+			return identity.Internal.OrgID, true
+		}
+	}
+
+	// both names of the variable are used by the endpoints
+	for _, name := range []string{"org_id", "organization"} {
+		var orgID types.OrgID
+		if orgIDParamNamed(name, &orgID).read(request) == nil {
+			return orgID, true
+		}
+	}
+
+	return 0, false
+}
+
+// usageMonthParam defines optional query parameter with the month in
+// YYYY-MM format
+func usageMonthParam(month *string) param {
+	return param{
+		name:   usageMonthQueryParam,
+		source: queryParam,
+		parse: func(rawValue string) error {
+			if _, err := time.Parse(storage.UsageMonthFormat, rawValue); err != nil {
+				return errors.New("month in YYYY-MM format expected")
+			}
+
+			*month = rawValue
+			return nil
+		},
+	}
+}
+
+// getOrgUsage returns numbers of processed messages, stored bytes and served
+// API calls of all organizations in the requested month, so the cost of the
+// service can be attributed to the organizations
+func (server *HTTPServer) getOrgUsage(writer http.ResponseWriter, request *http.Request) {
+	// current month is used when it's not specified
+	month := storage.UsageMonth(time.Now())
+	if !readParams(writer, request, usageMonthParam(&month)) {
+		// everything has been handled already
+		return
+	}
+
+	// calls counted by this instance are included in the usage, calls
+	// counted by other instances are stored in the next flush
+	server.apiCalls.flush(server.Storage)
+
+	usages, err := server.Storage.ReadOrgUsage(month)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read organization usage")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("usage", usages))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
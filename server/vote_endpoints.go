@@ -16,6 +16,7 @@ package server
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/RedHatInsights/insights-operator-utils/responses"
 	"github.com/rs/zerolog/log"
@@ -51,12 +52,6 @@ func (server *HTTPServer) voteOnRule(writer http.ResponseWriter, request *http.R
 		return
 	}
 
-	successful = server.checkUserClusterPermissions(writer, request, clusterID)
-	if !successful {
-		// everything has been handled already
-		return
-	}
-
 	voteMessage, successful := server.readFeedbackRequestBody(writer, request)
 	if !successful {
 		// everything has been handled already
@@ -88,19 +83,97 @@ func (server *HTTPServer) getVoteOnRule(writer http.ResponseWriter, request *htt
 		return
 	}
 
-	successful = server.checkUserClusterPermissions(writer, request, clusterID)
+	userFeedbackOnRule, err := server.Storage.GetUserFeedbackOnRule(clusterID, ruleID, errorKey, userID)
+	if err != nil {
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("vote", userFeedbackOnRule.UserVote))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// ruleFeedback is feedback left by the user on one rule of the cluster
+type ruleFeedback struct {
+	RuleID    types.RuleID    `json:"rule_id"`
+	ErrorKey  types.ErrorKey  `json:"error_key"`
+	UserVote  types.UserVote  `json:"user_vote"`
+	Message   string          `json:"message"`
+	UpdatedAt types.Timestamp `json:"updated_at"`
+}
+
+// userFeedbackForClusters returns feedback left by the user on rules of all
+// clusters specified in request body, so clients don't need to ask for
+// every cluster separately
+func (server *HTTPServer) userFeedbackForClusters(writer http.ResponseWriter, request *http.Request) {
+	orgID, successful := readOrganizationID(writer, request, server.Config.Auth)
 	if !successful {
 		// everything has been handled already
 		return
 	}
 
-	userFeedbackOnRule, err := server.Storage.GetUserFeedbackOnRule(clusterID, ruleID, errorKey, userID)
+	userID, successful := readUserID(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	clusters, successful := readClusterListFromBody(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	for _, clusterID := range clusters {
+		if err := validateClusterID(clusterID); err != nil {
+			sendWrongClusterIDResponse(writer, err)
+			return
+		}
+	}
+
+	clusterNames := constructClusterNames(clusters)
+
+	orgIDs, err := server.Storage.ReadOrgIDsOfClusters(clusterNames)
 	if err != nil {
+		log.Error().Err(err).Msg("Unable to read org IDs for list of clusters")
 		handleServerError(writer, err)
 		return
 	}
 
-	err = responses.SendOK(writer, responses.BuildOkResponseWithData("vote", userFeedbackOnRule.UserVote))
+	// all clusters must belong to the organization
+	for _, id := range orgIDs {
+		if id != orgID {
+			sendWrongClusterOrgIDResponse(writer, id)
+			return
+		}
+	}
+
+	feedbacks, err := server.Storage.GetUserFeedbackOnRulesForClusters(clusterNames, userID)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read user feedback for list of clusters")
+		handleServerError(writer, err)
+		return
+	}
+
+	// clusters without any feedback are returned with empty list
+	feedbacksPerCluster := make(map[types.ClusterName][]ruleFeedback, len(clusterNames))
+	for _, clusterName := range clusterNames {
+		feedbacksPerCluster[clusterName] = []ruleFeedback{}
+	}
+
+	for _, feedback := range feedbacks {
+		feedbacksPerCluster[feedback.ClusterID] = append(feedbacksPerCluster[feedback.ClusterID], ruleFeedback{
+			RuleID:    feedback.RuleID,
+			ErrorKey:  feedback.ErrorKey,
+			UserVote:  feedback.UserVote,
+			Message:   feedback.Message,
+			UpdatedAt: types.Timestamp(feedback.UpdatedAt.UTC().Format(time.RFC3339)),
+		})
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("feedback", feedbacksPerCluster))
 	if err != nil {
 		log.Error().Err(err).Msg(responseDataError)
 	}
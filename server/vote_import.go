@@ -0,0 +1,179 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// maxImportedVotes is the maximal number of votes in one import request, all
+// votes are written in one transaction
+const maxImportedVotes = 10000
+
+// importedVote is one vote (or feedback) in the request body of the votes
+// import. Timestamps are optional, the time of the import is used when they
+// are not set.
+type importedVote struct {
+	ClusterID types.ClusterName `json:"cluster"`
+	RuleID    types.RuleID      `json:"rule_id"`
+	ErrorKey  types.ErrorKey    `json:"error_key"`
+	UserID    types.UserID      `json:"user_id"`
+	UserVote  types.UserVote    `json:"user_vote"`
+	Message   string            `json:"message"`
+	AddedAt   *time.Time        `json:"added_at"`
+	UpdatedAt *time.Time        `json:"updated_at"`
+}
+
+// votesImport is the request body of the votes import
+type votesImport struct {
+	Votes []importedVote `json:"votes"`
+}
+
+// importVotes writes a batch of votes and feedback (migrated from the legacy
+// system, for example). All votes are validated the same way as votes sent
+// by users first and then written in one transaction.
+func (server *HTTPServer) importVotes(writer http.ResponseWriter, request *http.Request) {
+	var body votesImport
+
+	if request.ContentLength <= 0 {
+		handleServerError(writer, &NoBodyError{})
+		return
+	}
+
+	err := json.NewDecoder(request.Body).Decode(&body)
+	if err != nil {
+		handleServerError(writer, err)
+		return
+	}
+
+	feedbacks, err := server.validateImportedVotes(body.Votes, time.Now())
+	if err != nil {
+		handleServerError(writer, err)
+		return
+	}
+
+	clusterNames := make([]types.ClusterName, 0, len(feedbacks))
+	for i := range feedbacks {
+		clusterNames = append(clusterNames, feedbacks[i].ClusterID)
+	}
+
+	if server.rejectFrozenClusters(writer, request, clusterNames...) {
+		return
+	}
+
+	err = server.Storage.ImportUserFeedback(feedbacks)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to import votes")
+		handleServerError(writer, err)
+		return
+	}
+
+	log.Info().Int("votes", len(feedbacks)).Msg("Votes imported")
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("imported", len(feedbacks)))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// validateImportedVotes checks all imported votes and converts them to user
+// feedback records, the first invalid vote is reported as ValidationError
+func (server *HTTPServer) validateImportedVotes(
+	votes []importedVote, now time.Time,
+) ([]storage.UserFeedbackOnRule, error) {
+	if len(votes) == 0 || len(votes) > maxImportedVotes {
+		return nil, &types.ValidationError{
+			ParamName:  "votes",
+			ParamValue: len(votes),
+			ErrString:  fmt.Sprintf("between 1 and %d votes expected", maxImportedVotes),
+		}
+	}
+
+	feedbacks := make([]storage.UserFeedbackOnRule, 0, len(votes))
+
+	for i, vote := range votes {
+		invalid := func(param string, value interface{}, errString string) error {
+			return &types.ValidationError{
+				ParamName:  fmt.Sprintf("votes[%d].%s", i, param),
+				ParamValue: value,
+				ErrString:  errString,
+			}
+		}
+
+		if err := validateClusterID(string(vote.ClusterID)); err != nil {
+			return nil, invalid("cluster", vote.ClusterID, err.Error())
+		}
+		if !ruleIDValidator.MatchString(string(vote.RuleID)) {
+			return nil, invalid("rule_id", vote.RuleID, "invalid rule ID")
+		}
+		if !ruleIDValidator.MatchString(string(vote.ErrorKey)) {
+			return nil, invalid("error_key", vote.ErrorKey, "invalid error key")
+		}
+
+		userID := types.UserID(strings.TrimSpace(string(vote.UserID)))
+		if userID == "" {
+			return nil, invalid("user_id", vote.UserID, "user ID is empty")
+		}
+
+		switch vote.UserVote {
+		case types.UserVoteDislike, types.UserVoteNone, types.UserVoteLike:
+		default:
+			return nil, invalid("user_vote", int(vote.UserVote), "-1, 0 or 1 expected")
+		}
+
+		if len(vote.Message) > server.Config.MaximumFeedbackMessageLength {
+			return nil, invalid("message", vote.Message[0:server.Config.MaximumFeedbackMessageLength]+"...",
+				fmt.Sprintf("feedback message is longer than %v bytes", server.Config.MaximumFeedbackMessageLength),
+			)
+		}
+
+		addedAt, updatedAt := now, now
+		if vote.AddedAt != nil {
+			addedAt = *vote.AddedAt
+		}
+		if vote.UpdatedAt != nil {
+			updatedAt = *vote.UpdatedAt
+		} else if vote.AddedAt != nil {
+			updatedAt = addedAt
+		}
+
+		if updatedAt.Before(addedAt) {
+			return nil, invalid("updated_at", updatedAt.Format(time.RFC3339), "vote can't be updated before it was added")
+		}
+
+		feedbacks = append(feedbacks, storage.UserFeedbackOnRule{
+			ClusterID: vote.ClusterID,
+			RuleID:    vote.RuleID,
+			ErrorKey:  vote.ErrorKey,
+			UserID:    userID,
+			Message:   vote.Message,
+			UserVote:  vote.UserVote,
+			AddedAt:   addedAt,
+			UpdatedAt: updatedAt,
+		})
+	}
+
+	return feedbacks, nil
+}
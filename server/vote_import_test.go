@@ -0,0 +1,161 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func TestHTTPServer_importVotes(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed,
+		testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.AdminVotesImportEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+		Body: `{"votes": [
+			{
+				"cluster": "` + string(testdata.ClusterName) + `",
+				"rule_id": "` + string(testdata.Rule1ID) + `",
+				"error_key": "` + string(testdata.ErrorKey1) + `",
+				"user_id": "` + string(testdata.UserID) + `",
+				"user_vote": 1,
+				"message": "migrated",
+				"added_at": "2020-08-03T10:00:00Z",
+				"updated_at": "2020-08-03T11:00:00Z"
+			},
+			{
+				"cluster": "` + string(testdata.ClusterName) + `",
+				"rule_id": "` + string(testdata.Rule2ID) + `",
+				"error_key": "` + string(testdata.ErrorKey2) + `",
+				"user_id": "` + string(testdata.UserID) + `",
+				"user_vote": -1
+			}
+		]}`,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"imported": 2, "status": "ok"}`,
+	})
+
+	feedback, err := mockStorage.GetUserFeedbackOnRule(
+		testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID,
+	)
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, types.UserVoteLike, feedback.UserVote)
+	assert.Equal(t, "migrated", feedback.Message)
+	assert.True(t, time.Date(2020, 8, 3, 10, 0, 0, 0, time.UTC).Equal(feedback.AddedAt))
+	assert.True(t, time.Date(2020, 8, 3, 11, 0, 0, 0, time.UTC).Equal(feedback.UpdatedAt))
+
+	feedback, err = mockStorage.GetUserFeedbackOnRule(
+		testdata.ClusterName, testdata.Rule2ID, testdata.ErrorKey2, testdata.UserID,
+	)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.UserVoteDislike, feedback.UserVote)
+}
+
+func TestHTTPServer_importVotesValidation(t *testing.T) {
+	validVote := `"cluster": "` + string(testdata.ClusterName) + `",
+		"rule_id": "` + string(testdata.Rule1ID) + `",
+		"error_key": "` + string(testdata.ErrorKey1) + `",
+		"user_id": "` + string(testdata.UserID) + `"`
+
+	for _, testCase := range []struct {
+		name         string
+		body         string
+		expectedBody string
+	}{
+		{
+			name:         "no votes",
+			body:         `{"votes": []}`,
+			expectedBody: `{"status": "Error during validating param 'votes' with value '0'. Error: 'between 1 and 10000 votes expected'"}`,
+		},
+		{
+			name: "invalid vote",
+			body: `{"votes": [{` + validVote + `, "user_vote": 1}, {` + validVote + `, "user_vote": 5}]}`,
+			expectedBody: `{"status": "Error during validating param 'votes[1].user_vote' with value '5'. ` +
+				`Error: '-1, 0 or 1 expected'"}`,
+		},
+		{
+			name: "invalid rule ID",
+			body: `{"votes": [{"cluster": "` + string(testdata.ClusterName) + `", "rule_id": "rule-1", ` +
+				`"error_key": "KEY", "user_id": "1", "user_vote": 1}]}`,
+			expectedBody: `{"status": "Error during validating param 'votes[0].rule_id' with value 'rule-1'. ` +
+				`Error: 'invalid rule ID'"}`,
+		},
+		{
+			name: "updated before added",
+			body: `{"votes": [{` + validVote + `, "user_vote": 1, ` +
+				`"added_at": "2020-08-03T10:00:00Z", "updated_at": "2020-08-03T09:00:00Z"}]}`,
+			expectedBody: `{"status": "Error during validating param 'votes[0].updated_at' with value ` +
+				`'2020-08-03T09:00:00Z'. Error: 'vote can't be updated before it was added'"}`,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+				Method:       http.MethodPost,
+				Endpoint:     server.AdminVotesImportEndpoint,
+				ExtraHeaders: helpers.DebugConfirmationHeaders(),
+				Body:         testCase.body,
+			}, &helpers.APIResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       testCase.expectedBody,
+			})
+		})
+	}
+}
+
+func TestHTTPServer_importVotesRequiresAdminKey(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	// the confirmation header is not enough when debug endpoints are disabled
+	helpers.AssertAPIRequest(t, mockStorage, &configAPIKeyAuth, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.AdminVotesImportEndpoint,
+		ExtraHeaders: helpers.DebugConfirmationHeaders(),
+		Body:         `{"votes": []}`,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusUnauthorized,
+	})
+
+	_, adminKey, err := server.CreateAPIKey(mockStorage, "admin", []string{server.APIKeyScopeAdmin}, time.Time{})
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, &configAPIKeyAuth, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.AdminVotesImportEndpoint,
+		ExtraHeaders: apiKeyHeaders(adminKey),
+		Body:         `{"votes": []}`,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+	})
+}
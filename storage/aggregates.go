@@ -0,0 +1,111 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// AggregatesRecomputation contains numbers of records rebuilt by
+// RecomputeAggregates
+type AggregatesRecomputation struct {
+	Organizations     int64 `json:"organizations"`
+	RuleHitsFirstSeen int64 `json:"rule_hits_first_seen"`
+	CachedClusters    int   `json:"cached_clusters"`
+}
+
+// RecomputeAggregates rebuilds data derived from reports and rule hits,
+// which become stale after bulk imports or replays of messages. The first
+// and the last time the reports from organizations were received (org_info
+// table) are extended by times of the stored reports and rule hits without
+// the time they were first seen take it from the history of rule hits. The
+// tables are rebuilt in one transaction. The cache of timestamps when
+// the clusters were last checked is rebuilt only after the transaction is
+// committed, so it never contains data that were rolled back.
+func (storage DBStorage) RecomputeAggregates() (AggregatesRecomputation, error) {
+	var recomputation AggregatesRecomputation
+
+	ctx, cancel := storage.operationContext(aggregationOperation)
+	defer cancel()
+
+	tx, err := storage.connection.BeginTx(ctx, nil)
+	if err != nil {
+		return recomputation, types.ConvertDBError(err, nil)
+	}
+
+	// the WHERE clause is needed by SQLite to parse ON CONFLICT after SELECT
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO org_info (org_id, first_seen_at, last_seen_at)
+		SELECT org_id, MIN(reported_at), MAX(reported_at) FROM report
+		WHERE reported_at IS NOT NULL
+		GROUP BY org_id
+		ON CONFLICT (org_id) DO UPDATE SET
+			first_seen_at = CASE
+				WHEN excluded.first_seen_at < org_info.first_seen_at THEN excluded.first_seen_at
+				ELSE org_info.first_seen_at
+			END,
+			last_seen_at = CASE
+				WHEN excluded.last_seen_at > org_info.last_seen_at THEN excluded.last_seen_at
+				ELSE org_info.last_seen_at
+			END;
+	`)
+	if err == nil {
+		recomputation.Organizations, err = result.RowsAffected()
+	}
+
+	if err == nil && storage.schemaAtLeast(ruleHitFirstSeenVersion) {
+		result, err = tx.ExecContext(ctx, `
+			UPDATE rule_hit SET first_seen_at = (
+				SELECT MIN(history.appeared_at) FROM rule_hit_history AS history
+				WHERE history.org_id = rule_hit.org_id
+					AND history.cluster_id = rule_hit.cluster_id
+					AND history.rule_fqdn = rule_hit.rule_fqdn
+					AND history.error_key = rule_hit.error_key
+			)
+			WHERE first_seen_at IS NULL AND EXISTS (
+				SELECT 1 FROM rule_hit_history AS history
+				WHERE history.org_id = rule_hit.org_id
+					AND history.cluster_id = rule_hit.cluster_id
+					AND history.rule_fqdn = rule_hit.rule_fqdn
+					AND history.error_key = rule_hit.error_key
+			);
+		`)
+		if err == nil {
+			recomputation.RuleHitsFirstSeen, err = result.RowsAffected()
+		}
+	}
+
+	finishTransaction(tx, err)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to recompute aggregates")
+		return recomputation, types.ConvertDBError(err, nil)
+	}
+
+	recomputation.CachedClusters, err = storage.RebuildClustersLastCheckedCache()
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to rebuild clusters last checked cache")
+		return recomputation, err
+	}
+
+	log.Info().
+		Int64("organizations", recomputation.Organizations).
+		Int64("rule_hits_first_seen", recomputation.RuleHitsFirstSeen).
+		Int("cached_clusters", recomputation.CachedClusters).
+		Msg("Aggregates have been recomputed")
+
+	return recomputation, nil
+}
@@ -0,0 +1,189 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// apiKeyScopesSeparator separates scopes of API key stored in one column
+const apiKeyScopesSeparator = ","
+
+// errAPIKeysNotSupported is returned by writes of API keys before the
+// database is migrated
+var errAPIKeysNotSupported = fmt.Errorf("API keys are not supported before DB migration %d", apiKeyVersion)
+
+// CreateAPIKey stores new API key with hash of its secret. Zero expiration
+// time means that the key never expires.
+func (storage DBStorage) CreateAPIKey(
+	keyID, name string, scopes []string, keyHash string, expiresAt time.Time,
+) error {
+	if !storage.schemaAtLeast(apiKeyVersion) {
+		return errAPIKeysNotSupported
+	}
+
+	ctx, cancel := storage.operationContext(writeOperation)
+	defer cancel()
+
+	expiration := types.NullTime{Time: expiresAt, Valid: !expiresAt.IsZero()}
+
+	_, err := storage.connection.ExecContext(ctx, `
+		INSERT INTO api_key (key_id, name, key_hash, scopes, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6);
+	`, keyID, name, keyHash, strings.Join(scopes, apiKeyScopesSeparator), time.Now(), expiration.SQL())
+
+	return types.ConvertDBError(err, keyID)
+}
+
+// RotateAPIKey replaces hash of the secret of the API key, so the previous
+// secret can't be used anymore. ItemNotFoundError is returned when the key
+// doesn't exist or it is revoked.
+func (storage DBStorage) RotateAPIKey(keyID, keyHash string) error {
+	if !storage.schemaAtLeast(apiKeyVersion) {
+		return errAPIKeysNotSupported
+	}
+
+	return storage.updateAPIKey(keyID, `
+		UPDATE api_key SET key_hash = $1, rotated_at = $2
+		WHERE key_id = $3 AND revoked_at IS NULL;
+	`, keyHash, time.Now(), keyID)
+}
+
+// RevokeAPIKey revokes the API key, so it can't be used anymore. The key is
+// kept, so it's still listed. ItemNotFoundError is returned when the key
+// doesn't exist or it is revoked already.
+func (storage DBStorage) RevokeAPIKey(keyID string) error {
+	if !storage.schemaAtLeast(apiKeyVersion) {
+		return errAPIKeysNotSupported
+	}
+
+	return storage.updateAPIKey(keyID, `
+		UPDATE api_key SET revoked_at = $1
+		WHERE key_id = $2 AND revoked_at IS NULL;
+	`, time.Now(), keyID)
+}
+
+// updateAPIKey runs the update of one API key and checks it was updated
+func (storage DBStorage) updateAPIKey(keyID, query string, args ...interface{}) error {
+	ctx, cancel := storage.operationContext(writeOperation)
+	defer cancel()
+
+	result, err := storage.connection.ExecContext(ctx, query, args...)
+	if err != nil {
+		return types.ConvertDBError(err, keyID)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return types.ConvertDBError(err, keyID)
+	}
+
+	if updated == 0 {
+		return &types.ItemNotFoundError{ItemID: keyID}
+	}
+
+	return nil
+}
+
+// ReadAPIKey returns the API key together with hash of its secret.
+// ItemNotFoundError is returned when the key doesn't exist or the database
+// is not migrated yet.
+func (storage DBStorage) ReadAPIKey(keyID string) (types.APIKey, string, error) {
+	if !storage.schemaAtLeast(apiKeyVersion) {
+		return types.APIKey{}, "", &types.ItemNotFoundError{ItemID: keyID}
+	}
+
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
+	row := storage.connection.QueryRowContext(ctx, `
+		SELECT key_id, name, key_hash, scopes, created_at, expires_at, rotated_at, revoked_at
+		FROM api_key WHERE key_id = $1;
+	`, keyID)
+
+	key, keyHash, err := scanAPIKey(row)
+	if err != nil {
+		return key, keyHash, types.ConvertDBError(err, keyID)
+	}
+
+	return key, keyHash, nil
+}
+
+// ReadAPIKeys returns all API keys, including the expired and revoked ones,
+// ordered by the time they were created
+func (storage DBStorage) ReadAPIKeys() ([]types.APIKey, error) {
+	keys := make([]types.APIKey, 0)
+
+	if !storage.schemaAtLeast(apiKeyVersion) {
+		return keys, nil
+	}
+
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
+	rows, err := storage.readConnection().QueryContext(ctx, `
+		SELECT key_id, name, key_hash, scopes, created_at, expires_at, rotated_at, revoked_at
+		FROM api_key ORDER BY created_at, key_id;
+	`)
+	if err != nil {
+		return keys, err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		key, _, err := scanAPIKey(rows)
+		if err != nil {
+			return keys, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// rowScanner is implemented by both sql.Row and sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanAPIKey reads the API key and hash of its secret from the row
+func scanAPIKey(row rowScanner) (types.APIKey, string, error) {
+	var (
+		key                             types.APIKey
+		keyHash, scopes                 string
+		createdAt                       time.Time
+		expiresAt, rotatedAt, revokedAt types.NullTime
+	)
+
+	err := row.Scan(
+		&key.KeyID, &key.Name, &keyHash, &scopes, &createdAt, &expiresAt, &rotatedAt, &revokedAt,
+	)
+	if err != nil {
+		return key, "", err
+	}
+
+	key.Scopes = strings.Split(scopes, apiKeyScopesSeparator)
+	key.CreatedAt = types.Timestamp(createdAt.UTC().Format(time.RFC3339))
+	key.ExpiresAt = expiresAt.Timestamp()
+	key.RotatedAt = rotatedAt.Timestamp()
+	key.RevokedAt = revokedAt.Timestamp()
+
+	return key, keyHash, nil
+}
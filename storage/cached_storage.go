@@ -0,0 +1,508 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// results of reads of the cache used as result label of CachedReportReads
+// metric
+const (
+	cachedReadHit   = "hit"
+	cachedReadMiss  = "miss"
+	cachedReadError = "error"
+)
+
+// defaultRedisCacheTTL and defaultRedisCacheTimeout are used when no TTL or
+// timeout is configured
+const (
+	defaultRedisCacheTTL     = time.Hour
+	defaultRedisCacheTimeout = time.Second
+)
+
+// ErrCacheMiss is returned by CacheBackend when the key is not cached
+var ErrCacheMiss = errors.New("key not found in the cache")
+
+// CacheBackend stores values cached by CachedStorage
+type CacheBackend interface {
+	// Get returns the value of the key or ErrCacheMiss
+	Get(key string) ([]byte, error)
+	// Set stores the value of the key for the given time
+	Set(key string, value []byte, ttl time.Duration) error
+	// Counters returns values of the counters stored in the keys, counters
+	// that are not stored are 0
+	Counters(keys ...string) ([]int64, error)
+	// Increment increments the counters stored in the keys, the counters
+	// expire after the given time
+	Increment(ttl time.Duration, keys ...string) error
+	Close() error
+}
+
+// redisCacheBackend stores values in Redis
+type redisCacheBackend struct {
+	client  *redis.Client
+	timeout time.Duration
+}
+
+// NewRedisCacheBackend connects to Redis with the given URL, every command
+// has to finish in the given timeout
+func NewRedisCacheBackend(url string, timeout time.Duration) (CacheBackend, error) {
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	if timeout <= 0 {
+		timeout = defaultRedisCacheTimeout
+	}
+
+	return &redisCacheBackend{client: redis.NewClient(options), timeout: timeout}, nil
+}
+
+// Get returns the value of the key or ErrCacheMiss
+func (backend *redisCacheBackend) Get(key string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), backend.timeout)
+	defer cancel()
+
+	value, err := backend.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, ErrCacheMiss
+	}
+
+	return value, err
+}
+
+// Set stores the value of the key for the given time
+func (backend *redisCacheBackend) Set(key string, value []byte, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), backend.timeout)
+	defer cancel()
+
+	return backend.client.Set(ctx, key, value, ttl).Err()
+}
+
+// Counters returns values of the counters stored in the keys, counters that
+// are not stored are 0
+func (backend *redisCacheBackend) Counters(keys ...string) ([]int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), backend.timeout)
+	defer cancel()
+
+	values, err := backend.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	counters := make([]int64, len(keys))
+	for i, value := range values {
+		// missing keys are returned as nil
+		if value, ok := value.(string); ok {
+			counters[i], err = strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return counters, nil
+}
+
+// Increment increments the counters stored in the keys in one transaction,
+// the counters expire after the given time
+func (backend *redisCacheBackend) Increment(ttl time.Duration, keys ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), backend.timeout)
+	defer cancel()
+
+	_, err := backend.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Incr(ctx, key)
+			pipe.Expire(ctx, key, ttl)
+		}
+		return nil
+	})
+
+	return err
+}
+
+// Close closes connections to Redis
+func (backend *redisCacheBackend) Close() error {
+	return backend.client.Close()
+}
+
+// cachedReport is the report of the cluster as it is stored in the cache
+type cachedReport struct {
+	OrgID       types.OrgID     `json:"org_id"`
+	LastChecked types.Timestamp `json:"last_checked"`
+	Rules       []cachedRule    `json:"rules"`
+}
+
+// cachedRule is the rule hit of the cached report, template data are kept
+// unchanged (including order of their keys and whitespace), so they are
+// stored as a string
+type cachedRule struct {
+	Module       types.RuleID   `json:"component"`
+	ErrorKey     types.ErrorKey `json:"key"`
+	TemplateData string         `json:"details"`
+}
+
+// primaryReader is implemented by storages that can read from the read
+// replica, reports are cached only when read from the primary
+type primaryReader interface {
+	Primary() Storage
+}
+
+// CachedStorage caches reports read by ReadReportForCluster and
+// ReadReportForClusterByClusterName in the cache backend (Redis), so reads of
+// the same report don't access the database.
+//
+// Every cached report is stored under the key containing the generation of
+// the cached report of the cluster. The generation is read before the report
+// is read from the database and it's incremented after the report is
+// written or deleted and after rules are toggled for the cluster, so a
+// report read before the write is stored under the key of the previous
+// generation that is never read again. Reports deleted or archived by
+// retention invalidate the generation of all reports. All instances of the
+// service writing reports have to use CachedStorage with the same backend.
+//
+// Reports are cached only when read from the primary database, so the report
+// read from lagging read replica can't be cached instead of the newer one.
+// Errors of the backend are only logged, the database is used then.
+type CachedStorage struct {
+	Storage
+	backend   CacheBackend
+	ttl       time.Duration
+	keyPrefix string
+}
+
+// NewCachedStorage constructs storage caching reports of the given storage in
+// the backend for the given time
+func NewCachedStorage(storage Storage, backend CacheBackend, ttl time.Duration, keyPrefix string) *CachedStorage {
+	if ttl <= 0 {
+		ttl = defaultRedisCacheTTL
+	}
+
+	return &CachedStorage{
+		Storage:   storage,
+		backend:   backend,
+		ttl:       ttl,
+		keyPrefix: keyPrefix,
+	}
+}
+
+// generationKey returns key of the counter of generations of the cached
+// report of the cluster
+func (storage *CachedStorage) generationKey(clusterName types.ClusterName) string {
+	return fmt.Sprintf("%sgeneration:%s", storage.keyPrefix, clusterName)
+}
+
+// allReportsGenerationKey returns key of the counter of generations of all
+// cached reports
+func (storage *CachedStorage) allReportsGenerationKey() string {
+	return storage.keyPrefix + "generation"
+}
+
+// generationTTL returns expiration of the counters of generations. The
+// counter has to outlive reports cached under its previous value, otherwise
+// the counter starts again from 0 and a stale report cached under the
+// generation 0 would be read.
+func (storage *CachedStorage) generationTTL() time.Duration {
+	return 2 * storage.ttl
+}
+
+// reportKey returns key of the cached report of the cluster in the current
+// generation, empty key is returned when the generation can't be read
+func (storage *CachedStorage) reportKey(clusterName types.ClusterName) string {
+	generations, err := storage.backend.Counters(storage.allReportsGenerationKey(), storage.generationKey(clusterName))
+	if err != nil {
+		log.Error().Err(err).Str("cluster", string(clusterName)).Msg("Unable to read generation of cached report")
+		return ""
+	}
+
+	return fmt.Sprintf("%sreport:%s:%d.%d", storage.keyPrefix, clusterName, generations[0], generations[1])
+}
+
+// readCachedReport returns the cached report of the cluster and the key the
+// report read from the database should be cached under. Nil report is
+// returned when it is not cached, empty key when the cache is not available.
+func (storage *CachedStorage) readCachedReport(clusterName types.ClusterName) (*cachedReport, string) {
+	key := storage.reportKey(clusterName)
+	if key == "" {
+		metrics.CachedReportReads.WithLabelValues(cachedReadError).Inc()
+		return nil, ""
+	}
+
+	value, err := storage.backend.Get(key)
+	if err == ErrCacheMiss {
+		metrics.CachedReportReads.WithLabelValues(cachedReadMiss).Inc()
+		return nil, key
+	}
+
+	var report cachedReport
+	if err == nil {
+		err = json.Unmarshal(value, &report)
+	}
+
+	if err != nil {
+		log.Error().Err(err).Str("cluster", string(clusterName)).Msg("Unable to read cached report")
+		metrics.CachedReportReads.WithLabelValues(cachedReadError).Inc()
+		return nil, ""
+	}
+
+	metrics.CachedReportReads.WithLabelValues(cachedReadHit).Inc()
+	return &report, key
+}
+
+// cacheReport stores the report of the cluster read from the database under
+// the key read before the database was accessed. Reports with template data
+// that are not valid JSON are not cached.
+func (storage *CachedStorage) cacheReport(
+	key string, orgID types.OrgID, clusterName types.ClusterName, rules []types.RuleOnReport, lastChecked types.Timestamp,
+) {
+	if key == "" {
+		return
+	}
+
+	report := cachedReport{
+		OrgID:       orgID,
+		LastChecked: lastChecked,
+		Rules:       make([]cachedRule, 0, len(rules)),
+	}
+
+	for _, rule := range rules {
+		templateData, ok := rule.TemplateData.(json.RawMessage)
+		if !ok {
+			return
+		}
+
+		report.Rules = append(report.Rules, cachedRule{
+			Module:       rule.Module,
+			ErrorKey:     rule.ErrorKey,
+			TemplateData: string(templateData),
+		})
+	}
+
+	value, err := json.Marshal(report)
+	if err == nil {
+		err = storage.backend.Set(key, value, storage.ttl)
+	}
+
+	if err != nil {
+		log.Error().Err(err).Str("cluster", string(clusterName)).Msg("Unable to cache report")
+	}
+}
+
+// invalidateReports starts new generation of cached reports of the clusters,
+// so their reports cached before are not read anymore
+func (storage *CachedStorage) invalidateReports(clusterNames ...types.ClusterName) {
+	if len(clusterNames) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(clusterNames))
+	for _, clusterName := range clusterNames {
+		keys = append(keys, storage.generationKey(clusterName))
+	}
+
+	storage.incrementGenerations(keys...)
+}
+
+// invalidateAllReports starts new generation of all cached reports
+func (storage *CachedStorage) invalidateAllReports() {
+	storage.incrementGenerations(storage.allReportsGenerationKey())
+}
+
+// incrementGenerations increments the counters of generations
+func (storage *CachedStorage) incrementGenerations(keys ...string) {
+	if err := storage.backend.Increment(storage.generationTTL(), keys...); err != nil {
+		log.Error().Err(err).Strs("keys", keys).Msg("Unable to invalidate cached reports")
+	}
+}
+
+// rules returns rule hits of the cached report
+func (report *cachedReport) rules() []types.RuleOnReport {
+	rules := make([]types.RuleOnReport, 0, len(report.Rules))
+
+	for _, rule := range report.Rules {
+		rules = append(rules, types.RuleOnReport{
+			Module:       rule.Module,
+			ErrorKey:     rule.ErrorKey,
+			TemplateData: json.RawMessage(rule.TemplateData),
+		})
+	}
+
+	return rules
+}
+
+// primary returns the wrapped storage reading from the primary database
+func (storage *CachedStorage) primary() Storage {
+	if reader, ok := storage.Storage.(primaryReader); ok {
+		return reader.Primary()
+	}
+
+	return storage.Storage
+}
+
+// ReadReportForCluster reads the report from the cache first
+func (storage *CachedStorage) ReadReportForCluster(
+	orgID types.OrgID, clusterName types.ClusterName,
+) ([]types.RuleOnReport, types.Timestamp, error) {
+	// the cluster could be moved to another organization, the database
+	// reports it
+	report, key := storage.readCachedReport(clusterName)
+	if report != nil && report.OrgID == orgID {
+		return report.rules(), report.LastChecked, nil
+	}
+
+	// rule hits of another generation than the report are reported as an
+	// error, so only consistent reports are cached
+	rules, lastChecked, err := storage.primary().ReadReportForCluster(orgID, clusterName)
+	if err == nil {
+		storage.cacheReport(key, orgID, clusterName, rules, lastChecked)
+	}
+
+	return rules, lastChecked, err
+}
+
+// ReadReportForClusterByClusterName reads the report from the cache first
+func (storage *CachedStorage) ReadReportForClusterByClusterName(
+	clusterName types.ClusterName,
+) ([]types.RuleOnReport, types.Timestamp, error) {
+	report, key := storage.readCachedReport(clusterName)
+	if report != nil {
+		return report.rules(), report.LastChecked, nil
+	}
+
+	primary := storage.primary()
+
+	rules, lastChecked, err := primary.ReadReportForClusterByClusterName(clusterName)
+	if err != nil {
+		return rules, lastChecked, err
+	}
+
+	// organization is needed by ReadReportForCluster
+	orgID, err := primary.GetOrgIDByClusterID(clusterName)
+	if err == nil {
+		storage.cacheReport(key, orgID, clusterName, rules, lastChecked)
+	}
+
+	return rules, lastChecked, nil
+}
+
+// WriteReportForCluster writes the report and removes it from the cache
+func (storage *CachedStorage) WriteReportForCluster(
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+	report types.ClusterReport,
+	rules []types.ReportItem,
+	collectedAtTime time.Time,
+	kafkaOffset types.KafkaOffset,
+) error {
+	defer storage.invalidateReports(clusterName)
+
+	return storage.Storage.WriteReportForCluster(orgID, clusterName, report, rules, collectedAtTime, kafkaOffset)
+}
+
+// WriteFailedReportForCluster writes the failed report and removes the
+// report from the cache
+func (storage *CachedStorage) WriteFailedReportForCluster(
+	orgID types.OrgID, clusterName types.ClusterName, lastCheckedTime time.Time, kafkaOffset types.KafkaOffset,
+) error {
+	defer storage.invalidateReports(clusterName)
+
+	return storage.Storage.WriteFailedReportForCluster(orgID, clusterName, lastCheckedTime, kafkaOffset)
+}
+
+// DeleteReportsForOrg deletes reports of the organization and removes them
+// from the cache
+func (storage *CachedStorage) DeleteReportsForOrg(orgID types.OrgID) error {
+	clusterNames, err := storage.Storage.ListOfClustersForOrg(orgID, time.Time{}, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	defer storage.invalidateReports(clusterNames...)
+
+	return storage.Storage.DeleteReportsForOrg(orgID)
+}
+
+// DeleteReportsForCluster deletes the report and removes it from the cache
+func (storage *CachedStorage) DeleteReportsForCluster(clusterName types.ClusterName) error {
+	defer storage.invalidateReports(clusterName)
+
+	return storage.Storage.DeleteReportsForCluster(clusterName)
+}
+
+// DeleteClusterReport deletes the report and removes it from the cache
+func (storage *CachedStorage) DeleteClusterReport(
+	orgID types.OrgID, clusterName types.ClusterName, deletedAt time.Time,
+) error {
+	defer storage.invalidateReports(clusterName)
+
+	return storage.Storage.DeleteClusterReport(orgID, clusterName, deletedAt)
+}
+
+// ToggleRuleForCluster toggles the rule and removes the report of the cluster
+// from the cache
+func (storage *CachedStorage) ToggleRuleForCluster(
+	clusterID types.ClusterName, ruleID types.RuleID, errorKey types.ErrorKey, ruleToggle RuleToggle,
+) error {
+	defer storage.invalidateReports(clusterID)
+
+	return storage.Storage.ToggleRuleForCluster(clusterID, ruleID, errorKey, ruleToggle)
+}
+
+// DeleteFromRuleClusterToggle deletes the toggle and removes the report of
+// the cluster from the cache
+func (storage *CachedStorage) DeleteFromRuleClusterToggle(clusterID types.ClusterName, ruleID types.RuleID) error {
+	defer storage.invalidateReports(clusterID)
+
+	return storage.Storage.DeleteFromRuleClusterToggle(clusterID, ruleID)
+}
+
+// DeleteReportsNotCheckedSince deletes the reports and invalidates all cached
+// reports
+func (storage *CachedStorage) DeleteReportsNotCheckedSince(threshold time.Time) (int, error) {
+	defer storage.invalidateAllReports()
+
+	return storage.Storage.DeleteReportsNotCheckedSince(threshold)
+}
+
+// ArchiveReportsNotCheckedSince archives the reports and invalidates all
+// cached reports
+func (storage *CachedStorage) ArchiveReportsNotCheckedSince(threshold time.Time, limit int) (int, error) {
+	defer storage.invalidateAllReports()
+
+	return storage.Storage.ArchiveReportsNotCheckedSince(threshold, limit)
+}
+
+// CleanupOldData deletes data according to the retention policy and
+// invalidates all cached reports when reports are deleted
+func (storage *CachedStorage) CleanupOldData(policy RetentionPolicy) (map[string]int, error) {
+	if !policy.ReportsNotCheckedSince.IsZero() {
+		defer storage.invalidateAllReports()
+	}
+
+	return storage.Storage.CleanupOldData(policy)
+}
@@ -0,0 +1,342 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const cacheKeyPrefix = "test:"
+
+var errCacheUnavailable = errors.New("cache is not available")
+
+// fakeCacheBackend keeps cached values in memory instead of Redis
+type fakeCacheBackend struct {
+	mutex  sync.Mutex
+	values map[string][]byte
+	err    error
+}
+
+func newFakeCacheBackend() *fakeCacheBackend {
+	return &fakeCacheBackend{values: make(map[string][]byte)}
+}
+
+func (backend *fakeCacheBackend) Get(key string) ([]byte, error) {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+
+	if backend.err != nil {
+		return nil, backend.err
+	}
+
+	value, found := backend.values[key]
+	if !found {
+		return nil, storage.ErrCacheMiss
+	}
+
+	return value, nil
+}
+
+func (backend *fakeCacheBackend) Set(key string, value []byte, ttl time.Duration) error {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+
+	if backend.err != nil {
+		return backend.err
+	}
+
+	backend.values[key] = value
+	return nil
+}
+
+func (backend *fakeCacheBackend) Counters(keys ...string) ([]int64, error) {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+
+	if backend.err != nil {
+		return nil, backend.err
+	}
+
+	return backend.counters(keys...), nil
+}
+
+func (backend *fakeCacheBackend) Increment(ttl time.Duration, keys ...string) error {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+
+	if backend.err != nil {
+		return backend.err
+	}
+
+	for i, counter := range backend.counters(keys...) {
+		backend.values[keys[i]] = []byte(strconv.FormatInt(counter+1, 10))
+	}
+	return nil
+}
+
+func (backend *fakeCacheBackend) counters(keys ...string) []int64 {
+	counters := make([]int64, len(keys))
+	for i, key := range keys {
+		counters[i], _ = strconv.ParseInt(string(backend.values[key]), 10, 64)
+	}
+	return counters
+}
+
+func (backend *fakeCacheBackend) Close() error {
+	return nil
+}
+
+func (backend *fakeCacheBackend) isCached(clusterName types.ClusterName) bool {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+
+	generations := backend.counters(cacheKeyPrefix+"generation", cacheKeyPrefix+"generation:"+string(clusterName))
+	key := fmt.Sprintf("%sreport:%s:%d.%d", cacheKeyPrefix, clusterName, generations[0], generations[1])
+
+	_, found := backend.values[key]
+	return found
+}
+
+// mustGetCachedStorage returns mock DB storage wrapped into CachedStorage
+// using the fake backend
+func mustGetCachedStorage(t *testing.T) (*storage.CachedStorage, *storage.DBStorage, *fakeCacheBackend, func()) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	backend := newFakeCacheBackend()
+
+	cachedStorage := storage.NewCachedStorage(mockStorage, backend, time.Minute, cacheKeyPrefix)
+
+	return cachedStorage, mockStorage.(*storage.DBStorage), backend, closer
+}
+
+func TestCachedStorage_ReadReportForCluster(t *testing.T) {
+	cachedStorage, dbStorage, backend, closer := mustGetCachedStorage(t)
+	defer closer()
+
+	mustWriteClusterReport3Rules(t, cachedStorage, testdata.ClusterName)
+	assert.False(t, backend.isCached(testdata.ClusterName))
+
+	rules, lastChecked, err := cachedStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Len(t, rules, 3)
+	assert.True(t, backend.isCached(testdata.ClusterName))
+
+	// the report is read from the cache now
+	mustDeleteRuleHitsBehindStorage(t, dbStorage, testdata.ClusterName)
+
+	cachedRules, cachedLastChecked, err := cachedStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, rules, cachedRules)
+	assert.Equal(t, lastChecked, cachedLastChecked)
+
+	cachedRules, _, err = cachedStorage.ReadReportForClusterByClusterName(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, rules, cachedRules)
+}
+
+func TestCachedStorage_ReadReportFromPrimary(t *testing.T) {
+	cachedStorage, dbStorage, backend, closer := mustGetCachedStorage(t)
+	defer closer()
+
+	replicaStorage, replicaCloser := ira_helpers.MustGetMockStorage(t, true)
+	defer replicaCloser()
+
+	dbStorage.SetReadReplica(replicaStorage.(*storage.DBStorage).GetConnection())
+	// the replica is closed by its own closer
+	defer dbStorage.SetReadReplica(nil)
+
+	mustWriteClusterReport3Rules(t, cachedStorage, testdata.ClusterName)
+
+	// the report is not replicated yet, but it is read from the primary
+	rules, _, err := cachedStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Len(t, rules, 3)
+	assert.True(t, backend.isCached(testdata.ClusterName))
+}
+
+func TestCachedStorage_ReadReportForClusterByClusterName(t *testing.T) {
+	cachedStorage, dbStorage, backend, closer := mustGetCachedStorage(t)
+	defer closer()
+
+	mustWriteClusterReport3Rules(t, cachedStorage, testdata.ClusterName)
+
+	_, _, err := cachedStorage.ReadReportForClusterByClusterName(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.True(t, backend.isCached(testdata.ClusterName))
+
+	mustDeleteRuleHitsBehindStorage(t, dbStorage, testdata.ClusterName)
+
+	// organization of the cluster is cached too
+	assert.Equal(t, 3, readRuleHitsCount(t, cachedStorage, testdata.ClusterName))
+}
+
+func TestCachedStorage_ReadReportForClusterAnotherOrg(t *testing.T) {
+	cachedStorage, _, _, closer := mustGetCachedStorage(t)
+	defer closer()
+
+	mustWriteClusterReport3Rules(t, cachedStorage, testdata.ClusterName)
+	assert.Equal(t, 3, readRuleHitsCount(t, cachedStorage, testdata.ClusterName))
+
+	_, _, err := cachedStorage.ReadReportForCluster(testdata.Org2ID, testdata.ClusterName)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
+
+func TestCachedStorage_WriteReportInvalidatesCache(t *testing.T) {
+	cachedStorage, _, backend, closer := mustGetCachedStorage(t)
+	defer closer()
+
+	mustWriteClusterReport3Rules(t, cachedStorage, testdata.ClusterName)
+	assert.Equal(t, 3, readRuleHitsCount(t, cachedStorage, testdata.ClusterName))
+
+	err := cachedStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report2Rules, testdata.Report2RulesParsed,
+		testdata.LastCheckedAt.Add(time.Minute), testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+	assert.False(t, backend.isCached(testdata.ClusterName))
+
+	assert.Equal(t, 2, readRuleHitsCount(t, cachedStorage, testdata.ClusterName))
+}
+
+func TestCachedStorage_ToggleRuleInvalidatesCache(t *testing.T) {
+	cachedStorage, _, backend, closer := mustGetCachedStorage(t)
+	defer closer()
+
+	mustWriteClusterReport3Rules(t, cachedStorage, testdata.ClusterName)
+	assert.Equal(t, 3, readRuleHitsCount(t, cachedStorage, testdata.ClusterName))
+
+	err := cachedStorage.ToggleRuleForCluster(
+		testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, storage.RuleToggleDisable,
+	)
+	helpers.FailOnError(t, err)
+	assert.False(t, backend.isCached(testdata.ClusterName))
+
+	assert.Equal(t, 3, readRuleHitsCount(t, cachedStorage, testdata.ClusterName))
+
+	err = cachedStorage.DeleteFromRuleClusterToggle(testdata.ClusterName, testdata.Rule1ID)
+	helpers.FailOnError(t, err)
+	assert.False(t, backend.isCached(testdata.ClusterName))
+}
+
+func TestCachedStorage_DeleteReportsInvalidatesCache(t *testing.T) {
+	cachedStorage, _, backend, closer := mustGetCachedStorage(t)
+	defer closer()
+
+	mustWriteClusterReport3Rules(t, cachedStorage, testdata.ClusterName)
+	assert.Equal(t, 3, readRuleHitsCount(t, cachedStorage, testdata.ClusterName))
+
+	helpers.FailOnError(t, cachedStorage.DeleteReportsForOrg(testdata.OrgID))
+	assert.False(t, backend.isCached(testdata.ClusterName))
+
+	_, _, err := cachedStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
+
+func TestCachedStorage_CacheNotAvailable(t *testing.T) {
+	cachedStorage, _, backend, closer := mustGetCachedStorage(t)
+	defer closer()
+
+	backend.err = errCacheUnavailable
+
+	mustWriteClusterReport3Rules(t, cachedStorage, testdata.ClusterName)
+
+	// the report is read from the database
+	assert.Equal(t, 3, readRuleHitsCount(t, cachedStorage, testdata.ClusterName))
+}
+
+// writingStorage writes a new report of the cluster after the report is read
+// from the database, before it's cached
+type writingStorage struct {
+	storage.Storage
+	write func()
+}
+
+func (writing *writingStorage) ReadReportForCluster(
+	orgID types.OrgID, clusterName types.ClusterName,
+) ([]types.RuleOnReport, types.Timestamp, error) {
+	rules, lastChecked, err := writing.Storage.ReadReportForCluster(orgID, clusterName)
+	if writing.write != nil {
+		writing.write()
+		writing.write = nil
+	}
+	return rules, lastChecked, err
+}
+
+func TestCachedStorage_WriteBetweenReadAndCache(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	writing := &writingStorage{Storage: mockStorage}
+	cachedStorage := storage.NewCachedStorage(writing, newFakeCacheBackend(), time.Minute, cacheKeyPrefix)
+
+	mustWriteClusterReport3Rules(t, cachedStorage, testdata.ClusterName)
+
+	writing.write = func() {
+		err := cachedStorage.WriteReportForCluster(
+			testdata.OrgID, testdata.ClusterName, testdata.Report2Rules, testdata.Report2RulesParsed,
+			testdata.LastCheckedAt.Add(time.Minute), testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	// the report read before the write is returned, but it's not cached
+	// instead of the new one
+	assert.Equal(t, 3, readRuleHitsCount(t, cachedStorage, testdata.ClusterName))
+	assert.Equal(t, 2, readRuleHitsCount(t, cachedStorage, testdata.ClusterName))
+}
+
+func TestCachedStorage_RetentionInvalidatesCache(t *testing.T) {
+	for name, deleteReports := range map[string]func(*storage.CachedStorage, time.Time) error{
+		"delete": func(cachedStorage *storage.CachedStorage, threshold time.Time) error {
+			_, err := cachedStorage.DeleteReportsNotCheckedSince(threshold)
+			return err
+		},
+		"cleanup": func(cachedStorage *storage.CachedStorage, threshold time.Time) error {
+			_, err := cachedStorage.CleanupOldData(storage.RetentionPolicy{ReportsNotCheckedSince: threshold})
+			return err
+		},
+		"archive": func(cachedStorage *storage.CachedStorage, threshold time.Time) error {
+			_, err := cachedStorage.ArchiveReportsNotCheckedSince(threshold, 10)
+			return err
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dbStorage, _, closer := mustGetArchivingStorage(t)
+			defer closer()
+
+			backend := newFakeCacheBackend()
+			cachedStorage := storage.NewCachedStorage(dbStorage, backend, time.Minute, cacheKeyPrefix)
+
+			mustWriteClusterReport3Rules(t, cachedStorage, testdata.ClusterName)
+			assert.Equal(t, 3, readRuleHitsCount(t, cachedStorage, testdata.ClusterName))
+			assert.True(t, backend.isCached(testdata.ClusterName))
+
+			helpers.FailOnError(t, deleteReports(cachedStorage, testdata.LastCheckedAt.Add(time.Minute)))
+			assert.False(t, backend.isCached(testdata.ClusterName))
+		})
+	}
+}
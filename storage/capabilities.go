@@ -0,0 +1,59 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import "github.com/RedHatInsights/insights-results-aggregator/types"
+
+// Capabilities describes features of the storage that are not available on
+// all DB drivers. Server handlers and jobs should check them and degrade
+// gracefully instead of failing with driver-specific SQL errors (when the
+// service runs on SQLite, for example).
+type Capabilities struct {
+	// Upserts is true when upserts can return values of the written
+	// record (INSERT ... ON CONFLICT ... RETURNING)
+	Upserts bool `json:"upserts"`
+	// JSONBSearch is true when JSON columns can be searched by the
+	// database (PostgreSQL jsonb operators)
+	JSONBSearch bool `json:"jsonb_search"`
+	// Partitioning is true when tables can be partitioned
+	Partitioning bool `json:"partitioning"`
+	// SchemaIntrospection is true when tables, columns and indexes of the
+	// database can be read by ReadDBSchema
+	SchemaIntrospection bool `json:"schema_introspection"`
+}
+
+// capabilitiesOfDriver returns features supported by the DB driver
+func capabilitiesOfDriver(driver types.DBDriver) Capabilities {
+	switch driver {
+	case types.DBDriverPostgres:
+		return Capabilities{
+			Upserts:             true,
+			JSONBSearch:         true,
+			Partitioning:        true,
+			SchemaIntrospection: true,
+		}
+	case types.DBDriverSQLite3:
+		return Capabilities{
+			SchemaIntrospection: true,
+		}
+	default:
+		return Capabilities{}
+	}
+}
+
+// Capabilities returns features supported by the DB driver of the storage
+func (storage DBStorage) Capabilities() Capabilities {
+	return capabilitiesOfDriver(storage.dbDriverType)
+}
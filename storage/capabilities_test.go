@@ -0,0 +1,47 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func TestDBStorage_Capabilities(t *testing.T) {
+	sqliteStorage := storage.NewFromConnection(nil, types.DBDriverSQLite3)
+	assert.Equal(t, storage.Capabilities{
+		SchemaIntrospection: true,
+	}, sqliteStorage.Capabilities())
+
+	postgresStorage := storage.NewFromConnection(nil, types.DBDriverPostgres)
+	assert.Equal(t, storage.Capabilities{
+		Upserts:             true,
+		JSONBSearch:         true,
+		Partitioning:        true,
+		SchemaIntrospection: true,
+	}, postgresStorage.Capabilities())
+	assert.Equal(t, types.DBDriverPostgres, postgresStorage.GetDBDriverType())
+}
+
+func TestMemoryStorage_Capabilities(t *testing.T) {
+	memoryStorage := storage.NewMemoryStorage()
+
+	assert.Equal(t, storage.Capabilities{}, memoryStorage.Capabilities())
+	assert.Equal(t, types.DBDriverGeneral, memoryStorage.GetDBDriverType())
+}
@@ -0,0 +1,126 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// AddClusterAnnotation attaches a new annotation written by the author to
+// the cluster. ItemNotFoundError is returned when there is no report for the
+// cluster.
+func (storage DBStorage) AddClusterAnnotation(
+	clusterID types.ClusterName, author types.UserID, message string,
+) (types.ClusterAnnotation, error) {
+	ctx, cancel := storage.operationContext(writeOperation)
+	defer cancel()
+
+	exists, err := storage.DoesClusterExist(clusterID)
+	if err != nil {
+		return types.ClusterAnnotation{}, types.ConvertDBError(err, clusterID)
+	}
+	if !exists {
+		return types.ClusterAnnotation{}, &types.ItemNotFoundError{ItemID: clusterID}
+	}
+
+	createdAt := time.Now().UTC()
+	annotation := types.ClusterAnnotation{
+		ID:        uuid.New().String(),
+		ClusterID: clusterID,
+		Author:    author,
+		Message:   message,
+		CreatedAt: types.Timestamp(createdAt.Format(time.RFC3339)),
+	}
+
+	_, err = storage.connection.ExecContext(ctx, `
+		INSERT INTO cluster_annotation(annotation_id, cluster_id, author, message, created_at)
+		VALUES ($1, $2, $3, $4, $5);
+	`, annotation.ID, clusterID, author, message, createdAt)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to add cluster annotation")
+		return types.ClusterAnnotation{}, types.ConvertDBError(err, clusterID)
+	}
+
+	return annotation, nil
+}
+
+// ReadClusterAnnotations returns all annotations of the cluster, the oldest
+// annotation goes first
+func (storage DBStorage) ReadClusterAnnotations(clusterID types.ClusterName) ([]types.ClusterAnnotation, error) {
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
+	annotations := make([]types.ClusterAnnotation, 0)
+
+	rows, err := storage.readConnection().QueryContext(ctx, `
+		SELECT annotation_id, author, message, created_at FROM cluster_annotation
+		WHERE cluster_id = $1
+		ORDER BY created_at, annotation_id;
+	`, clusterID)
+	if err != nil {
+		return annotations, types.ConvertDBError(err, clusterID)
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var (
+			createdAt  time.Time
+			annotation = types.ClusterAnnotation{ClusterID: clusterID}
+		)
+
+		err = rows.Scan(&annotation.ID, &annotation.Author, &annotation.Message, &createdAt)
+		if err != nil {
+			log.Error().Err(err).Msg("ReadClusterAnnotations")
+			return annotations, types.ConvertDBError(err, clusterID)
+		}
+
+		annotation.CreatedAt = types.Timestamp(createdAt.UTC().Format(time.RFC3339))
+		annotations = append(annotations, annotation)
+	}
+
+	return annotations, nil
+}
+
+// DeleteClusterAnnotation deletes the annotation of the cluster.
+// ItemNotFoundError is returned when there is no such annotation.
+func (storage DBStorage) DeleteClusterAnnotation(clusterID types.ClusterName, annotationID string) error {
+	ctx, cancel := storage.operationContext(writeOperation)
+	defer cancel()
+
+	result, err := storage.connection.ExecContext(
+		ctx,
+		"DELETE FROM cluster_annotation WHERE cluster_id = $1 AND annotation_id = $2;",
+		clusterID, annotationID,
+	)
+	if err != nil {
+		return types.ConvertDBError(err, annotationID)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return types.ConvertDBError(err, annotationID)
+	}
+
+	if deleted == 0 {
+		return &types.ItemNotFoundError{ItemID: annotationID}
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// clusterClassColumn returns expression used to read the class of the
+// cluster. All clusters are self-managed before the database is migrated.
+func (storage DBStorage) clusterClassColumn() string {
+	if storage.schemaAtLeast(clusterClassVersion) {
+		return "cluster_class"
+	}
+
+	return "'" + string(types.ClusterClassSelfManaged) + "'"
+}
+
+// WriteClusterClass stores the class of the cluster taken from metadata of
+// its report. Nothing is stored before the database is migrated.
+func (storage DBStorage) WriteClusterClass(
+	orgID types.OrgID, clusterName types.ClusterName, class types.ClusterClass,
+) error {
+	if !class.IsValid() {
+		return fmt.Errorf("unknown cluster class '%s'", class)
+	}
+
+	if !storage.schemaAtLeast(clusterClassVersion) {
+		log.Debug().Msg("Cluster class is not stored, the database is not migrated yet")
+		return nil
+	}
+
+	ctx, cancel := storage.operationContext(writeOperation)
+	defer cancel()
+
+	_, err := storage.connection.ExecContext(
+		ctx,
+		"UPDATE report SET cluster_class = $1 WHERE org_id = $2 AND cluster = $3 AND cluster_class <> $4;",
+		class, orgID, clusterName, class,
+	)
+
+	return types.ConvertDBError(err, []interface{}{orgID, clusterName})
+}
+
+// ReadClusterClasses returns classes of all clusters of the organization
+// that have a report
+func (storage DBStorage) ReadClusterClasses(orgID types.OrgID) (map[types.ClusterName]types.ClusterClass, error) {
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
+	classes := make(map[types.ClusterName]types.ClusterClass)
+
+	// #nosec G202
+	rows, err := storage.readConnection().QueryContext(
+		ctx, "SELECT cluster, "+storage.clusterClassColumn()+" FROM report WHERE org_id = $1;", orgID,
+	)
+	if err != nil {
+		return classes, types.ConvertDBError(err, orgID)
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var (
+			clusterName types.ClusterName
+			class       types.ClusterClass
+		)
+
+		if err := rows.Scan(&clusterName, &class); err != nil {
+			return classes, types.ConvertDBError(err, orgID)
+		}
+
+		classes[clusterName] = class
+	}
+
+	return classes, rows.Err()
+}
@@ -0,0 +1,116 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/migration"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// TestDBStorage_WriteClusterClass checks that clusters are self-managed
+// until their class is stored
+func TestDBStorage_WriteClusterClass(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	for _, cluster := range []types.ClusterName{testdata.ClusterName, testdata.GetRandomClusterID()} {
+		err := mockStorage.WriteReportForCluster(
+			testdata.OrgID, cluster, testdata.ClusterReportEmpty, testdata.ReportEmptyRulesParsed,
+			testdata.LastCheckedAt, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	classes, err := mockStorage.ReadClusterClasses(testdata.OrgID)
+	helpers.FailOnError(t, err)
+	assert.Len(t, classes, 2)
+	for _, class := range classes {
+		assert.Equal(t, types.ClusterClassSelfManaged, class)
+	}
+
+	err = mockStorage.WriteClusterClass(testdata.OrgID, testdata.ClusterName, types.ClusterClassManaged)
+	helpers.FailOnError(t, err)
+
+	classes, err = mockStorage.ReadClusterClasses(testdata.OrgID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.ClusterClassManaged, classes[testdata.ClusterName])
+
+	// clusters of other organizations are not returned
+	classes, err = mockStorage.ReadClusterClasses(testdata.Org2ID)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, classes)
+}
+
+func TestDBStorage_WriteClusterClassUnknown(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteClusterClass(testdata.OrgID, testdata.ClusterName, "unknown")
+	assert.EqualError(t, err, "unknown cluster class 'unknown'")
+}
+
+// TestDBStorage_ClusterClassPreviousSchema checks that all clusters are
+// self-managed before the database is migrated
+func TestDBStorage_ClusterClassPreviousSchema(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+
+	err := migration.SetDBVersion(dbStorage.GetConnection(), dbStorage.GetDBDriverType(), 35)
+	helpers.FailOnError(t, err)
+
+	_, err = dbStorage.DetectSchemaVersion()
+	helpers.FailOnError(t, err)
+
+	err = mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.ClusterReportEmpty, testdata.ReportEmptyRulesParsed,
+		testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	err = mockStorage.WriteClusterClass(testdata.OrgID, testdata.ClusterName, types.ClusterClassManaged)
+	helpers.FailOnError(t, err)
+
+	classes, err := mockStorage.ReadClusterClasses(testdata.OrgID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, map[types.ClusterName]types.ClusterClass{
+		testdata.ClusterName: types.ClusterClassSelfManaged,
+	}, classes)
+}
+
+func TestDBStorage_ReadClusterClassesDBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	_, err := mockStorage.ReadClusterClasses(testdata.OrgID)
+	assert.EqualError(t, err, "sql: database is closed")
+}
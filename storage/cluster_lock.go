@@ -0,0 +1,102 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// clusterLocks serializes writes of reports of the same cluster done by one
+// instance of the service. Mutexes are created on demand and removed when no
+// one holds or waits for them, so the number of mutexes doesn't grow with
+// the number of clusters.
+type clusterLocks struct {
+	mutex sync.Mutex
+	locks map[types.ClusterName]*clusterLock
+}
+
+// clusterLock is a mutex of one cluster with the number of its holders and
+// waiters
+type clusterLock struct {
+	sync.Mutex
+	references int
+}
+
+// newClusterLocks constructs empty set of cluster locks
+func newClusterLocks() *clusterLocks {
+	return &clusterLocks{locks: map[types.ClusterName]*clusterLock{}}
+}
+
+// Lock blocks until the lock of the cluster is acquired
+func (locks *clusterLocks) Lock(clusterName types.ClusterName) {
+	locks.mutex.Lock()
+	lock, found := locks.locks[clusterName]
+	if !found {
+		lock = &clusterLock{}
+		locks.locks[clusterName] = lock
+	}
+	lock.references++
+	locks.mutex.Unlock()
+
+	lock.Lock()
+}
+
+// Unlock releases the lock of the cluster
+func (locks *clusterLocks) Unlock(clusterName types.ClusterName) {
+	locks.mutex.Lock()
+	defer locks.mutex.Unlock()
+
+	lock, found := locks.locks[clusterName]
+	if !found {
+		log.Error().Str("cluster", string(clusterName)).Msg("Unlocking cluster that is not locked")
+		return
+	}
+
+	lock.references--
+	if lock.references == 0 {
+		delete(locks.locks, clusterName)
+	}
+	lock.Unlock()
+}
+
+// Len returns the number of clusters that are locked or waited for
+func (locks *clusterLocks) Len() int {
+	locks.mutex.Lock()
+	defer locks.mutex.Unlock()
+
+	return len(locks.locks)
+}
+
+// lockClusterInTransaction acquires PostgreSQL advisory lock of the cluster
+// that is released at the end of the transaction, so writes of reports of the
+// same cluster are serialized across all instances of the service. Nothing is
+// done for other databases, the writes are serialized by clusterLocks only.
+func (storage DBStorage) lockClusterInTransaction(tx *sql.Tx, clusterName types.ClusterName) error {
+	if storage.dbDriverType != types.DBDriverPostgres {
+		return nil
+	}
+
+	_, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1));", clusterName)
+	if err != nil {
+		log.Err(err).Msgf("Unable to lock cluster %v", clusterName)
+	}
+
+	return err
+}
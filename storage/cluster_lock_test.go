@@ -0,0 +1,107 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// TestClusterLocks checks that the lock of one cluster doesn't block other
+// clusters and that unused locks are removed
+func TestClusterLocks(t *testing.T) {
+	const (
+		cluster1 = types.ClusterName("00000000-0000-0000-0000-000000000001")
+		cluster2 = types.ClusterName("00000000-0000-0000-0000-000000000002")
+	)
+
+	locks := storage.NewClusterLocks()
+
+	locks.Lock(cluster1)
+
+	locked := make(chan struct{})
+	go func() {
+		locks.Lock(cluster1)
+		close(locked)
+	}()
+
+	// other cluster is not blocked
+	locks.Lock(cluster2)
+	locks.Unlock(cluster2)
+
+	select {
+	case <-locked:
+		t.Fatal("cluster was locked twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	locks.Unlock(cluster1)
+	<-locked
+	assert.Equal(t, 1, locks.Len())
+
+	locks.Unlock(cluster1)
+	assert.Equal(t, 0, locks.Len())
+}
+
+// TestDBStorage_WriteReportForClusterConcurrently checks that concurrent
+// writes of the same cluster don't interleave
+func TestDBStorage_WriteReportForClusterConcurrently(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	const writers = 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- mockStorage.WriteReportForCluster(
+				testdata.OrgID,
+				testdata.ClusterName,
+				testdata.Report3Rules,
+				testdata.Report3RulesParsed,
+				testdata.LastCheckedAt.Add(time.Duration(i)*time.Second),
+				types.KafkaOffset(i),
+			)
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != types.ErrOldReport {
+			helpers.FailOnError(t, err)
+		}
+	}
+
+	ruleHits, err := mockStorage.ReadRuleHitsForCluster(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Len(t, ruleHits, len(testdata.Report3RulesParsed))
+
+	assert.Equal(t, 0, storage.GetClusterLocks(mockStorage.(*storage.DBStorage)).Len())
+}
@@ -0,0 +1,197 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/events"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// Policies applied to reports of clusters that are already stored under
+// another organization, the cluster was re-registered, for example
+const (
+	// ClusterOrgConflictMove moves the cluster into the new organization,
+	// rule hits of the previous organization are deleted
+	ClusterOrgConflictMove = "move"
+	// ClusterOrgConflictReject rejects the report with
+	// types.ErrClusterOrgConflict, the cluster stays in its organization
+	ClusterOrgConflictReject = "reject"
+	// ClusterOrgConflictKeep moves the cluster like ClusterOrgConflictMove,
+	// but the last report of the previous organization is kept in the
+	// history of reports
+	ClusterOrgConflictKeep = "keep"
+)
+
+// ClusterOrgConflictPolicies contains all supported policies applied to
+// reports of clusters already stored under another organization
+var ClusterOrgConflictPolicies = []string{
+	ClusterOrgConflictMove, ClusterOrgConflictReject, ClusterOrgConflictKeep,
+}
+
+// SetClusterOrgConflictPolicy sets the policy applied to reports of clusters
+// already stored under another organization, clusters are moved by default
+func (storage *DBStorage) SetClusterOrgConflictPolicy(policy string) {
+	if policy == "" {
+		policy = ClusterOrgConflictMove
+	}
+	storage.clusterOrgConflictPolicy = policy
+}
+
+// resolveClusterOrgConflict checks whether the cluster is stored under
+// another organization and applies the conflict policy. The change of the
+// organization is returned, nil is returned when there is no conflict.
+// types.ErrClusterOrgConflict is returned (together with the change) when
+// the report is rejected.
+func (storage DBStorage) resolveClusterOrgConflict(
+	tx *sql.Tx, orgID types.OrgID, clusterName types.ClusterName, lastCheckedTime time.Time,
+) (*types.ClusterOrgChange, error) {
+	var (
+		previousOrgID types.OrgID
+		report        types.ClusterReport
+		lastChecked   types.NullTime
+		kafkaOffset   types.NullKafkaOffset
+	)
+
+	err := tx.QueryRow(
+		"SELECT org_id, report, last_checked_at, kafka_offset FROM report WHERE cluster = $1;", clusterName,
+	).Scan(&previousOrgID, &report, &lastChecked, &kafkaOffset)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, types.ConvertDBError(err, clusterName)
+	}
+
+	if previousOrgID == orgID {
+		return nil, nil
+	}
+
+	// reports sent before the cluster was re-registered must not move it
+	// back into the previous organization
+	if lastChecked.Valid && lastChecked.Time.After(lastCheckedTime) {
+		return nil, types.ErrOldReport
+	}
+
+	change := &types.ClusterOrgChange{
+		ClusterName:   clusterName,
+		PreviousOrgID: previousOrgID,
+		OrgID:         orgID,
+		ChangedAt:     types.Timestamp(lastCheckedTime.UTC().Format(time.RFC3339)),
+		Resolution:    storage.clusterOrgConflictPolicy,
+	}
+
+	log.Warn().Msgf(
+		"Cluster %v reported under organization %v is stored under organization %v, resolution: %v",
+		clusterName, orgID, previousOrgID, change.Resolution,
+	)
+
+	if change.Resolution == ClusterOrgConflictReject {
+		return change, types.ErrClusterOrgConflict
+	}
+
+	if change.Resolution == ClusterOrgConflictKeep && storage.schemaAtLeast(reportHistoryVersion) && lastChecked.Valid {
+		err = reportHistoryUpsert.exec(tx, storage.dbDriverType, []interface{}{
+			previousOrgID, clusterName, report, lastChecked.Time, time.Now(), kafkaOffset.Offset,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// rule hits are deleted only for the organization of the new report
+	// when the report is written
+	_, err = tx.Exec(
+		"DELETE FROM rule_hit WHERE org_id = $1 AND cluster_id = $2;", previousOrgID, clusterName,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if storage.schemaAtLeast(clusterOrgChangeVersion) {
+		_, err = tx.Exec(`
+			INSERT INTO cluster_org_change (cluster_id, previous_org_id, org_id, changed_at, resolution)
+			VALUES ($1, $2, $3, $4, $5);
+		`, clusterName, previousOrgID, orgID, lastCheckedTime, change.Resolution)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return change, nil
+}
+
+// publishClusterOrgConflict publishes the resolved conflict of organizations
+// of the cluster detected in the report checked at detectedAt, err is the
+// result of the write of the report
+func publishClusterOrgConflict(change *types.ClusterOrgChange, detectedAt time.Time, err error) {
+	if change == nil || (err != nil && err != types.ErrClusterOrgConflict) {
+		return
+	}
+
+	events.DefaultBus.PublishClusterOrgConflict(events.ClusterOrgConflictEvent{
+		ClusterName:   change.ClusterName,
+		PreviousOrgID: change.PreviousOrgID,
+		OrgID:         change.OrgID,
+		DetectedAt:    detectedAt,
+		Resolution:    change.Resolution,
+	})
+}
+
+// ReadClusterOrgChanges returns clusters that started to report under
+// another organization since the given time, the most recent change goes
+// first. Rejected reports are not recorded.
+func (storage DBStorage) ReadClusterOrgChanges(since time.Time) ([]types.ClusterOrgChange, error) {
+	changes := make([]types.ClusterOrgChange, 0)
+
+	if !storage.schemaAtLeast(clusterOrgChangeVersion) {
+		return changes, nil
+	}
+
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
+	rows, err := storage.readConnection().QueryContext(ctx, `
+		SELECT cluster_id, previous_org_id, org_id, changed_at, resolution
+		FROM cluster_org_change
+		WHERE changed_at >= $1
+		ORDER BY changed_at DESC, cluster_id;
+	`, since)
+	if err != nil {
+		return changes, types.ConvertDBError(err, nil)
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var (
+			change    types.ClusterOrgChange
+			changedAt time.Time
+		)
+
+		err = rows.Scan(&change.ClusterName, &change.PreviousOrgID, &change.OrgID, &changedAt, &change.Resolution)
+		if err != nil {
+			return changes, types.ConvertDBError(err, nil)
+		}
+
+		change.ChangedAt = types.Timestamp(changedAt.UTC().Format(time.RFC3339))
+		changes = append(changes, change)
+	}
+
+	return changes, rows.Err()
+}
@@ -0,0 +1,355 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// clustersLastCheckedEntryOverhead is an estimate of memory (in bytes) taken
+// by one entry of the clustersLastChecked map without the cluster name itself:
+// string header (16 bytes), time.Time (24 bytes) and map bucket overhead
+const clustersLastCheckedEntryOverhead = 16 + 24 + 8
+
+// clustersLastCheckedTolerance is the maximal difference between the cached
+// and stored time of the last check that is not counted as divergence, the
+// database can round the stored time
+const clustersLastCheckedTolerance = time.Millisecond
+
+// maxClustersLastCheckedDivergenceExamples is the maximal number of divergent
+// clusters returned together with the counts
+const maxClustersLastCheckedDivergenceExamples = 20
+
+// ClustersLastCheckedAgeDistribution contains number of clusters in the
+// last checked cache grouped by the age of their last check
+type ClustersLastCheckedAgeDistribution struct {
+	LessThanHour  int `json:"less_than_1h"`
+	LessThanDay   int `json:"less_than_1d"`
+	LessThanWeek  int `json:"less_than_7d"`
+	LessThanMonth int `json:"less_than_30d"`
+	Older         int `json:"older"`
+}
+
+// ClustersLastCheckedCacheStats represents statistics about the cache of
+// timestamps when the clusters were last checked
+type ClustersLastCheckedCacheStats struct {
+	Size                int                                `json:"size"`
+	ReportsInStorage    int                                `json:"reports_in_storage"`
+	Oldest              types.Timestamp                    `json:"oldest,omitempty"`
+	Newest              types.Timestamp                    `json:"newest,omitempty"`
+	AgeDistribution     ClustersLastCheckedAgeDistribution `json:"age_distribution"`
+	MemoryEstimateBytes int                                `json:"memory_estimate_bytes"`
+}
+
+// RebuildClustersLastCheckedCache reads timestamps when the clusters were
+// last checked from the report table and replaces content of the cache with
+// them. The cache is left untouched when the reading fails. Number of entries
+// in the rebuilt cache is returned.
+func (storage DBStorage) RebuildClustersLastCheckedCache() (int, error) {
+	ctx, cancel := storage.operationContext(aggregationOperation)
+	defer cancel()
+
+	rows, err := storage.connection.QueryContext(ctx, "SELECT cluster, last_checked_at FROM report;")
+	if err != nil {
+		return 0, err
+	}
+
+	clustersLastChecked := make(map[types.ClusterName]time.Time)
+
+	for rows.Next() {
+		var (
+			clusterName types.ClusterName
+			lastChecked types.NullTime
+		)
+
+		if err := rows.Scan(&clusterName, &lastChecked); err != nil {
+			if closeErr := rows.Close(); closeErr != nil {
+				log.Error().Err(closeErr).Msg("Unable to close the DB rows handle")
+			}
+			return 0, err
+		}
+
+		// clusters without the time of the last check are not cached, any
+		// report of them is checked in the database
+		if !lastChecked.Valid {
+			continue
+		}
+
+		clustersLastChecked[clusterName] = lastChecked.Time
+	}
+
+	// Not using defer to close the rows here to:
+	// - make errcheck happy (it doesn't like ignoring returned errors),
+	// - return a possible error returned by the Close method.
+	if err := rows.Close(); err != nil {
+		return 0, err
+	}
+
+	storage.clustersLastCheckedMutex.Lock()
+	defer storage.clustersLastCheckedMutex.Unlock()
+
+	// the map is shared by all copies of DBStorage so it needs to be updated in place
+	for clusterName := range storage.clustersLastChecked {
+		delete(storage.clustersLastChecked, clusterName)
+	}
+	for clusterName, lastChecked := range clustersLastChecked {
+		storage.clustersLastChecked[clusterName] = lastChecked
+	}
+
+	return len(storage.clustersLastChecked), nil
+}
+
+// GetClustersLastCheckedCacheStats returns statistics about the cache of
+// timestamps when the clusters were last checked
+func (storage DBStorage) GetClustersLastCheckedCacheStats() (ClustersLastCheckedCacheStats, error) {
+	var stats ClustersLastCheckedCacheStats
+
+	reportsCount, err := storage.ReportsCount()
+	if err != nil {
+		return stats, err
+	}
+	stats.ReportsInStorage = reportsCount
+
+	var oldest, newest time.Time
+	now := time.Now()
+
+	storage.clustersLastCheckedMutex.RLock()
+	defer storage.clustersLastCheckedMutex.RUnlock()
+
+	stats.Size = len(storage.clustersLastChecked)
+
+	for clusterName, lastChecked := range storage.clustersLastChecked {
+		stats.MemoryEstimateBytes += len(clusterName) + clustersLastCheckedEntryOverhead
+
+		if oldest.IsZero() || lastChecked.Before(oldest) {
+			oldest = lastChecked
+		}
+		if newest.IsZero() || lastChecked.After(newest) {
+			newest = lastChecked
+		}
+
+		age := now.Sub(lastChecked)
+		switch {
+		case age < time.Hour:
+			stats.AgeDistribution.LessThanHour++
+		case age < 24*time.Hour:
+			stats.AgeDistribution.LessThanDay++
+		case age < 7*24*time.Hour:
+			stats.AgeDistribution.LessThanWeek++
+		case age < 30*24*time.Hour:
+			stats.AgeDistribution.LessThanMonth++
+		default:
+			stats.AgeDistribution.Older++
+		}
+	}
+
+	if stats.Size > 0 {
+		stats.Oldest = types.Timestamp(oldest.UTC().Format(time.RFC3339))
+		stats.Newest = types.Timestamp(newest.UTC().Format(time.RFC3339))
+	}
+
+	return stats, nil
+}
+
+// ClusterLastCheckedDivergence is one cluster whose time of the last check
+// in the cache differs from the time stored in the database, the times are
+// empty when the cluster is missing in the cache or in the database
+type ClusterLastCheckedDivergence struct {
+	Cluster types.ClusterName `json:"cluster"`
+	Cached  types.Timestamp   `json:"cached,omitempty"`
+	Stored  types.Timestamp   `json:"stored,omitempty"`
+}
+
+// ClustersLastCheckedDivergence contains results of comparison of sampled
+// clusters from the cache of timestamps when the clusters were last checked
+// with the database. The cache of the instance is stale when the reports of
+// the clusters were written by other instances of the service.
+type ClustersLastCheckedDivergence struct {
+	// SampledReports is the number of clusters sampled from the database,
+	// each of them is counted as matching, stale, ahead or missing in cache
+	SampledReports int `json:"sampled_reports"`
+	Matching       int `json:"matching"`
+	Stale          int `json:"stale"`
+	Ahead          int `json:"ahead"`
+	MissingInCache int `json:"missing_in_cache"`
+	// SampledCache is the number of clusters sampled from the cache, each of
+	// them without report in the database is counted as missing in storage
+	SampledCache        int                            `json:"sampled_cache"`
+	MissingInStorage    int                            `json:"missing_in_storage"`
+	MaxStalenessSeconds float64                        `json:"max_staleness_seconds"`
+	Examples            []ClusterLastCheckedDivergence `json:"examples"`
+}
+
+// addExample adds the divergent cluster to the examples unless there are
+// enough of them already
+func (divergence *ClustersLastCheckedDivergence) addExample(
+	clusterName types.ClusterName, cached, stored time.Time,
+) {
+	if len(divergence.Examples) >= maxClustersLastCheckedDivergenceExamples {
+		return
+	}
+
+	example := ClusterLastCheckedDivergence{Cluster: clusterName}
+	if !cached.IsZero() {
+		example.Cached = types.Timestamp(cached.UTC().Format(time.RFC3339Nano))
+	}
+	if !stored.IsZero() {
+		example.Stored = types.Timestamp(stored.UTC().Format(time.RFC3339Nano))
+	}
+
+	divergence.Examples = append(divergence.Examples, example)
+}
+
+// CheckClustersLastCheckedDivergence compares the cache of timestamps when
+// the clusters were last checked with the database. Up to sampleSize random
+// reports are read from the database and compared with the cache, and up to
+// sampleSize clusters from the cache are checked to have a report in the
+// database. The cache is not changed.
+func (storage DBStorage) CheckClustersLastCheckedDivergence(sampleSize int) (ClustersLastCheckedDivergence, error) {
+	divergence := ClustersLastCheckedDivergence{Examples: []ClusterLastCheckedDivergence{}}
+
+	stored, err := storage.sampleReportsLastChecked(sampleSize)
+	if err != nil {
+		return divergence, err
+	}
+
+	storage.clustersLastCheckedMutex.RLock()
+	cachedSample := make([]types.ClusterName, 0, sampleSize)
+	for clusterName := range storage.clustersLastChecked {
+		if len(cachedSample) >= sampleSize {
+			break
+		}
+		cachedSample = append(cachedSample, clusterName)
+	}
+
+	for clusterName, storedLastChecked := range stored {
+		divergence.SampledReports++
+
+		cachedLastChecked, found := storage.clustersLastChecked[clusterName]
+		if !found {
+			divergence.MissingInCache++
+			divergence.addExample(clusterName, time.Time{}, storedLastChecked)
+			continue
+		}
+
+		diff := storedLastChecked.Sub(cachedLastChecked)
+		switch {
+		case diff > clustersLastCheckedTolerance:
+			divergence.Stale++
+			if diff.Seconds() > divergence.MaxStalenessSeconds {
+				divergence.MaxStalenessSeconds = diff.Seconds()
+			}
+		case diff < -clustersLastCheckedTolerance:
+			divergence.Ahead++
+		default:
+			divergence.Matching++
+			continue
+		}
+		divergence.addExample(clusterName, cachedLastChecked, storedLastChecked)
+	}
+
+	cachedLastChecked := make(map[types.ClusterName]time.Time, len(cachedSample))
+	for _, clusterName := range cachedSample {
+		cachedLastChecked[clusterName] = storage.clustersLastChecked[clusterName]
+	}
+	storage.clustersLastCheckedMutex.RUnlock()
+
+	withReport, err := storage.clustersWithReport(cachedSample)
+	if err != nil {
+		return divergence, err
+	}
+
+	divergence.SampledCache = len(cachedSample)
+	for _, clusterName := range cachedSample {
+		if !withReport[clusterName] {
+			divergence.MissingInStorage++
+			divergence.addExample(clusterName, cachedLastChecked[clusterName], time.Time{})
+		}
+	}
+
+	return divergence, nil
+}
+
+// sampleReportsLastChecked reads time of the last check of up to sampleSize
+// random clusters from the report table, the most recent time is used for
+// clusters with reports in more organizations
+func (storage DBStorage) sampleReportsLastChecked(sampleSize int) (map[types.ClusterName]time.Time, error) {
+	ctx, cancel := storage.operationContext(aggregationOperation)
+	defer cancel()
+
+	rows, err := storage.connection.QueryContext(
+		ctx,
+		"SELECT cluster, last_checked_at FROM report WHERE last_checked_at IS NOT NULL ORDER BY random() LIMIT $1;",
+		sampleSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	stored := make(map[types.ClusterName]time.Time)
+
+	for rows.Next() {
+		var (
+			clusterName types.ClusterName
+			lastChecked time.Time
+		)
+
+		if err := rows.Scan(&clusterName, &lastChecked); err != nil {
+			return nil, err
+		}
+
+		if lastChecked.After(stored[clusterName]) {
+			stored[clusterName] = lastChecked
+		}
+	}
+
+	return stored, rows.Err()
+}
+
+// clustersWithReport returns which of the given clusters have a report in
+// the database
+func (storage DBStorage) clustersWithReport(clusterNames []types.ClusterName) (map[types.ClusterName]bool, error) {
+	withReport := make(map[types.ClusterName]bool, len(clusterNames))
+	if len(clusterNames) == 0 {
+		return withReport, nil
+	}
+
+	ctx, cancel := storage.operationContext(aggregationOperation)
+	defer cancel()
+
+	// #nosec G202
+	query := "SELECT DISTINCT cluster FROM report WHERE cluster IN (" + constructInClausule(len(clusterNames)) + ");"
+
+	rows, err := storage.connection.QueryContext(ctx, query, argsWithClusterNames(clusterNames)...)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var clusterName types.ClusterName
+		if err := rows.Scan(&clusterName); err != nil {
+			return nil, err
+		}
+		withReport[clusterName] = true
+	}
+
+	return withReport, rows.Err()
+}
@@ -0,0 +1,51 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"database/sql"
+
+	"github.com/rs/zerolog/log"
+)
+
+// configureConnectionPool applies limits of the connection pool from the
+// configuration, options that are not set (zero) keep the defaults of
+// database/sql package
+func configureConnectionPool(connection *sql.DB, configuration Configuration) {
+	if configuration.MaxOpenConnections > 0 {
+		connection.SetMaxOpenConns(configuration.MaxOpenConnections)
+	}
+
+	if configuration.MaxIdleConnections > 0 {
+		connection.SetMaxIdleConns(configuration.MaxIdleConnections)
+	}
+
+	if configuration.ConnMaxLifetime > 0 {
+		connection.SetConnMaxLifetime(configuration.ConnMaxLifetime)
+	}
+
+	if configuration.ConnMaxIdleTime > 0 {
+		connection.SetConnMaxIdleTime(configuration.ConnMaxIdleTime)
+	}
+
+	log.Info().
+		Int("max_open_connections", configuration.MaxOpenConnections).
+		Int("max_idle_connections", configuration.MaxIdleConnections).
+		Dur("conn_max_lifetime", configuration.ConnMaxLifetime).
+		Dur("conn_max_idle_time", configuration.ConnMaxIdleTime).
+		Msg("Connection pool of data storage configured")
+}
@@ -0,0 +1,81 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+)
+
+// consumerErrorTruncationMarker is appended to message values truncated
+// before they are stored into consumer_error table, the original size of the
+// value is part of the marker
+const consumerErrorTruncationMarker = "\n[truncated, original size %d bytes]"
+
+// consumerErrorMessageOptions specify how message values are stored into
+// consumer_error table
+type consumerErrorMessageOptions struct {
+	// maxSize is the maximal number of bytes of the message value stored,
+	// 0 means no limit
+	maxSize int
+	// compress enables gzip compression of the stored message value
+	compress bool
+}
+
+// SetConsumerErrorMessageOptions sets the maximal size of message values
+// stored with consumer errors (0 means no limit) and whether the values are
+// compressed by gzip
+func (storage *DBStorage) SetConsumerErrorMessageOptions(maxSize int, compress bool) {
+	storage.consumerErrorMessage = consumerErrorMessageOptions{
+		maxSize:  maxSize,
+		compress: compress,
+	}
+}
+
+// consumerErrorMessageValue returns the message value as stored into
+// consumer_error table. The value is truncated to the maximal size first
+// (the truncation marker is appended), then it is compressed. The value is
+// stored uncompressed when the compression fails.
+func (storage DBStorage) consumerErrorMessageValue(value []byte) []byte {
+	options := storage.consumerErrorMessage
+
+	if options.maxSize > 0 && len(value) > options.maxSize {
+		// capacity is limited, so the marker is not written into the
+		// original message value
+		marker := fmt.Sprintf(consumerErrorTruncationMarker, len(value))
+		value = append(value[:options.maxSize:options.maxSize], marker...)
+	}
+
+	if !options.compress || len(value) == 0 {
+		return value
+	}
+
+	var buffer bytes.Buffer
+
+	writer := gzip.NewWriter(&buffer)
+	_, err := writer.Write(value)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to compress message value of consumer error")
+		return value
+	}
+
+	return buffer.Bytes()
+}
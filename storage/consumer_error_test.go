@@ -0,0 +1,102 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+)
+
+// writeAndReadConsumerErrorMessage writes consumer error of the message with
+// the value and returns the message value as stored in the database
+func writeAndReadConsumerErrorMessage(t *testing.T, dbStorage *storage.DBStorage, value []byte) []byte {
+	err := dbStorage.WriteConsumerError(&sarama.ConsumerMessage{
+		Topic:     "topic",
+		Partition: 1,
+		Offset:    10,
+		Value:     value,
+		Timestamp: time.Now(),
+	}, errors.New("consumer error"))
+	helpers.FailOnError(t, err)
+
+	var stored []byte
+	err = storage.GetConnection(dbStorage).QueryRow(
+		"SELECT message FROM consumer_error WHERE topic = $1 AND partition = $2 AND topic_offset = $3",
+		"topic", 1, 10,
+	).Scan(&stored)
+	helpers.FailOnError(t, err)
+
+	return stored
+}
+
+func TestDBStorageWriteConsumerError_Truncated(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+	dbStorage.SetConsumerErrorMessageOptions(5, false)
+
+	value := []byte("0123456789")
+	stored := writeAndReadConsumerErrorMessage(t, dbStorage, value)
+
+	assert.Equal(t, "01234\n[truncated, original size 10 bytes]", string(stored))
+	// the consumed message is not changed
+	assert.Equal(t, "0123456789", string(value))
+}
+
+func TestDBStorageWriteConsumerError_NotTruncated(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+	dbStorage.SetConsumerErrorMessageOptions(10, false)
+
+	stored := writeAndReadConsumerErrorMessage(t, dbStorage, []byte("0123456789"))
+	assert.Equal(t, "0123456789", string(stored))
+}
+
+func TestDBStorageWriteConsumerError_Compressed(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+	dbStorage.SetConsumerErrorMessageOptions(5, true)
+
+	stored := writeAndReadConsumerErrorMessage(t, dbStorage, []byte("0123456789"))
+
+	reader, err := gzip.NewReader(bytes.NewReader(stored))
+	helpers.FailOnError(t, err)
+	decompressed, err := ioutil.ReadAll(reader)
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, "01234\n[truncated, original size 10 bytes]", string(decompressed))
+}
@@ -0,0 +1,97 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// WriteKafkaOffset stores offset of the latest message processed by the
+// consumer in the topic partition
+func (storage DBStorage) WriteKafkaOffset(topic string, partition int32, offset types.KafkaOffset) error {
+	ctx, cancel := storage.operationContext(writeOperation)
+	defer cancel()
+
+	_, err := storage.connection.ExecContext(ctx, `
+		INSERT INTO consumer_offset (topic, partition, kafka_offset, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (topic, partition) DO UPDATE SET
+			kafka_offset = $3,
+			updated_at = $4;
+	`, topic, partition, offset, time.Now())
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to write consumer offset")
+		return types.ConvertDBError(err, topic)
+	}
+
+	return nil
+}
+
+// GetLatestKafkaOffset returns offset of the latest message processed by the
+// consumer in the topic partition, 0 is returned when no message from the
+// partition was processed yet
+func (storage DBStorage) GetLatestKafkaOffset(topic string, partition int32) (types.KafkaOffset, error) {
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
+	var offset types.KafkaOffset
+
+	err := storage.connection.QueryRowContext(ctx, `
+		SELECT kafka_offset FROM consumer_offset WHERE topic = $1 AND partition = $2;
+	`, topic, partition).Scan(&offset)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+
+	return offset, err
+}
+
+// GetLatestKafkaOffsets returns offsets of the latest messages processed by
+// the consumer in all topic partitions ordered by topic and partition
+func (storage DBStorage) GetLatestKafkaOffsets() ([]types.KafkaPartitionOffset, error) {
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
+	offsets := make([]types.KafkaPartitionOffset, 0)
+
+	rows, err := storage.connection.QueryContext(ctx, `
+		SELECT topic, partition, kafka_offset, updated_at
+		FROM consumer_offset
+		ORDER BY topic, partition;
+	`)
+	if err != nil {
+		return offsets, err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var offset types.KafkaPartitionOffset
+
+		err = rows.Scan(&offset.Topic, &offset.Partition, &offset.Offset, &offset.UpdatedAt)
+		if err != nil {
+			log.Error().Err(err).Msg("GetLatestKafkaOffsets")
+			return offsets, err
+		}
+
+		offsets = append(offsets, offset)
+	}
+
+	return offsets, nil
+}
@@ -0,0 +1,217 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/RedHatInsights/insights-results-aggregator/migration"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// dbSchemaQueries are driver specific queries used to introspect the schema
+// of the database
+type dbSchemaQueries struct {
+	tables  string
+	columns string
+	// indexes returns one row per column of every index of the table as
+	// (index name, column name, unique, primary)
+	indexes string
+}
+
+var (
+	postgresSchemaQueries = dbSchemaQueries{
+		tables: `
+			SELECT table_name FROM information_schema.tables
+			WHERE table_schema = current_schema() AND table_type = 'BASE TABLE'
+			ORDER BY table_name
+		`,
+		columns: `
+			SELECT column_name, data_type, is_nullable = 'YES', COALESCE(column_default, '')
+			FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = $1
+			ORDER BY ordinal_position
+		`,
+		indexes: `
+			SELECT i.relname, a.attname, ix.indisunique, ix.indisprimary
+			FROM pg_index ix
+			JOIN pg_class t ON t.oid = ix.indrelid
+			JOIN pg_class i ON i.oid = ix.indexrelid
+			JOIN pg_namespace n ON n.oid = t.relnamespace
+			CROSS JOIN LATERAL unnest(ix.indkey) WITH ORDINALITY AS k(attnum, position)
+			JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum
+			WHERE n.nspname = current_schema() AND t.relname = $1
+			ORDER BY i.relname, k.position
+		`,
+	}
+
+	sqliteSchemaQueries = dbSchemaQueries{
+		tables: `
+			SELECT name FROM sqlite_master
+			WHERE type = 'table' AND name NOT LIKE 'sqlite_%'
+			ORDER BY name
+		`,
+		columns: `
+			SELECT name, type, "notnull" = 0 AND pk = 0, COALESCE(dflt_value, '')
+			FROM pragma_table_info($1)
+			ORDER BY cid
+		`,
+		indexes: `
+			SELECT il.name, COALESCE(ii.name, ''), il."unique", il.origin = 'pk'
+			FROM pragma_index_list($1) il
+			JOIN pragma_index_info(il.name) ii
+			ORDER BY il.name, ii.seqno
+		`,
+	}
+)
+
+// ReadDBSchema introspects tables, columns and indexes of the database and
+// returns them together with its current and the latest migration version
+func (storage DBStorage) ReadDBSchema() (types.DBSchema, error) {
+	var queries dbSchemaQueries
+
+	switch storage.dbDriverType {
+	case types.DBDriverPostgres:
+		queries = postgresSchemaQueries
+	case types.DBDriverSQLite3:
+		queries = sqliteSchemaQueries
+	default:
+		return types.DBSchema{}, fmt.Errorf("DB driver %v is not supported", storage.dbDriverType)
+	}
+
+	version, err := migration.GetDBVersion(storage.connection)
+	if err != nil {
+		return types.DBSchema{}, err
+	}
+
+	schema := types.DBSchema{
+		MigrationVersion:       uint(version),
+		LatestMigrationVersion: uint(migration.GetMaxVersion()),
+		Tables:                 make([]types.DBTable, 0),
+	}
+
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
+	tableNames, err := storage.readDBTableNames(ctx, queries.tables)
+	if err != nil {
+		return schema, err
+	}
+
+	// tables are described one by one after the list of them is read, the
+	// connection pool of SQLite can be limited to a single connection
+	for _, tableName := range tableNames {
+		table := types.DBTable{Name: tableName}
+
+		table.Columns, err = storage.readDBTableColumns(ctx, queries.columns, tableName)
+		if err != nil {
+			return schema, err
+		}
+
+		table.Indexes, err = storage.readDBTableIndexes(ctx, queries.indexes, tableName)
+		if err != nil {
+			return schema, err
+		}
+
+		schema.Tables = append(schema.Tables, table)
+	}
+
+	return schema, nil
+}
+
+// readDBTableNames returns names of all tables of the database
+func (storage DBStorage) readDBTableNames(ctx context.Context, query string) ([]string, error) {
+	rows, err := storage.connection.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	tableNames := make([]string, 0)
+
+	for rows.Next() {
+		var tableName string
+
+		if err := rows.Scan(&tableName); err != nil {
+			return nil, err
+		}
+
+		tableNames = append(tableNames, tableName)
+	}
+
+	return tableNames, rows.Err()
+}
+
+// readDBTableColumns returns columns of the table in the order they are
+// defined in
+func (storage DBStorage) readDBTableColumns(
+	ctx context.Context, query, tableName string,
+) ([]types.DBColumn, error) {
+	rows, err := storage.connection.QueryContext(ctx, query, tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	columns := make([]types.DBColumn, 0)
+
+	for rows.Next() {
+		var column types.DBColumn
+
+		if err := rows.Scan(&column.Name, &column.Type, &column.Nullable, &column.Default); err != nil {
+			return nil, err
+		}
+
+		columns = append(columns, column)
+	}
+
+	return columns, rows.Err()
+}
+
+// readDBTableIndexes returns indexes of the table sorted by name, the
+// columns of every index are in the order they are indexed in
+func (storage DBStorage) readDBTableIndexes(
+	ctx context.Context, query, tableName string,
+) ([]types.DBIndex, error) {
+	rows, err := storage.connection.QueryContext(ctx, query, tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	indexes := make([]types.DBIndex, 0)
+
+	for rows.Next() {
+		var (
+			index      types.DBIndex
+			columnName string
+		)
+
+		if err := rows.Scan(&index.Name, &columnName, &index.Unique, &index.Primary); err != nil {
+			return nil, err
+		}
+
+		if last := len(indexes) - 1; last >= 0 && indexes[last].Name == index.Name {
+			indexes[last].Columns = append(indexes[last].Columns, columnName)
+			continue
+		}
+
+		index.Columns = []string{columnName}
+		indexes = append(indexes, index)
+	}
+
+	return indexes, rows.Err()
+}
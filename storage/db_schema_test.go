@@ -0,0 +1,102 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/migration"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// findDBTable returns the table with the given name from the schema
+func findDBTable(t *testing.T, schema types.DBSchema, name string) types.DBTable {
+	for _, table := range schema.Tables {
+		if table.Name == name {
+			return table
+		}
+	}
+
+	t.Fatalf("table %s not found in DB schema", name)
+	return types.DBTable{}
+}
+
+// TestDBStorage_ReadDBSchema checks that tables, columns and indexes created
+// by migrations are introspected
+func TestDBStorage_ReadDBSchema(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	schema, err := mockStorage.ReadDBSchema()
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, uint(migration.GetMaxVersion()), schema.MigrationVersion)
+	assert.Equal(t, uint(migration.GetMaxVersion()), schema.LatestMigrationVersion)
+
+	ruleHit := findDBTable(t, schema, "rule_hit")
+
+	columnNames := make([]string, 0, len(ruleHit.Columns))
+	for _, column := range ruleHit.Columns {
+		columnNames = append(columnNames, column.Name)
+		if column.Name == "org_id" {
+			assert.False(t, column.Nullable)
+		}
+	}
+	assert.Subset(t, columnNames, []string{"org_id", "cluster_id", "rule_fqdn", "error_key", "template_data"})
+
+	assert.Contains(t, ruleHit.Indexes, types.DBIndex{
+		Name:    "rule_hit_cluster_id_idx",
+		Columns: []string{"cluster_id"},
+	})
+
+	var primaryKey *types.DBIndex
+	for i := range ruleHit.Indexes {
+		if ruleHit.Indexes[i].Primary {
+			primaryKey = &ruleHit.Indexes[i]
+		}
+	}
+	if assert.NotNil(t, primaryKey) {
+		assert.True(t, primaryKey.Unique)
+		assert.Equal(t, []string{"cluster_id", "org_id", "rule_fqdn", "error_key"}, primaryKey.Columns)
+	}
+}
+
+// TestDBStorage_ReadDBSchema_Migrations checks that the migration version of
+// not fully migrated database is reported
+func TestDBStorage_ReadDBSchema_Migrations(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, false)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+	helpers.FailOnError(t, migration.InitInfoTable(dbStorage.GetConnection()))
+	helpers.FailOnError(t, migration.SetDBVersion(
+		dbStorage.GetConnection(), dbStorage.GetDBDriverType(), 1,
+	))
+
+	schema, err := mockStorage.ReadDBSchema()
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, uint(1), schema.MigrationVersion)
+	assert.Equal(t, uint(migration.GetMaxVersion()), schema.LatestMigrationVersion)
+	findDBTable(t, schema, "report")
+	for _, table := range schema.Tables {
+		assert.NotEqual(t, "rule_hit", table.Name)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// builtInDrivers are SQL drivers of DBStorage, they can't be replaced by
+// registered drivers
+var builtInDrivers = []string{"sqlite3", "postgres"}
+
+// DriverFactory creates the storage of the registered driver from the
+// storage configuration. Options of the custom storage that don't fit into
+// Configuration have to be read by the factory itself.
+type DriverFactory func(configuration Configuration) (Storage, error)
+
+var (
+	registeredDrivers      = map[string]DriverFactory{}
+	registeredDriversMutex sync.RWMutex
+)
+
+// RegisterDriver makes the custom Storage implementation available under the
+// name, it's selected when the name is used as db_driver in the storage
+// configuration. It's meant to be called from init functions of packages
+// providing the storage, similarly to database/sql.Register. It panics when
+// the factory is nil or the name is already taken.
+func RegisterDriver(name string, factory DriverFactory) {
+	registeredDriversMutex.Lock()
+	defer registeredDriversMutex.Unlock()
+
+	if factory == nil {
+		panic("storage: factory of driver " + name + " is nil")
+	}
+
+	if isBuiltInDriver(name) {
+		panic("storage: driver " + name + " is built in")
+	}
+
+	if _, found := registeredDrivers[name]; found {
+		panic("storage: driver " + name + " is already registered")
+	}
+
+	registeredDrivers[name] = factory
+}
+
+// IsRegisteredDriver returns true when the driver was registered by
+// RegisterDriver, built-in drivers are not registered
+func IsRegisteredDriver(name string) bool {
+	registeredDriversMutex.RLock()
+	defer registeredDriversMutex.RUnlock()
+
+	_, found := registeredDrivers[name]
+	return found
+}
+
+// Drivers returns names of built-in drivers followed by sorted names of
+// registered drivers
+func Drivers() []string {
+	registeredDriversMutex.RLock()
+	defer registeredDriversMutex.RUnlock()
+
+	registered := make([]string, 0, len(registeredDrivers))
+	for name := range registeredDrivers {
+		registered = append(registered, name)
+	}
+	sort.Strings(registered)
+
+	return append(append([]string{}, builtInDrivers...), registered...)
+}
+
+// NewStorage creates the storage selected by the driver in the configuration.
+// Registered drivers are created by their factories, DBStorage is created for
+// built-in drivers.
+func NewStorage(configuration Configuration) (Storage, error) {
+	registeredDriversMutex.RLock()
+	factory, found := registeredDrivers[configuration.Driver]
+	registeredDriversMutex.RUnlock()
+
+	if !found {
+		dbStorage, err := New(configuration)
+		if err != nil {
+			return nil, err
+		}
+		return dbStorage, nil
+	}
+
+	storage, err := factory(configuration)
+	if err != nil {
+		return nil, fmt.Errorf("driver %v: %v", configuration.Driver, err)
+	}
+
+	return storage, nil
+}
+
+// isBuiltInDriver returns true for SQL drivers of DBStorage
+func isBuiltInDriver(name string) bool {
+	for _, builtIn := range builtInDrivers {
+		if name == builtIn {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,103 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+)
+
+// registered drivers can't be unregistered, so every test uses its own names
+const (
+	memoryTestDriver  = "memory-test"
+	failingTestDriver = "failing-test"
+)
+
+func init() {
+	storage.RegisterDriver(memoryTestDriver, func(storage.Configuration) (storage.Storage, error) {
+		return storage.NewMemoryStorage(), nil
+	})
+	storage.RegisterDriver(failingTestDriver, func(storage.Configuration) (storage.Storage, error) {
+		return nil, errors.New("storage is not available")
+	})
+}
+
+func TestRegisterDriver(t *testing.T) {
+	assert.True(t, storage.IsRegisteredDriver(memoryTestDriver))
+	assert.False(t, storage.IsRegisteredDriver("postgres"))
+	assert.False(t, storage.IsRegisteredDriver("unknown"))
+
+	drivers := storage.Drivers()
+	assert.Equal(t, []string{"sqlite3", "postgres"}, drivers[:2])
+	assert.Contains(t, drivers, memoryTestDriver)
+	assert.Contains(t, drivers, failingTestDriver)
+}
+
+// assertPanicsWithValue checks that the function panics with the value
+func assertPanicsWithValue(t *testing.T, expected interface{}, function func()) {
+	defer func() {
+		assert.Equal(t, expected, recover())
+	}()
+
+	function()
+}
+
+func TestRegisterDriver_Invalid(t *testing.T) {
+	factory := func(storage.Configuration) (storage.Storage, error) {
+		return storage.NewMemoryStorage(), nil
+	}
+
+	assertPanicsWithValue(t, "storage: driver memory-test is already registered", func() {
+		storage.RegisterDriver(memoryTestDriver, factory)
+	})
+	assertPanicsWithValue(t, "storage: driver postgres is built in", func() {
+		storage.RegisterDriver("postgres", factory)
+	})
+	assertPanicsWithValue(t, "storage: factory of driver nil-test is nil", func() {
+		storage.RegisterDriver("nil-test", nil)
+	})
+	assert.False(t, storage.IsRegisteredDriver("nil-test"))
+}
+
+func TestNewStorage_RegisteredDriver(t *testing.T) {
+	customStorage, err := storage.NewStorage(storage.Configuration{Driver: memoryTestDriver})
+	assert.NoError(t, err)
+	assert.IsType(t, &storage.MemoryStorage{}, customStorage)
+
+	_, err = storage.NewStorage(storage.Configuration{Driver: failingTestDriver})
+	assert.EqualError(t, err, "driver failing-test: storage is not available")
+
+	// registered drivers are not SQL drivers
+	_, err = storage.New(storage.Configuration{Driver: memoryTestDriver})
+	assert.EqualError(t, err, "driver memory-test is not a SQL driver")
+}
+
+func TestNewStorage_BuiltInDriver(t *testing.T) {
+	sqliteStorage, err := storage.NewStorage(storage.Configuration{
+		Driver:           "sqlite3",
+		SQLiteDataSource: ":memory:",
+	})
+	assert.NoError(t, err)
+	assert.IsType(t, &storage.DBStorage{}, sqliteStorage)
+	assert.NoError(t, sqliteStorage.Close())
+
+	customStorage, err := storage.NewStorage(storage.Configuration{Driver: "unknown"})
+	assert.EqualError(t, err, "driver unknown is not supported")
+	assert.Nil(t, customStorage)
+}
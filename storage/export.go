@@ -0,0 +1,229 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// ReportRecord represents one record from the report table
+type ReportRecord struct {
+	OrgID         types.OrgID
+	ClusterName   types.ClusterName
+	Report        types.ClusterReport
+	ReportedAt    sql.NullTime
+	LastCheckedAt sql.NullTime
+	KafkaOffset   types.KafkaOffset
+}
+
+// RuleHitRecord represents one record from the rule_hit table
+type RuleHitRecord struct {
+	OrgID        types.OrgID       `json:"org_id"`
+	ClusterName  types.ClusterName `json:"cluster"`
+	RuleFQDN     types.RuleID      `json:"rule_fqdn"`
+	ErrorKey     types.ErrorKey    `json:"error_key"`
+	TemplateData string            `json:"template_data"`
+}
+
+// IterateReports calls the callback for every record in the report table.
+// Records are read one by one so the whole table is never held in memory.
+// The iteration stops on first error returned by the callback. Operation
+// timeouts are not applied, the export can take long for large tables.
+func (storage DBStorage) IterateReports(callback func(ReportRecord) error) error {
+	rows, err := storage.readConnection().Query(`
+		SELECT org_id, cluster, report, reported_at, last_checked_at, kafka_offset
+		FROM report
+		ORDER BY org_id, cluster;
+	`)
+	if err != nil {
+		return types.ConvertDBError(err, nil)
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var (
+			record                    ReportRecord
+			reportedAt, lastCheckedAt types.NullTime
+			kafkaOffset               types.NullKafkaOffset
+		)
+
+		err := rows.Scan(
+			&record.OrgID,
+			&record.ClusterName,
+			&record.Report,
+			&reportedAt,
+			&lastCheckedAt,
+			&kafkaOffset,
+		)
+		if err != nil {
+			return types.ConvertDBError(err, nil)
+		}
+
+		record.ReportedAt = reportedAt.SQL()
+		record.LastCheckedAt = lastCheckedAt.SQL()
+		record.KafkaOffset = kafkaOffset.Offset
+
+		if err := callback(record); err != nil {
+			return err
+		}
+	}
+
+	return types.ConvertDBError(rows.Err(), nil)
+}
+
+// IterateRuleHits calls the callback for every record in the rule_hit table.
+// The iteration stops on first error returned by the callback.
+func (storage DBStorage) IterateRuleHits(callback func(RuleHitRecord) error) error {
+	rows, err := storage.readConnection().Query(`
+		SELECT org_id, cluster_id, rule_fqdn, error_key, template_data
+		FROM rule_hit
+		ORDER BY org_id, cluster_id, rule_fqdn, error_key;
+	`)
+	if err != nil {
+		return types.ConvertDBError(err, nil)
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var record RuleHitRecord
+
+		err := rows.Scan(
+			&record.OrgID,
+			&record.ClusterName,
+			&record.RuleFQDN,
+			&record.ErrorKey,
+			&record.TemplateData,
+		)
+		if err != nil {
+			return types.ConvertDBError(err, nil)
+		}
+
+		if err := callback(record); err != nil {
+			return err
+		}
+	}
+
+	return types.ConvertDBError(rows.Err(), nil)
+}
+
+// IterateRuleHitsForRule calls the callback for every record in the rule_hit
+// table with the rule and error key, ordered by organization and cluster.
+// Only rule hits of clusters of the organization are iterated unless orgID
+// is 0. The offset records are skipped and at most limit records are
+// iterated, 0 means no limit. Like other iterations, records are read one by
+// one, the iteration stops on first error returned by the callback and
+// operation timeouts are not applied.
+func (storage DBStorage) IterateRuleHitsForRule(
+	orgID types.OrgID,
+	ruleID types.RuleID,
+	errorKey types.ErrorKey,
+	offset, limit int,
+	callback func(RuleHitRecord) error,
+) error {
+	query := `
+		SELECT org_id, cluster_id, rule_fqdn, error_key, template_data
+		FROM rule_hit
+		WHERE rule_fqdn = $1 AND error_key = $2`
+	args := []interface{}{ruleID, errorKey}
+
+	if orgID != 0 {
+		args = append(args, orgID)
+		query += fmt.Sprintf(" AND org_id = $%d", len(args))
+	}
+
+	query += " ORDER BY org_id, cluster_id"
+
+	switch {
+	case limit > 0:
+		args = append(args, limit, offset)
+		query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	case offset > 0 && storage.dbDriverType == types.DBDriverSQLite3:
+		// SQLite doesn't support OFFSET without LIMIT
+		args = append(args, offset)
+		query += fmt.Sprintf(" LIMIT -1 OFFSET $%d", len(args))
+	case offset > 0:
+		args = append(args, offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	rows, err := storage.readConnection().Query(query+";", args...)
+	if err != nil {
+		return types.ConvertDBError(err, nil)
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var record RuleHitRecord
+
+		err := rows.Scan(
+			&record.OrgID,
+			&record.ClusterName,
+			&record.RuleFQDN,
+			&record.ErrorKey,
+			&record.TemplateData,
+		)
+		if err != nil {
+			return types.ConvertDBError(err, nil)
+		}
+
+		if err := callback(record); err != nil {
+			return err
+		}
+	}
+
+	return types.ConvertDBError(rows.Err(), nil)
+}
+
+// ReadRuleHitsForCluster returns raw records from the rule_hit table for the
+// cluster, without assembling the report. It's meant for debugging.
+func (storage DBStorage) ReadRuleHitsForCluster(clusterName types.ClusterName) ([]RuleHitRecord, error) {
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
+	records := make([]RuleHitRecord, 0)
+
+	rows, err := storage.readConnection().QueryContext(ctx, `
+		SELECT org_id, cluster_id, rule_fqdn, error_key, template_data
+		FROM rule_hit
+		WHERE cluster_id = $1
+		ORDER BY org_id, rule_fqdn, error_key;
+	`, clusterName)
+	if err != nil {
+		return records, types.ConvertDBError(err, clusterName)
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var record RuleHitRecord
+
+		err := rows.Scan(
+			&record.OrgID,
+			&record.ClusterName,
+			&record.RuleFQDN,
+			&record.ErrorKey,
+			&record.TemplateData,
+		)
+		if err != nil {
+			return records, types.ConvertDBError(err, clusterName)
+		}
+
+		records = append(records, record)
+	}
+
+	return records, types.ConvertDBError(rows.Err(), clusterName)
+}
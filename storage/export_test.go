@@ -18,8 +18,12 @@ package storage
 
 import (
 	"database/sql"
+	sql_driver "database/sql/driver"
 	"time"
 
+	"github.com/gchaincl/sqlhooks"
+
+	"github.com/RedHatInsights/insights-results-aggregator/chaos"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
 )
 
@@ -34,6 +38,8 @@ import (
 // to see why this trick is needed.
 type SQLHooks = sqlHooks
 
+type ClusterLocks = clusterLocks
+
 const (
 	LogFormatterString        = logFormatterString
 	SQLHooksKeyQueryBeginTime = sqlHooksKeyQueryBeginTime
@@ -42,6 +48,9 @@ const (
 var (
 	ConstructInClausule  = constructInClausule
 	ArgsWithClusterNames = argsWithClusterNames
+	InitAndGetDriver     = initAndGetDriver
+	NewClusterLocks      = newClusterLocks
+	RedactDataSource     = redactDataSource
 )
 
 func GetConnection(storage *DBStorage) *sql.DB {
@@ -51,3 +60,54 @@ func GetConnection(storage *DBStorage) *sql.DB {
 func GetClustersLastChecked(storage *DBStorage) map[types.ClusterName]time.Time {
 	return storage.clustersLastChecked
 }
+
+func SetSchema(storage *DBStorage, schema string) {
+	storage.schema = schema
+}
+
+func NewFailoverConnector(driver sql_driver.Driver, dataSources []string) sql_driver.Connector {
+	return &failoverConnector{driver: driver, dataSources: dataSources}
+}
+
+func GetFailoverConnTarget(conn sql_driver.Conn) sql_driver.Conn {
+	return conn.(*failoverConn).Conn
+}
+
+func NewChaosSQLHooks(injector *chaos.Injector, hooks sqlhooks.Hooks) sqlhooks.Hooks {
+	return &chaosSQLHooks{injector: injector, hooks: hooks}
+}
+
+func GetClusterLocks(storage *DBStorage) *ClusterLocks {
+	return storage.clusterLocks
+}
+
+func ReportUpsertSQL(dbDriver types.DBDriver) string {
+	return reportUpsert.sql(dbDriver)
+}
+
+func RuleHitUpsertSQL(dbDriver types.DBDriver) string {
+	return ruleHitUpsert.sql(dbDriver)
+}
+
+func UpsertRuleHit(
+	storage *DBStorage,
+	tx *sql.Tx,
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+	rule types.ReportItem,
+	firstSeenAt time.Time,
+) error {
+	return storage.upsertRuleHit(tx, orgID, clusterName, rule, firstSeenAt)
+}
+
+type RDSIAMTokenBuilder = rdsIAMTokenBuilder
+
+const RDSIAMTokenRefreshInterval = rdsIAMTokenRefreshInterval
+
+func NewRDSIAMAuthConnector(
+	driver sql_driver.Driver, dataSources []string, build RDSIAMTokenBuilder, now func() time.Time,
+) sql_driver.Connector {
+	tokens := newRDSIAMTokens(build)
+	tokens.now = now
+	return &rdsIAMAuthConnector{driver: driver, dataSources: dataSources, tokens: tokens}
+}
@@ -0,0 +1,191 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// externalReportUpsert writes the time the cluster was checked by the
+// external source
+var externalReportUpsert = upsertQuery{
+	table:           "external_report",
+	columns:         []string{"org_id", "cluster_id", "source", "last_checked_at", "reported_at", "kafka_offset"},
+	conflictColumns: []string{"cluster_id", "source"},
+	updateColumns:   []string{"org_id", "last_checked_at", "reported_at", "kafka_offset"},
+}
+
+// errExternalResultsNotSupported is returned by writes of external results
+// before the database is migrated
+var errExternalResultsNotSupported = fmt.Errorf(
+	"external results are not supported before DB migration %d", externalResultVersion,
+)
+
+// WriteExternalResults replaces results of the cluster from the external
+// source (security scanner, for example). Results of other sources are not
+// touched. ErrOldReport is returned when more recent results from the source
+// are already stored.
+func (storage DBStorage) WriteExternalResults(
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+	source string,
+	results []types.ExternalResult,
+	lastCheckedTime time.Time,
+	kafkaOffset types.KafkaOffset,
+) error {
+	if !storage.schemaAtLeast(externalResultVersion) {
+		return errExternalResultsNotSupported
+	}
+
+	storage.clusterLocks.Lock(clusterName)
+	defer storage.clusterLocks.Unlock(clusterName)
+
+	ctx, cancel := storage.operationContext(writeOperation)
+	defer cancel()
+
+	tx, err := storage.connection.BeginTx(ctx, nil)
+	if err != nil {
+		return types.ConvertDBError(err, nil)
+	}
+
+	err = func(tx *sql.Tx) error {
+		err := storage.lockClusterInTransaction(tx, clusterName)
+		if err != nil {
+			return err
+		}
+
+		var storedLastChecked time.Time
+
+		err = tx.QueryRow(
+			"SELECT last_checked_at FROM external_report WHERE cluster_id = $1 AND source = $2;",
+			clusterName, source,
+		).Scan(&storedLastChecked)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if err == nil && storedLastChecked.After(lastCheckedTime) {
+			log.Warn().Msgf(
+				"Database already contains results from %s for cluster %s more recent than %v",
+				source, clusterName, lastCheckedTime,
+			)
+			return types.ErrOldReport
+		}
+
+		err = externalReportUpsert.exec(tx, storage.dbDriverType, []interface{}{
+			orgID, clusterName, source, lastCheckedTime, time.Now(), kafkaOffset,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(
+			"DELETE FROM external_result WHERE cluster_id = $1 AND source = $2;", clusterName, source,
+		)
+		if err != nil {
+			return err
+		}
+
+		for _, result := range results {
+			_, err = tx.Exec(`
+				INSERT INTO external_result
+				(org_id, cluster_id, source, check_id, severity, description, details)
+				VALUES ($1, $2, $3, $4, $5, $6, $7);
+			`, orgID, clusterName, source, result.CheckID, result.Severity, result.Description, string(result.Details))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}(tx)
+
+	finishTransaction(tx, err)
+
+	if err == types.ErrOldReport {
+		return err
+	}
+
+	return types.ConvertDBError(err, []interface{}{orgID, clusterName, source})
+}
+
+// ReadExternalResults returns results of the cluster from the external
+// source, results of all sources are returned when the source is empty. The
+// results are ordered by source, the most severe results of the source go
+// first.
+func (storage DBStorage) ReadExternalResults(
+	clusterName types.ClusterName, source string,
+) ([]types.ExternalResult, error) {
+	results := make([]types.ExternalResult, 0)
+
+	if !storage.schemaAtLeast(externalResultVersion) {
+		return results, nil
+	}
+
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
+	query := `
+		SELECT result.source, result.check_id, result.severity, result.description, result.details,
+			report.last_checked_at
+		FROM external_result result
+		JOIN external_report report
+			ON report.cluster_id = result.cluster_id AND report.source = result.source
+		WHERE result.cluster_id = $1
+	`
+	args := []interface{}{clusterName}
+
+	if source != "" {
+		query += " AND result.source = $2"
+		args = append(args, source)
+	}
+
+	query += " ORDER BY result.source, result.severity DESC, result.check_id;"
+
+	rows, err := storage.readConnection().QueryContext(ctx, query, args...)
+	if err != nil {
+		return results, types.ConvertDBError(err, clusterName)
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var (
+			result      types.ExternalResult
+			details     string
+			lastChecked time.Time
+		)
+
+		err = rows.Scan(
+			&result.Source, &result.CheckID, &result.Severity, &result.Description, &details, &lastChecked,
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("ReadExternalResults")
+			return results, types.ConvertDBError(err, clusterName)
+		}
+
+		if details != "" {
+			result.Details = []byte(details)
+		}
+		result.LastCheckedAt = types.Timestamp(lastChecked.UTC().Format(time.RFC3339))
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
@@ -0,0 +1,245 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	sql_driver "database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// pgHost is one PostgreSQL host from the pg_host configuration option
+type pgHost struct {
+	host string
+	port int
+}
+
+// parsePGHosts parses comma-separated list of PostgreSQL hosts. Every host
+// can have its own port ("host:port"), defaultPort is used otherwise.
+func parsePGHosts(hosts string, defaultPort int) ([]pgHost, error) {
+	// no host configured, let the driver use its default
+	if hosts == "" {
+		return []pgHost{{host: hosts, port: defaultPort}}, nil
+	}
+
+	var parsed []pgHost
+
+	for _, item := range strings.Split(hosts, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			return nil, fmt.Errorf("empty host in PostgreSQL host list '%v'", hosts)
+		}
+
+		host, portStr, err := net.SplitHostPort(item)
+		if err != nil {
+			// no port specified for the host
+			parsed = append(parsed, pgHost{host: item, port: defaultPort})
+			continue
+		}
+
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port of PostgreSQL host '%v'", item)
+		}
+
+		parsed = append(parsed, pgHost{host: host, port: port})
+	}
+
+	return parsed, nil
+}
+
+// failoverConnector opens connections to the first PostgreSQL host that
+// accepts connections and allows writes, the same way as libpq does with
+// target_session_attrs=read-write. Hosts are tried in the configured order
+// for every new connection and host names are resolved on every attempt.
+// Connections are checked again whenever they are reused from the pool (see
+// failoverConn), so after failover the pool reconnects to the new primary
+// even when the old one keeps running as a standby.
+type failoverConnector struct {
+	driver      sql_driver.Driver
+	dataSources []string
+}
+
+// Connect returns connection to the first read-write host
+func (connector *failoverConnector) Connect(ctx context.Context) (sql_driver.Conn, error) {
+	var lastErr error
+
+	for _, dataSource := range connector.dataSources {
+		conn, err := connector.driver.Open(dataSource)
+		if err != nil {
+			log.Warn().Err(err).Msg("Unable to connect to PostgreSQL host, trying the next one")
+			lastErr = err
+			continue
+		}
+
+		readOnly, err := isReadOnlySession(ctx, conn)
+		if err == nil && !readOnly {
+			return &failoverConn{Conn: conn}, nil
+		}
+
+		if err == nil {
+			err = errors.New("PostgreSQL host is read-only")
+		}
+		log.Warn().Err(err).Msg("Unable to use PostgreSQL host, trying the next one")
+		lastErr = err
+
+		if err := conn.Close(); err != nil {
+			log.Error().Err(err).Msg("Unable to close connection to PostgreSQL host")
+		}
+	}
+
+	return nil, fmt.Errorf("no read-write PostgreSQL host is available, last error: %v", lastErr)
+}
+
+// Driver returns the underlying driver
+func (connector *failoverConnector) Driver() sql_driver.Driver {
+	return connector.driver
+}
+
+// failoverConn is a connection opened by failoverConnector. The session is
+// checked to allow writes whenever the connection is reused from the pool,
+// connections to the primary demoted to a standby are dropped then instead
+// of failing writes until they are closed. Other optional interfaces of the
+// driver connection are passed through, database/sql falls back to the
+// basic ones when the driver doesn't implement them.
+type failoverConn struct {
+	sql_driver.Conn
+}
+
+// ResetSession is called by database/sql before the connection is reused,
+// ErrBadConn makes it drop the connection and open a new one
+func (conn *failoverConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := conn.Conn.(sql_driver.SessionResetter); ok {
+		if err := resetter.ResetSession(ctx); err != nil {
+			return err
+		}
+	}
+
+	readOnly, err := isReadOnlySession(ctx, conn.Conn)
+	if err != nil {
+		log.Warn().Err(err).Msg("Unable to check PostgreSQL session, dropping the connection")
+		return sql_driver.ErrBadConn
+	}
+
+	if readOnly {
+		log.Warn().Msg("PostgreSQL host became read-only, dropping the connection")
+		return sql_driver.ErrBadConn
+	}
+
+	return nil
+}
+
+// BeginTx starts the transaction with the given options
+func (conn *failoverConn) BeginTx(ctx context.Context, opts sql_driver.TxOptions) (sql_driver.Tx, error) {
+	if beginner, ok := conn.Conn.(sql_driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+
+	if opts.Isolation != 0 || opts.ReadOnly {
+		return nil, errors.New("connection doesn't support transaction options")
+	}
+
+	return conn.Conn.Begin()
+}
+
+// PrepareContext prepares the statement
+func (conn *failoverConn) PrepareContext(ctx context.Context, query string) (sql_driver.Stmt, error) {
+	if preparer, ok := conn.Conn.(sql_driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+
+	return conn.Conn.Prepare(query)
+}
+
+// ExecContext executes the query without preparing it when the driver
+// supports it
+func (conn *failoverConn) ExecContext(
+	ctx context.Context, query string, args []sql_driver.NamedValue,
+) (sql_driver.Result, error) {
+	if execer, ok := conn.Conn.(sql_driver.ExecerContext); ok {
+		return execer.ExecContext(ctx, query, args)
+	}
+
+	return nil, sql_driver.ErrSkip
+}
+
+// QueryContext runs the query without preparing it when the driver supports
+// it
+func (conn *failoverConn) QueryContext(
+	ctx context.Context, query string, args []sql_driver.NamedValue,
+) (sql_driver.Rows, error) {
+	if queryer, ok := conn.Conn.(sql_driver.QueryerContext); ok {
+		return queryer.QueryContext(ctx, query, args)
+	}
+
+	return nil, sql_driver.ErrSkip
+}
+
+// Ping checks that the connection is alive
+func (conn *failoverConn) Ping(ctx context.Context) error {
+	if pinger, ok := conn.Conn.(sql_driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+
+	return nil
+}
+
+// CheckNamedValue converts query arguments the way the driver does
+func (conn *failoverConn) CheckNamedValue(value *sql_driver.NamedValue) error {
+	if checker, ok := conn.Conn.(sql_driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+
+	return sql_driver.ErrSkip
+}
+
+// isReadOnlySession checks whether the session doesn't allow writes, which
+// is the case for connections to hot standby servers
+func isReadOnlySession(ctx context.Context, conn sql_driver.Conn) (bool, error) {
+	queryer, ok := conn.(sql_driver.QueryerContext)
+	if !ok {
+		return false, errors.New("connection doesn't support queries")
+	}
+
+	rows, err := queryer.QueryContext(ctx, "SHOW transaction_read_only", nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	values := make([]sql_driver.Value, 1)
+	if err := rows.Next(values); err != nil {
+		return false, err
+	}
+
+	switch value := values[0].(type) {
+	case string:
+		return value == "on", nil
+	case []byte:
+		return string(value) == "on", nil
+	default:
+		return false, fmt.Errorf("unexpected value of transaction_read_only: %v", value)
+	}
+}
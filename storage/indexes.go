@@ -0,0 +1,146 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// expectedIndex is an index the most frequent query on the table relies on
+type expectedIndex struct {
+	name  string
+	table string
+	// hotQuery is the query using the index, its plan is logged when the
+	// index is missing, so it's visible how the table is scanned instead
+	hotQuery string
+	args     []interface{}
+}
+
+// expectedIndexes are indexes created by migrations for the hottest queries
+var expectedIndexes = []expectedIndex{
+	{
+		name:     "report_org_id_reported_at_idx",
+		table:    "report",
+		hotQuery: "SELECT cluster FROM report WHERE org_id = $1 AND reported_at >= $2 ORDER BY cluster",
+		args:     []interface{}{0, time.Unix(0, 0).UTC()},
+	},
+	{
+		name:     "rule_hit_cluster_id_idx",
+		table:    "rule_hit",
+		hotQuery: "SELECT template_data, rule_fqdn, error_key FROM rule_hit WHERE cluster_id = $1",
+		args:     []interface{}{""},
+	},
+	{
+		name:     "cluster_rule_toggle_cluster_id_rule_id_idx",
+		table:    "cluster_rule_toggle",
+		hotQuery: "SELECT rule_id, disabled FROM cluster_rule_toggle WHERE cluster_id = $1 AND rule_id = $2",
+		args:     []interface{}{"", ""},
+	},
+}
+
+// CheckIndexes checks that indexes used by the hottest queries exist and
+// returns names of the missing ones. Warning with the plan of the query
+// relying on the missing index is logged for each of them.
+func (storage DBStorage) CheckIndexes() ([]string, error) {
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
+	var indexQuery, explainPrefix string
+
+	switch storage.dbDriverType {
+	case types.DBDriverPostgres:
+		indexQuery = "SELECT COUNT(*) FROM pg_indexes WHERE schemaname = current_schema() AND tablename = $1 AND indexname = $2"
+		explainPrefix = "EXPLAIN "
+	case types.DBDriverSQLite3:
+		indexQuery = "SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND tbl_name = $1 AND name = $2"
+		explainPrefix = "EXPLAIN QUERY PLAN "
+	default:
+		return nil, fmt.Errorf("DB driver %v is not supported", storage.dbDriverType)
+	}
+
+	missing := make([]string, 0)
+
+	for _, index := range expectedIndexes {
+		var count int
+
+		err := storage.connection.QueryRowContext(ctx, indexQuery, index.table, index.name).Scan(&count)
+		if err != nil {
+			return missing, err
+		}
+
+		if count > 0 {
+			continue
+		}
+
+		missing = append(missing, index.name)
+
+		plan, err := storage.explainQuery(explainPrefix+index.hotQuery, index.args...)
+		if err != nil {
+			log.Error().Err(err).Str("index", index.name).Msg("Unable to explain query relying on missing index")
+		}
+
+		log.Warn().
+			Str("index", index.name).
+			Str("table", index.table).
+			Str("query", index.hotQuery).
+			Str("plan", plan).
+			Msg("Index used by frequent query is missing, check DB migrations")
+	}
+
+	return missing, nil
+}
+
+// explainQuery returns the plan of the query as reported by the DB, the last
+// column of every row of the plan is used, so it works for both EXPLAIN
+// output of PostgreSQL and EXPLAIN QUERY PLAN output of SQLite
+func (storage DBStorage) explainQuery(query string, args ...interface{}) (string, error) {
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
+	rows, err := storage.connection.QueryContext(ctx, query, args...)
+	if err != nil {
+		return "", err
+	}
+	defer closeRows(rows)
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+
+	lines := make([]string, 0)
+
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		for i := range values {
+			values[i] = new(sql.RawBytes)
+		}
+
+		if err := rows.Scan(values...); err != nil {
+			return "", err
+		}
+
+		lines = append(lines, string(*values[len(values)-1].(*sql.RawBytes)))
+	}
+
+	return strings.Join(lines, "\n"), rows.Err()
+}
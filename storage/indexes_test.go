@@ -0,0 +1,45 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+)
+
+// TestDBStorage_CheckIndexes checks that migrations create all indexes used
+// by the hottest queries and that the missing ones are reported
+func TestDBStorage_CheckIndexes(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+
+	missing, err := dbStorage.CheckIndexes()
+	helpers.FailOnError(t, err)
+	assert.Empty(t, missing)
+
+	_, err = storage.GetConnection(dbStorage).Exec("DROP INDEX rule_hit_cluster_id_idx")
+	helpers.FailOnError(t, err)
+
+	missing, err = dbStorage.CheckIndexes()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []string{"rule_hit_cluster_id_idx"}, missing)
+}
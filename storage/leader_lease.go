@@ -0,0 +1,59 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import "time"
+
+// AcquireLease acquires the lease of the given name for the holder or renews
+// it when the holder holds it already. The lease is valid for the given
+// duration. False is returned when the lease is held by another holder and
+// has not expired yet.
+func (storage DBStorage) AcquireLease(name, holder string, duration time.Duration) (bool, error) {
+	ctx, cancel := storage.operationContext(writeOperation)
+	defer cancel()
+
+	now := time.Now().UTC()
+
+	result, err := storage.connection.ExecContext(ctx, `
+		INSERT INTO leader_lease (name, holder, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO UPDATE SET holder = $2, expires_at = $3
+		WHERE leader_lease.holder = $2 OR leader_lease.expires_at < $4;
+	`, name, holder, now.Add(duration), now)
+	if err != nil {
+		return false, err
+	}
+
+	acquired, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return acquired > 0, nil
+}
+
+// ReleaseLease releases the lease of the given name, so another holder can
+// acquire it without waiting for the lease to expire. Nothing is done when
+// the lease is held by another holder.
+func (storage DBStorage) ReleaseLease(name, holder string) error {
+	ctx, cancel := storage.operationContext(writeOperation)
+	defer cancel()
+
+	_, err := storage.connection.ExecContext(
+		ctx, "DELETE FROM leader_lease WHERE name = $1 AND holder = $2;", name, holder,
+	)
+
+	return err
+}
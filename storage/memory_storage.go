@@ -0,0 +1,1249 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator/events"
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// MemoryStorage is an implementation of Storage interface keeping all data in
+// maps, so services embedding the aggregator and unit tests can run without
+// any database. All data behave the same way as in DBStorage with the
+// default configuration, including errors returned when the cluster doesn't
+// exist: statistics of checks and the history of written reports are not
+// kept, clusters reported under another organization are always moved to the
+// new organization. Operations tied to the database itself (its schema,
+// caches of the DB storage, recomputation of aggregates and the report
+// archive) return ErrNotSupported.
+type MemoryStorage struct {
+	mutex           sync.RWMutex
+	reports         map[types.ClusterName]*memoryReport
+	toggles         map[memoryToggleKey]memoryToggle
+	feedback        map[memoryFeedbackKey]UserFeedbackOnRule
+	disableFeedback map[memoryFeedbackKey]UserFeedbackOnRule
+	offsets         map[memoryOffsetKey]types.KafkaPartitionOffset
+	frozenOrgs      map[types.OrgID]types.OrgFreeze
+	usage           map[memoryUsageKey]types.OrgUsage
+	orgInfo         map[types.OrgID]memoryOrgInfo
+	orgFeedback     map[memoryOrgFeedbackKey]OrgUserFeedbackOnRule
+	ruleHitHistory  map[types.ClusterName][]memoryRuleHitOccurrence
+	annotations     map[types.ClusterName][]memoryAnnotation
+	reportHistory   map[types.ClusterName][]memoryHistoricalReport
+	externalResults map[memoryExternalKey]memoryExternalReport
+	staleWrites     map[memoryStaleWriteKey]memoryStaleWrite
+	orgChanges      []memoryOrgChange
+	consumerErrors  []memoryConsumerError
+	apiKeys         map[string]memoryAPIKey
+}
+
+// memoryReport is one row of the report table together with rule hits of
+// the report
+type memoryReport struct {
+	orgID       types.OrgID
+	report      types.ClusterReport
+	ruleHits    []types.ReportItem
+	reportedAt  time.Time
+	lastChecked time.Time
+	kafkaOffset types.KafkaOffset
+	class       types.ClusterClass
+	status      types.ReportStatus
+	messageKey  string
+	// firstSeen contains the time every rule ever reported for the cluster
+	// in its organization was reported for the first time, it replaces the
+	// history of rule hits
+	firstSeen map[ruleHitKey]time.Time
+}
+
+// memoryToggleKey identifies toggle of the rule for the cluster
+type memoryToggleKey struct {
+	clusterID types.ClusterName
+	ruleID    types.RuleID
+	errorKey  types.ErrorKey
+}
+
+// memoryToggle is one row of the cluster_rule_toggle table
+type memoryToggle struct {
+	ClusterRuleToggle
+	errorKey types.ErrorKey
+}
+
+// memoryFeedbackKey identifies feedback of the user on the rule for the
+// cluster
+type memoryFeedbackKey struct {
+	clusterID types.ClusterName
+	ruleID    types.RuleID
+	errorKey  types.ErrorKey
+	userID    types.UserID
+}
+
+// memoryOffsetKey identifies the topic partition
+type memoryOffsetKey struct {
+	topic     string
+	partition int32
+}
+
+// memoryUsageKey identifies usage of the organization in the month
+type memoryUsageKey struct {
+	orgID types.OrgID
+	month string
+}
+
+// NewMemoryStorage creates empty in-memory storage
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		reports:         make(map[types.ClusterName]*memoryReport),
+		toggles:         make(map[memoryToggleKey]memoryToggle),
+		feedback:        make(map[memoryFeedbackKey]UserFeedbackOnRule),
+		disableFeedback: make(map[memoryFeedbackKey]UserFeedbackOnRule),
+		offsets:         make(map[memoryOffsetKey]types.KafkaPartitionOffset),
+		frozenOrgs:      make(map[types.OrgID]types.OrgFreeze),
+		usage:           make(map[memoryUsageKey]types.OrgUsage),
+		orgInfo:         make(map[types.OrgID]memoryOrgInfo),
+		orgFeedback:     make(map[memoryOrgFeedbackKey]OrgUserFeedbackOnRule),
+		ruleHitHistory:  make(map[types.ClusterName][]memoryRuleHitOccurrence),
+		annotations:     make(map[types.ClusterName][]memoryAnnotation),
+		reportHistory:   make(map[types.ClusterName][]memoryHistoricalReport),
+		externalResults: make(map[memoryExternalKey]memoryExternalReport),
+		staleWrites:     make(map[memoryStaleWriteKey]memoryStaleWrite),
+		apiKeys:         make(map[string]memoryAPIKey),
+	}
+}
+
+// Init does nothing, the in-memory storage is ready when it is created
+func (storage *MemoryStorage) Init() error {
+	return nil
+}
+
+// Close does nothing, data of the in-memory storage are dropped together
+// with the storage
+func (storage *MemoryStorage) Close() error {
+	return nil
+}
+
+// GetDBDriverType returns DBDriverGeneral, the storage doesn't use any
+// database
+func (storage *MemoryStorage) GetDBDriverType() types.DBDriver {
+	return types.DBDriverGeneral
+}
+
+// Capabilities returns no capabilities, none of the database features is
+// available in memory
+func (storage *MemoryStorage) Capabilities() Capabilities {
+	return Capabilities{}
+}
+
+// ListOfOrgs returns organizations with at least one report ordered by ID
+func (storage *MemoryStorage) ListOfOrgs() ([]types.OrgID, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	orgs := make([]types.OrgID, 0)
+	seen := make(map[types.OrgID]bool)
+
+	for _, report := range storage.reports {
+		if !seen[report.orgID] {
+			seen[report.orgID] = true
+			orgs = append(orgs, report.orgID)
+		}
+	}
+
+	sort.Slice(orgs, func(i, j int) bool { return orgs[i] < orgs[j] })
+
+	return orgs, nil
+}
+
+// ListOfClustersForOrg returns clusters of the organization reported since
+// the time limit ordered by name, limit 0 means all clusters following the
+// offset
+func (storage *MemoryStorage) ListOfClustersForOrg(
+	orgID types.OrgID, timeLimit time.Time, offset, limit int,
+) ([]types.ClusterName, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	clusters := make([]types.ClusterName, 0)
+
+	for clusterName, report := range storage.reports {
+		if report.orgID == orgID && !report.reportedAt.Before(timeLimit) {
+			clusters = append(clusters, clusterName)
+		}
+	}
+
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i] < clusters[j] })
+
+	if offset >= len(clusters) {
+		return []types.ClusterName{}, nil
+	}
+	clusters = clusters[offset:]
+
+	if limit > 0 && limit < len(clusters) {
+		clusters = clusters[:limit]
+	}
+
+	return clusters, nil
+}
+
+// CountClustersForOrg returns number of clusters of the organization
+// reported since the time limit
+func (storage *MemoryStorage) CountClustersForOrg(orgID types.OrgID, timeLimit time.Time) (int, error) {
+	clusters, err := storage.ListOfClustersForOrg(orgID, timeLimit, 0, 0)
+	return len(clusters), err
+}
+
+// ReadReportForCluster returns rule hits of the cluster and the time it was
+// last checked
+func (storage *MemoryStorage) ReadReportForCluster(
+	orgID types.OrgID, clusterName types.ClusterName,
+) ([]types.RuleOnReport, types.Timestamp, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	report, found := storage.reports[clusterName]
+	if !found || report.orgID != orgID {
+		return make([]types.RuleOnReport, 0), "", types.ConvertDBError(
+			sql.ErrNoRows, []interface{}{orgID, clusterName},
+		)
+	}
+
+	return report.rules(), report.lastCheckedTimestamp(), nil
+}
+
+// ReadReportsForClusters returns reports of the given clusters, clusters
+// without report are missing in the returned map
+func (storage *MemoryStorage) ReadReportsForClusters(
+	clusterNames []types.ClusterName,
+) (map[types.ClusterName]types.ClusterReport, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	reports := make(map[types.ClusterName]types.ClusterReport)
+
+	for _, clusterName := range clusterNames {
+		if report, found := storage.reports[clusterName]; found {
+			reports[clusterName] = report.report
+		}
+	}
+
+	return reports, nil
+}
+
+// ReadOrgIDsForClusters returns organizations of the given clusters ordered
+// by ID
+func (storage *MemoryStorage) ReadOrgIDsForClusters(clusterNames []types.ClusterName) ([]types.OrgID, error) {
+	orgIDs, err := storage.ReadOrgIDsOfClusters(clusterNames)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]types.OrgID, 0)
+	seen := make(map[types.OrgID]bool)
+
+	for _, orgID := range orgIDs {
+		if !seen[orgID] {
+			seen[orgID] = true
+			ids = append(ids, orgID)
+		}
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	return ids, nil
+}
+
+// ReadSingleRuleTemplateData returns template data of one rule hit of the
+// cluster
+func (storage *MemoryStorage) ReadSingleRuleTemplateData(
+	orgID types.OrgID, clusterName types.ClusterName, ruleID types.RuleID, errorKey types.ErrorKey,
+) (interface{}, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	if report, found := storage.reports[clusterName]; found && report.orgID == orgID {
+		for _, ruleHit := range report.ruleHits {
+			if ruleHit.Module == ruleID && ruleHit.ErrorKey == errorKey {
+				return parseTemplateData(ruleHit.TemplateData), nil
+			}
+		}
+	}
+
+	return parseTemplateData(nil), types.ConvertDBError(
+		sql.ErrNoRows, []interface{}{orgID, clusterName, ruleID, errorKey},
+	)
+}
+
+// ReadReportForClusterByClusterName returns rule hits of the cluster and the
+// time it was last checked regardless of its organization
+func (storage *MemoryStorage) ReadReportForClusterByClusterName(
+	clusterName types.ClusterName,
+) ([]types.RuleOnReport, types.Timestamp, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	report, found := storage.reports[clusterName]
+	if !found {
+		return make([]types.RuleOnReport, 0), "", &types.ItemNotFoundError{
+			ItemID: fmt.Sprintf("%v", clusterName),
+		}
+	}
+
+	return report.rules(), report.lastCheckedTimestamp(), nil
+}
+
+// WriteReportForCluster writes the report of the cluster and its rule hits,
+// ErrOldReport is returned when the same or more recent report of the
+// cluster is already stored
+func (storage *MemoryStorage) WriteReportForCluster(
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+	report types.ClusterReport,
+	rules []types.ReportItem,
+	lastCheckedTime time.Time,
+	kafkaOffset types.KafkaOffset,
+) error {
+	err := storage.writeReport(orgID, clusterName, lastCheckedTime, kafkaOffset, func(stored *memoryReport) {
+		stored.report = report
+		stored.ruleHits = uniqueRuleHits(rules)
+		stored.status = types.ReportStatusAnalyzed
+
+		for _, ruleHit := range stored.ruleHits {
+			key := ruleHitKey{ruleID: ruleHit.Module, errorKey: ruleHit.ErrorKey}
+			if _, found := stored.firstSeen[key]; !found {
+				stored.firstSeen[key] = lastCheckedTime
+			}
+		}
+
+		storage.updateRuleHitHistory(orgID, clusterName, stored.ruleHits, lastCheckedTime)
+	})
+	if err != nil {
+		return recordReportWrite(err)
+	}
+
+	events.DefaultBus.PublishReportWritten(events.ReportWrittenEvent{
+		OrgID:       orgID,
+		ClusterName: clusterName,
+		LastChecked: lastCheckedTime,
+		RuleHits:    len(rules),
+	})
+
+	return recordReportWrite(nil)
+}
+
+// WriteFailedReportForCluster records that the analysis of the cluster
+// failed, rule hits from the last successful analysis are kept
+func (storage *MemoryStorage) WriteFailedReportForCluster(
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+	lastCheckedTime time.Time,
+	kafkaOffset types.KafkaOffset,
+) error {
+	err := storage.writeReport(orgID, clusterName, lastCheckedTime, kafkaOffset, func(stored *memoryReport) {
+		stored.report = emptyClusterReport
+		stored.status = types.ReportStatusFailed
+	})
+
+	return oldReportError(err)
+}
+
+// writeReport updates the stored report of the cluster when the report
+// being written is more recent. The cluster reported under another
+// organization is moved there without rule hits and the change of the
+// organization is recorded. The update is called with the mutex locked.
+func (storage *MemoryStorage) writeReport(
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+	lastCheckedTime time.Time,
+	kafkaOffset types.KafkaOffset,
+	update func(stored *memoryReport),
+) error {
+	storage.mutex.Lock()
+
+	stored, found := storage.reports[clusterName]
+	if found && !lastCheckedTime.After(stored.lastChecked) {
+		storage.mutex.Unlock()
+		metrics.ClustersLastCheckedCacheRejections.Inc()
+		if lastCheckedTime.Equal(stored.lastChecked) {
+			return errDuplicateReport
+		}
+		return types.ErrOldReport
+	}
+
+	var previousOrgID types.OrgID
+	if !found {
+		stored = &memoryReport{
+			class:     types.ClusterClassSelfManaged,
+			firstSeen: make(map[ruleHitKey]time.Time),
+		}
+		storage.reports[clusterName] = stored
+	} else if stored.orgID != orgID {
+		previousOrgID = stored.orgID
+		stored.ruleHits = nil
+		stored.firstSeen = make(map[ruleHitKey]time.Time)
+	}
+
+	stored.orgID = orgID
+	stored.reportedAt = time.Now()
+	stored.lastChecked = lastCheckedTime
+	stored.kafkaOffset = kafkaOffset
+	update(stored)
+
+	storage.recordOrgSeen(orgID, stored.reportedAt)
+	if previousOrgID != 0 {
+		storage.orgChanges = append(storage.orgChanges, memoryOrgChange{
+			ClusterOrgChange: types.ClusterOrgChange{
+				ClusterName:   clusterName,
+				PreviousOrgID: previousOrgID,
+				OrgID:         orgID,
+				ChangedAt:     memoryTimestamp(lastCheckedTime),
+				Resolution:    ClusterOrgConflictMove,
+			},
+			changedAt: lastCheckedTime,
+		})
+	}
+
+	storage.mutex.Unlock()
+
+	if previousOrgID != 0 {
+		events.DefaultBus.PublishClusterOrgConflict(events.ClusterOrgConflictEvent{
+			ClusterName:   clusterName,
+			PreviousOrgID: previousOrgID,
+			OrgID:         orgID,
+			DetectedAt:    lastCheckedTime,
+			Resolution:    ClusterOrgConflictMove,
+		})
+	}
+
+	return nil
+}
+
+// ReadReportStatusForCluster returns the status of the analysis of the last
+// report of the cluster, ItemNotFoundError is returned when no report was
+// received from the cluster yet
+func (storage *MemoryStorage) ReadReportStatusForCluster(
+	orgID types.OrgID, clusterName types.ClusterName,
+) (types.ReportStatus, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	report, found := storage.reports[clusterName]
+	if !found || report.orgID != orgID {
+		return types.ReportStatusNoData, types.ConvertDBError(
+			sql.ErrNoRows, []interface{}{orgID, clusterName},
+		)
+	}
+
+	return report.status, nil
+}
+
+// ReadRuleHitsFirstSeen returns the time the rules currently reported for
+// the cluster were reported for the first time ordered by rule and error key
+func (storage *MemoryStorage) ReadRuleHitsFirstSeen(
+	orgID types.OrgID, clusterName types.ClusterName,
+) ([]RuleHitFirstSeen, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	firstSeen := make([]RuleHitFirstSeen, 0)
+
+	report, found := storage.reports[clusterName]
+	if !found || report.orgID != orgID {
+		return firstSeen, nil
+	}
+
+	for _, ruleHit := range report.ruleHits {
+		firstSeen = append(firstSeen, RuleHitFirstSeen{
+			RuleID:      ruleHit.Module,
+			ErrorKey:    ruleHit.ErrorKey,
+			FirstSeenAt: report.firstSeen[ruleHitKey{ruleID: ruleHit.Module, errorKey: ruleHit.ErrorKey}],
+		})
+	}
+
+	sort.Slice(firstSeen, func(i, j int) bool {
+		if firstSeen[i].RuleID != firstSeen[j].RuleID {
+			return firstSeen[i].RuleID < firstSeen[j].RuleID
+		}
+		return firstSeen[i].ErrorKey < firstSeen[j].ErrorKey
+	})
+
+	return firstSeen, nil
+}
+
+// ReportsCount returns the number of stored reports
+func (storage *MemoryStorage) ReportsCount() (int, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	return len(storage.reports), nil
+}
+
+// DeleteReportsForOrg deletes reports of all clusters of the organization
+// together with feedback on their rules and the info about the organization
+func (storage *MemoryStorage) DeleteReportsForOrg(orgID types.OrgID) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	for clusterName, report := range storage.reports {
+		if report.orgID == orgID {
+			storage.deleteReport(clusterName)
+		}
+	}
+
+	delete(storage.orgInfo, orgID)
+
+	return nil
+}
+
+// DeleteReportsForCluster deletes the report of the cluster together with
+// feedback on its rules
+func (storage *MemoryStorage) DeleteReportsForCluster(clusterName types.ClusterName) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	storage.deleteReport(clusterName)
+
+	return nil
+}
+
+// DeleteClusterReport deletes the report of the decommissioned cluster,
+// ErrOldReport is returned when more recent report than deletedAt is stored
+func (storage *MemoryStorage) DeleteClusterReport(
+	orgID types.OrgID, clusterName types.ClusterName, deletedAt time.Time,
+) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	report, found := storage.reports[clusterName]
+	if found && !deletedAt.After(report.lastChecked) {
+		return types.ErrOldReport
+	}
+
+	if !found || report.orgID != orgID {
+		return &types.ItemNotFoundError{ItemID: fmt.Sprintf("%v/%v", orgID, clusterName)}
+	}
+
+	storage.deleteReport(clusterName)
+
+	return nil
+}
+
+// deleteReport deletes the report and cascades the deletion to feedback the
+// same way as foreign keys in the database do, the mutex has to be locked
+func (storage *MemoryStorage) deleteReport(clusterName types.ClusterName) {
+	delete(storage.reports, clusterName)
+
+	for key := range storage.feedback {
+		if key.clusterID == clusterName {
+			delete(storage.feedback, key)
+		}
+	}
+
+	for key := range storage.disableFeedback {
+		if key.clusterID == clusterName {
+			delete(storage.disableFeedback, key)
+		}
+	}
+}
+
+// GetOrgIDByClusterID returns organization of the cluster
+func (storage *MemoryStorage) GetOrgIDByClusterID(cluster types.ClusterName) (types.OrgID, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	report, found := storage.reports[cluster]
+	if !found {
+		return 0, sql.ErrNoRows
+	}
+
+	return report.orgID, nil
+}
+
+// DoesClusterExist checks if the cluster has a report
+func (storage *MemoryStorage) DoesClusterExist(clusterID types.ClusterName) (bool, error) {
+	exists, _, err := storage.DoesClusterExistWithOrgID(clusterID)
+	return exists, err
+}
+
+// DoesClusterExistWithOrgID checks if the cluster has a report and returns
+// the organization it belongs to
+func (storage *MemoryStorage) DoesClusterExistWithOrgID(clusterID types.ClusterName) (bool, types.OrgID, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	report, found := storage.reports[clusterID]
+	if !found {
+		return false, 0, nil
+	}
+
+	return true, report.orgID, nil
+}
+
+// ReadOrgIDsOfClusters returns organizations the given clusters belong to,
+// clusters that don't exist are missing in the returned map
+func (storage *MemoryStorage) ReadOrgIDsOfClusters(
+	clusterNames []types.ClusterName,
+) (map[types.ClusterName]types.OrgID, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	orgIDs := make(map[types.ClusterName]types.OrgID, len(clusterNames))
+
+	for _, clusterName := range clusterNames {
+		if report, found := storage.reports[clusterName]; found {
+			orgIDs[clusterName] = report.orgID
+		}
+	}
+
+	return orgIDs, nil
+}
+
+// WriteClusterClass stores the class of the cluster, nothing is stored when
+// the cluster doesn't have a report in the organization
+func (storage *MemoryStorage) WriteClusterClass(
+	orgID types.OrgID, clusterName types.ClusterName, class types.ClusterClass,
+) error {
+	if !class.IsValid() {
+		return fmt.Errorf("unknown cluster class '%s'", class)
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	if report, found := storage.reports[clusterName]; found && report.orgID == orgID {
+		report.class = class
+	}
+
+	return nil
+}
+
+// ReadClusterClasses returns classes of all clusters of the organization
+func (storage *MemoryStorage) ReadClusterClasses(orgID types.OrgID) (map[types.ClusterName]types.ClusterClass, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	classes := make(map[types.ClusterName]types.ClusterClass)
+
+	for clusterName, report := range storage.reports {
+		if report.orgID == orgID {
+			classes[clusterName] = report.class
+		}
+	}
+
+	return classes, nil
+}
+
+// rules returns rule hits of the report
+func (report *memoryReport) rules() []types.RuleOnReport {
+	rules := make([]types.RuleOnReport, 0, len(report.ruleHits))
+
+	for _, ruleHit := range report.ruleHits {
+		rules = append(rules, types.RuleOnReport{
+			Module:       ruleHit.Module,
+			ErrorKey:     ruleHit.ErrorKey,
+			TemplateData: parseTemplateData(ruleHit.TemplateData),
+		})
+	}
+
+	return rules
+}
+
+// lastCheckedTimestamp returns the time the cluster was last checked
+func (report *memoryReport) lastCheckedTimestamp() types.Timestamp {
+	return types.NullTime{Time: report.lastChecked, Valid: true}.Timestamp()
+}
+
+// memoryTimestamp formats the time the same way as times read from the
+// database, zero time (NULL in the database) is formatted as empty string
+func memoryTimestamp(t time.Time) types.Timestamp {
+	return types.NullTime{Time: t, Valid: !t.IsZero()}.Timestamp()
+}
+
+// uniqueRuleHits returns the rule hits without duplicates, the template data
+// of the last duplicate are kept the same way as by upsert of rule hits
+func uniqueRuleHits(rules []types.ReportItem) []types.ReportItem {
+	ruleHits := make([]types.ReportItem, 0, len(rules))
+	indexes := make(map[ruleHitKey]int)
+
+	for _, rule := range rules {
+		key := ruleHitKey{ruleID: rule.Module, errorKey: rule.ErrorKey}
+		if index, found := indexes[key]; found {
+			ruleHits[index] = rule
+			continue
+		}
+
+		indexes[key] = len(ruleHits)
+		ruleHits = append(ruleHits, rule)
+	}
+
+	return ruleHits
+}
+
+// ToggleRuleForCluster disables or enables the rule for the cluster
+func (storage *MemoryStorage) ToggleRuleForCluster(
+	clusterID types.ClusterName, ruleID types.RuleID, errorKey types.ErrorKey, ruleToggle RuleToggle,
+) error {
+	updatedAt := sql.NullTime{Time: time.Now(), Valid: true}
+
+	toggle := memoryToggle{
+		ClusterRuleToggle: ClusterRuleToggle{
+			ClusterID: clusterID,
+			RuleID:    ruleID,
+			Disabled:  ruleToggle,
+			UpdatedAt: updatedAt,
+		},
+		errorKey: errorKey,
+	}
+
+	switch ruleToggle {
+	case RuleToggleDisable:
+		toggle.DisabledAt = updatedAt
+	case RuleToggleEnable:
+		toggle.EnabledAt = updatedAt
+	default:
+		return fmt.Errorf("Unexpected rule toggle value")
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	storage.toggles[memoryToggleKey{clusterID, ruleID, errorKey}] = toggle
+
+	return nil
+}
+
+// GetFromClusterRuleToggle returns the most recently updated toggle of the
+// rule for the cluster
+func (storage *MemoryStorage) GetFromClusterRuleToggle(
+	clusterID types.ClusterName, ruleID types.RuleID,
+) (*ClusterRuleToggle, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	var latest *ClusterRuleToggle
+
+	for key, toggle := range storage.toggles {
+		if key.clusterID != clusterID || key.ruleID != ruleID {
+			continue
+		}
+
+		if latest == nil || toggle.UpdatedAt.Time.After(latest.UpdatedAt.Time) {
+			ruleToggle := toggle.ClusterRuleToggle
+			latest = &ruleToggle
+		}
+	}
+
+	if latest == nil {
+		return nil, &types.ItemNotFoundError{ItemID: ruleID}
+	}
+
+	return latest, nil
+}
+
+// GetTogglesForRules returns whether the rules are disabled for the cluster,
+// rules that were never toggled are missing in the returned map
+func (storage *MemoryStorage) GetTogglesForRules(
+	clusterID types.ClusterName, rulesReport []types.RuleOnReport,
+) (map[types.RuleID]bool, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	ruleIDs := make(map[types.RuleID]bool)
+	for _, rule := range rulesReport {
+		ruleIDs[rule.Module] = true
+	}
+
+	toggles := make(map[types.RuleID]bool)
+
+	for key, toggle := range storage.toggles {
+		if key.clusterID == clusterID && ruleIDs[key.ruleID] {
+			toggles[key.ruleID] = toggle.Disabled == RuleToggleDisable
+		}
+	}
+
+	return toggles, nil
+}
+
+// DeleteFromRuleClusterToggle deletes all toggles of the rule for the cluster
+func (storage *MemoryStorage) DeleteFromRuleClusterToggle(
+	clusterID types.ClusterName, ruleID types.RuleID,
+) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	for key := range storage.toggles {
+		if key.clusterID == clusterID && key.ruleID == ruleID {
+			delete(storage.toggles, key)
+		}
+	}
+
+	return nil
+}
+
+// ReadRuleDisableDetails returns details of all rules disabled for the
+// cluster together with the most recent disable feedback
+func (storage *MemoryStorage) ReadRuleDisableDetails(clusterID types.ClusterName) ([]RuleDisableDetails, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	details := make([]RuleDisableDetails, 0)
+
+	for key, toggle := range storage.toggles {
+		if key.clusterID != clusterID || toggle.Disabled != RuleToggleDisable {
+			continue
+		}
+
+		detail := RuleDisableDetails{
+			RuleID:     key.ruleID,
+			ErrorKey:   key.errorKey,
+			DisabledAt: toggle.DisabledAt,
+		}
+
+		var feedbackTime time.Time
+		for feedbackKey, feedback := range storage.disableFeedback {
+			if feedbackKey.clusterID == clusterID && feedbackKey.ruleID == key.ruleID &&
+				feedbackKey.errorKey == key.errorKey && feedback.UpdatedAt.After(feedbackTime) {
+				feedbackTime = feedback.UpdatedAt
+				detail.DisabledBy = feedback.UserID
+				detail.Justification = feedback.Message
+			}
+		}
+
+		details = append(details, detail)
+	}
+
+	sort.Slice(details, func(i, j int) bool {
+		if details[i].RuleID != details[j].RuleID {
+			return details[i].RuleID < details[j].RuleID
+		}
+		return details[i].ErrorKey < details[j].ErrorKey
+	})
+
+	return details, nil
+}
+
+// VoteOnRule likes or dislikes the rule for the cluster by the user
+func (storage *MemoryStorage) VoteOnRule(
+	clusterID types.ClusterName,
+	ruleID types.RuleID,
+	errorKey types.ErrorKey,
+	userID types.UserID,
+	userVote types.UserVote,
+	voteMessage string,
+) error {
+	return storage.addOrUpdateFeedback(
+		storage.feedback, memoryFeedbackKey{clusterID, ruleID, errorKey, userID}, &userVote, voteMessage,
+	)
+}
+
+// AddOrUpdateFeedbackOnRule adds or updates the message of the user on the
+// rule for the cluster, the vote is kept
+func (storage *MemoryStorage) AddOrUpdateFeedbackOnRule(
+	clusterID types.ClusterName,
+	ruleID types.RuleID,
+	errorKey types.ErrorKey,
+	userID types.UserID,
+	message string,
+) error {
+	return storage.addOrUpdateFeedback(
+		storage.feedback, memoryFeedbackKey{clusterID, ruleID, errorKey, userID}, nil, message,
+	)
+}
+
+// AddFeedbackOnRuleDisable adds or updates the feedback of the user on
+// disabling the rule for the cluster
+func (storage *MemoryStorage) AddFeedbackOnRuleDisable(
+	clusterID types.ClusterName,
+	ruleID types.RuleID,
+	errorKey types.ErrorKey,
+	userID types.UserID,
+	message string,
+) error {
+	return storage.addOrUpdateFeedback(
+		storage.disableFeedback, memoryFeedbackKey{clusterID, ruleID, errorKey, userID}, nil, message,
+	)
+}
+
+// addOrUpdateFeedback upserts the feedback into the given table, the vote is
+// updated only when userVote isn't nil. Feedback can be left only on clusters
+// with a report.
+func (storage *MemoryStorage) addOrUpdateFeedback(
+	table map[memoryFeedbackKey]UserFeedbackOnRule,
+	key memoryFeedbackKey,
+	userVote *types.UserVote,
+	message string,
+) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	if _, found := storage.reports[key.clusterID]; !found {
+		return &types.ForeignKeyError{}
+	}
+
+	now := time.Now()
+
+	feedback, found := table[key]
+	if !found {
+		feedback = UserFeedbackOnRule{
+			ClusterID: key.clusterID,
+			RuleID:    key.ruleID,
+			ErrorKey:  key.errorKey,
+			UserID:    key.userID,
+			UserVote:  types.UserVoteNone,
+			AddedAt:   now,
+		}
+	}
+
+	if userVote != nil {
+		feedback.UserVote = *userVote
+	}
+
+	feedback.Message = message
+	feedback.UpdatedAt = now
+	table[key] = feedback
+
+	metrics.FeedbackOnRules.Inc()
+
+	return nil
+}
+
+// GetUserFeedbackOnRule returns the feedback of the user on the rule for the
+// cluster
+func (storage *MemoryStorage) GetUserFeedbackOnRule(
+	clusterID types.ClusterName, ruleID types.RuleID, errorKey types.ErrorKey, userID types.UserID,
+) (*UserFeedbackOnRule, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	feedback, found := storage.feedback[memoryFeedbackKey{clusterID, ruleID, errorKey, userID}]
+	if !found {
+		return nil, &types.ItemNotFoundError{
+			ItemID: fmt.Sprintf("%v/%v/%v", clusterID, ruleID, userID),
+		}
+	}
+
+	return &feedback, nil
+}
+
+// GetUserFeedbackOnRuleDisable returns the feedback of the user on disabling
+// the rule for the cluster
+func (storage *MemoryStorage) GetUserFeedbackOnRuleDisable(
+	clusterID types.ClusterName, ruleID types.RuleID, userID types.UserID,
+) (*UserFeedbackOnRule, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	var disableFeedback *UserFeedbackOnRule
+
+	for key, feedback := range storage.disableFeedback {
+		if key.clusterID != clusterID || key.ruleID != ruleID || key.userID != userID {
+			continue
+		}
+
+		// the rule can be disabled with multiple error keys, the first
+		// one is returned
+		if disableFeedback == nil || key.errorKey < disableFeedback.ErrorKey {
+			feedback := feedback
+			disableFeedback = &feedback
+		}
+	}
+
+	if disableFeedback == nil {
+		return nil, &types.ItemNotFoundError{
+			ItemID: fmt.Sprintf("%v/%v/%v", clusterID, userID, ruleID),
+		}
+	}
+
+	return disableFeedback, nil
+}
+
+// GetUserFeedbackOnRules returns votes of the user on the rules for the
+// cluster
+func (storage *MemoryStorage) GetUserFeedbackOnRules(
+	clusterID types.ClusterName, rulesReport []types.RuleOnReport, userID types.UserID,
+) (map[types.RuleID]types.UserVote, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	ruleIDs := make(map[types.RuleID]bool)
+	for _, rule := range rulesReport {
+		ruleIDs[rule.Module] = true
+	}
+
+	feedbacks := make(map[types.RuleID]types.UserVote)
+
+	for key, feedback := range storage.feedback {
+		if key.clusterID == clusterID && key.userID == userID && ruleIDs[key.ruleID] {
+			feedbacks[key.ruleID] = feedback.UserVote
+		}
+	}
+
+	return feedbacks, nil
+}
+
+// GetUserDisableFeedbackOnRules returns the feedback of the user on disabling
+// the rules for the cluster
+func (storage *MemoryStorage) GetUserDisableFeedbackOnRules(
+	clusterID types.ClusterName, rulesReport []types.RuleOnReport, userID types.UserID,
+) (map[types.RuleID]UserFeedbackOnRule, error) {
+	feedbacks := make(map[types.RuleID]UserFeedbackOnRule)
+
+	for _, rule := range rulesReport {
+		feedback, err := storage.GetUserFeedbackOnRuleDisable(clusterID, rule.Module, userID)
+		if err == nil {
+			feedbacks[rule.Module] = *feedback
+		}
+	}
+
+	return feedbacks, nil
+}
+
+// GetUserFeedbackOnRulesForClusters returns all feedback of the user on rules
+// of the given clusters ordered by cluster, rule and error key
+func (storage *MemoryStorage) GetUserFeedbackOnRulesForClusters(
+	clusterNames []types.ClusterName, userID types.UserID,
+) ([]UserFeedbackOnRule, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	clusters := make(map[types.ClusterName]bool)
+	for _, clusterName := range clusterNames {
+		clusters[clusterName] = true
+	}
+
+	feedbacks := make([]UserFeedbackOnRule, 0)
+
+	for key, feedback := range storage.feedback {
+		if clusters[key.clusterID] && key.userID == userID {
+			feedbacks = append(feedbacks, feedback)
+		}
+	}
+
+	sort.Slice(feedbacks, func(i, j int) bool {
+		if feedbacks[i].ClusterID != feedbacks[j].ClusterID {
+			return feedbacks[i].ClusterID < feedbacks[j].ClusterID
+		}
+		if feedbacks[i].RuleID != feedbacks[j].RuleID {
+			return feedbacks[i].RuleID < feedbacks[j].RuleID
+		}
+		return feedbacks[i].ErrorKey < feedbacks[j].ErrorKey
+	})
+
+	return feedbacks, nil
+}
+
+// ImportUserFeedback writes the feedback with its original timestamps, either
+// all of them or none is written
+func (storage *MemoryStorage) ImportUserFeedback(feedbacks []UserFeedbackOnRule) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	for _, feedback := range feedbacks {
+		if _, found := storage.reports[feedback.ClusterID]; !found {
+			return &types.ForeignKeyError{}
+		}
+	}
+
+	for _, feedback := range feedbacks {
+		key := memoryFeedbackKey{feedback.ClusterID, feedback.RuleID, feedback.ErrorKey, feedback.UserID}
+		if stored, found := storage.feedback[key]; found {
+			feedback.AddedAt = stored.AddedAt
+		}
+		storage.feedback[key] = feedback
+	}
+
+	metrics.FeedbackOnRules.Add(float64(len(feedbacks)))
+
+	return nil
+}
+
+// ReadVoteSummaryOnRule returns the number of users who liked and disliked
+// the rule for the cluster
+func (storage *MemoryStorage) ReadVoteSummaryOnRule(
+	clusterID types.ClusterName, ruleID types.RuleID, errorKey types.ErrorKey,
+) (types.VoteSummary, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	var summary types.VoteSummary
+
+	for key, feedback := range storage.feedback {
+		if key.clusterID != clusterID || key.ruleID != ruleID || key.errorKey != errorKey {
+			continue
+		}
+
+		switch feedback.UserVote {
+		case types.UserVoteLike:
+			summary.Likes++
+		case types.UserVoteDislike:
+			summary.Dislikes++
+		}
+	}
+
+	return summary, nil
+}
+
+// WriteKafkaOffset stores offset of the latest message processed by the
+// consumer in the topic partition
+func (storage *MemoryStorage) WriteKafkaOffset(topic string, partition int32, offset types.KafkaOffset) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	storage.offsets[memoryOffsetKey{topic: topic, partition: partition}] = types.KafkaPartitionOffset{
+		Topic:     topic,
+		Partition: partition,
+		Offset:    offset,
+		UpdatedAt: time.Now(),
+	}
+
+	return nil
+}
+
+// GetLatestKafkaOffset returns offset of the latest message processed by the
+// consumer in the topic partition, 0 is returned when no message from the
+// partition was processed yet
+func (storage *MemoryStorage) GetLatestKafkaOffset(topic string, partition int32) (types.KafkaOffset, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	return storage.offsets[memoryOffsetKey{topic: topic, partition: partition}].Offset, nil
+}
+
+// GetLatestKafkaOffsets returns offsets of the latest messages processed by
+// the consumer in all topic partitions ordered by topic and partition
+func (storage *MemoryStorage) GetLatestKafkaOffsets() ([]types.KafkaPartitionOffset, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	offsets := make([]types.KafkaPartitionOffset, 0, len(storage.offsets))
+	for _, offset := range storage.offsets {
+		offsets = append(offsets, offset)
+	}
+
+	sort.Slice(offsets, func(i, j int) bool {
+		if offsets[i].Topic != offsets[j].Topic {
+			return offsets[i].Topic < offsets[j].Topic
+		}
+		return offsets[i].Partition < offsets[j].Partition
+	})
+
+	return offsets, nil
+}
+
+// FreezeOrg freezes the organization, reason of the freeze is updated when
+// the organization is frozen already
+func (storage *MemoryStorage) FreezeOrg(orgID types.OrgID, reason string) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	freeze, found := storage.frozenOrgs[orgID]
+	if !found {
+		freeze = types.OrgFreeze{
+			OrgID:    orgID,
+			FrozenAt: types.Timestamp(time.Now().UTC().Format(time.RFC3339)),
+		}
+	}
+	freeze.Reason = reason
+
+	storage.frozenOrgs[orgID] = freeze
+
+	return nil
+}
+
+// UnfreezeOrg removes the freeze of the organization. ItemNotFoundError is
+// returned when the organization is not frozen.
+func (storage *MemoryStorage) UnfreezeOrg(orgID types.OrgID) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	if _, found := storage.frozenOrgs[orgID]; !found {
+		return &types.ItemNotFoundError{ItemID: orgID}
+	}
+
+	delete(storage.frozenOrgs, orgID)
+
+	return nil
+}
+
+// IsOrgFrozen checks whether the organization is frozen
+func (storage *MemoryStorage) IsOrgFrozen(orgID types.OrgID) (bool, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	_, found := storage.frozenOrgs[orgID]
+
+	return found, nil
+}
+
+// ReadFrozenOrgs returns all frozen organizations ordered by organization ID
+func (storage *MemoryStorage) ReadFrozenOrgs() ([]types.OrgFreeze, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	freezes := make([]types.OrgFreeze, 0, len(storage.frozenOrgs))
+	for _, freeze := range storage.frozenOrgs {
+		freezes = append(freezes, freeze)
+	}
+
+	sort.Slice(freezes, func(i, j int) bool { return freezes[i].OrgID < freezes[j].OrgID })
+
+	return freezes, nil
+}
+
+// AddOrgUsage adds the given numbers of processed messages, stored bytes and
+// served API calls to the usage of the organization in the current month
+func (storage *MemoryStorage) AddOrgUsage(
+	orgID types.OrgID, messagesProcessed, bytesStored, apiCalls int64,
+) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	key := memoryUsageKey{orgID: orgID, month: UsageMonth(time.Now())}
+
+	usage, found := storage.usage[key]
+	if !found {
+		usage = types.OrgUsage{OrgID: orgID, Month: key.month}
+	}
+	usage.MessagesProcessed += messagesProcessed
+	usage.BytesStored += bytesStored
+	usage.APICalls += apiCalls
+
+	storage.usage[key] = usage
+
+	return nil
+}
+
+// ReadOrgUsage returns usage of all organizations in the given month (in
+// YYYY-MM format) ordered by organization
+func (storage *MemoryStorage) ReadOrgUsage(month string) ([]types.OrgUsage, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	usages := make([]types.OrgUsage, 0)
+	for key, usage := range storage.usage {
+		if key.month == month {
+			usages = append(usages, usage)
+		}
+	}
+
+	sort.Slice(usages, func(i, j int) bool { return usages[i].OrgID < usages[j].OrgID })
+
+	return usages, nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// memoryAPIKey is one row of the api_key table
+type memoryAPIKey struct {
+	key       types.APIKey
+	keyHash   string
+	createdAt time.Time
+	revoked   bool
+}
+
+// CreateAPIKey stores new API key with hash of its secret. Zero expiration
+// time means that the key never expires.
+func (storage *MemoryStorage) CreateAPIKey(
+	keyID, name string, scopes []string, keyHash string, expiresAt time.Time,
+) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	if _, found := storage.apiKeys[keyID]; found {
+		return fmt.Errorf("API key %v already exists", keyID)
+	}
+
+	createdAt := time.Now()
+
+	storage.apiKeys[keyID] = memoryAPIKey{
+		key: types.APIKey{
+			KeyID:     keyID,
+			Name:      name,
+			Scopes:    append([]string{}, scopes...),
+			CreatedAt: memoryTimestamp(createdAt),
+			ExpiresAt: memoryTimestamp(expiresAt),
+		},
+		keyHash:   keyHash,
+		createdAt: createdAt,
+	}
+
+	return nil
+}
+
+// RotateAPIKey replaces hash of the secret of the API key. ItemNotFoundError
+// is returned when the key doesn't exist or it is revoked.
+func (storage *MemoryStorage) RotateAPIKey(keyID, keyHash string) error {
+	return storage.updateAPIKey(keyID, func(apiKey *memoryAPIKey) {
+		apiKey.keyHash = keyHash
+		apiKey.key.RotatedAt = memoryTimestamp(time.Now())
+	})
+}
+
+// RevokeAPIKey revokes the API key, the key is kept, so it's still listed.
+// ItemNotFoundError is returned when the key doesn't exist or it is revoked
+// already.
+func (storage *MemoryStorage) RevokeAPIKey(keyID string) error {
+	return storage.updateAPIKey(keyID, func(apiKey *memoryAPIKey) {
+		apiKey.revoked = true
+		apiKey.key.RevokedAt = memoryTimestamp(time.Now())
+	})
+}
+
+// updateAPIKey updates the API key that is not revoked
+func (storage *MemoryStorage) updateAPIKey(keyID string, update func(apiKey *memoryAPIKey)) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	apiKey, found := storage.apiKeys[keyID]
+	if !found || apiKey.revoked {
+		return &types.ItemNotFoundError{ItemID: keyID}
+	}
+
+	update(&apiKey)
+	storage.apiKeys[keyID] = apiKey
+
+	return nil
+}
+
+// ReadAPIKey returns the API key together with hash of its secret.
+// ItemNotFoundError is returned when the key doesn't exist.
+func (storage *MemoryStorage) ReadAPIKey(keyID string) (types.APIKey, string, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	apiKey, found := storage.apiKeys[keyID]
+	if !found {
+		return types.APIKey{}, "", &types.ItemNotFoundError{ItemID: keyID}
+	}
+
+	return apiKey.apiKey(), apiKey.keyHash, nil
+}
+
+// ReadAPIKeys returns all API keys, including the expired and revoked ones,
+// ordered by the time they were created
+func (storage *MemoryStorage) ReadAPIKeys() ([]types.APIKey, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	apiKeys := make([]memoryAPIKey, 0, len(storage.apiKeys))
+	for _, apiKey := range storage.apiKeys {
+		apiKeys = append(apiKeys, apiKey)
+	}
+
+	sort.Slice(apiKeys, func(i, j int) bool {
+		if !apiKeys[i].createdAt.Equal(apiKeys[j].createdAt) {
+			return apiKeys[i].createdAt.Before(apiKeys[j].createdAt)
+		}
+		return apiKeys[i].key.KeyID < apiKeys[j].key.KeyID
+	})
+
+	keys := make([]types.APIKey, 0, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		keys = append(keys, apiKey.apiKey())
+	}
+
+	return keys, nil
+}
+
+// apiKey returns the API key with its own copy of scopes, so callers can't
+// change the stored key
+func (apiKey memoryAPIKey) apiKey() types.APIKey {
+	key := apiKey.key
+	key.Scopes = append([]string{}, key.Scopes...)
+
+	return key
+}
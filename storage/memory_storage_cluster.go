@@ -0,0 +1,378 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// memoryAnnotation is one row of the cluster_annotation table
+type memoryAnnotation struct {
+	types.ClusterAnnotation
+	createdAt time.Time
+}
+
+// memoryHistoricalReport is one row of the report_history table
+type memoryHistoricalReport struct {
+	orgID       types.OrgID
+	report      types.ClusterReport
+	lastChecked time.Time
+	reportedAt  time.Time
+	kafkaOffset types.KafkaOffset
+}
+
+// memoryExternalKey identifies results of the cluster from the external
+// source
+type memoryExternalKey struct {
+	clusterID types.ClusterName
+	source    string
+}
+
+// memoryExternalReport is one row of the external_report table together
+// with the results from the external source
+type memoryExternalReport struct {
+	orgID       types.OrgID
+	lastChecked time.Time
+	results     []types.ExternalResult
+}
+
+// memoryStaleWriteKey identifies stale report writes of the cluster
+type memoryStaleWriteKey struct {
+	orgID     types.OrgID
+	clusterID types.ClusterName
+}
+
+// memoryStaleWrite is one row of the stale_report_write table
+type memoryStaleWrite struct {
+	rejectedCount  int
+	lastRejectedAt time.Time
+	lastChecked    time.Time
+	producedAt     time.Time
+}
+
+// memoryOrgChange is one row of the cluster_org_change table
+type memoryOrgChange struct {
+	types.ClusterOrgChange
+	changedAt time.Time
+}
+
+// AddClusterAnnotation attaches a new annotation written by the author to
+// the cluster. ItemNotFoundError is returned when there is no report for the
+// cluster.
+func (storage *MemoryStorage) AddClusterAnnotation(
+	clusterID types.ClusterName, author types.UserID, message string,
+) (types.ClusterAnnotation, error) {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	if _, found := storage.reports[clusterID]; !found {
+		return types.ClusterAnnotation{}, &types.ItemNotFoundError{ItemID: clusterID}
+	}
+
+	createdAt := time.Now().UTC()
+	annotation := types.ClusterAnnotation{
+		ID:        uuid.New().String(),
+		ClusterID: clusterID,
+		Author:    author,
+		Message:   message,
+		CreatedAt: memoryTimestamp(createdAt),
+	}
+
+	storage.annotations[clusterID] = append(
+		storage.annotations[clusterID], memoryAnnotation{ClusterAnnotation: annotation, createdAt: createdAt},
+	)
+
+	return annotation, nil
+}
+
+// ReadClusterAnnotations returns all annotations of the cluster, the oldest
+// annotation goes first
+func (storage *MemoryStorage) ReadClusterAnnotations(clusterID types.ClusterName) ([]types.ClusterAnnotation, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	stored := append([]memoryAnnotation{}, storage.annotations[clusterID]...)
+
+	sort.Slice(stored, func(i, j int) bool {
+		if !stored[i].createdAt.Equal(stored[j].createdAt) {
+			return stored[i].createdAt.Before(stored[j].createdAt)
+		}
+		return stored[i].ID < stored[j].ID
+	})
+
+	annotations := make([]types.ClusterAnnotation, 0, len(stored))
+	for _, annotation := range stored {
+		annotations = append(annotations, annotation.ClusterAnnotation)
+	}
+
+	return annotations, nil
+}
+
+// DeleteClusterAnnotation deletes the annotation of the cluster.
+// ItemNotFoundError is returned when there is no such annotation.
+func (storage *MemoryStorage) DeleteClusterAnnotation(clusterID types.ClusterName, annotationID string) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	annotations := storage.annotations[clusterID]
+
+	for i, annotation := range annotations {
+		if annotation.ID == annotationID {
+			storage.annotations[clusterID] = append(annotations[:i:i], annotations[i+1:]...)
+			return nil
+		}
+	}
+
+	return &types.ItemNotFoundError{ItemID: annotationID}
+}
+
+// ReadReportChecks returns no checks, statistics of checks are disabled by
+// default in DBStorage and they are not kept in memory at all
+func (storage *MemoryStorage) ReadReportChecks(types.ClusterName, int) ([]types.ReportCheck, error) {
+	return make([]types.ReportCheck, 0), nil
+}
+
+// WriteReportHistory stores the report of the cluster into the history of
+// its reports, the same report can be written again. The latest report of
+// the cluster is not touched.
+func (storage *MemoryStorage) WriteReportHistory(
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+	report types.ClusterReport,
+	lastCheckedTime time.Time,
+	kafkaOffset types.KafkaOffset,
+) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	historicalReport := memoryHistoricalReport{
+		orgID:       orgID,
+		report:      report,
+		lastChecked: lastCheckedTime,
+		reportedAt:  time.Now(),
+		kafkaOffset: kafkaOffset,
+	}
+
+	history := storage.reportHistory[clusterName]
+	for i := range history {
+		if history[i].lastChecked.Equal(lastCheckedTime) {
+			history[i] = historicalReport
+			return nil
+		}
+	}
+
+	storage.reportHistory[clusterName] = append(history, historicalReport)
+
+	return nil
+}
+
+// ReadReportHistoryForCluster returns reports of the cluster stored in the
+// history whose time of the last check is in the range from (inclusive) to
+// (exclusive), zero times mean that the range is not limited. The oldest
+// report goes first.
+func (storage *MemoryStorage) ReadReportHistoryForCluster(
+	orgID types.OrgID, clusterName types.ClusterName, from, to time.Time,
+) ([]types.HistoricalReport, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	history := make([]memoryHistoricalReport, 0)
+	for _, report := range storage.reportHistory[clusterName] {
+		if report.orgID != orgID ||
+			(!from.IsZero() && report.lastChecked.Before(from)) ||
+			(!to.IsZero() && !report.lastChecked.Before(to)) {
+			continue
+		}
+
+		history = append(history, report)
+	}
+
+	sort.Slice(history, func(i, j int) bool { return history[i].lastChecked.Before(history[j].lastChecked) })
+
+	reports := make([]types.HistoricalReport, 0, len(history))
+	for _, report := range history {
+		reports = append(reports, types.HistoricalReport{
+			LastCheckedAt: memoryTimestamp(report.lastChecked),
+			ReportedAt:    memoryTimestamp(report.reportedAt),
+			Report:        report.report,
+		})
+	}
+
+	return reports, nil
+}
+
+// WriteExternalResults replaces results of the cluster from the external
+// source, results of other sources are not touched. ErrOldReport is returned
+// when more recent results from the source are already stored.
+func (storage *MemoryStorage) WriteExternalResults(
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+	source string,
+	results []types.ExternalResult,
+	lastCheckedTime time.Time,
+	_ types.KafkaOffset,
+) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	key := memoryExternalKey{clusterID: clusterName, source: source}
+
+	if stored, found := storage.externalResults[key]; found && stored.lastChecked.After(lastCheckedTime) {
+		return types.ErrOldReport
+	}
+
+	storedResults := make([]types.ExternalResult, 0, len(results))
+	for _, result := range results {
+		result.Source = source
+		result.LastCheckedAt = ""
+		storedResults = append(storedResults, result)
+	}
+
+	storage.externalResults[key] = memoryExternalReport{
+		orgID:       orgID,
+		lastChecked: lastCheckedTime,
+		results:     storedResults,
+	}
+
+	return nil
+}
+
+// ReadExternalResults returns results of the cluster from the external
+// source, results of all sources are returned when the source is empty. The
+// results are ordered by source, the most severe results of the source go
+// first.
+func (storage *MemoryStorage) ReadExternalResults(
+	clusterName types.ClusterName, source string,
+) ([]types.ExternalResult, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	results := make([]types.ExternalResult, 0)
+
+	for key, report := range storage.externalResults {
+		if key.clusterID != clusterName || (source != "" && key.source != source) {
+			continue
+		}
+
+		for _, result := range report.results {
+			result.LastCheckedAt = memoryTimestamp(report.lastChecked)
+			results = append(results, result)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Source != results[j].Source {
+			return results[i].Source < results[j].Source
+		}
+		if results[i].Severity != results[j].Severity {
+			return results[i].Severity > results[j].Severity
+		}
+		return results[i].CheckID < results[j].CheckID
+	})
+
+	return results, nil
+}
+
+// WriteStaleReport records that the report from the cluster was rejected
+// because a more recent report of the cluster was already stored, zero
+// producedAt means the time the report was produced to Kafka is not known
+func (storage *MemoryStorage) WriteStaleReport(
+	orgID types.OrgID, clusterName types.ClusterName, lastCheckedTime, producedAt time.Time,
+) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	key := memoryStaleWriteKey{orgID: orgID, clusterID: clusterName}
+
+	staleWrite := storage.staleWrites[key]
+	staleWrite.rejectedCount++
+	staleWrite.lastRejectedAt = time.Now()
+	staleWrite.lastChecked = lastCheckedTime
+	staleWrite.producedAt = producedAt
+
+	storage.staleWrites[key] = staleWrite
+
+	return nil
+}
+
+// ReadStaleReportWrites returns statistics of rejected stale reports for all
+// clusters ordered by organization and cluster
+func (storage *MemoryStorage) ReadStaleReportWrites() ([]types.StaleReportWrite, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	staleWrites := make([]types.StaleReportWrite, 0, len(storage.staleWrites))
+
+	for key, stored := range storage.staleWrites {
+		staleWrite := types.StaleReportWrite{
+			OrgID:          key.orgID,
+			ClusterName:    key.clusterID,
+			RejectedCount:  stored.rejectedCount,
+			LastRejectedAt: memoryTimestamp(stored.lastRejectedAt),
+			LastCheckedAt:  memoryTimestamp(stored.lastChecked),
+			ProducedAt:     memoryTimestamp(stored.producedAt),
+		}
+
+		if !stored.producedAt.IsZero() {
+			skew := stored.lastChecked.Sub(stored.producedAt).Seconds()
+			staleWrite.TimestampSkewSeconds = &skew
+		}
+
+		staleWrites = append(staleWrites, staleWrite)
+	}
+
+	sort.Slice(staleWrites, func(i, j int) bool {
+		if staleWrites[i].OrgID != staleWrites[j].OrgID {
+			return staleWrites[i].OrgID < staleWrites[j].OrgID
+		}
+		return staleWrites[i].ClusterName < staleWrites[j].ClusterName
+	})
+
+	return staleWrites, nil
+}
+
+// ReadClusterOrgChanges returns clusters that started to report under
+// another organization since the given time, the most recent change goes
+// first
+func (storage *MemoryStorage) ReadClusterOrgChanges(since time.Time) ([]types.ClusterOrgChange, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	stored := make([]memoryOrgChange, 0)
+	for _, change := range storage.orgChanges {
+		if !change.changedAt.Before(since) {
+			stored = append(stored, change)
+		}
+	}
+
+	sort.SliceStable(stored, func(i, j int) bool {
+		if !stored[i].changedAt.Equal(stored[j].changedAt) {
+			return stored[i].changedAt.After(stored[j].changedAt)
+		}
+		return stored[i].ClusterName < stored[j].ClusterName
+	})
+
+	changes := make([]types.ClusterOrgChange, 0, len(stored))
+	for _, change := range stored {
+		changes = append(changes, change.ClusterOrgChange)
+	}
+
+	return changes, nil
+}
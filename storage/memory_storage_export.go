@@ -0,0 +1,154 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"sort"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// IterateReports calls the callback for every stored report ordered by
+// organization and cluster. The reports are copied before the iteration, so
+// the callback can use the storage. The iteration stops on first error
+// returned by the callback.
+func (storage *MemoryStorage) IterateReports(callback func(ReportRecord) error) error {
+	storage.mutex.RLock()
+
+	records := make([]ReportRecord, 0, len(storage.reports))
+	for clusterName, report := range storage.reports {
+		records = append(records, ReportRecord{
+			OrgID:         report.orgID,
+			ClusterName:   clusterName,
+			Report:        report.report,
+			ReportedAt:    sql.NullTime{Time: report.reportedAt, Valid: true},
+			LastCheckedAt: sql.NullTime{Time: report.lastChecked, Valid: true},
+			KafkaOffset:   report.kafkaOffset,
+		})
+	}
+
+	storage.mutex.RUnlock()
+
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].OrgID != records[j].OrgID {
+			return records[i].OrgID < records[j].OrgID
+		}
+		return records[i].ClusterName < records[j].ClusterName
+	})
+
+	for _, record := range records {
+		if err := callback(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// IterateRuleHits calls the callback for every rule hit ordered by
+// organization, cluster, rule and error key. The iteration stops on first
+// error returned by the callback.
+func (storage *MemoryStorage) IterateRuleHits(callback func(RuleHitRecord) error) error {
+	return iterateRuleHitRecords(storage.ruleHitRecords(func(RuleHitRecord) bool { return true }), callback)
+}
+
+// IterateRuleHitsForRule calls the callback for every rule hit with the rule
+// and error key ordered by organization and cluster. Only rule hits of
+// clusters of the organization are iterated unless orgID is 0. The offset
+// records are skipped and at most limit records are iterated, 0 means no
+// limit.
+func (storage *MemoryStorage) IterateRuleHitsForRule(
+	orgID types.OrgID,
+	ruleID types.RuleID,
+	errorKey types.ErrorKey,
+	offset, limit int,
+	callback func(RuleHitRecord) error,
+) error {
+	records := storage.ruleHitRecords(func(record RuleHitRecord) bool {
+		return record.RuleFQDN == ruleID && record.ErrorKey == errorKey && (orgID == 0 || record.OrgID == orgID)
+	})
+
+	if offset >= len(records) {
+		return nil
+	}
+	records = records[offset:]
+
+	if limit > 0 && limit < len(records) {
+		records = records[:limit]
+	}
+
+	return iterateRuleHitRecords(records, callback)
+}
+
+// ReadRuleHitsForCluster returns raw records of rule hits of the cluster
+// ordered by rule and error key
+func (storage *MemoryStorage) ReadRuleHitsForCluster(clusterName types.ClusterName) ([]RuleHitRecord, error) {
+	return storage.ruleHitRecords(func(record RuleHitRecord) bool {
+		return record.ClusterName == clusterName
+	}), nil
+}
+
+// ruleHitRecords returns rule hits selected by the filter ordered by
+// organization, cluster, rule and error key
+func (storage *MemoryStorage) ruleHitRecords(filter func(RuleHitRecord) bool) []RuleHitRecord {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	records := make([]RuleHitRecord, 0)
+
+	for clusterName, report := range storage.reports {
+		for _, ruleHit := range report.ruleHits {
+			record := RuleHitRecord{
+				OrgID:        report.orgID,
+				ClusterName:  clusterName,
+				RuleFQDN:     ruleHit.Module,
+				ErrorKey:     ruleHit.ErrorKey,
+				TemplateData: string(ruleHit.TemplateData),
+			}
+
+			if filter(record) {
+				records = append(records, record)
+			}
+		}
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].OrgID != records[j].OrgID {
+			return records[i].OrgID < records[j].OrgID
+		}
+		if records[i].ClusterName != records[j].ClusterName {
+			return records[i].ClusterName < records[j].ClusterName
+		}
+		if records[i].RuleFQDN != records[j].RuleFQDN {
+			return records[i].RuleFQDN < records[j].RuleFQDN
+		}
+		return records[i].ErrorKey < records[j].ErrorKey
+	})
+
+	return records
+}
+
+// iterateRuleHitRecords calls the callback for every record until it returns
+// an error
+func iterateRuleHitRecords(records []RuleHitRecord, callback func(RuleHitRecord) error) error {
+	for _, record := range records {
+		if err := callback(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
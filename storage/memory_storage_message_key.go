@@ -0,0 +1,135 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Shopify/sarama"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// memoryConsumerError is one row of the consumer_error table, values of the
+// messages are not kept because nothing reads them back
+type memoryConsumerError struct {
+	types.MessageKeyConsumerErr
+	key        string
+	consumedAt time.Time
+}
+
+// WriteConsumerError records the error the consumer hit while processing the
+// message
+func (storage *MemoryStorage) WriteConsumerError(msg *sarama.ConsumerMessage, consumerErr error) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	consumedAt := time.Now().UTC()
+
+	storage.consumerErrors = append(storage.consumerErrors, memoryConsumerError{
+		MessageKeyConsumerErr: types.MessageKeyConsumerErr{
+			Topic:      msg.Topic,
+			Partition:  msg.Partition,
+			Offset:     msg.Offset,
+			ProducedAt: memoryTimestamp(msg.Timestamp),
+			ConsumedAt: memoryTimestamp(consumedAt),
+			Error:      consumerErr.Error(),
+		},
+		key:        string(msg.Key),
+		consumedAt: consumedAt,
+	})
+
+	return nil
+}
+
+// WriteReportMessageKey stores the key of the Kafka message the report of
+// the cluster was consumed from. The key is not stored when more recent
+// report of the cluster than the one checked at lastCheckedTime is already
+// stored.
+func (storage *MemoryStorage) WriteReportMessageKey(
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+	lastCheckedTime time.Time,
+	key string,
+) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	report, found := storage.reports[clusterName]
+	if found && report.orgID == orgID && !report.lastChecked.After(lastCheckedTime) {
+		report.messageKey = key
+	}
+
+	return nil
+}
+
+// LookupMessageKey returns reports and consumer errors stored for the Kafka
+// message key, the most recent ones first
+func (storage *MemoryStorage) LookupMessageKey(key string) (types.MessageKeyLookup, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	lookup := types.MessageKeyLookup{
+		Key:            key,
+		Reports:        make([]types.MessageKeyReport, 0),
+		ConsumerErrors: make([]types.MessageKeyConsumerErr, 0),
+	}
+
+	// missing keys are stored as NULL, so they never match
+	if key == "" {
+		return lookup, nil
+	}
+
+	clusterNames := make([]types.ClusterName, 0)
+	for clusterName, report := range storage.reports {
+		if report.messageKey == key {
+			clusterNames = append(clusterNames, clusterName)
+		}
+	}
+
+	sort.Slice(clusterNames, func(i, j int) bool {
+		return storage.reports[clusterNames[i]].lastChecked.After(storage.reports[clusterNames[j]].lastChecked)
+	})
+
+	for _, clusterName := range clusterNames {
+		report := storage.reports[clusterName]
+		lookup.Reports = append(lookup.Reports, types.MessageKeyReport{
+			OrgID:         report.orgID,
+			ClusterName:   clusterName,
+			Status:        report.status,
+			LastCheckedAt: memoryTimestamp(report.lastChecked),
+			ReportedAt:    memoryTimestamp(report.reportedAt),
+			KafkaOffset:   report.kafkaOffset,
+		})
+	}
+
+	consumerErrors := make([]memoryConsumerError, 0)
+	for _, consumerErr := range storage.consumerErrors {
+		if consumerErr.key == key {
+			consumerErrors = append(consumerErrors, consumerErr)
+		}
+	}
+
+	sort.SliceStable(consumerErrors, func(i, j int) bool {
+		return consumerErrors[i].consumedAt.After(consumerErrors[j].consumedAt)
+	})
+
+	for _, consumerErr := range consumerErrors {
+		lookup.ConsumerErrors = append(lookup.ConsumerErrors, consumerErr.MessageKeyConsumerErr)
+	}
+
+	return lookup, nil
+}
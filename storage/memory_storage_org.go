@@ -0,0 +1,348 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// memoryOrgInfo is one row of the org_info table
+type memoryOrgInfo struct {
+	firstSeenAt time.Time
+	lastSeenAt  time.Time
+}
+
+// memoryOrgFeedbackKey identifies feedback of the user on the rule for the
+// organization
+type memoryOrgFeedbackKey struct {
+	orgID    types.OrgID
+	ruleID   types.RuleID
+	errorKey types.ErrorKey
+	userID   types.UserID
+}
+
+// recordOrgSeen records that a report from the organization was received at
+// the given time, the mutex has to be locked
+func (storage *MemoryStorage) recordOrgSeen(orgID types.OrgID, seenAt time.Time) {
+	info, found := storage.orgInfo[orgID]
+	if !found {
+		info.firstSeenAt = seenAt
+	}
+	info.lastSeenAt = seenAt
+
+	storage.orgInfo[orgID] = info
+}
+
+// ReadOrgInfo returns when the organization was first seen and when the last
+// report from it was received. ItemNotFoundError is returned when no report
+// was received from the organization yet.
+func (storage *MemoryStorage) ReadOrgInfo(orgID types.OrgID) (types.OrgInfo, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	info, found := storage.orgInfo[orgID]
+	if !found {
+		return types.OrgInfo{}, types.ConvertDBError(sql.ErrNoRows, orgID)
+	}
+
+	return types.OrgInfo{
+		OrgID:       orgID,
+		FirstSeenAt: memoryTimestamp(info.firstSeenAt),
+		LastSeenAt:  memoryTimestamp(info.lastSeenAt),
+	}, nil
+}
+
+// ListOfOrgsWithSummary returns organizations with at least one report
+// together with number of their clusters and the time when the most recent
+// report was checked, ordered by ID
+func (storage *MemoryStorage) ListOfOrgsWithSummary() ([]types.OrgSummary, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	clusterCounts := make(map[types.OrgID]int)
+	lastChecked := make(map[types.OrgID]time.Time)
+
+	for _, report := range storage.reports {
+		clusterCounts[report.orgID]++
+		if report.lastChecked.After(lastChecked[report.orgID]) {
+			lastChecked[report.orgID] = report.lastChecked
+		}
+	}
+
+	orgs := make([]types.OrgSummary, 0, len(clusterCounts))
+	for orgID, clusterCount := range clusterCounts {
+		orgs = append(orgs, types.OrgSummary{
+			OrgID:         orgID,
+			ClusterCount:  clusterCount,
+			LastCheckedAt: memoryTimestamp(lastChecked[orgID]),
+		})
+	}
+
+	sort.Slice(orgs, func(i, j int) bool { return orgs[i].OrgID < orgs[j].OrgID })
+
+	return orgs, nil
+}
+
+// ReadOrgRuleDisables returns numbers of rules disabled for clusters of the
+// organization grouped by rule, rules disabled for all its clusters are
+// counted as disabled for the organization. At most mostDisabledLimit of the
+// most commonly disabled rules are returned.
+func (storage *MemoryStorage) ReadOrgRuleDisables(
+	orgID types.OrgID, mostDisabledLimit int,
+) (types.OrgRuleDisables, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	disables := types.OrgRuleDisables{MostDisabled: make([]types.DisabledRuleCount, 0)}
+
+	clusters := 0
+	for _, report := range storage.reports {
+		if report.orgID == orgID {
+			clusters++
+		}
+	}
+
+	counts := make(map[ruleHitKey]int)
+	for key, toggle := range storage.toggles {
+		report, found := storage.reports[key.clusterID]
+		if found && report.orgID == orgID && toggle.Disabled == RuleToggleDisable {
+			counts[ruleHitKey{ruleID: key.ruleID, errorKey: key.errorKey}]++
+		}
+	}
+
+	rules := make([]types.DisabledRuleCount, 0, len(counts))
+	for key, count := range counts {
+		rules = append(rules, types.DisabledRuleCount{RuleID: key.ruleID, ErrorKey: key.errorKey, Clusters: count})
+	}
+
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Clusters != rules[j].Clusters {
+			return rules[i].Clusters > rules[j].Clusters
+		}
+		if rules[i].RuleID != rules[j].RuleID {
+			return rules[i].RuleID < rules[j].RuleID
+		}
+		return rules[i].ErrorKey < rules[j].ErrorKey
+	})
+
+	for _, rule := range rules {
+		disables.ClusterLevel += rule.Clusters
+		if rule.Clusters == clusters {
+			disables.OrgLevel++
+		}
+		if len(disables.MostDisabled) < mostDisabledLimit {
+			disables.MostDisabled = append(disables.MostDisabled, rule)
+		}
+	}
+
+	return disables, nil
+}
+
+// ReadOrgReport returns report merged from the latest reports of all
+// clusters of the organization ordered by rule, clusters where the rule is
+// disabled are listed separately
+func (storage *MemoryStorage) ReadOrgReport(orgID types.OrgID) ([]types.OrgReportRule, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	rules := make(map[ruleHitKey]*types.OrgReportRule)
+
+	for clusterName, report := range storage.reports {
+		if report.orgID != orgID {
+			continue
+		}
+
+		for _, ruleHit := range report.ruleHits {
+			key := ruleHitKey{ruleID: ruleHit.Module, errorKey: ruleHit.ErrorKey}
+
+			rule, found := rules[key]
+			if !found {
+				rule = &types.OrgReportRule{
+					RuleID:           ruleHit.Module,
+					ErrorKey:         ruleHit.ErrorKey,
+					Clusters:         make([]types.ClusterName, 0),
+					DisabledClusters: make([]types.ClusterName, 0),
+				}
+				rules[key] = rule
+			}
+
+			toggle := storage.toggles[memoryToggleKey{clusterName, ruleHit.Module, ruleHit.ErrorKey}]
+			if toggle.Disabled == RuleToggleDisable {
+				rule.DisabledClusters = append(rule.DisabledClusters, clusterName)
+			} else {
+				rule.Clusters = append(rule.Clusters, clusterName)
+			}
+		}
+	}
+
+	report := make([]types.OrgReportRule, 0, len(rules))
+	for _, rule := range rules {
+		sortClusterNames(rule.Clusters)
+		sortClusterNames(rule.DisabledClusters)
+		report = append(report, *rule)
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].RuleID != report[j].RuleID {
+			return report[i].RuleID < report[j].RuleID
+		}
+		return report[i].ErrorKey < report[j].ErrorKey
+	})
+
+	return report, nil
+}
+
+// sortClusterNames sorts the cluster names in place
+func sortClusterNames(clusterNames []types.ClusterName) {
+	sort.Slice(clusterNames, func(i, j int) bool { return clusterNames[i] < clusterNames[j] })
+}
+
+// ReadVoteSummaryOnRuleForOrg returns the number of votes on the rule for
+// all clusters of the organization, a user voting for multiple clusters is
+// counted for every cluster
+func (storage *MemoryStorage) ReadVoteSummaryOnRuleForOrg(
+	orgID types.OrgID, ruleID types.RuleID, errorKey types.ErrorKey,
+) (types.VoteSummary, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	var summary types.VoteSummary
+
+	for key, feedback := range storage.feedback {
+		report, found := storage.reports[key.clusterID]
+		if !found || report.orgID != orgID || key.ruleID != ruleID || key.errorKey != errorKey {
+			continue
+		}
+
+		switch feedback.UserVote {
+		case types.UserVoteLike:
+			summary.Likes++
+		case types.UserVoteDislike:
+			summary.Dislikes++
+		}
+	}
+
+	return summary, nil
+}
+
+// VoteOnRuleForOrg likes or dislikes the rule for the organization by the
+// user
+func (storage *MemoryStorage) VoteOnRuleForOrg(
+	orgID types.OrgID,
+	ruleID types.RuleID,
+	errorKey types.ErrorKey,
+	userID types.UserID,
+	userVote types.UserVote,
+	voteMessage string,
+) error {
+	storage.addOrUpdateOrgFeedback(memoryOrgFeedbackKey{orgID, ruleID, errorKey, userID}, &userVote, voteMessage)
+	return nil
+}
+
+// AddOrUpdateFeedbackOnRuleForOrg adds or updates the message of the user on
+// the rule for the organization, the vote is kept
+func (storage *MemoryStorage) AddOrUpdateFeedbackOnRuleForOrg(
+	orgID types.OrgID,
+	ruleID types.RuleID,
+	errorKey types.ErrorKey,
+	userID types.UserID,
+	message string,
+) error {
+	storage.addOrUpdateOrgFeedback(memoryOrgFeedbackKey{orgID, ruleID, errorKey, userID}, nil, message)
+	return nil
+}
+
+// addOrUpdateOrgFeedback upserts the feedback on the rule for the
+// organization, the vote is updated only when userVote isn't nil
+func (storage *MemoryStorage) addOrUpdateOrgFeedback(
+	key memoryOrgFeedbackKey, userVote *types.UserVote, message string,
+) {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	now := time.Now()
+
+	feedback, found := storage.orgFeedback[key]
+	if !found {
+		feedback = OrgUserFeedbackOnRule{
+			OrgID:    key.orgID,
+			RuleID:   key.ruleID,
+			ErrorKey: key.errorKey,
+			UserID:   key.userID,
+			UserVote: types.UserVoteNone,
+			AddedAt:  now,
+		}
+	}
+
+	if userVote != nil {
+		feedback.UserVote = *userVote
+	}
+
+	feedback.Message = message
+	feedback.UpdatedAt = now
+	storage.orgFeedback[key] = feedback
+
+	metrics.FeedbackOnRules.Inc()
+}
+
+// GetUserFeedbackOnRuleForOrg returns the feedback of the user on the rule
+// for the organization
+func (storage *MemoryStorage) GetUserFeedbackOnRuleForOrg(
+	orgID types.OrgID, ruleID types.RuleID, errorKey types.ErrorKey, userID types.UserID,
+) (*OrgUserFeedbackOnRule, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	feedback, found := storage.orgFeedback[memoryOrgFeedbackKey{orgID, ruleID, errorKey, userID}]
+	if !found {
+		return nil, &types.ItemNotFoundError{
+			ItemID: fmt.Sprintf("%v/%v/%v/%v", orgID, ruleID, errorKey, userID),
+		}
+	}
+
+	return &feedback, nil
+}
+
+// GetFeedbackOnRuleForOrg returns feedback left by all users on the rule for
+// the organization, the most recently updated first
+func (storage *MemoryStorage) GetFeedbackOnRuleForOrg(
+	orgID types.OrgID, ruleID types.RuleID, errorKey types.ErrorKey,
+) ([]OrgUserFeedbackOnRule, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	feedbacks := make([]OrgUserFeedbackOnRule, 0)
+
+	for key, feedback := range storage.orgFeedback {
+		if key.orgID == orgID && key.ruleID == ruleID && key.errorKey == errorKey {
+			feedbacks = append(feedbacks, feedback)
+		}
+	}
+
+	sort.Slice(feedbacks, func(i, j int) bool {
+		if !feedbacks[i].UpdatedAt.Equal(feedbacks[j].UpdatedAt) {
+			return feedbacks[i].UpdatedAt.After(feedbacks[j].UpdatedAt)
+		}
+		return feedbacks[i].UserID < feedbacks[j].UserID
+	})
+
+	return feedbacks, nil
+}
@@ -0,0 +1,133 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// CleanupOldData deletes data according to the retention policy and returns
+// numbers of deleted records by table (report and consumer_error), records
+// related to deleted reports are not counted
+func (storage *MemoryStorage) CleanupOldData(policy RetentionPolicy) (map[string]int, error) {
+	pruned := make(map[string]int)
+
+	if !policy.ReportsNotCheckedSince.IsZero() {
+		deleted, err := storage.DeleteReportsNotCheckedSince(policy.ReportsNotCheckedSince)
+		if err != nil {
+			return pruned, err
+		}
+
+		pruned["report"] = deleted
+		metrics.PrunedRows.WithLabelValues("report").Add(float64(deleted))
+	}
+
+	if !policy.ConsumerErrorsConsumedBefore.IsZero() {
+		deleted := storage.deleteConsumerErrorsConsumedBefore(policy.ConsumerErrorsConsumedBefore)
+
+		pruned["consumer_error"] = deleted
+		metrics.PrunedRows.WithLabelValues("consumer_error").Add(float64(deleted))
+	}
+
+	return pruned, nil
+}
+
+// deleteConsumerErrorsConsumedBefore deletes consumer errors recorded before
+// the given time and returns their number
+func (storage *MemoryStorage) deleteConsumerErrorsConsumedBefore(threshold time.Time) int {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	kept := make([]memoryConsumerError, 0, len(storage.consumerErrors))
+	for _, consumerErr := range storage.consumerErrors {
+		if !consumerErr.consumedAt.Before(threshold) {
+			kept = append(kept, consumerErr)
+		}
+	}
+
+	deleted := len(storage.consumerErrors) - len(kept)
+	storage.consumerErrors = kept
+
+	return deleted
+}
+
+// DeleteReportsNotCheckedSince deletes reports of all clusters that were
+// last checked before the given time together with all data of the clusters
+// except rule toggles, reports of frozen organizations are kept. Number of
+// deleted reports is returned.
+func (storage *MemoryStorage) DeleteReportsNotCheckedSince(threshold time.Time) (int, error) {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	clusterNames := storage.agedOutClusters(threshold)
+
+	for _, clusterName := range clusterNames {
+		storage.deleteReport(clusterName)
+
+		delete(storage.ruleHitHistory, clusterName)
+		delete(storage.annotations, clusterName)
+		delete(storage.reportHistory, clusterName)
+
+		for key := range storage.staleWrites {
+			if key.clusterID == clusterName {
+				delete(storage.staleWrites, key)
+			}
+		}
+
+		for key := range storage.externalResults {
+			if key.clusterID == clusterName {
+				delete(storage.externalResults, key)
+			}
+		}
+
+		orgChanges := storage.orgChanges[:0]
+		for _, change := range storage.orgChanges {
+			if change.ClusterName != clusterName {
+				orgChanges = append(orgChanges, change)
+			}
+		}
+		storage.orgChanges = orgChanges
+	}
+
+	return len(clusterNames), nil
+}
+
+// CountClustersNotCheckedSince returns number of clusters that were last
+// checked before the given time, clusters of frozen organizations are not
+// counted
+func (storage *MemoryStorage) CountClustersNotCheckedSince(threshold time.Time) (int, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	return len(storage.agedOutClusters(threshold)), nil
+}
+
+// agedOutClusters returns clusters last checked before the threshold,
+// clusters of frozen organizations are never aged out. The mutex has to be
+// locked.
+func (storage *MemoryStorage) agedOutClusters(threshold time.Time) []types.ClusterName {
+	clusterNames := make([]types.ClusterName, 0)
+
+	for clusterName, report := range storage.reports {
+		if _, frozen := storage.frozenOrgs[report.orgID]; !frozen && report.lastChecked.Before(threshold) {
+			clusterNames = append(clusterNames, clusterName)
+		}
+	}
+
+	return clusterNames
+}
@@ -0,0 +1,254 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"sort"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// memoryRuleHitOccurrence is one row of the rule_hit_history table, zero
+// disappearedAt means the rule is still reported. Closed occurrences replace
+// the rule_hit_resolution table.
+type memoryRuleHitOccurrence struct {
+	orgID         types.OrgID
+	key           ruleHitKey
+	appearedAt    time.Time
+	disappearedAt time.Time
+}
+
+// updateRuleHitHistory closes occurrences of rules that are no longer
+// reported for the cluster in the organization and opens new occurrences for
+// rules that (re)appeared in the report, the mutex has to be locked
+func (storage *MemoryStorage) updateRuleHitHistory(
+	orgID types.OrgID, clusterName types.ClusterName, ruleHits []types.ReportItem, lastCheckedTime time.Time,
+) {
+	reported := make(map[ruleHitKey]bool)
+	for _, ruleHit := range ruleHits {
+		reported[ruleHitKey{ruleID: ruleHit.Module, errorKey: ruleHit.ErrorKey}] = true
+	}
+
+	occurrences := storage.ruleHitHistory[clusterName]
+	open := make(map[ruleHitKey]bool)
+
+	for i := range occurrences {
+		occurrence := &occurrences[i]
+		if occurrence.orgID != orgID || !occurrence.disappearedAt.IsZero() {
+			continue
+		}
+
+		if reported[occurrence.key] {
+			open[occurrence.key] = true
+		} else {
+			occurrence.disappearedAt = lastCheckedTime
+		}
+	}
+
+	for _, ruleHit := range ruleHits {
+		key := ruleHitKey{ruleID: ruleHit.Module, errorKey: ruleHit.ErrorKey}
+		if open[key] {
+			continue
+		}
+
+		occurrences = append(occurrences, memoryRuleHitOccurrence{
+			orgID:      orgID,
+			key:        key,
+			appearedAt: lastCheckedTime,
+		})
+		open[key] = true
+	}
+
+	storage.ruleHitHistory[clusterName] = occurrences
+}
+
+// ReadRuleHitOccurrences returns the timeline of periods during which the
+// rule was reported for the cluster, the oldest occurrence goes first
+func (storage *MemoryStorage) ReadRuleHitOccurrences(
+	clusterName types.ClusterName, ruleID types.RuleID, errorKey types.ErrorKey,
+) ([]types.RuleHitOccurrence, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	stored := make([]memoryRuleHitOccurrence, 0)
+	for _, occurrence := range storage.ruleHitHistory[clusterName] {
+		if occurrence.key.ruleID == ruleID && occurrence.key.errorKey == errorKey {
+			stored = append(stored, occurrence)
+		}
+	}
+
+	sort.SliceStable(stored, func(i, j int) bool { return stored[i].appearedAt.Before(stored[j].appearedAt) })
+
+	occurrences := make([]types.RuleHitOccurrence, 0, len(stored))
+	for _, occurrence := range stored {
+		occurrences = append(occurrences, types.RuleHitOccurrence{
+			AppearedAt:    memoryTimestamp(occurrence.appearedAt),
+			DisappearedAt: memoryTimestamp(occurrence.disappearedAt),
+		})
+	}
+
+	return occurrences, nil
+}
+
+// ReadRuleResolutionRates returns for every rule ever reported the number of
+// its hits, the number of its resolutions and their ratio, ordered by rule
+func (storage *MemoryStorage) ReadRuleResolutionRates() ([]types.RuleResolutionRate, error) {
+	return storage.readRuleResolutionRates(func(types.OrgID) bool { return true })
+}
+
+// ReadRuleResolutionRatesForOrg returns resolution rates of rules reported
+// for the clusters of the organization, ordered by rule
+func (storage *MemoryStorage) ReadRuleResolutionRatesForOrg(orgID types.OrgID) ([]types.RuleResolutionRate, error) {
+	return storage.readRuleResolutionRates(func(occurrenceOrgID types.OrgID) bool { return occurrenceOrgID == orgID })
+}
+
+// readRuleResolutionRates counts occurrences of rule hits of organizations
+// selected by the filter and their resolutions
+func (storage *MemoryStorage) readRuleResolutionRates(
+	orgFilter func(orgID types.OrgID) bool,
+) ([]types.RuleResolutionRate, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	counts := make(map[ruleHitKey]*types.RuleResolutionRate)
+
+	for _, occurrences := range storage.ruleHitHistory {
+		for _, occurrence := range occurrences {
+			if !orgFilter(occurrence.orgID) {
+				continue
+			}
+
+			rate, found := counts[occurrence.key]
+			if !found {
+				rate = &types.RuleResolutionRate{RuleID: occurrence.key.ruleID, ErrorKey: occurrence.key.errorKey}
+				counts[occurrence.key] = rate
+			}
+
+			rate.Hits++
+			if !occurrence.disappearedAt.IsZero() {
+				rate.Resolved++
+			}
+		}
+	}
+
+	rates := make([]types.RuleResolutionRate, 0, len(counts))
+	for _, rate := range counts {
+		rate.ResolutionRate = float64(rate.Resolved) / float64(rate.Hits)
+		rates = append(rates, *rate)
+	}
+
+	sort.Slice(rates, func(i, j int) bool {
+		if rates[i].RuleID != rates[j].RuleID {
+			return rates[i].RuleID < rates[j].RuleID
+		}
+		return rates[i].ErrorKey < rates[j].ErrorKey
+	})
+
+	return rates, nil
+}
+
+// ReadTopRules returns at most limit rules affecting the most clusters of
+// all organizations in the time window [from, to). Number of affected
+// clusters in the previous window of the same length is returned too.
+func (storage *MemoryStorage) ReadTopRules(from, to time.Time, limit int) ([]types.TopRule, error) {
+	return storage.readTopRules(from, to, limit, func(types.OrgID) bool { return true })
+}
+
+// ReadTopRulesForOrg returns at most limit rules affecting the most clusters
+// of the organization in the time window [from, to)
+func (storage *MemoryStorage) ReadTopRulesForOrg(
+	orgID types.OrgID, from, to time.Time, limit int,
+) ([]types.TopRule, error) {
+	return storage.readTopRules(from, to, limit, func(occurrenceOrgID types.OrgID) bool {
+		return occurrenceOrgID == orgID
+	})
+}
+
+// readTopRules counts clusters of organizations selected by the filter
+// affected by every rule in the time window and in the previous window, the
+// cluster is affected when an occurrence of the rule overlaps the window
+func (storage *MemoryStorage) readTopRules(
+	from, to time.Time, limit int, orgFilter func(orgID types.OrgID) bool,
+) ([]types.TopRule, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	previousFrom := from.Add(-to.Sub(from))
+
+	clusters := make(map[ruleHitKey]map[types.ClusterName]bool)
+	previousClusters := make(map[ruleHitKey]map[types.ClusterName]bool)
+
+	for clusterName, occurrences := range storage.ruleHitHistory {
+		for _, occurrence := range occurrences {
+			if !orgFilter(occurrence.orgID) {
+				continue
+			}
+
+			if occurrence.overlaps(from, to) {
+				addAffectedCluster(clusters, occurrence.key, clusterName)
+			}
+			if occurrence.overlaps(previousFrom, from) {
+				addAffectedCluster(previousClusters, occurrence.key, clusterName)
+			}
+		}
+	}
+
+	rules := make([]types.TopRule, 0, len(clusters))
+	for key, affected := range clusters {
+		rule := types.TopRule{
+			RuleID:           key.ruleID,
+			ErrorKey:         key.errorKey,
+			Clusters:         len(affected),
+			PreviousClusters: len(previousClusters[key]),
+		}
+		rule.Delta = rule.Clusters - rule.PreviousClusters
+
+		rules = append(rules, rule)
+	}
+
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Clusters != rules[j].Clusters {
+			return rules[i].Clusters > rules[j].Clusters
+		}
+		if rules[i].RuleID != rules[j].RuleID {
+			return rules[i].RuleID < rules[j].RuleID
+		}
+		return rules[i].ErrorKey < rules[j].ErrorKey
+	})
+
+	if limit >= 0 && len(rules) > limit {
+		rules = rules[:limit]
+	}
+
+	return rules, nil
+}
+
+// overlaps checks whether the occurrence overlaps the time window [from, to)
+func (occurrence memoryRuleHitOccurrence) overlaps(from, to time.Time) bool {
+	return occurrence.appearedAt.Before(to) &&
+		(occurrence.disappearedAt.IsZero() || occurrence.disappearedAt.After(from))
+}
+
+// addAffectedCluster adds the cluster to clusters affected by the rule
+func addAffectedCluster(
+	affected map[ruleHitKey]map[types.ClusterName]bool, key ruleHitKey, clusterName types.ClusterName,
+) {
+	if affected[key] == nil {
+		affected[key] = make(map[types.ClusterName]bool)
+	}
+
+	affected[key][clusterName] = true
+}
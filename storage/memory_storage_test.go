@@ -0,0 +1,816 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// runWithMemoryAndDBStorage runs the test against the in-memory storage and
+// the mock DB storage, so both are checked to behave the same way
+func runWithMemoryAndDBStorage(t *testing.T, test func(t *testing.T, mockStorage storage.Storage)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, storage.NewMemoryStorage())
+	})
+
+	t.Run("db", func(t *testing.T) {
+		mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+		defer closer()
+
+		test(t, mockStorage)
+	})
+}
+
+func TestMemoryStorage_WriteReportForCluster(t *testing.T) {
+	runWithMemoryAndDBStorage(t, func(t *testing.T, mockStorage storage.Storage) {
+		err := mockStorage.WriteReportForCluster(
+			testdata.OrgID, testdata.ClusterName, testdata.ClusterReport3Rules, testdata.Report3RulesParsed,
+			testdata.LastCheckedAt, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+
+		// the same report is rejected
+		err = mockStorage.WriteReportForCluster(
+			testdata.OrgID, testdata.ClusterName, testdata.ClusterReportEmpty, testdata.ReportEmptyRulesParsed,
+			testdata.LastCheckedAt, testdata.KafkaOffset,
+		)
+		assert.Equal(t, types.ErrOldReport, err)
+
+		rules, lastChecked, err := mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+		helpers.FailOnError(t, err)
+		assert.Len(t, rules, len(testdata.Report3RulesParsed))
+		assert.Equal(t, types.Timestamp(testdata.LastCheckedAt.UTC().Format(time.RFC3339)), lastChecked)
+
+		// more recent report replaces rule hits
+		err = mockStorage.WriteReportForCluster(
+			testdata.OrgID, testdata.ClusterName, testdata.ClusterReportEmpty, testdata.ReportEmptyRulesParsed,
+			testdata.LastCheckedAt.Add(time.Hour), testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+
+		rules, _, err = mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+		helpers.FailOnError(t, err)
+		assert.Empty(t, rules)
+
+		_, _, err = mockStorage.ReadReportForCluster(testdata.Org2ID, testdata.ClusterName)
+		assert.IsType(t, &types.ItemNotFoundError{}, err)
+	})
+}
+
+// TestMemoryStorage_ClusterMovedToAnotherOrg checks that the cluster reported
+// under another organization is moved there
+func TestMemoryStorage_ClusterMovedToAnotherOrg(t *testing.T) {
+	runWithMemoryAndDBStorage(t, func(t *testing.T, mockStorage storage.Storage) {
+		err := mockStorage.WriteReportForCluster(
+			testdata.OrgID, testdata.ClusterName, testdata.ClusterReport3Rules, testdata.Report3RulesParsed,
+			testdata.LastCheckedAt, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+
+		err = mockStorage.WriteReportForCluster(
+			testdata.Org2ID, testdata.ClusterName, testdata.ClusterReport3Rules, testdata.Report3RulesParsed,
+			testdata.LastCheckedAt.Add(time.Hour), testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+
+		orgID, err := mockStorage.GetOrgIDByClusterID(testdata.ClusterName)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, testdata.Org2ID, orgID)
+
+		clusters, err := mockStorage.ListOfClustersForOrg(testdata.OrgID, time.Time{}, 0, 0)
+		helpers.FailOnError(t, err)
+		assert.Empty(t, clusters)
+
+		clusters, err = mockStorage.ListOfClustersForOrg(testdata.Org2ID, time.Time{}, 0, 0)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, []types.ClusterName{testdata.ClusterName}, clusters)
+	})
+}
+
+func TestMemoryStorage_ListOfClustersForOrgPaging(t *testing.T) {
+	runWithMemoryAndDBStorage(t, func(t *testing.T, mockStorage storage.Storage) {
+		for i := 0; i < 3; i++ {
+			err := mockStorage.WriteReportForCluster(
+				testdata.OrgID, testdata.GetRandomClusterID(), testdata.ClusterReportEmpty,
+				testdata.ReportEmptyRulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+			)
+			helpers.FailOnError(t, err)
+		}
+
+		all, err := mockStorage.ListOfClustersForOrg(testdata.OrgID, time.Time{}, 0, 0)
+		helpers.FailOnError(t, err)
+		assert.Len(t, all, 3)
+
+		count, err := mockStorage.CountClustersForOrg(testdata.OrgID, time.Time{})
+		helpers.FailOnError(t, err)
+		assert.Equal(t, 3, count)
+
+		for _, testCase := range []struct {
+			offset, limit int
+			expected      []types.ClusterName
+		}{
+			{0, 2, all[:2]},
+			{2, 2, all[2:]},
+			{1, 0, all[1:]},
+			{3, 1, []types.ClusterName{}},
+		} {
+			clusters, err := mockStorage.ListOfClustersForOrg(
+				testdata.OrgID, time.Time{}, testCase.offset, testCase.limit,
+			)
+			helpers.FailOnError(t, err)
+			assert.Equal(t, testCase.expected, clusters, testCase)
+		}
+	})
+}
+
+func TestMemoryStorage_ToggleRuleForCluster(t *testing.T) {
+	runWithMemoryAndDBStorage(t, func(t *testing.T, mockStorage storage.Storage) {
+		_, err := mockStorage.GetFromClusterRuleToggle(testdata.ClusterName, testdata.Rule1ID)
+		assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+		err = mockStorage.ToggleRuleForCluster(
+			testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, storage.RuleToggleDisable,
+		)
+		helpers.FailOnError(t, err)
+
+		toggle, err := mockStorage.GetFromClusterRuleToggle(testdata.ClusterName, testdata.Rule1ID)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, storage.RuleToggleDisable, toggle.Disabled)
+		assert.True(t, toggle.DisabledAt.Valid)
+		assert.False(t, toggle.EnabledAt.Valid)
+
+		toggles, err := mockStorage.GetTogglesForRules(testdata.ClusterName, []types.RuleOnReport{
+			{Module: testdata.Rule1ID}, {Module: testdata.Rule2ID},
+		})
+		helpers.FailOnError(t, err)
+		assert.Equal(t, map[types.RuleID]bool{testdata.Rule1ID: true}, toggles)
+
+		err = mockStorage.ToggleRuleForCluster(
+			testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, storage.RuleToggleEnable,
+		)
+		helpers.FailOnError(t, err)
+
+		toggle, err = mockStorage.GetFromClusterRuleToggle(testdata.ClusterName, testdata.Rule1ID)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, storage.RuleToggleEnable, toggle.Disabled)
+		assert.False(t, toggle.DisabledAt.Valid)
+		assert.True(t, toggle.EnabledAt.Valid)
+
+		err = mockStorage.ToggleRuleForCluster(testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, -999)
+		assert.EqualError(t, err, "Unexpected rule toggle value")
+
+		helpers.FailOnError(t, mockStorage.DeleteFromRuleClusterToggle(testdata.ClusterName, testdata.Rule1ID))
+
+		_, err = mockStorage.GetFromClusterRuleToggle(testdata.ClusterName, testdata.Rule1ID)
+		assert.IsType(t, &types.ItemNotFoundError{}, err)
+	})
+}
+
+// TestMemoryStorage_VoteOnRule checks that the feedback can be left only on
+// existing clusters and that it is deleted together with the report
+func TestMemoryStorage_VoteOnRule(t *testing.T) {
+	runWithMemoryAndDBStorage(t, func(t *testing.T, mockStorage storage.Storage) {
+		err := mockStorage.VoteOnRule(
+			testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID, types.UserVoteLike, "",
+		)
+		assert.IsType(t, &types.ForeignKeyError{}, err)
+
+		err = mockStorage.WriteReportForCluster(
+			testdata.OrgID, testdata.ClusterName, testdata.ClusterReport3Rules, testdata.Report3RulesParsed,
+			testdata.LastCheckedAt, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+
+		err = mockStorage.VoteOnRule(
+			testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID, types.UserVoteLike, "",
+		)
+		helpers.FailOnError(t, err)
+
+		// the vote is kept when only the message is updated
+		err = mockStorage.AddOrUpdateFeedbackOnRule(
+			testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID, "message",
+		)
+		helpers.FailOnError(t, err)
+
+		feedback, err := mockStorage.GetUserFeedbackOnRule(
+			testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID,
+		)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, types.UserVoteLike, feedback.UserVote)
+		assert.Equal(t, "message", feedback.Message)
+
+		summary, err := mockStorage.ReadVoteSummaryOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, types.VoteSummary{Likes: 1}, summary)
+
+		helpers.FailOnError(t, mockStorage.DeleteReportsForCluster(testdata.ClusterName))
+
+		_, err = mockStorage.GetUserFeedbackOnRule(
+			testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID,
+		)
+		assert.IsType(t, &types.ItemNotFoundError{}, err)
+	})
+}
+
+// TestMemoryStorage_ReadRuleHitsFirstSeen checks that rules reported again
+// keep the time they were reported for the first time
+func TestMemoryStorage_ReadRuleHitsFirstSeen(t *testing.T) {
+	runWithMemoryAndDBStorage(t, func(t *testing.T, mockStorage storage.Storage) {
+		rule1 := types.ReportItem{Module: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1, TemplateData: []byte("{}")}
+		rule2 := types.ReportItem{Module: testdata.Rule2ID, ErrorKey: testdata.ErrorKey2, TemplateData: []byte("{}")}
+
+		firstCheck := testdata.LastCheckedAt
+		secondCheck := firstCheck.Add(time.Hour)
+		thirdCheck := firstCheck.Add(2 * time.Hour)
+
+		for _, report := range []struct {
+			rules         []types.ReportItem
+			lastCheckedAt time.Time
+		}{
+			{[]types.ReportItem{rule1}, firstCheck},
+			{[]types.ReportItem{}, secondCheck},
+			{[]types.ReportItem{rule1, rule2}, thirdCheck},
+		} {
+			err := mockStorage.WriteReportForCluster(
+				testdata.OrgID, testdata.ClusterName, testdata.ClusterReportEmpty, report.rules,
+				report.lastCheckedAt, testdata.KafkaOffset,
+			)
+			helpers.FailOnError(t, err)
+		}
+
+		firstSeen, err := mockStorage.ReadRuleHitsFirstSeen(testdata.OrgID, testdata.ClusterName)
+		helpers.FailOnError(t, err)
+		assert.Len(t, firstSeen, 2)
+
+		for _, record := range firstSeen {
+			switch record.RuleID {
+			case testdata.Rule1ID:
+				assert.True(t, firstCheck.Equal(record.FirstSeenAt), record.FirstSeenAt)
+			case testdata.Rule2ID:
+				assert.True(t, thirdCheck.Equal(record.FirstSeenAt), record.FirstSeenAt)
+			}
+		}
+	})
+}
+
+// TestMemoryStorage_WriteFailedReportForCluster checks that the failed
+// analysis keeps rule hits of the last successful one
+func TestMemoryStorage_WriteFailedReportForCluster(t *testing.T) {
+	runWithMemoryAndDBStorage(t, func(t *testing.T, mockStorage storage.Storage) {
+		_, err := mockStorage.ReadReportStatusForCluster(testdata.OrgID, testdata.ClusterName)
+		assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+		err = mockStorage.WriteReportForCluster(
+			testdata.OrgID, testdata.ClusterName, testdata.ClusterReport3Rules, testdata.Report3RulesParsed,
+			testdata.LastCheckedAt, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+
+		status, err := mockStorage.ReadReportStatusForCluster(testdata.OrgID, testdata.ClusterName)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, types.ReportStatusAnalyzed, status)
+
+		err = mockStorage.WriteFailedReportForCluster(
+			testdata.OrgID, testdata.ClusterName, testdata.LastCheckedAt, testdata.KafkaOffset,
+		)
+		assert.Equal(t, types.ErrOldReport, err)
+
+		err = mockStorage.WriteFailedReportForCluster(
+			testdata.OrgID, testdata.ClusterName, testdata.LastCheckedAt.Add(time.Hour), testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+
+		status, err = mockStorage.ReadReportStatusForCluster(testdata.OrgID, testdata.ClusterName)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, types.ReportStatusFailed, status)
+
+		rules, _, err := mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+		helpers.FailOnError(t, err)
+		assert.Len(t, rules, len(testdata.Report3RulesParsed))
+	})
+}
+
+func TestMemoryStorage_KafkaOffsets(t *testing.T) {
+	runWithMemoryAndDBStorage(t, func(t *testing.T, mockStorage storage.Storage) {
+		offset, err := mockStorage.GetLatestKafkaOffset("topic", 0)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, types.KafkaOffset(0), offset)
+
+		helpers.FailOnError(t, mockStorage.WriteKafkaOffset("topic", 1, 5))
+		helpers.FailOnError(t, mockStorage.WriteKafkaOffset("topic", 0, 1))
+		helpers.FailOnError(t, mockStorage.WriteKafkaOffset("topic", 0, 2))
+
+		offset, err = mockStorage.GetLatestKafkaOffset("topic", 0)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, types.KafkaOffset(2), offset)
+
+		offsets, err := mockStorage.GetLatestKafkaOffsets()
+		helpers.FailOnError(t, err)
+		assert.Len(t, offsets, 2)
+		assert.Equal(t, int32(0), offsets[0].Partition)
+		assert.Equal(t, types.KafkaOffset(2), offsets[0].Offset)
+		assert.Equal(t, int32(1), offsets[1].Partition)
+		assert.Equal(t, types.KafkaOffset(5), offsets[1].Offset)
+	})
+}
+
+func TestMemoryStorage_FreezeOrg(t *testing.T) {
+	runWithMemoryAndDBStorage(t, func(t *testing.T, mockStorage storage.Storage) {
+		helpers.FailOnError(t, mockStorage.FreezeOrg(testdata.OrgID, "legal hold"))
+		helpers.FailOnError(t, mockStorage.FreezeOrg(testdata.OrgID, "abuse investigation"))
+
+		frozen, err := mockStorage.IsOrgFrozen(testdata.OrgID)
+		helpers.FailOnError(t, err)
+		assert.True(t, frozen)
+
+		frozenOrgs, err := mockStorage.ReadFrozenOrgs()
+		helpers.FailOnError(t, err)
+		assert.Len(t, frozenOrgs, 1)
+		assert.Equal(t, "abuse investigation", frozenOrgs[0].Reason)
+
+		helpers.FailOnError(t, mockStorage.UnfreezeOrg(testdata.OrgID))
+
+		frozen, err = mockStorage.IsOrgFrozen(testdata.OrgID)
+		helpers.FailOnError(t, err)
+		assert.False(t, frozen)
+
+		err = mockStorage.UnfreezeOrg(testdata.OrgID)
+		assert.Equal(t, &types.ItemNotFoundError{ItemID: testdata.OrgID}, err)
+	})
+}
+
+func TestMemoryStorage_OrgUsage(t *testing.T) {
+	runWithMemoryAndDBStorage(t, func(t *testing.T, mockStorage storage.Storage) {
+		month := storage.UsageMonth(time.Now())
+
+		helpers.FailOnError(t, mockStorage.AddOrgUsage(testdata.Org2ID, 0, 0, 1))
+		helpers.FailOnError(t, mockStorage.AddOrgUsage(testdata.OrgID, 1, 1024, 0))
+		helpers.FailOnError(t, mockStorage.AddOrgUsage(testdata.OrgID, 1, 2048, 3))
+
+		usages, err := mockStorage.ReadOrgUsage(month)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, []types.OrgUsage{
+			{OrgID: testdata.OrgID, Month: month, MessagesProcessed: 2, BytesStored: 3072, APICalls: 3},
+			{OrgID: testdata.Org2ID, Month: month, MessagesProcessed: 0, BytesStored: 0, APICalls: 1},
+		}, usages)
+
+		usages, err = mockStorage.ReadOrgUsage("2000-01")
+		helpers.FailOnError(t, err)
+		assert.Empty(t, usages)
+	})
+}
+
+func TestMemoryStorage_APIKeys(t *testing.T) {
+	runWithMemoryAndDBStorage(t, func(t *testing.T, mockStorage storage.Storage) {
+		expiresAt := time.Now().Add(time.Hour)
+
+		helpers.FailOnError(t, mockStorage.CreateAPIKey("key1", "exporter", []string{"read"}, "hash1", expiresAt))
+		helpers.FailOnError(t, mockStorage.CreateAPIKey("key2", "admin", []string{"read", "admin"}, "hash2", time.Time{}))
+
+		key, keyHash, err := mockStorage.ReadAPIKey("key2")
+		helpers.FailOnError(t, err)
+		assert.Equal(t, "hash2", keyHash)
+		assert.Equal(t, []string{"read", "admin"}, key.Scopes)
+		assert.Empty(t, key.ExpiresAt)
+
+		helpers.FailOnError(t, mockStorage.RotateAPIKey("key1", "rotated"))
+		helpers.FailOnError(t, mockStorage.RevokeAPIKey("key2"))
+
+		// revoked keys can't be rotated or revoked again
+		assert.IsType(t, &types.ItemNotFoundError{}, mockStorage.RotateAPIKey("key2", "hash"))
+		assert.IsType(t, &types.ItemNotFoundError{}, mockStorage.RevokeAPIKey("key2"))
+		assert.IsType(t, &types.ItemNotFoundError{}, mockStorage.RevokeAPIKey("unknown"))
+
+		key, keyHash, err = mockStorage.ReadAPIKey("key1")
+		helpers.FailOnError(t, err)
+		assert.Equal(t, "rotated", keyHash)
+		assert.NotEmpty(t, key.RotatedAt)
+		assert.Equal(t, types.Timestamp(expiresAt.UTC().Format(time.RFC3339)), key.ExpiresAt)
+
+		keys, err := mockStorage.ReadAPIKeys()
+		helpers.FailOnError(t, err)
+		assert.Len(t, keys, 2)
+		assert.Equal(t, "key1", keys[0].KeyID)
+		assert.NotEmpty(t, keys[1].RevokedAt)
+
+		_, _, err = mockStorage.ReadAPIKey("unknown")
+		assert.IsType(t, &types.ItemNotFoundError{}, err)
+	})
+}
+
+func TestMemoryStorage_ClusterAnnotations(t *testing.T) {
+	runWithMemoryAndDBStorage(t, func(t *testing.T, mockStorage storage.Storage) {
+		_, err := mockStorage.AddClusterAnnotation(testdata.ClusterName, testdata.UserID, "upgrade planned")
+		assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+		err = mockStorage.WriteReportForCluster(
+			testdata.OrgID, testdata.ClusterName, testdata.ClusterReportEmpty, testdata.ReportEmptyRulesParsed,
+			testdata.LastCheckedAt, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+
+		annotation, err := mockStorage.AddClusterAnnotation(testdata.ClusterName, testdata.UserID, "upgrade planned")
+		helpers.FailOnError(t, err)
+
+		annotations, err := mockStorage.ReadClusterAnnotations(testdata.ClusterName)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, []types.ClusterAnnotation{annotation}, annotations)
+
+		helpers.FailOnError(t, mockStorage.DeleteClusterAnnotation(testdata.ClusterName, annotation.ID))
+
+		err = mockStorage.DeleteClusterAnnotation(testdata.ClusterName, annotation.ID)
+		assert.Equal(t, &types.ItemNotFoundError{ItemID: annotation.ID}, err)
+
+		annotations, err = mockStorage.ReadClusterAnnotations(testdata.ClusterName)
+		helpers.FailOnError(t, err)
+		assert.Empty(t, annotations)
+	})
+}
+
+// TestMemoryStorage_OrgData checks data merged from all clusters of the
+// organization
+func TestMemoryStorage_OrgData(t *testing.T) {
+	runWithMemoryAndDBStorage(t, func(t *testing.T, mockStorage storage.Storage) {
+		cluster1, cluster2 := types.ClusterName("cluster-1"), types.ClusterName("cluster-2")
+
+		_, err := mockStorage.ReadOrgInfo(testdata.OrgID)
+		assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+		err = mockStorage.WriteReportForCluster(
+			testdata.OrgID, cluster1, testdata.ClusterReport3Rules, testdata.Report3RulesParsed,
+			testdata.LastCheckedAt, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+
+		err = mockStorage.WriteReportForCluster(
+			testdata.OrgID, cluster2, testdata.ClusterReport3Rules, testdata.Report2RulesParsed,
+			testdata.LastCheckedAt.Add(time.Hour), testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+
+		info, err := mockStorage.ReadOrgInfo(testdata.OrgID)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, testdata.OrgID, info.OrgID)
+		assert.NotEmpty(t, info.FirstSeenAt)
+
+		orgs, err := mockStorage.ListOfOrgsWithSummary()
+		helpers.FailOnError(t, err)
+		assert.Equal(t, []types.OrgSummary{{
+			OrgID:         testdata.OrgID,
+			ClusterCount:  2,
+			LastCheckedAt: types.Timestamp(testdata.LastCheckedAt.Add(time.Hour).UTC().Format(time.RFC3339)),
+		}}, orgs)
+
+		err = mockStorage.ToggleRuleForCluster(cluster1, testdata.Rule1ID, testdata.ErrorKey1, storage.RuleToggleDisable)
+		helpers.FailOnError(t, err)
+		err = mockStorage.ToggleRuleForCluster(cluster2, testdata.Rule1ID, testdata.ErrorKey1, storage.RuleToggleDisable)
+		helpers.FailOnError(t, err)
+		err = mockStorage.ToggleRuleForCluster(cluster2, testdata.Rule2ID, testdata.ErrorKey2, storage.RuleToggleDisable)
+		helpers.FailOnError(t, err)
+
+		report, err := mockStorage.ReadOrgReport(testdata.OrgID)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, []types.OrgReportRule{
+			{
+				RuleID: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1,
+				Clusters: []types.ClusterName{}, DisabledClusters: []types.ClusterName{cluster1, cluster2},
+			},
+			{
+				RuleID: testdata.Rule2ID, ErrorKey: testdata.ErrorKey2,
+				Clusters: []types.ClusterName{cluster1}, DisabledClusters: []types.ClusterName{cluster2},
+			},
+			{
+				RuleID: testdata.Rule3ID, ErrorKey: testdata.ErrorKey3,
+				Clusters: []types.ClusterName{cluster1}, DisabledClusters: []types.ClusterName{},
+			},
+		}, report)
+
+		disables, err := mockStorage.ReadOrgRuleDisables(testdata.OrgID, 1)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, types.OrgRuleDisables{
+			ClusterLevel: 3,
+			OrgLevel:     1,
+			MostDisabled: []types.DisabledRuleCount{
+				{RuleID: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1, Clusters: 2},
+			},
+		}, disables)
+
+		for _, clusterName := range []types.ClusterName{cluster1, cluster2} {
+			err = mockStorage.VoteOnRule(
+				clusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID, types.UserVoteDislike, "",
+			)
+			helpers.FailOnError(t, err)
+		}
+
+		summary, err := mockStorage.ReadVoteSummaryOnRuleForOrg(testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, types.VoteSummary{Dislikes: 2}, summary)
+
+		helpers.FailOnError(t, mockStorage.DeleteReportsForOrg(testdata.OrgID))
+
+		_, err = mockStorage.ReadOrgInfo(testdata.OrgID)
+		assert.IsType(t, &types.ItemNotFoundError{}, err)
+	})
+}
+
+func TestMemoryStorage_FeedbackOnRuleForOrg(t *testing.T) {
+	runWithMemoryAndDBStorage(t, func(t *testing.T, mockStorage storage.Storage) {
+		err := mockStorage.VoteOnRuleForOrg(
+			testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID, types.UserVoteLike, "",
+		)
+		helpers.FailOnError(t, err)
+
+		// the vote is kept when only the message is updated
+		err = mockStorage.AddOrUpdateFeedbackOnRuleForOrg(
+			testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID, "message",
+		)
+		helpers.FailOnError(t, err)
+
+		feedback, err := mockStorage.GetUserFeedbackOnRuleForOrg(
+			testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID,
+		)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, types.UserVoteLike, feedback.UserVote)
+		assert.Equal(t, "message", feedback.Message)
+
+		feedbacks, err := mockStorage.GetFeedbackOnRuleForOrg(testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1)
+		helpers.FailOnError(t, err)
+		assert.Len(t, feedbacks, 1)
+
+		_, err = mockStorage.GetUserFeedbackOnRuleForOrg(
+			testdata.Org2ID, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID,
+		)
+		assert.IsType(t, &types.ItemNotFoundError{}, err)
+	})
+}
+
+// TestMemoryStorage_RuleHitHistory checks the timeline of rule hits and the
+// statistics computed from it
+func TestMemoryStorage_RuleHitHistory(t *testing.T) {
+	runWithMemoryAndDBStorage(t, func(t *testing.T, mockStorage storage.Storage) {
+		err := mockStorage.WriteReportForCluster(
+			testdata.OrgID, testdata.ClusterName, testdata.ClusterReport3Rules, testdata.Report3RulesParsed,
+			testdata.LastCheckedAt, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+
+		err = mockStorage.WriteReportForCluster(
+			testdata.OrgID, testdata.ClusterName, testdata.ClusterReport3Rules, testdata.Report2RulesParsed,
+			testdata.LastCheckedAt.Add(time.Hour), testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+
+		occurrences, err := mockStorage.ReadRuleHitOccurrences(testdata.ClusterName, testdata.Rule3ID, testdata.ErrorKey3)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, []types.RuleHitOccurrence{{
+			AppearedAt:    types.Timestamp(testdata.LastCheckedAt.UTC().Format(time.RFC3339)),
+			DisappearedAt: types.Timestamp(testdata.LastCheckedAt.Add(time.Hour).UTC().Format(time.RFC3339)),
+		}}, occurrences)
+
+		rates, err := mockStorage.ReadRuleResolutionRatesForOrg(testdata.OrgID)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, []types.RuleResolutionRate{
+			{RuleID: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1, Hits: 1},
+			{RuleID: testdata.Rule2ID, ErrorKey: testdata.ErrorKey2, Hits: 1},
+			{RuleID: testdata.Rule3ID, ErrorKey: testdata.ErrorKey3, Hits: 1, Resolved: 1, ResolutionRate: 1},
+		}, rates)
+
+		// only the rules still reported affect the cluster in the window
+		topRules, err := mockStorage.ReadTopRules(
+			testdata.LastCheckedAt.Add(2*time.Hour), testdata.LastCheckedAt.Add(3*time.Hour), 10,
+		)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, []types.TopRule{
+			{RuleID: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1, Clusters: 1, PreviousClusters: 1},
+			{RuleID: testdata.Rule2ID, ErrorKey: testdata.ErrorKey2, Clusters: 1, PreviousClusters: 1},
+		}, topRules)
+	})
+}
+
+// TestMemoryStorage_ClusterData checks data of the cluster stored next to
+// its report
+func TestMemoryStorage_ClusterData(t *testing.T) {
+	runWithMemoryAndDBStorage(t, func(t *testing.T, mockStorage storage.Storage) {
+		lastChecked := testdata.LastCheckedAt
+
+		err := mockStorage.WriteExternalResults(
+			testdata.OrgID, testdata.ClusterName, "scanner", []types.ExternalResult{
+				{CheckID: "b", Severity: 1, Description: "low"},
+				{CheckID: "a", Severity: 3, Description: "high"},
+			}, lastChecked, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+
+		err = mockStorage.WriteExternalResults(
+			testdata.OrgID, testdata.ClusterName, "scanner", nil, lastChecked.Add(-time.Hour), testdata.KafkaOffset,
+		)
+		assert.Equal(t, types.ErrOldReport, err)
+
+		results, err := mockStorage.ReadExternalResults(testdata.ClusterName, "")
+		helpers.FailOnError(t, err)
+		assert.Len(t, results, 2)
+		assert.Equal(t, "a", results[0].CheckID)
+		assert.Equal(t, "scanner", results[0].Source)
+		assert.Equal(t, types.Timestamp(lastChecked.UTC().Format(time.RFC3339)), results[0].LastCheckedAt)
+
+		producedAt := lastChecked.Add(-time.Minute)
+		helpers.FailOnError(t, mockStorage.WriteStaleReport(testdata.OrgID, testdata.ClusterName, lastChecked, time.Time{}))
+		helpers.FailOnError(t, mockStorage.WriteStaleReport(testdata.OrgID, testdata.ClusterName, lastChecked, producedAt))
+
+		staleWrites, err := mockStorage.ReadStaleReportWrites()
+		helpers.FailOnError(t, err)
+		assert.Len(t, staleWrites, 1)
+		assert.Equal(t, 2, staleWrites[0].RejectedCount)
+		assert.Equal(t, 60.0, *staleWrites[0].TimestampSkewSeconds)
+
+		for i := 0; i < 3; i++ {
+			err = mockStorage.WriteReportHistory(
+				testdata.OrgID, testdata.ClusterName, testdata.ClusterReportEmpty,
+				lastChecked.Add(time.Duration(i)*time.Hour), testdata.KafkaOffset,
+			)
+			helpers.FailOnError(t, err)
+		}
+
+		history, err := mockStorage.ReadReportHistoryForCluster(
+			testdata.OrgID, testdata.ClusterName, lastChecked.Add(time.Hour), time.Time{},
+		)
+		helpers.FailOnError(t, err)
+		assert.Len(t, history, 2)
+		assert.Equal(t, types.Timestamp(lastChecked.Add(time.Hour).UTC().Format(time.RFC3339)), history[0].LastCheckedAt)
+
+		checks, err := mockStorage.ReadReportChecks(testdata.ClusterName, 0)
+		helpers.FailOnError(t, err)
+		assert.Empty(t, checks)
+
+		err = mockStorage.WriteReportForCluster(
+			testdata.OrgID, testdata.ClusterName, testdata.ClusterReportEmpty, testdata.ReportEmptyRulesParsed,
+			lastChecked, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+
+		err = mockStorage.WriteReportForCluster(
+			testdata.Org2ID, testdata.ClusterName, testdata.ClusterReportEmpty, testdata.ReportEmptyRulesParsed,
+			lastChecked.Add(time.Hour), testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+
+		changes, err := mockStorage.ReadClusterOrgChanges(lastChecked)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, []types.ClusterOrgChange{{
+			ClusterName:   testdata.ClusterName,
+			PreviousOrgID: testdata.OrgID,
+			OrgID:         testdata.Org2ID,
+			ChangedAt:     types.Timestamp(lastChecked.Add(time.Hour).UTC().Format(time.RFC3339)),
+			Resolution:    storage.ClusterOrgConflictMove,
+		}}, changes)
+	})
+}
+
+// TestMemoryStorage_MessageKeys checks lookup of reports and consumer errors
+// by the key of the Kafka message
+func TestMemoryStorage_MessageKeys(t *testing.T) {
+	runWithMemoryAndDBStorage(t, func(t *testing.T, mockStorage storage.Storage) {
+		const key = "request-id"
+
+		err := mockStorage.WriteReportForCluster(
+			testdata.OrgID, testdata.ClusterName, testdata.ClusterReportEmpty, testdata.ReportEmptyRulesParsed,
+			testdata.LastCheckedAt, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+		helpers.FailOnError(t, mockStorage.WriteReportMessageKey(
+			testdata.OrgID, testdata.ClusterName, testdata.LastCheckedAt, key,
+		))
+
+		err = mockStorage.WriteConsumerError(&sarama.ConsumerMessage{
+			Topic:     "topic",
+			Partition: 1,
+			Offset:    10,
+			Key:       []byte(key),
+			Value:     []byte("not a report"),
+			Timestamp: testdata.LastCheckedAt,
+		}, errors.New("unable to parse the message"))
+		helpers.FailOnError(t, err)
+
+		lookup, err := mockStorage.LookupMessageKey(key)
+		helpers.FailOnError(t, err)
+		assert.Len(t, lookup.Reports, 1)
+		assert.Equal(t, testdata.ClusterName, lookup.Reports[0].ClusterName)
+		assert.Equal(t, types.ReportStatusAnalyzed, lookup.Reports[0].Status)
+		assert.Len(t, lookup.ConsumerErrors, 1)
+		assert.Equal(t, "unable to parse the message", lookup.ConsumerErrors[0].Error)
+
+		pruned, err := mockStorage.CleanupOldData(storage.RetentionPolicy{
+			ConsumerErrorsConsumedBefore: time.Now().UTC().Add(time.Hour),
+		})
+		helpers.FailOnError(t, err)
+		assert.Equal(t, map[string]int{"consumer_error": 1}, pruned)
+
+		lookup, err = mockStorage.LookupMessageKey(key)
+		helpers.FailOnError(t, err)
+		assert.Empty(t, lookup.ConsumerErrors)
+	})
+}
+
+// TestMemoryStorage_ExportAndRetention checks iteration over all reports and
+// rule hits and that reports of frozen organizations are not aged out
+func TestMemoryStorage_ExportAndRetention(t *testing.T) {
+	runWithMemoryAndDBStorage(t, func(t *testing.T, mockStorage storage.Storage) {
+		cluster1, cluster2, cluster3 := types.ClusterName("cluster-1"), types.ClusterName("cluster-2"), types.ClusterName("cluster-3")
+
+		for _, report := range []struct {
+			orgID       types.OrgID
+			clusterName types.ClusterName
+			lastChecked time.Time
+		}{
+			{testdata.OrgID, cluster1, testdata.LastCheckedAt},
+			{testdata.Org2ID, cluster2, testdata.LastCheckedAt},
+			{testdata.OrgID, cluster3, testdata.LastCheckedAt.Add(2 * time.Hour)},
+		} {
+			err := mockStorage.WriteReportForCluster(
+				report.orgID, report.clusterName, testdata.ClusterReport3Rules, testdata.Report3RulesParsed,
+				report.lastChecked, testdata.KafkaOffset,
+			)
+			helpers.FailOnError(t, err)
+		}
+
+		ruleHits, err := mockStorage.ReadRuleHitsForCluster(cluster1)
+		helpers.FailOnError(t, err)
+		assert.Len(t, ruleHits, len(testdata.Report3RulesParsed))
+
+		var forRule []types.ClusterName
+		err = mockStorage.IterateRuleHitsForRule(
+			testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1, 1, 0, func(record storage.RuleHitRecord) error {
+				forRule = append(forRule, record.ClusterName)
+				return nil
+			},
+		)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, []types.ClusterName{cluster3}, forRule)
+
+		helpers.FailOnError(t, mockStorage.FreezeOrg(testdata.Org2ID, "legal hold"))
+
+		threshold := testdata.LastCheckedAt.Add(time.Hour)
+
+		count, err := mockStorage.CountClustersNotCheckedSince(threshold)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, 1, count)
+
+		deleted, err := mockStorage.DeleteReportsNotCheckedSince(threshold)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, 1, deleted)
+
+		var reports []types.ClusterName
+		err = mockStorage.IterateReports(func(record storage.ReportRecord) error {
+			reports = append(reports, record.ClusterName)
+			return nil
+		})
+		helpers.FailOnError(t, err)
+		assert.Equal(t, []types.ClusterName{cluster3, cluster2}, reports)
+
+		ruleHitsCount := 0
+		err = mockStorage.IterateRuleHits(func(storage.RuleHitRecord) error {
+			ruleHitsCount++
+			return nil
+		})
+		helpers.FailOnError(t, err)
+		assert.Equal(t, 2*len(testdata.Report3RulesParsed), ruleHitsCount)
+	})
+}
+
+// TestMemoryStorage_NotSupported checks that operations tied to the
+// database fail instead of silently doing nothing
+func TestMemoryStorage_NotSupported(t *testing.T) {
+	memoryStorage := storage.NewMemoryStorage()
+
+	_, err := memoryStorage.ReadDBSchema()
+	assert.True(t, errors.Is(err, storage.ErrNotSupported), err)
+
+	_, err = memoryStorage.ArchiveReportsNotCheckedSince(time.Now(), 1)
+	assert.True(t, errors.Is(err, storage.ErrNotSupported), err)
+
+	_, err = memoryStorage.RecomputeAggregates()
+	assert.True(t, errors.Is(err, storage.ErrNotSupported), err)
+}
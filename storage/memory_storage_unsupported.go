@@ -0,0 +1,63 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// ErrNotSupported is returned by operations of MemoryStorage tied to the
+// database itself, they have no counterpart in memory
+var ErrNotSupported = errors.New("operation is not supported by the in-memory storage")
+
+// notSupported returns ErrNotSupported together with the name of the
+// operation
+func notSupported(operation string) error {
+	return fmt.Errorf("%v: %w", operation, ErrNotSupported)
+}
+
+// RebuildClustersLastCheckedCache is not supported by the in-memory storage
+func (*MemoryStorage) RebuildClustersLastCheckedCache() (int, error) {
+	return 0, notSupported("RebuildClustersLastCheckedCache")
+}
+
+// RecomputeAggregates is not supported by the in-memory storage
+func (*MemoryStorage) RecomputeAggregates() (AggregatesRecomputation, error) {
+	return AggregatesRecomputation{}, notSupported("RecomputeAggregates")
+}
+
+// ReadDBSchema is not supported by the in-memory storage
+func (*MemoryStorage) ReadDBSchema() (types.DBSchema, error) {
+	return types.DBSchema{}, notSupported("ReadDBSchema")
+}
+
+// ArchiveReportsNotCheckedSince is not supported by the in-memory storage
+func (*MemoryStorage) ArchiveReportsNotCheckedSince(time.Time, int) (int, error) {
+	return 0, notSupported("ArchiveReportsNotCheckedSince")
+}
+
+// GetClustersLastCheckedCacheStats is not supported by the in-memory storage
+func (*MemoryStorage) GetClustersLastCheckedCacheStats() (ClustersLastCheckedCacheStats, error) {
+	return ClustersLastCheckedCacheStats{}, notSupported("GetClustersLastCheckedCacheStats")
+}
+
+// CheckClustersLastCheckedDivergence is not supported by the in-memory storage
+func (*MemoryStorage) CheckClustersLastCheckedDivergence(int) (ClustersLastCheckedDivergence, error) {
+	return ClustersLastCheckedDivergence{}, notSupported("CheckClustersLastCheckedDivergence")
+}
@@ -0,0 +1,173 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// messageKey converts the key of the Kafka message to the value stored in
+// the database. Keys are stored as text, so they can be looked up by the
+// string the platform gateway logs, missing key is stored as NULL.
+func messageKey(key []byte) sql.NullString {
+	if len(key) == 0 {
+		return sql.NullString{}
+	}
+
+	return sql.NullString{String: string(key), Valid: true}
+}
+
+// WriteReportMessageKey stores the key of the Kafka message the report of
+// the cluster was consumed from. The key is not stored when more recent
+// report of the cluster than the one checked at lastCheckedTime is already
+// stored. Nothing is stored before the database is migrated.
+func (storage DBStorage) WriteReportMessageKey(
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+	lastCheckedTime time.Time,
+	key string,
+) error {
+	if !storage.schemaAtLeast(reportMessageKeyVersion) {
+		return nil
+	}
+
+	ctx, cancel := storage.operationContext(writeOperation)
+	defer cancel()
+
+	_, err := storage.connection.ExecContext(ctx, `
+		UPDATE report SET message_key = $1
+		WHERE org_id = $2 AND cluster = $3 AND last_checked_at <= $4;`,
+		messageKey([]byte(key)), orgID, clusterName, lastCheckedTime,
+	)
+
+	return types.ConvertDBError(err, []interface{}{orgID, clusterName})
+}
+
+// LookupMessageKey returns reports and consumer errors stored for the Kafka
+// message key. Only consumer errors are returned before the database is
+// migrated.
+func (storage DBStorage) LookupMessageKey(key string) (types.MessageKeyLookup, error) {
+	lookup := types.MessageKeyLookup{
+		Key:            key,
+		Reports:        make([]types.MessageKeyReport, 0),
+		ConsumerErrors: make([]types.MessageKeyConsumerErr, 0),
+	}
+
+	if storage.schemaAtLeast(reportMessageKeyVersion) {
+		reports, err := storage.lookupMessageKeyReports(key)
+		if err != nil {
+			return lookup, err
+		}
+		lookup.Reports = reports
+	}
+
+	consumerErrors, err := storage.lookupMessageKeyConsumerErrors(key)
+	if err != nil {
+		return lookup, err
+	}
+	lookup.ConsumerErrors = consumerErrors
+
+	return lookup, nil
+}
+
+// lookupMessageKeyReports returns reports consumed from messages with the key
+func (storage DBStorage) lookupMessageKeyReports(key string) ([]types.MessageKeyReport, error) {
+	reports := make([]types.MessageKeyReport, 0)
+
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
+	rows, err := storage.readConnection().QueryContext(ctx, `
+		SELECT org_id, cluster, status, last_checked_at, reported_at, kafka_offset
+		FROM report
+		WHERE message_key = $1
+		ORDER BY last_checked_at DESC;`,
+		key,
+	)
+	if err != nil {
+		return reports, types.ConvertDBError(err, key)
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var (
+			report      types.MessageKeyReport
+			lastChecked time.Time
+			reportedAt  time.Time
+		)
+
+		err = rows.Scan(
+			&report.OrgID, &report.ClusterName, &report.Status, &lastChecked, &reportedAt, &report.KafkaOffset,
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("LookupMessageKey")
+			return reports, types.ConvertDBError(err, key)
+		}
+
+		report.LastCheckedAt = types.Timestamp(lastChecked.UTC().Format(time.RFC3339))
+		report.ReportedAt = types.Timestamp(reportedAt.UTC().Format(time.RFC3339))
+		reports = append(reports, report)
+	}
+
+	return reports, rows.Err()
+}
+
+// lookupMessageKeyConsumerErrors returns errors recorded by the consumer for
+// messages with the key
+func (storage DBStorage) lookupMessageKeyConsumerErrors(key string) ([]types.MessageKeyConsumerErr, error) {
+	consumerErrors := make([]types.MessageKeyConsumerErr, 0)
+
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
+	rows, err := storage.readConnection().QueryContext(ctx, `
+		SELECT topic, partition, topic_offset, produced_at, consumed_at, error
+		FROM consumer_error
+		WHERE key = $1
+		ORDER BY consumed_at DESC;`,
+		key,
+	)
+	if err != nil {
+		return consumerErrors, types.ConvertDBError(err, key)
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var (
+			consumerErr types.MessageKeyConsumerErr
+			producedAt  time.Time
+			consumedAt  time.Time
+		)
+
+		err = rows.Scan(
+			&consumerErr.Topic, &consumerErr.Partition, &consumerErr.Offset, &producedAt, &consumedAt, &consumerErr.Error,
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("LookupMessageKey")
+			return consumerErrors, types.ConvertDBError(err, key)
+		}
+
+		consumerErr.ProducedAt = types.Timestamp(producedAt.UTC().Format(time.RFC3339))
+		consumerErr.ConsumedAt = types.Timestamp(consumedAt.UTC().Format(time.RFC3339))
+		consumerErrors = append(consumerErrors, consumerErr)
+	}
+
+	return consumerErrors, rows.Err()
+}
@@ -0,0 +1,132 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const testMessageKey = "0b9dcac4-1f2a-4c0e-b7a1-d3c3a2b6f0e1"
+
+// TestDBStorage_LookupMessageKey checks that reports and consumer errors are
+// found by the key of the message they were consumed from
+func TestDBStorage_LookupMessageKey(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.ClusterReportEmpty,
+		testdata.ReportEmptyRulesParsed,
+		testdata.LastCheckedAt,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	err = mockStorage.WriteReportMessageKey(
+		testdata.OrgID, testdata.ClusterName, testdata.LastCheckedAt, testMessageKey,
+	)
+	helpers.FailOnError(t, err)
+
+	err = mockStorage.WriteConsumerError(&sarama.ConsumerMessage{
+		Topic:     "topic",
+		Partition: 1,
+		Offset:    10,
+		Key:       []byte(testMessageKey),
+		Value:     []byte("value"),
+		Timestamp: testdata.LastCheckedAt,
+	}, errors.New("consumer error"))
+	helpers.FailOnError(t, err)
+
+	lookup, err := mockStorage.LookupMessageKey(testMessageKey)
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, testMessageKey, lookup.Key)
+	if assert.Len(t, lookup.Reports, 1) {
+		assert.Equal(t, testdata.OrgID, lookup.Reports[0].OrgID)
+		assert.Equal(t, testdata.ClusterName, lookup.Reports[0].ClusterName)
+		assert.Equal(t, types.ReportStatusAnalyzed, lookup.Reports[0].Status)
+		assert.Equal(t, testdata.KafkaOffset, lookup.Reports[0].KafkaOffset)
+	}
+	if assert.Len(t, lookup.ConsumerErrors, 1) {
+		assert.Equal(t, "topic", lookup.ConsumerErrors[0].Topic)
+		assert.Equal(t, int64(10), lookup.ConsumerErrors[0].Offset)
+		assert.Equal(t, "consumer error", lookup.ConsumerErrors[0].Error)
+	}
+}
+
+// TestDBStorage_WriteReportMessageKey_NewerReport checks that the key of
+// older message doesn't replace the key of more recent report
+func TestDBStorage_WriteReportMessageKey_NewerReport(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	lastCheckedAt := testdata.LastCheckedAt.Add(time.Hour)
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.ClusterReportEmpty,
+		testdata.ReportEmptyRulesParsed,
+		lastCheckedAt,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.FailOnError(t, mockStorage.WriteReportMessageKey(
+		testdata.OrgID, testdata.ClusterName, lastCheckedAt, testMessageKey,
+	))
+	helpers.FailOnError(t, mockStorage.WriteReportMessageKey(
+		testdata.OrgID, testdata.ClusterName, testdata.LastCheckedAt, "older-key",
+	))
+
+	lookup, err := mockStorage.LookupMessageKey("older-key")
+	helpers.FailOnError(t, err)
+	assert.Empty(t, lookup.Reports)
+
+	lookup, err = mockStorage.LookupMessageKey(testMessageKey)
+	helpers.FailOnError(t, err)
+	assert.Len(t, lookup.Reports, 1)
+}
+
+// TestDBStorage_LookupMessageKey_NotFound checks that empty lists are
+// returned for unknown key
+func TestDBStorage_LookupMessageKey_NotFound(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	lookup, err := mockStorage.LookupMessageKey(testMessageKey)
+	helpers.FailOnError(t, err)
+
+	assert.Empty(t, lookup.Reports)
+	assert.Empty(t, lookup.ConsumerErrors)
+}
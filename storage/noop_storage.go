@@ -42,7 +42,7 @@ func (*NoopStorage) ListOfOrgs() ([]types.OrgID, error) {
 }
 
 // ListOfClustersForOrg noop
-func (*NoopStorage) ListOfClustersForOrg(types.OrgID, time.Time) ([]types.ClusterName, error) {
+func (*NoopStorage) ListOfClustersForOrg(types.OrgID, time.Time, int, int) ([]types.ClusterName, error) {
 	return nil, nil
 }
 
@@ -64,10 +64,20 @@ func (*NoopStorage) ReadReportForClusterByClusterName(
 }
 
 // GetLatestKafkaOffset noop
-func (*NoopStorage) GetLatestKafkaOffset() (types.KafkaOffset, error) {
+func (*NoopStorage) GetLatestKafkaOffset(string, int32) (types.KafkaOffset, error) {
 	return 0, nil
 }
 
+// GetLatestKafkaOffsets noop
+func (*NoopStorage) GetLatestKafkaOffsets() ([]types.KafkaPartitionOffset, error) {
+	return nil, nil
+}
+
+// WriteKafkaOffset noop
+func (*NoopStorage) WriteKafkaOffset(string, int32, types.KafkaOffset) error {
+	return nil
+}
+
 // WriteReportForCluster noop
 func (*NoopStorage) WriteReportForCluster(
 	types.OrgID, types.ClusterName, types.ClusterReport, []types.ReportItem, time.Time, types.KafkaOffset,
@@ -75,6 +85,16 @@ func (*NoopStorage) WriteReportForCluster(
 	return nil
 }
 
+// WriteFailedReportForCluster noop
+func (*NoopStorage) WriteFailedReportForCluster(types.OrgID, types.ClusterName, time.Time, types.KafkaOffset) error {
+	return nil
+}
+
+// ReadReportStatusForCluster noop
+func (*NoopStorage) ReadReportStatusForCluster(types.OrgID, types.ClusterName) (types.ReportStatus, error) {
+	return types.ReportStatusNoData, nil
+}
+
 // ReportsCount noop
 func (*NoopStorage) ReportsCount() (int, error) {
 	return 0, nil
@@ -123,6 +143,11 @@ func (*NoopStorage) DeleteReportsForCluster(types.ClusterName) error {
 	return nil
 }
 
+// DeleteClusterReport noop
+func (*NoopStorage) DeleteClusterReport(types.OrgID, types.ClusterName, time.Time) error {
+	return nil
+}
+
 // LoadRuleContent noop
 func (*NoopStorage) LoadRuleContent(content.RuleContentDirectory) error {
 	return nil
@@ -192,6 +217,16 @@ func (*NoopStorage) GetTogglesForRules(
 	return nil, nil
 }
 
+// ReadRuleDisableDetails noop
+func (*NoopStorage) ReadRuleDisableDetails(types.ClusterName) ([]RuleDisableDetails, error) {
+	return nil, nil
+}
+
+// ReadRuleHitsFirstSeen noop
+func (*NoopStorage) ReadRuleHitsFirstSeen(types.OrgID, types.ClusterName) ([]RuleHitFirstSeen, error) {
+	return nil, nil
+}
+
 // GetUserFeedbackOnRules noop
 func (*NoopStorage) GetUserFeedbackOnRules(
 	types.ClusterName,
@@ -220,6 +255,16 @@ func (*NoopStorage) DoesClusterExist(types.ClusterName) (bool, error) {
 	return false, nil
 }
 
+// DoesClusterExistWithOrgID noop
+func (*NoopStorage) DoesClusterExistWithOrgID(types.ClusterName) (bool, types.OrgID, error) {
+	return false, 0, nil
+}
+
+// ReadOrgIDsOfClusters noop
+func (*NoopStorage) ReadOrgIDsOfClusters([]types.ClusterName) (map[types.ClusterName]types.OrgID, error) {
+	return nil, nil
+}
+
 // ReadOrgIDsForClusters read organization IDs for given list of cluster names.
 func (*NoopStorage) ReadOrgIDsForClusters(clusterNames []types.ClusterName) ([]types.OrgID, error) {
 	return nil, nil
@@ -230,3 +275,316 @@ func (*NoopStorage) ReadOrgIDsForClusters(clusterNames []types.ClusterName) ([]t
 func (*NoopStorage) ReadReportsForClusters(clusterNames []types.ClusterName) (map[types.ClusterName]types.ClusterReport, error) {
 	return nil, nil
 }
+
+// ReadRuleHitOccurrences noop
+func (*NoopStorage) ReadRuleHitOccurrences(
+	types.ClusterName, types.RuleID, types.ErrorKey,
+) ([]types.RuleHitOccurrence, error) {
+	return nil, nil
+}
+
+// ReadReportChecks noop
+func (*NoopStorage) ReadReportChecks(types.ClusterName, int) ([]types.ReportCheck, error) {
+	return nil, nil
+}
+
+// WriteReportHistory noop
+func (*NoopStorage) WriteReportHistory(
+	types.OrgID, types.ClusterName, types.ClusterReport, time.Time, types.KafkaOffset,
+) error {
+	return nil
+}
+
+// RebuildClustersLastCheckedCache noop
+func (*NoopStorage) RebuildClustersLastCheckedCache() (int, error) {
+	return 0, nil
+}
+
+// RecomputeAggregates noop
+func (*NoopStorage) RecomputeAggregates() (AggregatesRecomputation, error) {
+	return AggregatesRecomputation{}, nil
+}
+
+// CreateAPIKey noop
+func (*NoopStorage) CreateAPIKey(string, string, []string, string, time.Time) error {
+	return nil
+}
+
+// RotateAPIKey noop
+func (*NoopStorage) RotateAPIKey(string, string) error {
+	return nil
+}
+
+// RevokeAPIKey noop
+func (*NoopStorage) RevokeAPIKey(string) error {
+	return nil
+}
+
+// ReadAPIKey noop
+func (*NoopStorage) ReadAPIKey(string) (types.APIKey, string, error) {
+	return types.APIKey{}, "", nil
+}
+
+// ReadAPIKeys noop
+func (*NoopStorage) ReadAPIKeys() ([]types.APIKey, error) {
+	return nil, nil
+}
+
+// ReadClusterOrgChanges noop
+func (*NoopStorage) ReadClusterOrgChanges(time.Time) ([]types.ClusterOrgChange, error) {
+	return nil, nil
+}
+
+// WriteExternalResults noop
+func (*NoopStorage) WriteExternalResults(
+	types.OrgID, types.ClusterName, string, []types.ExternalResult, time.Time, types.KafkaOffset,
+) error {
+	return nil
+}
+
+// ReadExternalResults noop
+func (*NoopStorage) ReadExternalResults(types.ClusterName, string) ([]types.ExternalResult, error) {
+	return nil, nil
+}
+
+// ReadDBSchema noop
+func (*NoopStorage) ReadDBSchema() (types.DBSchema, error) {
+	return types.DBSchema{}, nil
+}
+
+// GetDBDriverType noop
+func (*NoopStorage) GetDBDriverType() types.DBDriver {
+	return types.DBDriverGeneral
+}
+
+// Capabilities noop
+func (*NoopStorage) Capabilities() Capabilities {
+	return Capabilities{}
+}
+
+// ArchiveReportsNotCheckedSince noop
+func (*NoopStorage) ArchiveReportsNotCheckedSince(time.Time, int) (int, error) {
+	return 0, nil
+}
+
+// IterateRuleHitsForRule noop
+func (*NoopStorage) IterateRuleHitsForRule(
+	types.OrgID, types.RuleID, types.ErrorKey, int, int, func(RuleHitRecord) error,
+) error {
+	return nil
+}
+
+// ReadReportHistoryForCluster noop
+func (*NoopStorage) ReadReportHistoryForCluster(
+	types.OrgID, types.ClusterName, time.Time, time.Time,
+) ([]types.HistoricalReport, error) {
+	return nil, nil
+}
+
+// CleanupOldData noop
+func (*NoopStorage) CleanupOldData(RetentionPolicy) (map[string]int, error) {
+	return map[string]int{}, nil
+}
+
+// ReadOrgRuleDisables noop
+func (*NoopStorage) ReadOrgRuleDisables(types.OrgID, int) (types.OrgRuleDisables, error) {
+	return types.OrgRuleDisables{MostDisabled: []types.DisabledRuleCount{}}, nil
+}
+
+// CountClustersForOrg noop
+func (*NoopStorage) CountClustersForOrg(types.OrgID, time.Time) (int, error) {
+	return 0, nil
+}
+
+// WriteReportMessageKey noop
+func (*NoopStorage) WriteReportMessageKey(types.OrgID, types.ClusterName, time.Time, string) error {
+	return nil
+}
+
+// WriteClusterClass noop
+func (*NoopStorage) WriteClusterClass(types.OrgID, types.ClusterName, types.ClusterClass) error {
+	return nil
+}
+
+// ReadClusterClasses noop
+func (*NoopStorage) ReadClusterClasses(types.OrgID) (map[types.ClusterName]types.ClusterClass, error) {
+	return nil, nil
+}
+
+// LookupMessageKey noop
+func (*NoopStorage) LookupMessageKey(string) (types.MessageKeyLookup, error) {
+	return types.MessageKeyLookup{}, nil
+}
+
+// GetClustersLastCheckedCacheStats noop
+func (*NoopStorage) GetClustersLastCheckedCacheStats() (ClustersLastCheckedCacheStats, error) {
+	return ClustersLastCheckedCacheStats{}, nil
+}
+
+// CheckClustersLastCheckedDivergence noop
+func (*NoopStorage) CheckClustersLastCheckedDivergence(int) (ClustersLastCheckedDivergence, error) {
+	return ClustersLastCheckedDivergence{}, nil
+}
+
+// IterateReports noop
+func (*NoopStorage) IterateReports(func(ReportRecord) error) error {
+	return nil
+}
+
+// IterateRuleHits noop
+func (*NoopStorage) IterateRuleHits(func(RuleHitRecord) error) error {
+	return nil
+}
+
+// DeleteReportsNotCheckedSince noop
+func (*NoopStorage) DeleteReportsNotCheckedSince(time.Time) (int, error) {
+	return 0, nil
+}
+
+// CountClustersNotCheckedSince noop
+func (*NoopStorage) CountClustersNotCheckedSince(time.Time) (int, error) {
+	return 0, nil
+}
+
+// AddClusterAnnotation noop
+func (*NoopStorage) AddClusterAnnotation(
+	types.ClusterName, types.UserID, string,
+) (types.ClusterAnnotation, error) {
+	return types.ClusterAnnotation{}, nil
+}
+
+// ReadClusterAnnotations noop
+func (*NoopStorage) ReadClusterAnnotations(types.ClusterName) ([]types.ClusterAnnotation, error) {
+	return nil, nil
+}
+
+// DeleteClusterAnnotation noop
+func (*NoopStorage) DeleteClusterAnnotation(types.ClusterName, string) error {
+	return nil
+}
+
+// ListOfOrgsWithSummary noop
+func (*NoopStorage) ListOfOrgsWithSummary() ([]types.OrgSummary, error) {
+	return nil, nil
+}
+
+// ImportUserFeedback noop
+func (*NoopStorage) ImportUserFeedback([]UserFeedbackOnRule) error {
+	return nil
+}
+
+// ReadOrgInfo noop
+func (*NoopStorage) ReadOrgInfo(types.OrgID) (types.OrgInfo, error) {
+	return types.OrgInfo{}, nil
+}
+
+// GetUserFeedbackOnRulesForClusters noop
+func (*NoopStorage) GetUserFeedbackOnRulesForClusters(
+	[]types.ClusterName, types.UserID,
+) ([]UserFeedbackOnRule, error) {
+	return nil, nil
+}
+
+// WriteStaleReport noop
+func (*NoopStorage) WriteStaleReport(types.OrgID, types.ClusterName, time.Time, time.Time) error {
+	return nil
+}
+
+// ReadStaleReportWrites noop
+func (*NoopStorage) ReadStaleReportWrites() ([]types.StaleReportWrite, error) {
+	return nil, nil
+}
+
+// ReadRuleHitsForCluster noop
+func (*NoopStorage) ReadRuleHitsForCluster(types.ClusterName) ([]RuleHitRecord, error) {
+	return nil, nil
+}
+
+// AddOrgUsage noop
+func (*NoopStorage) AddOrgUsage(types.OrgID, int64, int64, int64) error {
+	return nil
+}
+
+// ReadOrgUsage noop
+func (*NoopStorage) ReadOrgUsage(string) ([]types.OrgUsage, error) {
+	return nil, nil
+}
+
+// ReadRuleResolutionRates noop
+func (*NoopStorage) ReadRuleResolutionRates() ([]types.RuleResolutionRate, error) {
+	return nil, nil
+}
+
+// ReadRuleResolutionRatesForOrg noop
+func (*NoopStorage) ReadRuleResolutionRatesForOrg(types.OrgID) ([]types.RuleResolutionRate, error) {
+	return nil, nil
+}
+
+// ReadTopRules noop
+func (*NoopStorage) ReadTopRules(time.Time, time.Time, int) ([]types.TopRule, error) {
+	return nil, nil
+}
+
+// ReadTopRulesForOrg noop
+func (*NoopStorage) ReadTopRulesForOrg(types.OrgID, time.Time, time.Time, int) ([]types.TopRule, error) {
+	return nil, nil
+}
+
+// ReadOrgReport noop
+func (*NoopStorage) ReadOrgReport(types.OrgID) ([]types.OrgReportRule, error) {
+	return nil, nil
+}
+
+// FreezeOrg noop
+func (*NoopStorage) FreezeOrg(types.OrgID, string) error {
+	return nil
+}
+
+// UnfreezeOrg noop
+func (*NoopStorage) UnfreezeOrg(types.OrgID) error {
+	return nil
+}
+
+// IsOrgFrozen noop
+func (*NoopStorage) IsOrgFrozen(types.OrgID) (bool, error) {
+	return false, nil
+}
+
+// ReadFrozenOrgs noop
+func (*NoopStorage) ReadFrozenOrgs() ([]types.OrgFreeze, error) {
+	return nil, nil
+}
+
+// ReadVoteSummaryOnRule noop
+func (*NoopStorage) ReadVoteSummaryOnRule(types.ClusterName, types.RuleID, types.ErrorKey) (types.VoteSummary, error) {
+	return types.VoteSummary{}, nil
+}
+
+// ReadVoteSummaryOnRuleForOrg noop
+func (*NoopStorage) ReadVoteSummaryOnRuleForOrg(types.OrgID, types.RuleID, types.ErrorKey) (types.VoteSummary, error) {
+	return types.VoteSummary{}, nil
+}
+
+// VoteOnRuleForOrg noop
+func (*NoopStorage) VoteOnRuleForOrg(types.OrgID, types.RuleID, types.ErrorKey, types.UserID, types.UserVote, string) error {
+	return nil
+}
+
+// AddOrUpdateFeedbackOnRuleForOrg noop
+func (*NoopStorage) AddOrUpdateFeedbackOnRuleForOrg(
+	types.OrgID, types.RuleID, types.ErrorKey, types.UserID, string,
+) error {
+	return nil
+}
+
+// GetUserFeedbackOnRuleForOrg noop
+func (*NoopStorage) GetUserFeedbackOnRuleForOrg(
+	types.OrgID, types.RuleID, types.ErrorKey, types.UserID,
+) (*OrgUserFeedbackOnRule, error) {
+	return nil, nil
+}
+
+// GetFeedbackOnRuleForOrg noop
+func (*NoopStorage) GetFeedbackOnRuleForOrg(types.OrgID, types.RuleID, types.ErrorKey) ([]OrgUserFeedbackOnRule, error) {
+	return nil, nil
+}
@@ -30,11 +30,15 @@ func TestNoopStorage_Methods(t *testing.T) {
 	_ = noopStorage.Init()
 	_ = noopStorage.Close()
 	_, _ = noopStorage.ListOfOrgs()
-	_, _ = noopStorage.ListOfClustersForOrg(0, time.Now())
+	_, _ = noopStorage.ListOfClustersForOrg(0, time.Now(), 0, 0)
 	_, _, _ = noopStorage.ReadReportForCluster(0, "")
 	_, _, _ = noopStorage.ReadReportForClusterByClusterName("")
-	_, _ = noopStorage.GetLatestKafkaOffset()
+	_, _ = noopStorage.GetLatestKafkaOffset("", 0)
+	_, _ = noopStorage.GetLatestKafkaOffsets()
+	_ = noopStorage.WriteKafkaOffset("", 0, 0)
 	_ = noopStorage.WriteReportForCluster(0, "", "", []types.ReportItem{}, time.Now(), 0)
+	_ = noopStorage.WriteFailedReportForCluster(0, "", time.Now(), 0)
+	_, _ = noopStorage.ReadReportStatusForCluster(0, "")
 	_, _ = noopStorage.ReportsCount()
 	_ = noopStorage.VoteOnRule("", "", "", "", 0, "")
 	_ = noopStorage.AddOrUpdateFeedbackOnRule("", "", "", "", "")
@@ -43,6 +47,7 @@ func TestNoopStorage_Methods(t *testing.T) {
 	_, _ = noopStorage.GetUserFeedbackOnRule("", "", "", "")
 	_ = noopStorage.DeleteReportsForOrg(0)
 	_ = noopStorage.DeleteReportsForCluster("")
+	_ = noopStorage.DeleteClusterReport(0, "", time.Time{})
 	_ = noopStorage.LoadRuleContent(content.RuleContentDirectory{})
 	_, _ = noopStorage.GetRuleByID("")
 	_, _ = noopStorage.GetOrgIDByClusterID("")
@@ -60,6 +65,8 @@ func TestNoopStorage_Methods_Cont(t *testing.T) {
 	_ = noopStorage.DeleteFromRuleClusterToggle("", "")
 	_, _ = noopStorage.GetFromClusterRuleToggle("", "")
 	_, _ = noopStorage.GetTogglesForRules("", nil)
+	_, _ = noopStorage.ReadRuleDisableDetails("")
+	_, _ = noopStorage.ReadRuleHitsFirstSeen(0, "")
 	_, _ = noopStorage.GetUserFeedbackOnRules("", nil, "")
 	_, _ = noopStorage.GetRuleWithContent("", "")
 	_, _ = noopStorage.ReadOrgIDsForClusters([]types.ClusterName{})
@@ -67,4 +74,65 @@ func TestNoopStorage_Methods_Cont(t *testing.T) {
 	_, _ = noopStorage.ReadSingleRuleTemplateData(0, "", "", "")
 	_, _ = noopStorage.GetUserDisableFeedbackOnRules("", []types.RuleOnReport{}, "")
 	_, _ = noopStorage.DoesClusterExist("")
+	_, _, _ = noopStorage.DoesClusterExistWithOrgID("")
+	_, _ = noopStorage.ReadOrgIDsOfClusters(nil)
+	_, _ = noopStorage.ReadRuleHitOccurrences("", "", "")
+	_, _ = noopStorage.ReadReportChecks("", 0)
+	_ = noopStorage.WriteReportHistory(0, "", "", time.Time{}, 0)
+	_, _ = noopStorage.RebuildClustersLastCheckedCache()
+	_, _ = noopStorage.GetClustersLastCheckedCacheStats()
+	_, _ = noopStorage.CheckClustersLastCheckedDivergence(0)
+	_, _ = noopStorage.RecomputeAggregates()
+	_ = noopStorage.CreateAPIKey("", "", nil, "", time.Time{})
+	_ = noopStorage.RotateAPIKey("", "")
+	_ = noopStorage.RevokeAPIKey("")
+	_, _, _ = noopStorage.ReadAPIKey("")
+	_, _ = noopStorage.ReadAPIKeys()
+	_, _ = noopStorage.ReadClusterOrgChanges(time.Time{})
+	_ = noopStorage.WriteExternalResults(0, "", "", nil, time.Time{}, 0)
+	_, _ = noopStorage.ReadExternalResults("", "")
+	_, _ = noopStorage.ReadDBSchema()
+	_ = noopStorage.GetDBDriverType()
+	_ = noopStorage.Capabilities()
+	_, _ = noopStorage.ArchiveReportsNotCheckedSince(time.Time{}, 0)
+	_ = noopStorage.IterateRuleHitsForRule(0, "", "", 0, 0, nil)
+	_, _ = noopStorage.ReadReportHistoryForCluster(0, "", time.Time{}, time.Time{})
+	_, _ = noopStorage.CleanupOldData(storage.RetentionPolicy{})
+	_, _ = noopStorage.ReadOrgRuleDisables(0, 0)
+	_, _ = noopStorage.CountClustersForOrg(0, time.Now())
+	_ = noopStorage.WriteReportMessageKey(0, "", time.Time{}, "")
+	_, _ = noopStorage.LookupMessageKey("")
+	_ = noopStorage.WriteClusterClass(0, "", "")
+	_, _ = noopStorage.ReadClusterClasses(0)
+	_ = noopStorage.IterateReports(nil)
+	_ = noopStorage.IterateRuleHits(nil)
+	_, _ = noopStorage.DeleteReportsNotCheckedSince(time.Time{})
+	_, _ = noopStorage.CountClustersNotCheckedSince(time.Time{})
+	_, _ = noopStorage.AddClusterAnnotation("", "", "")
+	_, _ = noopStorage.ReadClusterAnnotations("")
+	_ = noopStorage.DeleteClusterAnnotation("", "")
+	_, _ = noopStorage.ReadOrgInfo(0)
+	_, _ = noopStorage.ListOfOrgsWithSummary()
+	_ = noopStorage.ImportUserFeedback(nil)
+	_, _ = noopStorage.GetUserFeedbackOnRulesForClusters(nil, "")
+	_ = noopStorage.WriteStaleReport(0, "", time.Time{}, time.Time{})
+	_, _ = noopStorage.ReadStaleReportWrites()
+	_, _ = noopStorage.ReadRuleHitsForCluster("")
+	_ = noopStorage.AddOrgUsage(0, 0, 0, 0)
+	_, _ = noopStorage.ReadOrgUsage("")
+	_, _ = noopStorage.ReadRuleResolutionRates()
+	_, _ = noopStorage.ReadRuleResolutionRatesForOrg(0)
+	_, _ = noopStorage.ReadTopRules(time.Time{}, time.Time{}, 0)
+	_, _ = noopStorage.ReadTopRulesForOrg(0, time.Time{}, time.Time{}, 0)
+	_, _ = noopStorage.ReadOrgReport(0)
+	_ = noopStorage.FreezeOrg(0, "")
+	_ = noopStorage.UnfreezeOrg(0)
+	_, _ = noopStorage.IsOrgFrozen(0)
+	_, _ = noopStorage.ReadFrozenOrgs()
+	_, _ = noopStorage.ReadVoteSummaryOnRule("", "", "")
+	_, _ = noopStorage.ReadVoteSummaryOnRuleForOrg(0, "", "")
+	_ = noopStorage.VoteOnRuleForOrg(0, "", "", "", 0, "")
+	_ = noopStorage.AddOrUpdateFeedbackOnRuleForOrg(0, "", "", "", "")
+	_, _ = noopStorage.GetUserFeedbackOnRuleForOrg(0, "", "", "")
+	_, _ = noopStorage.GetFeedbackOnRuleForOrg(0, "", "")
 }
@@ -0,0 +1,75 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// operationClass classifies storage operations by the time they are expected
+// to take, every class has its own configurable timeout
+type operationClass int
+
+const (
+	// readOperation reads a few records selected by key (report of one
+	// cluster, for example)
+	readOperation operationClass = iota
+	// writeOperation writes records, including the whole transaction
+	writeOperation
+	// aggregationOperation scans or aggregates records of many clusters
+	// (overview of organization, statistics, for example)
+	aggregationOperation
+)
+
+// operationTimeouts contains timeouts of all operation classes, 0 means the
+// operations of the class have no deadline
+type operationTimeouts struct {
+	read        time.Duration
+	write       time.Duration
+	aggregation time.Duration
+}
+
+// SetOperationTimeouts sets timeouts of reads, writes and aggregations done
+// by the storage, 0 means no timeout
+func (storage *DBStorage) SetOperationTimeouts(read, write, aggregation time.Duration) {
+	storage.timeouts = operationTimeouts{
+		read:        read,
+		write:       write,
+		aggregation: aggregation,
+	}
+}
+
+// operationContext returns context with the deadline of the operation class,
+// the returned cancel function has to be called when the operation is done
+// (when all returned rows are read)
+func (storage DBStorage) operationContext(class operationClass) (context.Context, context.CancelFunc) {
+	var timeout time.Duration
+
+	switch class {
+	case readOperation:
+		timeout = storage.timeouts.read
+	case writeOperation:
+		timeout = storage.timeouts.write
+	case aggregationOperation:
+		timeout = storage.timeouts.aggregation
+	}
+
+	if timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+
+	return context.WithTimeout(context.Background(), timeout)
+}
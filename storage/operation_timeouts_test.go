@@ -0,0 +1,63 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+)
+
+// TestDBStorageAggregationTimeout checks that slow aggregation is canceled
+// when the aggregation timeout expires
+func TestDBStorageAggregationTimeout(t *testing.T) {
+	mockStorage, expects := ira_helpers.MustGetMockStorageWithExpects(t)
+	defer ira_helpers.MustCloseMockStorageWithExpects(t, mockStorage, expects)
+
+	mockStorage.(*storage.DBStorage).SetOperationTimeouts(0, 0, 10*time.Millisecond)
+
+	expects.ExpectQuery("SELECT DISTINCT org_id FROM report").
+		WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"org_id"}).AddRow(testdata.OrgID))
+
+	start := time.Now()
+	_, err := mockStorage.ListOfOrgs()
+	assert.Error(t, err)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+}
+
+// TestDBStorageTimeoutOfOtherClass checks that timeout of one operation class
+// is not applied to operations of other classes
+func TestDBStorageTimeoutOfOtherClass(t *testing.T) {
+	mockStorage, expects := ira_helpers.MustGetMockStorageWithExpects(t)
+	defer ira_helpers.MustCloseMockStorageWithExpects(t, mockStorage, expects)
+
+	mockStorage.(*storage.DBStorage).SetOperationTimeouts(time.Millisecond, time.Millisecond, 0)
+
+	expects.ExpectQuery("SELECT DISTINCT org_id FROM report").
+		WillDelayFor(50 * time.Millisecond).
+		WillReturnRows(sqlmock.NewRows([]string{"org_id"}).AddRow(testdata.OrgID))
+
+	orgs, err := mockStorage.ListOfOrgs()
+	helpers.FailOnError(t, err)
+	assert.Len(t, orgs, 1)
+}
@@ -0,0 +1,109 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// FreezeOrg freezes the organization, so messages from it are dropped and its
+// data can't be changed through the REST API. Reason of the freeze is updated
+// when the organization is frozen already.
+func (storage DBStorage) FreezeOrg(orgID types.OrgID, reason string) error {
+	ctx, cancel := storage.operationContext(writeOperation)
+	defer cancel()
+
+	_, err := storage.connection.ExecContext(ctx, `
+		INSERT INTO org_freeze (org_id, reason, frozen_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (org_id) DO UPDATE SET reason = $2;
+	`, orgID, reason, time.Now())
+
+	return types.ConvertDBError(err, orgID)
+}
+
+// UnfreezeOrg removes the freeze of the organization. ItemNotFoundError is
+// returned when the organization is not frozen.
+func (storage DBStorage) UnfreezeOrg(orgID types.OrgID) error {
+	ctx, cancel := storage.operationContext(writeOperation)
+	defer cancel()
+
+	result, err := storage.connection.ExecContext(ctx, "DELETE FROM org_freeze WHERE org_id = $1;", orgID)
+	if err != nil {
+		return types.ConvertDBError(err, orgID)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return types.ConvertDBError(err, orgID)
+	}
+
+	if deleted == 0 {
+		return &types.ItemNotFoundError{ItemID: orgID}
+	}
+
+	return nil
+}
+
+// IsOrgFrozen checks whether the organization is frozen
+func (storage DBStorage) IsOrgFrozen(orgID types.OrgID) (bool, error) {
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
+	var count int
+
+	err := storage.connection.QueryRowContext(
+		ctx, "SELECT COUNT(*) FROM org_freeze WHERE org_id = $1;", orgID,
+	).Scan(&count)
+	if err != nil {
+		return false, types.ConvertDBError(err, orgID)
+	}
+
+	return count > 0, nil
+}
+
+// ReadFrozenOrgs returns all frozen organizations ordered by organization ID
+func (storage DBStorage) ReadFrozenOrgs() ([]types.OrgFreeze, error) {
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
+	freezes := make([]types.OrgFreeze, 0)
+
+	rows, err := storage.readConnection().QueryContext(
+		ctx, "SELECT org_id, reason, frozen_at FROM org_freeze ORDER BY org_id;",
+	)
+	if err != nil {
+		return freezes, err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var (
+			freeze   types.OrgFreeze
+			frozenAt time.Time
+		)
+
+		if err := rows.Scan(&freeze.OrgID, &freeze.Reason, &frozenAt); err != nil {
+			return freezes, err
+		}
+
+		freeze.FrozenAt = types.Timestamp(frozenAt.UTC().Format(time.RFC3339))
+		freezes = append(freezes, freeze)
+	}
+
+	return freezes, rows.Err()
+}
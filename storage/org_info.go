@@ -0,0 +1,170 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// updateOrgInfo records that a report from the organization was received at
+// the given time. The first time is kept when the organization is already
+// known.
+func updateOrgInfo(tx *sql.Tx, orgID types.OrgID, seenAt time.Time) error {
+	_, err := tx.Exec(`
+		INSERT INTO org_info (org_id, first_seen_at, last_seen_at)
+		VALUES ($1, $2, $2)
+		ON CONFLICT (org_id) DO UPDATE SET last_seen_at = $2;
+	`, orgID, seenAt)
+	if err != nil {
+		log.Err(err).Msgf("Unable to update organization info (org: %v)", orgID)
+	}
+
+	return err
+}
+
+// ReadOrgInfo returns when the organization was first seen and when the last
+// report from it was received. ItemNotFoundError is returned when no report
+// was received from the organization yet.
+func (storage DBStorage) ReadOrgInfo(orgID types.OrgID) (types.OrgInfo, error) {
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
+	var firstSeenAt, lastSeenAt time.Time
+
+	err := storage.readConnection().QueryRowContext(
+		ctx,
+		"SELECT first_seen_at, last_seen_at FROM org_info WHERE org_id = $1;", orgID,
+	).Scan(&firstSeenAt, &lastSeenAt)
+	if err != nil {
+		return types.OrgInfo{}, types.ConvertDBError(err, orgID)
+	}
+
+	return types.OrgInfo{
+		OrgID:       orgID,
+		FirstSeenAt: types.Timestamp(firstSeenAt.UTC().Format(time.RFC3339)),
+		LastSeenAt:  types.Timestamp(lastSeenAt.UTC().Format(time.RFC3339)),
+	}, nil
+}
+
+// ReadOrgRuleDisables returns numbers of rules disabled for clusters of the
+// organization grouped by rule. Rules disabled for all clusters of the
+// organization are counted as disabled for the organization, the service
+// doesn't support disabling of rules for the whole organization. At most
+// mostDisabledLimit of the most commonly disabled rules are returned.
+func (storage DBStorage) ReadOrgRuleDisables(
+	orgID types.OrgID, mostDisabledLimit int,
+) (types.OrgRuleDisables, error) {
+	ctx, cancel := storage.operationContext(aggregationOperation)
+	defer cancel()
+
+	disables := types.OrgRuleDisables{MostDisabled: make([]types.DisabledRuleCount, 0)}
+
+	var clusters int
+	err := storage.readConnection().QueryRowContext(
+		ctx, "SELECT COUNT(*) FROM report WHERE org_id = $1;", orgID,
+	).Scan(&clusters)
+	if err != nil {
+		return disables, types.ConvertDBError(err, orgID)
+	}
+
+	rows, err := storage.readConnection().QueryContext(ctx, `
+		SELECT toggle.rule_id, toggle.error_key, COUNT(*)
+		FROM cluster_rule_toggle toggle
+		JOIN report ON report.cluster = toggle.cluster_id
+		WHERE report.org_id = $1 AND toggle.disabled = $2
+		GROUP BY toggle.rule_id, toggle.error_key
+		ORDER BY COUNT(*) DESC, toggle.rule_id, toggle.error_key;
+	`, orgID, RuleToggleDisable)
+	if err != nil {
+		return disables, types.ConvertDBError(err, orgID)
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var rule types.DisabledRuleCount
+
+		err = rows.Scan(&rule.RuleID, &rule.ErrorKey, &rule.Clusters)
+		if err != nil {
+			log.Error().Err(err).Msg("ReadOrgRuleDisables")
+			return disables, types.ConvertDBError(err, orgID)
+		}
+
+		disables.ClusterLevel += rule.Clusters
+		if rule.Clusters == clusters {
+			disables.OrgLevel++
+		}
+		if len(disables.MostDisabled) < mostDisabledLimit {
+			disables.MostDisabled = append(disables.MostDisabled, rule)
+		}
+	}
+
+	return disables, rows.Err()
+}
+
+// ListOfOrgsWithSummary returns all organizations that have at least one
+// cluster report together with number of their clusters and the time when the
+// most recent report was checked. The most recent report is joined back to
+// the grouped records, so its timestamp is read from the column and it's
+// converted to time by all drivers. Organizations whose reports have no time
+// of the last check (rows written by old versions of the service) are
+// listed with empty timestamp.
+func (storage DBStorage) ListOfOrgsWithSummary() ([]types.OrgSummary, error) {
+	ctx, cancel := storage.operationContext(aggregationOperation)
+	defer cancel()
+
+	orgs := make([]types.OrgSummary, 0)
+
+	rows, err := storage.readConnection().QueryContext(ctx, `
+		SELECT DISTINCT report.org_id, orgs.cluster_count, report.last_checked_at
+		FROM report
+		JOIN (
+			SELECT org_id, count(*) AS cluster_count, max(last_checked_at) AS last_checked_at
+			FROM report
+			GROUP BY org_id
+		) AS orgs
+		ON report.org_id = orgs.org_id AND (
+			report.last_checked_at = orgs.last_checked_at OR orgs.last_checked_at IS NULL
+		)
+		ORDER BY report.org_id;
+	`)
+	if err != nil {
+		return orgs, types.ConvertDBError(err, nil)
+	}
+
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var (
+			org           types.OrgSummary
+			lastCheckedAt types.NullTime
+		)
+
+		err = rows.Scan(&org.OrgID, &org.ClusterCount, &lastCheckedAt)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to read organization summary")
+			return orgs, err
+		}
+
+		org.LastCheckedAt = lastCheckedAt.Timestamp()
+		orgs = append(orgs, org)
+	}
+
+	return orgs, rows.Err()
+}
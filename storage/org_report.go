@@ -0,0 +1,108 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// orgReportQuery groups rule hits of all clusters of the organization by rule,
+// clusters where the rule is enabled and where it is disabled are aggregated
+// separately. The placeholder is replaced by the string aggregation function
+// of the DB.
+const orgReportQuery = `
+	SELECT
+		rule_hit.rule_fqdn,
+		rule_hit.error_key,
+		%[1]s(CASE WHEN COALESCE(toggle.disabled, 0) = 0 THEN rule_hit.cluster_id END, ','),
+		%[1]s(CASE WHEN toggle.disabled = 1 THEN rule_hit.cluster_id END, ',')
+	FROM rule_hit
+	LEFT JOIN cluster_rule_toggle toggle
+	ON toggle.cluster_id = rule_hit.cluster_id
+		AND toggle.rule_id = rule_hit.rule_fqdn
+		AND toggle.error_key = rule_hit.error_key
+	WHERE rule_hit.org_id = $1
+	GROUP BY rule_hit.rule_fqdn, rule_hit.error_key
+	ORDER BY rule_hit.rule_fqdn, rule_hit.error_key;
+`
+
+// ReadOrgReport returns report merged from the latest reports of all
+// clusters of the organization: every rule hit by any of the clusters with
+// the list of affected clusters, ordered by rule. Disables of the rules are
+// honored, the clusters where the rule is disabled are not listed as affected.
+func (storage DBStorage) ReadOrgReport(orgID types.OrgID) ([]types.OrgReportRule, error) {
+	ctx, cancel := storage.operationContext(aggregationOperation)
+	defer cancel()
+
+	report := make([]types.OrgReportRule, 0)
+
+	aggregateFunction := "group_concat"
+	if storage.dbDriverType == types.DBDriverPostgres {
+		aggregateFunction = "string_agg"
+	}
+
+	rows, err := storage.readConnection().QueryContext(ctx, fmt.Sprintf(orgReportQuery, aggregateFunction), orgID)
+	if err != nil {
+		return report, types.ConvertDBError(err, orgID)
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var (
+			rule             types.OrgReportRule
+			clusters         sql.NullString
+			disabledClusters sql.NullString
+		)
+
+		err = rows.Scan(&rule.RuleID, &rule.ErrorKey, &clusters, &disabledClusters)
+		if err != nil {
+			log.Error().Err(err).Msg("ReadOrgReport")
+			return report, types.ConvertDBError(err, orgID)
+		}
+
+		rule.Clusters = splitClusterNames(clusters)
+		rule.DisabledClusters = splitClusterNames(disabledClusters)
+
+		report = append(report, rule)
+	}
+
+	return report, nil
+}
+
+// splitClusterNames returns sorted cluster names from the string aggregated
+// by the DB, the order of the aggregated values is not defined
+func splitClusterNames(aggregated sql.NullString) []types.ClusterName {
+	clusterNames := make([]types.ClusterName, 0)
+	if !aggregated.Valid || aggregated.String == "" {
+		return clusterNames
+	}
+
+	for _, clusterName := range strings.Split(aggregated.String, ",") {
+		clusterNames = append(clusterNames, types.ClusterName(clusterName))
+	}
+
+	sort.Slice(clusterNames, func(i, j int) bool {
+		return clusterNames[i] < clusterNames[j]
+	})
+
+	return clusterNames
+}
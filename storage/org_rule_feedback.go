@@ -0,0 +1,213 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// OrgUserFeedbackOnRule shows user's feedback on rule in the view of the
+// whole organization
+type OrgUserFeedbackOnRule struct {
+	OrgID     types.OrgID
+	RuleID    types.RuleID
+	ErrorKey  types.ErrorKey
+	UserID    types.UserID
+	Message   string
+	UserVote  types.UserVote
+	AddedAt   time.Time
+	UpdatedAt time.Time
+}
+
+var errOrgRuleFeedbackNotSupported = fmt.Errorf(
+	"feedback on rules for organization is not supported before DB migration %d", orgRuleFeedbackVersion,
+)
+
+// VoteOnRuleForOrg likes or dislikes rule for organization by user. If entry
+// exists, it overwrites it
+func (storage DBStorage) VoteOnRuleForOrg(
+	orgID types.OrgID,
+	ruleID types.RuleID,
+	errorKey types.ErrorKey,
+	userID types.UserID,
+	userVote types.UserVote,
+	voteMessage string,
+) error {
+	return storage.addOrUpdateUserFeedbackOnRuleForOrg(`
+		INSERT INTO org_rule_user_feedback
+		(org_id, rule_id, error_key, user_id, user_vote, message, added_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (org_id, rule_id, error_key, user_id)
+		DO UPDATE SET user_vote = $5, message = $6, updated_at = $8;`,
+		orgID, ruleID, errorKey, userID, userVote, voteMessage,
+	)
+}
+
+// AddOrUpdateFeedbackOnRuleForOrg adds feedback on rule for organization by
+// user. If entry exists, its message is overwritten and the vote is kept
+func (storage DBStorage) AddOrUpdateFeedbackOnRuleForOrg(
+	orgID types.OrgID,
+	ruleID types.RuleID,
+	errorKey types.ErrorKey,
+	userID types.UserID,
+	message string,
+) error {
+	return storage.addOrUpdateUserFeedbackOnRuleForOrg(`
+		INSERT INTO org_rule_user_feedback
+		(org_id, rule_id, error_key, user_id, user_vote, message, added_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (org_id, rule_id, error_key, user_id)
+		DO UPDATE SET message = $6, updated_at = $8;`,
+		orgID, ruleID, errorKey, userID, types.UserVoteNone, message,
+	)
+}
+
+// addOrUpdateUserFeedbackOnRuleForOrg executes the upsert of the feedback
+func (storage DBStorage) addOrUpdateUserFeedbackOnRuleForOrg(
+	query string,
+	orgID types.OrgID,
+	ruleID types.RuleID,
+	errorKey types.ErrorKey,
+	userID types.UserID,
+	userVote types.UserVote,
+	message string,
+) error {
+	if !storage.schemaAtLeast(orgRuleFeedbackVersion) {
+		return errOrgRuleFeedbackNotSupported
+	}
+
+	ctx, cancel := storage.operationContext(writeOperation)
+	defer cancel()
+
+	now := time.Now()
+
+	_, err := storage.connection.ExecContext(
+		ctx, query, orgID, ruleID, errorKey, userID, userVote, message, now, now,
+	)
+	err = types.ConvertDBError(err, orgID)
+	if err != nil {
+		log.Error().Err(err).Msg("addOrUpdateUserFeedbackOnRuleForOrg")
+		return err
+	}
+
+	metrics.FeedbackOnRules.Inc()
+
+	return nil
+}
+
+// GetUserFeedbackOnRuleForOrg gets feedback left by the user on rule for the
+// organization. ItemNotFoundError is returned when there is no feedback or
+// the database is not migrated yet.
+func (storage DBStorage) GetUserFeedbackOnRuleForOrg(
+	orgID types.OrgID, ruleID types.RuleID, errorKey types.ErrorKey, userID types.UserID,
+) (*OrgUserFeedbackOnRule, error) {
+	notFound := &types.ItemNotFoundError{
+		ItemID: fmt.Sprintf("%v/%v/%v/%v", orgID, ruleID, errorKey, userID),
+	}
+
+	if !storage.schemaAtLeast(orgRuleFeedbackVersion) {
+		return nil, notFound
+	}
+
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
+	feedback := OrgUserFeedbackOnRule{}
+
+	err := storage.readConnection().QueryRowContext(
+		ctx,
+		`SELECT org_id, rule_id, error_key, user_id, message, user_vote, added_at, updated_at
+		FROM org_rule_user_feedback
+		WHERE org_id = $1 AND rule_id = $2 AND error_key = $3 AND user_id = $4`,
+		orgID, ruleID, errorKey, userID,
+	).Scan(
+		&feedback.OrgID,
+		&feedback.RuleID,
+		&feedback.ErrorKey,
+		&feedback.UserID,
+		&feedback.Message,
+		&feedback.UserVote,
+		&feedback.AddedAt,
+		&feedback.UpdatedAt,
+	)
+
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, notFound
+	case err != nil:
+		return nil, types.ConvertDBError(err, orgID)
+	}
+
+	return &feedback, nil
+}
+
+// GetFeedbackOnRuleForOrg returns feedback left by all users on rule for the
+// organization, the most recently updated first. Nothing is returned before
+// the database is migrated.
+func (storage DBStorage) GetFeedbackOnRuleForOrg(
+	orgID types.OrgID, ruleID types.RuleID, errorKey types.ErrorKey,
+) ([]OrgUserFeedbackOnRule, error) {
+	feedbacks := make([]OrgUserFeedbackOnRule, 0)
+
+	if !storage.schemaAtLeast(orgRuleFeedbackVersion) {
+		return feedbacks, nil
+	}
+
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
+	rows, err := storage.readConnection().QueryContext(
+		ctx,
+		`SELECT org_id, rule_id, error_key, user_id, message, user_vote, added_at, updated_at
+		FROM org_rule_user_feedback
+		WHERE org_id = $1 AND rule_id = $2 AND error_key = $3
+		ORDER BY updated_at DESC, user_id`,
+		orgID, ruleID, errorKey,
+	)
+	if err != nil {
+		return feedbacks, types.ConvertDBError(err, orgID)
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var feedback OrgUserFeedbackOnRule
+
+		err = rows.Scan(
+			&feedback.OrgID,
+			&feedback.RuleID,
+			&feedback.ErrorKey,
+			&feedback.UserID,
+			&feedback.Message,
+			&feedback.UserVote,
+			&feedback.AddedAt,
+			&feedback.UpdatedAt,
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("GetFeedbackOnRuleForOrg")
+			return nil, types.ConvertDBError(err, orgID)
+		}
+
+		feedbacks = append(feedbacks, feedback)
+	}
+
+	return feedbacks, nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// TestDBStorage_VoteOnRuleForOrg checks that votes and feedback messages on
+// rule for organization are stored and overwritten
+func TestDBStorage_VoteOnRuleForOrg(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.VoteOnRuleForOrg(
+		testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID, types.UserVoteDislike, "noisy",
+	)
+	helpers.FailOnError(t, err)
+
+	// message is changed, the vote is kept
+	err = mockStorage.AddOrUpdateFeedbackOnRuleForOrg(
+		testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID, "noisy for our clusters",
+	)
+	helpers.FailOnError(t, err)
+
+	feedback, err := mockStorage.GetUserFeedbackOnRuleForOrg(
+		testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID,
+	)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.UserVoteDislike, feedback.UserVote)
+	assert.Equal(t, "noisy for our clusters", feedback.Message)
+
+	err = mockStorage.VoteOnRuleForOrg(
+		testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID, types.UserVoteLike, "",
+	)
+	helpers.FailOnError(t, err)
+
+	feedback, err = mockStorage.GetUserFeedbackOnRuleForOrg(
+		testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID,
+	)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.UserVoteLike, feedback.UserVote)
+	assert.Equal(t, "", feedback.Message)
+}
+
+// TestDBStorage_GetFeedbackOnRuleForOrg checks that feedback of all users of
+// the organization on the rule is returned
+func TestDBStorage_GetFeedbackOnRuleForOrg(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	feedbacks, err := mockStorage.GetFeedbackOnRuleForOrg(testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, feedbacks)
+
+	for _, userID := range []types.UserID{"1", "2"} {
+		err = mockStorage.VoteOnRuleForOrg(
+			testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1, userID, types.UserVoteLike, "",
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	// feedback for another organization and another rule
+	err = mockStorage.VoteOnRuleForOrg(
+		testdata.Org2ID, testdata.Rule1ID, testdata.ErrorKey1, "3", types.UserVoteLike, "",
+	)
+	helpers.FailOnError(t, err)
+	err = mockStorage.VoteOnRuleForOrg(
+		testdata.OrgID, testdata.Rule2ID, testdata.ErrorKey2, "3", types.UserVoteLike, "",
+	)
+	helpers.FailOnError(t, err)
+
+	feedbacks, err = mockStorage.GetFeedbackOnRuleForOrg(testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1)
+	helpers.FailOnError(t, err)
+	assert.Len(t, feedbacks, 2)
+}
+
+// TestDBStorage_GetUserFeedbackOnRuleForOrg_NotFound checks that missing
+// feedback is reported as not found
+func TestDBStorage_GetUserFeedbackOnRuleForOrg_NotFound(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	_, err := mockStorage.GetUserFeedbackOnRuleForOrg(
+		testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID,
+	)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
@@ -0,0 +1,97 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// UsageMonthFormat is the format of month the usage is accounted for
+const UsageMonthFormat = "2006-01"
+
+// UsageMonth returns the month the usage happening at the given time is
+// accounted for
+func UsageMonth(t time.Time) string {
+	return t.UTC().Format(UsageMonthFormat)
+}
+
+// AddOrgUsage adds the given numbers of processed messages, stored bytes and
+// served API calls to the usage of the organization in the current month
+func (storage DBStorage) AddOrgUsage(
+	orgID types.OrgID, messagesProcessed, bytesStored, apiCalls int64,
+) error {
+	ctx, cancel := storage.operationContext(writeOperation)
+	defer cancel()
+
+	_, err := storage.connection.ExecContext(ctx, `
+		INSERT INTO org_usage
+			(org_id, month, messages_processed, bytes_stored, api_calls)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (org_id, month) DO UPDATE SET
+			messages_processed = org_usage.messages_processed + $3,
+			bytes_stored = org_usage.bytes_stored + $4,
+			api_calls = org_usage.api_calls + $5;
+	`, orgID, UsageMonth(time.Now()), messagesProcessed, bytesStored, apiCalls)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to add organization usage")
+		return types.ConvertDBError(err, orgID)
+	}
+
+	return nil
+}
+
+// ReadOrgUsage returns usage of all organizations in the given month (in
+// YYYY-MM format) ordered by organization
+func (storage DBStorage) ReadOrgUsage(month string) ([]types.OrgUsage, error) {
+	ctx, cancel := storage.operationContext(aggregationOperation)
+	defer cancel()
+
+	usages := make([]types.OrgUsage, 0)
+
+	rows, err := storage.readConnection().QueryContext(ctx, `
+		SELECT org_id, month, messages_processed, bytes_stored, api_calls
+		FROM org_usage
+		WHERE month = $1
+		ORDER BY org_id;
+	`, month)
+	if err != nil {
+		return usages, types.ConvertDBError(err, month)
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var usage types.OrgUsage
+
+		err = rows.Scan(
+			&usage.OrgID,
+			&usage.Month,
+			&usage.MessagesProcessed,
+			&usage.BytesStored,
+			&usage.APICalls,
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("ReadOrgUsage")
+			return usages, types.ConvertDBError(err, month)
+		}
+
+		usages = append(usages, usage)
+	}
+
+	return usages, nil
+}
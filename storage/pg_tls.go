@@ -0,0 +1,94 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+)
+
+// PGSSLModes contains values of pg_sslmode option supported by the driver
+var PGSSLModes = []string{"disable", "require", "verify-ca", "verify-full"}
+
+// pgParam is a run-time parameter of PostgreSQL connection
+type pgParam struct {
+	name  string
+	value string
+}
+
+// pgTLSParams returns connection parameters configured by pg_sslmode,
+// pg_sslrootcert, pg_sslcert and pg_sslkey options
+func pgTLSParams(configuration Configuration) []pgParam {
+	var params []pgParam
+
+	for _, param := range []pgParam{
+		{"sslmode", configuration.PGSSLMode},
+		{"sslrootcert", configuration.PGSSLRootCert},
+		{"sslcert", configuration.PGSSLCert},
+		{"sslkey", configuration.PGSSLKey},
+	} {
+		if param.value != "" {
+			params = append(params, param)
+		}
+	}
+
+	return params
+}
+
+// ValidatePGTLS checks TLS options of the PostgreSQL connection: certificate
+// and key have to be set together and all configured files have to exist.
+// TLS parameters can't be set in pg_params when the options are used, the
+// driver would use the first one. The mode is validated by the caller, see
+// PGSSLModes. The returned error starts with the name of the wrong option.
+func ValidatePGTLS(configuration Configuration) error {
+	if configuration.PGSSLCert != "" && configuration.PGSSLKey == "" {
+		return errors.New("pg_sslkey has to be set together with pg_sslcert")
+	}
+	if configuration.PGSSLKey != "" && configuration.PGSSLCert == "" {
+		return errors.New("pg_sslcert has to be set together with pg_sslkey")
+	}
+
+	for _, file := range []struct {
+		option, name string
+	}{
+		{"pg_sslrootcert", configuration.PGSSLRootCert},
+		{"pg_sslcert", configuration.PGSSLCert},
+		{"pg_sslkey", configuration.PGSSLKey},
+	} {
+		if file.name == "" {
+			continue
+		}
+		if _, err := os.Stat(file.name); err != nil {
+			return fmt.Errorf("%s: %v", file.option, err)
+		}
+	}
+
+	params, err := url.ParseQuery(configuration.PGParams)
+	if err != nil {
+		return fmt.Errorf("pg_params: %v", err)
+	}
+
+	for _, param := range pgTLSParams(configuration) {
+		if _, found := params[param.name]; found {
+			return fmt.Errorf("pg_params can't contain %s when pg_%s is set", param.name, param.name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,248 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"database/sql"
+	sql_driver "database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/rds/rdsutils"
+)
+
+const (
+	// rdsIAMTokenRefreshInterval is the age of IAM auth token after which
+	// a new token is generated. RDS accepts the tokens for 15 minutes, so the
+	// token used for a new connection never expires during connecting.
+	rdsIAMTokenRefreshInterval = 10 * time.Minute
+	// defaultPGPort is used in IAM auth token when no port is configured
+	defaultPGPort = "5432"
+)
+
+// ValidatePGIAMAuth checks that the PostgreSQL connection configured to use
+// RDS IAM authentication contains everything the token is generated for
+func ValidatePGIAMAuth(configuration Configuration) error {
+	if configuration.PGURL == "" {
+		if configuration.PGUsername == "" {
+			return errors.New("pg_username is required by RDS IAM authentication")
+		}
+		return nil
+	}
+
+	if err := validatePGIAMAuthURL("pg_url", configuration.PGURL); err != nil {
+		return err
+	}
+
+	if configuration.PGReadReplicaURL != "" {
+		return validatePGIAMAuthURL("pg_read_replica_url", configuration.PGReadReplicaURL)
+	}
+
+	return nil
+}
+
+// validatePGIAMAuthURL checks that the connection URL configured in the given
+// option contains the user the token is generated for
+func validatePGIAMAuthURL(option, connection string) error {
+	if !isPGURL(connection) {
+		return fmt.Errorf("RDS IAM authentication requires %s in URL format", option)
+	}
+
+	// the URL was validated already
+	parsed, err := url.Parse(connection)
+	if err != nil || parsed.User.Username() == "" {
+		return fmt.Errorf("user in %s is required by RDS IAM authentication", option)
+	}
+
+	return nil
+}
+
+// rdsIAMTokenBuilder generates IAM auth token for the database user and RDS
+// endpoint (host:port)
+type rdsIAMTokenBuilder func(endpoint, user string) (string, error)
+
+// newRDSIAMTokenBuilder returns the builder signing the tokens by the
+// credentials from the default AWS credentials chain (env variables, shared
+// credentials file, IAM role of the pod or instance). The region is taken
+// from the AWS configuration (AWS_REGION env variable) when it's empty.
+func newRDSIAMTokenBuilder(region string) (rdsIAMTokenBuilder, error) {
+	awsConfig := aws.NewConfig()
+	if region != "" {
+		awsConfig = awsConfig.WithRegion(region)
+	}
+
+	awsSession, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	region = aws.StringValue(awsSession.Config.Region)
+	if region == "" {
+		return nil, errors.New("AWS region of RDS IAM authentication is not configured")
+	}
+
+	credentials := awsSession.Config.Credentials
+
+	return func(endpoint, user string) (string, error) {
+		return rdsutils.BuildAuthToken(endpoint, region, user, credentials)
+	}, nil
+}
+
+// rdsIAMToken is generated IAM auth token together with its creation time
+type rdsIAMToken struct {
+	token     string
+	createdAt time.Time
+}
+
+// rdsIAMTokens caches IAM auth tokens of every endpoint and user, so a new
+// token is not generated for every connection, and refreshes them before
+// they expire
+type rdsIAMTokens struct {
+	mutex  sync.Mutex
+	build  rdsIAMTokenBuilder
+	now    func() time.Time
+	tokens map[string]rdsIAMToken
+}
+
+// newRDSIAMTokens constructs the cache of tokens generated by the builder
+func newRDSIAMTokens(build rdsIAMTokenBuilder) *rdsIAMTokens {
+	return &rdsIAMTokens{
+		build:  build,
+		now:    time.Now,
+		tokens: map[string]rdsIAMToken{},
+	}
+}
+
+// token returns valid IAM auth token for the endpoint and user
+func (tokens *rdsIAMTokens) token(endpoint, user string) (string, error) {
+	tokens.mutex.Lock()
+	defer tokens.mutex.Unlock()
+
+	key := user + "@" + endpoint
+	now := tokens.now()
+
+	cached, found := tokens.tokens[key]
+	if found && now.Sub(cached.createdAt) < rdsIAMTokenRefreshInterval {
+		return cached.token, nil
+	}
+
+	token, err := tokens.build(endpoint, user)
+	if err != nil {
+		return "", fmt.Errorf("unable to generate RDS IAM auth token: %v", err)
+	}
+
+	tokens.tokens[key] = rdsIAMToken{token: token, createdAt: now}
+
+	return token, nil
+}
+
+// withToken returns the data source (PostgreSQL connection URL) with the
+// password replaced by IAM auth token of its host and user
+func (tokens *rdsIAMTokens) withToken(dataSource string) (string, error) {
+	if !isPGURL(dataSource) {
+		return "", errors.New("RDS IAM authentication requires PostgreSQL connection URL")
+	}
+
+	parsed, err := url.Parse(dataSource)
+	if err != nil {
+		// the error contains the data source
+		return "", errors.New("invalid PostgreSQL connection URL")
+	}
+
+	host, port := parsed.Hostname(), parsed.Port()
+	if port == "" {
+		port = defaultPGPort
+	}
+
+	user := parsed.User.Username()
+
+	token, err := tokens.token(net.JoinHostPort(host, port), user)
+	if err != nil {
+		return "", err
+	}
+
+	parsed.User = url.UserPassword(user, token)
+
+	return parsed.String(), nil
+}
+
+// rdsIAMAuthConnector opens connections to RDS PostgreSQL authenticated by
+// IAM auth tokens instead of static password. The token is checked only when
+// the connection is established, so open connections keep working after the
+// token expires and every new connection gets a fresh token.
+type rdsIAMAuthConnector struct {
+	driver      sql_driver.Driver
+	dataSources []string
+	tokens      *rdsIAMTokens
+}
+
+// Connect returns connection opened with the current IAM auth token, the
+// first read-write host is used when there are more hosts
+func (connector *rdsIAMAuthConnector) Connect(ctx context.Context) (sql_driver.Conn, error) {
+	dataSources := make([]string, 0, len(connector.dataSources))
+
+	for _, dataSource := range connector.dataSources {
+		withToken, err := connector.tokens.withToken(dataSource)
+		if err != nil {
+			return nil, err
+		}
+		dataSources = append(dataSources, withToken)
+	}
+
+	if len(dataSources) == 1 {
+		return connector.driver.Open(dataSources[0])
+	}
+
+	failover := failoverConnector{driver: connector.driver, dataSources: dataSources}
+	return failover.Connect(ctx)
+}
+
+// Driver returns the underlying driver
+func (connector *rdsIAMAuthConnector) Driver() sql_driver.Driver {
+	return connector.driver
+}
+
+// openRDSIAMAuthConnection opens the database authenticated by IAM auth
+// tokens generated in the given AWS region
+func openRDSIAMAuthConnection(driverName string, dataSources []string, region string) (*sql.DB, error) {
+	build, err := newRDSIAMTokenBuilder(region)
+	if err != nil {
+		return nil, err
+	}
+
+	// sql.Open doesn't connect to the database, it's the only way to get
+	// registered driver by its name
+	db, err := sql.Open(driverName, "")
+	if err != nil {
+		return nil, err
+	}
+	driver := db.Driver()
+	_ = db.Close()
+
+	return sql.OpenDB(&rdsIAMAuthConnector{
+		driver:      driver,
+		dataSources: dataSources,
+		tokens:      newRDSIAMTokens(build),
+	}), nil
+}
@@ -0,0 +1,72 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"database/sql"
+
+	"github.com/rs/zerolog/log"
+)
+
+// openReadReplica opens the connection to read-only replica configured in
+// pg_read_replica_url option. The replica uses the same schema, TLS options
+// and authentication as the primary.
+func openReadReplica(driverName string, configuration Configuration) (*sql.DB, error) {
+	dataSource, err := pgURLDataSource(configuration.PGReadReplicaURL, pgConnectionParams(configuration))
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().Msgf(
+		"Making connection to read replica of data storage, datasource=%s",
+		redactDataSource(dataSource),
+	)
+
+	if configuration.PGIAMAuth {
+		return openRDSIAMAuthConnection(driverName, []string{dataSource}, configuration.PGIAMRegion)
+	}
+
+	return sql.Open(driverName, dataSource)
+}
+
+// SetReadReplica sets the connection to read-only replica, read queries of
+// the REST API and exports are sent to it instead of the primary. Queries
+// the consumer and writes depend on (clusters last checked cache, Kafka
+// offsets, frozen organizations, API keys, organizations of clusters, schema
+// version) and maintenance queries always go to the primary, so replication
+// lag never affects them.
+func (storage *DBStorage) SetReadReplica(replica *sql.DB) {
+	storage.replica = replica
+}
+
+// Primary returns the storage reading from the primary even when the read
+// replica is configured. Reports cached by CachedStorage are read by it, so
+// replication lag can't put stale reports into the cache.
+func (storage DBStorage) Primary() Storage {
+	storage.replica = nil
+	return &storage
+}
+
+// readConnection returns the connection used by read queries of the REST
+// API, that is the read replica when configured and the primary otherwise
+func (storage DBStorage) readConnection() *sql.DB {
+	if storage.replica != nil {
+		return storage.replica
+	}
+
+	return storage.connection
+}
@@ -0,0 +1,327 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// ErrReportArchiveNotSet is returned when reports should be archived, but no
+// archive was set by SetReportArchive
+var ErrReportArchiveNotSet = errors.New("report archive is not set")
+
+// ArchivedReport is the report of the cluster together with its rule hits
+// moved from the database into the report archive
+type ArchivedReport struct {
+	OrgID       types.OrgID         `json:"org_id"`
+	ClusterName types.ClusterName   `json:"cluster"`
+	Report      types.ClusterReport `json:"report"`
+	// ReportedAt is zero for reports written by old versions of the service
+	ReportedAt    time.Time         `json:"reported_at"`
+	LastCheckedAt time.Time         `json:"last_checked_at"`
+	KafkaOffset   types.KafkaOffset `json:"kafka_offset"`
+	RuleHits      []ArchivedRuleHit `json:"rule_hits"`
+	ArchivedAt    time.Time         `json:"archived_at"`
+}
+
+// ArchivedRuleHit is one rule hit of the archived report
+type ArchivedRuleHit struct {
+	RuleFQDN     types.RuleID   `json:"rule_fqdn"`
+	ErrorKey     types.ErrorKey `json:"error_key"`
+	TemplateData string         `json:"template_data"`
+}
+
+// ReportArchive stores reports moved out of the database (see archive
+// package). One report per cluster is archived, archiving the cluster again
+// replaces its report.
+type ReportArchive interface {
+	WriteArchivedReport(report ArchivedReport) error
+	// ReadArchivedReport returns ItemNotFoundError when no report of the
+	// cluster is archived
+	ReadArchivedReport(clusterName types.ClusterName) (ArchivedReport, error)
+}
+
+// archiveCandidate is the report selected to be archived
+type archiveCandidate struct {
+	ArchivedReport
+	generation int64
+}
+
+// SetReportArchive sets the archive aged-out reports are moved into. Reports
+// not found in the database are looked up in the archive then.
+func (storage *DBStorage) SetReportArchive(archive ReportArchive) {
+	storage.reportArchive = archive
+}
+
+// ArchiveReportsNotCheckedSince moves at most limit reports of clusters that
+// were last checked before the given time into the report archive, the
+// oldest reports first. Every report is written into the archive before it is
+// deleted from the database together with its rule hits, the report is kept
+// in the database when a newer report of the cluster was written in the
+// meantime. Reports of frozen organizations are not archived. Records
+// referencing the report (user feedback, rule toggles) are deleted by the DB
+// cascade. Number of archived reports is returned.
+func (storage DBStorage) ArchiveReportsNotCheckedSince(threshold time.Time, limit int) (int, error) {
+	if storage.reportArchive == nil {
+		return 0, ErrReportArchiveNotSet
+	}
+
+	candidates, err := storage.readArchiveCandidates(threshold, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	archived := 0
+
+	for i := range candidates {
+		candidate := &candidates[i]
+
+		candidate.RuleHits, err = storage.readArchivedRuleHits(candidate)
+		if err == types.ErrReportInconsistent {
+			// the report was written in the meantime, it's not aged out
+			continue
+		}
+		if err != nil {
+			return archived, err
+		}
+
+		candidate.ArchivedAt = time.Now().UTC()
+
+		if err := storage.reportArchive.WriteArchivedReport(candidate.ArchivedReport); err != nil {
+			log.Error().Err(err).Str("cluster", string(candidate.ClusterName)).Msg("Unable to archive report")
+			return archived, err
+		}
+
+		deleted, err := storage.deleteArchivedReport(candidate, threshold)
+		if err != nil {
+			return archived, err
+		}
+
+		if deleted {
+			archived++
+			metrics.ArchivedReports.Inc()
+		}
+	}
+
+	return archived, nil
+}
+
+// readArchiveCandidates reads the oldest reports last checked before the
+// given time
+func (storage DBStorage) readArchiveCandidates(threshold time.Time, limit int) ([]archiveCandidate, error) {
+	ctx, cancel := storage.operationContext(aggregationOperation)
+	defer cancel()
+
+	// disable "G202 (CWE-89): SQL string concatenation"
+	// #nosec G202
+	rows, err := storage.connection.QueryContext(ctx, `
+		SELECT org_id, cluster, report, reported_at, last_checked_at, kafka_offset, `+storage.reportGenerationColumn()+`
+		FROM report
+		WHERE `+agedOutReportsCondition+`
+		ORDER BY last_checked_at
+		LIMIT $2;
+	`, threshold, limit)
+	if err != nil {
+		return nil, types.ConvertDBError(err, nil)
+	}
+	defer closeRows(rows)
+
+	candidates := make([]archiveCandidate, 0)
+
+	for rows.Next() {
+		var (
+			candidate                 archiveCandidate
+			reportedAt, lastCheckedAt types.NullTime
+			kafkaOffset               types.NullKafkaOffset
+		)
+
+		err := rows.Scan(
+			&candidate.OrgID,
+			&candidate.ClusterName,
+			&candidate.Report,
+			&reportedAt,
+			&lastCheckedAt,
+			&kafkaOffset,
+			&candidate.generation,
+		)
+		if err != nil {
+			return nil, types.ConvertDBError(err, nil)
+		}
+
+		candidate.ReportedAt = reportedAt.Time
+		candidate.LastCheckedAt = lastCheckedAt.Time
+		candidate.KafkaOffset = kafkaOffset.Offset
+
+		candidates = append(candidates, candidate)
+	}
+
+	return candidates, types.ConvertDBError(rows.Err(), nil)
+}
+
+// readArchivedRuleHits reads rule hits of the report selected to be archived
+func (storage DBStorage) readArchivedRuleHits(candidate *archiveCandidate) ([]ArchivedRuleHit, error) {
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
+	// disable "G202 (CWE-89): SQL string concatenation"
+	// #nosec G202
+	rows, err := storage.connection.QueryContext(ctx, `
+		SELECT rule_fqdn, error_key, template_data, `+storage.reportGenerationColumn()+`
+		FROM rule_hit
+		WHERE org_id = $1 AND cluster_id = $2
+		ORDER BY rule_fqdn, error_key;
+	`, candidate.OrgID, candidate.ClusterName)
+	if err != nil {
+		return nil, types.ConvertDBError(err, []interface{}{candidate.OrgID, candidate.ClusterName})
+	}
+	defer closeRows(rows)
+
+	ruleHits := make([]ArchivedRuleHit, 0)
+
+	for rows.Next() {
+		var (
+			ruleHit    ArchivedRuleHit
+			generation int64
+		)
+
+		err := rows.Scan(&ruleHit.RuleFQDN, &ruleHit.ErrorKey, &ruleHit.TemplateData, &generation)
+		if err != nil {
+			return nil, types.ConvertDBError(err, nil)
+		}
+
+		if generation != candidate.generation {
+			return nil, types.ErrReportInconsistent
+		}
+
+		ruleHits = append(ruleHits, ruleHit)
+	}
+
+	return ruleHits, types.ConvertDBError(rows.Err(), nil)
+}
+
+// deleteArchivedReport deletes the archived report and its rule hits. Nothing
+// is deleted and false is returned when the report of the cluster was
+// replaced by a newer one (generation of the report changed or it was checked
+// after the threshold) or when the organization was frozen in the meantime.
+func (storage DBStorage) deleteArchivedReport(candidate *archiveCandidate, threshold time.Time) (bool, error) {
+	orgID, clusterName := candidate.OrgID, candidate.ClusterName
+
+	storage.clusterLocks.Lock(clusterName)
+	defer storage.clusterLocks.Unlock(clusterName)
+
+	ctx, cancel := storage.operationContext(writeOperation)
+	defer cancel()
+
+	tx, err := storage.connection.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	var deleted bool
+
+	err = func(tx *sql.Tx) error {
+		err := storage.lockClusterInTransaction(tx, clusterName)
+		if err != nil {
+			return err
+		}
+
+		// disable "G202 (CWE-89): SQL string concatenation"
+		// #nosec G202
+		result, err := tx.Exec(
+			"DELETE FROM report WHERE org_id = $1 AND cluster = $2 AND last_checked_at < $3 AND "+
+				storage.reportGenerationColumn()+" = $4 AND org_id NOT IN (SELECT org_id FROM org_freeze);",
+			orgID, clusterName, threshold, candidate.generation,
+		)
+		if err != nil {
+			return err
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil || affected == 0 {
+			return err
+		}
+
+		_, err = tx.Exec(
+			"DELETE FROM rule_hit WHERE org_id = $1 AND cluster_id = $2;", orgID, clusterName,
+		)
+		deleted = err == nil
+
+		return err
+	}(tx)
+
+	finishTransaction(tx, err)
+
+	if err != nil {
+		return false, types.ConvertDBError(err, []interface{}{orgID, clusterName})
+	}
+
+	if deleted {
+		storage.clustersLastCheckedMutex.Lock()
+		delete(storage.clustersLastChecked, clusterName)
+		storage.clustersLastCheckedMutex.Unlock()
+
+		storage.invalidateCachedReport(clusterName)
+	}
+
+	return deleted, nil
+}
+
+// readArchivedReport reads the report of the cluster from the report
+// archive, nil is returned when no archive is set or the report of the
+// cluster is not archived
+func (storage DBStorage) readArchivedReport(clusterName types.ClusterName) (*ArchivedReport, error) {
+	if storage.reportArchive == nil {
+		return nil, nil
+	}
+
+	archived, err := storage.reportArchive.ReadArchivedReport(clusterName)
+	if _, notFound := err.(*types.ItemNotFoundError); notFound {
+		return nil, nil
+	}
+	if err != nil {
+		log.Error().Err(err).Str("cluster", string(clusterName)).Msg("Unable to read archived report")
+		return nil, err
+	}
+
+	return &archived, nil
+}
+
+// rules returns rule hits of the archived report
+func (archived *ArchivedReport) rules() []types.RuleOnReport {
+	rules := make([]types.RuleOnReport, 0, len(archived.RuleHits))
+
+	for _, ruleHit := range archived.RuleHits {
+		rules = append(rules, types.RuleOnReport{
+			Module:       ruleHit.RuleFQDN,
+			ErrorKey:     ruleHit.ErrorKey,
+			TemplateData: parseTemplateData([]byte(ruleHit.TemplateData)),
+		})
+	}
+
+	return rules
+}
+
+// lastCheckedTimestamp returns the time of the last check of the archived
+// report in the format used for reports read from the database
+func (archived *ArchivedReport) lastCheckedTimestamp() types.Timestamp {
+	return types.NullTime{Time: archived.LastCheckedAt, Valid: true}.Timestamp()
+}
@@ -0,0 +1,186 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// memoryReportArchive keeps archived reports in memory
+type memoryReportArchive struct {
+	mutex   sync.Mutex
+	reports map[types.ClusterName]storage.ArchivedReport
+}
+
+func newMemoryReportArchive() *memoryReportArchive {
+	return &memoryReportArchive{reports: make(map[types.ClusterName]storage.ArchivedReport)}
+}
+
+func (archive *memoryReportArchive) WriteArchivedReport(report storage.ArchivedReport) error {
+	archive.mutex.Lock()
+	defer archive.mutex.Unlock()
+
+	archive.reports[report.ClusterName] = report
+	return nil
+}
+
+func (archive *memoryReportArchive) ReadArchivedReport(clusterName types.ClusterName) (storage.ArchivedReport, error) {
+	archive.mutex.Lock()
+	defer archive.mutex.Unlock()
+
+	report, found := archive.reports[clusterName]
+	if !found {
+		return report, &types.ItemNotFoundError{ItemID: clusterName}
+	}
+	return report, nil
+}
+
+// mustGetArchivingStorage returns mock DB storage with the in-memory archive
+func mustGetArchivingStorage(t *testing.T) (*storage.DBStorage, *memoryReportArchive, func()) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	dbStorage := mockStorage.(*storage.DBStorage)
+
+	archive := newMemoryReportArchive()
+	dbStorage.SetReportArchive(archive)
+
+	return dbStorage, archive, closer
+}
+
+func TestDBStorage_ArchiveReportsNotCheckedSince(t *testing.T) {
+	dbStorage, archive, closer := mustGetArchivingStorage(t)
+	defer closer()
+
+	mustWriteClusterReport3Rules(t, dbStorage, testdata.ClusterName)
+
+	archived, err := dbStorage.ArchiveReportsNotCheckedSince(testdata.LastCheckedAt, 10)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 0, archived)
+
+	archived, err = dbStorage.ArchiveReportsNotCheckedSince(testdata.LastCheckedAt.Add(time.Hour), 10)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 1, archived)
+
+	count, err := dbStorage.ReportsCount()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 0, count)
+
+	report, err := archive.ReadArchivedReport(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.OrgID, report.OrgID)
+	assert.Equal(t, testdata.Report3Rules, report.Report)
+	assert.Equal(t, testdata.KafkaOffset, report.KafkaOffset)
+	assert.Len(t, report.RuleHits, 3)
+}
+
+func TestDBStorage_ArchiveReportsNotCheckedSinceLimit(t *testing.T) {
+	dbStorage, _, closer := mustGetArchivingStorage(t)
+	defer closer()
+
+	mustWriteClusterReport3Rules(t, dbStorage, testdata.ClusterName)
+	mustWriteClusterReport3Rules(t, dbStorage, testdata.GetRandomClusterID())
+
+	archived, err := dbStorage.ArchiveReportsNotCheckedSince(testdata.LastCheckedAt.Add(time.Hour), 1)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 1, archived)
+
+	count, err := dbStorage.ReportsCount()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestDBStorage_ArchiveReportsNotCheckedSinceFrozenOrg(t *testing.T) {
+	dbStorage, archive, closer := mustGetArchivingStorage(t)
+	defer closer()
+
+	mustWriteClusterReport3Rules(t, dbStorage, testdata.ClusterName)
+	helpers.FailOnError(t, dbStorage.FreezeOrg(testdata.OrgID, "legal hold"))
+
+	archived, err := dbStorage.ArchiveReportsNotCheckedSince(testdata.LastCheckedAt.Add(time.Hour), 10)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 0, archived)
+
+	count, err := dbStorage.ReportsCount()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 1, count)
+
+	_, err = archive.ReadArchivedReport(testdata.ClusterName)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
+
+func TestDBStorage_ReadArchivedReport(t *testing.T) {
+	dbStorage, _, closer := mustGetArchivingStorage(t)
+	defer closer()
+
+	mustWriteClusterReport3Rules(t, dbStorage, testdata.ClusterName)
+
+	rules, lastChecked, err := dbStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+
+	_, err = dbStorage.ArchiveReportsNotCheckedSince(testdata.LastCheckedAt.Add(time.Hour), 10)
+	helpers.FailOnError(t, err)
+
+	archivedRules, archivedLastChecked, err := dbStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.ElementsMatch(t, rules, archivedRules)
+	assert.Equal(t, lastChecked, archivedLastChecked)
+
+	archivedRules, _, err = dbStorage.ReadReportForClusterByClusterName(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.ElementsMatch(t, rules, archivedRules)
+
+	// the cluster is archived under another organization
+	_, _, err = dbStorage.ReadReportForCluster(testdata.Org2ID, testdata.ClusterName)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
+
+func TestDBStorage_ReadArchivedReportReplacedByNewReport(t *testing.T) {
+	dbStorage, _, closer := mustGetArchivingStorage(t)
+	defer closer()
+
+	mustWriteClusterReport3Rules(t, dbStorage, testdata.ClusterName)
+
+	_, err := dbStorage.ArchiveReportsNotCheckedSince(testdata.LastCheckedAt.Add(time.Hour), 10)
+	helpers.FailOnError(t, err)
+
+	// the cluster is checked again after the report was archived
+	err = dbStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report2Rules, testdata.Report2RulesParsed,
+		testdata.LastCheckedAt.Add(2*time.Hour), testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, 2, readRuleHitsCount(t, dbStorage, testdata.ClusterName))
+}
+
+func TestDBStorage_ArchiveReportsNotSet(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	_, err := mockStorage.ArchiveReportsNotCheckedSince(time.Now(), 10)
+	assert.Equal(t, storage.ErrReportArchiveNotSet, err)
+
+	_, _, err = mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
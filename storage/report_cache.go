@@ -0,0 +1,168 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// reportVersion identifies one write of the report of the cluster. The
+// generation changes with every write of the report (by any replica of the
+// service), the time of the check protects the cache against reports deleted
+// and written again.
+type reportVersion struct {
+	generation  int64
+	lastChecked time.Time
+}
+
+// reportCacheEntry contains parsed rule hits of one version of the report of
+// the cluster
+type reportCacheEntry struct {
+	clusterName types.ClusterName
+	version     reportVersion
+	rules       []types.RuleOnReport
+}
+
+// reportCache is LRU cache of rule hits of reports with parsed template data,
+// so template data of frequently read reports are not parsed on every read.
+// Only the last read version of the report of every cluster is cached.
+type reportCache struct {
+	mutex   sync.Mutex
+	size    int
+	lru     *list.List
+	entries map[types.ClusterName]*list.Element
+}
+
+// newReportCache creates cache of the given number of reports
+func newReportCache(size int) *reportCache {
+	return &reportCache{
+		size:    size,
+		lru:     list.New(),
+		entries: make(map[types.ClusterName]*list.Element),
+	}
+}
+
+// get returns rule hits of the version of the report of the cluster
+func (cache *reportCache) get(clusterName types.ClusterName, version reportVersion) ([]types.RuleOnReport, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	element, found := cache.entries[clusterName]
+	if !found || !element.Value.(*reportCacheEntry).version.equal(version) {
+		metrics.ReportCacheMisses.Inc()
+		return nil, false
+	}
+
+	metrics.ReportCacheHits.Inc()
+	cache.lru.MoveToFront(element)
+
+	// callers can modify the returned rule hits
+	return copyRules(element.Value.(*reportCacheEntry).rules), true
+}
+
+// put stores rule hits of the version of the report of the cluster replacing
+// any other version, the least recently used report is evicted when the cache
+// is full
+func (cache *reportCache) put(clusterName types.ClusterName, version reportVersion, rules []types.RuleOnReport) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	entry := &reportCacheEntry{clusterName: clusterName, version: version, rules: copyRules(rules)}
+
+	if element, found := cache.entries[clusterName]; found {
+		element.Value = entry
+		cache.lru.MoveToFront(element)
+		return
+	}
+
+	cache.entries[clusterName] = cache.lru.PushFront(entry)
+
+	for cache.lru.Len() > cache.size {
+		oldest := cache.lru.Back()
+		cache.lru.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*reportCacheEntry).clusterName)
+	}
+}
+
+// invalidate removes the report of the cluster from the cache
+func (cache *reportCache) invalidate(clusterName types.ClusterName) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if element, found := cache.entries[clusterName]; found {
+		cache.lru.Remove(element)
+		delete(cache.entries, clusterName)
+	}
+}
+
+// equal checks whether both versions identify the same write of the report
+func (version reportVersion) equal(other reportVersion) bool {
+	return version.generation == other.generation && version.lastChecked.Equal(other.lastChecked)
+}
+
+// copyRules returns shallow copy of the rule hits
+func copyRules(rules []types.RuleOnReport) []types.RuleOnReport {
+	return append(make([]types.RuleOnReport, 0, len(rules)), rules...)
+}
+
+// SetReportCacheSize sets the number of reports whose parsed rule hits are
+// cached in memory, 0 disables the cache
+func (storage *DBStorage) SetReportCacheSize(size int) {
+	if size <= 0 {
+		storage.reportCache = nil
+		return
+	}
+
+	storage.reportCache = newReportCache(size)
+}
+
+// cachedReportRules returns cached rule hits of the version of the report.
+// Nothing is cached before the database is migrated, because the generation
+// doesn't change when the report is written by another replica then.
+func (storage DBStorage) cachedReportRules(
+	clusterName types.ClusterName, version reportVersion,
+) ([]types.RuleOnReport, bool) {
+	if storage.reportCache == nil || !storage.schemaAtLeast(reportGenerationVersion) {
+		return nil, false
+	}
+
+	return storage.reportCache.get(clusterName, version)
+}
+
+// cacheReportRules stores rule hits of the version of the report
+func (storage DBStorage) cacheReportRules(
+	clusterName types.ClusterName, version reportVersion, rules []types.RuleOnReport,
+) {
+	if storage.reportCache == nil || !storage.schemaAtLeast(reportGenerationVersion) {
+		return
+	}
+
+	storage.reportCache.put(clusterName, version, rules)
+}
+
+// invalidateCachedReport removes cached rule hits of the report of the
+// cluster after it was written or deleted
+func (storage DBStorage) invalidateCachedReport(clusterName types.ClusterName) {
+	if storage.reportCache != nil {
+		storage.reportCache.invalidate(clusterName)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mustWriteClusterReport3Rules writes the report of the cluster with 3 rule hits
+func mustWriteClusterReport3Rules(t *testing.T, mockStorage storage.Storage, clusterName types.ClusterName) {
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, clusterName, testdata.Report3Rules, testdata.Report3RulesParsed,
+		testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+}
+
+// mustDeleteRuleHitsBehindStorage deletes rule hits of the cluster directly
+// in the database, so the storage doesn't know about it
+func mustDeleteRuleHitsBehindStorage(t *testing.T, dbStorage *storage.DBStorage, clusterName types.ClusterName) {
+	_, err := dbStorage.GetConnection().Exec("DELETE FROM rule_hit WHERE cluster_id = $1;", clusterName)
+	helpers.FailOnError(t, err)
+}
+
+// readRuleHitsCount returns the number of rule hits of the report of the
+// cluster
+func readRuleHitsCount(t *testing.T, mockStorage storage.Storage, clusterName types.ClusterName) int {
+	rules, _, err := mockStorage.ReadReportForCluster(testdata.OrgID, clusterName)
+	helpers.FailOnError(t, err)
+
+	return len(rules)
+}
+
+func TestDBStorage_ReportCache(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+	dbStorage.SetReportCacheSize(10)
+
+	mustWriteClusterReport3Rules(t, mockStorage, testdata.ClusterName)
+	assert.Equal(t, 3, readRuleHitsCount(t, mockStorage, testdata.ClusterName))
+
+	// the second read is served from the cache
+	mustDeleteRuleHitsBehindStorage(t, dbStorage, testdata.ClusterName)
+	assert.Equal(t, 3, readRuleHitsCount(t, mockStorage, testdata.ClusterName))
+
+	rules, _, err := mockStorage.ReadReportForClusterByClusterName(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Len(t, rules, 3)
+
+	// the write invalidates the cache
+	err = mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.ClusterReportEmpty, testdata.ReportEmptyRulesParsed,
+		testdata.LastCheckedAt.Add(time.Hour), testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 0, readRuleHitsCount(t, mockStorage, testdata.ClusterName))
+}
+
+func TestDBStorage_ReportCacheDisabled(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+
+	mustWriteClusterReport3Rules(t, mockStorage, testdata.ClusterName)
+	assert.Equal(t, 3, readRuleHitsCount(t, mockStorage, testdata.ClusterName))
+
+	mustDeleteRuleHitsBehindStorage(t, dbStorage, testdata.ClusterName)
+	assert.Equal(t, 0, readRuleHitsCount(t, mockStorage, testdata.ClusterName))
+}
+
+// TestDBStorage_ReportCacheEviction checks that the least recently read
+// report is evicted when the cache is full
+func TestDBStorage_ReportCacheEviction(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+	dbStorage.SetReportCacheSize(1)
+
+	cluster2 := testdata.GetRandomClusterID()
+
+	mustWriteClusterReport3Rules(t, mockStorage, testdata.ClusterName)
+	mustWriteClusterReport3Rules(t, mockStorage, cluster2)
+
+	assert.Equal(t, 3, readRuleHitsCount(t, mockStorage, testdata.ClusterName))
+	assert.Equal(t, 3, readRuleHitsCount(t, mockStorage, cluster2))
+
+	mustDeleteRuleHitsBehindStorage(t, dbStorage, testdata.ClusterName)
+	mustDeleteRuleHitsBehindStorage(t, dbStorage, cluster2)
+
+	assert.Equal(t, 0, readRuleHitsCount(t, mockStorage, testdata.ClusterName))
+	assert.Equal(t, 0, readRuleHitsCount(t, mockStorage, cluster2))
+}
@@ -0,0 +1,126 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// SetCheckHistorySize sets the number of the last checks of every cluster
+// whose statistics are kept, 0 disables the statistics
+func (storage *DBStorage) SetCheckHistorySize(size int) {
+	storage.checkHistorySize = size
+}
+
+// writeReportCheck stores statistics of the check of the cluster and deletes
+// statistics of checks exceeding the configured number of checks. Nothing is
+// done when the statistics are disabled or the database is not migrated yet.
+func (storage DBStorage) writeReportCheck(
+	tx *sql.Tx,
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+	checkedAt time.Time,
+	changes ruleHitChanges,
+) error {
+	if storage.checkHistorySize <= 0 || !storage.schemaAtLeast(reportCheckVersion) {
+		return nil
+	}
+
+	// the same report can be written again
+	_, err := tx.Exec(`
+		INSERT INTO report_check (org_id, cluster_id, checked_at, hit_count, added_count, removed_count)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (cluster_id, checked_at) DO UPDATE SET
+			org_id = $1, hit_count = $4, added_count = $5, removed_count = $6;
+	`, orgID, clusterName, checkedAt, changes.hitCount, changes.added, changes.removed)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM report_check
+		WHERE cluster_id = $1 AND checked_at NOT IN (
+			SELECT checked_at FROM report_check
+			WHERE cluster_id = $1
+			ORDER BY checked_at DESC
+			LIMIT $2
+		);
+	`, clusterName, storage.checkHistorySize)
+
+	return err
+}
+
+// ReadReportChecks returns statistics of the last checks of the cluster,
+// limit 0 means that all stored checks are returned. The oldest check goes
+// first.
+func (storage DBStorage) ReadReportChecks(
+	clusterName types.ClusterName, limit int,
+) ([]types.ReportCheck, error) {
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
+	checks := make([]types.ReportCheck, 0)
+
+	if !storage.schemaAtLeast(reportCheckVersion) {
+		return checks, nil
+	}
+
+	query := `
+		SELECT checked_at, hit_count, added_count, removed_count FROM report_check
+		WHERE cluster_id = $1
+		ORDER BY checked_at DESC
+	`
+	args := []interface{}{clusterName}
+
+	if limit > 0 {
+		query += " LIMIT $2"
+		args = append(args, limit)
+	}
+
+	rows, err := storage.readConnection().QueryContext(ctx, query, args...)
+	if err != nil {
+		return checks, types.ConvertDBError(err, clusterName)
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var (
+			check     types.ReportCheck
+			checkedAt time.Time
+		)
+
+		err = rows.Scan(&checkedAt, &check.HitCount, &check.Added, &check.Removed)
+		if err != nil {
+			log.Error().Err(err).Msg("ReadReportChecks")
+			return checks, types.ConvertDBError(err, clusterName)
+		}
+
+		check.CheckedAt = types.Timestamp(checkedAt.UTC().Format(time.RFC3339))
+		checks = append(checks, check)
+	}
+
+	// the newest checks are selected, but they are returned in the order
+	// they were done
+	for i, j := 0, len(checks)-1; i < j; i, j = i+1, j-1 {
+		checks[i], checks[j] = checks[j], checks[i]
+	}
+
+	return checks, rows.Err()
+}
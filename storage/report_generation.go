@@ -0,0 +1,53 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// writeReportGeneration starts new generation of the report of the cluster
+// and marks all its rule hits as members of that generation. It has to be
+// called in the transaction writing the report, after the rule hits are
+// written, so the rule hits and the report row always share the generation
+// once the transaction is committed.
+func writeReportGeneration(tx *sql.Tx, clusterName types.ClusterName) error {
+	_, err := tx.Exec(
+		"UPDATE report SET generation = generation + 1 WHERE cluster = $1;", clusterName,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE rule_hit SET generation = (SELECT generation FROM report WHERE cluster = $1)
+		WHERE cluster_id = $1;`, clusterName,
+	)
+
+	return err
+}
+
+// reportGenerationColumn returns expression used to read the generation of
+// the report and its rule hits. All rows belong to the same generation before
+// the database is migrated.
+func (storage DBStorage) reportGenerationColumn() string {
+	if storage.schemaAtLeast(reportGenerationVersion) {
+		return "generation"
+	}
+
+	return "0"
+}
@@ -0,0 +1,181 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// reportHistoryUpsert writes the historical report of the cluster, the same
+// report can be uploaded again
+var reportHistoryUpsert = upsertQuery{
+	table:           "report_history",
+	columns:         []string{"org_id", "cluster_id", "report", "last_checked_at", "reported_at", "kafka_offset"},
+	conflictColumns: []string{"cluster_id", "last_checked_at"},
+	updateColumns:   []string{"org_id", "report", "reported_at", "kafka_offset"},
+}
+
+// SetReportHistorySize sets the number of the last reports of every cluster
+// kept in the history of reports, 0 disables storing of written reports into
+// the history
+func (storage *DBStorage) SetReportHistorySize(size int) {
+	storage.reportHistorySize = size
+}
+
+// WriteReportHistory stores the report of the cluster into the history of
+// its reports. Unlike WriteReportForCluster, the report can be older than the
+// latest report of the cluster, it's used for reports of disconnected
+// clusters uploaded in bulk, for example. The latest report of the cluster
+// is not touched.
+func (storage DBStorage) WriteReportHistory(
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+	report types.ClusterReport,
+	lastCheckedTime time.Time,
+	kafkaOffset types.KafkaOffset,
+) error {
+	if !storage.schemaAtLeast(reportHistoryVersion) {
+		return fmt.Errorf("history of reports is not supported before DB migration %d", reportHistoryVersion)
+	}
+
+	ctx, cancel := storage.operationContext(writeOperation)
+	defer cancel()
+
+	tx, err := storage.connection.BeginTx(ctx, nil)
+	if err != nil {
+		return types.ConvertDBError(err, nil)
+	}
+
+	err = writeReportHistory(tx, storage.dbDriverType, orgID, clusterName, report, lastCheckedTime, kafkaOffset)
+
+	finishTransaction(tx, err)
+
+	return types.ConvertDBError(err, []interface{}{orgID, clusterName})
+}
+
+// writeReportHistory stores the report of the cluster into the history
+func writeReportHistory(
+	tx *sql.Tx,
+	dbDriverType types.DBDriver,
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+	report types.ClusterReport,
+	lastCheckedTime time.Time,
+	kafkaOffset types.KafkaOffset,
+) error {
+	return reportHistoryUpsert.exec(tx, dbDriverType, []interface{}{
+		orgID, clusterName, report, lastCheckedTime, time.Now(), kafkaOffset,
+	})
+}
+
+// writeLatestReportHistory stores the written report of the cluster into the
+// history and deletes reports exceeding the configured number of reports,
+// historical reports uploaded by WriteReportHistory included. Nothing is
+// done when the history is disabled or the database is not migrated yet.
+func (storage DBStorage) writeLatestReportHistory(
+	tx *sql.Tx,
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+	report types.ClusterReport,
+	lastCheckedTime time.Time,
+	kafkaOffset types.KafkaOffset,
+) error {
+	if storage.reportHistorySize <= 0 || !storage.schemaAtLeast(reportHistoryVersion) {
+		return nil
+	}
+
+	err := writeReportHistory(tx, storage.dbDriverType, orgID, clusterName, report, lastCheckedTime, kafkaOffset)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM report_history
+		WHERE cluster_id = $1 AND last_checked_at NOT IN (
+			SELECT last_checked_at FROM report_history
+			WHERE cluster_id = $1
+			ORDER BY last_checked_at DESC
+			LIMIT $2
+		);
+	`, clusterName, storage.reportHistorySize)
+
+	return err
+}
+
+// ReadReportHistoryForCluster returns reports of the cluster stored in the
+// history whose time of the last check is in the range from (inclusive) to
+// (exclusive), zero times mean that the range is not limited. The oldest
+// report goes first. Empty history is returned before the database is
+// migrated.
+func (storage DBStorage) ReadReportHistoryForCluster(
+	orgID types.OrgID, clusterName types.ClusterName, from, to time.Time,
+) ([]types.HistoricalReport, error) {
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
+	reports := make([]types.HistoricalReport, 0)
+
+	if !storage.schemaAtLeast(reportHistoryVersion) {
+		return reports, nil
+	}
+
+	query := `
+		SELECT report, last_checked_at, reported_at FROM report_history
+		WHERE org_id = $1 AND cluster_id = $2
+	`
+	args := []interface{}{orgID, clusterName}
+
+	if !from.IsZero() {
+		args = append(args, from)
+		query += fmt.Sprintf(" AND last_checked_at >= $%d", len(args))
+	}
+	if !to.IsZero() {
+		args = append(args, to)
+		query += fmt.Sprintf(" AND last_checked_at < $%d", len(args))
+	}
+	query += " ORDER BY last_checked_at;"
+
+	rows, err := storage.readConnection().QueryContext(ctx, query, args...)
+	if err != nil {
+		return reports, types.ConvertDBError(err, []interface{}{orgID, clusterName})
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var (
+			report      types.HistoricalReport
+			lastChecked time.Time
+			reportedAt  time.Time
+		)
+
+		err = rows.Scan(&report.Report, &lastChecked, &reportedAt)
+		if err != nil {
+			log.Error().Err(err).Msg("ReadReportHistoryForCluster")
+			return reports, types.ConvertDBError(err, []interface{}{orgID, clusterName})
+		}
+
+		report.LastCheckedAt = types.Timestamp(lastChecked.UTC().Format(time.RFC3339))
+		report.ReportedAt = types.Timestamp(reportedAt.UTC().Format(time.RFC3339))
+		reports = append(reports, report)
+	}
+
+	return reports, rows.Err()
+}
@@ -0,0 +1,99 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// emptyClusterReport is stored for the cluster whose first report couldn't
+// be analyzed
+const emptyClusterReport = types.ClusterReport(
+	`{"fingerprints": [], "info": [], "reports": [], "skips": [], "system": {}}`,
+)
+
+// failedReportUpsert records failed analysis of the cluster, the report and
+// rule hits from the last successful analysis are kept
+var failedReportUpsert = upsertQuery{
+	table:           "report",
+	columns:         []string{"org_id", "cluster", "report", "reported_at", "last_checked_at", "kafka_offset", "status"},
+	conflictColumns: []string{"cluster"},
+	updateColumns:   []string{"org_id", "reported_at", "last_checked_at", "kafka_offset", "status"},
+}
+
+// WriteFailedReportForCluster records that the analysis of the cluster
+// failed. Rule hits of the cluster are not changed, so the results of the
+// last successful analysis are still available (unless the cluster is moved
+// from another organization, see ClusterOrgConflictPolicies).
+func (storage DBStorage) WriteFailedReportForCluster(
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+	lastCheckedTime time.Time,
+	kafkaOffset types.KafkaOffset,
+) error {
+	var orgChange *types.ClusterOrgChange
+
+	err := storage.writeClusterReport(orgID, clusterName, lastCheckedTime, func(tx *sql.Tx) error {
+		var err error
+
+		orgChange, err = storage.resolveClusterOrgConflict(tx, orgID, clusterName, lastCheckedTime)
+		if err != nil {
+			return err
+		}
+
+		reportedAtTime := time.Now()
+
+		err = failedReportUpsert.exec(tx, storage.dbDriverType, []interface{}{
+			orgID, clusterName, emptyClusterReport, reportedAtTime, lastCheckedTime, kafkaOffset, types.ReportStatusFailed,
+		})
+		if err != nil {
+			log.Err(err).Msgf("Unable to record failed analysis of the cluster (org: %v, cluster: %v)", orgID, clusterName)
+			return err
+		}
+
+		return updateOrgInfo(tx, orgID, reportedAtTime)
+	})
+
+	publishClusterOrgConflict(orgChange, lastCheckedTime, err)
+
+	return err
+}
+
+// ReadReportStatusForCluster returns the status of the analysis of the last
+// report of the cluster. ItemNotFoundError is returned when no report was
+// received from the cluster yet.
+func (storage DBStorage) ReadReportStatusForCluster(
+	orgID types.OrgID, clusterName types.ClusterName,
+) (types.ReportStatus, error) {
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
+	var status types.ReportStatus
+
+	err := storage.readConnection().QueryRowContext(
+		ctx,
+		"SELECT status FROM report WHERE org_id = $1 AND cluster = $2;", orgID, clusterName,
+	).Scan(&status)
+	if err != nil {
+		return types.ReportStatusNoData, types.ConvertDBError(err, []interface{}{orgID, clusterName})
+	}
+
+	return status, nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"errors"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// errDuplicateReport is returned by writeClusterReportIfNewer when the report
+// with the same time of the last check is already stored. It's counted
+// separately from old reports, callers get ErrOldReport anyway.
+var errDuplicateReport = errors.New("the same report is already stored")
+
+// reportWriteResult returns the result of the write of the report counted by
+// metrics.WrittenReportsByResult. Reports rejected because of the conflict of
+// organizations are counted as failed validation.
+func reportWriteResult(err error) string {
+	var validationError *types.ValidationError
+
+	switch {
+	case err == nil:
+		return metrics.ReportWriteWritten
+	case err == errDuplicateReport:
+		return metrics.ReportWriteDuplicate
+	case err == types.ErrOldReport:
+		return metrics.ReportWriteSkippedOld
+	case err == types.ErrClusterOrgConflict, errors.As(err, &validationError):
+		return metrics.ReportWriteValidationFailed
+	default:
+		return metrics.ReportWriteDBError
+	}
+}
+
+// recordReportWrite counts the result of the write of the report and returns
+// the error for callers, ErrOldReport is returned for duplicate reports
+func recordReportWrite(err error) error {
+	metrics.WrittenReportsByResult.WithLabelValues(reportWriteResult(err)).Inc()
+
+	return oldReportError(err)
+}
+
+// oldReportError replaces errDuplicateReport by ErrOldReport
+func oldReportError(err error) error {
+	if err == errDuplicateReport {
+		return types.ErrOldReport
+	}
+
+	return err
+}
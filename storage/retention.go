@@ -0,0 +1,213 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// RetentionPolicy describes data deleted by CleanupOldData, data are kept
+// when the time is zero
+type RetentionPolicy struct {
+	// ReportsNotCheckedSince deletes reports of clusters that were last
+	// checked before the given time, see DeleteReportsNotCheckedSince
+	ReportsNotCheckedSince time.Time
+	// ConsumerErrorsConsumedBefore deletes messages stored in consumer_error
+	// table that were consumed before the given time
+	ConsumerErrorsConsumedBefore time.Time
+}
+
+// CleanupOldData deletes data according to the retention policy and returns
+// numbers of deleted rows by table (report and consumer_error), rows related
+// to deleted reports are not counted. The numbers are added to pruned_rows
+// metric too.
+func (storage DBStorage) CleanupOldData(policy RetentionPolicy) (map[string]int, error) {
+	pruned := make(map[string]int)
+
+	if !policy.ReportsNotCheckedSince.IsZero() {
+		deleted, err := storage.DeleteReportsNotCheckedSince(policy.ReportsNotCheckedSince)
+		if err != nil {
+			return pruned, err
+		}
+
+		pruned["report"] = deleted
+		metrics.PrunedRows.WithLabelValues("report").Add(float64(deleted))
+	}
+
+	if !policy.ConsumerErrorsConsumedBefore.IsZero() {
+		deleted, err := storage.deleteConsumerErrorsConsumedBefore(policy.ConsumerErrorsConsumedBefore)
+		if err != nil {
+			return pruned, err
+		}
+
+		pruned["consumer_error"] = deleted
+		metrics.PrunedRows.WithLabelValues("consumer_error").Add(float64(deleted))
+	}
+
+	return pruned, nil
+}
+
+// deleteConsumerErrorsConsumedBefore deletes messages that were consumed
+// before the given time from consumer_error table and returns their number
+func (storage DBStorage) deleteConsumerErrorsConsumedBefore(threshold time.Time) (int, error) {
+	ctx, cancel := storage.operationContext(aggregationOperation)
+	defer cancel()
+
+	result, err := storage.connection.ExecContext(
+		ctx, "DELETE FROM consumer_error WHERE consumed_at < $1;", threshold,
+	)
+	if err != nil {
+		return 0, types.ConvertDBError(err, nil)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(deleted), nil
+}
+
+// agedOutReportsCondition selects reports last checked before the threshold
+// passed as $1, reports of frozen organizations are never aged out
+const agedOutReportsCondition = "last_checked_at < $1 AND org_id NOT IN (SELECT org_id FROM org_freeze)"
+
+// DeleteReportsNotCheckedSince deletes reports of all clusters that were
+// last checked before the given time together with their rule hits, rule
+// hits history and resolutions, annotations, stale report writes,
+// statistics of checks, historical reports, changes of organizations and
+// external results. Reports of frozen organizations are kept.
+// Records referencing the report (user feedback, rule toggles) are deleted
+// by the DB cascade. Number of deleted reports is returned.
+func (storage DBStorage) DeleteReportsNotCheckedSince(threshold time.Time) (int, error) {
+	ctx, cancel := storage.operationContext(aggregationOperation)
+	defer cancel()
+
+	tx, err := storage.connection.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		deleted      int64
+		clusterNames []types.ClusterName
+	)
+
+	tables := []string{"rule_hit", "rule_hit_history", "rule_hit_resolution", "cluster_annotation", "stale_report_write"}
+	if storage.schemaAtLeast(reportCheckVersion) {
+		tables = append(tables, "report_check")
+	}
+	if storage.schemaAtLeast(reportHistoryVersion) {
+		tables = append(tables, "report_history")
+	}
+	if storage.schemaAtLeast(clusterOrgChangeVersion) {
+		tables = append(tables, "cluster_org_change")
+	}
+	if storage.schemaAtLeast(externalResultVersion) {
+		tables = append(tables, "external_result", "external_report")
+	}
+
+	err = func(tx *sql.Tx) error {
+		var err error
+
+		clusterNames, err = selectAgedOutClusters(tx, threshold)
+		if err != nil {
+			return err
+		}
+
+		for _, table := range tables {
+			// disable "G202 (CWE-89): SQL string concatenation"
+			// #nosec G202
+			_, err := tx.Exec(
+				"DELETE FROM "+table+" WHERE cluster_id IN (SELECT cluster FROM report WHERE "+agedOutReportsCondition+");",
+				threshold,
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		// disable "G202 (CWE-89): SQL string concatenation"
+		// #nosec G202
+		result, err := tx.Exec("DELETE FROM report WHERE "+agedOutReportsCondition+";", threshold)
+		if err != nil {
+			return err
+		}
+
+		deleted, err = result.RowsAffected()
+		return err
+	}(tx)
+
+	finishTransaction(tx, err)
+
+	if err != nil {
+		return 0, types.ConvertDBError(err, nil)
+	}
+
+	storage.clustersLastCheckedMutex.Lock()
+	for _, clusterName := range clusterNames {
+		delete(storage.clustersLastChecked, clusterName)
+	}
+	storage.clustersLastCheckedMutex.Unlock()
+
+	return int(deleted), nil
+}
+
+// selectAgedOutClusters returns clusters whose reports are deleted by
+// DeleteReportsNotCheckedSince
+func selectAgedOutClusters(tx *sql.Tx, threshold time.Time) ([]types.ClusterName, error) {
+	// disable "G202 (CWE-89): SQL string concatenation"
+	// #nosec G202
+	rows, err := tx.Query("SELECT cluster FROM report WHERE "+agedOutReportsCondition+";", threshold)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	clusterNames := make([]types.ClusterName, 0)
+
+	for rows.Next() {
+		var clusterName types.ClusterName
+		if err := rows.Scan(&clusterName); err != nil {
+			return nil, err
+		}
+
+		clusterNames = append(clusterNames, clusterName)
+	}
+
+	return clusterNames, rows.Err()
+}
+
+// CountClustersNotCheckedSince returns number of clusters that were last
+// checked before the given time, clusters of frozen organizations are not
+// counted
+func (storage DBStorage) CountClustersNotCheckedSince(threshold time.Time) (int, error) {
+	ctx, cancel := storage.operationContext(aggregationOperation)
+	defer cancel()
+
+	count := -1
+	// disable "G202 (CWE-89): SQL string concatenation"
+	// #nosec G202
+	err := storage.connection.QueryRowContext(
+		ctx,
+		"SELECT count(*) FROM report WHERE "+agedOutReportsCondition+";", threshold,
+	).Scan(&count)
+
+	return count, types.ConvertDBError(err, nil)
+}
@@ -71,6 +71,9 @@ func (storage DBStorage) addOrUpdateUserFeedbackOnRuleForCluster(
 	userVotePtr *types.UserVote,
 	messagePtr *string,
 ) error {
+	ctx, cancel := storage.operationContext(writeOperation)
+	defer cancel()
+
 	updateVote := false
 	updateMessage := false
 	userVote := types.UserVoteNone
@@ -92,7 +95,7 @@ func (storage DBStorage) addOrUpdateUserFeedbackOnRuleForCluster(
 		return err
 	}
 
-	statement, err := storage.connection.Prepare(query)
+	statement, err := storage.connection.PrepareContext(ctx, query)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable to prepare statement")
 		return err
@@ -106,7 +109,7 @@ func (storage DBStorage) addOrUpdateUserFeedbackOnRuleForCluster(
 
 	now := time.Now()
 
-	_, err = statement.Exec(clusterID, ruleID, userID, userVote, now, now, message, errorKey)
+	_, err = statement.ExecContext(ctx, clusterID, ruleID, userID, userVote, now, now, message, errorKey)
 	err = types.ConvertDBError(err, nil)
 	if err != nil {
 		log.Error().Err(err).Msg("addOrUpdateUserFeedbackOnRuleForCluster")
@@ -155,9 +158,13 @@ func (storage DBStorage) constructUpsertClusterRuleUserFeedback(updateVote bool,
 func (storage DBStorage) GetUserFeedbackOnRule(
 	clusterID types.ClusterName, ruleID types.RuleID, errorKey types.ErrorKey, userID types.UserID,
 ) (*UserFeedbackOnRule, error) {
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
 	feedback := UserFeedbackOnRule{}
 
-	err := storage.connection.QueryRow(
+	err := storage.readConnection().QueryRowContext(
+		ctx,
 		`SELECT cluster_id, rule_id, error_key, user_id, message, user_vote, added_at, updated_at
 		FROM cluster_rule_user_feedback
 		WHERE cluster_id = $1 AND rule_id = $2 AND error_key = $3 AND user_id = $4`,
@@ -189,9 +196,13 @@ func (storage DBStorage) GetUserFeedbackOnRule(
 func (storage DBStorage) GetUserFeedbackOnRuleDisable(
 	clusterID types.ClusterName, ruleID types.RuleID, userID types.UserID,
 ) (*UserFeedbackOnRule, error) {
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
 	feedback := UserFeedbackOnRule{}
 
-	err := storage.connection.QueryRow(
+	err := storage.readConnection().QueryRowContext(
+		ctx,
 		`SELECT cluster_id, user_id, rule_id, message, added_at, updated_at
 		FROM cluster_user_rule_disable_feedback
 		WHERE cluster_id = $1 AND user_id = $2 AND rule_id = $3`,
@@ -221,6 +232,9 @@ func (storage DBStorage) GetUserFeedbackOnRuleDisable(
 func (storage DBStorage) GetUserFeedbackOnRules(
 	clusterID types.ClusterName, rulesReport []types.RuleOnReport, userID types.UserID,
 ) (map[types.RuleID]types.UserVote, error) {
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
 	ruleIDs := make([]string, 0)
 	for _, v := range rulesReport {
 		ruleIDs = append(ruleIDs, string(v.Module))
@@ -235,7 +249,7 @@ func (storage DBStorage) GetUserFeedbackOnRules(
 	whereInStatement := "'" + strings.Join([]string(ruleIDs), "','") + "'"
 	query = fmt.Sprintf(query, whereInStatement)
 
-	rows, err := storage.connection.Query(query, clusterID, userID)
+	rows, err := storage.readConnection().QueryContext(ctx, query, clusterID, userID)
 	if err != nil {
 		return feedbacks, err
 	}
@@ -261,6 +275,130 @@ func (storage DBStorage) GetUserFeedbackOnRules(
 	return feedbacks, nil
 }
 
+// GetUserFeedbackOnRulesForClusters gets all feedback (votes and messages)
+// left by the user on rules of the given clusters in one query
+func (storage DBStorage) GetUserFeedbackOnRulesForClusters(
+	clusterNames []types.ClusterName, userID types.UserID,
+) ([]UserFeedbackOnRule, error) {
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
+	feedbacks := make([]UserFeedbackOnRule, 0)
+
+	if len(clusterNames) == 0 {
+		return feedbacks, nil
+	}
+
+	// prepare arguments, user ID goes after all cluster names
+	args := append(argsWithClusterNames(clusterNames), userID)
+
+	// construct the `in` clausule in SQL query statement
+	inClausule := constructInClausule(len(clusterNames))
+
+	// disable "G202 (CWE-89): SQL string concatenation"
+	// #nosec G202
+	query := `SELECT cluster_id, rule_id, error_key, user_id, message, user_vote, added_at, updated_at
+		FROM cluster_rule_user_feedback
+		WHERE cluster_id IN (` + inClausule + `) AND user_id = $` + fmt.Sprint(len(args)) + `
+		ORDER BY cluster_id, rule_id, error_key`
+
+	rows, err := storage.readConnection().QueryContext(ctx, query, args...)
+	if err != nil {
+		return feedbacks, types.ConvertDBError(err, userID)
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var feedback UserFeedbackOnRule
+
+		err = rows.Scan(
+			&feedback.ClusterID,
+			&feedback.RuleID,
+			&feedback.ErrorKey,
+			&feedback.UserID,
+			&feedback.Message,
+			&feedback.UserVote,
+			&feedback.AddedAt,
+			&feedback.UpdatedAt,
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("GetUserFeedbackOnRulesForClusters")
+			return nil, types.ConvertDBError(err, userID)
+		}
+
+		feedbacks = append(feedbacks, feedback)
+	}
+
+	return feedbacks, nil
+}
+
+// ReadVoteSummaryOnRule returns the number of users who liked and disliked
+// the rule for the cluster
+func (storage DBStorage) ReadVoteSummaryOnRule(
+	clusterID types.ClusterName, ruleID types.RuleID, errorKey types.ErrorKey,
+) (types.VoteSummary, error) {
+	return storage.readVoteSummary(`
+		SELECT user_vote, COUNT(*)
+		FROM cluster_rule_user_feedback
+		WHERE cluster_id = $1 AND rule_id = $2 AND error_key = $3
+		GROUP BY user_vote`,
+		clusterID, ruleID, errorKey,
+	)
+}
+
+// ReadVoteSummaryOnRuleForOrg returns the number of votes on the rule for
+// all clusters of the organization, a user voting for multiple clusters is
+// counted for every cluster
+func (storage DBStorage) ReadVoteSummaryOnRuleForOrg(
+	orgID types.OrgID, ruleID types.RuleID, errorKey types.ErrorKey,
+) (types.VoteSummary, error) {
+	return storage.readVoteSummary(`
+		SELECT feedback.user_vote, COUNT(*)
+		FROM cluster_rule_user_feedback feedback
+		JOIN report ON report.cluster = feedback.cluster_id
+		WHERE report.org_id = $1 AND feedback.rule_id = $2 AND feedback.error_key = $3
+		GROUP BY feedback.user_vote`,
+		orgID, ruleID, errorKey,
+	)
+}
+
+// readVoteSummary sums votes grouped by the vote using the given query
+func (storage DBStorage) readVoteSummary(query string, args ...interface{}) (types.VoteSummary, error) {
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
+	var summary types.VoteSummary
+
+	rows, err := storage.readConnection().QueryContext(ctx, query, args...)
+	if err != nil {
+		return summary, types.ConvertDBError(err, nil)
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var (
+			userVote types.UserVote
+			count    int
+		)
+
+		err = rows.Scan(&userVote, &count)
+		if err != nil {
+			log.Error().Err(err).Msg("readVoteSummary")
+			return summary, types.ConvertDBError(err, nil)
+		}
+
+		// reset votes are kept in the table
+		switch userVote {
+		case types.UserVoteLike:
+			summary.Likes = count
+		case types.UserVoteDislike:
+			summary.Dislikes = count
+		}
+	}
+
+	return summary, nil
+}
+
 // GetUserDisableFeedbackOnRules gets user disable feedbacks for defined array of rule IDs from DB
 func (storage DBStorage) GetUserDisableFeedbackOnRules(
 	clusterID types.ClusterName, rulesReport []types.RuleOnReport, userID types.UserID,
@@ -294,7 +432,10 @@ func (storage DBStorage) AddFeedbackOnRuleDisable(
 	userID types.UserID,
 	message string,
 ) error {
-	statement, err := storage.connection.Prepare(`
+	ctx, cancel := storage.operationContext(writeOperation)
+	defer cancel()
+
+	statement, err := storage.connection.PrepareContext(ctx, `
 		INSERT INTO cluster_user_rule_disable_feedback
 		(cluster_id, user_id, rule_id, error_key, message, added_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7)
@@ -313,7 +454,7 @@ func (storage DBStorage) AddFeedbackOnRuleDisable(
 
 	now := time.Now()
 
-	_, err = statement.Exec(clusterID, userID, ruleID, errorKey, message, now, now)
+	_, err = statement.ExecContext(ctx, clusterID, userID, ruleID, errorKey, message, now, now)
 	err = types.ConvertDBError(err, nil)
 	if err != nil {
 		log.Error().Err(err).Msg("addOrUpdateUserFeedbackOnRuleDisableForCluster")
@@ -324,3 +465,57 @@ func (storage DBStorage) AddFeedbackOnRuleDisable(
 
 	return nil
 }
+
+// userFeedbackImport writes imported feedback with its original timestamps,
+// the time when the feedback was added is kept for existing records
+var userFeedbackImport = upsertQuery{
+	table: "cluster_rule_user_feedback",
+	columns: []string{
+		"cluster_id", "rule_id", "error_key", "user_id", "user_vote", "message", "added_at", "updated_at",
+	},
+	conflictColumns: []string{"cluster_id", "rule_id", "error_key", "user_id"},
+	updateColumns:   []string{"user_vote", "message", "updated_at"},
+}
+
+// ImportUserFeedback writes votes and feedback (migrated from another system,
+// for example) in one transaction, so either all of them or none is written.
+// Existing feedback of the same user on the same rule of the cluster is
+// overwritten.
+func (storage DBStorage) ImportUserFeedback(feedbacks []UserFeedbackOnRule) error {
+	ctx, cancel := storage.operationContext(writeOperation)
+	defer cancel()
+
+	tx, err := storage.connection.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = func(tx *sql.Tx) error {
+		for i, feedback := range feedbacks {
+			err := userFeedbackImport.exec(tx, storage.dbDriverType, []interface{}{
+				feedback.ClusterID,
+				feedback.RuleID,
+				feedback.ErrorKey,
+				feedback.UserID,
+				feedback.UserVote,
+				feedback.Message,
+				feedback.AddedAt,
+				feedback.UpdatedAt,
+			})
+			if err != nil {
+				log.Error().Err(err).Msgf("Unable to import user feedback #%d", i)
+				return types.ConvertDBError(err, feedback.ClusterID)
+			}
+		}
+
+		return nil
+	}(tx)
+
+	finishTransaction(tx, err)
+
+	if err == nil {
+		metrics.FeedbackOnRules.Add(float64(len(feedbacks)))
+	}
+
+	return err
+}
@@ -0,0 +1,152 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// RuleHitFirstSeen represents the time the rule was reported for the
+// cluster for the first time
+type RuleHitFirstSeen struct {
+	RuleID      types.RuleID
+	ErrorKey    types.ErrorKey
+	FirstSeenAt time.Time
+}
+
+// queryer is implemented by both DB connection and transaction
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// readRuleHitsFirstSeen returns the time of the first occurrence of every
+// rule ever reported for the cluster from the history of rule hits. When it
+// is called in the write path, it has to be called after the history is
+// updated, so rules that appeared in the report being written are included.
+func readRuleHitsFirstSeen(
+	ctx context.Context, db queryer, orgID types.OrgID, clusterName types.ClusterName,
+) (map[ruleHitKey]time.Time, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT rule_fqdn, error_key, appeared_at FROM rule_hit_history
+		WHERE org_id = $1 AND cluster_id = $2;
+	`, orgID, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	firstSeen := make(map[ruleHitKey]time.Time)
+	for rows.Next() {
+		var (
+			key        ruleHitKey
+			appearedAt time.Time
+		)
+
+		err = rows.Scan(&key.ruleID, &key.errorKey, &appearedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if seenAt, found := firstSeen[key]; !found || appearedAt.Before(seenAt) {
+			firstSeen[key] = appearedAt
+		}
+	}
+
+	return firstSeen, rows.Err()
+}
+
+// ReadRuleHitsFirstSeen returns the time the rules currently reported for
+// the cluster were reported for the first time. Rule hits without the time
+// stored, because they were written by an instance of the service not
+// knowing first_seen_at column or the column doesn't exist yet, take it from
+// the history of rule hits.
+func (storage DBStorage) ReadRuleHitsFirstSeen(
+	orgID types.OrgID, clusterName types.ClusterName,
+) ([]RuleHitFirstSeen, error) {
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
+	firstSeen := make([]RuleHitFirstSeen, 0)
+
+	query := `
+		SELECT rule_fqdn, error_key, first_seen_at FROM rule_hit
+		WHERE org_id = $1 AND cluster_id = $2
+		ORDER BY rule_fqdn, error_key;
+	`
+	if !storage.schemaAtLeast(ruleHitFirstSeenVersion) {
+		query = `
+			SELECT rule_fqdn, error_key, NULL FROM rule_hit
+			WHERE org_id = $1 AND cluster_id = $2
+			ORDER BY rule_fqdn, error_key;
+		`
+	}
+
+	rows, err := storage.readConnection().QueryContext(ctx, query, orgID, clusterName)
+	if err != nil {
+		return firstSeen, err
+	}
+	defer closeRows(rows)
+
+	var missing []int
+
+	for rows.Next() {
+		var (
+			record      RuleHitFirstSeen
+			firstSeenAt sql.NullTime
+		)
+
+		err = rows.Scan(&record.RuleID, &record.ErrorKey, &firstSeenAt)
+		if err != nil {
+			return firstSeen, err
+		}
+
+		if !firstSeenAt.Valid {
+			missing = append(missing, len(firstSeen))
+		}
+		record.FirstSeenAt = firstSeenAt.Time
+
+		firstSeen = append(firstSeen, record)
+	}
+
+	if err = rows.Err(); err != nil || len(missing) == 0 {
+		return firstSeen, err
+	}
+
+	history, err := readRuleHitsFirstSeen(ctx, storage.connection, orgID, clusterName)
+	if err != nil {
+		return firstSeen, err
+	}
+
+	complete := firstSeen[:0]
+	for i, record := range firstSeen {
+		if len(missing) > 0 && missing[0] == i {
+			missing = missing[1:]
+
+			seenAt, found := history[ruleHitKey{ruleID: record.RuleID, errorKey: record.ErrorKey}]
+			if !found {
+				continue
+			}
+			record.FirstSeenAt = seenAt
+		}
+
+		complete = append(complete, record)
+	}
+
+	return complete, nil
+}
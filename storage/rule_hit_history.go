@@ -0,0 +1,171 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// ruleHitKey identifies a single rule hit for the cluster
+type ruleHitKey struct {
+	ruleID   types.RuleID
+	errorKey types.ErrorKey
+}
+
+// ruleHitChanges describes how the check of the cluster changed its rule
+// hits
+type ruleHitChanges struct {
+	hitCount int
+	added    int
+	removed  int
+}
+
+// updateRuleHitHistory closes occurrences of rules that are no longer
+// reported for the cluster and records their resolution and opens new
+// occurrences for rules that (re)appeared in the report. Number of rules
+// that were added and removed is returned.
+func updateRuleHitHistory(
+	tx *sql.Tx,
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+	rules []types.ReportItem,
+	lastCheckedTime time.Time,
+) (ruleHitChanges, error) {
+	var changes ruleHitChanges
+
+	rows, err := tx.Query(`
+		SELECT rule_fqdn, error_key FROM rule_hit_history
+		WHERE org_id = $1 AND cluster_id = $2 AND disappeared_at IS NULL;
+	`, orgID, clusterName)
+	if err != nil {
+		return changes, err
+	}
+
+	openOccurrences := make(map[ruleHitKey]bool)
+	for rows.Next() {
+		var key ruleHitKey
+
+		err = rows.Scan(&key.ruleID, &key.errorKey)
+		if err != nil {
+			closeRows(rows)
+			return changes, err
+		}
+
+		openOccurrences[key] = true
+	}
+	closeRows(rows)
+
+	reportedRules := make(map[ruleHitKey]bool)
+	for _, rule := range rules {
+		key := ruleHitKey{ruleID: rule.Module, errorKey: rule.ErrorKey}
+		reportedRules[key] = true
+
+		if openOccurrences[key] {
+			continue
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO rule_hit_history(org_id, cluster_id, rule_fqdn, error_key, appeared_at)
+			VALUES ($1, $2, $3, $4, $5);
+		`, orgID, clusterName, rule.Module, rule.ErrorKey, lastCheckedTime)
+		if err != nil {
+			log.Err(err).Msgf("Unable to write rule hit history (org: %v, cluster: %v, rule: %v|%v)",
+				orgID, clusterName, rule.Module, rule.ErrorKey,
+			)
+			return changes, err
+		}
+
+		// the same rule may be present more than once in the report
+		openOccurrences[key] = true
+		changes.added++
+	}
+
+	for key := range openOccurrences {
+		if reportedRules[key] {
+			continue
+		}
+
+		_, err = tx.Exec(`
+			UPDATE rule_hit_history SET disappeared_at = $1
+			WHERE org_id = $2 AND cluster_id = $3 AND rule_fqdn = $4 AND error_key = $5 AND disappeared_at IS NULL;
+		`, lastCheckedTime, orgID, clusterName, key.ruleID, key.errorKey)
+		if err != nil {
+			log.Err(err).Msgf("Unable to update rule hit history (org: %v, cluster: %v, rule: %v|%v)",
+				orgID, clusterName, key.ruleID, key.errorKey,
+			)
+			return changes, err
+		}
+
+		err = writeRuleHitResolution(tx, orgID, clusterName, key, lastCheckedTime)
+		if err != nil {
+			return changes, err
+		}
+
+		changes.removed++
+	}
+
+	changes.hitCount = len(reportedRules)
+
+	return changes, nil
+}
+
+// ReadRuleHitOccurrences returns the timeline of periods during which
+// the rule was reported for the cluster, the oldest occurrence goes first
+func (storage DBStorage) ReadRuleHitOccurrences(
+	clusterName types.ClusterName, ruleID types.RuleID, errorKey types.ErrorKey,
+) ([]types.RuleHitOccurrence, error) {
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
+	occurrences := make([]types.RuleHitOccurrence, 0)
+
+	rows, err := storage.readConnection().QueryContext(ctx, `
+		SELECT appeared_at, disappeared_at FROM rule_hit_history
+		WHERE cluster_id = $1 AND rule_fqdn = $2 AND error_key = $3
+		ORDER BY appeared_at;
+	`, clusterName, ruleID, errorKey)
+	if err != nil {
+		return occurrences, types.ConvertDBError(err, clusterName)
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var (
+			appearedAt    time.Time
+			disappearedAt sql.NullTime
+			occurrence    types.RuleHitOccurrence
+		)
+
+		err = rows.Scan(&appearedAt, &disappearedAt)
+		if err != nil {
+			log.Error().Err(err).Msg("ReadRuleHitOccurrences")
+			return occurrences, types.ConvertDBError(err, clusterName)
+		}
+
+		occurrence.AppearedAt = types.Timestamp(appearedAt.UTC().Format(time.RFC3339))
+		if disappearedAt.Valid {
+			occurrence.DisappearedAt = types.Timestamp(disappearedAt.Time.UTC().Format(time.RFC3339))
+		}
+
+		occurrences = append(occurrences, occurrence)
+	}
+
+	return occurrences, types.ConvertDBError(rows.Err(), clusterName)
+}
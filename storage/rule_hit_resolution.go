@@ -0,0 +1,109 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// ruleResolutionRatesQuery counts occurrences of rule hits and their
+// resolutions per rule, both placeholders are replaced by the same condition
+// selecting the organization or by nothing
+const ruleResolutionRatesQuery = `
+	SELECT hits.rule_fqdn, hits.error_key, hits.count, COALESCE(resolutions.count, 0)
+	FROM (
+		SELECT rule_fqdn, error_key, count(*) AS count FROM rule_hit_history
+		%s
+		GROUP BY rule_fqdn, error_key
+	) hits
+	LEFT JOIN (
+		SELECT rule_fqdn, error_key, count(*) AS count FROM rule_hit_resolution
+		%s
+		GROUP BY rule_fqdn, error_key
+	) resolutions
+	ON hits.rule_fqdn = resolutions.rule_fqdn AND hits.error_key = resolutions.error_key
+	ORDER BY hits.rule_fqdn, hits.error_key;
+`
+
+// writeRuleHitResolution records that the rule disappeared from the report
+// of the cluster
+func writeRuleHitResolution(
+	tx *sql.Tx, orgID types.OrgID, clusterName types.ClusterName, key ruleHitKey, resolvedAt time.Time,
+) error {
+	_, err := tx.Exec(`
+		INSERT INTO rule_hit_resolution(org_id, cluster_id, rule_fqdn, error_key, resolved_at)
+		VALUES ($1, $2, $3, $4, $5);
+	`, orgID, clusterName, key.ruleID, key.errorKey, resolvedAt)
+	if err != nil {
+		log.Err(err).Msgf("Unable to write rule hit resolution (org: %v, cluster: %v, rule: %v|%v)",
+			orgID, clusterName, key.ruleID, key.errorKey,
+		)
+	}
+
+	return err
+}
+
+// ReadRuleResolutionRates returns for every rule ever reported the number of
+// its hits, the number of its resolutions and their ratio, ordered by rule
+func (storage DBStorage) ReadRuleResolutionRates() ([]types.RuleResolutionRate, error) {
+	return storage.readRuleResolutionRates(fmt.Sprintf(ruleResolutionRatesQuery, "", ""))
+}
+
+// ReadRuleResolutionRatesForOrg returns resolution rates of rules reported
+// for the clusters of the organization, ordered by rule
+func (storage DBStorage) ReadRuleResolutionRatesForOrg(orgID types.OrgID) ([]types.RuleResolutionRate, error) {
+	const orgCondition = "WHERE org_id = $1"
+	return storage.readRuleResolutionRates(
+		fmt.Sprintf(ruleResolutionRatesQuery, orgCondition, orgCondition), orgID,
+	)
+}
+
+// readRuleResolutionRates reads resolution rates using the given query
+func (storage DBStorage) readRuleResolutionRates(query string, args ...interface{}) ([]types.RuleResolutionRate, error) {
+	ctx, cancel := storage.operationContext(aggregationOperation)
+	defer cancel()
+
+	rates := make([]types.RuleResolutionRate, 0)
+
+	rows, err := storage.readConnection().QueryContext(ctx, query, args...)
+	if err != nil {
+		return rates, types.ConvertDBError(err, nil)
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var rate types.RuleResolutionRate
+
+		err = rows.Scan(&rate.RuleID, &rate.ErrorKey, &rate.Hits, &rate.Resolved)
+		if err != nil {
+			log.Error().Err(err).Msg("ReadRuleResolutionRates")
+			return rates, types.ConvertDBError(err, nil)
+		}
+
+		if rate.Hits > 0 {
+			rate.ResolutionRate = float64(rate.Resolved) / float64(rate.Hits)
+		}
+
+		rates = append(rates, rate)
+	}
+
+	return rates, nil
+}
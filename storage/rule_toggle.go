@@ -45,10 +45,24 @@ type ClusterRuleToggle struct {
 	UpdatedAt  sql.NullTime
 }
 
+// RuleDisableDetails describes when the rule was disabled for the cluster and
+// who disabled it with which justification. DisabledBy and Justification are
+// taken from the most recent disable feedback, they are empty when no
+// feedback was left.
+type RuleDisableDetails struct {
+	RuleID        types.RuleID
+	ErrorKey      types.ErrorKey
+	DisabledAt    sql.NullTime
+	DisabledBy    types.UserID
+	Justification string
+}
+
 // ToggleRuleForCluster toggles rule for specified cluster
 func (storage DBStorage) ToggleRuleForCluster(
 	clusterID types.ClusterName, ruleID types.RuleID, errorKey types.ErrorKey, ruleToggle RuleToggle,
 ) error {
+	ctx, cancel := storage.operationContext(writeOperation)
+	defer cancel()
 
 	var query string
 	var enabledAt, disabledAt, updatedAt sql.NullTime
@@ -77,7 +91,8 @@ func (storage DBStorage) ToggleRuleForCluster(
 			updated_at = $7
 	`
 
-	_, err := storage.connection.Exec(
+	_, err := storage.connection.ExecContext(
+		ctx,
 		query,
 		clusterID,
 		ruleID,
@@ -99,6 +114,9 @@ func (storage DBStorage) ToggleRuleForCluster(
 func (storage DBStorage) GetFromClusterRuleToggle(
 	clusterID types.ClusterName, ruleID types.RuleID,
 ) (*ClusterRuleToggle, error) {
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
 	var disabledRule ClusterRuleToggle
 
 	// query has LIMIT 1 and ORDER BY updated_at because of old functionality where
@@ -122,7 +140,8 @@ func (storage DBStorage) GetFromClusterRuleToggle(
 	LIMIT 1
 	`
 
-	err := storage.connection.QueryRow(
+	err := storage.readConnection().QueryRowContext(
+		ctx,
 		query,
 		clusterID,
 		ruleID,
@@ -145,6 +164,9 @@ func (storage DBStorage) GetFromClusterRuleToggle(
 func (storage DBStorage) GetTogglesForRules(
 	clusterID types.ClusterName, rulesReport []types.RuleOnReport,
 ) (map[types.RuleID]bool, error) {
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
 	ruleIDs := make([]string, 0)
 	for _, rule := range rulesReport {
 		ruleIDs = append(ruleIDs, string(rule.Module))
@@ -165,7 +187,7 @@ func (storage DBStorage) GetTogglesForRules(
 	whereInStatement := "'" + strings.Join(ruleIDs, "','") + "'"
 	query = fmt.Sprintf(query, whereInStatement)
 
-	rows, err := storage.connection.Query(query, clusterID)
+	rows, err := storage.readConnection().QueryContext(ctx, query, clusterID)
 	if err != nil {
 		return toggles, err
 	}
@@ -194,6 +216,9 @@ func (storage DBStorage) GetTogglesForRules(
 func (storage DBStorage) DeleteFromRuleClusterToggle(
 	clusterID types.ClusterName, ruleID types.RuleID,
 ) error {
+	ctx, cancel := storage.operationContext(writeOperation)
+	defer cancel()
+
 	query := `
 	DELETE FROM
 		cluster_rule_toggle
@@ -201,6 +226,82 @@ func (storage DBStorage) DeleteFromRuleClusterToggle(
 		cluster_id = $1 AND
 		rule_id = $2
 	`
-	_, err := storage.connection.Exec(query, clusterID, ruleID)
+	_, err := storage.connection.ExecContext(ctx, query, clusterID, ruleID)
 	return err
 }
+
+// ReadRuleDisableDetails returns details of all rules disabled for the
+// cluster. Toggles are joined with the disable feedback of all users, so the
+// details are read by one query.
+func (storage DBStorage) ReadRuleDisableDetails(clusterID types.ClusterName) ([]RuleDisableDetails, error) {
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
+	rows, err := storage.readConnection().QueryContext(ctx, `
+		SELECT
+			toggle.rule_id,
+			toggle.error_key,
+			toggle.disabled_at,
+			feedback.user_id,
+			feedback.message,
+			feedback.updated_at
+		FROM
+			cluster_rule_toggle toggle
+		LEFT JOIN cluster_user_rule_disable_feedback feedback ON
+			feedback.cluster_id = toggle.cluster_id AND
+			feedback.rule_id = toggle.rule_id AND
+			feedback.error_key = toggle.error_key
+		WHERE
+			toggle.cluster_id = $1 AND
+			toggle.disabled = $2
+	`, clusterID, RuleToggleDisable)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	type ruleKey struct {
+		ruleID   types.RuleID
+		errorKey types.ErrorKey
+	}
+
+	details := make([]RuleDisableDetails, 0)
+	// index of the rule in details and time of its feedback, only the most
+	// recent feedback is used
+	indexes := make(map[ruleKey]int)
+	feedbackTimes := make(map[ruleKey]time.Time)
+
+	for rows.Next() {
+		var (
+			detail       RuleDisableDetails
+			userID       sql.NullString
+			message      sql.NullString
+			feedbackTime sql.NullTime
+		)
+
+		err = rows.Scan(&detail.RuleID, &detail.ErrorKey, &detail.DisabledAt, &userID, &message, &feedbackTime)
+		if err != nil {
+			log.Error().Err(err).Msg("ReadRuleDisableDetails")
+			return nil, err
+		}
+
+		detail.DisabledBy = types.UserID(userID.String)
+		detail.Justification = message.String
+
+		key := ruleKey{detail.RuleID, detail.ErrorKey}
+		index, found := indexes[key]
+		if !found {
+			indexes[key] = len(details)
+			feedbackTimes[key] = feedbackTime.Time
+			details = append(details, detail)
+			continue
+		}
+
+		if feedbackTime.Valid && feedbackTime.Time.After(feedbackTimes[key]) {
+			feedbackTimes[key] = feedbackTime.Time
+			details[index] = detail
+		}
+	}
+
+	return details, rows.Err()
+}
@@ -0,0 +1,107 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"sync"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/migration"
+)
+
+// ruleHitFirstSeenVersion is the migration version that added first_seen_at
+// column into rule_hit table
+const ruleHitFirstSeenVersion migration.Version = 26
+
+// reportCheckVersion is the migration version that added report_check table
+const reportCheckVersion migration.Version = 28
+
+// reportHistoryVersion is the migration version that added report_history
+// table
+const reportHistoryVersion migration.Version = 29
+
+// apiKeyVersion is the migration version that added api_key table
+const apiKeyVersion migration.Version = 30
+
+// clusterOrgChangeVersion is the migration version that added
+// cluster_org_change table
+const clusterOrgChangeVersion migration.Version = 31
+
+// externalResultVersion is the migration version that added external_report
+// and external_result tables
+const externalResultVersion migration.Version = 32
+
+// reportMessageKeyVersion is the migration version that added message_key
+// column to report table
+const reportMessageKeyVersion migration.Version = 33
+
+// reportGenerationVersion is the migration version that added generation
+// column to report and rule_hit tables
+const reportGenerationVersion migration.Version = 34
+
+// orgRuleFeedbackVersion is the migration version that added
+// org_rule_user_feedback table
+const orgRuleFeedbackVersion migration.Version = 35
+
+// clusterClassVersion is the migration version that added the class of the
+// cluster to the report table
+const clusterClassVersion migration.Version = 36
+
+// MinSupportedDBVersion is the oldest migration version of the database the
+// storage can work with. Instances of the service are upgraded one by one
+// during rolling deployments, so new instances can run against the database
+// that is not migrated yet and old instances can run against already
+// migrated database. Both have to read complete data written by the other.
+const MinSupportedDBVersion = ruleHitFirstSeenVersion - 1
+
+// schemaVersion is the migration version of the database detected by the
+// storage, it is shared by all copies of DBStorage
+type schemaVersion struct {
+	mutex   sync.RWMutex
+	version migration.Version
+}
+
+// DetectSchemaVersion reads the migration version of the database and
+// adapts queries to its schema. The latest schema is expected until the
+// version is detected.
+func (storage DBStorage) DetectSchemaVersion() (migration.Version, error) {
+	version, err := migration.GetDBVersion(storage.connection)
+	if err != nil {
+		return 0, err
+	}
+
+	storage.schemaVersion.mutex.Lock()
+	storage.schemaVersion.version = version
+	storage.schemaVersion.mutex.Unlock()
+
+	if version < migration.GetMaxVersion() {
+		log.Warn().Msgf(
+			"DB is not migrated to the latest version yet (current: %d, latest: %d), compatible queries are used",
+			version, migration.GetMaxVersion(),
+		)
+	}
+
+	return version, nil
+}
+
+// schemaAtLeast returns true when the database is migrated at least to the
+// given version, so the tables and columns added by the migration exist
+func (storage DBStorage) schemaAtLeast(version migration.Version) bool {
+	storage.schemaVersion.mutex.RLock()
+	defer storage.schemaVersion.mutex.RUnlock()
+
+	return storage.schemaVersion.version >= version
+}
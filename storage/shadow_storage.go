@@ -0,0 +1,700 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// results of the comparison of reads used as result label of ShadowReads
+// metric
+const (
+	shadowReadMatch    = "match"
+	shadowReadMismatch = "mismatch"
+	shadowReadError    = "error"
+	shadowReadSkipped  = "skipped"
+)
+
+// defaultMaxPendingComparisons is used when no limit is configured
+const defaultMaxPendingComparisons = 100
+
+// ShadowReadStorage returns results of the current storage and sends all
+// reads to the candidate storage too. Results of both storages are compared
+// asynchronously and reported by ShadowReads metric, so the candidate storage
+// (new schema or new database) can be validated with real traffic without
+// affecting the responses. Writes go to the current storage only, the
+// candidate storage has to be filled by other means.
+type ShadowReadStorage struct {
+	Storage
+	candidate Storage
+	// pending limits number of comparisons running at the same time, reads
+	// are not compared when it's full
+	pending chan struct{}
+	// comparisons waits for the running comparisons
+	comparisons *sync.WaitGroup
+}
+
+// NewShadowReadStorage constructs storage returning results of the current
+// storage and comparing them with results of the candidate storage
+func NewShadowReadStorage(current, candidate Storage, maxPendingComparisons int) *ShadowReadStorage {
+	if maxPendingComparisons <= 0 {
+		maxPendingComparisons = defaultMaxPendingComparisons
+	}
+
+	return &ShadowReadStorage{
+		Storage:     current,
+		candidate:   candidate,
+		pending:     make(chan struct{}, maxPendingComparisons),
+		comparisons: &sync.WaitGroup{},
+	}
+}
+
+// Wait waits until all running comparisons are finished, it should be called
+// before the candidate storage is closed
+func (storage *ShadowReadStorage) Wait() {
+	storage.comparisons.Wait()
+}
+
+// compare reads the same data from the candidate storage in background and
+// compares them with the result and error returned by the current storage
+func (storage *ShadowReadStorage) compare(
+	method string, result []interface{}, err error, read func(candidate Storage) ([]interface{}, error),
+) {
+	select {
+	case storage.pending <- struct{}{}:
+	default:
+		metrics.ShadowReads.WithLabelValues(method, shadowReadSkipped).Inc()
+		return
+	}
+
+	storage.comparisons.Add(1)
+
+	go func() {
+		defer func() {
+			<-storage.pending
+			storage.comparisons.Done()
+		}()
+
+		candidateResult, candidateErr := read(storage.candidate)
+
+		comparison := compareShadowReads(result, err, candidateResult, candidateErr)
+		if comparison != shadowReadMatch {
+			log.Warn().
+				Str("method", method).
+				Str("result", comparison).
+				AnErr("current_error", err).
+				AnErr("candidate_error", candidateErr).
+				Msg("Read from the candidate storage doesn't match the current storage")
+		}
+
+		metrics.ShadowReads.WithLabelValues(method, comparison).Inc()
+	}()
+}
+
+// compareShadowReads compares results of the same read from the current and
+// the candidate storage. Errors match when they have the same type (both items
+// were not found, for example), results are compared in JSON, so timestamps in
+// different time zone objects with the same offset are equal.
+func compareShadowReads(result []interface{}, err error, candidateResult []interface{}, candidateErr error) string {
+	if err != nil || candidateErr != nil {
+		_, candidateItemNotFound := candidateErr.(*types.ItemNotFoundError)
+
+		switch {
+		case reflect.TypeOf(err) == reflect.TypeOf(candidateErr):
+			return shadowReadMatch
+		case candidateErr != nil && !candidateItemNotFound:
+			return shadowReadError
+		default:
+			return shadowReadMismatch
+		}
+	}
+
+	expected, err := json.Marshal(result)
+	if err != nil {
+		return shadowReadError
+	}
+
+	actual, err := json.Marshal(candidateResult)
+	if err != nil {
+		return shadowReadError
+	}
+
+	if !bytes.Equal(expected, actual) {
+		return shadowReadMismatch
+	}
+
+	return shadowReadMatch
+}
+
+// ListOfOrgs with shadow read
+func (storage *ShadowReadStorage) ListOfOrgs() ([]types.OrgID, error) {
+	orgs, err := storage.Storage.ListOfOrgs()
+	storage.compare("ListOfOrgs", []interface{}{orgs}, err, func(candidate Storage) ([]interface{}, error) {
+		orgs, err := candidate.ListOfOrgs()
+		return []interface{}{orgs}, err
+	})
+
+	return orgs, err
+}
+
+// ListOfOrgsWithSummary with shadow read
+func (storage *ShadowReadStorage) ListOfOrgsWithSummary() ([]types.OrgSummary, error) {
+	orgs, err := storage.Storage.ListOfOrgsWithSummary()
+	storage.compare("ListOfOrgsWithSummary", []interface{}{orgs}, err, func(candidate Storage) ([]interface{}, error) {
+		orgs, err := candidate.ListOfOrgsWithSummary()
+		return []interface{}{orgs}, err
+	})
+
+	return orgs, err
+}
+
+// ListOfClustersForOrg with shadow read
+func (storage *ShadowReadStorage) ListOfClustersForOrg(
+	orgID types.OrgID, timeLimit time.Time, offset, limit int,
+) ([]types.ClusterName, error) {
+	clusters, err := storage.Storage.ListOfClustersForOrg(orgID, timeLimit, offset, limit)
+	storage.compare("ListOfClustersForOrg", []interface{}{clusters}, err, func(candidate Storage) ([]interface{}, error) {
+		clusters, err := candidate.ListOfClustersForOrg(orgID, timeLimit, offset, limit)
+		return []interface{}{clusters}, err
+	})
+
+	return clusters, err
+}
+
+// ReadReportForCluster with shadow read
+func (storage *ShadowReadStorage) ReadReportForCluster(
+	orgID types.OrgID, clusterName types.ClusterName,
+) ([]types.RuleOnReport, types.Timestamp, error) {
+	rules, lastChecked, err := storage.Storage.ReadReportForCluster(orgID, clusterName)
+	storage.compare("ReadReportForCluster", []interface{}{rules, lastChecked}, err, func(candidate Storage) ([]interface{}, error) {
+		rules, lastChecked, err := candidate.ReadReportForCluster(orgID, clusterName)
+		return []interface{}{rules, lastChecked}, err
+	})
+
+	return rules, lastChecked, err
+}
+
+// ReadReportsForClusters with shadow read
+func (storage *ShadowReadStorage) ReadReportsForClusters(
+	clusterNames []types.ClusterName,
+) (map[types.ClusterName]types.ClusterReport, error) {
+	reports, err := storage.Storage.ReadReportsForClusters(clusterNames)
+	storage.compare("ReadReportsForClusters", []interface{}{reports}, err, func(candidate Storage) ([]interface{}, error) {
+		reports, err := candidate.ReadReportsForClusters(clusterNames)
+		return []interface{}{reports}, err
+	})
+
+	return reports, err
+}
+
+// ReadOrgIDsForClusters with shadow read
+func (storage *ShadowReadStorage) ReadOrgIDsForClusters(clusterNames []types.ClusterName) ([]types.OrgID, error) {
+	orgs, err := storage.Storage.ReadOrgIDsForClusters(clusterNames)
+	storage.compare("ReadOrgIDsForClusters", []interface{}{orgs}, err, func(candidate Storage) ([]interface{}, error) {
+		orgs, err := candidate.ReadOrgIDsForClusters(clusterNames)
+		return []interface{}{orgs}, err
+	})
+
+	return orgs, err
+}
+
+// ReadSingleRuleTemplateData with shadow read
+func (storage *ShadowReadStorage) ReadSingleRuleTemplateData(
+	orgID types.OrgID, clusterName types.ClusterName, ruleID types.RuleID, errorKey types.ErrorKey,
+) (interface{}, error) {
+	templateData, err := storage.Storage.ReadSingleRuleTemplateData(orgID, clusterName, ruleID, errorKey)
+	storage.compare("ReadSingleRuleTemplateData", []interface{}{templateData}, err, func(candidate Storage) ([]interface{}, error) {
+		templateData, err := candidate.ReadSingleRuleTemplateData(orgID, clusterName, ruleID, errorKey)
+		return []interface{}{templateData}, err
+	})
+
+	return templateData, err
+}
+
+// ReadReportForClusterByClusterName with shadow read
+func (storage *ShadowReadStorage) ReadReportForClusterByClusterName(
+	clusterName types.ClusterName,
+) ([]types.RuleOnReport, types.Timestamp, error) {
+	rules, lastChecked, err := storage.Storage.ReadReportForClusterByClusterName(clusterName)
+	storage.compare("ReadReportForClusterByClusterName", []interface{}{rules, lastChecked}, err, func(candidate Storage) ([]interface{}, error) {
+		rules, lastChecked, err := candidate.ReadReportForClusterByClusterName(clusterName)
+		return []interface{}{rules, lastChecked}, err
+	})
+
+	return rules, lastChecked, err
+}
+
+// ReadReportStatusForCluster with shadow read
+func (storage *ShadowReadStorage) ReadReportStatusForCluster(
+	orgID types.OrgID, clusterName types.ClusterName,
+) (types.ReportStatus, error) {
+	status, err := storage.Storage.ReadReportStatusForCluster(orgID, clusterName)
+	storage.compare("ReadReportStatusForCluster", []interface{}{status}, err, func(candidate Storage) ([]interface{}, error) {
+		status, err := candidate.ReadReportStatusForCluster(orgID, clusterName)
+		return []interface{}{status}, err
+	})
+
+	return status, err
+}
+
+// ReportsCount with shadow read
+func (storage *ShadowReadStorage) ReportsCount() (int, error) {
+	count, err := storage.Storage.ReportsCount()
+	storage.compare("ReportsCount", []interface{}{count}, err, func(candidate Storage) ([]interface{}, error) {
+		count, err := candidate.ReportsCount()
+		return []interface{}{count}, err
+	})
+
+	return count, err
+}
+
+// GetUserFeedbackOnRule with shadow read
+func (storage *ShadowReadStorage) GetUserFeedbackOnRule(
+	clusterID types.ClusterName, ruleID types.RuleID, errorKey types.ErrorKey, userID types.UserID,
+) (*UserFeedbackOnRule, error) {
+	feedback, err := storage.Storage.GetUserFeedbackOnRule(clusterID, ruleID, errorKey, userID)
+	storage.compare("GetUserFeedbackOnRule", []interface{}{feedback}, err, func(candidate Storage) ([]interface{}, error) {
+		feedback, err := candidate.GetUserFeedbackOnRule(clusterID, ruleID, errorKey, userID)
+		return []interface{}{feedback}, err
+	})
+
+	return feedback, err
+}
+
+// GetUserFeedbackOnRuleDisable with shadow read
+func (storage *ShadowReadStorage) GetUserFeedbackOnRuleDisable(
+	clusterID types.ClusterName, ruleID types.RuleID, userID types.UserID,
+) (*UserFeedbackOnRule, error) {
+	feedback, err := storage.Storage.GetUserFeedbackOnRuleDisable(clusterID, ruleID, userID)
+	storage.compare("GetUserFeedbackOnRuleDisable", []interface{}{feedback}, err, func(candidate Storage) ([]interface{}, error) {
+		feedback, err := candidate.GetUserFeedbackOnRuleDisable(clusterID, ruleID, userID)
+		return []interface{}{feedback}, err
+	})
+
+	return feedback, err
+}
+
+// GetFromClusterRuleToggle with shadow read
+func (storage *ShadowReadStorage) GetFromClusterRuleToggle(
+	clusterID types.ClusterName, ruleID types.RuleID,
+) (*ClusterRuleToggle, error) {
+	toggle, err := storage.Storage.GetFromClusterRuleToggle(clusterID, ruleID)
+	storage.compare("GetFromClusterRuleToggle", []interface{}{toggle}, err, func(candidate Storage) ([]interface{}, error) {
+		toggle, err := candidate.GetFromClusterRuleToggle(clusterID, ruleID)
+		return []interface{}{toggle}, err
+	})
+
+	return toggle, err
+}
+
+// GetTogglesForRules with shadow read
+func (storage *ShadowReadStorage) GetTogglesForRules(
+	clusterID types.ClusterName, rulesReport []types.RuleOnReport,
+) (map[types.RuleID]bool, error) {
+	toggles, err := storage.Storage.GetTogglesForRules(clusterID, rulesReport)
+	storage.compare("GetTogglesForRules", []interface{}{toggles}, err, func(candidate Storage) ([]interface{}, error) {
+		toggles, err := candidate.GetTogglesForRules(clusterID, rulesReport)
+		return []interface{}{toggles}, err
+	})
+
+	return toggles, err
+}
+
+// ReadRuleDisableDetails with shadow read
+func (storage *ShadowReadStorage) ReadRuleDisableDetails(clusterID types.ClusterName) ([]RuleDisableDetails, error) {
+	details, err := storage.Storage.ReadRuleDisableDetails(clusterID)
+	storage.compare("ReadRuleDisableDetails", []interface{}{details}, err, func(candidate Storage) ([]interface{}, error) {
+		details, err := candidate.ReadRuleDisableDetails(clusterID)
+		return []interface{}{details}, err
+	})
+
+	return details, err
+}
+
+// ReadRuleHitsFirstSeen with shadow read
+func (storage *ShadowReadStorage) ReadRuleHitsFirstSeen(
+	orgID types.OrgID, clusterName types.ClusterName,
+) ([]RuleHitFirstSeen, error) {
+	firstSeen, err := storage.Storage.ReadRuleHitsFirstSeen(orgID, clusterName)
+	storage.compare("ReadRuleHitsFirstSeen", []interface{}{firstSeen}, err, func(candidate Storage) ([]interface{}, error) {
+		firstSeen, err := candidate.ReadRuleHitsFirstSeen(orgID, clusterName)
+		return []interface{}{firstSeen}, err
+	})
+
+	return firstSeen, err
+}
+
+// GetOrgIDByClusterID with shadow read
+func (storage *ShadowReadStorage) GetOrgIDByClusterID(cluster types.ClusterName) (types.OrgID, error) {
+	orgID, err := storage.Storage.GetOrgIDByClusterID(cluster)
+	storage.compare("GetOrgIDByClusterID", []interface{}{orgID}, err, func(candidate Storage) ([]interface{}, error) {
+		orgID, err := candidate.GetOrgIDByClusterID(cluster)
+		return []interface{}{orgID}, err
+	})
+
+	return orgID, err
+}
+
+// GetUserFeedbackOnRules with shadow read
+func (storage *ShadowReadStorage) GetUserFeedbackOnRules(
+	clusterID types.ClusterName, rulesReport []types.RuleOnReport, userID types.UserID,
+) (map[types.RuleID]types.UserVote, error) {
+	votes, err := storage.Storage.GetUserFeedbackOnRules(clusterID, rulesReport, userID)
+	storage.compare("GetUserFeedbackOnRules", []interface{}{votes}, err, func(candidate Storage) ([]interface{}, error) {
+		votes, err := candidate.GetUserFeedbackOnRules(clusterID, rulesReport, userID)
+		return []interface{}{votes}, err
+	})
+
+	return votes, err
+}
+
+// GetUserDisableFeedbackOnRules with shadow read
+func (storage *ShadowReadStorage) GetUserDisableFeedbackOnRules(
+	clusterID types.ClusterName, rulesReport []types.RuleOnReport, userID types.UserID,
+) (map[types.RuleID]UserFeedbackOnRule, error) {
+	feedbacks, err := storage.Storage.GetUserDisableFeedbackOnRules(clusterID, rulesReport, userID)
+	storage.compare("GetUserDisableFeedbackOnRules", []interface{}{feedbacks}, err, func(candidate Storage) ([]interface{}, error) {
+		feedbacks, err := candidate.GetUserDisableFeedbackOnRules(clusterID, rulesReport, userID)
+		return []interface{}{feedbacks}, err
+	})
+
+	return feedbacks, err
+}
+
+// DoesClusterExist with shadow read
+func (storage *ShadowReadStorage) DoesClusterExist(clusterID types.ClusterName) (bool, error) {
+	exists, err := storage.Storage.DoesClusterExist(clusterID)
+	storage.compare("DoesClusterExist", []interface{}{exists}, err, func(candidate Storage) ([]interface{}, error) {
+		exists, err := candidate.DoesClusterExist(clusterID)
+		return []interface{}{exists}, err
+	})
+
+	return exists, err
+}
+
+// DoesClusterExistWithOrgID with shadow read
+func (storage *ShadowReadStorage) DoesClusterExistWithOrgID(clusterID types.ClusterName) (bool, types.OrgID, error) {
+	exists, orgID, err := storage.Storage.DoesClusterExistWithOrgID(clusterID)
+	storage.compare("DoesClusterExistWithOrgID", []interface{}{exists, orgID}, err, func(candidate Storage) ([]interface{}, error) {
+		exists, orgID, err := candidate.DoesClusterExistWithOrgID(clusterID)
+		return []interface{}{exists, orgID}, err
+	})
+
+	return exists, orgID, err
+}
+
+// ReadOrgIDsOfClusters with shadow read
+func (storage *ShadowReadStorage) ReadOrgIDsOfClusters(
+	clusterNames []types.ClusterName,
+) (map[types.ClusterName]types.OrgID, error) {
+	orgIDs, err := storage.Storage.ReadOrgIDsOfClusters(clusterNames)
+	storage.compare("ReadOrgIDsOfClusters", []interface{}{orgIDs}, err, func(candidate Storage) ([]interface{}, error) {
+		orgIDs, err := candidate.ReadOrgIDsOfClusters(clusterNames)
+		return []interface{}{orgIDs}, err
+	})
+
+	return orgIDs, err
+}
+
+// ReadRuleHitOccurrences with shadow read
+func (storage *ShadowReadStorage) ReadRuleHitOccurrences(
+	clusterName types.ClusterName, ruleID types.RuleID, errorKey types.ErrorKey,
+) ([]types.RuleHitOccurrence, error) {
+	occurrences, err := storage.Storage.ReadRuleHitOccurrences(clusterName, ruleID, errorKey)
+	storage.compare("ReadRuleHitOccurrences", []interface{}{occurrences}, err, func(candidate Storage) ([]interface{}, error) {
+		occurrences, err := candidate.ReadRuleHitOccurrences(clusterName, ruleID, errorKey)
+		return []interface{}{occurrences}, err
+	})
+
+	return occurrences, err
+}
+
+// ReadReportChecks with shadow read
+func (storage *ShadowReadStorage) ReadReportChecks(
+	clusterName types.ClusterName, limit int,
+) ([]types.ReportCheck, error) {
+	checks, err := storage.Storage.ReadReportChecks(clusterName, limit)
+	storage.compare("ReadReportChecks", []interface{}{checks}, err, func(candidate Storage) ([]interface{}, error) {
+		checks, err := candidate.ReadReportChecks(clusterName, limit)
+		return []interface{}{checks}, err
+	})
+
+	return checks, err
+}
+
+// CountClustersNotCheckedSince with shadow read
+func (storage *ShadowReadStorage) CountClustersNotCheckedSince(threshold time.Time) (int, error) {
+	count, err := storage.Storage.CountClustersNotCheckedSince(threshold)
+	storage.compare("CountClustersNotCheckedSince", []interface{}{count}, err, func(candidate Storage) ([]interface{}, error) {
+		count, err := candidate.CountClustersNotCheckedSince(threshold)
+		return []interface{}{count}, err
+	})
+
+	return count, err
+}
+
+// ReadClusterAnnotations with shadow read
+func (storage *ShadowReadStorage) ReadClusterAnnotations(clusterID types.ClusterName) ([]types.ClusterAnnotation, error) {
+	annotations, err := storage.Storage.ReadClusterAnnotations(clusterID)
+	storage.compare("ReadClusterAnnotations", []interface{}{annotations}, err, func(candidate Storage) ([]interface{}, error) {
+		annotations, err := candidate.ReadClusterAnnotations(clusterID)
+		return []interface{}{annotations}, err
+	})
+
+	return annotations, err
+}
+
+// ReadOrgInfo with shadow read
+func (storage *ShadowReadStorage) ReadOrgInfo(orgID types.OrgID) (types.OrgInfo, error) {
+	info, err := storage.Storage.ReadOrgInfo(orgID)
+	storage.compare("ReadOrgInfo", []interface{}{info}, err, func(candidate Storage) ([]interface{}, error) {
+		info, err := candidate.ReadOrgInfo(orgID)
+		return []interface{}{info}, err
+	})
+
+	return info, err
+}
+
+// GetUserFeedbackOnRulesForClusters with shadow read
+func (storage *ShadowReadStorage) GetUserFeedbackOnRulesForClusters(
+	clusterNames []types.ClusterName, userID types.UserID,
+) ([]UserFeedbackOnRule, error) {
+	feedbacks, err := storage.Storage.GetUserFeedbackOnRulesForClusters(clusterNames, userID)
+	storage.compare("GetUserFeedbackOnRulesForClusters", []interface{}{feedbacks}, err, func(candidate Storage) ([]interface{}, error) {
+		feedbacks, err := candidate.GetUserFeedbackOnRulesForClusters(clusterNames, userID)
+		return []interface{}{feedbacks}, err
+	})
+
+	return feedbacks, err
+}
+
+// ReadStaleReportWrites with shadow read
+func (storage *ShadowReadStorage) ReadStaleReportWrites() ([]types.StaleReportWrite, error) {
+	staleWrites, err := storage.Storage.ReadStaleReportWrites()
+	storage.compare("ReadStaleReportWrites", []interface{}{staleWrites}, err, func(candidate Storage) ([]interface{}, error) {
+		staleWrites, err := candidate.ReadStaleReportWrites()
+		return []interface{}{staleWrites}, err
+	})
+
+	return staleWrites, err
+}
+
+// ReadRuleHitsForCluster with shadow read
+func (storage *ShadowReadStorage) ReadRuleHitsForCluster(clusterName types.ClusterName) ([]RuleHitRecord, error) {
+	ruleHits, err := storage.Storage.ReadRuleHitsForCluster(clusterName)
+	storage.compare("ReadRuleHitsForCluster", []interface{}{ruleHits}, err, func(candidate Storage) ([]interface{}, error) {
+		ruleHits, err := candidate.ReadRuleHitsForCluster(clusterName)
+		return []interface{}{ruleHits}, err
+	})
+
+	return ruleHits, err
+}
+
+// ReadOrgUsage with shadow read
+func (storage *ShadowReadStorage) ReadOrgUsage(month string) ([]types.OrgUsage, error) {
+	usages, err := storage.Storage.ReadOrgUsage(month)
+	storage.compare("ReadOrgUsage", []interface{}{usages}, err, func(candidate Storage) ([]interface{}, error) {
+		usages, err := candidate.ReadOrgUsage(month)
+		return []interface{}{usages}, err
+	})
+
+	return usages, err
+}
+
+// ReadRuleResolutionRates with shadow read
+func (storage *ShadowReadStorage) ReadRuleResolutionRates() ([]types.RuleResolutionRate, error) {
+	rates, err := storage.Storage.ReadRuleResolutionRates()
+	storage.compare("ReadRuleResolutionRates", []interface{}{rates}, err, func(candidate Storage) ([]interface{}, error) {
+		rates, err := candidate.ReadRuleResolutionRates()
+		return []interface{}{rates}, err
+	})
+
+	return rates, err
+}
+
+// ReadRuleResolutionRatesForOrg with shadow read
+func (storage *ShadowReadStorage) ReadRuleResolutionRatesForOrg(orgID types.OrgID) ([]types.RuleResolutionRate, error) {
+	rates, err := storage.Storage.ReadRuleResolutionRatesForOrg(orgID)
+	storage.compare("ReadRuleResolutionRatesForOrg", []interface{}{rates}, err, func(candidate Storage) ([]interface{}, error) {
+		rates, err := candidate.ReadRuleResolutionRatesForOrg(orgID)
+		return []interface{}{rates}, err
+	})
+
+	return rates, err
+}
+
+// ReadTopRules with shadow read
+func (storage *ShadowReadStorage) ReadTopRules(from, to time.Time, limit int) ([]types.TopRule, error) {
+	rules, err := storage.Storage.ReadTopRules(from, to, limit)
+	storage.compare("ReadTopRules", []interface{}{rules}, err, func(candidate Storage) ([]interface{}, error) {
+		rules, err := candidate.ReadTopRules(from, to, limit)
+		return []interface{}{rules}, err
+	})
+
+	return rules, err
+}
+
+// ReadTopRulesForOrg with shadow read
+func (storage *ShadowReadStorage) ReadTopRulesForOrg(
+	orgID types.OrgID, from, to time.Time, limit int,
+) ([]types.TopRule, error) {
+	rules, err := storage.Storage.ReadTopRulesForOrg(orgID, from, to, limit)
+	storage.compare("ReadTopRulesForOrg", []interface{}{rules}, err, func(candidate Storage) ([]interface{}, error) {
+		rules, err := candidate.ReadTopRulesForOrg(orgID, from, to, limit)
+		return []interface{}{rules}, err
+	})
+
+	return rules, err
+}
+
+// ReadOrgReport with shadow read
+func (storage *ShadowReadStorage) ReadOrgReport(orgID types.OrgID) ([]types.OrgReportRule, error) {
+	rules, err := storage.Storage.ReadOrgReport(orgID)
+	storage.compare("ReadOrgReport", []interface{}{rules}, err, func(candidate Storage) ([]interface{}, error) {
+		rules, err := candidate.ReadOrgReport(orgID)
+		return []interface{}{rules}, err
+	})
+
+	return rules, err
+}
+
+// IsOrgFrozen with shadow read
+func (storage *ShadowReadStorage) IsOrgFrozen(orgID types.OrgID) (bool, error) {
+	frozen, err := storage.Storage.IsOrgFrozen(orgID)
+	storage.compare("IsOrgFrozen", []interface{}{frozen}, err, func(candidate Storage) ([]interface{}, error) {
+		frozen, err := candidate.IsOrgFrozen(orgID)
+		return []interface{}{frozen}, err
+	})
+
+	return frozen, err
+}
+
+// ReadFrozenOrgs with shadow read
+func (storage *ShadowReadStorage) ReadFrozenOrgs() ([]types.OrgFreeze, error) {
+	freezes, err := storage.Storage.ReadFrozenOrgs()
+	storage.compare("ReadFrozenOrgs", []interface{}{freezes}, err, func(candidate Storage) ([]interface{}, error) {
+		freezes, err := candidate.ReadFrozenOrgs()
+		return []interface{}{freezes}, err
+	})
+
+	return freezes, err
+}
+
+// ReadVoteSummaryOnRule with shadow read
+func (storage *ShadowReadStorage) ReadVoteSummaryOnRule(
+	clusterID types.ClusterName, ruleID types.RuleID, errorKey types.ErrorKey,
+) (types.VoteSummary, error) {
+	summary, err := storage.Storage.ReadVoteSummaryOnRule(clusterID, ruleID, errorKey)
+	storage.compare("ReadVoteSummaryOnRule", []interface{}{summary}, err, func(candidate Storage) ([]interface{}, error) {
+		summary, err := candidate.ReadVoteSummaryOnRule(clusterID, ruleID, errorKey)
+		return []interface{}{summary}, err
+	})
+
+	return summary, err
+}
+
+// ReadVoteSummaryOnRuleForOrg with shadow read
+func (storage *ShadowReadStorage) ReadVoteSummaryOnRuleForOrg(
+	orgID types.OrgID, ruleID types.RuleID, errorKey types.ErrorKey,
+) (types.VoteSummary, error) {
+	summary, err := storage.Storage.ReadVoteSummaryOnRuleForOrg(orgID, ruleID, errorKey)
+	storage.compare("ReadVoteSummaryOnRuleForOrg", []interface{}{summary}, err, func(candidate Storage) ([]interface{}, error) {
+		summary, err := candidate.ReadVoteSummaryOnRuleForOrg(orgID, ruleID, errorKey)
+		return []interface{}{summary}, err
+	})
+
+	return summary, err
+}
+
+// GetUserFeedbackOnRuleForOrg with shadow read
+func (storage *ShadowReadStorage) GetUserFeedbackOnRuleForOrg(
+	orgID types.OrgID, ruleID types.RuleID, errorKey types.ErrorKey, userID types.UserID,
+) (*OrgUserFeedbackOnRule, error) {
+	feedback, err := storage.Storage.GetUserFeedbackOnRuleForOrg(orgID, ruleID, errorKey, userID)
+	storage.compare("GetUserFeedbackOnRuleForOrg", []interface{}{feedback}, err, func(candidate Storage) ([]interface{}, error) {
+		feedback, err := candidate.GetUserFeedbackOnRuleForOrg(orgID, ruleID, errorKey, userID)
+		return []interface{}{feedback}, err
+	})
+
+	return feedback, err
+}
+
+// GetFeedbackOnRuleForOrg with shadow read
+func (storage *ShadowReadStorage) GetFeedbackOnRuleForOrg(
+	orgID types.OrgID, ruleID types.RuleID, errorKey types.ErrorKey,
+) ([]OrgUserFeedbackOnRule, error) {
+	feedbacks, err := storage.Storage.GetFeedbackOnRuleForOrg(orgID, ruleID, errorKey)
+	storage.compare("GetFeedbackOnRuleForOrg", []interface{}{feedbacks}, err, func(candidate Storage) ([]interface{}, error) {
+		feedbacks, err := candidate.GetFeedbackOnRuleForOrg(orgID, ruleID, errorKey)
+		return []interface{}{feedbacks}, err
+	})
+
+	return feedbacks, err
+}
+
+// ReadAPIKey with shadow read
+func (storage *ShadowReadStorage) ReadAPIKey(keyID string) (types.APIKey, string, error) {
+	key, keyHash, err := storage.Storage.ReadAPIKey(keyID)
+	storage.compare("ReadAPIKey", []interface{}{key, keyHash}, err, func(candidate Storage) ([]interface{}, error) {
+		key, keyHash, err := candidate.ReadAPIKey(keyID)
+		return []interface{}{key, keyHash}, err
+	})
+
+	return key, keyHash, err
+}
+
+// ReadAPIKeys with shadow read
+func (storage *ShadowReadStorage) ReadAPIKeys() ([]types.APIKey, error) {
+	keys, err := storage.Storage.ReadAPIKeys()
+	storage.compare("ReadAPIKeys", []interface{}{keys}, err, func(candidate Storage) ([]interface{}, error) {
+		keys, err := candidate.ReadAPIKeys()
+		return []interface{}{keys}, err
+	})
+
+	return keys, err
+}
+
+// ReadClusterOrgChanges with shadow read
+func (storage *ShadowReadStorage) ReadClusterOrgChanges(since time.Time) ([]types.ClusterOrgChange, error) {
+	changes, err := storage.Storage.ReadClusterOrgChanges(since)
+	storage.compare("ReadClusterOrgChanges", []interface{}{changes}, err, func(candidate Storage) ([]interface{}, error) {
+		changes, err := candidate.ReadClusterOrgChanges(since)
+		return []interface{}{changes}, err
+	})
+
+	return changes, err
+}
+
+// ReadExternalResults with shadow read
+func (storage *ShadowReadStorage) ReadExternalResults(
+	clusterName types.ClusterName, source string,
+) ([]types.ExternalResult, error) {
+	results, err := storage.Storage.ReadExternalResults(clusterName, source)
+	storage.compare("ReadExternalResults", []interface{}{results}, err, func(candidate Storage) ([]interface{}, error) {
+		results, err := candidate.ReadExternalResults(clusterName, source)
+		return []interface{}{results}, err
+	})
+
+	return results, err
+}
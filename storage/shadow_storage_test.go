@@ -0,0 +1,116 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	prommodels "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func getShadowReadsValue(t *testing.T, method, result string) float64 {
+	metric := &prommodels.Metric{}
+	helpers.FailOnError(t, metrics.ShadowReads.WithLabelValues(method, result).Write(metric))
+
+	return metric.GetCounter().GetValue()
+}
+
+// assertShadowRead reads the report through shadow-read storage and checks
+// the result of the comparison with the candidate storage
+func assertShadowRead(t *testing.T, current, candidate storage.Storage, expectedResult string) {
+	shadowStorage := storage.NewShadowReadStorage(current, candidate, 0)
+	initValue := getShadowReadsValue(t, "ReadReportForCluster", expectedResult)
+
+	expectedRules, expectedLastChecked, expectedErr := current.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	rules, lastChecked, err := shadowStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	shadowStorage.Wait()
+
+	// the result of the current storage is always returned
+	assert.Equal(t, expectedErr, err)
+	assert.Equal(t, expectedRules, rules)
+	assert.Equal(t, expectedLastChecked, lastChecked)
+
+	assert.Equal(t, initValue+1, getShadowReadsValue(t, "ReadReportForCluster", expectedResult))
+}
+
+func writeReport(t *testing.T, mockStorage storage.Storage, report types.ClusterReport, rules []types.ReportItem) {
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, report, rules, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+}
+
+func TestShadowReadStorage_Match(t *testing.T) {
+	current, closeCurrent := ira_helpers.MustGetMockStorage(t, true)
+	defer closeCurrent()
+	candidate, closeCandidate := ira_helpers.MustGetMockStorage(t, true)
+	defer closeCandidate()
+
+	writeReport(t, current, testdata.Report3Rules, testdata.Report3RulesParsed)
+	writeReport(t, candidate, testdata.Report3Rules, testdata.Report3RulesParsed)
+
+	assertShadowRead(t, current, candidate, "match")
+}
+
+func TestShadowReadStorage_MatchNotFound(t *testing.T) {
+	current, closeCurrent := ira_helpers.MustGetMockStorage(t, true)
+	defer closeCurrent()
+	candidate, closeCandidate := ira_helpers.MustGetMockStorage(t, true)
+	defer closeCandidate()
+
+	assertShadowRead(t, current, candidate, "match")
+}
+
+func TestShadowReadStorage_Mismatch(t *testing.T) {
+	current, closeCurrent := ira_helpers.MustGetMockStorage(t, true)
+	defer closeCurrent()
+	candidate, closeCandidate := ira_helpers.MustGetMockStorage(t, true)
+	defer closeCandidate()
+
+	writeReport(t, current, testdata.Report3Rules, testdata.Report3RulesParsed)
+	writeReport(t, candidate, testdata.Report2Rules, testdata.Report2RulesParsed)
+
+	assertShadowRead(t, current, candidate, "mismatch")
+}
+
+func TestShadowReadStorage_MissingInCandidate(t *testing.T) {
+	current, closeCurrent := ira_helpers.MustGetMockStorage(t, true)
+	defer closeCurrent()
+	candidate, closeCandidate := ira_helpers.MustGetMockStorage(t, true)
+	defer closeCandidate()
+
+	writeReport(t, current, testdata.Report3Rules, testdata.Report3RulesParsed)
+
+	assertShadowRead(t, current, candidate, "mismatch")
+}
+
+func TestShadowReadStorage_CandidateError(t *testing.T) {
+	current, closeCurrent := ira_helpers.MustGetMockStorage(t, true)
+	defer closeCurrent()
+	candidate, closeCandidate := ira_helpers.MustGetMockStorage(t, true)
+	closeCandidate()
+
+	writeReport(t, current, testdata.Report3Rules, testdata.Report3RulesParsed)
+
+	assertShadowRead(t, current, candidate, "error")
+}
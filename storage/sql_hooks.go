@@ -27,6 +27,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog/log"
 
+	"github.com/RedHatInsights/insights-results-aggregator/chaos"
 	"github.com/RedHatInsights/insights-results-aggregator/metrics"
 )
 
@@ -63,7 +64,9 @@ func (h *sqlHooks) After(ctx context.Context, query string, args ...interface{})
 	beginTime := ctx.Value(sqlHooksKeyQueryBeginTime).(time.Time)
 	duration := time.Since(beginTime)
 
-	metrics.SQLQueriesDurations.With(prometheus.Labels{"query": query}).Observe(duration.Seconds())
+	metrics.ObserveWithContext(
+		ctx, metrics.SQLQueriesDurations.With(prometheus.Labels{"query": query}), duration.Seconds(),
+	)
 
 	jsonArgs, err := json.Marshal(args)
 	if err == nil {
@@ -85,27 +88,80 @@ func (h *sqlHooks) log(format string, params ...interface{}) {
 	log.Debug().Str("type", "SQL").Msgf(format, params...)
 }
 
+// chaosSQLHooks injects faults of chaos mode before the query is executed,
+// the query is then handled by the wrapped hooks (if there are any)
+type chaosSQLHooks struct {
+	injector *chaos.Injector
+	hooks    sqlhooks.Hooks
+}
+
+// Before is called before the query is executed, the query is not executed
+// when the fault is injected
+func (h *chaosSQLHooks) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	if err := h.injector.Inject(ctx); err != nil {
+		return ctx, err
+	}
+
+	if h.hooks == nil {
+		return ctx, nil
+	}
+
+	return h.hooks.Before(ctx, query, args...)
+}
+
+// After is called after the query was executed
+func (h *chaosSQLHooks) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	if h.hooks == nil {
+		return ctx, nil
+	}
+
+	return h.hooks.After(ctx, query, args...)
+}
+
+// registerSQLDriver registers the driver under the name unless there's
+// already a driver with the same name
+func registerSQLDriver(name string, driver sql_driver.Driver) {
+	// linear search is not gonna be an issue since there's not many drivers
+	// and we call New() only ones/twice per process life
+	for _, existingDriver := range sql.Drivers() {
+		if existingDriver == name {
+			return
+		}
+	}
+
+	sql.Register(name, driver)
+}
+
 // InitSQLDriverWithLogs initializes wrapped version of driver with logging sql queries
 // and returns its name
 func InitSQLDriverWithLogs(
 	realDriver sql_driver.Driver,
 	realDriverName string,
 ) string {
-	// linear search is not gonna be an issue since there's not many drivers
-	// and we call New() only ones/twice per process life
-	foundHooksDriver := false
 	hooksDriverName := realDriverName + "WithHooks"
+	registerSQLDriver(hooksDriverName, sqlhooks.Wrap(realDriver, &sqlHooks{}))
 
-	for _, existingDriver := range sql.Drivers() {
-		if existingDriver == hooksDriverName {
-			foundHooksDriver = true
-			break
-		}
-	}
+	return hooksDriverName
+}
+
+// InitSQLDriverWithChaos initializes wrapped version of driver injecting
+// faults of chaos mode into all queries and optionally logging them and
+// returns its name
+func InitSQLDriverWithChaos(
+	realDriver sql_driver.Driver,
+	realDriverName string,
+	injector *chaos.Injector,
+	logSQLQueries bool,
+) string {
+	hooks := &chaosSQLHooks{injector: injector}
+	chaosDriverName := realDriverName + "WithChaos"
 
-	if !foundHooksDriver {
-		sql.Register(hooksDriverName, sqlhooks.Wrap(realDriver, &sqlHooks{}))
+	if logSQLQueries {
+		hooks.hooks = &sqlHooks{}
+		chaosDriverName += "AndHooks"
 	}
 
-	return hooksDriverName
+	registerSQLDriver(chaosDriverName, sqlhooks.Wrap(realDriver, hooks))
+
+	return chaosDriverName
 }
@@ -19,6 +19,7 @@ package storage_test
 import (
 	"bytes"
 	"context"
+	"database/sql"
 	"fmt"
 	"math"
 	"testing"
@@ -30,6 +31,7 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/RedHatInsights/insights-results-aggregator/chaos"
 	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
 )
@@ -72,6 +74,7 @@ func TestSQLHooksLoggingArgsJSON(t *testing.T) {
 
 	buf := new(bytes.Buffer)
 	logger := zerolog.New(buf).With().Str("type", "SQL").Logger()
+	defer func(logger zerolog.Logger) { log.Logger = logger }(log.Logger)
 	log.Logger = logger
 
 	hooks := storage.SQLHooks{}
@@ -108,6 +111,7 @@ func TestSQLHooksLoggingArgsNotJSON(t *testing.T) {
 
 	buf := new(bytes.Buffer)
 	logger := zerolog.New(buf).With().Str("type", "SQL").Logger()
+	defer func(logger zerolog.Logger) { log.Logger = logger }(log.Logger)
 	log.Logger = logger
 	hooks := storage.SQLHooks{}
 
@@ -133,3 +137,87 @@ func TestSQLHooksLoggingArgsNotJSON(t *testing.T) {
 		fmt.Sprintf(storage.LogFormatterString, query, params)+" took",
 	)
 }
+
+// chaosInjector is shared by all tests, because the wrapped driver is
+// registered only once
+var chaosInjector = chaos.NewInjector()
+
+func mustConfigureChaos(t *testing.T, errorPercentage int) {
+	chaosInjector.Configure(chaos.Configuration{Enabled: true})
+	helpers.FailOnError(t, chaosInjector.UpdateSettings(chaos.Settings{
+		Active:          true,
+		ErrorPercentage: errorPercentage,
+	}))
+}
+
+func TestInitSQLDriverWithChaos(t *testing.T) {
+	driverName := storage.InitSQLDriverWithChaos(
+		&sqlite3.SQLiteDriver{},
+		"sqlite3",
+		chaosInjector,
+		false,
+	)
+	assert.Equal(t, "sqlite3WithChaos", driverName)
+
+	driverName = storage.InitSQLDriverWithChaos(
+		&pq.Driver{},
+		"postgres",
+		chaosInjector,
+		true,
+	)
+	assert.Equal(t, "postgresWithChaosAndHooks", driverName)
+}
+
+func TestInitSQLDriverWithChaos_QueriesFail(t *testing.T) {
+	mustConfigureChaos(t, 100)
+	defer chaosInjector.Configure(chaos.Configuration{})
+
+	driverName := storage.InitSQLDriverWithChaos(
+		&sqlite3.SQLiteDriver{},
+		"sqlite3",
+		chaosInjector,
+		false,
+	)
+
+	connection, err := sql.Open(driverName, ":memory:")
+	helpers.FailOnError(t, err)
+	defer func() {
+		helpers.FailOnError(t, connection.Close())
+	}()
+
+	_, err = connection.Exec("SELECT 1")
+	assert.Equal(t, chaos.ErrInjectedFault, err)
+}
+
+func TestChaosSQLHooks_FaultInjected(t *testing.T) {
+	mustConfigureChaos(t, 100)
+	defer chaosInjector.Configure(chaos.Configuration{})
+
+	hooks := storage.NewChaosSQLHooks(chaosInjector, nil)
+
+	_, err := hooks.Before(context.Background(), "SELECT 1")
+	assert.Equal(t, chaos.ErrInjectedFault, err)
+}
+
+func TestChaosSQLHooks_WrappedHooksCalled(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	mustConfigureChaos(t, 0)
+	defer chaosInjector.Configure(chaos.Configuration{})
+
+	const query = "SELECT 1"
+
+	buf := new(bytes.Buffer)
+	defer func(logger zerolog.Logger) { log.Logger = logger }(log.Logger)
+	log.Logger = zerolog.New(buf).With().Str("type", "SQL").Logger()
+
+	hooks := storage.NewChaosSQLHooks(chaosInjector, &storage.SQLHooks{})
+
+	ctx, err := hooks.Before(context.Background(), query)
+	helpers.FailOnError(t, err)
+
+	_, err = hooks.After(ctx, query)
+	helpers.FailOnError(t, err)
+
+	assert.Contains(t, buf.String(), "query `"+query+"`")
+	assert.Contains(t, buf.String(), " took ")
+}
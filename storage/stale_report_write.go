@@ -0,0 +1,110 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// WriteStaleReport records that the report from the cluster was rejected
+// because a more recent report of the cluster was already stored.
+// lastCheckedTime is the time from the rejected report and producedAt is the
+// time it was produced to Kafka, zero producedAt means it's not known.
+func (storage DBStorage) WriteStaleReport(
+	orgID types.OrgID, clusterName types.ClusterName, lastCheckedTime, producedAt time.Time,
+) error {
+	ctx, cancel := storage.operationContext(writeOperation)
+	defer cancel()
+
+	var producedAtValue interface{}
+	if !producedAt.IsZero() {
+		producedAtValue = producedAt
+	}
+
+	_, err := storage.connection.ExecContext(ctx, `
+		INSERT INTO stale_report_write
+			(org_id, cluster_id, rejected_count, last_rejected_at, last_checked_at, produced_at)
+		VALUES ($1, $2, 1, $3, $4, $5)
+		ON CONFLICT (org_id, cluster_id) DO UPDATE SET
+			rejected_count = stale_report_write.rejected_count + 1,
+			last_rejected_at = $3,
+			last_checked_at = $4,
+			produced_at = $5;
+	`, orgID, clusterName, time.Now(), lastCheckedTime, producedAtValue)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to write stale report")
+		return types.ConvertDBError(err, []interface{}{orgID, clusterName})
+	}
+
+	return nil
+}
+
+// ReadStaleReportWrites returns statistics of rejected stale reports for all
+// clusters ordered by organization and cluster
+func (storage DBStorage) ReadStaleReportWrites() ([]types.StaleReportWrite, error) {
+	ctx, cancel := storage.operationContext(aggregationOperation)
+	defer cancel()
+
+	staleWrites := make([]types.StaleReportWrite, 0)
+
+	rows, err := storage.readConnection().QueryContext(ctx, `
+		SELECT org_id, cluster_id, rejected_count, last_rejected_at, last_checked_at, produced_at
+		FROM stale_report_write
+		ORDER BY org_id, cluster_id;
+	`)
+	if err != nil {
+		return staleWrites, types.ConvertDBError(err, nil)
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var (
+			staleWrite                    types.StaleReportWrite
+			lastRejectedAt, lastCheckedAt time.Time
+			producedAt                    sql.NullTime
+		)
+
+		err = rows.Scan(
+			&staleWrite.OrgID,
+			&staleWrite.ClusterName,
+			&staleWrite.RejectedCount,
+			&lastRejectedAt,
+			&lastCheckedAt,
+			&producedAt,
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("ReadStaleReportWrites")
+			return staleWrites, types.ConvertDBError(err, nil)
+		}
+
+		staleWrite.LastRejectedAt = types.Timestamp(lastRejectedAt.UTC().Format(time.RFC3339))
+		staleWrite.LastCheckedAt = types.Timestamp(lastCheckedAt.UTC().Format(time.RFC3339))
+
+		if producedAt.Valid {
+			staleWrite.ProducedAt = types.Timestamp(producedAt.Time.UTC().Format(time.RFC3339))
+			skew := lastCheckedAt.Sub(producedAt.Time).Seconds()
+			staleWrite.TimestampSkewSeconds = &skew
+		}
+
+		staleWrites = append(staleWrites, staleWrite)
+	}
+
+	return staleWrites, nil
+}
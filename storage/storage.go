@@ -27,10 +27,18 @@ See the License for the specific language governing permissions and
 package storage
 
 import (
+	"context"
 	"database/sql"
 	sql_driver "database/sql/driver"
 	"encoding/json"
 	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/Shopify/sarama"
@@ -40,6 +48,8 @@ import (
 	_ "github.com/mattn/go-sqlite3" // SQLite database driver
 	"github.com/rs/zerolog/log"
 
+	"github.com/RedHatInsights/insights-results-aggregator/chaos"
+	"github.com/RedHatInsights/insights-results-aggregator/events"
 	"github.com/RedHatInsights/insights-results-aggregator/metrics"
 	"github.com/RedHatInsights/insights-results-aggregator/migration"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
@@ -50,8 +60,9 @@ type Storage interface {
 	Init() error
 	Close() error
 	ListOfOrgs() ([]types.OrgID, error)
+	ListOfOrgsWithSummary() ([]types.OrgSummary, error)
 	ListOfClustersForOrg(
-		orgID types.OrgID, timeLimit time.Time) ([]types.ClusterName, error,
+		orgID types.OrgID, timeLimit time.Time, offset, limit int) ([]types.ClusterName, error,
 	)
 	ReadReportForCluster(
 		orgID types.OrgID, clusterName types.ClusterName) ([]types.RuleOnReport, types.Timestamp, error,
@@ -64,7 +75,9 @@ type Storage interface {
 		orgID types.OrgID, clusterName types.ClusterName, ruleID types.RuleID, errorKey types.ErrorKey,
 	) (interface{}, error)
 	ReadReportForClusterByClusterName(clusterName types.ClusterName) ([]types.RuleOnReport, types.Timestamp, error)
-	GetLatestKafkaOffset() (types.KafkaOffset, error)
+	GetLatestKafkaOffset(topic string, partition int32) (types.KafkaOffset, error)
+	GetLatestKafkaOffsets() ([]types.KafkaPartitionOffset, error)
+	WriteKafkaOffset(topic string, partition int32, offset types.KafkaOffset) error
 	WriteReportForCluster(
 		orgID types.OrgID,
 		clusterName types.ClusterName,
@@ -73,6 +86,13 @@ type Storage interface {
 		collectedAtTime time.Time,
 		kafkaOffset types.KafkaOffset,
 	) error
+	WriteFailedReportForCluster(
+		orgID types.OrgID,
+		clusterName types.ClusterName,
+		lastCheckedTime time.Time,
+		kafkaOffset types.KafkaOffset,
+	) error
+	ReadReportStatusForCluster(orgID types.OrgID, clusterName types.ClusterName) (types.ReportStatus, error)
 	ReportsCount() (int, error)
 	VoteOnRule(
 		clusterID types.ClusterName,
@@ -107,6 +127,7 @@ type Storage interface {
 	) (*UserFeedbackOnRule, error)
 	DeleteReportsForOrg(orgID types.OrgID) error
 	DeleteReportsForCluster(clusterName types.ClusterName) error
+	DeleteClusterReport(orgID types.OrgID, clusterName types.ClusterName, deletedAt time.Time) error
 	ToggleRuleForCluster(
 		clusterID types.ClusterName,
 		ruleID types.RuleID,
@@ -121,6 +142,8 @@ type Storage interface {
 		types.ClusterName,
 		[]types.RuleOnReport,
 	) (map[types.RuleID]bool, error)
+	ReadRuleDisableDetails(clusterID types.ClusterName) ([]RuleDisableDetails, error)
+	ReadRuleHitsFirstSeen(orgID types.OrgID, clusterName types.ClusterName) ([]RuleHitFirstSeen, error)
 	DeleteFromRuleClusterToggle(
 		clusterID types.ClusterName,
 		ruleID types.RuleID,
@@ -138,6 +161,123 @@ type Storage interface {
 		userID types.UserID,
 	) (map[types.RuleID]UserFeedbackOnRule, error)
 	DoesClusterExist(clusterID types.ClusterName) (bool, error)
+	DoesClusterExistWithOrgID(clusterID types.ClusterName) (bool, types.OrgID, error)
+	ReadOrgIDsOfClusters(clusterNames []types.ClusterName) (map[types.ClusterName]types.OrgID, error)
+	RebuildClustersLastCheckedCache() (int, error)
+	GetClustersLastCheckedCacheStats() (ClustersLastCheckedCacheStats, error)
+	CheckClustersLastCheckedDivergence(sampleSize int) (ClustersLastCheckedDivergence, error)
+	RecomputeAggregates() (AggregatesRecomputation, error)
+	CreateAPIKey(keyID, name string, scopes []string, keyHash string, expiresAt time.Time) error
+	RotateAPIKey(keyID, keyHash string) error
+	RevokeAPIKey(keyID string) error
+	ReadAPIKey(keyID string) (types.APIKey, string, error)
+	ReadAPIKeys() ([]types.APIKey, error)
+	ReadClusterOrgChanges(since time.Time) ([]types.ClusterOrgChange, error)
+	WriteExternalResults(
+		orgID types.OrgID,
+		clusterName types.ClusterName,
+		source string,
+		results []types.ExternalResult,
+		lastCheckedTime time.Time,
+		kafkaOffset types.KafkaOffset,
+	) error
+	ReadExternalResults(clusterName types.ClusterName, source string) ([]types.ExternalResult, error)
+	ReadDBSchema() (types.DBSchema, error)
+	WriteReportMessageKey(
+		orgID types.OrgID,
+		clusterName types.ClusterName,
+		lastCheckedTime time.Time,
+		key string,
+	) error
+	LookupMessageKey(key string) (types.MessageKeyLookup, error)
+	WriteClusterClass(orgID types.OrgID, clusterName types.ClusterName, class types.ClusterClass) error
+	ReadClusterClasses(orgID types.OrgID) (map[types.ClusterName]types.ClusterClass, error)
+	ReadRuleHitOccurrences(
+		clusterName types.ClusterName,
+		ruleID types.RuleID,
+		errorKey types.ErrorKey,
+	) ([]types.RuleHitOccurrence, error)
+	ReadReportChecks(clusterName types.ClusterName, limit int) ([]types.ReportCheck, error)
+	WriteReportHistory(
+		orgID types.OrgID,
+		clusterName types.ClusterName,
+		report types.ClusterReport,
+		lastCheckedTime time.Time,
+		kafkaOffset types.KafkaOffset,
+	) error
+	IterateReports(callback func(ReportRecord) error) error
+	IterateRuleHits(callback func(RuleHitRecord) error) error
+	DeleteReportsNotCheckedSince(threshold time.Time) (int, error)
+	CountClustersNotCheckedSince(threshold time.Time) (int, error)
+	AddClusterAnnotation(
+		clusterID types.ClusterName, author types.UserID, message string,
+	) (types.ClusterAnnotation, error)
+	ReadClusterAnnotations(clusterID types.ClusterName) ([]types.ClusterAnnotation, error)
+	DeleteClusterAnnotation(clusterID types.ClusterName, annotationID string) error
+	ReadOrgInfo(orgID types.OrgID) (types.OrgInfo, error)
+	ImportUserFeedback(feedbacks []UserFeedbackOnRule) error
+	GetUserFeedbackOnRulesForClusters(
+		clusterNames []types.ClusterName, userID types.UserID,
+	) ([]UserFeedbackOnRule, error)
+	WriteStaleReport(
+		orgID types.OrgID, clusterName types.ClusterName, lastCheckedTime, producedAt time.Time,
+	) error
+	ReadStaleReportWrites() ([]types.StaleReportWrite, error)
+	ReadRuleHitsForCluster(clusterName types.ClusterName) ([]RuleHitRecord, error)
+	AddOrgUsage(orgID types.OrgID, messagesProcessed, bytesStored, apiCalls int64) error
+	ReadOrgUsage(month string) ([]types.OrgUsage, error)
+	ReadRuleResolutionRates() ([]types.RuleResolutionRate, error)
+	ReadRuleResolutionRatesForOrg(orgID types.OrgID) ([]types.RuleResolutionRate, error)
+	ReadTopRules(from, to time.Time, limit int) ([]types.TopRule, error)
+	ReadTopRulesForOrg(orgID types.OrgID, from, to time.Time, limit int) ([]types.TopRule, error)
+	ReadOrgReport(orgID types.OrgID) ([]types.OrgReportRule, error)
+	FreezeOrg(orgID types.OrgID, reason string) error
+	UnfreezeOrg(orgID types.OrgID) error
+	IsOrgFrozen(orgID types.OrgID) (bool, error)
+	ReadFrozenOrgs() ([]types.OrgFreeze, error)
+	ReadVoteSummaryOnRule(
+		clusterID types.ClusterName, ruleID types.RuleID, errorKey types.ErrorKey,
+	) (types.VoteSummary, error)
+	ReadVoteSummaryOnRuleForOrg(
+		orgID types.OrgID, ruleID types.RuleID, errorKey types.ErrorKey,
+	) (types.VoteSummary, error)
+	VoteOnRuleForOrg(
+		orgID types.OrgID,
+		ruleID types.RuleID,
+		errorKey types.ErrorKey,
+		userID types.UserID,
+		userVote types.UserVote,
+		voteMessage string,
+	) error
+	AddOrUpdateFeedbackOnRuleForOrg(
+		orgID types.OrgID,
+		ruleID types.RuleID,
+		errorKey types.ErrorKey,
+		userID types.UserID,
+		message string,
+	) error
+	GetUserFeedbackOnRuleForOrg(
+		orgID types.OrgID, ruleID types.RuleID, errorKey types.ErrorKey, userID types.UserID,
+	) (*OrgUserFeedbackOnRule, error)
+	GetFeedbackOnRuleForOrg(
+		orgID types.OrgID, ruleID types.RuleID, errorKey types.ErrorKey,
+	) ([]OrgUserFeedbackOnRule, error)
+	GetDBDriverType() types.DBDriver
+	Capabilities() Capabilities
+	ArchiveReportsNotCheckedSince(threshold time.Time, limit int) (int, error)
+	IterateRuleHitsForRule(
+		orgID types.OrgID,
+		ruleID types.RuleID,
+		errorKey types.ErrorKey,
+		offset, limit int,
+		callback func(RuleHitRecord) error,
+	) error
+	ReadReportHistoryForCluster(
+		orgID types.OrgID, clusterName types.ClusterName, from, to time.Time,
+	) ([]types.HistoricalReport, error)
+	CleanupOldData(policy RetentionPolicy) (map[string]int, error)
+	ReadOrgRuleDisables(orgID types.OrgID, mostDisabledLimit int) (types.OrgRuleDisables, error)
+	CountClustersForOrg(orgID types.OrgID, timeLimit time.Time) (int, error)
 }
 
 // DBStorage is an implementation of Storage interface that use selected SQL like database
@@ -145,45 +285,156 @@ type Storage interface {
 // sql package. It is possible to configure connection via Configuration structure.
 // SQLQueriesLog is log for sql queries, default is nil which means nothing is logged
 type DBStorage struct {
-	connection   *sql.DB
+	connection *sql.DB
+	// replica is the connection to read-only replica used by read queries
+	// of the REST API, nil when all queries go to the primary
+	replica      *sql.DB
 	dbDriverType types.DBDriver
+	// schema is the PostgreSQL schema used by all queries, empty for the
+	// default one
+	schema string
 	// clusterLastCheckedDict is a dictionary of timestamps when the clusters were last checked.
 	clustersLastChecked map[types.ClusterName]time.Time
+	// clustersLastCheckedMutex guards clustersLastChecked as the cache can be
+	// rebuilt via REST API while reports are being consumed
+	clustersLastCheckedMutex *sync.RWMutex
+	// clusterLocks serializes concurrent writes of reports of one cluster
+	clusterLocks *clusterLocks
+	// timeouts are deadlines of reads, writes and aggregations
+	timeouts operationTimeouts
+	// schemaVersion is the migration version of the database, queries
+	// are adapted to its schema during rolling upgrades
+	schemaVersion *schemaVersion
+	// checkHistorySize is the number of the last checks of every cluster
+	// whose statistics are kept, 0 disables the statistics
+	checkHistorySize int
+	// reportHistorySize is the number of the last reports of every cluster
+	// kept in the history of reports, 0 disables the history
+	reportHistorySize int
+	// clusterOrgConflictPolicy is applied to reports of clusters already
+	// stored under another organization
+	clusterOrgConflictPolicy string
+	// consumerErrorMessage specifies how message values are stored with
+	// consumer errors
+	consumerErrorMessage consumerErrorMessageOptions
+	// reportCache contains parsed rule hits of recently read reports, nil
+	// when the cache is disabled
+	reportCache *reportCache
+	// reportArchive contains reports moved out of the database, nil when
+	// reports are not archived
+	reportArchive ReportArchive
 }
 
+// pgSchemaRegex matches allowed names of PostgreSQL schemas. Only lowercase
+// names are allowed, because unquoted identifiers in search_path are folded to
+// lowercase by PostgreSQL.
+var pgSchemaRegex = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
 // New function creates and initializes a new instance of Storage interface
 func New(configuration Configuration) (*DBStorage, error) {
-	driverType, driverName, dataSource, err := initAndGetDriver(configuration)
+	driverType, driverName, dataSources, err := initAndGetDriver(configuration)
 	if err != nil {
 		return nil, err
 	}
 
+	redactedDataSources := make([]string, 0, len(dataSources))
+	for _, dataSource := range dataSources {
+		redactedDataSources = append(redactedDataSources, redactDataSource(dataSource))
+	}
+
 	log.Info().Msgf(
 		"Making connection to data storage, driver=%s datasource=%s",
-		driverName, dataSource,
+		driverName, strings.Join(redactedDataSources, ","),
 	)
 
-	connection, err := sql.Open(driverName, dataSource)
+	var connection *sql.DB
+	if driverType == types.DBDriverPostgres && configuration.PGIAMAuth {
+		connection, err = openRDSIAMAuthConnection(driverName, dataSources, configuration.PGIAMRegion)
+	} else {
+		connection, err = openConnection(driverName, dataSources)
+	}
 	if err != nil {
 		log.Error().Err(err).Msg("Can not connect to data storage")
 		return nil, err
 	}
 
-	return NewFromConnection(connection, driverType), nil
+	configureConnectionPool(connection, configuration)
+
+	storage := NewFromConnection(connection, driverType)
+
+	if driverType == types.DBDriverPostgres && configuration.PGReadReplicaURL != "" {
+		replica, err := openReadReplica(driverName, configuration)
+		if err != nil {
+			log.Error().Err(err).Msg("Can not connect to read replica of data storage")
+			_ = connection.Close()
+			return nil, err
+		}
+
+		configureConnectionPool(replica, configuration)
+		storage.SetReadReplica(replica)
+	}
+	if driverType == types.DBDriverPostgres {
+		storage.schema = configuration.PGSchema
+	}
+	storage.SetOperationTimeouts(
+		configuration.ReadTimeout,
+		configuration.WriteTimeout,
+		configuration.AggregationTimeout,
+	)
+	storage.SetCheckHistorySize(configuration.CheckHistorySize)
+	storage.SetReportHistorySize(configuration.ReportHistorySize)
+	storage.SetReportCacheSize(configuration.ReportCacheSize)
+	storage.SetClusterOrgConflictPolicy(configuration.ClusterOrgConflictPolicy)
+	storage.SetConsumerErrorMessageOptions(
+		configuration.ConsumerErrorMaxMessageSize,
+		configuration.ConsumerErrorCompressMessage,
+	)
+
+	return storage, nil
 }
 
 // NewFromConnection function creates and initializes a new instance of Storage interface from prepared connection
 func NewFromConnection(connection *sql.DB, dbDriverType types.DBDriver) *DBStorage {
 	return &DBStorage{
-		connection:          connection,
-		dbDriverType:        dbDriverType,
-		clustersLastChecked: map[types.ClusterName]time.Time{},
+		connection:               connection,
+		dbDriverType:             dbDriverType,
+		clustersLastChecked:      map[types.ClusterName]time.Time{},
+		clustersLastCheckedMutex: &sync.RWMutex{},
+		clusterLocks:             newClusterLocks(),
+		schemaVersion:            &schemaVersion{version: migration.GetMaxVersion()},
+		clusterOrgConflictPolicy: ClusterOrgConflictMove,
 	}
 }
 
-// initAndGetDriver initializes driver(with logs if logSQLQueries is true),
-// checks if it's supported and returns driver type, driver name, dataSource and error
-func initAndGetDriver(configuration Configuration) (driverType types.DBDriver, driverName string, dataSource string, err error) {
+// openConnection opens the database. When there are more data sources (one
+// for every PostgreSQL host), connections are opened to the first one that
+// allows writes.
+func openConnection(driverName string, dataSources []string) (*sql.DB, error) {
+	if len(dataSources) == 1 {
+		return sql.Open(driverName, dataSources[0])
+	}
+
+	// sql.Open doesn't connect to the database, it's the only way to get
+	// registered driver by its name
+	db, err := sql.Open(driverName, "")
+	if err != nil {
+		return nil, err
+	}
+	driver := db.Driver()
+	_ = db.Close()
+
+	return sql.OpenDB(&failoverConnector{
+		driver:      driver,
+		dataSources: dataSources,
+	}), nil
+}
+
+// initAndGetDriver initializes driver(with logs if logSQLQueries is true and
+// with fault injection if chaos mode is enabled),
+// checks if it's supported and returns driver type, driver name, data sources
+// and error. There are more data sources only when more PostgreSQL hosts are
+// configured.
+func initAndGetDriver(configuration Configuration) (driverType types.DBDriver, driverName string, dataSources []string, err error) {
 	var driver sql_driver.Driver
 	driverName = configuration.Driver
 
@@ -191,34 +442,93 @@ func initAndGetDriver(configuration Configuration) (driverType types.DBDriver, d
 	case "sqlite3":
 		driverType = types.DBDriverSQLite3
 		driver = &sqlite3.SQLiteDriver{}
-		dataSource = configuration.SQLiteDataSource
+		dataSources = []string{configuration.SQLiteDataSource}
 	case "postgres":
 		driverType = types.DBDriverPostgres
 		driver = &pq.Driver{}
-		dataSource = fmt.Sprintf(
-			"postgresql://%v:%v@%v:%v/%v?%v",
-			configuration.PGUsername,
-			configuration.PGPassword,
-			configuration.PGHost,
-			configuration.PGPort,
-			configuration.PGDBName,
-			configuration.PGParams,
-		)
+
+		if configuration.PGSchema != "" && !pgSchemaRegex.MatchString(configuration.PGSchema) {
+			err = fmt.Errorf("invalid PostgreSQL schema name '%v'", configuration.PGSchema)
+			return
+		}
+
+		// the schema is used by all queries and migrations via search_path
+		// run-time parameter sent when the connection is established, TLS
+		// options are sent the same way
+		connectionParams := pgConnectionParams(configuration)
+
+		params := configuration.PGParams
+		for _, param := range connectionParams {
+			if params != "" {
+				params += "&"
+			}
+			params += param.name + "=" + url.QueryEscape(param.value)
+		}
+
+		// connection URL replaces all other connection options
+		if configuration.PGURL != "" {
+			var dataSource string
+			dataSource, err = pgURLDataSource(configuration.PGURL, connectionParams)
+			if err != nil {
+				return
+			}
+			dataSources = []string{dataSource}
+			break
+		}
+
+		var hosts []pgHost
+		hosts, err = parsePGHosts(configuration.PGHost, configuration.PGPort)
+		if err != nil {
+			return
+		}
+
+		for _, host := range hosts {
+			dataSources = append(dataSources, fmt.Sprintf(
+				"postgresql://%v:%v@%v/%v?%v",
+				configuration.PGUsername,
+				configuration.PGPassword,
+				net.JoinHostPort(host.host, strconv.Itoa(host.port)),
+				configuration.PGDBName,
+				params,
+			))
+		}
 	default:
+		if IsRegisteredDriver(driverName) {
+			// custom storages are created by NewStorage
+			err = fmt.Errorf("driver %v is not a SQL driver", driverName)
+			return
+		}
 		err = fmt.Errorf("driver %v is not supported", driverName)
 		return
 	}
 
-	if configuration.LogSQLQueries {
+	if chaos.DefaultInjector.Enabled() {
+		driverName = InitSQLDriverWithChaos(driver, driverName, chaos.DefaultInjector, configuration.LogSQLQueries)
+	} else if configuration.LogSQLQueries {
 		driverName = InitSQLDriverWithLogs(driver, driverName)
 	}
 
 	return
 }
 
+// CreateSchemaIfNotExists creates the configured PostgreSQL schema if it
+// doesn't exist yet. It does nothing when the default schema is used.
+func (storage DBStorage) CreateSchemaIfNotExists() error {
+	if storage.schema == "" {
+		return nil
+	}
+
+	_, err := storage.connection.Exec("CREATE SCHEMA IF NOT EXISTS " + pq.QuoteIdentifier(storage.schema) + ";")
+	return err
+}
+
 // MigrateToLatest migrates the database to the latest available
 // migration version. This must be done before an Init() call.
 func (storage DBStorage) MigrateToLatest() error {
+	if err := storage.CreateSchemaIfNotExists(); err != nil {
+		return err
+	}
+
 	if err := migration.InitInfoTable(storage.connection); err != nil {
 		return err
 	}
@@ -230,36 +540,20 @@ func (storage DBStorage) MigrateToLatest() error {
 // tasks necessary for further service operation.
 func (storage DBStorage) Init() error {
 	// Read clusterName:LastChecked dictionary from DB.
-	rows, err := storage.connection.Query("SELECT cluster, last_checked_at FROM report;")
-	if err != nil {
-		return err
-	}
-
-	for rows.Next() {
-		var (
-			clusterName types.ClusterName
-			lastChecked time.Time
-		)
-
-		if err := rows.Scan(&clusterName, &lastChecked); err != nil {
-			if closeErr := rows.Close(); closeErr != nil {
-				log.Error().Err(closeErr).Msg("Unable to close the DB rows handle")
-			}
-			return err
-		}
-
-		storage.clustersLastChecked[clusterName] = lastChecked
-	}
-
-	// Not using defer to close the rows here to:
-	// - make errcheck happy (it doesn't like ignoring returned errors),
-	// - return a possible error returned by the Close method.
-	return rows.Close()
+	_, err := storage.RebuildClustersLastCheckedCache()
+	return err
 }
 
 // Close method closes the connection to database. Needs to be called at the end of application lifecycle.
 func (storage DBStorage) Close() error {
 	log.Info().Msg("Closing connection to data storage")
+	if storage.replica != nil {
+		err := storage.replica.Close()
+		if err != nil {
+			log.Error().Err(err).Msg("Can not close connection to read replica of data storage")
+			return err
+		}
+	}
 	if storage.connection != nil {
 		err := storage.connection.Close()
 		if err != nil {
@@ -287,9 +581,12 @@ func closeRows(rows *sql.Rows) {
 
 // ListOfOrgs reads list of all organizations that have at least one cluster report
 func (storage DBStorage) ListOfOrgs() ([]types.OrgID, error) {
+	ctx, cancel := storage.operationContext(aggregationOperation)
+	defer cancel()
+
 	orgs := make([]types.OrgID, 0)
 
-	rows, err := storage.connection.Query("SELECT DISTINCT org_id FROM report ORDER BY org_id;")
+	rows, err := storage.readConnection().QueryContext(ctx, "SELECT DISTINCT org_id FROM report ORDER BY org_id;")
 	err = types.ConvertDBError(err, nil)
 	if err != nil {
 		return orgs, err
@@ -309,8 +606,15 @@ func (storage DBStorage) ListOfOrgs() ([]types.OrgID, error) {
 	return orgs, nil
 }
 
-// ListOfClustersForOrg reads list of all clusters fro given organization
-func (storage DBStorage) ListOfClustersForOrg(orgID types.OrgID, timeLimit time.Time) ([]types.ClusterName, error) {
+// ListOfClustersForOrg reads list of clusters fro given organization ordered
+// by name. First offset clusters are skipped and at most limit clusters are
+// returned, limit 0 means all remaining clusters.
+func (storage DBStorage) ListOfClustersForOrg(
+	orgID types.OrgID, timeLimit time.Time, offset, limit int,
+) ([]types.ClusterName, error) {
+	ctx, cancel := storage.operationContext(aggregationOperation)
+	defer cancel()
+
 	clusters := make([]types.ClusterName, 0)
 
 	q := `
@@ -318,10 +622,20 @@ func (storage DBStorage) ListOfClustersForOrg(orgID types.OrgID, timeLimit time.
 		FROM report
 		WHERE org_id = $1
 		AND reported_at >= $2
-		ORDER BY cluster;
+		ORDER BY cluster
 	`
+	args := []interface{}{orgID, timeLimit}
 
-	rows, err := storage.connection.Query(q, orgID, timeLimit)
+	if offset > 0 || limit > 0 {
+		// SQLite doesn't support OFFSET without LIMIT
+		if limit <= 0 {
+			limit = math.MaxInt32
+		}
+		q += " LIMIT $3 OFFSET $4"
+		args = append(args, limit, offset)
+	}
+
+	rows, err := storage.readConnection().QueryContext(ctx, q, args...)
 
 	err = types.ConvertDBError(err, orgID)
 	if err != nil {
@@ -342,9 +656,27 @@ func (storage DBStorage) ListOfClustersForOrg(orgID types.OrgID, timeLimit time.
 	return clusters, nil
 }
 
+// CountClustersForOrg returns number of clusters of the organization
+// reported since the time limit
+func (storage DBStorage) CountClustersForOrg(orgID types.OrgID, timeLimit time.Time) (int, error) {
+	ctx, cancel := storage.operationContext(aggregationOperation)
+	defer cancel()
+
+	count := 0
+	err := storage.readConnection().QueryRowContext(
+		ctx,
+		"SELECT COUNT(*) FROM report WHERE org_id = $1 AND reported_at >= $2;", orgID, timeLimit,
+	).Scan(&count)
+
+	return count, types.ConvertDBError(err, orgID)
+}
+
 // GetOrgIDByClusterID reads OrgID for specified cluster
 func (storage DBStorage) GetOrgIDByClusterID(cluster types.ClusterName) (types.OrgID, error) {
-	row := storage.connection.QueryRow("SELECT org_id FROM report WHERE cluster = $1 ORDER BY org_id;", cluster)
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
+	row := storage.connection.QueryRowContext(ctx, "SELECT org_id FROM report WHERE cluster = $1 ORDER BY org_id;", cluster)
 
 	var orgID uint64
 	err := row.Scan(&orgID)
@@ -368,7 +700,11 @@ func parseTemplateData(templateData []byte) interface{} {
 	return templateDataJSON
 }
 
-func parseRuleRows(rows *sql.Rows) ([]types.RuleOnReport, error) {
+// parseRuleRows reads rule hits of the report, ErrReportInconsistent is
+// returned when some rule hit doesn't belong to the generation of the report
+func parseRuleRows(rows *sql.Rows, reportGeneration int64) ([]types.RuleOnReport, error) {
+	defer closeRows(rows)
+
 	report := make([]types.RuleOnReport, 0)
 
 	for rows.Next() {
@@ -376,14 +712,24 @@ func parseRuleRows(rows *sql.Rows) ([]types.RuleOnReport, error) {
 			templateDataBytes []byte
 			ruleFQDN          types.RuleID
 			errorKey          types.ErrorKey
+			generation        int64
 		)
 
-		err := rows.Scan(&templateDataBytes, &ruleFQDN, &errorKey)
+		err := rows.Scan(&templateDataBytes, &ruleFQDN, &errorKey, &generation)
 		if err != nil {
 			log.Error().Err(err).Msg("ReportListForCluster")
 			return report, err
 		}
 
+		if generation != reportGeneration {
+			log.Warn().Msgf(
+				"Rule hit %v|%v belongs to generation %d of the report instead of %d",
+				ruleFQDN, errorKey, generation, reportGeneration,
+			)
+			metrics.InconsistentReportReads.Inc()
+			return make([]types.RuleOnReport, 0), types.ErrReportInconsistent
+		}
+
 		templateData := parseTemplateData(templateDataBytes)
 		rule := types.RuleOnReport{
 			Module:       ruleFQDN,
@@ -422,6 +768,9 @@ func argsWithClusterNames(clusterNames []types.ClusterName) []interface{} {
 
 // ReadOrgIDsForClusters read organization IDs for given list of cluster names.
 func (storage DBStorage) ReadOrgIDsForClusters(clusterNames []types.ClusterName) ([]types.OrgID, error) {
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
 	// stub for return value
 	ids := make([]types.OrgID, 0)
 
@@ -436,7 +785,7 @@ func (storage DBStorage) ReadOrgIDsForClusters(clusterNames []types.ClusterName)
 	query := "SELECT DISTINCT org_id FROM report WHERE cluster in (" + inClausule + ");"
 
 	// select results from the database
-	rows, err := storage.connection.Query(query, args...)
+	rows, err := storage.readConnection().QueryContext(ctx, query, args...)
 	if err != nil {
 		log.Error().Err(err).Msg("query to get org ids")
 		return ids, err
@@ -462,6 +811,9 @@ func (storage DBStorage) ReadOrgIDsForClusters(clusterNames []types.ClusterName)
 // ReadReportsForClusters function reads reports for given list of cluster
 // names.
 func (storage DBStorage) ReadReportsForClusters(clusterNames []types.ClusterName) (map[types.ClusterName]types.ClusterReport, error) {
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
 	// stub for return value
 	reports := make(map[types.ClusterName]types.ClusterReport)
 
@@ -476,7 +828,7 @@ func (storage DBStorage) ReadReportsForClusters(clusterNames []types.ClusterName
 	query := "SELECT cluster, report FROM report WHERE cluster in (" + inClausule + ");"
 
 	// select results from the database
-	rows, err := storage.connection.Query(query, args...)
+	rows, err := storage.readConnection().QueryContext(ctx, query, args...)
 	if err != nil {
 		return reports, err
 	}
@@ -506,38 +858,73 @@ func (storage DBStorage) ReadReportsForClusters(clusterNames []types.ClusterName
 func (storage DBStorage) ReadReportForCluster(
 	orgID types.OrgID, clusterName types.ClusterName,
 ) ([]types.RuleOnReport, types.Timestamp, error) {
-	var lastChecked time.Time
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
+	var (
+		lastChecked types.NullTime
+		generation  int64
+	)
 	report := make([]types.RuleOnReport, 0)
+	generationColumn := storage.reportGenerationColumn()
 
-	err := storage.connection.QueryRow(
-		"SELECT last_checked_at FROM report WHERE org_id = $1 AND cluster = $2;", orgID, clusterName,
-	).Scan(&lastChecked)
+	// disable "G202 (CWE-89): SQL string concatenation"
+	// #nosec G202
+	err := storage.readConnection().QueryRowContext(
+		ctx,
+		"SELECT last_checked_at, "+generationColumn+" FROM report WHERE org_id = $1 AND cluster = $2;", orgID, clusterName,
+	).Scan(&lastChecked, &generation)
 	err = types.ConvertDBError(err, []interface{}{orgID, clusterName})
+	if _, notFound := err.(*types.ItemNotFoundError); notFound {
+		// the report could be moved into the archive
+		archived, archiveErr := storage.readArchivedReport(clusterName)
+		if archiveErr != nil {
+			return report, "", archiveErr
+		}
+		if archived != nil && archived.OrgID == orgID {
+			return archived.rules(), archived.lastCheckedTimestamp(), nil
+		}
+	}
 	if err != nil {
-		return report, types.Timestamp(lastChecked.UTC().Format(time.RFC3339)), err
+		return report, lastChecked.Timestamp(), err
 	}
 
-	rows, err := storage.connection.Query(
-		"SELECT template_data, rule_fqdn, error_key FROM rule_hit WHERE org_id = $1 AND cluster_id = $2;", orgID, clusterName,
+	version := reportVersion{generation: generation, lastChecked: lastChecked.Time}
+	if rules, found := storage.cachedReportRules(clusterName, version); found {
+		return rules, lastChecked.Timestamp(), nil
+	}
+
+	// disable "G202 (CWE-89): SQL string concatenation"
+	// #nosec G202
+	rows, err := storage.readConnection().QueryContext(
+		ctx,
+		"SELECT template_data, rule_fqdn, error_key, "+generationColumn+" FROM rule_hit WHERE org_id = $1 AND cluster_id = $2;",
+		orgID, clusterName,
 	)
 
 	err = types.ConvertDBError(err, []interface{}{orgID, clusterName})
 	if err != nil {
-		return report, types.Timestamp(lastChecked.UTC().Format(time.RFC3339)), err
+		return report, lastChecked.Timestamp(), err
 	}
 
-	report, err = parseRuleRows(rows)
+	report, err = parseRuleRows(rows, generation)
+	if err == nil {
+		storage.cacheReportRules(clusterName, version, report)
+	}
 
-	return report, types.Timestamp(lastChecked.UTC().Format(time.RFC3339)), err
+	return report, lastChecked.Timestamp(), err
 }
 
 // ReadSingleRuleTemplateData reads template data for a single rule
 func (storage DBStorage) ReadSingleRuleTemplateData(
 	orgID types.OrgID, clusterName types.ClusterName, ruleID types.RuleID, errorKey types.ErrorKey,
 ) (interface{}, error) {
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
 	var templateDataBytes []byte
 
-	err := storage.connection.QueryRow(`
+	err := storage.readConnection().QueryRowContext(ctx, `
 		SELECT template_data FROM rule_hit
 		WHERE org_id = $1 AND cluster_id = $2 AND rule_fqdn = $3 AND error_key = $4;
 	`,
@@ -555,15 +942,34 @@ func (storage DBStorage) ReadSingleRuleTemplateData(
 func (storage DBStorage) ReadReportForClusterByClusterName(
 	clusterName types.ClusterName,
 ) ([]types.RuleOnReport, types.Timestamp, error) {
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
 	report := make([]types.RuleOnReport, 0)
-	var lastChecked time.Time
+	var (
+		lastChecked types.NullTime
+		generation  int64
+	)
+	generationColumn := storage.reportGenerationColumn()
 
-	err := storage.connection.QueryRow(
-		"SELECT last_checked_at FROM report WHERE cluster = $1;", clusterName,
-	).Scan(&lastChecked)
+	// disable "G202 (CWE-89): SQL string concatenation"
+	// #nosec G202
+	err := storage.readConnection().QueryRowContext(
+		ctx,
+		"SELECT last_checked_at, "+generationColumn+" FROM report WHERE cluster = $1;", clusterName,
+	).Scan(&lastChecked, &generation)
 
 	switch {
 	case err == sql.ErrNoRows:
+		// the report could be moved into the archive
+		archived, archiveErr := storage.readArchivedReport(clusterName)
+		if archiveErr != nil {
+			return report, "", archiveErr
+		}
+		if archived != nil {
+			return archived.rules(), archived.lastCheckedTimestamp(), nil
+		}
+
 		return report, "", &types.ItemNotFoundError{
 			ItemID: fmt.Sprintf("%v", clusterName),
 		}
@@ -571,56 +977,28 @@ func (storage DBStorage) ReadReportForClusterByClusterName(
 		return report, "", err
 	}
 
-	rows, err := storage.connection.Query(
-		"SELECT template_data, rule_fqdn, error_key FROM rule_hit WHERE cluster_id = $1;", clusterName,
+	version := reportVersion{generation: generation, lastChecked: lastChecked.Time}
+	if rules, found := storage.cachedReportRules(clusterName, version); found {
+		return rules, lastChecked.Timestamp(), nil
+	}
+
+	// disable "G202 (CWE-89): SQL string concatenation"
+	// #nosec G202
+	rows, err := storage.readConnection().QueryContext(
+		ctx,
+		"SELECT template_data, rule_fqdn, error_key, "+generationColumn+" FROM rule_hit WHERE cluster_id = $1;", clusterName,
 	)
 
 	if err != nil {
-		return report, types.Timestamp(lastChecked.UTC().Format(time.RFC3339)), err
+		return report, lastChecked.Timestamp(), err
 	}
 
-	report, err = parseRuleRows(rows)
-
-	return report, types.Timestamp(lastChecked.UTC().Format(time.RFC3339)), err
-}
-
-// GetLatestKafkaOffset returns latest kafka offset from report table
-func (storage DBStorage) GetLatestKafkaOffset() (types.KafkaOffset, error) {
-	var offset types.KafkaOffset
-	err := storage.connection.QueryRow("SELECT COALESCE(MAX(kafka_offset), 0) FROM report;").Scan(&offset)
-	return offset, err
-}
-
-func (storage DBStorage) getReportUpsertQuery() string {
-	if storage.dbDriverType == types.DBDriverSQLite3 {
-		return `
-			INSERT OR REPLACE INTO report(org_id, cluster, report, reported_at, last_checked_at, kafka_offset)
-			VALUES ($1, $2, $3, $4, $5, $6)
-		`
+	report, err = parseRuleRows(rows, generation)
+	if err == nil {
+		storage.cacheReportRules(clusterName, version, report)
 	}
 
-	return `
-		INSERT INTO report(org_id, cluster, report, reported_at, last_checked_at, kafka_offset)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		ON CONFLICT (cluster)
-		DO UPDATE SET org_id = $1, report = $3, reported_at = $4, last_checked_at = $5, kafka_offset = $6
-	`
-}
-
-func (storage DBStorage) getRuleHitUpsertQuery() string {
-	if storage.dbDriverType == types.DBDriverSQLite3 {
-		return `
-			INSERT OR REPLACE INTO rule_hit(org_id, cluster_id, rule_fqdn, error_key, template_data)
-			VALUES ($1, $2, $3, $4, $5)
-		`
-	}
-
-	return `
-		INSERT INTO rule_hit(org_id, cluster_id, rule_fqdn, error_key, template_data)
-		VALUES ($1, $2, $3, $4, $5)
-		ON CONFLICT (org_id, cluster_id, rule_fqdn, error_key)
-		DO UPDATE SET template_data = $4
-	`
+	return report, lastChecked.Timestamp(), err
 }
 
 func (storage DBStorage) updateReport(
@@ -632,14 +1010,33 @@ func (storage DBStorage) updateReport(
 	lastCheckedTime time.Time,
 	kafkaOffset types.KafkaOffset,
 ) error {
-	// Get the UPSERT query for writing a report into the database.
-	reportUpsertQuery := storage.getReportUpsertQuery()
+	changes, err := updateRuleHitHistory(tx, orgID, clusterName, rules, lastCheckedTime)
+	if err != nil {
+		log.Err(err).Msgf("Unable to update rule hit history (org: %v, cluster: %v)", orgID, clusterName)
+		return err
+	}
 
-	// Get the UPSERT query for writing a rule into the database.
-	ruleUpsertQuery := storage.getRuleHitUpsertQuery()
+	err = storage.writeReportCheck(tx, orgID, clusterName, lastCheckedTime, changes)
+	if err != nil {
+		log.Err(err).Msgf("Unable to write statistics of the check (org: %v, cluster: %v)", orgID, clusterName)
+		return err
+	}
+
+	err = storage.writeLatestReportHistory(tx, orgID, clusterName, report, lastCheckedTime, kafkaOffset)
+	if err != nil {
+		log.Err(err).Msgf("Unable to write the report into history (org: %v, cluster: %v)", orgID, clusterName)
+		return err
+	}
+
+	// the transaction is bound to the context of the write operation
+	firstSeen, err := readRuleHitsFirstSeen(context.Background(), tx, orgID, clusterName)
+	if err != nil {
+		log.Err(err).Msgf("Unable to read first occurrences of rule hits (org: %v, cluster: %v)", orgID, clusterName)
+		return err
+	}
 
 	deleteQuery := "DELETE FROM rule_hit WHERE org_id = $1 AND cluster_id = $2;"
-	_, err := tx.Exec(deleteQuery, orgID, clusterName)
+	_, err = tx.Exec(deleteQuery, orgID, clusterName)
 	if err != nil {
 		log.Err(err).Msgf("Unable to remove previous cluster reports (org: %v, cluster: %v)", orgID, clusterName)
 		return err
@@ -649,7 +1046,12 @@ func (storage DBStorage) updateReport(
 	reportedAtTime := time.Now()
 
 	for _, rule := range rules {
-		_, err = tx.Exec(ruleUpsertQuery, orgID, clusterName, rule.Module, rule.ErrorKey, string(rule.TemplateData))
+		firstSeenAt, found := firstSeen[ruleHitKey{ruleID: rule.Module, errorKey: rule.ErrorKey}]
+		if !found {
+			firstSeenAt = lastCheckedTime
+		}
+
+		err = storage.upsertRuleHit(tx, orgID, clusterName, rule, firstSeenAt)
 		if err != nil {
 			log.Err(err).Msgf("Unable to upsert the cluster report rules (org: %v, cluster: %v, rule: %v|%v)",
 				orgID, clusterName, rule.Module, rule.ErrorKey,
@@ -658,12 +1060,50 @@ func (storage DBStorage) updateReport(
 		}
 	}
 
-	_, err = tx.Exec(reportUpsertQuery, orgID, clusterName, report, reportedAtTime, lastCheckedTime, kafkaOffset)
+	err = reportUpsert.exec(tx, storage.dbDriverType, []interface{}{
+		orgID, clusterName, report, reportedAtTime, lastCheckedTime, kafkaOffset, types.ReportStatusAnalyzed,
+	})
 	if err != nil {
 		log.Err(err).Msgf("Unable to upsert the cluster report (org: %v, cluster: %v)", orgID, clusterName)
 		return err
 	}
 
+	if storage.schemaAtLeast(reportGenerationVersion) {
+		err = writeReportGeneration(tx, clusterName)
+		if err != nil {
+			log.Err(err).Msgf("Unable to write generation of the cluster report (org: %v, cluster: %v)", orgID, clusterName)
+			return err
+		}
+	}
+
+	return updateOrgInfo(tx, orgID, reportedAtTime)
+}
+
+// upsertRuleHit writes one rule hit of the cluster and checks that its
+// template data were actually stored. The time the rule was seen for the
+// first time is kept when the rule hit is already stored.
+func (storage DBStorage) upsertRuleHit(
+	tx *sql.Tx, orgID types.OrgID, clusterName types.ClusterName, rule types.ReportItem, firstSeenAt time.Time,
+) error {
+	templateData := string(rule.TemplateData)
+	storedTemplateData := templateData
+
+	upsert := ruleHitUpsert
+	args := []interface{}{orgID, clusterName, rule.Module, rule.ErrorKey, templateData, firstSeenAt}
+	if !storage.schemaAtLeast(ruleHitFirstSeenVersion) {
+		upsert = legacyRuleHitUpsert
+		args = args[:len(args)-1]
+	}
+
+	err := upsert.exec(tx, storage.dbDriverType, args, &storedTemplateData)
+	if err != nil {
+		return err
+	}
+
+	if storedTemplateData != templateData {
+		return fmt.Errorf("template data of rule hit %v|%v were not updated", rule.Module, rule.ErrorKey)
+	}
+
 	return nil
 }
 
@@ -676,9 +1116,70 @@ func (storage DBStorage) WriteReportForCluster(
 	lastCheckedTime time.Time,
 	kafkaOffset types.KafkaOffset,
 ) error {
+	var orgChange *types.ClusterOrgChange
+
+	err := storage.writeClusterReportIfNewer(orgID, clusterName, lastCheckedTime, func(tx *sql.Tx) error {
+		var err error
+
+		orgChange, err = storage.resolveClusterOrgConflict(tx, orgID, clusterName, lastCheckedTime)
+		if err != nil {
+			return err
+		}
+
+		return storage.updateReport(tx, orgID, clusterName, report, rules, lastCheckedTime, kafkaOffset)
+	})
+	err = recordReportWrite(err)
+
+	publishClusterOrgConflict(orgChange, lastCheckedTime, err)
+
+	if err == nil {
+		events.DefaultBus.PublishReportWritten(events.ReportWrittenEvent{
+			OrgID:       orgID,
+			ClusterName: clusterName,
+			LastChecked: lastCheckedTime,
+			RuleHits:    len(rules),
+		})
+	}
+
+	return err
+}
+
+// writeClusterReport calls write in a transaction with the cluster locked,
+// unless a more recent report of the cluster is already stored
+func (storage DBStorage) writeClusterReport(
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+	lastCheckedTime time.Time,
+	write func(tx *sql.Tx) error,
+) error {
+	return oldReportError(storage.writeClusterReportIfNewer(orgID, clusterName, lastCheckedTime, write))
+}
+
+// writeClusterReportIfNewer does the same as writeClusterReport, but
+// errDuplicateReport is returned instead of ErrOldReport when the cache of
+// last checked timestamps contains the same time of the last check
+func (storage DBStorage) writeClusterReportIfNewer(
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+	lastCheckedTime time.Time,
+	write func(tx *sql.Tx) error,
+) error {
+	// Concurrent writes of the same cluster (from different partitions, for
+	// example) would interleave deletes and inserts of its rule hits
+	storage.clusterLocks.Lock(clusterName)
+	defer storage.clusterLocks.Unlock(clusterName)
+
 	// Skip writing the report if it isn't newer than a report
 	// that is already in the database for the same cluster.
-	if oldLastChecked, exists := storage.clustersLastChecked[clusterName]; exists && !lastCheckedTime.After(oldLastChecked) {
+	storage.clustersLastCheckedMutex.RLock()
+	oldLastChecked, exists := storage.clustersLastChecked[clusterName]
+	storage.clustersLastCheckedMutex.RUnlock()
+
+	if exists && !lastCheckedTime.After(oldLastChecked) {
+		metrics.ClustersLastCheckedCacheRejections.Inc()
+		if lastCheckedTime.Equal(oldLastChecked) {
+			return errDuplicateReport
+		}
 		return types.ErrOldReport
 	}
 
@@ -686,13 +1187,23 @@ func (storage DBStorage) WriteReportForCluster(
 		return fmt.Errorf("writing report with DB %v is not supported", storage.dbDriverType)
 	}
 
+	// the deadline doesn't include waiting for the cluster lock above
+	ctx, cancel := storage.operationContext(writeOperation)
+	defer cancel()
+
 	// Begin a new transaction.
-	tx, err := storage.connection.Begin()
+	tx, err := storage.connection.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
 	err = func(tx *sql.Tx) error {
+		// Other instances of the service can write the same cluster, the
+		// lock has to be acquired before the most recent report is checked
+		err := storage.lockClusterInTransaction(tx, clusterName)
+		if err != nil {
+			return err
+		}
 
 		// Check if there is a more recent report for the cluster already in the database.
 		rows, err := tx.Query(
@@ -710,22 +1221,27 @@ func (storage DBStorage) WriteReportForCluster(
 		if rows.Next() {
 			log.Warn().Msgf("Database already contains report for organization %d and cluster name %s more recent than %v",
 				orgID, clusterName, lastCheckedTime)
-			return nil
+			metrics.ClustersLastCheckedDBRejections.Inc()
+			return types.ErrOldReport
 		}
 
-		err = storage.updateReport(tx, orgID, clusterName, report, rules, lastCheckedTime, kafkaOffset)
+		err = write(tx)
 		if err != nil {
 			return err
 		}
 
+		storage.clustersLastCheckedMutex.Lock()
 		storage.clustersLastChecked[clusterName] = lastCheckedTime
-		metrics.WrittenReports.Inc()
+		storage.clustersLastCheckedMutex.Unlock()
 
 		return nil
 	}(tx)
 
 	finishTransaction(tx, err)
 
+	// the generation of the report was changed by the write
+	storage.invalidateCachedReport(clusterName)
+
 	return err
 }
 
@@ -746,8 +1262,11 @@ func finishTransaction(tx *sql.Tx, err error) {
 
 // ReportsCount reads number of all records stored in database
 func (storage DBStorage) ReportsCount() (int, error) {
+	ctx, cancel := storage.operationContext(aggregationOperation)
+	defer cancel()
+
 	count := -1
-	err := storage.connection.QueryRow("SELECT count(*) FROM report;").Scan(&count)
+	err := storage.connection.QueryRowContext(ctx, "SELECT count(*) FROM report;").Scan(&count)
 	err = types.ConvertDBError(err, nil)
 
 	return count, err
@@ -755,27 +1274,82 @@ func (storage DBStorage) ReportsCount() (int, error) {
 
 // DeleteReportsForOrg deletes all reports related to the specified organization from the storage.
 func (storage DBStorage) DeleteReportsForOrg(orgID types.OrgID) error {
-	_, err := storage.connection.Exec("DELETE FROM report WHERE org_id = $1;", orgID)
+	ctx, cancel := storage.operationContext(writeOperation)
+	defer cancel()
+
+	_, err := storage.connection.ExecContext(ctx, "DELETE FROM report WHERE org_id = $1;", orgID)
+	if err != nil {
+		return err
+	}
+
+	_, err = storage.connection.ExecContext(ctx, "DELETE FROM org_info WHERE org_id = $1;", orgID)
 	return err
 }
 
 // DeleteReportsForCluster deletes all reports related to the specified cluster from the storage.
 func (storage DBStorage) DeleteReportsForCluster(clusterName types.ClusterName) error {
-	_, err := storage.connection.Exec("DELETE FROM report WHERE cluster = $1;", clusterName)
+	ctx, cancel := storage.operationContext(writeOperation)
+	defer cancel()
+
+	_, err := storage.connection.ExecContext(ctx, "DELETE FROM report WHERE cluster = $1;", clusterName)
+	storage.invalidateCachedReport(clusterName)
+
 	return err
 }
 
+// DeleteClusterReport deletes the report and rule hits of the decommissioned
+// cluster, feedback and toggles of the cluster are deleted by cascade. The
+// deletion is skipped with ErrOldReport when more recent report than
+// deletedAt is stored, so reports of the cluster older than the deletion
+// are rejected afterwards.
+func (storage DBStorage) DeleteClusterReport(
+	orgID types.OrgID, clusterName types.ClusterName, deletedAt time.Time,
+) error {
+	return storage.writeClusterReport(orgID, clusterName, deletedAt, func(tx *sql.Tx) error {
+		_, err := tx.Exec(
+			"DELETE FROM rule_hit WHERE org_id = $1 AND cluster_id = $2;", orgID, clusterName,
+		)
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.Exec(
+			"DELETE FROM report WHERE org_id = $1 AND cluster = $2;", orgID, clusterName,
+		)
+		if err != nil {
+			return err
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			return &types.ItemNotFoundError{ItemID: fmt.Sprintf("%v/%v", orgID, clusterName)}
+		}
+
+		return nil
+	})
+}
+
 // GetConnection returns db connection(useful for testing)
 func (storage DBStorage) GetConnection() *sql.DB {
 	return storage.connection
 }
 
 // WriteConsumerError writes a report about a consumer error into the storage.
+// The message value is truncated and compressed according to the
+// configuration (see SetConsumerErrorMessageOptions).
 func (storage DBStorage) WriteConsumerError(msg *sarama.ConsumerMessage, consumerErr error) error {
-	_, err := storage.connection.Exec(`
+	ctx, cancel := storage.operationContext(writeOperation)
+	defer cancel()
+
+	_, err := storage.connection.ExecContext(ctx, `
 		INSERT INTO consumer_error (topic, partition, topic_offset, key, produced_at, consumed_at, message, error)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
-		msg.Topic, msg.Partition, msg.Offset, msg.Key, msg.Timestamp, time.Now().UTC(), msg.Value, consumerErr.Error())
+		msg.Topic, msg.Partition, msg.Offset, messageKey(msg.Key), msg.Timestamp, time.Now().UTC(),
+		storage.consumerErrorMessageValue(msg.Value), consumerErr.Error())
 
 	return err
 }
@@ -787,14 +1361,74 @@ func (storage DBStorage) GetDBDriverType() types.DBDriver {
 
 // DoesClusterExist checks if cluster with this id exists
 func (storage DBStorage) DoesClusterExist(clusterID types.ClusterName) (bool, error) {
-	err := storage.connection.QueryRow(
-		"SELECT cluster FROM report WHERE cluster = $1", clusterID,
-	).Scan(&clusterID)
+	exists, _, err := storage.DoesClusterExistWithOrgID(clusterID)
+	return exists, err
+}
+
+// DoesClusterExistWithOrgID checks if cluster with this id exists and returns
+// the organization it belongs to, so both the existence and the ownership of
+// the cluster can be checked by one query
+func (storage DBStorage) DoesClusterExistWithOrgID(clusterID types.ClusterName) (bool, types.OrgID, error) {
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
+	var orgID types.OrgID
+
+	err := storage.connection.QueryRowContext(
+		ctx,
+		"SELECT org_id FROM report WHERE cluster = $1 ORDER BY org_id", clusterID,
+	).Scan(&orgID)
 	if err == sql.ErrNoRows {
-		return false, nil
+		return false, 0, nil
 	} else if err != nil {
-		return false, err
+		return false, 0, err
+	}
+
+	return true, orgID, nil
+}
+
+// ReadOrgIDsOfClusters returns organizations the given clusters belong to,
+// clusters that don't exist are missing in the returned map
+func (storage DBStorage) ReadOrgIDsOfClusters(
+	clusterNames []types.ClusterName,
+) (map[types.ClusterName]types.OrgID, error) {
+	ctx, cancel := storage.operationContext(readOperation)
+	defer cancel()
+
+	orgIDs := make(map[types.ClusterName]types.OrgID, len(clusterNames))
+
+	if len(clusterNames) == 0 {
+		return orgIDs, nil
+	}
+
+	// disable "G202 (CWE-89): SQL string concatenation"
+	// #nosec G202
+	query := "SELECT cluster, org_id FROM report WHERE cluster in (" +
+		constructInClausule(len(clusterNames)) + ") ORDER BY org_id;"
+
+	rows, err := storage.readConnection().QueryContext(ctx, query, argsWithClusterNames(clusterNames)...)
+	if err != nil {
+		log.Error().Err(err).Msg("query to get org ids of clusters")
+		return orgIDs, err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var (
+			clusterName types.ClusterName
+			orgID       types.OrgID
+		)
+
+		if err := rows.Scan(&clusterName, &orgID); err != nil {
+			log.Error().Err(err).Msg("read org id of cluster")
+			return orgIDs, err
+		}
+
+		// the same as DoesClusterExistWithOrgID, the lowest org ID is used
+		if _, found := orgIDs[clusterName]; !found {
+			orgIDs[clusterName] = orgID
+		}
 	}
 
-	return true, nil
+	return orgIDs, nil
 }
@@ -24,12 +24,15 @@ import (
 	"testing"
 	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
 	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/RedHatInsights/insights-results-aggregator/events"
+	"github.com/RedHatInsights/insights-results-aggregator/migration"
 	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
@@ -63,6 +66,8 @@ func TestDBStorage_ToggleRuleForCluster(t *testing.T) {
 }
 
 func TestDBStorage_ToggleRuleForCluster_UnexpectedRuleToggleValue(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -73,6 +78,8 @@ func TestDBStorage_ToggleRuleForCluster_UnexpectedRuleToggleValue(t *testing.T)
 }
 
 func TestDBStorage_ToggleRuleForCluster_DBError(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	closer()
 
@@ -83,6 +90,8 @@ func TestDBStorage_ToggleRuleForCluster_DBError(t *testing.T) {
 }
 
 func TestDBStorageGetTogglesForRules_NoRules(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -93,6 +102,8 @@ func TestDBStorageGetTogglesForRules_NoRules(t *testing.T) {
 }
 
 func TestDBStorageGetTogglesForRules_AllRulesEnabled(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -103,6 +114,8 @@ func TestDBStorageGetTogglesForRules_AllRulesEnabled(t *testing.T) {
 }
 
 func TestDBStorageGetTogglesForRules_OneRuleDisabled(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -156,6 +169,56 @@ func TestDBStorageToggleRuleAndGet(t *testing.T) {
 	}
 }
 
+func TestDBStorage_ReadRuleDisableDetails(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReport3Rules(t, mockStorage)
+
+	for _, rule := range []struct {
+		ruleID types.RuleID
+		toggle storage.RuleToggle
+	}{
+		{testdata.Rule1ID, storage.RuleToggleDisable},
+		{testdata.Rule2ID, storage.RuleToggleDisable},
+		{testdata.Rule3ID, storage.RuleToggleEnable},
+	} {
+		helpers.FailOnError(t, mockStorage.ToggleRuleForCluster(
+			testdata.ClusterName, rule.ruleID, testdata.ErrorKey1, rule.toggle,
+		))
+	}
+
+	// only the most recent justification is returned
+	helpers.FailOnError(t, mockStorage.AddFeedbackOnRuleDisable(
+		testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID, "first reason",
+	))
+	helpers.FailOnError(t, mockStorage.AddFeedbackOnRuleDisable(
+		testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, "another-user", "second reason",
+	))
+
+	details, err := mockStorage.ReadRuleDisableDetails(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, details, 2)
+	for _, detail := range details {
+		assert.Equal(t, types.ErrorKey(testdata.ErrorKey1), detail.ErrorKey)
+		assert.True(t, detail.DisabledAt.Valid)
+
+		switch detail.RuleID {
+		case testdata.Rule1ID:
+			assert.Equal(t, types.UserID("another-user"), detail.DisabledBy)
+			assert.Equal(t, "second reason", detail.Justification)
+		case testdata.Rule2ID:
+			assert.Empty(t, detail.DisabledBy)
+			assert.Empty(t, detail.Justification)
+		default:
+			t.Errorf("unexpected disabled rule %v", detail.RuleID)
+		}
+	}
+}
+
 // TODO: make it work with the new arch
 //func TestDBStorageToggleRulesAndList(t *testing.T) {
 //	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
@@ -252,6 +315,82 @@ func TestDBStorageVoteOnRule_NoCluster(t *testing.T) {
 	}
 }
 
+// TestDBStorageImportUserFeedback checks that imported feedback keeps its
+// timestamps and overwrites the existing vote
+func TestDBStorageImportUserFeedback(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReport3Rules(t, mockStorage)
+
+	helpers.FailOnError(t, mockStorage.VoteOnRule(
+		testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID, types.UserVoteDislike, "",
+	))
+
+	addedAt := time.Date(2020, 8, 3, 10, 0, 0, 0, time.UTC)
+	updatedAt := addedAt.Add(time.Hour)
+
+	helpers.FailOnError(t, mockStorage.ImportUserFeedback([]storage.UserFeedbackOnRule{
+		{
+			ClusterID: testdata.ClusterName,
+			RuleID:    testdata.Rule1ID,
+			ErrorKey:  testdata.ErrorKey1,
+			UserID:    testdata.UserID,
+			Message:   "migrated",
+			UserVote:  types.UserVoteLike,
+			AddedAt:   addedAt,
+			UpdatedAt: updatedAt,
+		},
+	}))
+
+	feedback, err := mockStorage.GetUserFeedbackOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID)
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, types.UserVoteLike, feedback.UserVote)
+	assert.Equal(t, "migrated", feedback.Message)
+	assert.True(t, updatedAt.Equal(feedback.UpdatedAt))
+}
+
+// TestDBStorageImportUserFeedback_Transaction checks that no feedback is
+// imported when one of the records can't be written
+func TestDBStorageImportUserFeedback_Transaction(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReport3Rules(t, mockStorage)
+
+	now := time.Now()
+
+	err := mockStorage.ImportUserFeedback([]storage.UserFeedbackOnRule{
+		{
+			ClusterID: testdata.ClusterName,
+			RuleID:    testdata.Rule1ID,
+			ErrorKey:  testdata.ErrorKey1,
+			UserID:    testdata.UserID,
+			UserVote:  types.UserVoteLike,
+			AddedAt:   now,
+			UpdatedAt: now,
+		},
+		{
+			ClusterID: testdata.GetRandomClusterID(),
+			RuleID:    testdata.Rule1ID,
+			ErrorKey:  testdata.ErrorKey1,
+			UserID:    testdata.UserID,
+			UserVote:  types.UserVoteLike,
+			AddedAt:   now,
+			UpdatedAt: now,
+		},
+	})
+	assert.Error(t, err)
+
+	_, err = mockStorage.GetUserFeedbackOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
+
 // TODO: fix according to the new architecture
 //func TestDBStorageVoteOnRule_NoRule(t *testing.T) {
 //	for _, vote := range []types.UserVote{
@@ -304,7 +443,76 @@ func TestDBStorageChangeVote(t *testing.T) {
 	assert.NotEqual(t, feedback.AddedAt, feedback.UpdatedAt)
 }
 
+func TestDBStorageReadVoteSummaryOnRule(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReport3Rules(t, mockStorage)
+
+	// another cluster of the same organization and cluster of another one
+	cluster2 := testdata.GetRandomClusterID()
+	cluster3 := testdata.GetRandomClusterID()
+	helpers.FailOnError(t, mockStorage.WriteReportForCluster(
+		testdata.OrgID, cluster2, testdata.Report3Rules, testdata.Report3RulesParsed,
+		testdata.LastCheckedAt, testdata.KafkaOffset+1,
+	))
+	helpers.FailOnError(t, mockStorage.WriteReportForCluster(
+		testdata.Org2ID, cluster3, testdata.Report3Rules, testdata.Report3RulesParsed,
+		testdata.LastCheckedAt, testdata.KafkaOffset+2,
+	))
+
+	for _, vote := range []struct {
+		cluster types.ClusterName
+		userID  types.UserID
+		vote    types.UserVote
+	}{
+		{testdata.ClusterName, "1", types.UserVoteLike},
+		{testdata.ClusterName, "2", types.UserVoteDislike},
+		{testdata.ClusterName, "3", types.UserVoteDislike},
+		{testdata.ClusterName, "4", types.UserVoteNone},
+		{cluster2, "1", types.UserVoteLike},
+		{cluster3, "1", types.UserVoteDislike},
+	} {
+		helpers.FailOnError(t, mockStorage.VoteOnRule(
+			vote.cluster, testdata.Rule1ID, testdata.ErrorKey1, vote.userID, vote.vote, "",
+		))
+	}
+	// vote on another rule is not counted
+	helpers.FailOnError(t, mockStorage.VoteOnRule(
+		testdata.ClusterName, testdata.Rule2ID, testdata.ErrorKey2, "1", types.UserVoteDislike, "",
+	))
+
+	summary, err := mockStorage.ReadVoteSummaryOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.VoteSummary{Likes: 1, Dislikes: 2}, summary)
+
+	summary, err = mockStorage.ReadVoteSummaryOnRuleForOrg(testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.VoteSummary{Likes: 2, Dislikes: 2}, summary)
+
+	summary, err = mockStorage.ReadVoteSummaryOnRule(cluster3, testdata.Rule2ID, testdata.ErrorKey2)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.VoteSummary{}, summary)
+}
+
+func TestDBStorageReadVoteSummaryOnRule_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, false)
+	closer()
+
+	_, err := mockStorage.ReadVoteSummaryOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1)
+	assert.EqualError(t, err, "sql: database is closed")
+
+	_, err = mockStorage.ReadVoteSummaryOnRuleForOrg(testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1)
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
 func TestDBStorageTextFeedback(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -355,6 +563,8 @@ func TestDBStorageFeedbackChangeMessage(t *testing.T) {
 }
 
 func TestDBStorageFeedbackErrorItemNotFound(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -365,6 +575,8 @@ func TestDBStorageFeedbackErrorItemNotFound(t *testing.T) {
 }
 
 func TestDBStorageFeedbackErrorDBError(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	closer()
 
@@ -373,6 +585,8 @@ func TestDBStorageFeedbackErrorDBError(t *testing.T) {
 }
 
 func TestDBStorageVoteOnRuleDBError(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	closer()
 
@@ -397,6 +611,8 @@ func TestDBStorageVoteOnRuleUnsupportedDriverError(t *testing.T) {
 }
 
 func TestDBStorageVoteOnRuleDBExecError(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, false)
 	defer closer()
 	connection := storage.GetConnection(mockStorage.(*storage.DBStorage))
@@ -455,6 +671,7 @@ func TestDBStorageVoteOnRuleDBCloseError(t *testing.T) {
 	const errStr = "close error"
 
 	buf := new(bytes.Buffer)
+	defer func(logger zerolog.Logger) { log.Logger = logger }(log.Logger)
 	log.Logger = zerolog.New(buf)
 
 	mockStorage, expects := ira_helpers.MustGetMockStorageWithExpects(t)
@@ -474,6 +691,8 @@ func TestDBStorageVoteOnRuleDBCloseError(t *testing.T) {
 }
 
 func TestDBStorageGetVotesForNoRules(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -486,6 +705,8 @@ func TestDBStorageGetVotesForNoRules(t *testing.T) {
 }
 
 func TestDBStorageGetDisableFeedback(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -498,6 +719,8 @@ func TestDBStorageGetDisableFeedback(t *testing.T) {
 }
 
 func TestDBStorageGetVotes(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -522,7 +745,83 @@ func TestDBStorageGetVotes(t *testing.T) {
 	assert.Equal(t, types.UserVoteNone, feedbacks[testdata.Rule3ID])
 }
 
+func TestDBStorageGetUserFeedbackOnRulesForClusters(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	cluster2 := testdata.GetRandomClusterID()
+	cluster3 := testdata.GetRandomClusterID()
+
+	for _, clusterName := range []types.ClusterName{testdata.ClusterName, cluster2, cluster3} {
+		err := mockStorage.WriteReportForCluster(
+			testdata.OrgID, clusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	helpers.FailOnError(t, mockStorage.VoteOnRule(
+		testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID, types.UserVoteLike, "helpful",
+	))
+	helpers.FailOnError(t, mockStorage.VoteOnRule(
+		cluster2, testdata.Rule2ID, testdata.ErrorKey1, testdata.UserID, types.UserVoteDislike, "",
+	))
+	// feedback of other user and other cluster is not returned
+	helpers.FailOnError(t, mockStorage.VoteOnRule(
+		testdata.ClusterName, testdata.Rule2ID, testdata.ErrorKey1, "another user", types.UserVoteLike, "",
+	))
+	helpers.FailOnError(t, mockStorage.VoteOnRule(
+		cluster3, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID, types.UserVoteLike, "",
+	))
+
+	feedbacks, err := mockStorage.GetUserFeedbackOnRulesForClusters(
+		[]types.ClusterName{testdata.ClusterName, cluster2}, testdata.UserID,
+	)
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, feedbacks, 2)
+
+	votes := make(map[types.ClusterName]storage.UserFeedbackOnRule)
+	for _, feedback := range feedbacks {
+		assert.Equal(t, testdata.UserID, feedback.UserID)
+		votes[feedback.ClusterID] = feedback
+	}
+
+	assert.Equal(t, testdata.Rule1ID, votes[testdata.ClusterName].RuleID)
+	assert.Equal(t, types.UserVoteLike, votes[testdata.ClusterName].UserVote)
+	assert.Equal(t, "helpful", votes[testdata.ClusterName].Message)
+	assert.Equal(t, testdata.Rule2ID, votes[cluster2].RuleID)
+	assert.Equal(t, types.UserVoteDislike, votes[cluster2].UserVote)
+}
+
+func TestDBStorageGetUserFeedbackOnRulesForClusters_NoClusters(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	feedbacks, err := mockStorage.GetUserFeedbackOnRulesForClusters(nil, testdata.UserID)
+	helpers.FailOnError(t, err)
+
+	assert.Empty(t, feedbacks)
+}
+
+func TestDBStorageGetUserFeedbackOnRulesForClusters_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	_, err := mockStorage.GetUserFeedbackOnRulesForClusters(
+		[]types.ClusterName{testdata.ClusterName}, testdata.UserID,
+	)
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
 func TestDBStorageTextDisableFeedback(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -573,6 +872,8 @@ func TestDBStorageDisableFeedbackChangeMessage(t *testing.T) {
 }
 
 func TestDBStorageDisableFeedbackErrorItemNotFound(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -583,9 +884,1008 @@ func TestDBStorageDisableFeedbackErrorItemNotFound(t *testing.T) {
 }
 
 func TestDBStorageDisableFeedbackErrorDBError(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	closer()
 
 	_, err := mockStorage.GetUserFeedbackOnRuleDisable(testdata.ClusterName, testdata.Rule1ID, testdata.UserID)
 	assert.EqualError(t, err, "sql: database is closed")
 }
+
+func TestDBStorageReadRuleHitOccurrences(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	rule1 := types.ReportItem{Module: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1, TemplateData: []byte("{}")}
+	rule2 := types.ReportItem{Module: testdata.Rule2ID, ErrorKey: testdata.ErrorKey2, TemplateData: []byte("{}")}
+
+	firstCheck := testdata.LastCheckedAt
+	secondCheck := firstCheck.Add(time.Hour)
+	thirdCheck := firstCheck.Add(2 * time.Hour)
+
+	for _, report := range []struct {
+		rules         []types.ReportItem
+		lastCheckedAt time.Time
+	}{
+		{[]types.ReportItem{rule1, rule2}, firstCheck},
+		{[]types.ReportItem{rule2}, secondCheck},
+		{[]types.ReportItem{rule1, rule2}, thirdCheck},
+	} {
+		err := mockStorage.WriteReportForCluster(
+			testdata.OrgID, testdata.ClusterName, testdata.ClusterReportEmpty, report.rules, report.lastCheckedAt, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	occurrences, err := mockStorage.ReadRuleHitOccurrences(testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1)
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, []types.RuleHitOccurrence{
+		{
+			AppearedAt:    types.Timestamp(firstCheck.UTC().Format(time.RFC3339)),
+			DisappearedAt: types.Timestamp(secondCheck.UTC().Format(time.RFC3339)),
+		},
+		{
+			AppearedAt: types.Timestamp(thirdCheck.UTC().Format(time.RFC3339)),
+		},
+	}, occurrences)
+
+	occurrences, err = mockStorage.ReadRuleHitOccurrences(testdata.ClusterName, testdata.Rule2ID, testdata.ErrorKey2)
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, []types.RuleHitOccurrence{
+		{
+			AppearedAt: types.Timestamp(firstCheck.UTC().Format(time.RFC3339)),
+		},
+	}, occurrences)
+}
+
+func TestDBStorageReadRuleHitOccurrencesNoHits(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReport3Rules(t, mockStorage)
+
+	occurrences, err := mockStorage.ReadRuleHitOccurrences(testdata.ClusterName, testdata.Rule1ID, "no_such_error_key")
+	helpers.FailOnError(t, err)
+
+	assert.Empty(t, occurrences)
+}
+
+func TestDBStorageReadRuleHitOccurrencesDBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	_, err := mockStorage.ReadRuleHitOccurrences(testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1)
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
+// TestDBStorageReadRuleHitOccurrencesRowsError checks that error hit while
+// iterating over the occurrences is not swallowed
+func TestDBStorageReadRuleHitOccurrencesRowsError(t *testing.T) {
+	const errStr = "connection reset"
+
+	mockStorage, expects := ira_helpers.MustGetMockStorageWithExpects(t)
+	defer ira_helpers.MustCloseMockStorageWithExpects(t, mockStorage, expects)
+
+	expects.ExpectQuery("SELECT appeared_at, disappeared_at FROM rule_hit_history").
+		WithArgs(testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"appeared_at", "disappeared_at"}).
+				AddRow(testdata.LastCheckedAt, testdata.LastCheckedAt.Add(time.Hour)).
+				AddRow(testdata.LastCheckedAt.Add(2*time.Hour), nil).
+				RowError(1, fmt.Errorf(errStr)),
+		)
+
+	occurrences, err := mockStorage.ReadRuleHitOccurrences(testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1)
+	assert.EqualError(t, err, errStr)
+	assert.Len(t, occurrences, 1)
+}
+
+func TestDBStorageReadRuleHitsFirstSeen(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	rule1 := types.ReportItem{Module: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1, TemplateData: []byte("{}")}
+	rule2 := types.ReportItem{Module: testdata.Rule2ID, ErrorKey: testdata.ErrorKey2, TemplateData: []byte("{}")}
+	rule3 := types.ReportItem{Module: testdata.Rule3ID, ErrorKey: testdata.ErrorKey3, TemplateData: []byte("{}")}
+
+	firstCheck := testdata.LastCheckedAt
+	secondCheck := firstCheck.Add(time.Hour)
+	thirdCheck := firstCheck.Add(2 * time.Hour)
+
+	for _, report := range []struct {
+		rules         []types.ReportItem
+		lastCheckedAt time.Time
+	}{
+		{[]types.ReportItem{rule1, rule2}, firstCheck},
+		{[]types.ReportItem{rule2}, secondCheck},
+		{[]types.ReportItem{rule1, rule2, rule3}, thirdCheck},
+	} {
+		err := mockStorage.WriteReportForCluster(
+			testdata.OrgID, testdata.ClusterName, testdata.ClusterReportEmpty, report.rules, report.lastCheckedAt, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	firstSeen, err := mockStorage.ReadRuleHitsFirstSeen(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+
+	firstSeenByRule := make(map[types.RuleID]time.Time)
+	for _, record := range firstSeen {
+		firstSeenByRule[record.RuleID] = record.FirstSeenAt
+	}
+
+	assert.Len(t, firstSeenByRule, 3)
+	// the rule was reported before it disappeared from the report
+	assert.True(t, firstCheck.Equal(firstSeenByRule[testdata.Rule1ID]), firstSeenByRule[testdata.Rule1ID])
+	// the rule was reported all the time
+	assert.True(t, firstCheck.Equal(firstSeenByRule[testdata.Rule2ID]), firstSeenByRule[testdata.Rule2ID])
+	// the rule is new
+	assert.True(t, thirdCheck.Equal(firstSeenByRule[testdata.Rule3ID]), firstSeenByRule[testdata.Rule3ID])
+}
+
+func TestDBStorageReadRuleHitsFirstSeenDBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	_, err := mockStorage.ReadRuleHitsFirstSeen(testdata.OrgID, testdata.ClusterName)
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
+// TestDBStorageRuleHitsFirstSeenPreviousSchema checks that rule hits are
+// written and read before the database is migrated to the latest version
+func TestDBStorageRuleHitsFirstSeenPreviousSchema(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+
+	err := migration.SetDBVersion(dbStorage.GetConnection(), dbStorage.GetDBDriverType(), storage.MinSupportedDBVersion)
+	helpers.FailOnError(t, err)
+
+	version, err := dbStorage.DetectSchemaVersion()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, storage.MinSupportedDBVersion, version)
+
+	firstCheck := testdata.LastCheckedAt
+	for _, lastCheckedAt := range []time.Time{firstCheck, firstCheck.Add(time.Hour)} {
+		err = mockStorage.WriteReportForCluster(
+			testdata.OrgID, testdata.ClusterName, testdata.Report2Rules, testdata.Report2RulesParsed, lastCheckedAt, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	assertFirstSeen := func() {
+		firstSeen, err := mockStorage.ReadRuleHitsFirstSeen(testdata.OrgID, testdata.ClusterName)
+		helpers.FailOnError(t, err)
+
+		assert.Len(t, firstSeen, 2)
+		for _, record := range firstSeen {
+			assert.True(t, firstCheck.Equal(record.FirstSeenAt), record)
+		}
+	}
+
+	assertFirstSeen()
+
+	// the database is migrated while the storage is running
+	err = dbStorage.MigrateToLatest()
+	helpers.FailOnError(t, err)
+	assertFirstSeen()
+
+	_, err = dbStorage.DetectSchemaVersion()
+	helpers.FailOnError(t, err)
+	assertFirstSeen()
+}
+
+// TestDBStorageRuleHitsFirstSeenWrittenByPreviousVersion checks that rule
+// hits written without first_seen_at by previous version of the service
+// take it from the history of rule hits
+func TestDBStorageRuleHitsFirstSeenWrittenByPreviousVersion(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReport3Rules(t, mockStorage)
+
+	_, err := mockStorage.(*storage.DBStorage).GetConnection().Exec(`UPDATE rule_hit SET first_seen_at = NULL`)
+	helpers.FailOnError(t, err)
+
+	firstSeen, err := mockStorage.ReadRuleHitsFirstSeen(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, firstSeen, 3)
+	for _, record := range firstSeen {
+		assert.True(t, testdata.LastCheckedAt.Equal(record.FirstSeenAt), record)
+	}
+}
+
+func TestDBStorageReadRuleResolutionRates(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	rule1 := types.ReportItem{Module: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1, TemplateData: []byte("{}")}
+	rule2 := types.ReportItem{Module: testdata.Rule2ID, ErrorKey: testdata.ErrorKey2, TemplateData: []byte("{}")}
+
+	firstCheck := testdata.LastCheckedAt
+	secondCheck := firstCheck.Add(time.Hour)
+	thirdCheck := firstCheck.Add(2 * time.Hour)
+
+	for _, report := range []struct {
+		orgID         types.OrgID
+		clusterName   types.ClusterName
+		rules         []types.ReportItem
+		lastCheckedAt time.Time
+	}{
+		// rule1 is resolved, reappears and then it's resolved again
+		{testdata.OrgID, testdata.ClusterName, []types.ReportItem{rule1, rule2}, firstCheck},
+		{testdata.OrgID, testdata.ClusterName, []types.ReportItem{rule2}, secondCheck},
+		{testdata.OrgID, testdata.ClusterName, []types.ReportItem{rule1, rule2}, thirdCheck},
+		{testdata.OrgID, testdata.ClusterName, []types.ReportItem{rule2}, thirdCheck.Add(time.Hour)},
+		// rule1 is not resolved in another organization
+		{testdata.Org2ID, testdata.GetRandomClusterID(), []types.ReportItem{rule1}, firstCheck},
+	} {
+		err := mockStorage.WriteReportForCluster(
+			report.orgID, report.clusterName, testdata.ClusterReportEmpty, report.rules, report.lastCheckedAt, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	rates, err := mockStorage.ReadRuleResolutionRates()
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, []types.RuleResolutionRate{
+		{RuleID: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1, Hits: 3, Resolved: 2, ResolutionRate: 2.0 / 3.0},
+		{RuleID: testdata.Rule2ID, ErrorKey: testdata.ErrorKey2, Hits: 1, Resolved: 0, ResolutionRate: 0},
+	}, rates)
+
+	rates, err = mockStorage.ReadRuleResolutionRatesForOrg(testdata.Org2ID)
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, []types.RuleResolutionRate{
+		{RuleID: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1, Hits: 1, Resolved: 0, ResolutionRate: 0},
+	}, rates)
+
+	rates, err = mockStorage.ReadRuleResolutionRatesForOrg(types.OrgID(12345))
+	helpers.FailOnError(t, err)
+	assert.Empty(t, rates)
+}
+
+func TestDBStorageReadRuleResolutionRatesDBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	_, err := mockStorage.ReadRuleResolutionRates()
+	assert.EqualError(t, err, "sql: database is closed")
+
+	_, err = mockStorage.ReadRuleResolutionRatesForOrg(testdata.OrgID)
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
+func TestDBStorageReadTopRules(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	rule1 := types.ReportItem{Module: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1, TemplateData: []byte("{}")}
+	rule2 := types.ReportItem{Module: testdata.Rule2ID, ErrorKey: testdata.ErrorKey2, TemplateData: []byte("{}")}
+
+	to := testdata.LastCheckedAt.Add(10 * 24 * time.Hour)
+	from := to.Add(-24 * time.Hour)
+	cluster2 := testdata.GetRandomClusterID()
+
+	for _, report := range []struct {
+		orgID         types.OrgID
+		clusterName   types.ClusterName
+		rules         []types.ReportItem
+		lastCheckedAt time.Time
+	}{
+		// rule1 is resolved in the previous window, rule2 affects the
+		// cluster in both windows
+		{testdata.OrgID, testdata.ClusterName, []types.ReportItem{rule1}, to.Add(-40 * time.Hour)},
+		{testdata.OrgID, testdata.ClusterName, []types.ReportItem{rule2}, to.Add(-30 * time.Hour)},
+		// both rules appear in the current window in another organization
+		{testdata.Org2ID, cluster2, []types.ReportItem{rule1, rule2}, to.Add(-6 * time.Hour)},
+	} {
+		err := mockStorage.WriteReportForCluster(
+			report.orgID, report.clusterName, testdata.ClusterReportEmpty, report.rules, report.lastCheckedAt, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	rules, err := mockStorage.ReadTopRules(from, to, 10)
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, []types.TopRule{
+		{RuleID: testdata.Rule2ID, ErrorKey: testdata.ErrorKey2, Clusters: 2, PreviousClusters: 1, Delta: 1},
+		{RuleID: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1, Clusters: 1, PreviousClusters: 1, Delta: 0},
+	}, rules)
+
+	rules, err = mockStorage.ReadTopRules(from, to, 1)
+	helpers.FailOnError(t, err)
+	assert.Len(t, rules, 1)
+
+	rules, err = mockStorage.ReadTopRulesForOrg(testdata.OrgID, from, to, 10)
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, []types.TopRule{
+		{RuleID: testdata.Rule2ID, ErrorKey: testdata.ErrorKey2, Clusters: 1, PreviousClusters: 1, Delta: 0},
+	}, rules)
+
+	// no rule affects any cluster before the first report
+	rules, err = mockStorage.ReadTopRules(testdata.LastCheckedAt, from.Add(-48*time.Hour), 10)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, rules)
+}
+
+func TestDBStorageReadTopRulesDBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	_, err := mockStorage.ReadTopRules(testdata.LastCheckedAt, time.Now(), 10)
+	assert.EqualError(t, err, "sql: database is closed")
+
+	_, err = mockStorage.ReadTopRulesForOrg(testdata.OrgID, testdata.LastCheckedAt, time.Now(), 10)
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
+func TestDBStorageReadOrgReport(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	const (
+		cluster1 = types.ClusterName("00000000-0000-0000-0000-000000000001")
+		cluster2 = types.ClusterName("00000000-0000-0000-0000-000000000002")
+		cluster3 = types.ClusterName("00000000-0000-0000-0000-000000000003")
+	)
+
+	rule1 := types.ReportItem{Module: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1, TemplateData: []byte("{}")}
+	rule2 := types.ReportItem{Module: testdata.Rule2ID, ErrorKey: testdata.ErrorKey2, TemplateData: []byte("{}")}
+
+	for _, report := range []struct {
+		orgID       types.OrgID
+		clusterName types.ClusterName
+		rules       []types.ReportItem
+	}{
+		{testdata.OrgID, cluster2, []types.ReportItem{rule1, rule2}},
+		{testdata.OrgID, cluster1, []types.ReportItem{rule1}},
+		// clusters of another organization are not part of the report
+		{testdata.Org2ID, cluster3, []types.ReportItem{rule1}},
+	} {
+		err := mockStorage.WriteReportForCluster(
+			report.orgID, report.clusterName, testdata.ClusterReportEmpty, report.rules, testdata.LastCheckedAt, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	helpers.FailOnError(t, mockStorage.ToggleRuleForCluster(
+		cluster2, testdata.Rule2ID, testdata.ErrorKey2, storage.RuleToggleDisable,
+	))
+	// enabled rule is reported as any other one
+	helpers.FailOnError(t, mockStorage.ToggleRuleForCluster(
+		cluster1, testdata.Rule1ID, testdata.ErrorKey1, storage.RuleToggleEnable,
+	))
+
+	report, err := mockStorage.ReadOrgReport(testdata.OrgID)
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, []types.OrgReportRule{
+		{
+			RuleID:           testdata.Rule1ID,
+			ErrorKey:         testdata.ErrorKey1,
+			Clusters:         []types.ClusterName{cluster1, cluster2},
+			DisabledClusters: []types.ClusterName{},
+		},
+		{
+			RuleID:           testdata.Rule2ID,
+			ErrorKey:         testdata.ErrorKey2,
+			Clusters:         []types.ClusterName{},
+			DisabledClusters: []types.ClusterName{cluster2},
+		},
+	}, report)
+
+	report, err = mockStorage.ReadOrgReport(types.OrgID(12345))
+	helpers.FailOnError(t, err)
+	assert.Empty(t, report)
+}
+
+func TestDBStorageReadOrgReportDBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	_, err := mockStorage.ReadOrgReport(testdata.OrgID)
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
+func TestDBStorageReadReportChecks(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+	dbStorage.SetCheckHistorySize(2)
+
+	for i, report := range []struct {
+		report types.ClusterReport
+		rules  []types.ReportItem
+	}{
+		{testdata.Report3Rules, testdata.Report3RulesParsed},
+		{testdata.ClusterReportEmpty, testdata.ReportEmptyRulesParsed},
+		{testdata.Report3Rules, testdata.Report3RulesParsed},
+	} {
+		err := mockStorage.WriteReportForCluster(
+			testdata.OrgID, testdata.ClusterName, report.report, report.rules,
+			testdata.LastCheckedAt.Add(time.Duration(i)*time.Hour), testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	// only the last two checks are kept
+	checks, err := mockStorage.ReadReportChecks(testdata.ClusterName, 0)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []types.ReportCheck{
+		{
+			CheckedAt: types.Timestamp(testdata.LastCheckedAt.Add(time.Hour).UTC().Format(time.RFC3339)),
+			HitCount:  0, Added: 0, Removed: 3,
+		},
+		{
+			CheckedAt: types.Timestamp(testdata.LastCheckedAt.Add(2 * time.Hour).UTC().Format(time.RFC3339)),
+			HitCount:  3, Added: 3, Removed: 0,
+		},
+	}, checks)
+
+	checks, err = mockStorage.ReadReportChecks(testdata.ClusterName, 1)
+	helpers.FailOnError(t, err)
+	assert.Len(t, checks, 1)
+	assert.Equal(t, 3, checks[0].HitCount)
+}
+
+func TestDBStorageReadReportChecksPreviousSchema(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+	dbStorage.SetCheckHistorySize(10)
+
+	err := migration.SetDBVersion(dbStorage.GetConnection(), dbStorage.GetDBDriverType(), 27)
+	helpers.FailOnError(t, err)
+
+	_, err = dbStorage.DetectSchemaVersion()
+	helpers.FailOnError(t, err)
+
+	// statistics are neither written nor read before the migration
+	mustWriteReport3Rules(t, mockStorage)
+
+	checks, err := mockStorage.ReadReportChecks(testdata.ClusterName, 0)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, checks)
+
+	_, err = mockStorage.DeleteReportsNotCheckedSince(time.Now().Add(time.Hour))
+	helpers.FailOnError(t, err)
+}
+
+func TestDBStorageReadReportChecksDBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	_, err := mockStorage.ReadReportChecks(testdata.ClusterName, 10)
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
+func TestDBStorageWriteReportHistory(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed,
+		testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	// older report is stored twice, the second upload replaces the first one
+	for i := 0; i < 2; i++ {
+		err = mockStorage.WriteReportHistory(
+			testdata.OrgID, testdata.ClusterName, testdata.ClusterReportEmpty,
+			testdata.LastCheckedAt.Add(-time.Hour), testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	var count int
+	err = dbStorage.GetConnection().QueryRow(
+		"SELECT COUNT(*) FROM report_history WHERE cluster_id = $1", testdata.ClusterName,
+	).Scan(&count)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 1, count)
+
+	// the latest report of the cluster is not touched
+	rules, lastChecked, err := mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Len(t, rules, 3)
+	assert.Equal(t, types.Timestamp(testdata.LastCheckedAt.UTC().Format(time.RFC3339)), lastChecked)
+}
+
+func TestDBStorageWriteReportHistoryPreviousSchema(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+
+	err := migration.SetDBVersion(dbStorage.GetConnection(), dbStorage.GetDBDriverType(), 28)
+	helpers.FailOnError(t, err)
+
+	_, err = dbStorage.DetectSchemaVersion()
+	helpers.FailOnError(t, err)
+
+	err = mockStorage.WriteReportHistory(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	assert.EqualError(t, err, "history of reports is not supported before DB migration 29")
+
+	_, err = mockStorage.DeleteReportsNotCheckedSince(time.Now().Add(time.Hour))
+	helpers.FailOnError(t, err)
+}
+
+func TestDBStorageWriteReportHistoryDBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	err := mockStorage.WriteReportHistory(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
+func TestDBStorageReportHistorySize(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+	dbStorage.SetReportHistorySize(2)
+
+	reports := []types.ClusterReport{testdata.Report2Rules, testdata.Report3Rules, testdata.ClusterReportEmpty}
+	for i, report := range reports {
+		err := mockStorage.WriteReportForCluster(
+			testdata.OrgID, testdata.ClusterName, report, []types.ReportItem{},
+			testdata.LastCheckedAt.Add(time.Duration(i)*time.Hour), testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	// only the last two reports are kept, the oldest one goes first
+	history, err := mockStorage.ReadReportHistoryForCluster(
+		testdata.OrgID, testdata.ClusterName, time.Time{}, time.Time{},
+	)
+	helpers.FailOnError(t, err)
+	assert.Len(t, history, 2)
+	assert.Equal(t, testdata.Report3Rules, history[0].Report)
+	assert.Equal(t, testdata.ClusterReportEmpty, history[1].Report)
+	assert.Equal(
+		t, types.Timestamp(testdata.LastCheckedAt.Add(time.Hour).UTC().Format(time.RFC3339)), history[0].LastCheckedAt,
+	)
+
+	// from is inclusive, to is exclusive
+	history, err = mockStorage.ReadReportHistoryForCluster(
+		testdata.OrgID, testdata.ClusterName,
+		testdata.LastCheckedAt.Add(time.Hour), testdata.LastCheckedAt.Add(2*time.Hour),
+	)
+	helpers.FailOnError(t, err)
+	assert.Len(t, history, 1)
+	assert.Equal(t, testdata.Report3Rules, history[0].Report)
+
+	// reports of other organizations are not returned
+	history, err = mockStorage.ReadReportHistoryForCluster(
+		testdata.Org2ID, testdata.ClusterName, time.Time{}, time.Time{},
+	)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, history)
+}
+
+func TestDBStorageReportHistoryDisabled(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed,
+		testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	history, err := mockStorage.ReadReportHistoryForCluster(
+		testdata.OrgID, testdata.ClusterName, time.Time{}, time.Time{},
+	)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, history)
+}
+
+func TestDBStorageReadReportHistoryPreviousSchema(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+
+	err := migration.SetDBVersion(dbStorage.GetConnection(), dbStorage.GetDBDriverType(), 28)
+	helpers.FailOnError(t, err)
+
+	_, err = dbStorage.DetectSchemaVersion()
+	helpers.FailOnError(t, err)
+
+	history, err := mockStorage.ReadReportHistoryForCluster(
+		testdata.OrgID, testdata.ClusterName, time.Time{}, time.Time{},
+	)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, history)
+}
+
+func TestDBStorageRecomputeAggregates(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+
+	mustWriteReport3Rules(t, mockStorage)
+
+	// derived data missing after a bulk import
+	_, err := dbStorage.GetConnection().Exec("DELETE FROM org_info;")
+	helpers.FailOnError(t, err)
+	_, err = dbStorage.GetConnection().Exec("UPDATE rule_hit SET first_seen_at = NULL;")
+	helpers.FailOnError(t, err)
+
+	recomputation, err := mockStorage.RecomputeAggregates()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, storage.AggregatesRecomputation{
+		Organizations:     1,
+		RuleHitsFirstSeen: 3,
+		CachedClusters:    1,
+	}, recomputation)
+
+	_, err = mockStorage.ReadOrgInfo(testdata.OrgID)
+	helpers.FailOnError(t, err)
+
+	var missing int
+	err = dbStorage.GetConnection().QueryRow(
+		"SELECT COUNT(*) FROM rule_hit WHERE first_seen_at IS NULL;",
+	).Scan(&missing)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 0, missing)
+
+	// nothing is missing anymore
+	recomputation, err = mockStorage.RecomputeAggregates()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, int64(0), recomputation.RuleHitsFirstSeen)
+}
+
+func TestDBStorageRecomputeAggregatesDBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	_, err := mockStorage.RecomputeAggregates()
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
+// mustWriteReportsUnderTwoOrgs writes report of the cluster under the first
+// organization and a newer report of it under the second one, the error of
+// the second write is returned
+func mustWriteReportsUnderTwoOrgs(t *testing.T, mockStorage storage.Storage) error {
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed,
+		testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	return mockStorage.WriteReportForCluster(
+		testdata.Org2ID, testdata.ClusterName, testdata.Report2Rules, testdata.Report2RulesParsed,
+		testdata.LastCheckedAt.Add(time.Hour), testdata.KafkaOffset+1,
+	)
+}
+
+// countRows returns number of rows of the table for the cluster
+func countRows(t *testing.T, dbStorage *storage.DBStorage, table string) int {
+	var count int
+
+	err := dbStorage.GetConnection().QueryRow(
+		"SELECT COUNT(*) FROM "+table+" WHERE cluster_id = $1", testdata.ClusterName,
+	).Scan(&count)
+	helpers.FailOnError(t, err)
+
+	return count
+}
+
+func TestDBStorageClusterOrgConflictMove(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+
+	var conflicts []events.ClusterOrgConflictEvent
+	defer events.DefaultBus.SubscribeClusterOrgConflict(func(event events.ClusterOrgConflictEvent) {
+		conflicts = append(conflicts, event)
+	})()
+
+	err := mustWriteReportsUnderTwoOrgs(t, mockStorage)
+	helpers.FailOnError(t, err)
+
+	_, _, err = mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+	rules, _, err := mockStorage.ReadReportForCluster(testdata.Org2ID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Len(t, rules, 2)
+
+	// rule hits of the previous organization are deleted
+	hits, err := mockStorage.ReadRuleHitsForCluster(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Len(t, hits, 2)
+	assert.Equal(t, 0, countRows(t, dbStorage, "report_history"))
+
+	changes, err := mockStorage.ReadClusterOrgChanges(testdata.LastCheckedAt)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []types.ClusterOrgChange{{
+		ClusterName:   testdata.ClusterName,
+		PreviousOrgID: testdata.OrgID,
+		OrgID:         testdata.Org2ID,
+		ChangedAt:     types.Timestamp(testdata.LastCheckedAt.Add(time.Hour).UTC().Format(time.RFC3339)),
+		Resolution:    storage.ClusterOrgConflictMove,
+	}}, changes)
+
+	if assert.Len(t, conflicts, 1) {
+		assert.Equal(t, testdata.OrgID, conflicts[0].PreviousOrgID)
+		assert.Equal(t, testdata.Org2ID, conflicts[0].OrgID)
+		assert.Equal(t, storage.ClusterOrgConflictMove, conflicts[0].Resolution)
+	}
+
+	// only recent changes are returned
+	changes, err = mockStorage.ReadClusterOrgChanges(testdata.LastCheckedAt.Add(2 * time.Hour))
+	helpers.FailOnError(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestDBStorageClusterOrgConflictReject(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mockStorage.(*storage.DBStorage).SetClusterOrgConflictPolicy(storage.ClusterOrgConflictReject)
+
+	var conflicts []events.ClusterOrgConflictEvent
+	defer events.DefaultBus.SubscribeClusterOrgConflict(func(event events.ClusterOrgConflictEvent) {
+		conflicts = append(conflicts, event)
+	})()
+
+	err := mustWriteReportsUnderTwoOrgs(t, mockStorage)
+	assert.Equal(t, types.ErrClusterOrgConflict, err)
+
+	rules, _, err := mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Len(t, rules, 3)
+
+	changes, err := mockStorage.ReadClusterOrgChanges(testdata.LastCheckedAt)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, changes)
+
+	if assert.Len(t, conflicts, 1) {
+		assert.Equal(t, storage.ClusterOrgConflictReject, conflicts[0].Resolution)
+	}
+}
+
+func TestDBStorageClusterOrgConflictKeep(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+	dbStorage.SetClusterOrgConflictPolicy(storage.ClusterOrgConflictKeep)
+
+	err := mustWriteReportsUnderTwoOrgs(t, mockStorage)
+	helpers.FailOnError(t, err)
+
+	// the last report of the previous organization is kept in history
+	var orgID types.OrgID
+	err = dbStorage.GetConnection().QueryRow(
+		"SELECT org_id FROM report_history WHERE cluster_id = $1", testdata.ClusterName,
+	).Scan(&orgID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.OrgID, orgID)
+
+	changes, err := mockStorage.ReadClusterOrgChanges(testdata.LastCheckedAt)
+	helpers.FailOnError(t, err)
+	if assert.Len(t, changes, 1) {
+		assert.Equal(t, storage.ClusterOrgConflictKeep, changes[0].Resolution)
+	}
+}
+
+func TestDBStorageClusterOrgConflictOldReport(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mustWriteReportsUnderTwoOrgs(t, mockStorage)
+	helpers.FailOnError(t, err)
+
+	// report sent before the cluster was moved doesn't move it back, another
+	// instance of the storage doesn't have the cluster in its cache
+	dbStorage := mockStorage.(*storage.DBStorage)
+	otherStorage := storage.NewFromConnection(dbStorage.GetConnection(), dbStorage.GetDBDriverType())
+	err = otherStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed,
+		testdata.LastCheckedAt.Add(time.Minute), testdata.KafkaOffset,
+	)
+	assert.Equal(t, types.ErrOldReport, err)
+
+	_, _, err = mockStorage.ReadReportForCluster(testdata.Org2ID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+}
+
+func TestDBStorageClusterOrgConflictPreviousSchema(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+
+	err := migration.SetDBVersion(dbStorage.GetConnection(), dbStorage.GetDBDriverType(), 30)
+	helpers.FailOnError(t, err)
+
+	_, err = dbStorage.DetectSchemaVersion()
+	helpers.FailOnError(t, err)
+
+	// the cluster is moved, but the change is not recorded
+	err = mustWriteReportsUnderTwoOrgs(t, mockStorage)
+	helpers.FailOnError(t, err)
+
+	changes, err := mockStorage.ReadClusterOrgChanges(testdata.LastCheckedAt)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, changes)
+
+	_, err = mockStorage.DeleteReportsNotCheckedSince(time.Now().Add(time.Hour))
+	helpers.FailOnError(t, err)
+}
+
+// externalResults contains results of checks of the cluster by external
+// source
+var externalResults = []types.ExternalResult{
+	{CheckID: "CVE-2020-0001", Severity: 2, Description: "outdated package"},
+	{CheckID: "CVE-2020-0002", Severity: 4, Description: "vulnerable image", Details: []byte(`{"image":"nginx"}`)},
+}
+
+func TestDBStorageWriteExternalResults(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteExternalResults(
+		testdata.OrgID, testdata.ClusterName, "scanner", externalResults, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	err = mockStorage.WriteExternalResults(
+		testdata.OrgID, testdata.ClusterName, "compliance", externalResults[:1], testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	lastChecked := types.Timestamp(testdata.LastCheckedAt.UTC().Format(time.RFC3339))
+
+	// sources are ordered, the most severe results go first
+	results, err := mockStorage.ReadExternalResults(testdata.ClusterName, "")
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []types.ExternalResult{
+		{Source: "compliance", CheckID: "CVE-2020-0001", Severity: 2, Description: "outdated package", LastCheckedAt: lastChecked},
+		{
+			Source: "scanner", CheckID: "CVE-2020-0002", Severity: 4, Description: "vulnerable image",
+			Details: []byte(`{"image":"nginx"}`), LastCheckedAt: lastChecked,
+		},
+		{Source: "scanner", CheckID: "CVE-2020-0001", Severity: 2, Description: "outdated package", LastCheckedAt: lastChecked},
+	}, results)
+
+	// newer results replace the results of the source only
+	err = mockStorage.WriteExternalResults(
+		testdata.OrgID, testdata.ClusterName, "scanner", nil, testdata.LastCheckedAt.Add(time.Hour), testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	results, err = mockStorage.ReadExternalResults(testdata.ClusterName, "scanner")
+	helpers.FailOnError(t, err)
+	assert.Empty(t, results)
+
+	results, err = mockStorage.ReadExternalResults(testdata.ClusterName, "compliance")
+	helpers.FailOnError(t, err)
+	assert.Len(t, results, 1)
+
+	// older results are rejected
+	err = mockStorage.WriteExternalResults(
+		testdata.OrgID, testdata.ClusterName, "scanner", externalResults, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	assert.Equal(t, types.ErrOldReport, err)
+}
+
+func TestDBStorageWriteExternalResultsPreviousSchema(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+
+	err := migration.SetDBVersion(dbStorage.GetConnection(), dbStorage.GetDBDriverType(), 31)
+	helpers.FailOnError(t, err)
+
+	_, err = dbStorage.DetectSchemaVersion()
+	helpers.FailOnError(t, err)
+
+	err = mockStorage.WriteExternalResults(
+		testdata.OrgID, testdata.ClusterName, "scanner", externalResults, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	assert.EqualError(t, err, "external results are not supported before DB migration 32")
+
+	results, err := mockStorage.ReadExternalResults(testdata.ClusterName, "")
+	helpers.FailOnError(t, err)
+	assert.Empty(t, results)
+
+	_, err = mockStorage.DeleteReportsNotCheckedSince(time.Now().Add(time.Hour))
+	helpers.FailOnError(t, err)
+}
+
+func TestDBStorageReadExternalResultsDBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	_, err := mockStorage.ReadExternalResults(testdata.ClusterName, "")
+	assert.EqualError(t, err, "sql: database is closed")
+}
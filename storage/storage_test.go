@@ -34,6 +34,7 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/RedHatInsights/insights-results-aggregator/migration"
 	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
 
@@ -44,6 +45,17 @@ func init() {
 	zerolog.SetGlobalLevel(zerolog.WarnLevel)
 }
 
+// TestMain removes DB containers started for the tests (if any)
+func TestMain(m *testing.M) {
+	code := m.Run()
+
+	if err := ira_helpers.PurgeDockerDatabases(); err != nil {
+		log.Error().Err(err).Msg("unable to remove DB containers")
+	}
+
+	os.Exit(code)
+}
+
 func assertNumberOfReports(t *testing.T, mockStorage storage.Storage, expectedNumberOfReports int) {
 	numberOfReports, err := mockStorage.ReportsCount()
 	helpers.FailOnError(t, err)
@@ -100,6 +112,8 @@ func TestNewStorageWithLoggingError(t *testing.T) {
 
 // TestDBStorageReadReportForClusterEmptyTable check the behaviour of method ReadReportForCluster
 func TestDBStorageReadReportForClusterEmptyTable(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -120,6 +134,8 @@ func TestDBStorageReadReportForClusterEmptyTable(t *testing.T) {
 
 // TestDBStorageReadReportForClusterClosedStorage check the behaviour of method ReadReportForCluster
 func TestDBStorageReadReportForClusterClosedStorage(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	// we need to close storage right now
 	closer()
@@ -130,6 +146,8 @@ func TestDBStorageReadReportForClusterClosedStorage(t *testing.T) {
 
 // TestDBStorageReadReportForCluster check the behaviour of method ReadReportForCluster
 func TestDBStorageReadReportForCluster(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -138,8 +156,44 @@ func TestDBStorageReadReportForCluster(t *testing.T) {
 
 }
 
+// TestDBStorageReadReportForClusterInconsistent checks that rule hits from
+// another generation of the report are not returned
+func TestDBStorageReadReportForClusterInconsistent(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	for i := 0; i < 2; i++ {
+		err := mockStorage.WriteReportForCluster(
+			testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed,
+			testdata.LastCheckedAt.Add(time.Duration(i)*time.Hour), testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	report, _, err := mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Len(t, report, 3)
+
+	// simulate rule hit left from the previous write of the report
+	connection := mockStorage.(*storage.DBStorage).GetConnection()
+	_, err = connection.Exec(
+		"UPDATE rule_hit SET generation = generation - 1 WHERE rule_fqdn = (SELECT MIN(rule_fqdn) FROM rule_hit);",
+	)
+	helpers.FailOnError(t, err)
+
+	_, _, err = mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	assert.Equal(t, types.ErrReportInconsistent, err)
+
+	_, _, err = mockStorage.ReadReportForClusterByClusterName(testdata.ClusterName)
+	assert.Equal(t, types.ErrReportInconsistent, err)
+}
+
 // TestDBStorageGetOrgIDByClusterID check the behaviour of method GetOrgIDByClusterID
 func TestDBStorageGetOrgIDByClusterID(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -151,6 +205,8 @@ func TestDBStorageGetOrgIDByClusterID(t *testing.T) {
 }
 
 func TestDBStorageGetOrgIDByClusterID_Error(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, false)
 	defer closer()
 
@@ -191,6 +247,8 @@ func TestDBStorageGetOrgIDByClusterID_Error(t *testing.T) {
 
 // TestDBStorageGetOrgIDByClusterIDFailing check the behaviour of method GetOrgIDByClusterID for not existed ClusterID
 func TestDBStorageGetOrgIDByClusterIDFailing(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -202,6 +260,8 @@ func TestDBStorageGetOrgIDByClusterIDFailing(t *testing.T) {
 // TestDBStorageReadReportNoTable check the behaviour of method ReadReportForCluster
 // when the table with results does not exist
 func TestDBStorageReadReportNoTable(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, false)
 	defer closer()
 
@@ -211,6 +271,8 @@ func TestDBStorageReadReportNoTable(t *testing.T) {
 
 // TestDBStorageWriteReportForClusterClosedStorage check the behaviour of method WriteReportForCluster
 func TestDBStorageWriteReportForClusterClosedStorage(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	// we need to close storage right now
 	closer()
@@ -245,6 +307,8 @@ func TestDBStorageWriteReportForClusterUnsupportedDriverError(t *testing.T) {
 // TestDBStorageWriteReportForClusterMoreRecentInDB checks that older report
 // will not replace a more recent one when writing a report to storage.
 func TestDBStorageWriteReportForClusterMoreRecentInDB(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -276,6 +340,8 @@ func TestDBStorageWriteReportForClusterMoreRecentInDB(t *testing.T) {
 
 // TestDBStorageClusterOrgTransfer checks the behaviour of report storage in case of cluster org transfer
 func TestDBStorageClusterOrgTransfer(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -328,6 +394,8 @@ func TestDBStorageClusterOrgTransfer(t *testing.T) {
 // TestDBStorageWriteReportForClusterDroppedReportTable checks the error
 // returned when trying to SELECT from a dropped/missing report table.
 func TestDBStorageWriteReportForClusterDroppedReportTable(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -349,12 +417,24 @@ func TestDBStorageWriteReportForClusterDroppedReportTable(t *testing.T) {
 }
 
 func TestDBStorageWriteReportForClusterExecError(t *testing.T) {
-	mockStorage, closer := ira_helpers.MustGetMockStorage(t, false)
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
-	createReportTableWithBadClusterField(t, mockStorage)
+	// other tables touched by the write are kept, so the write fails on the
+	// report table
+	connection := storage.GetConnection(mockStorage.(*storage.DBStorage))
+	dropQuery := "DROP TABLE report"
+	if os.Getenv("INSIGHTS_RESULTS_AGGREGATOR__TESTS_DB") == "postgres" {
+		dropQuery += " CASCADE"
+	}
+	_, err := connection.Exec(dropQuery)
+	helpers.FailOnError(t, err)
 
-	err := mockStorage.WriteReportForCluster(
+	createBadReportTable(t, connection)
+
+	err = mockStorage.WriteReportForCluster(
 		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt, testdata.KafkaOffset,
 	)
 	assert.Error(t, err)
@@ -372,19 +452,48 @@ func TestDBStorageWriteReportForClusterFakePostgresOK(t *testing.T) {
 
 	expects.ExpectBegin()
 
+	expects.ExpectExec("SELECT pg_advisory_xact_lock").
+		WillReturnResult(driver.ResultNoRows)
+
 	expects.ExpectQuery(`SELECT last_checked_at FROM report`).
 		WillReturnRows(expects.NewRows([]string{"last_checked_at"})).
 		RowsWillBeClosed()
 
-	expects.ExpectExec("DELETE FROM rule_hit").
-		WillReturnResult(driver.ResultNoRows)
+	// the cluster isn't stored under any organization yet
+	expects.ExpectQuery(`SELECT org_id, report, last_checked_at, kafka_offset FROM report WHERE cluster`).
+		WillReturnRows(expects.NewRows([]string{"org_id", "report", "last_checked_at", "kafka_offset"}))
+
+	expects.ExpectQuery("SELECT rule_fqdn, error_key FROM rule_hit_history").
+		WillReturnRows(expects.NewRows([]string{"rule_fqdn", "error_key"})).
+		RowsWillBeClosed()
 
 	for i := 0; i < len(testdata.Report3RulesParsed); i++ {
-		expects.ExpectExec("INSERT INTO rule_hit").
+		expects.ExpectExec("INSERT INTO rule_hit_history").
 			WillReturnResult(driver.ResultNoRows)
 	}
 
+	expects.ExpectQuery("SELECT rule_fqdn, error_key, appeared_at FROM rule_hit_history").
+		WillReturnRows(expects.NewRows([]string{"rule_fqdn", "error_key", "appeared_at"})).
+		RowsWillBeClosed()
+
+	expects.ExpectExec("DELETE FROM rule_hit").
+		WillReturnResult(driver.ResultNoRows)
+
+	for _, rule := range testdata.Report3RulesParsed {
+		expects.ExpectQuery(`INSERT INTO rule_hit\(.*RETURNING template_data`).
+			WillReturnRows(expects.NewRows([]string{"template_data"}).AddRow(string(rule.TemplateData)))
+	}
+
 	expects.ExpectExec("INSERT INTO report").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	expects.ExpectExec(`UPDATE report SET generation = generation \+ 1`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	expects.ExpectExec("UPDATE rule_hit SET generation").
+		WillReturnResult(sqlmock.NewResult(0, int64(len(testdata.Report3RulesParsed))))
+
+	expects.ExpectExec("INSERT INTO org_info").
 		WillReturnResult(driver.ResultNoRows)
 
 	expects.ExpectCommit()
@@ -397,6 +506,8 @@ func TestDBStorageWriteReportForClusterFakePostgresOK(t *testing.T) {
 
 // TestDBStorageListOfOrgs check the behaviour of method ListOfOrgs
 func TestDBStorageListOfOrgs(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -410,6 +521,8 @@ func TestDBStorageListOfOrgs(t *testing.T) {
 }
 
 func TestDBStorageListOfOrgsNoTable(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, false)
 	defer closer()
 
@@ -419,6 +532,8 @@ func TestDBStorageListOfOrgsNoTable(t *testing.T) {
 
 // TestDBStorageListOfOrgsClosedStorage check the behaviour of method ListOfOrgs
 func TestDBStorageListOfOrgsClosedStorage(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	// we need to close storage right now
 	closer()
@@ -427,8 +542,50 @@ func TestDBStorageListOfOrgsClosedStorage(t *testing.T) {
 	assert.EqualError(t, err, "sql: database is closed")
 }
 
+// TestDBStorageListOfOrgsWithSummary checks that clusters of organizations
+// are counted and that organizations with more clusters checked at the same
+// time are returned only once
+func TestDBStorageListOfOrgsWithSummary(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	orgs, err := mockStorage.ListOfOrgsWithSummary()
+	helpers.FailOnError(t, err)
+	assert.Empty(t, orgs)
+
+	lastChecked := time.Date(2020, 8, 3, 10, 0, 0, 0, time.UTC)
+
+	for i, clusterName := range []types.ClusterName{
+		"1deb586c-fb85-4db4-ae5b-139cdbdf77ae",
+		"a1bf5b15-5229-4042-9825-c69dc36b57f5",
+	} {
+		err = mockStorage.WriteReportForCluster(
+			1, clusterName, testdata.ClusterReportEmpty, testdata.ReportEmptyRulesParsed,
+			lastChecked, types.KafkaOffset(i),
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	err = mockStorage.WriteReportForCluster(
+		3, testdata.ClusterName, testdata.ClusterReportEmpty, testdata.ReportEmptyRulesParsed,
+		lastChecked.Add(-time.Hour), testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	orgs, err = mockStorage.ListOfOrgsWithSummary()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []types.OrgSummary{
+		{OrgID: 1, ClusterCount: 2, LastCheckedAt: "2020-08-03T10:00:00Z"},
+		{OrgID: 3, ClusterCount: 1, LastCheckedAt: "2020-08-03T09:00:00Z"},
+	}, orgs)
+}
+
 // TestDBStorageListOfClustersFor check the behaviour of method ListOfClustersForOrg
 func TestDBStorageListOfClustersForOrg(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -440,7 +597,7 @@ func TestDBStorageListOfClustersForOrg(t *testing.T) {
 	// also pushing cluster for different org
 	writeReportForCluster(t, mockStorage, testdata.Org2ID, cluster3ID, testdata.ClusterReportEmpty, testdata.ReportEmptyRulesParsed)
 
-	result, err := mockStorage.ListOfClustersForOrg(testdata.OrgID, time.Now().Add(-time.Hour))
+	result, err := mockStorage.ListOfClustersForOrg(testdata.OrgID, time.Now().Add(-time.Hour), 0, 0)
 	helpers.FailOnError(t, err)
 
 	assert.ElementsMatch(t, []types.ClusterName{
@@ -448,13 +605,15 @@ func TestDBStorageListOfClustersForOrg(t *testing.T) {
 		cluster2ID,
 	}, result)
 
-	result, err = mockStorage.ListOfClustersForOrg(testdata.Org2ID, time.Now().Add(-time.Hour))
+	result, err = mockStorage.ListOfClustersForOrg(testdata.Org2ID, time.Now().Add(-time.Hour), 0, 0)
 	helpers.FailOnError(t, err)
 
 	assert.Equal(t, []types.ClusterName{cluster3ID}, result)
 }
 
 func TestDBStorageListOfClustersTimeLimit(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -466,7 +625,7 @@ func TestDBStorageListOfClustersTimeLimit(t *testing.T) {
 
 	// since we can't easily change reported_at without changing the core source code, let's make a request from the "future"
 	// fetch org overview with T+2h
-	result, err := mockStorage.ListOfClustersForOrg(testdata.OrgID, time.Now().Add(time.Hour*2))
+	result, err := mockStorage.ListOfClustersForOrg(testdata.OrgID, time.Now().Add(time.Hour*2), 0, 0)
 	helpers.FailOnError(t, err)
 
 	// must fetch nothing
@@ -474,7 +633,7 @@ func TestDBStorageListOfClustersTimeLimit(t *testing.T) {
 	assert.Empty(t, result)
 
 	// request with T-2h
-	result, err = mockStorage.ListOfClustersForOrg(testdata.OrgID, time.Now().Add(-time.Hour*2))
+	result, err = mockStorage.ListOfClustersForOrg(testdata.OrgID, time.Now().Add(-time.Hour*2), 0, 0)
 	helpers.FailOnError(t, err)
 
 	// must fetch all reports
@@ -485,25 +644,31 @@ func TestDBStorageListOfClustersTimeLimit(t *testing.T) {
 }
 
 func TestDBStorageListOfClustersNoTable(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, false)
 	defer closer()
 
-	_, err := mockStorage.ListOfClustersForOrg(5, time.Now().Add(-time.Hour))
+	_, err := mockStorage.ListOfClustersForOrg(5, time.Now().Add(-time.Hour), 0, 0)
 	assert.EqualError(t, err, "no such table: report")
 }
 
 // TestDBStorageListOfClustersClosedStorage check the behaviour of method ListOfOrgs
 func TestDBStorageListOfClustersClosedStorage(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	// we need to close storage right now
 	closer()
 
-	_, err := mockStorage.ListOfClustersForOrg(5, time.Now().Add(-time.Hour))
+	_, err := mockStorage.ListOfClustersForOrg(5, time.Now().Add(-time.Hour), 0, 0)
 	assert.EqualError(t, err, "sql: database is closed")
 }
 
 // TestMockDBReportsCount check the behaviour of method ReportsCount
 func TestMockDBReportsCount(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -515,6 +680,8 @@ func TestMockDBReportsCount(t *testing.T) {
 }
 
 func TestMockDBReportsCountNoTable(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, false)
 	defer closer()
 
@@ -523,6 +690,8 @@ func TestMockDBReportsCountNoTable(t *testing.T) {
 }
 
 func TestMockDBReportsCountClosedStorage(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, false)
 	// we need to close storage right now
 	closer()
@@ -573,6 +742,7 @@ func mustWriteReport(
 
 func TestDBStorageListOfOrgsLogError(t *testing.T) {
 	buf := new(bytes.Buffer)
+	defer func(logger zerolog.Logger) { log.Logger = logger }(log.Logger)
 	log.Logger = zerolog.New(buf)
 
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
@@ -603,6 +773,7 @@ func TestDBStorageListOfClustersForOrgScanError(t *testing.T) {
 	// just for the coverage, because this error can't happen ever because we use
 	// not null in table creation
 	buf := new(bytes.Buffer)
+	defer func(logger zerolog.Logger) { log.Logger = logger }(log.Logger)
 	log.Logger = zerolog.New(buf)
 
 	mockStorage, expects := ira_helpers.MustGetMockStorageWithExpects(t)
@@ -612,7 +783,7 @@ func TestDBStorageListOfClustersForOrgScanError(t *testing.T) {
 		sqlmock.NewRows([]string{"cluster"}).AddRow(nil),
 	)
 
-	_, err := mockStorage.ListOfClustersForOrg(testdata.OrgID, time.Now().Add(-time.Hour))
+	_, err := mockStorage.ListOfClustersForOrg(testdata.OrgID, time.Now().Add(-time.Hour), 0, 0)
 	helpers.FailOnError(t, err)
 
 	assert.Contains(t, buf.String(), "converting NULL to string is unsupported")
@@ -654,7 +825,36 @@ func TestDBStorageDeleteReports(t *testing.T) {
 	}
 }
 
+func TestDBStorage_DeleteClusterReport(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReport3Rules(t, mockStorage)
+
+	// deletion older than the stored report is skipped
+	err := mockStorage.DeleteClusterReport(
+		testdata.OrgID, testdata.ClusterName, testdata.LastCheckedAt.Add(-time.Hour),
+	)
+	assert.Equal(t, types.ErrOldReport, err)
+	assertNumberOfReports(t, mockStorage, 1)
+
+	deletedAt := testdata.LastCheckedAt.Add(time.Hour)
+	err = mockStorage.DeleteClusterReport(testdata.OrgID, testdata.ClusterName, deletedAt)
+	helpers.FailOnError(t, err)
+	assertNumberOfReports(t, mockStorage, 0)
+
+	_, _, err = mockStorage.ReadReportForClusterByClusterName(testdata.ClusterName)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+	err = mockStorage.DeleteClusterReport(testdata.OrgID, testdata.ClusterName, deletedAt.Add(time.Hour))
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
+
 func TestDBStorage_ReadReportForClusterByClusterName_OK(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -668,6 +868,8 @@ func TestDBStorage_ReadReportForClusterByClusterName_OK(t *testing.T) {
 }
 
 func TestDBStorage_CheckIfClusterExists_ClusterDoesNotExist(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -680,6 +882,8 @@ func TestDBStorage_CheckIfClusterExists_ClusterDoesNotExist(t *testing.T) {
 }
 
 func TestDBStorage_CheckIfClusterExists_DBError(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	closer()
 
@@ -696,6 +900,8 @@ func TestDBStorage_NewSQLite(t *testing.T) {
 }
 
 func TestDBStorageWriteConsumerError(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -740,49 +946,84 @@ func TestDBStorageWriteConsumerError(t *testing.T) {
 	assert.Equal(t, testError.Error(), storageError)
 }
 
+// testTopicName is the topic used by tests of consumer offsets
+const testTopicName = "ccx.ocp.results"
+
 func TestDBStorage_GetLatestKafkaOffset(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
-	offset, err := mockStorage.GetLatestKafkaOffset()
+	offset, err := mockStorage.GetLatestKafkaOffset(testTopicName, 0)
 	helpers.FailOnError(t, err)
 
 	assert.Equal(t, types.KafkaOffset(0), offset)
 
-	mustWriteReport3Rules(t, mockStorage)
+	helpers.FailOnError(t, mockStorage.WriteKafkaOffset(testTopicName, 0, 10))
+	helpers.FailOnError(t, mockStorage.WriteKafkaOffset(testTopicName, 1, 5))
+	helpers.FailOnError(t, mockStorage.WriteKafkaOffset(testTopicName, 0, 11))
+
+	offset, err = mockStorage.GetLatestKafkaOffset(testTopicName, 0)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.KafkaOffset(11), offset)
 
-	offset, err = mockStorage.GetLatestKafkaOffset()
+	offset, err = mockStorage.GetLatestKafkaOffset(testTopicName, 1)
 	helpers.FailOnError(t, err)
+	assert.Equal(t, types.KafkaOffset(5), offset)
 
-	assert.Equal(t, types.KafkaOffset(1), offset)
+	// offsets of other topics are separated
+	offset, err = mockStorage.GetLatestKafkaOffset("another.topic", 0)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.KafkaOffset(0), offset)
 }
 
-func TestDBStorage_GetLatestKafkaOffset_ZeroOffset(t *testing.T) {
+func TestDBStorage_GetLatestKafkaOffsets(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
-	offset, err := mockStorage.GetLatestKafkaOffset()
+	offsets, err := mockStorage.GetLatestKafkaOffsets()
 	helpers.FailOnError(t, err)
+	assert.Empty(t, offsets)
 
-	assert.Equal(t, types.KafkaOffset(0), offset)
+	helpers.FailOnError(t, mockStorage.WriteKafkaOffset(testTopicName, 1, 5))
+	helpers.FailOnError(t, mockStorage.WriteKafkaOffset(testTopicName, 0, 10))
 
-	err = mockStorage.WriteReportForCluster(
-		testdata.OrgID,
-		testdata.ClusterName,
-		testdata.Report3Rules,
-		testdata.Report3RulesParsed,
-		testdata.LastCheckedAt,
-		types.KafkaOffset(0),
-	)
+	offsets, err = mockStorage.GetLatestKafkaOffsets()
 	helpers.FailOnError(t, err)
 
-	offset, err = mockStorage.GetLatestKafkaOffset()
-	helpers.FailOnError(t, err)
+	assert.Len(t, offsets, 2)
+	for i, expected := range []types.KafkaPartitionOffset{
+		{Topic: testTopicName, Partition: 0, Offset: 10},
+		{Topic: testTopicName, Partition: 1, Offset: 5},
+	} {
+		assert.Equal(t, expected.Topic, offsets[i].Topic)
+		assert.Equal(t, expected.Partition, offsets[i].Partition)
+		assert.Equal(t, expected.Offset, offsets[i].Offset)
+		assert.False(t, offsets[i].UpdatedAt.IsZero())
+	}
+}
 
-	assert.Equal(t, types.KafkaOffset(0), offset)
+func TestDBStorage_KafkaOffsets_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, false)
+	closer()
+
+	assert.Error(t, mockStorage.WriteKafkaOffset(testTopicName, 0, 10))
+
+	_, err := mockStorage.GetLatestKafkaOffset(testTopicName, 0)
+	assert.Error(t, err)
+
+	_, err = mockStorage.GetLatestKafkaOffsets()
+	assert.Error(t, err)
 }
 
 func TestDBStorage_Init(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -803,6 +1044,8 @@ func TestDBStorage_Init(t *testing.T) {
 }
 
 func TestDBStorage_Init_Error(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, false)
 	defer closer()
 
@@ -829,6 +1072,25 @@ func TestDBStorage_Init_Error(t *testing.T) {
 func createReportTableWithBadClusterField(t *testing.T, mockStorage storage.Storage) {
 	connection := storage.GetConnection(mockStorage.(*storage.DBStorage))
 
+	createBadReportTable(t, connection)
+
+	query := `
+		CREATE TABLE rule_hit (
+			org_id			INTEGER NOT NULL,
+			cluster_id      VARCHAR NOT NULL,
+			rule_fqdn 		VARCHAR NOT NULL,
+			error_key        VARCHAR NOT NULL,
+			template_data   VARCHAR NOT NULL,
+			PRIMARY KEY(cluster_id, org_id, rule_fqdn, error_key)
+		)
+	`
+
+	_, err := connection.Exec(query)
+	helpers.FailOnError(t, err)
+}
+
+// createBadReportTable creates the report table with integer cluster column
+func createBadReportTable(t *testing.T, connection *sql.DB) {
 	query := `
 		CREATE TABLE report (
 			org_id          INTEGER NOT NULL,
@@ -837,6 +1099,10 @@ func createReportTableWithBadClusterField(t *testing.T, mockStorage storage.Stor
 			reported_at     TIMESTAMP,
 			last_checked_at TIMESTAMP,
 			kafka_offset BIGINT NOT NULL DEFAULT 0,
+			status          VARCHAR NOT NULL DEFAULT 'analyzed',
+			message_key     VARCHAR,
+			generation      BIGINT NOT NULL DEFAULT 0,
+			cluster_class   VARCHAR NOT NULL DEFAULT 'self-managed',
 			PRIMARY KEY(org_id, cluster)
 		)
 	`
@@ -850,6 +1116,10 @@ func createReportTableWithBadClusterField(t *testing.T, mockStorage storage.Stor
 				reported_at     TIMESTAMP,
 				last_checked_at TIMESTAMP,
 				kafka_offset BIGINT NOT NULL DEFAULT 0,
+				status          VARCHAR NOT NULL DEFAULT 'analyzed',
+				message_key     VARCHAR,
+				generation      BIGINT NOT NULL DEFAULT 0,
+				cluster_class   VARCHAR NOT NULL DEFAULT 'self-managed',
 				PRIMARY KEY(org_id, cluster)
 			)
 		`
@@ -858,20 +1128,6 @@ func createReportTableWithBadClusterField(t *testing.T, mockStorage storage.Stor
 	// create a table with a bad type
 	_, err := connection.Exec(query)
 	helpers.FailOnError(t, err)
-
-	query = `
-		CREATE TABLE rule_hit (
-			org_id			INTEGER NOT NULL,
-			cluster_id      VARCHAR NOT NULL,
-			rule_fqdn 		VARCHAR NOT NULL,
-			error_key        VARCHAR NOT NULL,
-			template_data   VARCHAR NOT NULL,
-			PRIMARY KEY(cluster_id, org_id, rule_fqdn, error_key)
-		)
-	`
-
-	_, err = connection.Exec(query)
-	helpers.FailOnError(t, err)
 }
 
 // TestConstructInClausule checks the helper function constructInClausule
@@ -910,6 +1166,8 @@ func TestArgsWithClusterNames(t *testing.T) {
 // TestDBStorageReadReportsForClusters1 check the behaviour of method
 // ReadReportForClusters
 func TestDBStorageReadReportsForClusters1(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -927,6 +1185,8 @@ func TestDBStorageReadReportsForClusters1(t *testing.T) {
 // TestDBStorageReadReportsForClusters2 check the behaviour of method
 // ReadReportForClusters
 func TestDBStorageReadReportsForClusters2(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -944,6 +1204,8 @@ func TestDBStorageReadReportsForClusters2(t *testing.T) {
 // TestDBStorageReadReportsForClusters3 check the behaviour of method
 // ReadReportForClusters
 func TestDBStorageReadReportsForClusters3(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -960,6 +1222,8 @@ func TestDBStorageReadReportsForClusters3(t *testing.T) {
 // TestDBStorageReadOrgIDsForClusters1 check the behaviour of method
 // ReadOrgIDsForClusters
 func TestDBStorageReadOrgIDsForClusters1(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -977,6 +1241,8 @@ func TestDBStorageReadOrgIDsForClusters1(t *testing.T) {
 // TestDBStorageReadOrgIDsForClusters2 check the behaviour of method
 // ReadOrgIDsForClusters
 func TestDBStorageReadOrgIDsForClusters2(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -995,6 +1261,8 @@ func TestDBStorageReadOrgIDsForClusters2(t *testing.T) {
 // TestDBStorageReadOrgIDsForClusters3 check the behaviour of method
 // ReadOrgIDsForClusters
 func TestDBStorageReadOrgIDsForClusters3(t *testing.T) {
+	t.Parallel()
+
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
 
@@ -1007,3 +1275,1471 @@ func TestDBStorageReadOrgIDsForClusters3(t *testing.T) {
 	// error is expected in this case
 	assert.NotNil(t, err)
 }
+
+// TestDBStorageDoesClusterExistWithOrgID check the behaviour of method
+// DoesClusterExistWithOrgID
+func TestDBStorageDoesClusterExistWithOrgID(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	exists, orgID, err := mockStorage.DoesClusterExistWithOrgID(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.False(t, exists)
+	assert.Equal(t, types.OrgID(0), orgID)
+
+	writeReportForCluster(t, mockStorage, testdata.Org2ID, testdata.ClusterName, `{"report":{}}`, testdata.ReportEmptyRulesParsed)
+
+	exists, orgID, err = mockStorage.DoesClusterExistWithOrgID(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, testdata.Org2ID, orgID)
+}
+
+// TestDBStorageDoesClusterExistWithOrgIDDBError check the behaviour of
+// method DoesClusterExistWithOrgID when DB is closed
+func TestDBStorageDoesClusterExistWithOrgIDDBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	_, _, err := mockStorage.DoesClusterExistWithOrgID(testdata.ClusterName)
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
+// TestDBStorageReadOrgIDsOfClusters check the behaviour of method
+// ReadOrgIDsOfClusters
+func TestDBStorageReadOrgIDsOfClusters(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	writeReportForCluster(t, mockStorage, testdata.OrgID, testdata.ClusterName, `{"report":{}}`, testdata.ReportEmptyRulesParsed)
+	otherClusterName := testdata.GetRandomClusterID()
+	writeReportForCluster(t, mockStorage, testdata.Org2ID, otherClusterName, `{"report":{}}`, testdata.ReportEmptyRulesParsed)
+
+	// unknown cluster is not in the result
+	results, err := mockStorage.ReadOrgIDsOfClusters([]types.ClusterName{
+		testdata.ClusterName, otherClusterName, "not-a-cluster",
+	})
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, map[types.ClusterName]types.OrgID{
+		testdata.ClusterName: testdata.OrgID,
+		otherClusterName:     testdata.Org2ID,
+	}, results)
+
+	// empty list is not an error
+	results, err = mockStorage.ReadOrgIDsOfClusters(nil)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, results)
+}
+
+// TestDBStorageReadOrgIDsOfClustersDBError check the behaviour of method
+// ReadOrgIDsOfClusters when DB is closed
+func TestDBStorageReadOrgIDsOfClustersDBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	_, err := mockStorage.ReadOrgIDsOfClusters([]types.ClusterName{testdata.ClusterName})
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
+func TestDBStorage_RebuildClustersLastCheckedCache(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+
+	mustWriteReport3Rules(t, mockStorage)
+
+	// corrupt the cache
+	clustersLastChecked := storage.GetClustersLastChecked(dbStorage)
+	clustersLastChecked[testdata.ClusterName] = time.Now().Add(24 * time.Hour)
+	clustersLastChecked["ee7d2bf4-8933-4a3a-8634-3328fe806e08"] = time.Now()
+
+	size, err := mockStorage.RebuildClustersLastCheckedCache()
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, 1, size)
+	assert.Len(t, clustersLastChecked, 1)
+	assert.Equal(t, testdata.LastCheckedAt.Unix(), clustersLastChecked[testdata.ClusterName].Unix())
+}
+
+func TestDBStorage_RebuildClustersLastCheckedCache_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+
+	mustWriteReport3Rules(t, mockStorage)
+	closer()
+
+	_, err := mockStorage.RebuildClustersLastCheckedCache()
+	assert.EqualError(t, err, "sql: database is closed")
+
+	// the cache has to stay untouched
+	assert.Len(t, storage.GetClustersLastChecked(dbStorage), 1)
+}
+
+func TestDBStorage_GetClustersLastCheckedCacheStats(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	now := time.Now()
+
+	writeReportForCluster := func(clusterName types.ClusterName, lastCheckedAt time.Time) {
+		err := mockStorage.WriteReportForCluster(
+			testdata.OrgID, clusterName, testdata.ClusterReportEmpty, testdata.ReportEmptyRulesParsed, lastCheckedAt, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	writeReportForCluster("1deb586c-fb85-4db4-ae5b-139cdbdf77ae", now.Add(-time.Minute))
+	writeReportForCluster("a1bf5b15-5229-4042-9825-c69dc36b57f5", now.Add(-2*time.Hour))
+	writeReportForCluster("ee7d2bf4-8933-4a3a-8634-3328fe806e08", now.Add(-60*24*time.Hour))
+
+	stats, err := mockStorage.GetClustersLastCheckedCacheStats()
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, 3, stats.Size)
+	assert.Equal(t, 3, stats.ReportsInStorage)
+	assert.Equal(t, storage.ClustersLastCheckedAgeDistribution{
+		LessThanHour: 1,
+		LessThanDay:  1,
+		Older:        1,
+	}, stats.AgeDistribution)
+	assert.Equal(t, types.Timestamp(now.Add(-60*24*time.Hour).UTC().Format(time.RFC3339)), stats.Oldest)
+	assert.Equal(t, types.Timestamp(now.Add(-time.Minute).UTC().Format(time.RFC3339)), stats.Newest)
+	assert.Greater(t, stats.MemoryEstimateBytes, 3*36)
+}
+
+func TestDBStorage_CheckClustersLastCheckedDivergence(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+	lastCheckedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	const (
+		staleCluster    = types.ClusterName("1deb586c-fb85-4db4-ae5b-139cdbdf77ae")
+		uncachedCluster = types.ClusterName("a1bf5b15-5229-4042-9825-c69dc36b57f5")
+		matchingCluster = types.ClusterName("ee7d2bf4-8933-4a3a-8634-3328fe806e08")
+		removedCluster  = types.ClusterName("5d5892d3-1f74-4ccf-91af-548dfc9767aa")
+	)
+
+	for _, clusterName := range []types.ClusterName{staleCluster, uncachedCluster, matchingCluster} {
+		err := mockStorage.WriteReportForCluster(
+			testdata.OrgID, clusterName, testdata.ClusterReportEmpty, testdata.ReportEmptyRulesParsed, lastCheckedAt, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	// simulate writes and deletes done by other instances
+	clustersLastChecked := storage.GetClustersLastChecked(dbStorage)
+	clustersLastChecked[staleCluster] = lastCheckedAt.Add(-time.Hour)
+	delete(clustersLastChecked, uncachedCluster)
+	clustersLastChecked[removedCluster] = lastCheckedAt
+
+	divergence, err := mockStorage.CheckClustersLastCheckedDivergence(10)
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, 3, divergence.SampledReports)
+	assert.Equal(t, 1, divergence.Matching)
+	assert.Equal(t, 1, divergence.Stale)
+	assert.Equal(t, 0, divergence.Ahead)
+	assert.Equal(t, 1, divergence.MissingInCache)
+	assert.Equal(t, 3, divergence.SampledCache)
+	assert.Equal(t, 1, divergence.MissingInStorage)
+	assert.Equal(t, float64(3600), divergence.MaxStalenessSeconds)
+	assert.Len(t, divergence.Examples, 3)
+
+	// the cache is not changed
+	assert.Len(t, clustersLastChecked, 3)
+}
+
+func TestDBStorage_GetClustersLastCheckedCacheStats_Empty(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	stats, err := mockStorage.GetClustersLastCheckedCacheStats()
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, storage.ClustersLastCheckedCacheStats{}, stats)
+}
+
+func TestDBStorage_GetClustersLastCheckedCacheStats_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	_, err := mockStorage.GetClustersLastCheckedCacheStats()
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
+func TestDBStorage_IterateReports(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReport3Rules(t, mockStorage)
+
+	var records []storage.ReportRecord
+	err := mockStorage.IterateReports(func(record storage.ReportRecord) error {
+		records = append(records, record)
+		return nil
+	})
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, records, 1)
+	assert.Equal(t, testdata.OrgID, records[0].OrgID)
+	assert.Equal(t, testdata.ClusterName, records[0].ClusterName)
+	assert.Equal(t, testdata.Report3Rules, records[0].Report)
+	assert.True(t, records[0].LastCheckedAt.Valid)
+	assert.Equal(t, testdata.LastCheckedAt.Unix(), records[0].LastCheckedAt.Time.Unix())
+	assert.Equal(t, testdata.KafkaOffset, records[0].KafkaOffset)
+}
+
+func TestDBStorage_IterateReports_CallbackError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReport3Rules(t, mockStorage)
+
+	err := mockStorage.IterateReports(func(storage.ReportRecord) error {
+		return fmt.Errorf("callback error")
+	})
+	assert.EqualError(t, err, "callback error")
+}
+
+func TestDBStorage_IterateReports_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	err := mockStorage.IterateReports(func(storage.ReportRecord) error {
+		return nil
+	})
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
+func TestDBStorage_IterateRuleHits(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReport3Rules(t, mockStorage)
+
+	var records []storage.RuleHitRecord
+	err := mockStorage.IterateRuleHits(func(record storage.RuleHitRecord) error {
+		records = append(records, record)
+		return nil
+	})
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, records, len(testdata.Report3RulesParsed))
+	for _, record := range records {
+		assert.Equal(t, testdata.OrgID, record.OrgID)
+		assert.Equal(t, testdata.ClusterName, record.ClusterName)
+	}
+}
+
+func TestDBStorage_IterateRuleHits_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	err := mockStorage.IterateRuleHits(func(storage.RuleHitRecord) error {
+		return nil
+	})
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
+func TestDBStorage_ReadRuleHitsForCluster(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	records, err := mockStorage.ReadRuleHitsForCluster(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, records)
+
+	mustWriteReport3Rules(t, mockStorage)
+
+	// rule hits of other clusters are not returned
+	err = mockStorage.WriteReportForCluster(
+		testdata.Org2ID,
+		testdata.GetRandomClusterID(),
+		testdata.Report2Rules,
+		testdata.Report2RulesParsed,
+		testdata.LastCheckedAt,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	records, err = mockStorage.ReadRuleHitsForCluster(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, records, len(testdata.Report3RulesParsed))
+	for _, record := range records {
+		assert.Equal(t, testdata.OrgID, record.OrgID)
+		assert.Equal(t, testdata.ClusterName, record.ClusterName)
+		assert.NotEmpty(t, record.TemplateData)
+	}
+}
+
+func TestDBStorage_ReadRuleHitsForCluster_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	_, err := mockStorage.ReadRuleHitsForCluster(testdata.ClusterName)
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
+// mustWriteRuleHitsOfClusters writes reports of the clusters of the
+// organizations with a rule hit of Rule1ID with template data containing the
+// cluster name
+func mustWriteRuleHitsOfClusters(
+	t *testing.T, mockStorage storage.Storage, clusters map[types.ClusterName]types.OrgID,
+) {
+	for clusterName, orgID := range clusters {
+		err := mockStorage.WriteReportForCluster(
+			orgID,
+			clusterName,
+			testdata.Report0Rules,
+			[]types.ReportItem{{
+				Module:       testdata.Rule1ID,
+				ErrorKey:     testdata.ErrorKey1,
+				TemplateData: []byte(fmt.Sprintf(`{"cluster": "%v"}`, clusterName)),
+			}},
+			testdata.LastCheckedAt,
+			testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+}
+
+func TestDBStorage_IterateRuleHitsForRule(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	const (
+		cluster1 = types.ClusterName("11111111-0000-0000-0000-000000000000")
+		cluster2 = types.ClusterName("22222222-0000-0000-0000-000000000000")
+		cluster3 = types.ClusterName("33333333-0000-0000-0000-000000000000")
+	)
+
+	mustWriteRuleHitsOfClusters(t, mockStorage, map[types.ClusterName]types.OrgID{
+		cluster3: testdata.OrgID,
+		cluster2: testdata.Org2ID,
+		cluster1: testdata.OrgID,
+	})
+
+	iterate := func(orgID types.OrgID, errorKey types.ErrorKey, offset, limit int) []types.ClusterName {
+		clusters := []types.ClusterName{}
+
+		err := mockStorage.IterateRuleHitsForRule(
+			orgID, testdata.Rule1ID, errorKey, offset, limit,
+			func(record storage.RuleHitRecord) error {
+				assert.Equal(t, fmt.Sprintf(`{"cluster": "%v"}`, record.ClusterName), record.TemplateData)
+				clusters = append(clusters, record.ClusterName)
+				return nil
+			},
+		)
+		helpers.FailOnError(t, err)
+
+		return clusters
+	}
+
+	// ordered by organization and cluster
+	assert.Equal(t, []types.ClusterName{cluster1, cluster3, cluster2}, iterate(0, testdata.ErrorKey1, 0, 0))
+	assert.Equal(t, []types.ClusterName{cluster1, cluster3}, iterate(testdata.OrgID, testdata.ErrorKey1, 0, 0))
+	assert.Equal(t, []types.ClusterName{cluster3}, iterate(0, testdata.ErrorKey1, 1, 1))
+	assert.Equal(t, []types.ClusterName{cluster3, cluster2}, iterate(0, testdata.ErrorKey1, 1, 0))
+	assert.Empty(t, iterate(0, testdata.ErrorKey2, 0, 0))
+}
+
+func TestDBStorage_IterateRuleHitsForRule_CallbackError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteRuleHitsOfClusters(t, mockStorage, map[types.ClusterName]types.OrgID{
+		testdata.ClusterName: testdata.OrgID,
+	})
+
+	err := mockStorage.IterateRuleHitsForRule(
+		0, testdata.Rule1ID, testdata.ErrorKey1, 0, 0,
+		func(storage.RuleHitRecord) error {
+			return fmt.Errorf("callback error")
+		},
+	)
+	assert.EqualError(t, err, "callback error")
+}
+
+func TestDBStorage_IterateRuleHitsForRule_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	err := mockStorage.IterateRuleHitsForRule(
+		0, testdata.Rule1ID, testdata.ErrorKey1, 0, 0,
+		func(storage.RuleHitRecord) error {
+			return nil
+		},
+	)
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
+func TestDBStorage_DeleteReportsNotCheckedSince(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReport3Rules(t, mockStorage)
+
+	// the report is newer than the threshold
+	deleted, err := mockStorage.DeleteReportsNotCheckedSince(testdata.LastCheckedAt.Add(-time.Hour))
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 0, deleted)
+
+	deleted, err = mockStorage.DeleteReportsNotCheckedSince(testdata.LastCheckedAt.Add(time.Hour))
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 1, deleted)
+
+	count, err := mockStorage.ReportsCount()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 0, count)
+
+	err = mockStorage.IterateRuleHits(func(storage.RuleHitRecord) error {
+		return fmt.Errorf("rule hits were not deleted")
+	})
+	helpers.FailOnError(t, err)
+
+	clustersLastChecked := storage.GetClustersLastChecked(mockStorage.(*storage.DBStorage))
+	assert.Empty(t, clustersLastChecked)
+}
+
+func TestDBStorage_DeleteReportsNotCheckedSince_FrozenOrg(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReport3Rules(t, mockStorage)
+	helpers.FailOnError(t, mockStorage.FreezeOrg(testdata.OrgID, "legal hold"))
+
+	count, err := mockStorage.CountClustersNotCheckedSince(testdata.LastCheckedAt.Add(time.Hour))
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 0, count)
+
+	deleted, err := mockStorage.DeleteReportsNotCheckedSince(testdata.LastCheckedAt.Add(time.Hour))
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 0, deleted)
+
+	count, err = mockStorage.ReportsCount()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 1, count)
+
+	clustersLastChecked := storage.GetClustersLastChecked(mockStorage.(*storage.DBStorage))
+	assert.Contains(t, clustersLastChecked, testdata.ClusterName)
+}
+
+func TestDBStorage_DeleteReportsNotCheckedSince_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	_, err := mockStorage.DeleteReportsNotCheckedSince(time.Now())
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
+func TestDBStorage_CleanupOldData(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReport3Rules(t, mockStorage)
+
+	err := mockStorage.WriteConsumerError(&sarama.ConsumerMessage{
+		Topic:     "topic",
+		Offset:    10,
+		Value:     []byte("value"),
+		Timestamp: time.Now(),
+	}, fmt.Errorf("Consumer error"))
+	helpers.FailOnError(t, err)
+
+	// nothing is deleted without retention
+	pruned, err := mockStorage.CleanupOldData(storage.RetentionPolicy{})
+	helpers.FailOnError(t, err)
+	assert.Empty(t, pruned)
+
+	// data are newer than the thresholds
+	pruned, err = mockStorage.CleanupOldData(storage.RetentionPolicy{
+		ReportsNotCheckedSince:       testdata.LastCheckedAt.Add(-time.Hour),
+		ConsumerErrorsConsumedBefore: time.Now().Add(-time.Hour),
+	})
+	helpers.FailOnError(t, err)
+	assert.Equal(t, map[string]int{"report": 0, "consumer_error": 0}, pruned)
+
+	pruned, err = mockStorage.CleanupOldData(storage.RetentionPolicy{
+		ConsumerErrorsConsumedBefore: time.Now().Add(time.Hour),
+	})
+	helpers.FailOnError(t, err)
+	assert.Equal(t, map[string]int{"consumer_error": 1}, pruned)
+
+	// the report is kept without report retention
+	count, err := mockStorage.ReportsCount()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 1, count)
+
+	pruned, err = mockStorage.CleanupOldData(storage.RetentionPolicy{
+		ReportsNotCheckedSince: testdata.LastCheckedAt.Add(time.Hour),
+	})
+	helpers.FailOnError(t, err)
+	assert.Equal(t, map[string]int{"report": 1}, pruned)
+}
+
+func TestDBStorage_CleanupOldData_FrozenOrg(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReport3Rules(t, mockStorage)
+	helpers.FailOnError(t, mockStorage.FreezeOrg(testdata.OrgID, "legal hold"))
+
+	pruned, err := mockStorage.CleanupOldData(storage.RetentionPolicy{
+		ReportsNotCheckedSince: testdata.LastCheckedAt.Add(time.Hour),
+	})
+	helpers.FailOnError(t, err)
+	assert.Equal(t, map[string]int{"report": 0}, pruned)
+
+	count, err := mockStorage.ReportsCount()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestDBStorage_CleanupOldData_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	_, err := mockStorage.CleanupOldData(storage.RetentionPolicy{ConsumerErrorsConsumedBefore: time.Now()})
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
+func TestDBStorage_CountClustersNotCheckedSince(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReport3Rules(t, mockStorage)
+
+	count, err := mockStorage.CountClustersNotCheckedSince(testdata.LastCheckedAt.Add(-time.Hour))
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 0, count)
+
+	count, err = mockStorage.CountClustersNotCheckedSince(testdata.LastCheckedAt.Add(time.Hour))
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestDBStorage_CountClustersNotCheckedSince_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	_, err := mockStorage.CountClustersNotCheckedSince(time.Now())
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
+func TestInitAndGetDriver_PGSchema(t *testing.T) {
+	_, _, dataSources, err := storage.InitAndGetDriver(storage.Configuration{
+		Driver:   "postgres",
+		PGParams: "sslmode=disable",
+		PGSchema: "stage",
+	})
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, dataSources, 1)
+	assert.True(t, strings.HasSuffix(dataSources[0], "?sslmode=disable&search_path=stage"), dataSources[0])
+}
+
+func TestInitAndGetDriver_PGSchemaWithoutParams(t *testing.T) {
+	_, _, dataSources, err := storage.InitAndGetDriver(storage.Configuration{
+		Driver:   "postgres",
+		PGSchema: "perf_2",
+	})
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, dataSources, 1)
+	assert.True(t, strings.HasSuffix(dataSources[0], "?search_path=perf_2"), dataSources[0])
+}
+
+func TestInitAndGetDriver_PGURL(t *testing.T) {
+	for _, testCase := range []struct {
+		url        string
+		schema     string
+		dataSource string
+	}{
+		{
+			url:        "postgres://user:password@db:5432/aggregator?sslmode=disable",
+			dataSource: "postgres://user:password@db:5432/aggregator?sslmode=disable",
+		},
+		{
+			url:        "postgres://user:password@db:5432/aggregator?sslmode=disable",
+			schema:     "stage",
+			dataSource: "postgres://user:password@db:5432/aggregator?search_path=stage&sslmode=disable",
+		},
+		{
+			url:        "host=db dbname=aggregator sslmode=disable",
+			schema:     "stage",
+			dataSource: "host=db dbname=aggregator sslmode=disable search_path=stage",
+		},
+	} {
+		_, _, dataSources, err := storage.InitAndGetDriver(storage.Configuration{
+			Driver: "postgres",
+			// the URL replaces other connection options
+			PGURL:    testCase.url,
+			PGHost:   "db1,db2",
+			PGSchema: testCase.schema,
+		})
+		helpers.FailOnError(t, err)
+
+		assert.Equal(t, []string{testCase.dataSource}, dataSources)
+	}
+}
+
+func TestInitAndGetDriver_PGTLS(t *testing.T) {
+	configuration := storage.Configuration{
+		Driver:        "postgres",
+		PGParams:      "connect_timeout=10",
+		PGSchema:      "stage",
+		PGSSLMode:     "verify-full",
+		PGSSLRootCert: "/etc/ssl/root ca.crt",
+	}
+
+	_, _, dataSources, err := storage.InitAndGetDriver(configuration)
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, dataSources, 1)
+	assert.True(t, strings.HasSuffix(
+		dataSources[0],
+		"?connect_timeout=10&sslmode=verify-full&sslrootcert=%2Fetc%2Fssl%2Froot+ca.crt&search_path=stage",
+	), dataSources[0])
+
+	// TLS options replace the ones in the connection URL
+	configuration.PGURL = "postgres://user:password@db:5432/aggregator?sslmode=disable"
+	_, _, dataSources, err = storage.InitAndGetDriver(configuration)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []string{
+		"postgres://user:password@db:5432/aggregator?search_path=stage&sslmode=verify-full&sslrootcert=%2Fetc%2Fssl%2Froot+ca.crt",
+	}, dataSources)
+
+	configuration.PGURL = "host=db dbname=aggregator sslmode=disable"
+	_, _, dataSources, err = storage.InitAndGetDriver(configuration)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []string{
+		`host=db dbname=aggregator sslmode=disable sslmode=verify-full sslrootcert='/etc/ssl/root ca.crt' search_path=stage`,
+	}, dataSources)
+}
+
+func TestInitAndGetDriver_InvalidPGURL(t *testing.T) {
+	for _, url := range []string{
+		"mysql://user:password@db/aggregator",
+		"postgres://user:password@db:port/aggregator",
+		"host=db dbname",
+	} {
+		_, _, _, err := storage.InitAndGetDriver(storage.Configuration{
+			Driver: "postgres",
+			PGURL:  url,
+		})
+		assert.Error(t, err, url)
+		if err != nil {
+			assert.NotContains(t, err.Error(), "password@", url)
+		}
+	}
+}
+
+func TestRedactDataSource(t *testing.T) {
+	assert.Equal(
+		t,
+		"postgres://user:xxxxx@db:5432/aggregator?sslmode=disable",
+		storage.RedactDataSource("postgres://user:secret@db:5432/aggregator?sslmode=disable"),
+	)
+	assert.Equal(
+		t,
+		"postgres://user@db/aggregator",
+		storage.RedactDataSource("postgres://user@db/aggregator"),
+	)
+	assert.Equal(
+		t,
+		"host=db password=xxxxx dbname=aggregator",
+		storage.RedactDataSource("host=db password=secret dbname=aggregator"),
+	)
+	assert.Equal(
+		t,
+		"host=db password = xxxxx dbname=aggregator",
+		storage.RedactDataSource("host=db password = 'very secret' dbname=aggregator"),
+	)
+}
+
+func TestNewStorage_ConnectionPool(t *testing.T) {
+	s, err := storage.New(storage.Configuration{
+		Driver:             "sqlite3",
+		SQLiteDataSource:   ":memory:",
+		MaxOpenConnections: 10,
+		MaxIdleConnections: 5,
+		ConnMaxLifetime:    time.Hour,
+		ConnMaxIdleTime:    time.Minute,
+	})
+	helpers.FailOnError(t, err)
+	defer func() {
+		helpers.FailOnError(t, s.Close())
+	}()
+
+	assert.Equal(t, 10, storage.GetConnection(s).Stats().MaxOpenConnections)
+}
+
+func TestNewStorage_InvalidPGSchema(t *testing.T) {
+	for _, schema := range []string{"Stage", "stage,public", "stage; DROP TABLE report", "1stage"} {
+		_, err := storage.New(storage.Configuration{
+			Driver:   "postgres",
+			PGSchema: schema,
+		})
+		assert.EqualError(t, err, fmt.Sprintf("invalid PostgreSQL schema name '%v'", schema))
+	}
+}
+
+func TestDBStorage_CreateSchemaIfNotExists(t *testing.T) {
+	mockStorage, expects := ira_helpers.MustGetMockStorageWithExpectsForDriver(t, types.DBDriverPostgres)
+	defer ira_helpers.MustCloseMockStorageWithExpects(t, mockStorage, expects)
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+	storage.SetSchema(dbStorage, "stage")
+
+	expects.ExpectExec(`CREATE SCHEMA IF NOT EXISTS "stage";`).
+		WillReturnResult(driver.ResultNoRows)
+
+	helpers.FailOnError(t, dbStorage.CreateSchemaIfNotExists())
+}
+
+func TestDBStorage_CreateSchemaIfNotExists_DefaultSchema(t *testing.T) {
+	mockStorage, expects := ira_helpers.MustGetMockStorageWithExpectsForDriver(t, types.DBDriverPostgres)
+	defer ira_helpers.MustCloseMockStorageWithExpects(t, mockStorage, expects)
+
+	// no query is expected
+	helpers.FailOnError(t, mockStorage.(*storage.DBStorage).CreateSchemaIfNotExists())
+}
+
+func TestDBStorage_ClusterAnnotations(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReport3Rules(t, mockStorage)
+
+	annotations, err := mockStorage.ReadClusterAnnotations(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, annotations)
+
+	first, err := mockStorage.AddClusterAnnotation(testdata.ClusterName, testdata.UserID, "first note")
+	helpers.FailOnError(t, err)
+	second, err := mockStorage.AddClusterAnnotation(testdata.ClusterName, testdata.UserID, "second note")
+	helpers.FailOnError(t, err)
+
+	assert.NotEqual(t, first.ID, second.ID)
+	assert.Equal(t, testdata.ClusterName, first.ClusterID)
+	assert.Equal(t, testdata.UserID, first.Author)
+	assert.Equal(t, "first note", first.Message)
+	assert.NotEmpty(t, first.CreatedAt)
+
+	annotations, err = mockStorage.ReadClusterAnnotations(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.ElementsMatch(t, []types.ClusterAnnotation{first, second}, annotations)
+
+	helpers.FailOnError(t, mockStorage.DeleteClusterAnnotation(testdata.ClusterName, first.ID))
+
+	annotations, err = mockStorage.ReadClusterAnnotations(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []types.ClusterAnnotation{second}, annotations)
+}
+
+func TestDBStorage_AddClusterAnnotation_ClusterNotFound(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	_, err := mockStorage.AddClusterAnnotation(testdata.ClusterName, testdata.UserID, "note")
+	assert.Equal(t, &types.ItemNotFoundError{ItemID: testdata.ClusterName}, err)
+}
+
+func TestDBStorage_DeleteClusterAnnotation_NotFound(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReport3Rules(t, mockStorage)
+
+	annotation, err := mockStorage.AddClusterAnnotation(testdata.ClusterName, testdata.UserID, "note")
+	helpers.FailOnError(t, err)
+
+	// annotation of other cluster can't be deleted
+	err = mockStorage.DeleteClusterAnnotation(testdata.GetRandomClusterID(), annotation.ID)
+	assert.Equal(t, &types.ItemNotFoundError{ItemID: annotation.ID}, err)
+}
+
+func TestDBStorage_ClusterAnnotations_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	_, err := mockStorage.AddClusterAnnotation(testdata.ClusterName, testdata.UserID, "note")
+	assert.EqualError(t, err, "sql: database is closed")
+
+	_, err = mockStorage.ReadClusterAnnotations(testdata.ClusterName)
+	assert.EqualError(t, err, "sql: database is closed")
+
+	err = mockStorage.DeleteClusterAnnotation(testdata.ClusterName, "c8d2b1b3-5e2a-4a7b-9d5c-0a3c2f6b2d11")
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
+func TestDBStorage_ReadOrgInfo(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	_, err := mockStorage.ReadOrgInfo(testdata.OrgID)
+	assert.Equal(t, &types.ItemNotFoundError{ItemID: testdata.OrgID}, err)
+
+	mustWriteReport3Rules(t, mockStorage)
+
+	first, err := mockStorage.ReadOrgInfo(testdata.OrgID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.OrgID, first.OrgID)
+	assert.NotEmpty(t, first.FirstSeenAt)
+	assert.Equal(t, first.FirstSeenAt, first.LastSeenAt)
+
+	// report for another cluster of the same organization
+	err = mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		testdata.GetRandomClusterID(),
+		testdata.Report3Rules,
+		testdata.Report3RulesParsed,
+		testdata.LastCheckedAt,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	second, err := mockStorage.ReadOrgInfo(testdata.OrgID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, first.FirstSeenAt, second.FirstSeenAt)
+	assert.True(t, second.LastSeenAt >= first.LastSeenAt)
+
+	// other organizations are not affected
+	_, err = mockStorage.ReadOrgInfo(testdata.Org2ID)
+	assert.Equal(t, &types.ItemNotFoundError{ItemID: testdata.Org2ID}, err)
+
+	helpers.FailOnError(t, mockStorage.DeleteReportsForOrg(testdata.OrgID))
+
+	_, err = mockStorage.ReadOrgInfo(testdata.OrgID)
+	assert.Equal(t, &types.ItemNotFoundError{ItemID: testdata.OrgID}, err)
+}
+
+func TestDBStorage_ReadOrgRuleDisables(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	cluster2 := testdata.GetRandomClusterID()
+	for _, cluster := range []types.ClusterName{testdata.ClusterName, cluster2} {
+		err := mockStorage.WriteReportForCluster(
+			testdata.OrgID, cluster, testdata.Report3Rules, testdata.Report3RulesParsed,
+			testdata.LastCheckedAt, testdata.KafkaOffset,
+		)
+		helpers.FailOnError(t, err)
+	}
+
+	disables, err := mockStorage.ReadOrgRuleDisables(testdata.OrgID, 1)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.OrgRuleDisables{MostDisabled: []types.DisabledRuleCount{}}, disables)
+
+	// Rule1 is disabled for both clusters, Rule2 for one of them, enabled
+	// rules are not counted
+	for _, toggle := range []struct {
+		cluster  types.ClusterName
+		ruleID   types.RuleID
+		errorKey types.ErrorKey
+		state    storage.RuleToggle
+	}{
+		{testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, storage.RuleToggleDisable},
+		{cluster2, testdata.Rule1ID, testdata.ErrorKey1, storage.RuleToggleDisable},
+		{testdata.ClusterName, testdata.Rule2ID, testdata.ErrorKey2, storage.RuleToggleDisable},
+		{cluster2, testdata.Rule2ID, testdata.ErrorKey2, storage.RuleToggleEnable},
+	} {
+		err = mockStorage.ToggleRuleForCluster(toggle.cluster, toggle.ruleID, toggle.errorKey, toggle.state)
+		helpers.FailOnError(t, err)
+	}
+
+	disables, err = mockStorage.ReadOrgRuleDisables(testdata.OrgID, 1)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.OrgRuleDisables{
+		ClusterLevel: 3,
+		OrgLevel:     1,
+		MostDisabled: []types.DisabledRuleCount{
+			{RuleID: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1, Clusters: 2},
+		},
+	}, disables)
+
+	// other organizations are not affected
+	disables, err = mockStorage.ReadOrgRuleDisables(testdata.Org2ID, 1)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 0, disables.ClusterLevel)
+}
+
+func TestDBStorage_ReadOrgRuleDisables_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	_, err := mockStorage.ReadOrgRuleDisables(testdata.OrgID, 1)
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
+func TestDBStorage_ReadOrgInfo_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	_, err := mockStorage.ReadOrgInfo(testdata.OrgID)
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
+func TestDBStorage_StaleReportWrites(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	staleWrites, err := mockStorage.ReadStaleReportWrites()
+	helpers.FailOnError(t, err)
+	assert.Empty(t, staleWrites)
+
+	lastChecked := testdata.LastCheckedAt.UTC()
+	producedAt := lastChecked.Add(2 * time.Hour)
+
+	helpers.FailOnError(t, mockStorage.WriteStaleReport(testdata.Org2ID, testdata.ClusterName, lastChecked, time.Time{}))
+	helpers.FailOnError(t, mockStorage.WriteStaleReport(testdata.OrgID, testdata.ClusterName, lastChecked, time.Time{}))
+	helpers.FailOnError(t, mockStorage.WriteStaleReport(testdata.OrgID, testdata.ClusterName, lastChecked, producedAt))
+
+	staleWrites, err = mockStorage.ReadStaleReportWrites()
+	helpers.FailOnError(t, err)
+	assert.Len(t, staleWrites, 2)
+
+	// ordered by organization
+	assert.Equal(t, testdata.OrgID, staleWrites[0].OrgID)
+	assert.Equal(t, testdata.ClusterName, staleWrites[0].ClusterName)
+	assert.Equal(t, 2, staleWrites[0].RejectedCount)
+	assert.Equal(t, types.Timestamp(lastChecked.Format(time.RFC3339)), staleWrites[0].LastCheckedAt)
+	assert.Equal(t, types.Timestamp(producedAt.Format(time.RFC3339)), staleWrites[0].ProducedAt)
+	if assert.NotNil(t, staleWrites[0].TimestampSkewSeconds) {
+		assert.Equal(t, -2*time.Hour.Seconds(), *staleWrites[0].TimestampSkewSeconds)
+	}
+
+	assert.Equal(t, testdata.Org2ID, staleWrites[1].OrgID)
+	assert.Equal(t, 1, staleWrites[1].RejectedCount)
+	assert.Empty(t, staleWrites[1].ProducedAt)
+	assert.Nil(t, staleWrites[1].TimestampSkewSeconds)
+}
+
+func TestDBStorage_StaleReportWrites_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	err := mockStorage.WriteStaleReport(testdata.OrgID, testdata.ClusterName, time.Now(), time.Now())
+	assert.EqualError(t, err, "sql: database is closed")
+
+	_, err = mockStorage.ReadStaleReportWrites()
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
+func TestDBStorage_OrgUsage(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	month := storage.UsageMonth(time.Now())
+
+	usages, err := mockStorage.ReadOrgUsage(month)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, usages)
+
+	helpers.FailOnError(t, mockStorage.AddOrgUsage(testdata.Org2ID, 0, 0, 1))
+	helpers.FailOnError(t, mockStorage.AddOrgUsage(testdata.OrgID, 1, 1024, 0))
+	helpers.FailOnError(t, mockStorage.AddOrgUsage(testdata.OrgID, 1, 2048, 0))
+	helpers.FailOnError(t, mockStorage.AddOrgUsage(testdata.OrgID, 0, 0, 3))
+
+	usages, err = mockStorage.ReadOrgUsage(month)
+	helpers.FailOnError(t, err)
+
+	// ordered by organization
+	assert.Equal(t, []types.OrgUsage{
+		{OrgID: testdata.OrgID, Month: month, MessagesProcessed: 2, BytesStored: 3072, APICalls: 3},
+		{OrgID: testdata.Org2ID, Month: month, MessagesProcessed: 0, BytesStored: 0, APICalls: 1},
+	}, usages)
+
+	usages, err = mockStorage.ReadOrgUsage("2000-01")
+	helpers.FailOnError(t, err)
+	assert.Empty(t, usages)
+}
+
+func TestDBStorage_OrgUsage_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	err := mockStorage.AddOrgUsage(testdata.OrgID, 1, 1, 1)
+	assert.EqualError(t, err, "sql: database is closed")
+
+	_, err = mockStorage.ReadOrgUsage(storage.UsageMonth(time.Now()))
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
+func TestDBStorage_ReadReportStatusForCluster_NoReport(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	status, err := mockStorage.ReadReportStatusForCluster(testdata.OrgID, testdata.ClusterName)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+	assert.Equal(t, types.ReportStatusNoData, status)
+}
+
+func TestDBStorage_WriteFailedReportForCluster_KeepsRuleHits(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed,
+		testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	status, err := mockStorage.ReadReportStatusForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.ReportStatusAnalyzed, status)
+
+	failedAt := testdata.LastCheckedAt.Add(time.Hour)
+	err = mockStorage.WriteFailedReportForCluster(testdata.OrgID, testdata.ClusterName, failedAt, testdata.KafkaOffset+1)
+	helpers.FailOnError(t, err)
+
+	status, err = mockStorage.ReadReportStatusForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.ReportStatusFailed, status)
+
+	rules, lastChecked, err := mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Len(t, rules, len(testdata.Report3RulesParsed))
+	assert.Equal(t, types.Timestamp(failedAt.UTC().Format(time.RFC3339)), lastChecked)
+
+	// the next successfully analyzed report replaces the status
+	err = mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report0Rules, testdata.ReportEmptyRulesParsed,
+		failedAt.Add(time.Hour), testdata.KafkaOffset+2,
+	)
+	helpers.FailOnError(t, err)
+
+	status, err = mockStorage.ReadReportStatusForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.ReportStatusAnalyzed, status)
+}
+
+func TestDBStorage_WriteFailedReportForCluster_NewCluster(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteFailedReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	status, err := mockStorage.ReadReportStatusForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.ReportStatusFailed, status)
+
+	rules, _, err := mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, rules)
+}
+
+func TestDBStorage_WriteFailedReportForCluster_OldReport(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed,
+		testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	err = mockStorage.WriteFailedReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.LastCheckedAt.Add(-time.Hour), testdata.KafkaOffset,
+	)
+	assert.Equal(t, types.ErrOldReport, err)
+
+	status, err := mockStorage.ReadReportStatusForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.ReportStatusAnalyzed, status)
+}
+
+func TestDBStorage_FreezeOrg(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	frozen, err := mockStorage.IsOrgFrozen(testdata.OrgID)
+	helpers.FailOnError(t, err)
+	assert.False(t, frozen)
+
+	helpers.FailOnError(t, mockStorage.FreezeOrg(testdata.OrgID, "legal hold"))
+	// freezing again just updates the reason
+	helpers.FailOnError(t, mockStorage.FreezeOrg(testdata.OrgID, "abuse investigation"))
+
+	frozen, err = mockStorage.IsOrgFrozen(testdata.OrgID)
+	helpers.FailOnError(t, err)
+	assert.True(t, frozen)
+
+	frozenOrgs, err := mockStorage.ReadFrozenOrgs()
+	helpers.FailOnError(t, err)
+	assert.Len(t, frozenOrgs, 1)
+	assert.Equal(t, testdata.OrgID, frozenOrgs[0].OrgID)
+	assert.Equal(t, "abuse investigation", frozenOrgs[0].Reason)
+	assert.NotEmpty(t, frozenOrgs[0].FrozenAt)
+
+	helpers.FailOnError(t, mockStorage.UnfreezeOrg(testdata.OrgID))
+
+	frozen, err = mockStorage.IsOrgFrozen(testdata.OrgID)
+	helpers.FailOnError(t, err)
+	assert.False(t, frozen)
+
+	err = mockStorage.UnfreezeOrg(testdata.OrgID)
+	assert.Equal(t, &types.ItemNotFoundError{ItemID: testdata.OrgID}, err)
+}
+
+func TestDBStorage_APIKeys(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	helpers.FailOnError(t, mockStorage.CreateAPIKey("first", "notification service", []string{"read"}, "hash1", time.Time{}))
+	helpers.FailOnError(t, mockStorage.CreateAPIKey("second", "remediation service", []string{"read", "write"}, "hash2", expiresAt))
+
+	key, keyHash, err := mockStorage.ReadAPIKey("second")
+	helpers.FailOnError(t, err)
+	assert.Equal(t, "hash2", keyHash)
+	assert.Equal(t, "remediation service", key.Name)
+	assert.Equal(t, []string{"read", "write"}, key.Scopes)
+	assert.Equal(t, types.Timestamp(expiresAt.Format(time.RFC3339)), key.ExpiresAt)
+	assert.NotEmpty(t, key.CreatedAt)
+	assert.Empty(t, key.RotatedAt)
+
+	helpers.FailOnError(t, mockStorage.RotateAPIKey("first", "hash3"))
+
+	key, keyHash, err = mockStorage.ReadAPIKey("first")
+	helpers.FailOnError(t, err)
+	assert.Equal(t, "hash3", keyHash)
+	assert.Empty(t, key.ExpiresAt)
+	assert.NotEmpty(t, key.RotatedAt)
+
+	helpers.FailOnError(t, mockStorage.RevokeAPIKey("first"))
+
+	// revoked key can be neither rotated nor revoked again
+	err = mockStorage.RotateAPIKey("first", "hash4")
+	assert.Equal(t, &types.ItemNotFoundError{ItemID: "first"}, err)
+	err = mockStorage.RevokeAPIKey("first")
+	assert.Equal(t, &types.ItemNotFoundError{ItemID: "first"}, err)
+
+	keys, err := mockStorage.ReadAPIKeys()
+	helpers.FailOnError(t, err)
+	if assert.Len(t, keys, 2) {
+		assert.Equal(t, "first", keys[0].KeyID)
+		assert.NotEmpty(t, keys[0].RevokedAt)
+		assert.Equal(t, "second", keys[1].KeyID)
+		assert.Empty(t, keys[1].RevokedAt)
+	}
+
+	_, _, err = mockStorage.ReadAPIKey("unknown")
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
+
+func TestDBStorage_APIKeysPreviousSchema(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+
+	err := migration.SetDBVersion(dbStorage.GetConnection(), dbStorage.GetDBDriverType(), 29)
+	helpers.FailOnError(t, err)
+
+	_, err = dbStorage.DetectSchemaVersion()
+	helpers.FailOnError(t, err)
+
+	err = mockStorage.CreateAPIKey("first", "notification service", []string{"read"}, "hash", time.Time{})
+	assert.EqualError(t, err, "API keys are not supported before DB migration 30")
+
+	_, _, err = mockStorage.ReadAPIKey("first")
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+	keys, err := mockStorage.ReadAPIKeys()
+	helpers.FailOnError(t, err)
+	assert.Empty(t, keys)
+}
+
+func TestDBStorage_APIKeysDBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	err := mockStorage.CreateAPIKey("first", "notification service", []string{"read"}, "hash", time.Time{})
+	assert.EqualError(t, err, "sql: database is closed")
+
+	_, err = mockStorage.ReadAPIKeys()
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
+func TestDBStorage_AcquireLease(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+
+	acquired, err := dbStorage.AcquireLease("scheduler", "first", time.Hour)
+	helpers.FailOnError(t, err)
+	assert.True(t, acquired)
+
+	// the holder renews the lease
+	acquired, err = dbStorage.AcquireLease("scheduler", "first", time.Hour)
+	helpers.FailOnError(t, err)
+	assert.True(t, acquired)
+
+	// the lease is held by another holder
+	acquired, err = dbStorage.AcquireLease("scheduler", "second", time.Hour)
+	helpers.FailOnError(t, err)
+	assert.False(t, acquired)
+
+	// other leases are independent
+	acquired, err = dbStorage.AcquireLease("other", "second", time.Hour)
+	helpers.FailOnError(t, err)
+	assert.True(t, acquired)
+
+	// releasing the lease held by another holder does nothing
+	helpers.FailOnError(t, dbStorage.ReleaseLease("scheduler", "second"))
+
+	acquired, err = dbStorage.AcquireLease("scheduler", "second", time.Hour)
+	helpers.FailOnError(t, err)
+	assert.False(t, acquired)
+
+	helpers.FailOnError(t, dbStorage.ReleaseLease("scheduler", "first"))
+
+	acquired, err = dbStorage.AcquireLease("scheduler", "second", time.Hour)
+	helpers.FailOnError(t, err)
+	assert.True(t, acquired)
+}
+
+func TestDBStorage_AcquireLease_Expired(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+
+	acquired, err := dbStorage.AcquireLease("scheduler", "first", -time.Minute)
+	helpers.FailOnError(t, err)
+	assert.True(t, acquired)
+
+	// the first holder stopped renewing the lease
+	acquired, err = dbStorage.AcquireLease("scheduler", "second", time.Hour)
+	helpers.FailOnError(t, err)
+	assert.True(t, acquired)
+
+	acquired, err = dbStorage.AcquireLease("scheduler", "first", time.Hour)
+	helpers.FailOnError(t, err)
+	assert.False(t, acquired)
+}
+
+func TestDBStorage_AcquireLease_DBError(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+
+	_, err := dbStorage.AcquireLease("scheduler", "first", time.Hour)
+	assert.EqualError(t, err, "sql: database is closed")
+
+	err = dbStorage.ReleaseLease("scheduler", "first")
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
+// mustWriteReportWithNullTimestamps writes report of the cluster without the
+// time it was reported and checked, like the rows written by old versions of
+// the service
+func mustWriteReportWithNullTimestamps(t *testing.T, mockStorage storage.Storage, orgID types.OrgID, clusterName types.ClusterName) {
+	_, err := mockStorage.(*storage.DBStorage).GetConnection().Exec(`
+		INSERT INTO report (org_id, cluster, report, reported_at, last_checked_at, kafka_offset)
+		VALUES ($1, $2, $3, NULL, NULL, $4)
+	`, orgID, clusterName, testdata.ClusterReportEmpty, testdata.KafkaOffset)
+	helpers.FailOnError(t, err)
+}
+
+func TestDBStorage_ReadReportForCluster_NullLastChecked(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReportWithNullTimestamps(t, mockStorage, testdata.OrgID, testdata.ClusterName)
+
+	report, lastChecked, err := mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, report)
+	assert.Equal(t, types.Timestamp(""), lastChecked)
+
+	report, lastChecked, err = mockStorage.ReadReportForClusterByClusterName(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, report)
+	assert.Equal(t, types.Timestamp(""), lastChecked)
+}
+
+func TestDBStorage_RebuildClustersLastCheckedCache_NullLastChecked(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReport3Rules(t, mockStorage)
+	mustWriteReportWithNullTimestamps(t, mockStorage, testdata.OrgID, "ee7d2bf4-8933-4a3a-8634-3328fe806e08")
+
+	size, err := mockStorage.RebuildClustersLastCheckedCache()
+	helpers.FailOnError(t, err)
+
+	// the cluster without the time of the last check is not cached
+	assert.Equal(t, 1, size)
+
+	// and the report of it is accepted
+	err = mockStorage.WriteReportForCluster(
+		testdata.OrgID, "ee7d2bf4-8933-4a3a-8634-3328fe806e08",
+		testdata.ClusterReportEmpty, testdata.ReportEmptyRulesParsed,
+		testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+}
+
+func TestDBStorageListOfOrgsWithSummary_NullLastChecked(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReportWithNullTimestamps(t, mockStorage, 1, "1deb586c-fb85-4db4-ae5b-139cdbdf77ae")
+	mustWriteReportWithNullTimestamps(t, mockStorage, 1, "a1bf5b15-5229-4042-9825-c69dc36b57f5")
+
+	err := mockStorage.WriteReportForCluster(
+		3, testdata.ClusterName, testdata.ClusterReportEmpty, testdata.ReportEmptyRulesParsed,
+		time.Date(2020, 8, 3, 10, 0, 0, 0, time.UTC), testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	orgs, err := mockStorage.ListOfOrgsWithSummary()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []types.OrgSummary{
+		{OrgID: 1, ClusterCount: 2, LastCheckedAt: ""},
+		{OrgID: 3, ClusterCount: 1, LastCheckedAt: "2020-08-03T10:00:00Z"},
+	}, orgs)
+}
+
+func TestDBStorage_IterateReports_NullTimestamps(t *testing.T) {
+	t.Parallel()
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReportWithNullTimestamps(t, mockStorage, testdata.OrgID, testdata.ClusterName)
+
+	var records []storage.ReportRecord
+	err := mockStorage.IterateReports(func(record storage.ReportRecord) error {
+		records = append(records, record)
+		return nil
+	})
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, records, 1)
+	assert.False(t, records[0].ReportedAt.Valid)
+	assert.False(t, records[0].LastCheckedAt.Valid)
+	assert.Equal(t, testdata.KafkaOffset, records[0].KafkaOffset)
+}
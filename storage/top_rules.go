@@ -0,0 +1,101 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// topRulesQuery counts clusters affected by every rule in the time window
+// [$2, $1) and in the previous window [$4, $3) of the same length from
+// occurrences of rule hits. The cluster is affected when an occurrence of the
+// rule overlaps the window. The first placeholder is replaced by the
+// condition selecting the organization or by nothing, the second one by the
+// placeholder of the limit. Every placeholder is used just once and in
+// ascending order, because SQLite binds the arguments by the order of
+// placeholders.
+const topRulesQuery = `
+	SELECT rule_fqdn, error_key, clusters, previous_clusters FROM (
+		SELECT rule_fqdn, error_key,
+			COUNT(DISTINCT CASE
+				WHEN appeared_at < $1 AND (disappeared_at IS NULL OR disappeared_at > $2) THEN cluster_id
+			END) AS clusters,
+			COUNT(DISTINCT CASE
+				WHEN appeared_at < $3 AND (disappeared_at IS NULL OR disappeared_at > $4) THEN cluster_id
+			END) AS previous_clusters
+		FROM rule_hit_history
+		WHERE appeared_at < $5 AND (disappeared_at IS NULL OR disappeared_at > $6) %s
+		GROUP BY rule_fqdn, error_key
+	) counts
+	WHERE clusters > 0
+	ORDER BY clusters DESC, rule_fqdn, error_key
+	LIMIT %s;
+`
+
+// ReadTopRules returns at most limit rules affecting the most clusters of
+// all organizations in the time window [from, to). Number of affected
+// clusters in the previous window of the same length is returned too.
+func (storage DBStorage) ReadTopRules(from, to time.Time, limit int) ([]types.TopRule, error) {
+	return storage.readTopRules(fmt.Sprintf(topRulesQuery, "", "$7"), from, to, limit)
+}
+
+// ReadTopRulesForOrg returns at most limit rules affecting the most clusters
+// of the organization in the time window [from, to)
+func (storage DBStorage) ReadTopRulesForOrg(
+	orgID types.OrgID, from, to time.Time, limit int,
+) ([]types.TopRule, error) {
+	return storage.readTopRules(fmt.Sprintf(topRulesQuery, "AND org_id = $7", "$8"), from, to, limit, orgID)
+}
+
+// readTopRules reads the top rules using the given query, the arguments
+// are placed before the limit
+func (storage DBStorage) readTopRules(
+	query string, from, to time.Time, limit int, args ...interface{},
+) ([]types.TopRule, error) {
+	ctx, cancel := storage.operationContext(aggregationOperation)
+	defer cancel()
+
+	rules := make([]types.TopRule, 0)
+
+	previousFrom := from.Add(-to.Sub(from))
+	args = append([]interface{}{to, from, from, previousFrom, to, previousFrom}, args...)
+	args = append(args, limit)
+
+	rows, err := storage.readConnection().QueryContext(ctx, query, args...)
+	if err != nil {
+		return rules, types.ConvertDBError(err, nil)
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var rule types.TopRule
+
+		err = rows.Scan(&rule.RuleID, &rule.ErrorKey, &rule.Clusters, &rule.PreviousClusters)
+		if err != nil {
+			log.Error().Err(err).Msg("ReadTopRules")
+			return rules, types.ConvertDBError(err, nil)
+		}
+
+		rule.Delta = rule.Clusters - rule.PreviousClusters
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
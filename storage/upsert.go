@@ -0,0 +1,123 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// upsertQuery describes INSERT statement that updates the existing record
+// when it conflicts with the inserted one. Values are always passed in the
+// order of columns, updated columns are set to the inserted values
+// (EXCLUDED), so placeholders can't be mixed up.
+type upsertQuery struct {
+	table           string
+	columns         []string
+	conflictColumns []string
+	updateColumns   []string
+	// returning contains columns returned by PostgreSQL so the caller can
+	// check what was actually stored. The SQLite version bundled with the
+	// driver doesn't support RETURNING, number of affected rows is checked
+	// instead.
+	returning []string
+}
+
+// sql returns the statement for the DB driver
+func (query upsertQuery) sql(dbDriver types.DBDriver) string {
+	placeholders := make([]string, len(query.columns))
+	for i := range query.columns {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+
+	updates := make([]string, len(query.updateColumns))
+	for i, column := range query.updateColumns {
+		updates[i] = fmt.Sprintf("%s = excluded.%s", column, column)
+	}
+
+	statement := fmt.Sprintf(
+		"INSERT INTO %s(%s) VALUES (%s) ON CONFLICT (%s) DO UPDATE SET %s",
+		query.table,
+		strings.Join(query.columns, ", "),
+		strings.Join(placeholders, ", "),
+		strings.Join(query.conflictColumns, ", "),
+		strings.Join(updates, ", "),
+	)
+
+	if capabilitiesOfDriver(dbDriver).Upserts && len(query.returning) > 0 {
+		statement += " RETURNING " + strings.Join(query.returning, ", ")
+	}
+
+	return statement + ";"
+}
+
+// exec executes the upsert in the transaction and checks that exactly one
+// record was written. Values of returning columns are scanned into dest on
+// PostgreSQL, dest is left untouched on other DBs.
+func (query upsertQuery) exec(
+	tx *sql.Tx, dbDriver types.DBDriver, args []interface{}, dest ...interface{},
+) error {
+	if capabilitiesOfDriver(dbDriver).Upserts && len(query.returning) > 0 {
+		return tx.QueryRow(query.sql(dbDriver), args...).Scan(dest...)
+	}
+
+	result, err := tx.Exec(query.sql(dbDriver), args...)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected != 1 {
+		return fmt.Errorf("upsert into %s affected %d rows instead of 1", query.table, affected)
+	}
+
+	return nil
+}
+
+// reportUpsert writes the report of the cluster, the cluster can move to
+// another organization
+var reportUpsert = upsertQuery{
+	table:           "report",
+	columns:         []string{"org_id", "cluster", "report", "reported_at", "last_checked_at", "kafka_offset", "status"},
+	conflictColumns: []string{"cluster"},
+	updateColumns:   []string{"org_id", "report", "reported_at", "last_checked_at", "kafka_offset", "status"},
+}
+
+// ruleHitUpsert writes one rule hit of the cluster and returns the stored
+// template data
+var ruleHitUpsert = upsertQuery{
+	table:           "rule_hit",
+	columns:         []string{"org_id", "cluster_id", "rule_fqdn", "error_key", "template_data", "first_seen_at"},
+	conflictColumns: []string{"org_id", "cluster_id", "rule_fqdn", "error_key"},
+	updateColumns:   []string{"template_data"},
+	returning:       []string{"template_data"},
+}
+
+// legacyRuleHitUpsert writes one rule hit of the cluster into rule_hit table
+// without first_seen_at column, it is used until the database is migrated
+var legacyRuleHitUpsert = upsertQuery{
+	table:           "rule_hit",
+	columns:         []string{"org_id", "cluster_id", "rule_fqdn", "error_key", "template_data"},
+	conflictColumns: []string{"org_id", "cluster_id", "rule_fqdn", "error_key"},
+	updateColumns:   []string{"template_data"},
+	returning:       []string{"template_data"},
+}
@@ -0,0 +1,110 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// TestUpsertSQL checks that updated columns are set to the inserted values
+// and that only PostgreSQL returns the stored values
+func TestUpsertSQL(t *testing.T) {
+	assert.Equal(t,
+		"INSERT INTO rule_hit(org_id, cluster_id, rule_fqdn, error_key, template_data, first_seen_at) "+
+			"VALUES ($1, $2, $3, $4, $5, $6) "+
+			"ON CONFLICT (org_id, cluster_id, rule_fqdn, error_key) "+
+			"DO UPDATE SET template_data = excluded.template_data RETURNING template_data;",
+		storage.RuleHitUpsertSQL(types.DBDriverPostgres),
+	)
+	assert.Equal(t,
+		"INSERT INTO rule_hit(org_id, cluster_id, rule_fqdn, error_key, template_data, first_seen_at) "+
+			"VALUES ($1, $2, $3, $4, $5, $6) "+
+			"ON CONFLICT (org_id, cluster_id, rule_fqdn, error_key) "+
+			"DO UPDATE SET template_data = excluded.template_data;",
+		storage.RuleHitUpsertSQL(types.DBDriverSQLite3),
+	)
+	assert.Equal(t,
+		"INSERT INTO report(org_id, cluster, report, reported_at, last_checked_at, kafka_offset, status) "+
+			"VALUES ($1, $2, $3, $4, $5, $6, $7) "+
+			"ON CONFLICT (cluster) "+
+			"DO UPDATE SET org_id = excluded.org_id, report = excluded.report, reported_at = excluded.reported_at, "+
+			"last_checked_at = excluded.last_checked_at, kafka_offset = excluded.kafka_offset, status = excluded.status;",
+		storage.ReportUpsertSQL(types.DBDriverPostgres),
+	)
+}
+
+// TestDBStorage_UpsertRuleHitUpdatesTemplateData checks that the upsert of an
+// existing rule hit updates its template data
+func TestDBStorage_UpsertRuleHitUpdatesTemplateData(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReport3Rules(t, mockStorage)
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+	rule := testdata.Report3RulesParsed[0]
+	rule.TemplateData = json.RawMessage(`{"refreshed":true}`)
+
+	tx, err := storage.GetConnection(dbStorage).Begin()
+	helpers.FailOnError(t, err)
+
+	err = storage.UpsertRuleHit(dbStorage, tx, testdata.OrgID, testdata.ClusterName, rule, testdata.LastCheckedAt)
+	if err != nil {
+		_ = tx.Rollback()
+		t.Fatal(err)
+	}
+	helpers.FailOnError(t, tx.Commit())
+
+	templateData, err := mockStorage.ReadSingleRuleTemplateData(
+		testdata.OrgID, testdata.ClusterName, rule.Module, rule.ErrorKey,
+	)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, json.RawMessage(`{"refreshed":true}`), templateData)
+}
+
+// TestDBStorage_WriteReportForClusterRefreshesTemplateData checks that
+// re-sent report of the cluster refreshes template data of its rule hits
+func TestDBStorage_WriteReportForClusterRefreshesTemplateData(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mustWriteReport3Rules(t, mockStorage)
+
+	rules := make([]types.ReportItem, len(testdata.Report3RulesParsed))
+	copy(rules, testdata.Report3RulesParsed)
+	rules[1].TemplateData = json.RawMessage(`{"refreshed":true}`)
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, rules,
+		testdata.LastCheckedAt.Add(time.Hour), testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	templateData, err := mockStorage.ReadSingleRuleTemplateData(
+		testdata.OrgID, testdata.ClusterName, rules[1].Module, rules[1].ErrorKey,
+	)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, json.RawMessage(`{"refreshed":true}`), templateData)
+}
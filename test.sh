@@ -120,6 +120,7 @@ function start_service() {
     # TODO: stop parent(this script) if service died
     INSIGHTS_RESULTS_AGGREGATOR__LOGGING__LOG_LEVEL=$LOG_LEVEL \
         INSIGHTS_RESULTS_AGGREGATOR_CONFIG_FILE=./tests/tests \
+        INSIGHTS_RESULTS_AGGREGATOR_ENABLE_DEBUG_ENDPOINTS=true \
         ./insights-results-aggregator ||
         echo -e "${COLORS_RED}service exited with error${COLORS_RESET}" &
     # shellcheck disable=2181
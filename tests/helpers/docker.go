@@ -0,0 +1,184 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helpers
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+)
+
+const (
+	// testsDBDockerEnvVariable enables running DB containers for tests
+	testsDBDockerEnvVariable = "INSIGHTS_RESULTS_AGGREGATOR__TESTS_DB_DOCKER"
+	// dockerContainerLifetime is the time after which docker kills the
+	// container even if the tests didn't purge it (crashed, timed out)
+	dockerContainerLifetime = 30 * time.Minute
+	// dockerStartTimeout is the maximal time to wait for DB in the container
+	dockerStartTimeout = 2 * time.Minute
+)
+
+// dockerDatabaseSpec describes the container with DB for one driver
+type dockerDatabaseSpec struct {
+	repository    string
+	tag           string
+	env           []string
+	port          string
+	adminUser     string
+	adminPassword string
+	// dataSource returns data source used to check the DB is ready
+	dataSource func(host string, port int) string
+}
+
+// DockerDatabase is a DB running in the container
+type DockerDatabase struct {
+	Host          string
+	Port          int
+	AdminUser     string
+	AdminPassword string
+	resource      *dockertest.Resource
+}
+
+// dockerDatabaseSpecs contains DB containers for all drivers the tests can
+// run against
+var dockerDatabaseSpecs = map[string]dockerDatabaseSpec{
+	postgres: {
+		repository:    "postgres",
+		tag:           "12",
+		env:           []string{"POSTGRES_PASSWORD=admin"},
+		port:          "5432/tcp",
+		adminUser:     "postgres",
+		adminPassword: "admin",
+		dataSource: func(host string, port int) string {
+			return fmt.Sprintf(
+				"host=%s port=%d user=postgres password=admin sslmode=disable", host, port,
+			)
+		},
+	},
+}
+
+var (
+	dockerPool      *dockertest.Pool
+	dockerDatabases = map[string]*DockerDatabase{}
+	dockerMutex     sync.Mutex
+)
+
+// UseDockerDatabase returns true when tests should run against DB started
+// in docker container, that is when env variable
+// INSIGHTS_RESULTS_AGGREGATOR__TESTS_DB_DOCKER is set to true
+func UseDockerDatabase() bool {
+	useDocker, err := strconv.ParseBool(os.Getenv(testsDBDockerEnvVariable))
+	return err == nil && useDocker
+}
+
+// MustGetDockerDatabase returns DB for the specified driver running in docker
+// container. The container is started on the first call and then shared by
+// all tests in the package, so every test should use its own database in it.
+// Call PurgeDockerDatabases from TestMain to remove the containers, otherwise
+// they are removed by docker after dockerContainerLifetime.
+func MustGetDockerDatabase(tb testing.TB, driver string) *DockerDatabase {
+	dockerMutex.Lock()
+	defer dockerMutex.Unlock()
+
+	if database, found := dockerDatabases[driver]; found {
+		return database
+	}
+
+	spec, found := dockerDatabaseSpecs[driver]
+	if !found {
+		tb.Fatalf("no docker container for DB driver %s", driver)
+	}
+
+	if dockerPool == nil {
+		pool, err := dockertest.NewPool("")
+		helpers.FailOnError(tb, err)
+
+		pool.MaxWait = dockerStartTimeout
+		dockerPool = pool
+	}
+
+	resource, err := dockerPool.RunWithOptions(&dockertest.RunOptions{
+		Repository: spec.repository,
+		Tag:        spec.tag,
+		Env:        spec.env,
+	}, func(config *docker.HostConfig) {
+		config.AutoRemove = true
+		config.RestartPolicy = docker.RestartPolicy{Name: "no"}
+	})
+	helpers.FailOnError(tb, err)
+
+	helpers.FailOnError(tb, resource.Expire(uint(dockerContainerLifetime.Seconds())))
+
+	host := resource.GetBoundIP(spec.port)
+	if host == "" || host == "0.0.0.0" {
+		host = "localhost"
+	}
+	port, err := strconv.Atoi(resource.GetPort(spec.port))
+	helpers.FailOnError(tb, err)
+
+	// the DB in the container needs some time to start accepting connections
+	err = dockerPool.Retry(func() error {
+		db, err := sql.Open(driver, spec.dataSource(host, port))
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = db.Close()
+		}()
+
+		return db.Ping()
+	})
+	if err != nil {
+		_ = dockerPool.Purge(resource)
+		helpers.FailOnError(tb, err)
+	}
+
+	database := &DockerDatabase{
+		Host:          host,
+		Port:          port,
+		AdminUser:     spec.adminUser,
+		AdminPassword: spec.adminPassword,
+		resource:      resource,
+	}
+	dockerDatabases[driver] = database
+
+	return database
+}
+
+// PurgeDockerDatabases stops and removes all containers started by
+// MustGetDockerDatabase
+func PurgeDockerDatabases() error {
+	dockerMutex.Lock()
+	defer dockerMutex.Unlock()
+
+	var firstErr error
+
+	for driver, database := range dockerDatabases {
+		if err := dockerPool.Purge(database.resource); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(dockerDatabases, driver)
+	}
+
+	return firstErr
+}
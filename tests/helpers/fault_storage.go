@@ -0,0 +1,1046 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helpers
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// Fault describes a failure injected into a storage method
+type Fault struct {
+	// Err is returned by the method instead of calling the wrapped storage.
+	// When it is nil, only the latency is injected.
+	Err error
+	// AfterCalls is number of calls of the method that pass before the fault
+	// is injected for the first time
+	AfterCalls int
+	// Times limits how many times the fault is injected, 0 means always
+	Times int
+	// Latency delays every affected call of the method
+	Latency time.Duration
+}
+
+// methodFault is the fault configured for one method together with the
+// number of times it was already injected
+type methodFault struct {
+	fault    Fault
+	injected int
+}
+
+// FaultInjectingStorage wraps another storage and allows tests to configure
+// failures of its methods (error returned, after N calls, latency), so the
+// timeout and partial-failure paths of the callers can be tested. Methods
+// without configured fault just call the wrapped storage.
+type FaultInjectingStorage struct {
+	storage.Storage
+	mutex  sync.Mutex
+	faults map[string]*methodFault
+	calls  map[string]int
+}
+
+// NewFaultInjectingStorage wraps the storage, no fault is configured
+func NewFaultInjectingStorage(wrapped storage.Storage) *FaultInjectingStorage {
+	return &FaultInjectingStorage{
+		Storage: wrapped,
+		faults:  make(map[string]*methodFault),
+		calls:   make(map[string]int),
+	}
+}
+
+// MustGetMockStorageWithFaults creates mocked storage (see MustGetMockStorage)
+// wrapped by FaultInjectingStorage
+func MustGetMockStorageWithFaults(tb testing.TB, init bool) (*FaultInjectingStorage, func()) {
+	mockStorage, closer := MustGetMockStorage(tb, init)
+
+	return NewFaultInjectingStorage(mockStorage), closer
+}
+
+// InjectFault configures the fault of the method. It replaces the fault
+// configured previously and resets the number of calls of the method. It
+// panics when there is no such method in storage.Storage interface.
+func (s *FaultInjectingStorage) InjectFault(method string, fault Fault) {
+	storageInterface := reflect.TypeOf((*storage.Storage)(nil)).Elem()
+	if _, found := storageInterface.MethodByName(method); !found {
+		panic(fmt.Sprintf("storage.Storage has no method %s", method))
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.faults[method] = &methodFault{fault: fault}
+	s.calls[method] = 0
+}
+
+// ClearFaults removes all configured faults
+func (s *FaultInjectingStorage) ClearFaults() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.faults = make(map[string]*methodFault)
+}
+
+// Calls returns how many times the method was called, including the calls
+// that failed due to the injected fault
+func (s *FaultInjectingStorage) Calls(method string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.calls[method]
+}
+
+// inject counts the call of the method, waits for configured latency and
+// returns the error that should be returned by the method (if any)
+func (s *FaultInjectingStorage) inject(method string) error {
+	s.mutex.Lock()
+
+	s.calls[method]++
+	calls := s.calls[method]
+
+	configured, found := s.faults[method]
+	if !found || calls <= configured.fault.AfterCalls ||
+		(configured.fault.Times > 0 && configured.injected >= configured.fault.Times) {
+		s.mutex.Unlock()
+		return nil
+	}
+
+	configured.injected++
+	fault := configured.fault
+
+	s.mutex.Unlock()
+
+	// the lock is not held while waiting, so other calls are not delayed
+	time.Sleep(fault.Latency)
+
+	return fault.Err
+}
+
+// Init with fault injection
+func (s *FaultInjectingStorage) Init() error {
+	if err := s.inject("Init"); err != nil {
+		return err
+	}
+
+	return s.Storage.Init()
+}
+
+// Close with fault injection
+func (s *FaultInjectingStorage) Close() error {
+	if err := s.inject("Close"); err != nil {
+		return err
+	}
+
+	return s.Storage.Close()
+}
+
+// ListOfOrgs with fault injection
+func (s *FaultInjectingStorage) ListOfOrgs() ([]types.OrgID, error) {
+	if err := s.inject("ListOfOrgs"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.ListOfOrgs()
+}
+
+// ListOfClustersForOrg with fault injection
+func (s *FaultInjectingStorage) ListOfClustersForOrg(
+	orgID types.OrgID, timeLimit time.Time, offset, limit int,
+) ([]types.ClusterName, error) {
+	if err := s.inject("ListOfClustersForOrg"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.ListOfClustersForOrg(orgID, timeLimit, offset, limit)
+}
+
+// ReadReportForCluster with fault injection
+func (s *FaultInjectingStorage) ReadReportForCluster(orgID types.OrgID, clusterName types.ClusterName) ([]types.RuleOnReport, types.Timestamp, error) {
+	if err := s.inject("ReadReportForCluster"); err != nil {
+		return nil, "", err
+	}
+
+	return s.Storage.ReadReportForCluster(orgID, clusterName)
+}
+
+// ReadReportsForClusters with fault injection
+func (s *FaultInjectingStorage) ReadReportsForClusters(clusterNames []types.ClusterName) (map[types.ClusterName]types.ClusterReport, error) {
+	if err := s.inject("ReadReportsForClusters"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.ReadReportsForClusters(clusterNames)
+}
+
+// ReadOrgIDsForClusters with fault injection
+func (s *FaultInjectingStorage) ReadOrgIDsForClusters(clusterNames []types.ClusterName) ([]types.OrgID, error) {
+	if err := s.inject("ReadOrgIDsForClusters"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.ReadOrgIDsForClusters(clusterNames)
+}
+
+// ReadSingleRuleTemplateData with fault injection
+func (s *FaultInjectingStorage) ReadSingleRuleTemplateData(orgID types.OrgID, clusterName types.ClusterName, ruleID types.RuleID, errorKey types.ErrorKey) (interface{}, error) {
+	if err := s.inject("ReadSingleRuleTemplateData"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.ReadSingleRuleTemplateData(orgID, clusterName, ruleID, errorKey)
+}
+
+// ReadReportForClusterByClusterName with fault injection
+func (s *FaultInjectingStorage) ReadReportForClusterByClusterName(clusterName types.ClusterName) ([]types.RuleOnReport, types.Timestamp, error) {
+	if err := s.inject("ReadReportForClusterByClusterName"); err != nil {
+		return nil, "", err
+	}
+
+	return s.Storage.ReadReportForClusterByClusterName(clusterName)
+}
+
+// GetLatestKafkaOffset with fault injection
+func (s *FaultInjectingStorage) GetLatestKafkaOffset(topic string, partition int32) (types.KafkaOffset, error) {
+	if err := s.inject("GetLatestKafkaOffset"); err != nil {
+		return 0, err
+	}
+
+	return s.Storage.GetLatestKafkaOffset(topic, partition)
+}
+
+// GetLatestKafkaOffsets with fault injection
+func (s *FaultInjectingStorage) GetLatestKafkaOffsets() ([]types.KafkaPartitionOffset, error) {
+	if err := s.inject("GetLatestKafkaOffsets"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.GetLatestKafkaOffsets()
+}
+
+// WriteKafkaOffset with fault injection
+func (s *FaultInjectingStorage) WriteKafkaOffset(topic string, partition int32, offset types.KafkaOffset) error {
+	if err := s.inject("WriteKafkaOffset"); err != nil {
+		return err
+	}
+
+	return s.Storage.WriteKafkaOffset(topic, partition, offset)
+}
+
+// WriteReportForCluster with fault injection
+func (s *FaultInjectingStorage) WriteReportForCluster(orgID types.OrgID, clusterName types.ClusterName, report types.ClusterReport, rules []types.ReportItem, collectedAtTime time.Time, kafkaOffset types.KafkaOffset) error {
+	if err := s.inject("WriteReportForCluster"); err != nil {
+		return err
+	}
+
+	return s.Storage.WriteReportForCluster(orgID, clusterName, report, rules, collectedAtTime, kafkaOffset)
+}
+
+// WriteFailedReportForCluster with fault injection
+func (s *FaultInjectingStorage) WriteFailedReportForCluster(orgID types.OrgID, clusterName types.ClusterName, lastCheckedTime time.Time, kafkaOffset types.KafkaOffset) error {
+	if err := s.inject("WriteFailedReportForCluster"); err != nil {
+		return err
+	}
+
+	return s.Storage.WriteFailedReportForCluster(orgID, clusterName, lastCheckedTime, kafkaOffset)
+}
+
+// ReadReportStatusForCluster with fault injection
+func (s *FaultInjectingStorage) ReadReportStatusForCluster(orgID types.OrgID, clusterName types.ClusterName) (types.ReportStatus, error) {
+	if err := s.inject("ReadReportStatusForCluster"); err != nil {
+		return types.ReportStatusNoData, err
+	}
+
+	return s.Storage.ReadReportStatusForCluster(orgID, clusterName)
+}
+
+// ReportsCount with fault injection
+func (s *FaultInjectingStorage) ReportsCount() (int, error) {
+	if err := s.inject("ReportsCount"); err != nil {
+		return 0, err
+	}
+
+	return s.Storage.ReportsCount()
+}
+
+// VoteOnRule with fault injection
+func (s *FaultInjectingStorage) VoteOnRule(clusterID types.ClusterName, ruleID types.RuleID, errorKey types.ErrorKey, userID types.UserID, userVote types.UserVote, voteMessage string) error {
+	if err := s.inject("VoteOnRule"); err != nil {
+		return err
+	}
+
+	return s.Storage.VoteOnRule(clusterID, ruleID, errorKey, userID, userVote, voteMessage)
+}
+
+// AddOrUpdateFeedbackOnRule with fault injection
+func (s *FaultInjectingStorage) AddOrUpdateFeedbackOnRule(clusterID types.ClusterName, ruleID types.RuleID, errorKey types.ErrorKey, userID types.UserID, message string) error {
+	if err := s.inject("AddOrUpdateFeedbackOnRule"); err != nil {
+		return err
+	}
+
+	return s.Storage.AddOrUpdateFeedbackOnRule(clusterID, ruleID, errorKey, userID, message)
+}
+
+// AddFeedbackOnRuleDisable with fault injection
+func (s *FaultInjectingStorage) AddFeedbackOnRuleDisable(clusterID types.ClusterName, ruleID types.RuleID, errorKey types.ErrorKey, userID types.UserID, message string) error {
+	if err := s.inject("AddFeedbackOnRuleDisable"); err != nil {
+		return err
+	}
+
+	return s.Storage.AddFeedbackOnRuleDisable(clusterID, ruleID, errorKey, userID, message)
+}
+
+// GetUserFeedbackOnRule with fault injection
+func (s *FaultInjectingStorage) GetUserFeedbackOnRule(clusterID types.ClusterName, ruleID types.RuleID, errorKey types.ErrorKey, userID types.UserID) (*storage.UserFeedbackOnRule, error) {
+	if err := s.inject("GetUserFeedbackOnRule"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.GetUserFeedbackOnRule(clusterID, ruleID, errorKey, userID)
+}
+
+// GetUserFeedbackOnRuleDisable with fault injection
+func (s *FaultInjectingStorage) GetUserFeedbackOnRuleDisable(clusterID types.ClusterName, ruleID types.RuleID, userID types.UserID) (*storage.UserFeedbackOnRule, error) {
+	if err := s.inject("GetUserFeedbackOnRuleDisable"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.GetUserFeedbackOnRuleDisable(clusterID, ruleID, userID)
+}
+
+// DeleteReportsForOrg with fault injection
+func (s *FaultInjectingStorage) DeleteReportsForOrg(orgID types.OrgID) error {
+	if err := s.inject("DeleteReportsForOrg"); err != nil {
+		return err
+	}
+
+	return s.Storage.DeleteReportsForOrg(orgID)
+}
+
+// DeleteReportsForCluster with fault injection
+func (s *FaultInjectingStorage) DeleteReportsForCluster(clusterName types.ClusterName) error {
+	if err := s.inject("DeleteReportsForCluster"); err != nil {
+		return err
+	}
+
+	return s.Storage.DeleteReportsForCluster(clusterName)
+}
+
+// DeleteClusterReport with fault injection
+func (s *FaultInjectingStorage) DeleteClusterReport(
+	orgID types.OrgID, clusterName types.ClusterName, deletedAt time.Time,
+) error {
+	if err := s.inject("DeleteClusterReport"); err != nil {
+		return err
+	}
+
+	return s.Storage.DeleteClusterReport(orgID, clusterName, deletedAt)
+}
+
+// ToggleRuleForCluster with fault injection
+func (s *FaultInjectingStorage) ToggleRuleForCluster(clusterID types.ClusterName, ruleID types.RuleID, errorKey types.ErrorKey, ruleToggle storage.RuleToggle) error {
+	if err := s.inject("ToggleRuleForCluster"); err != nil {
+		return err
+	}
+
+	return s.Storage.ToggleRuleForCluster(clusterID, ruleID, errorKey, ruleToggle)
+}
+
+// GetFromClusterRuleToggle with fault injection
+func (s *FaultInjectingStorage) GetFromClusterRuleToggle(p0 types.ClusterName, p1 types.RuleID) (*storage.ClusterRuleToggle, error) {
+	if err := s.inject("GetFromClusterRuleToggle"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.GetFromClusterRuleToggle(p0, p1)
+}
+
+// GetTogglesForRules with fault injection
+func (s *FaultInjectingStorage) GetTogglesForRules(p0 types.ClusterName, p1 []types.RuleOnReport) (map[types.RuleID]bool, error) {
+	if err := s.inject("GetTogglesForRules"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.GetTogglesForRules(p0, p1)
+}
+
+// ReadRuleDisableDetails with fault injection
+func (s *FaultInjectingStorage) ReadRuleDisableDetails(clusterID types.ClusterName) ([]storage.RuleDisableDetails, error) {
+	if err := s.inject("ReadRuleDisableDetails"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.ReadRuleDisableDetails(clusterID)
+}
+
+// ReadRuleHitsFirstSeen with fault injection
+func (s *FaultInjectingStorage) ReadRuleHitsFirstSeen(
+	orgID types.OrgID, clusterName types.ClusterName,
+) ([]storage.RuleHitFirstSeen, error) {
+	if err := s.inject("ReadRuleHitsFirstSeen"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.ReadRuleHitsFirstSeen(orgID, clusterName)
+}
+
+// DeleteFromRuleClusterToggle with fault injection
+func (s *FaultInjectingStorage) DeleteFromRuleClusterToggle(clusterID types.ClusterName, ruleID types.RuleID) error {
+	if err := s.inject("DeleteFromRuleClusterToggle"); err != nil {
+		return err
+	}
+
+	return s.Storage.DeleteFromRuleClusterToggle(clusterID, ruleID)
+}
+
+// GetOrgIDByClusterID with fault injection
+func (s *FaultInjectingStorage) GetOrgIDByClusterID(cluster types.ClusterName) (types.OrgID, error) {
+	if err := s.inject("GetOrgIDByClusterID"); err != nil {
+		return 0, err
+	}
+
+	return s.Storage.GetOrgIDByClusterID(cluster)
+}
+
+// WriteConsumerError with fault injection
+func (s *FaultInjectingStorage) WriteConsumerError(msg *sarama.ConsumerMessage, consumerErr error) error {
+	if err := s.inject("WriteConsumerError"); err != nil {
+		return err
+	}
+
+	return s.Storage.WriteConsumerError(msg, consumerErr)
+}
+
+// GetUserFeedbackOnRules with fault injection
+func (s *FaultInjectingStorage) GetUserFeedbackOnRules(clusterID types.ClusterName, rulesReport []types.RuleOnReport, userID types.UserID) (map[types.RuleID]types.UserVote, error) {
+	if err := s.inject("GetUserFeedbackOnRules"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.GetUserFeedbackOnRules(clusterID, rulesReport, userID)
+}
+
+// GetUserDisableFeedbackOnRules with fault injection
+func (s *FaultInjectingStorage) GetUserDisableFeedbackOnRules(clusterID types.ClusterName, rulesReport []types.RuleOnReport, userID types.UserID) (map[types.RuleID]storage.UserFeedbackOnRule, error) {
+	if err := s.inject("GetUserDisableFeedbackOnRules"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.GetUserDisableFeedbackOnRules(clusterID, rulesReport, userID)
+}
+
+// DoesClusterExist with fault injection
+func (s *FaultInjectingStorage) DoesClusterExist(clusterID types.ClusterName) (bool, error) {
+	if err := s.inject("DoesClusterExist"); err != nil {
+		return false, err
+	}
+
+	return s.Storage.DoesClusterExist(clusterID)
+}
+
+// DoesClusterExistWithOrgID with fault injection
+func (s *FaultInjectingStorage) DoesClusterExistWithOrgID(clusterID types.ClusterName) (bool, types.OrgID, error) {
+	if err := s.inject("DoesClusterExistWithOrgID"); err != nil {
+		return false, 0, err
+	}
+
+	return s.Storage.DoesClusterExistWithOrgID(clusterID)
+}
+
+// ReadOrgIDsOfClusters with fault injection
+func (s *FaultInjectingStorage) ReadOrgIDsOfClusters(
+	clusterNames []types.ClusterName,
+) (map[types.ClusterName]types.OrgID, error) {
+	if err := s.inject("ReadOrgIDsOfClusters"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.ReadOrgIDsOfClusters(clusterNames)
+}
+
+// RebuildClustersLastCheckedCache with fault injection
+func (s *FaultInjectingStorage) RebuildClustersLastCheckedCache() (int, error) {
+	if err := s.inject("RebuildClustersLastCheckedCache"); err != nil {
+		return 0, err
+	}
+
+	return s.Storage.RebuildClustersLastCheckedCache()
+}
+
+// RecomputeAggregates with fault injection
+func (s *FaultInjectingStorage) RecomputeAggregates() (storage.AggregatesRecomputation, error) {
+	if err := s.inject("RecomputeAggregates"); err != nil {
+		return storage.AggregatesRecomputation{}, err
+	}
+
+	return s.Storage.RecomputeAggregates()
+}
+
+// CreateAPIKey with fault injection
+func (s *FaultInjectingStorage) CreateAPIKey(
+	keyID, name string, scopes []string, keyHash string, expiresAt time.Time,
+) error {
+	if err := s.inject("CreateAPIKey"); err != nil {
+		return err
+	}
+
+	return s.Storage.CreateAPIKey(keyID, name, scopes, keyHash, expiresAt)
+}
+
+// RotateAPIKey with fault injection
+func (s *FaultInjectingStorage) RotateAPIKey(keyID, keyHash string) error {
+	if err := s.inject("RotateAPIKey"); err != nil {
+		return err
+	}
+
+	return s.Storage.RotateAPIKey(keyID, keyHash)
+}
+
+// RevokeAPIKey with fault injection
+func (s *FaultInjectingStorage) RevokeAPIKey(keyID string) error {
+	if err := s.inject("RevokeAPIKey"); err != nil {
+		return err
+	}
+
+	return s.Storage.RevokeAPIKey(keyID)
+}
+
+// ReadAPIKey with fault injection
+func (s *FaultInjectingStorage) ReadAPIKey(keyID string) (types.APIKey, string, error) {
+	if err := s.inject("ReadAPIKey"); err != nil {
+		return types.APIKey{}, "", err
+	}
+
+	return s.Storage.ReadAPIKey(keyID)
+}
+
+// ReadAPIKeys with fault injection
+func (s *FaultInjectingStorage) ReadAPIKeys() ([]types.APIKey, error) {
+	if err := s.inject("ReadAPIKeys"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.ReadAPIKeys()
+}
+
+// ReadClusterOrgChanges with fault injection
+func (s *FaultInjectingStorage) ReadClusterOrgChanges(since time.Time) ([]types.ClusterOrgChange, error) {
+	if err := s.inject("ReadClusterOrgChanges"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.ReadClusterOrgChanges(since)
+}
+
+// WriteExternalResults with fault injection
+func (s *FaultInjectingStorage) WriteExternalResults(
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+	source string,
+	results []types.ExternalResult,
+	lastCheckedTime time.Time,
+	kafkaOffset types.KafkaOffset,
+) error {
+	if err := s.inject("WriteExternalResults"); err != nil {
+		return err
+	}
+
+	return s.Storage.WriteExternalResults(orgID, clusterName, source, results, lastCheckedTime, kafkaOffset)
+}
+
+// ReadExternalResults with fault injection
+func (s *FaultInjectingStorage) ReadExternalResults(
+	clusterName types.ClusterName, source string,
+) ([]types.ExternalResult, error) {
+	if err := s.inject("ReadExternalResults"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.ReadExternalResults(clusterName, source)
+}
+
+// ReadDBSchema with fault injection
+func (s *FaultInjectingStorage) ReadDBSchema() (types.DBSchema, error) {
+	if err := s.inject("ReadDBSchema"); err != nil {
+		return types.DBSchema{}, err
+	}
+
+	return s.Storage.ReadDBSchema()
+}
+
+// ArchiveReportsNotCheckedSince with fault injection
+func (s *FaultInjectingStorage) ArchiveReportsNotCheckedSince(threshold time.Time, limit int) (int, error) {
+	if err := s.inject("ArchiveReportsNotCheckedSince"); err != nil {
+		return 0, err
+	}
+
+	return s.Storage.ArchiveReportsNotCheckedSince(threshold, limit)
+}
+
+// IterateRuleHitsForRule with fault injection
+func (s *FaultInjectingStorage) IterateRuleHitsForRule(
+	orgID types.OrgID,
+	ruleID types.RuleID,
+	errorKey types.ErrorKey,
+	offset, limit int,
+	callback func(storage.RuleHitRecord) error,
+) error {
+	if err := s.inject("IterateRuleHitsForRule"); err != nil {
+		return err
+	}
+
+	return s.Storage.IterateRuleHitsForRule(orgID, ruleID, errorKey, offset, limit, callback)
+}
+
+// ReadReportHistoryForCluster with fault injection
+func (s *FaultInjectingStorage) ReadReportHistoryForCluster(
+	orgID types.OrgID, clusterName types.ClusterName, from, to time.Time,
+) ([]types.HistoricalReport, error) {
+	if err := s.inject("ReadReportHistoryForCluster"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.ReadReportHistoryForCluster(orgID, clusterName, from, to)
+}
+
+// CleanupOldData with fault injection
+func (s *FaultInjectingStorage) CleanupOldData(policy storage.RetentionPolicy) (map[string]int, error) {
+	if err := s.inject("CleanupOldData"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.CleanupOldData(policy)
+}
+
+// ReadOrgRuleDisables with fault injection
+func (s *FaultInjectingStorage) ReadOrgRuleDisables(
+	orgID types.OrgID, mostDisabledLimit int,
+) (types.OrgRuleDisables, error) {
+	if err := s.inject("ReadOrgRuleDisables"); err != nil {
+		return types.OrgRuleDisables{}, err
+	}
+
+	return s.Storage.ReadOrgRuleDisables(orgID, mostDisabledLimit)
+}
+
+// CountClustersForOrg with fault injection
+func (s *FaultInjectingStorage) CountClustersForOrg(orgID types.OrgID, timeLimit time.Time) (int, error) {
+	if err := s.inject("CountClustersForOrg"); err != nil {
+		return 0, err
+	}
+
+	return s.Storage.CountClustersForOrg(orgID, timeLimit)
+}
+
+// WriteReportMessageKey with fault injection
+func (s *FaultInjectingStorage) WriteReportMessageKey(orgID types.OrgID, clusterName types.ClusterName, lastCheckedTime time.Time, key string) error {
+	if err := s.inject("WriteReportMessageKey"); err != nil {
+		return err
+	}
+
+	return s.Storage.WriteReportMessageKey(orgID, clusterName, lastCheckedTime, key)
+}
+
+// WriteClusterClass with fault injection
+func (s *FaultInjectingStorage) WriteClusterClass(orgID types.OrgID, clusterName types.ClusterName, class types.ClusterClass) error {
+	if err := s.inject("WriteClusterClass"); err != nil {
+		return err
+	}
+
+	return s.Storage.WriteClusterClass(orgID, clusterName, class)
+}
+
+// ReadClusterClasses with fault injection
+func (s *FaultInjectingStorage) ReadClusterClasses(orgID types.OrgID) (map[types.ClusterName]types.ClusterClass, error) {
+	if err := s.inject("ReadClusterClasses"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.ReadClusterClasses(orgID)
+}
+
+// LookupMessageKey with fault injection
+func (s *FaultInjectingStorage) LookupMessageKey(key string) (types.MessageKeyLookup, error) {
+	if err := s.inject("LookupMessageKey"); err != nil {
+		return types.MessageKeyLookup{}, err
+	}
+
+	return s.Storage.LookupMessageKey(key)
+}
+
+// GetClustersLastCheckedCacheStats with fault injection
+func (s *FaultInjectingStorage) GetClustersLastCheckedCacheStats() (storage.ClustersLastCheckedCacheStats, error) {
+	if err := s.inject("GetClustersLastCheckedCacheStats"); err != nil {
+		return storage.ClustersLastCheckedCacheStats{}, err
+	}
+
+	return s.Storage.GetClustersLastCheckedCacheStats()
+}
+
+// CheckClustersLastCheckedDivergence with fault injection
+func (s *FaultInjectingStorage) CheckClustersLastCheckedDivergence(
+	sampleSize int,
+) (storage.ClustersLastCheckedDivergence, error) {
+	if err := s.inject("CheckClustersLastCheckedDivergence"); err != nil {
+		return storage.ClustersLastCheckedDivergence{}, err
+	}
+
+	return s.Storage.CheckClustersLastCheckedDivergence(sampleSize)
+}
+
+// ReadRuleHitOccurrences with fault injection
+func (s *FaultInjectingStorage) ReadRuleHitOccurrences(clusterName types.ClusterName, ruleID types.RuleID, errorKey types.ErrorKey) ([]types.RuleHitOccurrence, error) {
+	if err := s.inject("ReadRuleHitOccurrences"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.ReadRuleHitOccurrences(clusterName, ruleID, errorKey)
+}
+
+// ReadReportChecks with fault injection
+func (s *FaultInjectingStorage) ReadReportChecks(clusterName types.ClusterName, limit int) ([]types.ReportCheck, error) {
+	if err := s.inject("ReadReportChecks"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.ReadReportChecks(clusterName, limit)
+}
+
+// WriteReportHistory with fault injection
+func (s *FaultInjectingStorage) WriteReportHistory(
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+	report types.ClusterReport,
+	lastCheckedTime time.Time,
+	kafkaOffset types.KafkaOffset,
+) error {
+	if err := s.inject("WriteReportHistory"); err != nil {
+		return err
+	}
+
+	return s.Storage.WriteReportHistory(orgID, clusterName, report, lastCheckedTime, kafkaOffset)
+}
+
+// IterateReports with fault injection
+func (s *FaultInjectingStorage) IterateReports(callback func(storage.ReportRecord) error) error {
+	if err := s.inject("IterateReports"); err != nil {
+		return err
+	}
+
+	return s.Storage.IterateReports(callback)
+}
+
+// IterateRuleHits with fault injection
+func (s *FaultInjectingStorage) IterateRuleHits(callback func(storage.RuleHitRecord) error) error {
+	if err := s.inject("IterateRuleHits"); err != nil {
+		return err
+	}
+
+	return s.Storage.IterateRuleHits(callback)
+}
+
+// DeleteReportsNotCheckedSince with fault injection
+func (s *FaultInjectingStorage) DeleteReportsNotCheckedSince(threshold time.Time) (int, error) {
+	if err := s.inject("DeleteReportsNotCheckedSince"); err != nil {
+		return 0, err
+	}
+
+	return s.Storage.DeleteReportsNotCheckedSince(threshold)
+}
+
+// CountClustersNotCheckedSince with fault injection
+func (s *FaultInjectingStorage) CountClustersNotCheckedSince(threshold time.Time) (int, error) {
+	if err := s.inject("CountClustersNotCheckedSince"); err != nil {
+		return 0, err
+	}
+
+	return s.Storage.CountClustersNotCheckedSince(threshold)
+}
+
+// AddClusterAnnotation with fault injection
+func (s *FaultInjectingStorage) AddClusterAnnotation(clusterID types.ClusterName, author types.UserID, message string) (types.ClusterAnnotation, error) {
+	if err := s.inject("AddClusterAnnotation"); err != nil {
+		return types.ClusterAnnotation{}, err
+	}
+
+	return s.Storage.AddClusterAnnotation(clusterID, author, message)
+}
+
+// ReadClusterAnnotations with fault injection
+func (s *FaultInjectingStorage) ReadClusterAnnotations(clusterID types.ClusterName) ([]types.ClusterAnnotation, error) {
+	if err := s.inject("ReadClusterAnnotations"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.ReadClusterAnnotations(clusterID)
+}
+
+// DeleteClusterAnnotation with fault injection
+func (s *FaultInjectingStorage) DeleteClusterAnnotation(clusterID types.ClusterName, annotationID string) error {
+	if err := s.inject("DeleteClusterAnnotation"); err != nil {
+		return err
+	}
+
+	return s.Storage.DeleteClusterAnnotation(clusterID, annotationID)
+}
+
+// ListOfOrgsWithSummary with fault injection
+func (s *FaultInjectingStorage) ListOfOrgsWithSummary() ([]types.OrgSummary, error) {
+	if err := s.inject("ListOfOrgsWithSummary"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.ListOfOrgsWithSummary()
+}
+
+// ImportUserFeedback with fault injection
+func (s *FaultInjectingStorage) ImportUserFeedback(feedbacks []storage.UserFeedbackOnRule) error {
+	if err := s.inject("ImportUserFeedback"); err != nil {
+		return err
+	}
+
+	return s.Storage.ImportUserFeedback(feedbacks)
+}
+
+// ReadOrgInfo with fault injection
+func (s *FaultInjectingStorage) ReadOrgInfo(orgID types.OrgID) (types.OrgInfo, error) {
+	if err := s.inject("ReadOrgInfo"); err != nil {
+		return types.OrgInfo{}, err
+	}
+
+	return s.Storage.ReadOrgInfo(orgID)
+}
+
+// GetUserFeedbackOnRulesForClusters with fault injection
+func (s *FaultInjectingStorage) GetUserFeedbackOnRulesForClusters(
+	clusterNames []types.ClusterName, userID types.UserID,
+) ([]storage.UserFeedbackOnRule, error) {
+	if err := s.inject("GetUserFeedbackOnRulesForClusters"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.GetUserFeedbackOnRulesForClusters(clusterNames, userID)
+}
+
+// WriteStaleReport with fault injection
+func (s *FaultInjectingStorage) WriteStaleReport(
+	orgID types.OrgID, clusterName types.ClusterName, lastCheckedTime, producedAt time.Time,
+) error {
+	if err := s.inject("WriteStaleReport"); err != nil {
+		return err
+	}
+
+	return s.Storage.WriteStaleReport(orgID, clusterName, lastCheckedTime, producedAt)
+}
+
+// ReadStaleReportWrites with fault injection
+func (s *FaultInjectingStorage) ReadStaleReportWrites() ([]types.StaleReportWrite, error) {
+	if err := s.inject("ReadStaleReportWrites"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.ReadStaleReportWrites()
+}
+
+// ReadRuleHitsForCluster with fault injection
+func (s *FaultInjectingStorage) ReadRuleHitsForCluster(clusterName types.ClusterName) ([]storage.RuleHitRecord, error) {
+	if err := s.inject("ReadRuleHitsForCluster"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.ReadRuleHitsForCluster(clusterName)
+}
+
+// AddOrgUsage with fault injection
+func (s *FaultInjectingStorage) AddOrgUsage(
+	orgID types.OrgID, messagesProcessed, bytesStored, apiCalls int64,
+) error {
+	if err := s.inject("AddOrgUsage"); err != nil {
+		return err
+	}
+
+	return s.Storage.AddOrgUsage(orgID, messagesProcessed, bytesStored, apiCalls)
+}
+
+// ReadOrgUsage with fault injection
+func (s *FaultInjectingStorage) ReadOrgUsage(month string) ([]types.OrgUsage, error) {
+	if err := s.inject("ReadOrgUsage"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.ReadOrgUsage(month)
+}
+
+// ReadRuleResolutionRates with fault injection
+func (s *FaultInjectingStorage) ReadRuleResolutionRates() ([]types.RuleResolutionRate, error) {
+	if err := s.inject("ReadRuleResolutionRates"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.ReadRuleResolutionRates()
+}
+
+// ReadRuleResolutionRatesForOrg with fault injection
+func (s *FaultInjectingStorage) ReadRuleResolutionRatesForOrg(orgID types.OrgID) ([]types.RuleResolutionRate, error) {
+	if err := s.inject("ReadRuleResolutionRatesForOrg"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.ReadRuleResolutionRatesForOrg(orgID)
+}
+
+// ReadTopRules with fault injection
+func (s *FaultInjectingStorage) ReadTopRules(from, to time.Time, limit int) ([]types.TopRule, error) {
+	if err := s.inject("ReadTopRules"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.ReadTopRules(from, to, limit)
+}
+
+// ReadTopRulesForOrg with fault injection
+func (s *FaultInjectingStorage) ReadTopRulesForOrg(orgID types.OrgID, from, to time.Time, limit int) ([]types.TopRule, error) {
+	if err := s.inject("ReadTopRulesForOrg"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.ReadTopRulesForOrg(orgID, from, to, limit)
+}
+
+// ReadOrgReport with fault injection
+func (s *FaultInjectingStorage) ReadOrgReport(orgID types.OrgID) ([]types.OrgReportRule, error) {
+	if err := s.inject("ReadOrgReport"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.ReadOrgReport(orgID)
+}
+
+// FreezeOrg with fault injection
+func (s *FaultInjectingStorage) FreezeOrg(orgID types.OrgID, reason string) error {
+	if err := s.inject("FreezeOrg"); err != nil {
+		return err
+	}
+
+	return s.Storage.FreezeOrg(orgID, reason)
+}
+
+// UnfreezeOrg with fault injection
+func (s *FaultInjectingStorage) UnfreezeOrg(orgID types.OrgID) error {
+	if err := s.inject("UnfreezeOrg"); err != nil {
+		return err
+	}
+
+	return s.Storage.UnfreezeOrg(orgID)
+}
+
+// IsOrgFrozen with fault injection
+func (s *FaultInjectingStorage) IsOrgFrozen(orgID types.OrgID) (bool, error) {
+	if err := s.inject("IsOrgFrozen"); err != nil {
+		return false, err
+	}
+
+	return s.Storage.IsOrgFrozen(orgID)
+}
+
+// ReadFrozenOrgs with fault injection
+func (s *FaultInjectingStorage) ReadFrozenOrgs() ([]types.OrgFreeze, error) {
+	if err := s.inject("ReadFrozenOrgs"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.ReadFrozenOrgs()
+}
+
+// ReadVoteSummaryOnRule with fault injection
+func (s *FaultInjectingStorage) ReadVoteSummaryOnRule(
+	clusterID types.ClusterName, ruleID types.RuleID, errorKey types.ErrorKey,
+) (types.VoteSummary, error) {
+	if err := s.inject("ReadVoteSummaryOnRule"); err != nil {
+		return types.VoteSummary{}, err
+	}
+
+	return s.Storage.ReadVoteSummaryOnRule(clusterID, ruleID, errorKey)
+}
+
+// ReadVoteSummaryOnRuleForOrg with fault injection
+func (s *FaultInjectingStorage) ReadVoteSummaryOnRuleForOrg(
+	orgID types.OrgID, ruleID types.RuleID, errorKey types.ErrorKey,
+) (types.VoteSummary, error) {
+	if err := s.inject("ReadVoteSummaryOnRuleForOrg"); err != nil {
+		return types.VoteSummary{}, err
+	}
+
+	return s.Storage.ReadVoteSummaryOnRuleForOrg(orgID, ruleID, errorKey)
+}
+
+// VoteOnRuleForOrg with fault injection
+func (s *FaultInjectingStorage) VoteOnRuleForOrg(
+	orgID types.OrgID,
+	ruleID types.RuleID,
+	errorKey types.ErrorKey,
+	userID types.UserID,
+	userVote types.UserVote,
+	voteMessage string,
+) error {
+	if err := s.inject("VoteOnRuleForOrg"); err != nil {
+		return err
+	}
+
+	return s.Storage.VoteOnRuleForOrg(orgID, ruleID, errorKey, userID, userVote, voteMessage)
+}
+
+// AddOrUpdateFeedbackOnRuleForOrg with fault injection
+func (s *FaultInjectingStorage) AddOrUpdateFeedbackOnRuleForOrg(
+	orgID types.OrgID,
+	ruleID types.RuleID,
+	errorKey types.ErrorKey,
+	userID types.UserID,
+	message string,
+) error {
+	if err := s.inject("AddOrUpdateFeedbackOnRuleForOrg"); err != nil {
+		return err
+	}
+
+	return s.Storage.AddOrUpdateFeedbackOnRuleForOrg(orgID, ruleID, errorKey, userID, message)
+}
+
+// GetUserFeedbackOnRuleForOrg with fault injection
+func (s *FaultInjectingStorage) GetUserFeedbackOnRuleForOrg(
+	orgID types.OrgID, ruleID types.RuleID, errorKey types.ErrorKey, userID types.UserID,
+) (*storage.OrgUserFeedbackOnRule, error) {
+	if err := s.inject("GetUserFeedbackOnRuleForOrg"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.GetUserFeedbackOnRuleForOrg(orgID, ruleID, errorKey, userID)
+}
+
+// GetFeedbackOnRuleForOrg with fault injection
+func (s *FaultInjectingStorage) GetFeedbackOnRuleForOrg(
+	orgID types.OrgID, ruleID types.RuleID, errorKey types.ErrorKey,
+) ([]storage.OrgUserFeedbackOnRule, error) {
+	if err := s.inject("GetFeedbackOnRuleForOrg"); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.GetFeedbackOnRuleForOrg(orgID, ruleID, errorKey)
+}
@@ -15,6 +15,7 @@
 package helpers
 
 import (
+	"net/http"
 	"testing"
 
 	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
@@ -34,12 +35,6 @@ var (
 	ExecuteRequest = helpers.ExecuteRequest
 	// CheckResponseBodyJSON checks response body
 	CheckResponseBodyJSON = helpers.CheckResponseBodyJSON
-	// AssertReportResponsesEqual fails if report responses aren't equal
-	AssertReportResponsesEqual = helpers.AssertReportResponsesEqual
-	// AssertReportResponsesEqualCustomElementsChecker fails if report responses aren't equal
-	AssertReportResponsesEqualCustomElementsChecker = helpers.AssertReportResponsesEqualCustomElementsChecker
-	// AssertRuleResponsesEqual fails if rule responses aren't equal
-	AssertRuleResponsesEqual = helpers.AssertRuleResponsesEqual
 	// NewGockRequestMatcher returns a new matcher for github.com/h2non/gock to match requests
 	// with provided method, url and jsonBody
 	NewGockRequestMatcher = helpers.NewGockRequestMatcher
@@ -55,6 +50,7 @@ var DefaultServerConfig = server.Configuration{
 	APIPrefix:                    "/api/test/",
 	APISpecFile:                  "openapi.json",
 	Debug:                        true,
+	DebugEndpointsEnabled:        true,
 	Auth:                         false,
 	MaximumFeedbackMessageLength: 255,
 	OrgOverviewLimitHours:        2,
@@ -66,15 +62,23 @@ var DefaultServerConfigAuth = server.Configuration{
 	APIPrefix:                    "/api/test/",
 	APISpecFile:                  "openapi.json",
 	Debug:                        true,
+	DebugEndpointsEnabled:        true,
 	Auth:                         true,
 	MaximumFeedbackMessageLength: 255,
 	OrgOverviewLimitHours:        2,
 }
 
+// DebugConfirmationHeaders returns headers confirming that the request is
+// intentionally sent to a debug endpoint
+func DebugConfirmationHeaders() http.Header {
+	return http.Header{server.DebugConfirmationHeader: []string{"true"}}
+}
+
 // AssertAPIRequest creates new server with provided mockStorage
 // (which you can keep nil so it will be created automatically)
 // and provided serverConfig(you can leave it empty to use the default one)
-// sends api request and checks api response (see docs for APIRequest and APIResponse)
+// sends api request and checks api response (see docs for APIRequest and APIResponse).
+// BodyChecker is called even when the expected body is not set.
 func AssertAPIRequest(
 	t testing.TB,
 	mockStorage storage.Storage,
@@ -91,6 +95,13 @@ func AssertAPIRequest(
 		serverConfig = &DefaultServerConfig
 	}
 
+	// the body checker is called only when the expected body is set
+	if expectedResponse.BodyChecker != nil && expectedResponse.Body == nil {
+		response := *expectedResponse
+		response.Body = ""
+		expectedResponse = &response
+	}
+
 	testServer := server.New(*serverConfig, mockStorage)
 
 	helpers.AssertAPIRequest(t, testServer, serverConfig.APIPrefix, request, expectedResponse)
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
@@ -37,6 +38,8 @@ const postgres = "postgres"
 // or on postgresql with config taken from config-devel.toml
 // if env variable INSIGHTS_RESULTS_AGGREGATOR__TESTS_DB is set to "postgres"
 // INSIGHTS_RESULTS_AGGREGATOR__TESTS_DB_ADMIN_PASS is set to db admin's password
+// if INSIGHTS_RESULTS_AGGREGATOR__TESTS_DB_DOCKER is set to true, postgresql
+// is started in docker container instead (see MustGetDockerDatabase)
 // produces t.Fatal(err) on error
 func MustGetMockStorage(tb testing.TB, init bool) (storage.Storage, func()) {
 	if os.Getenv("INSIGHTS_RESULTS_AGGREGATOR__TESTS_DB") == postgres {
@@ -102,9 +105,13 @@ func MustCloseMockDBWithExpects(
 	helpers.FailOnError(t, db.Close())
 }
 
-// MustGetSQLiteMemoryStorage creates test sqlite storage in file
+// MustGetSQLiteMemoryStorage creates test sqlite storage in memory. Every
+// storage gets its own uniquely named database, so tests can run in
+// parallel, the database is shared by all connections of the storage.
 func MustGetSQLiteMemoryStorage(tb testing.TB, init bool) (storage.Storage, func()) {
-	sqliteStorage := mustGetSqliteStorage(tb, ":memory:", init)
+	datasource := fmt.Sprintf("file:%v?mode=memory&cache=shared", uuid.New().String())
+
+	sqliteStorage := mustGetSqliteStorage(tb, datasource, init)
 
 	return sqliteStorage, func() {
 		MustCloseStorage(tb, sqliteStorage)
@@ -140,45 +147,135 @@ func mustGetSqliteStorage(tb testing.TB, datasource string, init bool) storage.S
 	return sqliteStorage
 }
 
-// MustGetPostgresStorage creates test postgres storage with credentials from config-devel
-func MustGetPostgresStorage(tb testing.TB, init bool) (storage.Storage, func()) {
-	dbAdminPassword := os.Getenv("INSIGHTS_RESULTS_AGGREGATOR__TESTS_DB_ADMIN_PASS")
+// postgresTestDatabase is the database shared by all tests running at the
+// same time, every test uses its own schema in it. The database is created
+// when the first test needs it and dropped when the last one finishes.
+type postgresTestDatabase struct {
+	mutex     sync.Mutex
+	name      string
+	adminConn *sql.DB
+	users     int
+}
 
-	err := conf.LoadConfiguration("../config-devel")
-	helpers.FailOnError(tb, err)
+var (
+	testPostgresDatabase postgresTestDatabase
 
-	// force postgres and replace db name with test one
-	storageConf := &conf.Config.Storage
+	// configuration of postgres storage is read from config-devel only
+	// once, so tests running in parallel don't change it under each other
+	postgresConfigurationOnce sync.Once
+	postgresConfiguration     storage.Configuration
+	postgresConfigurationErr  error
+)
+
+// mustGetPostgresConfiguration returns copy of the storage configuration
+// from config-devel with the connection to the DB started in docker when
+// enabled by UseDockerDatabase
+func mustGetPostgresConfiguration(tb testing.TB) (storageConf storage.Configuration, adminUser, adminPassword string) {
+	postgresConfigurationOnce.Do(func() {
+		postgresConfigurationErr = conf.LoadConfiguration("../config-devel")
+		postgresConfiguration = conf.GetStorageConfiguration()
+	})
+	helpers.FailOnError(tb, postgresConfigurationErr)
+
+	storageConf = postgresConfiguration
 	storageConf.Driver = postgres
-	storageConf.PGDBName += "_test_db_" + strings.ReplaceAll(uuid.New().String(), "-", "_")
+	adminUser = postgres
+	adminPassword = os.Getenv("INSIGHTS_RESULTS_AGGREGATOR__TESTS_DB_ADMIN_PASS")
 
-	connString := fmt.Sprintf(
-		"host=%s port=%d user=%s password=%s sslmode=disable",
-		storageConf.PGHost, storageConf.PGPort, postgres, dbAdminPassword,
-	)
+	if UseDockerDatabase() {
+		database := MustGetDockerDatabase(tb, postgres)
 
-	adminConn, err := sql.Open(storageConf.Driver, connString)
-	helpers.FailOnError(tb, err)
+		storageConf.PGHost = database.Host
+		storageConf.PGPort = database.Port
+		storageConf.PGUsername = database.AdminUser
+		storageConf.PGPassword = database.AdminPassword
 
-	query := "CREATE DATABASE " + storageConf.PGDBName + ";"
-	_, err = adminConn.Exec(query)
-	helpers.FailOnError(tb, err)
+		adminUser = database.AdminUser
+		adminPassword = database.AdminPassword
+	}
+
+	return storageConf, adminUser, adminPassword
+}
+
+// acquire returns name of the test database, it's created when no other
+// test is using it
+func (database *postgresTestDatabase) acquire(
+	tb testing.TB, storageConf storage.Configuration, adminUser, adminPassword string,
+) string {
+	database.mutex.Lock()
+	defer database.mutex.Unlock()
+
+	if database.users == 0 {
+		connString := fmt.Sprintf(
+			"host=%s port=%d user=%s password=%s sslmode=disable",
+			storageConf.PGHost, storageConf.PGPort, adminUser, adminPassword,
+		)
+
+		adminConn, err := sql.Open(storageConf.Driver, connString)
+		helpers.FailOnError(tb, err)
+
+		name := storageConf.PGDBName + "_test_db_" + strings.ReplaceAll(uuid.New().String(), "-", "_")
+
+		_, err = adminConn.Exec("CREATE DATABASE " + name + ";")
+		if err != nil {
+			_ = adminConn.Close()
+			helpers.FailOnError(tb, err)
+		}
+
+		database.name = name
+		database.adminConn = adminConn
+	}
+
+	database.users++
 
-	postgresStorage, err := storage.New(conf.GetStorageConfiguration())
+	return database.name
+}
+
+// release drops the test database when no other test is using it
+func (database *postgresTestDatabase) release(tb testing.TB) {
+	database.mutex.Lock()
+	defer database.mutex.Unlock()
+
+	database.users--
+	if database.users > 0 {
+		return
+	}
+
+	_, err := database.adminConn.Exec("DROP DATABASE " + database.name)
 	helpers.FailOnError(tb, err)
 
+	helpers.FailOnError(tb, database.adminConn.Close())
+	database.adminConn = nil
+}
+
+// MustGetPostgresStorage creates test postgres storage with credentials from config-devel
+// or in postgres docker container when enabled by UseDockerDatabase. Every
+// storage uses its own schema in the shared test database, so tests can run
+// in parallel.
+func MustGetPostgresStorage(tb testing.TB, init bool) (storage.Storage, func()) {
+	storageConf, adminUser, adminPassword := mustGetPostgresConfiguration(tb)
+
+	storageConf.PGDBName = testPostgresDatabase.acquire(tb, storageConf, adminUser, adminPassword)
+	storageConf.PGSchema = "test_" + strings.ReplaceAll(uuid.New().String(), "-", "_")
+
+	postgresStorage, err := storage.New(storageConf)
+	if err != nil {
+		testPostgresDatabase.release(tb)
+		helpers.FailOnError(tb, err)
+	}
+
+	// the schema is needed even by tests running the migrations on their own
+	helpers.FailOnError(tb, postgresStorage.CreateSchemaIfNotExists())
+
 	if init {
 		helpers.FailOnError(tb, postgresStorage.MigrateToLatest())
 		helpers.FailOnError(tb, postgresStorage.Init())
 	}
 
+	// the schema is dropped together with the test database
 	return postgresStorage, func() {
 		MustCloseStorage(tb, postgresStorage)
-
-		_, err := adminConn.Exec("DROP DATABASE " + conf.Config.Storage.PGDBName)
-		helpers.FailOnError(tb, err)
-
-		helpers.FailOnError(tb, adminConn.Close())
+		testPostgresDatabase.release(tb)
 	}
 }
 
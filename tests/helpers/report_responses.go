@@ -0,0 +1,127 @@
+// Copyright 2020 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helpers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// RuleDisableDetails describes who disabled the rule for the cluster in
+// the report response
+type RuleDisableDetails struct {
+	DisabledBy    types.UserID    `json:"disabled_by,omitempty"`
+	DisabledAt    types.Timestamp `json:"disabled_at,omitempty"`
+	Justification string          `json:"justification,omitempty"`
+}
+
+// RuleVotes is the summary of votes on the rule in the single rule response
+type RuleVotes struct {
+	User         types.UserVote     `json:"user"`
+	Cluster      types.VoteSummary  `json:"cluster"`
+	Organization *types.VoteSummary `json:"organization,omitempty"`
+}
+
+// RuleOnReport is the rule in the report response, it contains the fields
+// the service adds to the rule of the stored report
+type RuleOnReport struct {
+	types.RuleOnReport
+	DisableDetails *RuleDisableDetails `json:"disable_details,omitempty"`
+	FirstSeenAt    types.Timestamp     `json:"first_seen_at,omitempty"`
+	Votes          *RuleVotes          `json:"votes,omitempty"`
+}
+
+// ReportResponse is the report in the response of the report endpoint
+type ReportResponse struct {
+	Meta   types.ReportResponseMeta `json:"meta"`
+	Report []RuleOnReport           `json:"reports"`
+}
+
+// ExpectedReportResponse returns the expected response of the report
+// endpoint built from the response (usually taken from testdata), every rule
+// is changed by the function first, so the fields the service adds to the
+// rules can be set
+func ExpectedReportResponse(t testing.TB, response string, update func(rule *RuleOnReport)) string {
+	var expected struct {
+		Status string         `json:"status"`
+		Report ReportResponse `json:"report"`
+	}
+
+	FailOnError(t, helpers.JSONUnmarshalStrict([]byte(response), &expected))
+
+	for i := range expected.Report.Report {
+		update(&expected.Report.Report[i])
+	}
+
+	result, err := json.Marshal(expected)
+	FailOnError(t, err)
+
+	return string(result)
+}
+
+// AssertReportResponsesEqual checks if reports in answer are the same
+func AssertReportResponsesEqual(t testing.TB, expected, got []byte) {
+	AssertReportResponsesEqualCustomElementsChecker(t, expected, got, func(t testing.TB, expected, got []RuleOnReport) {
+		assert.ElementsMatch(t, expected, got)
+	})
+}
+
+// AssertReportResponsesEqualCustomElementsChecker checks if reports in
+// answer are the same using custom checker for elements
+func AssertReportResponsesEqualCustomElementsChecker(
+	t testing.TB, expected, got []byte, elementsChecker func(testing.TB, []RuleOnReport, []RuleOnReport),
+) {
+	var expectedResponse, gotResponse struct {
+		Status string         `json:"status"`
+		Report ReportResponse `json:"report"`
+	}
+
+	FailOnError(t, helpers.JSONUnmarshalStrict(expected, &expectedResponse))
+	FailOnError(t, helpers.JSONUnmarshalStrict(got, &gotResponse))
+
+	assert.NotEmpty(t, expectedResponse.Status, "status of expected response is empty")
+	assert.Equal(t, expectedResponse.Status, gotResponse.Status)
+	assert.Equal(t, expectedResponse.Report.Meta, gotResponse.Report.Meta)
+	// ignore the order
+	assert.Equal(
+		t,
+		len(expectedResponse.Report.Report),
+		len(gotResponse.Report.Report),
+		"length of reports should be equal",
+	)
+	if elementsChecker != nil {
+		elementsChecker(t, expectedResponse.Report.Report, gotResponse.Report.Report)
+	}
+}
+
+// AssertRuleResponsesEqual checks if rules in answer are the same
+func AssertRuleResponsesEqual(t testing.TB, expected, got []byte) {
+	var expectedResponse, gotResponse struct {
+		Status string       `json:"status"`
+		Report RuleOnReport `json:"report"`
+	}
+
+	FailOnError(t, helpers.JSONUnmarshalStrict(expected, &expectedResponse))
+	FailOnError(t, helpers.JSONUnmarshalStrict(got, &gotResponse))
+
+	assert.NotEmpty(t, expectedResponse.Status, "status of expected response is empty")
+	assert.Equal(t, expectedResponse.Status, gotResponse.Status)
+	assert.EqualValues(t, expectedResponse.Report, gotResponse.Report)
+}
@@ -22,6 +22,8 @@ import (
 	"fmt"
 
 	"github.com/verdverm/frisby"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
 )
 
 // common constants used by REST API tests
@@ -76,6 +78,11 @@ func setAuthHeader(f *frisby.Frisby) {
 	setAuthHeaderForOrganization(f, 1)
 }
 
+// setDebugConfirmationHeader set header required by debug endpoints
+func setDebugConfirmationHeader(f *frisby.Frisby) {
+	f.SetHeader(server.DebugConfirmationHeader, "true")
+}
+
 // readStatusFromResponse reads and parses status from response body
 func readStatusFromResponse(f *frisby.Frisby) StatusOnlyResponse {
 	response := StatusOnlyResponse{}
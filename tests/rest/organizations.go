@@ -67,6 +67,7 @@ func contentSizeForOrganizationResponse(orgIDs ...int) int {
 func checkOrganizationsEndpointWithPostfix(postfix string) {
 	f := frisby.Create("Check the end point to return list of organizations by HTTP GET method").Get(apiURL + "organizations" + postfix)
 	setAuthHeader(f)
+	setDebugConfirmationHeader(f)
 	f.Send()
 	f.ExpectStatus(200)
 	f.ExpectHeader(contentTypeHeader, ContentTypeJSON)
@@ -110,6 +110,7 @@ func checkOkStatusUserVote(url string, message string) {
 
 func checkOkStatusGetVote(url string, message string) {
 	f := frisby.Create(message).Get(url)
+	setDebugConfirmationHeader(f)
 	checkOkStatus(f)
 }
 
@@ -132,6 +133,7 @@ func checkInvalidUUIDFormat(f *frisby.Frisby) {
 
 func checkInvalidUUIDFormatGet(url string, message string) {
 	f := frisby.Create(message).Get(url)
+	setDebugConfirmationHeader(f)
 	checkInvalidUUIDFormat(f)
 }
 
@@ -159,6 +161,7 @@ func checkItemNotFound(f *frisby.Frisby) {
 
 func checkItemNotFoundGet(url string, message string) {
 	f := frisby.Create(message).Get(url)
+	setDebugConfirmationHeader(f)
 	checkItemNotFound(f)
 }
 
@@ -0,0 +1,150 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+// ruleSortKeys are pairs of attributes identifying the rule in JSON objects
+// stored in reports (component, key) and returned by REST API (rule_id,
+// error_key)
+var ruleSortKeys = [][2]string{
+	{"component", "key"},
+	{"rule_id", "error_key"},
+}
+
+// SortRulesOnReport sorts the rules by rule module and error key. Sorting is
+// stable, so rules with the same module and error key keep their order.
+func SortRulesOnReport(rules []RuleOnReport) {
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].Module != rules[j].Module {
+			return rules[i].Module < rules[j].Module
+		}
+		return rules[i].ErrorKey < rules[j].ErrorKey
+	})
+}
+
+// MarshalCanonical serializes the value into canonical JSON, see
+// CanonicalJSON
+func MarshalCanonical(value interface{}) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return CanonicalJSON(data)
+}
+
+// CanonicalJSON converts JSON document into its canonical form: keys of all
+// objects are sorted, lists of rules are sorted by rule module and error key
+// and insignificant whitespace is removed. The same document is therefore
+// always serialized into the same bytes, so the output can be compared by
+// diff tools and cached by proxies.
+func CanonicalJSON(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	// keep numbers as they are, float64 would lose precision of large IDs
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	sortRuleLists(value)
+
+	// encoding/json sorts keys of maps
+	return json.Marshal(value)
+}
+
+// sortRuleLists recursively sorts all lists of rules in the decoded JSON
+// document
+func sortRuleLists(value interface{}) {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for _, item := range typed {
+			sortRuleLists(item)
+		}
+	case []interface{}:
+		for _, item := range typed {
+			sortRuleLists(item)
+		}
+		sortRuleList(typed)
+	}
+}
+
+// sortRuleList sorts the list when all its items are rules identified by the
+// same pair of attributes, other lists are left untouched
+func sortRuleList(list []interface{}) {
+	if len(list) < 2 {
+		return
+	}
+
+	for _, keys := range ruleSortKeys {
+		ruleKeys := make([][2]string, len(list))
+		isRuleList := true
+
+		for i, item := range list {
+			module, errorKey, ok := ruleKey(item, keys)
+			if !ok {
+				isRuleList = false
+				break
+			}
+			ruleKeys[i] = [2]string{module, errorKey}
+		}
+
+		if !isRuleList {
+			continue
+		}
+
+		indexes := make([]int, len(list))
+		for i := range indexes {
+			indexes[i] = i
+		}
+		sort.SliceStable(indexes, func(i, j int) bool {
+			first, second := ruleKeys[indexes[i]], ruleKeys[indexes[j]]
+			if first[0] != second[0] {
+				return first[0] < second[0]
+			}
+			return first[1] < second[1]
+		})
+
+		sorted := make([]interface{}, len(list))
+		for i, index := range indexes {
+			sorted[i] = list[index]
+		}
+		copy(list, sorted)
+
+		return
+	}
+}
+
+// ruleKey returns the rule module and error key of the JSON object, ok is
+// false when the item is not an object with both attributes being strings
+func ruleKey(item interface{}, keys [2]string) (module, errorKey string, ok bool) {
+	object, isObject := item.(map[string]interface{})
+	if !isObject {
+		return "", "", false
+	}
+
+	module, moduleOK := object[keys[0]].(string)
+	errorKey, errorKeyOK := object[keys[1]].(string)
+
+	return module, errorKey, moduleOK && errorKeyOK
+}
@@ -0,0 +1,43 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+// ClusterClass tells apart clusters managed by Red Hat SRE (OSD, ROSA) from
+// clusters managed by customers
+type ClusterClass string
+
+const (
+	// ClusterClassManaged is the class of clusters managed by Red Hat SRE
+	ClusterClassManaged ClusterClass = "managed"
+	// ClusterClassSelfManaged is the class of clusters managed by customers,
+	// it's used for clusters without the class in metadata of their reports
+	ClusterClassSelfManaged ClusterClass = "self-managed"
+)
+
+// ClusterClasses contains all classes of clusters
+var ClusterClasses = []ClusterClass{ClusterClassManaged, ClusterClassSelfManaged}
+
+// IsValid returns true for known classes of clusters
+func (class ClusterClass) IsValid() bool {
+	for _, known := range ClusterClasses {
+		if class == known {
+			return true
+		}
+	}
+
+	return false
+}
@@ -41,6 +41,15 @@ type (
 // exists on the storage while attempting to write a report for a cluster.
 var ErrOldReport = types.ErrOldReport
 
+// ErrClusterOrgConflict is an error returned when the report of a cluster
+// stored under another organization is rejected by the conflict policy
+var ErrClusterOrgConflict = errors.New("cluster is already reported under another organization")
+
+// ErrReportInconsistent is an error returned when rule hits of the cluster
+// were written by another write of the report than the report read (the
+// report was written concurrently with the read), the read can be retried
+var ErrReportInconsistent = errors.New("rule hits belong to another generation of the report")
+
 // TableNotFoundError table not found error
 type TableNotFoundError struct {
 	tableName string
@@ -0,0 +1,127 @@
+/*
+Copyright © 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// timeFormats are formats of timestamps returned as text by the database
+// drivers, SQLite returns timestamps computed by aggregate functions (like
+// MAX) that way, for example
+var timeFormats = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// NullTime is a timestamp read from a column that can contain NULL (report
+// timestamps of rows written by old versions of the service, for example).
+// NULL is read as invalid time that is formatted as empty Timestamp.
+type NullTime struct {
+	Time  time.Time
+	Valid bool
+}
+
+// Scan implements sql.Scanner interface. Besides time values, timestamps
+// returned as text are accepted, other values are rejected.
+func (nullTime *NullTime) Scan(value interface{}) error {
+	switch typed := value.(type) {
+	case nil:
+		nullTime.Time, nullTime.Valid = time.Time{}, false
+		return nil
+	case time.Time:
+		nullTime.Time, nullTime.Valid = typed, true
+		return nil
+	case []byte:
+		return nullTime.parse(string(typed))
+	case string:
+		return nullTime.parse(typed)
+	default:
+		return fmt.Errorf("unable to scan %T into timestamp", value)
+	}
+}
+
+// parse reads the timestamp returned as text
+func (nullTime *NullTime) parse(value string) error {
+	// time.Time.String adds monotonic clock reading that is not a part of
+	// the timestamp
+	value = strings.TrimSpace(strings.SplitN(value, " m=", 2)[0])
+
+	for _, format := range timeFormats {
+		parsed, err := time.Parse(format, value)
+		if err == nil {
+			nullTime.Time, nullTime.Valid = parsed, true
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unable to parse timestamp %q", value)
+}
+
+// TimeOr returns the time, or the default when the value is NULL
+func (nullTime NullTime) TimeOr(defaultTime time.Time) time.Time {
+	if !nullTime.Valid {
+		return defaultTime
+	}
+
+	return nullTime.Time
+}
+
+// Timestamp returns the time in UTC formatted as RFC3339, or empty Timestamp
+// when the value is NULL
+func (nullTime NullTime) Timestamp() Timestamp {
+	if !nullTime.Valid {
+		return ""
+	}
+
+	return Timestamp(nullTime.Time.UTC().Format(time.RFC3339))
+}
+
+// SQL returns the value as sql.NullTime
+func (nullTime NullTime) SQL() sql.NullTime {
+	return sql.NullTime{Time: nullTime.Time, Valid: nullTime.Valid}
+}
+
+// NullKafkaOffset is Kafka offset read from a column that can contain NULL.
+// NULL is read as invalid offset with value 0, the same offset is used for
+// reports that were not consumed from Kafka.
+type NullKafkaOffset struct {
+	Offset KafkaOffset
+	Valid  bool
+}
+
+// Scan implements sql.Scanner interface
+func (nullOffset *NullKafkaOffset) Scan(value interface{}) error {
+	var nullInt sql.NullInt64
+
+	if err := nullInt.Scan(value); err != nil {
+		return fmt.Errorf("unable to scan %T into Kafka offset: %v", value, err)
+	}
+
+	nullOffset.Offset, nullOffset.Valid = KafkaOffset(nullInt.Int64), nullInt.Valid
+	return nil
+}
@@ -17,6 +17,9 @@
 package types
 
 import (
+	"encoding/json"
+	"time"
+
 	"github.com/RedHatInsights/insights-operator-utils/types"
 )
 
@@ -103,6 +106,292 @@ type FeedbackRequest struct {
 	Message string `json:"message"`
 }
 
+// RuleHitOccurrence represents a period of time during which the rule was
+// reported for the cluster. DisappearedAt is empty while the rule is still
+// being reported.
+type RuleHitOccurrence struct {
+	AppearedAt    Timestamp `json:"appeared_at"`
+	DisappearedAt Timestamp `json:"disappeared_at,omitempty"`
+}
+
+// ReportCheck contains statistics of one check of the cluster: number of
+// rules hit by the cluster and number of rules that were added and removed
+// since the previous check
+type ReportCheck struct {
+	CheckedAt Timestamp `json:"checked_at"`
+	HitCount  int       `json:"hit_count"`
+	Added     int       `json:"added"`
+	Removed   int       `json:"removed"`
+}
+
+// HistoricalReport is one report of the cluster stored in the history of
+// its reports
+type HistoricalReport struct {
+	LastCheckedAt Timestamp     `json:"last_checked_at"`
+	ReportedAt    Timestamp     `json:"reported_at"`
+	Report        ClusterReport `json:"report"`
+}
+
+// RuleResolutionRate contains the number of times the rule was reported for
+// clusters (Hits), the number of times it disappeared from a new report of the
+// cluster (Resolved) and their ratio
+type RuleResolutionRate struct {
+	RuleID         RuleID   `json:"rule_id"`
+	ErrorKey       ErrorKey `json:"error_key"`
+	Hits           int      `json:"hits"`
+	Resolved       int      `json:"resolved"`
+	ResolutionRate float64  `json:"resolution_rate"`
+}
+
+// OrgReportRule represents one rule in the report merged from reports of all
+// clusters of the organization. Clusters contains the clusters the rule is
+// reported for, clusters where the rule is disabled are listed separately.
+type OrgReportRule struct {
+	RuleID           RuleID        `json:"rule_id"`
+	ErrorKey         ErrorKey      `json:"error_key"`
+	Clusters         []ClusterName `json:"clusters"`
+	DisabledClusters []ClusterName `json:"disabled_clusters"`
+}
+
+// ClusterAnnotation represents a free-text note attached to the cluster
+// report, usually by support engineer
+type ClusterAnnotation struct {
+	ID        string      `json:"id"`
+	ClusterID ClusterName `json:"cluster"`
+	Author    UserID      `json:"author"`
+	Message   string      `json:"message"`
+	CreatedAt Timestamp   `json:"created_at"`
+}
+
+// OrgInfo contains timestamps when the first and the last report from the
+// organization was received
+type OrgInfo struct {
+	OrgID       OrgID     `json:"org_id"`
+	FirstSeenAt Timestamp `json:"first_seen_at"`
+	LastSeenAt  Timestamp `json:"last_seen_at"`
+}
+
+// DisabledRuleCount is the number of clusters of the organization where the
+// rule is disabled
+type DisabledRuleCount struct {
+	RuleID   RuleID   `json:"rule_id"`
+	ErrorKey ErrorKey `json:"error_key"`
+	Clusters int      `json:"clusters"`
+}
+
+// OrgRuleDisables summarizes rules disabled in the organization. ClusterLevel
+// is the number of disables of rules for clusters of the organization,
+// OrgLevel is the number of rules disabled for all its clusters.
+type OrgRuleDisables struct {
+	ClusterLevel int                 `json:"cluster_level"`
+	OrgLevel     int                 `json:"org_level"`
+	MostDisabled []DisabledRuleCount `json:"most_disabled"`
+}
+
+// OrgOverview represents the overview of the organization: when it was
+// first seen and last active and how many rules it disabled
+type OrgOverview struct {
+	OrgInfo
+	DisabledRules OrgRuleDisables `json:"disabled_rules"`
+}
+
+// OrgFreeze describes administrative freeze of the organization, messages
+// from frozen organization are dropped and its data can't be changed
+type OrgFreeze struct {
+	OrgID    OrgID     `json:"org_id"`
+	Reason   string    `json:"reason"`
+	FrozenAt Timestamp `json:"frozen_at"`
+}
+
+// APIKey describes API key used by internal services to authenticate to the
+// REST API. Secret part of the key is never stored, only its hash is.
+type APIKey struct {
+	KeyID     string    `json:"key_id"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt Timestamp `json:"created_at"`
+	ExpiresAt Timestamp `json:"expires_at,omitempty"`
+	RotatedAt Timestamp `json:"rotated_at,omitempty"`
+	RevokedAt Timestamp `json:"revoked_at,omitempty"`
+}
+
+// ClusterOrgChange records that the cluster started to report under another
+// organization and how the conflict was resolved (see
+// storage.ClusterOrgConflictPolicy)
+type ClusterOrgChange struct {
+	ClusterName   ClusterName `json:"cluster"`
+	PreviousOrgID OrgID       `json:"previous_org_id"`
+	OrgID         OrgID       `json:"org_id"`
+	ChangedAt     Timestamp   `json:"changed_at"`
+	Resolution    string      `json:"resolution"`
+}
+
+// ExternalResult is the result of one check of the cluster done by an
+// external source (security scanner, for example) instead of
+// insights-operator. Severity goes from 1 (low) to 4 (critical), details are
+// arbitrary JSON provided by the source.
+type ExternalResult struct {
+	Source        string          `json:"source"`
+	CheckID       string          `json:"check_id"`
+	Severity      int             `json:"severity"`
+	Description   string          `json:"description"`
+	Details       json.RawMessage `json:"details,omitempty"`
+	LastCheckedAt Timestamp       `json:"last_checked_at"`
+}
+
+// OrgSummary contains number of clusters of the organization that have
+// a report and the time when the most recent report was checked
+type OrgSummary struct {
+	OrgID         OrgID     `json:"org_id"`
+	ClusterCount  int       `json:"cluster_count"`
+	LastCheckedAt Timestamp `json:"last_checked_at"`
+}
+
+// StaleReportWrite contains statistics of reports from the cluster that were
+// rejected because a more recent report of the cluster was already stored.
+// Timestamps are taken from the last rejected report, TimestampSkewSeconds is
+// difference between its last checked time and the time it was produced to
+// Kafka, which is far from zero for clusters with wrong clock.
+type StaleReportWrite struct {
+	OrgID                OrgID       `json:"org_id"`
+	ClusterName          ClusterName `json:"cluster"`
+	RejectedCount        int         `json:"rejected_count"`
+	LastRejectedAt       Timestamp   `json:"last_rejected_at"`
+	LastCheckedAt        Timestamp   `json:"last_checked_at"`
+	ProducedAt           Timestamp   `json:"produced_at,omitempty"`
+	TimestampSkewSeconds *float64    `json:"timestamp_skew_seconds,omitempty"`
+}
+
+// OrgUsage contains usage of the service by the organization in one month,
+// the month is in YYYY-MM format
+type OrgUsage struct {
+	OrgID             OrgID  `json:"org_id"`
+	Month             string `json:"month"`
+	MessagesProcessed int64  `json:"messages_processed"`
+	BytesStored       int64  `json:"bytes_stored"`
+	APICalls          int64  `json:"api_calls"`
+}
+
+// KafkaPartitionOffset contains offset of the latest message processed by
+// the consumer in the topic partition
+type KafkaPartitionOffset struct {
+	Topic     string      `json:"topic"`
+	Partition int32       `json:"partition"`
+	Offset    KafkaOffset `json:"offset"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// TopRule contains the number of clusters affected by the rule in the time
+// window, the number of clusters affected in the previous window of the same
+// length and their difference
+type TopRule struct {
+	RuleID           RuleID   `json:"rule_id"`
+	ErrorKey         ErrorKey `json:"error_key"`
+	Clusters         int      `json:"clusters"`
+	PreviousClusters int      `json:"previous_clusters"`
+	Delta            int      `json:"delta"`
+}
+
+// VoteSummary contains the number of users who liked and disliked the rule
+type VoteSummary struct {
+	Likes    int `json:"likes"`
+	Dislikes int `json:"dislikes"`
+}
+
+// MessageKeyLookup contains everything stored for the Kafka message key, so
+// an upload can be traced from the platform gateway to the stored report
+type MessageKeyLookup struct {
+	Key            string                  `json:"key"`
+	Reports        []MessageKeyReport      `json:"reports"`
+	ConsumerErrors []MessageKeyConsumerErr `json:"consumer_errors"`
+}
+
+// MessageKeyReport is the stored report of the cluster consumed from the
+// message with the looked up key
+type MessageKeyReport struct {
+	OrgID         OrgID        `json:"org_id"`
+	ClusterName   ClusterName  `json:"cluster"`
+	Status        ReportStatus `json:"status"`
+	LastCheckedAt Timestamp    `json:"last_checked_at"`
+	ReportedAt    Timestamp    `json:"reported_at"`
+	KafkaOffset   KafkaOffset  `json:"kafka_offset"`
+}
+
+// MessageKeyConsumerErr is the consumer error recorded for the message with
+// the looked up key
+type MessageKeyConsumerErr struct {
+	Topic      string    `json:"topic"`
+	Partition  int32     `json:"partition"`
+	Offset     int64     `json:"offset"`
+	ProducedAt Timestamp `json:"produced_at"`
+	ConsumedAt Timestamp `json:"consumed_at"`
+	Error      string    `json:"error"`
+}
+
+// DBSchema describes the schema of the deployed database as introspected at
+// runtime together with the migration version the database is at
+type DBSchema struct {
+	MigrationVersion       uint      `json:"migration_version"`
+	LatestMigrationVersion uint      `json:"latest_migration_version"`
+	Tables                 []DBTable `json:"tables"`
+}
+
+// DBTable describes one table of the database
+type DBTable struct {
+	Name    string     `json:"name"`
+	Columns []DBColumn `json:"columns"`
+	Indexes []DBIndex  `json:"indexes"`
+}
+
+// DBColumn describes one column of the database table
+type DBColumn struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Nullable bool   `json:"nullable"`
+	Default  string `json:"default,omitempty"`
+}
+
+// DBIndex describes one index of the database table, primary key is
+// reported as an index too
+type DBIndex struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	Unique  bool     `json:"unique"`
+	Primary bool     `json:"primary"`
+}
+
+// IngestSimulation is the result of running the message through parsing,
+// validation and report assembly done by the consumer without storing
+// anything. RuleHits and ExternalResults are the data that would be stored.
+type IngestSimulation struct {
+	Valid           bool             `json:"valid"`
+	Errors          []string         `json:"errors"`
+	Warnings        []string         `json:"warnings"`
+	Type            string           `json:"type,omitempty"`
+	OrgID           OrgID            `json:"org_id,omitempty"`
+	ClusterName     ClusterName      `json:"cluster,omitempty"`
+	Status          ReportStatus     `json:"status,omitempty"`
+	LastCheckedAt   Timestamp        `json:"last_checked_at,omitempty"`
+	ReportSize      int              `json:"report_size,omitempty"`
+	RuleHits        []ReportItem     `json:"rule_hits"`
+	ExternalResults []ExternalResult `json:"external_results,omitempty"`
+}
+
+// ReportStatus is the result of the analysis of the cluster, it tells apart
+// the cluster without any issue from the cluster that couldn't be analyzed
+type ReportStatus string
+
+const (
+	// ReportStatusAnalyzed means the report was analyzed, a report without
+	// rule hits means no issues were found
+	ReportStatusAnalyzed ReportStatus = "analyzed"
+	// ReportStatusFailed means the analysis of the last report failed
+	ReportStatusFailed ReportStatus = "failed"
+	// ReportStatusNoData means no report has been received from the cluster
+	// yet
+	ReportStatusNoData ReportStatus = "no_data"
+)
+
 // ReportItem represents a single (hit) rule of the string encoded report
 type ReportItem = types.ReportItem
 
@@ -39,3 +39,11 @@ if [ "$STORAGE" == "postgres" ]; then
     CONFIG_FILE=config-devel.toml
     run_unit_tests
 fi
+
+if [ "$STORAGE" == "postgres-docker" ]; then
+    # tests with postgres started in docker container
+    echo "running unit tests with postgres in docker"
+    export INSIGHTS_RESULTS_AGGREGATOR__TESTS_DB="postgres"
+    export INSIGHTS_RESULTS_AGGREGATOR__TESTS_DB_DOCKER="true"
+    run_unit_tests
+fi